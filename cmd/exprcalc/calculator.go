@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser"
+)
+
+// calculator evaluates lines of input, keeping the variables assigned.
+type calculator struct {
+	env     *expressionparser.Environment
+	numbers expressionparser.FormatOptions // of the -format, -precision and -group flags
+}
+
+// errFailed is returned by evalFile when some of its lines failed, each
+// having been reported as it did.
+var errFailed = errors.New("some expressions failed")
+
+// evalFile evaluates each non-blank line of the named file, or of stdin for
+// -, writing each result to stdout and each error, after the file name and
+// line number, to stderr.
+func (c *calculator) evalFile(name string, stdin io.Reader, stdout, stderr io.Writer) error {
+	in := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	failed := false
+	scanner := bufio.NewScanner(in)
+	for n := 1; scanner.Scan(); n++ {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			failed = !c.evalTo(line, fmt.Sprintf("%s:%d: ", name, n), stdout, stderr) || failed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+// evalTo evaluates a line, writing the result to stdout or the error,
+// after prefix, to stderr, and reports whether it succeeded.
+func (c *calculator) evalTo(line, prefix string, stdout, stderr io.Writer) bool {
+	result, err := c.evalLine(line)
+	if err != nil {
+		fmt.Fprint(stderr, prefix+describe(line, err))
+		return false
+	}
+	fmt.Fprintln(stdout, result)
+	return true
+}
+
+// evalLine evaluates a single expression, or an assignment, which it
+// stores in the environment, and formats the result.
+func (c *calculator) evalLine(line string) (string, error) {
+	stmts, err := expressionparser.ParseProgram(line)
+	if err != nil {
+		return "", err
+	}
+	if len(stmts) != 1 {
+		return "", fmt.Errorf("expected one expression or assignment per line, got %d", len(stmts))
+	}
+	if a, ok := stmts[0].(*expressionparser.Assign); ok {
+		value, err := expressionparser.EvalIn(a.Value, c.env)
+		if err != nil {
+			return "", err
+		}
+		c.env.Set(a.Name, value)
+		return a.Name + " = " + c.format(value), nil
+	}
+	value, err := expressionparser.EvalIn(stmts[0], c.env)
+	if err != nil {
+		return "", err
+	}
+	return c.format(value), nil
+}
+
+// format renders a value, a number as the flags choose, and a duration
+// too in the sexagesimal style.
+func (c *calculator) format(value expressionparser.Value) string {
+	if value.Kind() == expressionparser.NumberKind {
+		x, _ := value.AsFloat()
+		return expressionparser.FormatResult(x, c.numbers)
+	}
+	if d, err := value.AsDuration(); err == nil && c.numbers.Style == expressionparser.StyleSexagesimal {
+		return expressionparser.FormatResult(d.Seconds(), c.numbers)
+	}
+	return value.String()
+}
+
+// describe renders err for input as FormatError does, with a caret under
+// the offending column when the error gives its offset.
+func describe(input string, err error) string {
+	lines := strings.Split(expressionparser.FormatError(input, err), "\n")
+	var b strings.Builder
+	fmt.Fprintf(&b, "error: %s\n", lines[0])
+	for _, line := range lines[1:] {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+	return b.String()
+}
+
+// end of file
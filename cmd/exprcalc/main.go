@@ -0,0 +1,131 @@
+// Command exprcalc evaluates expressions:
+//
+//	exprcalc -e "2*(3+4)"          evaluate an expression
+//	exprcalc -f formulas.txt       evaluate each line of a file, - for stdin
+//	exprcalc "(2 + 3) * 5"         evaluate the arguments as one expression
+//	exprcalc                       read expressions line by line
+//
+// -var name=value, which may be repeated, binds a variable for them.
+// -format style prints numbers in the style shortest, fixed, scientific,
+// engineering or fraction, -precision n with n digits after the point, and
+// -group with commas between the thousands; -precision alone means fixed.
+// The exit status is 1 when any expression fails and 2 when the flags are
+// invalid.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser"
+)
+
+func main() {
+	info, err := os.Stdin.Stat()
+	interactive := err == nil && info.Mode()&os.ModeCharDevice != 0
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, interactive))
+}
+
+// run runs exprcalc with the command-line arguments args, returning the exit
+// status. The REPL shows its prompt only when interactive.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, interactive bool) int {
+	flags := flag.NewFlagSet("exprcalc", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	expr := flags.String("e", "", "evaluate `expression`")
+	file := flags.String("f", "", "evaluate each line of `file`, - for stdin")
+	format := flags.String("format", "", "print numbers in `style`: shortest, fixed, scientific, engineering, fraction or sexagesimal")
+	precision := flags.Int("precision", -1, "print numbers with `n` digits after the point, or as few as identify them when negative")
+	group := flags.Bool("group", false, "separate the thousands of numbers with commas")
+	vars := variables{}
+	flags.Var(vars, "var", "bind a variable, as `name=value`; may be repeated")
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 && (*expr != "" || *file != "") {
+		fmt.Fprintln(stderr, "exprcalc: expression arguments cannot be combined with -e or -f")
+		return 2
+	}
+
+	style, ok := numberStyle(*format, *precision)
+	if !ok {
+		fmt.Fprintf(stderr, "exprcalc: unknown -format %q; want shortest, fixed, scientific, engineering, fraction or sexagesimal\n", *format)
+		return 2
+	}
+
+	c := &calculator{env: expressionparser.NewEnvironment(nil), numbers: expressionparser.FormatOptions{Style: style, Precision: *precision, Grouping: *group}}
+	for name, value := range vars {
+		c.env.SetNumber(name, value)
+	}
+	status := 0
+	if flags.NArg() > 0 {
+		*expr = strings.Join(flags.Args(), " ")
+	}
+	if *expr != "" && !c.evalTo(*expr, "", stdout, stderr) {
+		status = 1
+	}
+	if *file != "" {
+		if err := c.evalFile(*file, stdin, stdout, stderr); err != nil {
+			if err != errFailed {
+				fmt.Fprintf(stderr, "exprcalc: %v\n", err)
+			}
+			status = 1
+		}
+	}
+	if *expr == "" && *file == "" {
+		c.repl(stdin, stdout, interactive)
+	}
+	return status
+}
+
+// numberStyle returns the style named by -format, fixed when only
+// -precision is given, and whether the name is known.
+func numberStyle(name string, precision int) (expressionparser.NumberStyle, bool) {
+	if name == "" {
+		if precision >= 0 {
+			return expressionparser.StyleFixed, true
+		}
+		return expressionparser.StyleShortest, true
+	}
+	for style := expressionparser.StyleShortest; style <= expressionparser.StyleSexagesimal; style++ {
+		if style.String() == name {
+			return style, true
+		}
+	}
+	return 0, false
+}
+
+// variables collects the -var flags.
+type variables map[string]float64
+
+// String lists the variables, for the flag package.
+func (v variables) String() string {
+	var pairs []string
+	for name, value := range v {
+		pairs = append(pairs, name+"="+strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set adds the variable of a -var flag.
+func (v variables) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return errors.New("want name=value")
+	}
+	x, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return fmt.Errorf("%s: %q is not a number", name, value)
+	}
+	v[name] = x
+	return nil
+}
+
+// end of file
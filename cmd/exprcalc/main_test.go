@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		args   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{[]string{"-e", "2*(3+4)"}, 0, "14\n", ""},
+		{[]string{"(2", "+ 3)", "*", "5"}, 0, "25\n", ""},
+		{[]string{"-var", "x=1.5", "-var", "y = 2", "-e", "x*y"}, 0, "3\n", ""},
+		{[]string{"-e", "1/3", "-precision", "3"}, 0, "0.333\n", ""},
+		{[]string{"-e", "1234567.5", "-group", "-precision", "1"}, 0, "1,234,567.5\n", ""},
+		{[]string{"-e", "0.75", "-format", "fraction"}, 0, "3/4\n", ""},
+		{[]string{"-e", "0.000125", "-format", "engineering", "-precision", "1"}, 0, "125.0e-06\n", ""},
+		{[]string{"-e", "1234.5", "-format", "scientific"}, 0, "1.2345e+03\n", ""},
+		{[]string{"-e", "1/0"}, 1, "", "error: division by zero in \"1 / 0\" at offset 0\n  1/0\n  ^~~\n"},
+		{[]string{"-f", "-"}, 1, "9\n", "-:2: error: undefined variable zz in \"zz\" at offset 0\n  zz\n  ^~\n"},
+		{[]string{"-e", "1", "2"}, 2, "", "exprcalc: expression arguments cannot be combined with -e or -f\n"},
+		{[]string{"-format", "bogus", "-e", "1"}, 2, "", "exprcalc: unknown -format \"bogus\"; want shortest, fixed, scientific, engineering, fraction or sexagesimal\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		status := run(tt.args, strings.NewReader("3*3\nzz\n"), &stdout, &stderr, false)
+		if status != tt.status || stdout.String() != tt.stdout || stderr.String() != tt.stderr {
+			t.Errorf("exprcalc %q: status %d, output %q, stderr %q; want %d, %q, %q", tt.args, status, stdout.String(), stderr.String(), tt.status, tt.stdout, tt.stderr)
+		}
+	}
+}
+
+func TestRunBadVar(t *testing.T) {
+	for arg, msg := range map[string]string{
+		"x":     `invalid value "x" for flag -var: want name=value`,
+		"=1":    `invalid value "=1" for flag -var: want name=value`,
+		"x=abc": `invalid value "x=abc" for flag -var: x: "abc" is not a number`,
+	} {
+		var stdout, stderr bytes.Buffer
+		status := run([]string{"-var", arg, "-e", "1"}, strings.NewReader(""), &stdout, &stderr, false)
+		if status != 2 || stdout.Len() != 0 || !strings.HasPrefix(stderr.String(), msg+"\nUsage of exprcalc:") {
+			t.Errorf("-var %s: status %d, output %q, stderr %q; want 2 and %q", arg, status, stdout.String(), stderr.String(), msg)
+		}
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "formulas.txt")
+	if err := os.WriteFile(name, []byte("1 + 1\n\nx * 2\n2 +\ny = 3\ny\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	// A failing line is reported with its number, and the rest still evaluated
+	status := run([]string{"-f", name, "-var", "x=4"}, strings.NewReader(""), &stdout, &stderr, false)
+	if status != 1 {
+		t.Errorf("status %d, want 1", status)
+	}
+	if want := "2\n8\ny = 3\n3\n"; stdout.String() != want {
+		t.Errorf("output %q, want %q", stdout.String(), want)
+	}
+	if want := name + ":4: error: expected number, name, string, '(', '[', or unary '-' or '!' but found end of input at offset 3\n  2 +\n     ^\n"; stderr.String() != want {
+		t.Errorf("stderr %q, want %q", stderr.String(), want)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	missing := filepath.Join(dir, "missing.txt")
+	if status := run([]string{"-f", missing}, strings.NewReader(""), &stdout, &stderr, false); status != 1 || !strings.HasPrefix(stderr.String(), "exprcalc: open "+missing+": ") {
+		t.Errorf("-f %s: status %d, stderr %q", missing, status, stderr.String())
+	}
+}
+
+// end of file
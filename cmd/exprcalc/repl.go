@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser"
+)
+
+const replHelp = `Enter an expression to evaluate it, or name = expression to assign to a
+variable for the lines that follow. Commands:
+  :vars        list the variables
+  :dot EXPR    print the tree of EXPR in Graphviz dot format
+  :help        show this help
+  :quit        exit, as does the end of the input
+`
+
+// repl reads lines from in until its end or :quit, evaluating each and
+// writing the result, or the error with a caret under the line, to out.
+// The prompt is shown only when interactive.
+func (c *calculator) repl(in io.Reader, out io.Writer, interactive bool) {
+	scanner := bufio.NewScanner(in)
+	for {
+		if interactive {
+			fmt.Fprint(out, "> ")
+		}
+		if !scanner.Scan() {
+			if interactive {
+				fmt.Fprintln(out)
+			}
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case line == ":quit" || line == ":q":
+			return
+		case line == ":help":
+			fmt.Fprint(out, replHelp)
+		case line == ":vars":
+			for _, name := range c.env.Names() {
+				value, _ := c.env.Get(name)
+				fmt.Fprintf(out, "%s = %s\n", name, c.format(value))
+			}
+		case strings.HasPrefix(line, ":dot "):
+			input := strings.TrimSpace(strings.TrimPrefix(line, ":dot "))
+			expr, err := expressionparser.ParseString(input)
+			if err != nil {
+				fmt.Fprint(out, describe(input, err))
+				break
+			}
+			fmt.Fprint(out, expressionparser.ToDot(expr))
+		case strings.HasPrefix(line, ":"):
+			fmt.Fprintf(out, "unknown command %s; :help lists the commands\n", strings.Fields(line)[0])
+		default:
+			c.evalTo(line, "", out, out)
+		}
+	}
+}
+
+// end of file
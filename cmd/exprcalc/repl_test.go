@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestREPL(t *testing.T) {
+	input := "x = 2\nx * 3\n2 + \n:vars\n:foo\n\n1/0\n:quit\n5\n"
+	want := `x = 2
+6
+error: expected number, name, string, '(', '[', or unary '-' or '!' but found end of input at offset 3
+  2 +
+     ^
+x = 2
+unknown command :foo; :help lists the commands
+error: division by zero in "1 / 0" at offset 0
+  1/0
+  ^~~
+`
+	var stdout, stderr bytes.Buffer
+	if status := run(nil, strings.NewReader(input), &stdout, &stderr, false); status != 0 {
+		t.Errorf("exit status %d, want 0", status)
+	}
+	if got := stdout.String(); got != want {
+		t.Errorf("output\n%s\nwant\n%s", got, want)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("stderr %q, want nothing", stderr.String())
+	}
+}
+
+func TestREPLPrompt(t *testing.T) {
+	tests := []struct {
+		input       string
+		interactive bool
+		want        string
+	}{
+		// The end of the input, without a newline, ends the REPL cleanly
+		{"1+1", true, "> 2\n> \n"},
+		{"1+1", false, "2\n"},
+		{"", true, "> \n"},
+		{":q\n", true, "> "},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		status := run(nil, strings.NewReader(tt.input), &stdout, &stderr, tt.interactive)
+		if status != 0 || stdout.String() != tt.want || stderr.Len() != 0 {
+			t.Errorf("%q interactive %v: status %d, output %q, stderr %q; want 0, %q", tt.input, tt.interactive, status, stdout.String(), stderr.String(), tt.want)
+		}
+	}
+}
+
+func TestREPLCommands(t *testing.T) {
+	var stdout bytes.Buffer
+	run(nil, strings.NewReader(":help\n:dot 1 + x\n:dot (1\n"), &stdout, &stdout, false)
+	got := stdout.String()
+	if !strings.HasPrefix(got, replHelp) {
+		t.Errorf(":help printed %q, want the help", got)
+	}
+	for _, want := range []string{"digraph", `label="+"`, "error: expected ')' to close '(' at offset 0"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output %q does not contain %q", got, want)
+		}
+	}
+}
+
+// end of file
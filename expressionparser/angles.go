@@ -0,0 +1,90 @@
+package expressionparser
+
+import "math"
+
+// AngleUnit is the unit in which the trigonometric builtins measure angles.
+type AngleUnit int
+
+const (
+	// Radians is the default unit.
+	Radians AngleUnit = iota
+
+	// Degrees makes sin, cos and tan take their argument in degrees, and
+	// asin, acos, atan and atan2 return degrees, so sin(90) is 1 and
+	// asin(1) is 90.
+	Degrees
+)
+
+// WithAngleUnit sets the unit of angles for the trigonometric builtins in
+// Eval, EvalValue and the evaluations built on them. Nothing else depends
+// on it: rad and deg convert between the units whatever it is, and
+// EvalComplex and Program.Run always work in radians. In degrees the
+// angles whose sine, cosine or tangent is 0, 1/2 or 1 in magnitude give
+// those values exactly, so sin(180) is 0 rather than 1.2e-16, and the
+// inverse functions give the exact angle back.
+func WithAngleUnit(unit AngleUnit) Option {
+	return func(c *config) {
+		c.angleUnit = unit
+	}
+}
+
+// Conversion factors between the units
+const (
+	radiansPerDegree = math.Pi / 180
+	degreesPerRadian = 180 / math.Pi
+)
+
+// Exact values of sine and cosine at multiples of 30 degrees, and of
+// tangent at multiples of 45 degrees, by multiple; the others are irrational.
+var (
+	sinMultiples = map[int]float64{0: 0, 1: 0.5, 3: 1, 5: 0.5, 6: 0, 7: -0.5, 9: -1, 11: -0.5}
+	cosMultiples = map[int]float64{0: 1, 2: 0.5, 3: 0, 4: -0.5, 6: -1, 8: -0.5, 9: 0, 10: 0.5}
+	tanMultiples = map[int]float64{0: 0, 1: 1, 3: -1, 4: 0, 5: 1, 7: -1}
+)
+
+// Exact angles in degrees of the inverse functions at the values above
+var (
+	asinExact = map[float64]float64{0: 0, 0.5: 30, 1: 90, -0.5: -30, -1: -90}
+	acosExact = map[float64]float64{1: 0, 0.5: 60, 0: 90, -0.5: 120, -1: 180}
+	atanExact = map[float64]float64{0: 0, 1: 45, -1: -45}
+)
+
+// degreesArg adapts a trigonometric function to take its argument in
+// degrees, with exact results at multiples of step listed in exact.
+func degreesArg(fn func(float64) float64, step float64, exact map[int]float64) func(args []float64) (float64, error) {
+	n := int(360 / step)
+	return func(args []float64) (float64, error) {
+		// Reducing first is exact and keeps large angles accurate
+		x := math.Mod(args[0], 360)
+		if q := x / step; q == math.Trunc(q) {
+			if v, ok := exact[(int(q)%n+n)%n]; ok {
+				return v, nil
+			}
+		}
+		return fn(x * radiansPerDegree), nil
+	}
+}
+
+// degreesResult adapts an inverse trigonometric function to return degrees,
+// with exact angles at the arguments listed in exact.
+func degreesResult(fn func(float64) float64, exact map[float64]float64) func(args []float64) (float64, error) {
+	return func(args []float64) (float64, error) {
+		if v, ok := exact[args[0]]; ok {
+			return v, nil
+		}
+		return fn(args[0]) * degreesPerRadian, nil
+	}
+}
+
+// atan2Degrees is atan2 returning degrees.
+func atan2Degrees(args []float64) (float64, error) {
+	return math.Atan2(args[0], args[1]) * degreesPerRadian, nil
+}
+
+// inDegrees attaches the degrees variant of a trigonometric builtin.
+func inDegrees(b builtin, fn func(args []float64) (float64, error)) builtin {
+	b.degrees = fn
+	return b
+}
+
+// end of file
@@ -0,0 +1,58 @@
+package expressionparser
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDegrees(t *testing.T) {
+	degrees := NewEvaluator(WithAngleUnit(Degrees))
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"sin(90)", 1},
+		{"sin(180)", 0},
+		{"sin(-30)", -0.5},
+		{"cos(60)", 0.5},
+		{"cos(90)", 0},
+		{"tan(45)", 1},
+		{"tan(135)", -1},
+		{"asin(1)", 90},
+		{"acos(0.5)", 60},
+		{"acos(-1)", 180},
+		{"atan(1)", 45},
+		{"atan2(1, -1)", 135},
+		{"asin(sin(37))", 37},
+		{"sin(37)", math.Sin(37 * math.Pi / 180)},
+	}
+	for _, tt := range tests {
+		got, err := degrees.Eval(mustParse(t, tt.input))
+		if err != nil || math.Abs(got-tt.want) > 1e-14 {
+			t.Errorf("%s in degrees = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+	if got, _ := Evaluate("sin(90)"); got != math.Sin(90) {
+		t.Errorf("sin(90) in radians = %v, want %v", got, math.Sin(90))
+	}
+}
+
+func TestDegreesAffectsOnlyTrigonometry(t *testing.T) {
+	degrees := NewEvaluator(WithAngleUnit(Degrees))
+	for _, input := range []string{"exp(1)", "ln(10)", "log(8, 2)", "sqrt(2)", "pow(2, 0.5)", "hypot(3, 4)", "rad(180)", "deg(1)", "deg(rad(45))"} {
+		expr := mustParse(t, input)
+		want, wantErr := Eval(expr)
+		got, err := degrees.Eval(expr)
+		if !sameResult(got, err, want, wantErr) {
+			t.Errorf("%s in degrees = %v, %v; in radians %v, %v", input, got, err, want, wantErr)
+		}
+	}
+	if got, _ := Evaluate("rad(180)"); got != math.Pi {
+		t.Errorf("rad(180) = %v, want pi", got)
+	}
+	if got, _ := Evaluate("deg(rad(45))"); got != 45 {
+		t.Errorf("deg(rad(45)) = %v, want 45", got)
+	}
+}
+
+// end of file
@@ -0,0 +1,177 @@
+package expressionparser
+
+// arenaChunk is the number of nodes of each type an Arena allocates at once.
+const arenaChunk = 128
+
+// Arena holds the nodes of the trees parsed WithArena: numbers, variables,
+// operators and calls are taken from chunks of 128 allocated together rather
+// than allocated one at a time, so that parsing many short-lived
+// expressions makes a handful of allocations instead of one per node.
+//
+// The trees belong to the arena. Release hands their nodes out again to
+// the trees parsed after it, so a tree must not be used, or kept, once the
+// arena it was parsed into is released; Clone copies a tree out of it. An
+// Arena is not safe for concurrent use, and by default, without
+// WithArena, every node is allocated on its own and lives as long as it is
+// referenced.
+type Arena struct {
+	numbers   [][]Number
+	variables [][]Variable
+	binaries  [][]BinaryOp
+	unaries   [][]UnaryOp
+	calls     [][]FunctionCall
+
+	numberAt, variableAt, binaryAt, unaryAt, callAt arenaCursor
+}
+
+// arenaCursor locates the next free node of one type in an Arena.
+type arenaCursor struct {
+	chunk, next int
+}
+
+// NewArena returns an empty arena.
+func NewArena() *Arena {
+	return &Arena{}
+}
+
+// WithArena makes the parser allocate nodes from a, which then owns the
+// trees parsed.
+func WithArena(a *Arena) Option {
+	return func(c *config) {
+		c.arena = a
+	}
+}
+
+// Release makes the nodes of every tree parsed into the arena free for
+// trees parsed later, keeping the chunks. The nodes are zeroed, so that
+// they keep nothing alive, and a tree used in error after Release reads
+// as literals 0 and operators without operands rather than as the
+// expression it was.
+func (a *Arena) Release() {
+	for i := 0; i <= a.numberAt.chunk && i < len(a.numbers); i++ {
+		clear(a.numbers[i])
+	}
+	for i := 0; i <= a.variableAt.chunk && i < len(a.variables); i++ {
+		clear(a.variables[i])
+	}
+	for i := 0; i <= a.binaryAt.chunk && i < len(a.binaries); i++ {
+		clear(a.binaries[i])
+	}
+	for i := 0; i <= a.unaryAt.chunk && i < len(a.unaries); i++ {
+		clear(a.unaries[i])
+	}
+	for i := 0; i <= a.callAt.chunk && i < len(a.calls); i++ {
+		clear(a.calls[i])
+	}
+	a.numberAt, a.variableAt, a.binaryAt, a.unaryAt, a.callAt = arenaCursor{}, arenaCursor{}, arenaCursor{}, arenaCursor{}, arenaCursor{}
+}
+
+// advance moves the cursor past the node it located, returning where that
+// was.
+func (c *arenaCursor) advance() (chunk, next int) {
+	chunk, next = c.chunk, c.next
+	if c.next++; c.next == arenaChunk {
+		c.chunk, c.next = c.chunk+1, 0
+	}
+	return chunk, next
+}
+
+// number returns a zero Number from the arena.
+func (a *Arena) number() *Number {
+	if a.numberAt.chunk == len(a.numbers) {
+		a.numbers = append(a.numbers, make([]Number, arenaChunk))
+	}
+	chunk, next := a.numberAt.advance()
+	return &a.numbers[chunk][next]
+}
+
+// variable returns a zero Variable from the arena.
+func (a *Arena) variable() *Variable {
+	if a.variableAt.chunk == len(a.variables) {
+		a.variables = append(a.variables, make([]Variable, arenaChunk))
+	}
+	chunk, next := a.variableAt.advance()
+	return &a.variables[chunk][next]
+}
+
+// binary returns a zero BinaryOp from the arena.
+func (a *Arena) binary() *BinaryOp {
+	if a.binaryAt.chunk == len(a.binaries) {
+		a.binaries = append(a.binaries, make([]BinaryOp, arenaChunk))
+	}
+	chunk, next := a.binaryAt.advance()
+	return &a.binaries[chunk][next]
+}
+
+// unary returns a zero UnaryOp from the arena.
+func (a *Arena) unary() *UnaryOp {
+	if a.unaryAt.chunk == len(a.unaries) {
+		a.unaries = append(a.unaries, make([]UnaryOp, arenaChunk))
+	}
+	chunk, next := a.unaryAt.advance()
+	return &a.unaries[chunk][next]
+}
+
+// call returns a zero FunctionCall from the arena.
+func (a *Arena) call() *FunctionCall {
+	if a.callAt.chunk == len(a.calls) {
+		a.calls = append(a.calls, make([]FunctionCall, arenaChunk))
+	}
+	chunk, next := a.callAt.advance()
+	return &a.calls[chunk][next]
+}
+
+// newNumber returns a Number of the tree being parsed.
+func (p *Parser) newNumber(value float64) *Number {
+	if p.cfg.arena == nil {
+		return Num(value)
+	}
+	n := p.cfg.arena.number()
+	n.Value = value
+	return n
+}
+
+// newVariable returns a Variable of the tree being parsed.
+func (p *Parser) newVariable(name string) *Variable {
+	if p.cfg.arena == nil {
+		return Var(name)
+	}
+	v := p.cfg.arena.variable()
+	v.Name = name
+	return v
+}
+
+// newBinary returns a BinaryOp of the tree being parsed, spanning its
+// operands.
+func (p *Parser) newBinary(left Expr, op Token, right Expr) *BinaryOp {
+	span := joinSpans(left, right)
+	if p.cfg.arena == nil {
+		return &BinaryOp{Left: left, Op: op, Right: right, Span: span}
+	}
+	b := p.cfg.arena.binary()
+	*b = BinaryOp{Left: left, Op: op, Right: right, Span: span}
+	return b
+}
+
+// newUnary returns a UnaryOp of the tree being parsed.
+func (p *Parser) newUnary(op Token, operand Expr, span Span) *UnaryOp {
+	if p.cfg.arena == nil {
+		return &UnaryOp{Op: op, Operand: operand, Span: span}
+	}
+	u := p.cfg.arena.unary()
+	*u = UnaryOp{Op: op, Operand: operand, Span: span}
+	return u
+}
+
+// newCall returns a FunctionCall without arguments of the tree being
+// parsed.
+func (p *Parser) newCall(name string) *FunctionCall {
+	if p.cfg.arena == nil {
+		return &FunctionCall{Name: name}
+	}
+	c := p.cfg.arena.call()
+	c.Name = name
+	return c
+}
+
+// end of file
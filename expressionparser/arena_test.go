@@ -0,0 +1,104 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestArena(t *testing.T) {
+	arena := NewArena()
+	// Enough nodes to fill several chunks
+	long := strings.Repeat("-x * 2 + f(y, 1) - ", 100) + "1"
+	for _, input := range append([]string{long, "1", "sqrt(x) + -y ^ 2", "let a = 1 in a"}, evalCorpus...) {
+		want := mustParse(t, input)
+		got, err := ParseString(input, WithArena(arena))
+		if err != nil {
+			t.Fatalf("ParseString(%q) into an arena: %v", input, err)
+		}
+		if !Equal(got, want) || SpanOf(got) != SpanOf(want) {
+			t.Errorf("ParseString(%q) into an arena = %s, want %s", input, ToSExpr(got), ToSExpr(want))
+		}
+	}
+	if len(arena.binaries) < 2 || len(arena.numbers) < 2 {
+		t.Errorf("arena of %d binary and %d number chunks, want several", len(arena.binaries), len(arena.numbers))
+	}
+
+	// A clone outlives the release, and the tree itself reads as zeros
+	tree, _ := ParseString("x * 2 + 3", WithArena(arena))
+	kept := Clone(tree)
+	chunks := len(arena.binaries)
+	arena.Release()
+	if got := Format(kept); got != "x * 2 + 3" {
+		t.Errorf("clone after Release = %q", got)
+	}
+	if b := tree.(*BinaryOp); b.Left != nil || b.Right != nil {
+		t.Errorf("released tree still holds %s", ToSExpr(tree))
+	}
+	// The chunks are reused
+	for i := 0; i < 50; i++ {
+		ParseString("a + b * c - d", WithArena(arena))
+	}
+	if len(arena.binaries) != chunks {
+		t.Errorf("parsing after Release grew the arena from %d to %d binary chunks", chunks, len(arena.binaries))
+	}
+}
+
+func TestArenaAllocs(t *testing.T) {
+	const input = "a * 2 + b * 3 - sqrt(c) / (d + 1) - -e"
+	plain := testing.AllocsPerRun(100, func() { ParseString(input) })
+	arena := NewArena()
+	pooled := testing.AllocsPerRun(100, func() {
+		ParseString(input, WithArena(arena))
+		arena.Release()
+	})
+	if pooled >= plain {
+		t.Errorf("parsing into an arena allocates %v times, without one %v", pooled, plain)
+	}
+}
+
+// TestArenaConcurrent parses into an arena for each goroutine, as an Arena
+// must not be shared; run it with -race.
+func TestArenaConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			arena := NewArena()
+			for i := 0; i < 200; i++ {
+				input := fmt.Sprintf("x * %d + y - f(%d, z)", g, i)
+				expr, err := ParseString(input, WithArena(arena))
+				if err != nil || Format(expr) != input {
+					t.Errorf("ParseString(%q) into an arena = %v, %v", input, expr, err)
+					return
+				}
+				if i%10 == 9 {
+					arena.Release()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func BenchmarkParseArena(b *testing.B) {
+	const input = "a * 2 + b * 3 - sqrt(c) / (d + 1) - -e"
+	b.Run("plain", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ParseString(input)
+		}
+	})
+	b.Run("arena", func(b *testing.B) {
+		arena := NewArena()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ParseString(input, WithArena(arena))
+			arena.Release()
+		}
+	})
+}
+
+// end of file
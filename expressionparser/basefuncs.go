@@ -0,0 +1,97 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Prefixes of the numbers hex, oct and bin write, which frombase accepts
+var basePrefixes = map[int]string{16: "0x", 8: "0o", 2: "0b"}
+
+// prefixedBase returns the builtin writing a number in base with its
+// prefix, as hex(255) gives "0xff"; an optional second argument pads the
+// digits with zeros to that width, so that hex(255, 4) is "0x00ff".
+func prefixedBase(name string, base int) func(args []Value) (Value, error) {
+	return func(args []Value) (Value, error) {
+		s, err := formatBase(name, base, args[0].num, args[1:])
+		if err != nil {
+			return Value{}, err
+		}
+		return StringValue(basePrefixes[base] + s), nil
+	}
+}
+
+// toBase writes a number in the base from 2 to 36 given second, with digits
+// from 0 to 9 and then a to z and no prefix, so that tobase(255, 36) is
+// "73"; an optional third argument pads it to a width as hex does.
+func toBase(args []Value) (Value, error) {
+	base, err := radix("tobase", args[1].num)
+	if err != nil {
+		return Value{}, err
+	}
+	s, err := formatBase("tobase", base, args[0].num, args[2:])
+	if err != nil {
+		return Value{}, err
+	}
+	return StringValue(s), nil
+}
+
+// formatBase writes x, which must be a whole number from 0 to 2^63-1, in
+// base for the named builtin, padded to the width given in pad, if any.
+func formatBase(name string, base int, x float64, pad []Value) (string, error) {
+	if x != math.Trunc(x) || x < 0 || x >= math.MaxInt64 {
+		return "", withCode(CodeOutsideDomain, fmt.Errorf("%s: %s is not a whole number from 0 to 2^63-1", name, formatNumber(x)))
+	}
+	s := strconv.FormatInt(int64(x), base)
+	if len(pad) == 0 {
+		return s, nil
+	}
+	width := pad[0].num
+	if width != math.Trunc(width) || width < 0 || width > 64 {
+		return "", withCode(CodeOutsideDomain, fmt.Errorf("%s: width %s is not a whole number from 0 to 64", name, formatNumber(width)))
+	}
+	if n := int(width) - len(s); n > 0 {
+		s = strings.Repeat("0", n) + s
+	}
+	return s, nil
+}
+
+// fromBase reads a string of digits in the base from 2 to 36 given second,
+// in either case and with the prefix hex, oct or bin writes allowed for
+// bases 16, 8 and 2, so that frombase("ff", 16) and frombase("0xff", 16)
+// are 255. The number must fit in an int64 and be one a float64 holds
+// exactly.
+func fromBase(args []Value) (Value, error) {
+	base, err := radix("frombase", args[1].num)
+	if err != nil {
+		return Value{}, err
+	}
+	s := args[0].str
+	digits := s
+	if prefix, ok := basePrefixes[base]; ok && len(digits) > len(prefix) && strings.EqualFold(digits[:len(prefix)], prefix) {
+		digits = digits[len(prefix):]
+	}
+	n, err := strconv.ParseUint(digits, base, 63)
+	if err != nil {
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			return Value{}, withCode(CodeOverflow, fmt.Errorf("frombase: %q in base %d does not fit in an int64", s, base))
+		}
+		return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("frombase: %q is not a number in base %d", s, base))
+	}
+	if x := float64(n); x >= math.MaxInt64 || uint64(x) != n {
+		return Value{}, withCode(CodeOverflow, fmt.Errorf("frombase: %q in base %d is %d, which a number does not hold exactly", s, base, n))
+	}
+	return NumberValue(float64(n)), nil
+}
+
+// radix checks the base argument of the named builtin.
+func radix(name string, base float64) (int, error) {
+	if base != math.Trunc(base) || base < 2 || base > 36 {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("%s: base %s is not a whole number from 2 to 36", name, formatNumber(base)))
+	}
+	return int(base), nil
+}
+
+// end of file
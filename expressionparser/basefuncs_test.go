@@ -0,0 +1,92 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBaseFormatting(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // the result as Value.String renders it
+	}{
+		{"hex(255)", `"0xff"`},
+		{"bin(10)", `"0b1010"`},
+		{"oct(8)", `"0o10"`},
+		{"hex(0)", `"0x0"`},
+		{"hex(2^53)", `"0x20000000000000"`},
+		{"tobase(255, 36)", `"73"`},
+		{"tobase(35, 36)", `"z"`},
+		{"tobase(0, 2)", `"0"`},
+		{"hex(x)", `"0x3"`},
+		// Padding to a width, never truncating
+		{"hex(255, 4)", `"0x00ff"`},
+		{"bin(5, 8)", `"0b00000101"`},
+		{"oct(8, 3)", `"0o010"`},
+		{"hex(4096, 2)", `"0x1000"`},
+		{"tobase(5, 2, 6)", `"000101"`},
+		// frombase accepts either case and the matching prefix
+		{`frombase("ff", 16)`, "255"},
+		{`frombase("0xFF", 16)`, "255"},
+		{`frombase("0b101", 2)`, "5"},
+		{`frombase("zz", 36)`, "1295"},
+	}
+	for _, tt := range tests {
+		got, err := EvalValue(mustParse(t, tt.input), map[string]Value{"x": NumberValue(3)})
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestBaseRoundTrip(t *testing.T) {
+	for _, n := range []float64{0, 1, 7, 255, 48879, 123456, 1<<53 - 1} {
+		for _, pair := range []struct {
+			format string
+			base   int
+		}{{"hex(%v)", 16}, {"bin(%v)", 2}, {"oct(%v)", 8}, {"tobase(%v, 36)", 36}, {"tobase(%v, 7, 20)", 7}} {
+			input := fmt.Sprintf("frombase(%s, %d)", fmt.Sprintf(pair.format, n), pair.base)
+			if got, err := Evaluate(input); err != nil || got != n {
+				t.Errorf("%s = %v, %v; want %v", input, got, err, n)
+			}
+		}
+	}
+}
+
+func TestBaseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{"hex(-1)", CodeOutsideDomain, "hex: -1 is not a whole number from 0 to 2^63-1"},
+		{"bin(1.5)", CodeOutsideDomain, "bin: 1.5 is not a whole number from 0 to 2^63-1"},
+		{"oct(2^63)", CodeOutsideDomain, "oct: 9.223372036854776e+18 is not a whole number from 0 to 2^63-1"},
+		{"tobase(-5, 2)", CodeOutsideDomain, "tobase: -5 is not a whole number from 0 to 2^63-1"},
+		{"tobase(10, 1)", CodeOutsideDomain, "tobase: base 1 is not a whole number from 2 to 36"},
+		{"tobase(10, 37)", CodeOutsideDomain, "tobase: base 37 is not a whole number from 2 to 36"},
+		{"hex(1, -1)", CodeOutsideDomain, "hex: width -1 is not a whole number from 0 to 64"},
+		{`frombase("-1", 10)`, CodeOutsideDomain, `frombase: "-1" is not a number in base 10`},
+		{`frombase("g", 16)`, CodeOutsideDomain, `frombase: "g" is not a number in base 16`},
+		{`frombase("", 16)`, CodeOutsideDomain, `frombase: "" is not a number in base 16`},
+		{`frombase("0x10", 2)`, CodeOutsideDomain, `frombase: "0x10" is not a number in base 2`},
+		{`frombase("7fffffffffffffff", 16)`, CodeOverflow, `frombase: "7fffffffffffffff" in base 16 is 9223372036854775807, which a number does not hold exactly`},
+	}
+	for _, tt := range tests {
+		_, err := EvalValue(mustParse(t, tt.input), nil)
+		if ErrorCode(err) != tt.code {
+			t.Errorf("%s: error %v, want %s", tt.input, err, tt.code)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.msg+" in ") {
+			t.Errorf("%s: error %q, want %q", tt.input, err, tt.msg)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,193 @@
+package expressionparser
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// WithCollectRowErrors makes EvalBatch carry on past rows that fail,
+// giving them NaN and reporting them all in a *BatchError, instead of
+// stopping at the first.
+func WithCollectRowErrors() Option {
+	return func(c *config) {
+		c.collectRowErrors = true
+	}
+}
+
+// WithParallelBatch makes EvalBatch split large batches between as many
+// goroutines as GOMAXPROCS allows, each evaluating a contiguous range of
+// rows with bindings of its own. Results are the same as evaluating the
+// rows in order; when a row fails, the other workers are cancelled and the
+// error reported is that of the lowest failing row found.
+func WithParallelBatch() Option {
+	return func(c *config) {
+		c.batchWorkers = runtime.GOMAXPROCS(0)
+	}
+}
+
+// RowError reports the failure of one row of EvalBatch.
+type RowError struct {
+	Row int
+	Err error
+}
+
+// Error names the row and the failure.
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+// Unwrap returns the error of the row.
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError lists the rows of EvalBatch that failed, in order, when
+// row errors are collected.
+type BatchError struct {
+	Rows []*RowError
+}
+
+// Error counts the failed rows and shows the first.
+func (e *BatchError) Error() string {
+	if len(e.Rows) == 1 {
+		return e.Rows[0].Error()
+	}
+	return fmt.Sprintf("%d rows failed, the first at %v", len(e.Rows), e.Rows[0])
+}
+
+// Unwrap returns the errors of the rows.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Rows))
+	for i, row := range e.Rows {
+		errs[i] = row
+	}
+	return errs
+}
+
+// EvalBatch evaluates an expression over columns of variable values with
+// the default Evaluator.
+func EvalBatch(expr Expr, cols map[string][]float64) ([]float64, error) {
+	return defaultEvaluator.EvalBatch(expr, cols)
+}
+
+// EvalBatch evaluates expr once per row of cols, a table whose columns
+// hold the values of the variables named by their keys, and returns the
+// results by row. All columns must have the same length; variables without
+// a column take the values set on the evaluator. The tree is compiled once
+// with CompileFunc and the rows then reuse a single set of bindings, so
+// evaluating a row allocates nothing for them.
+//
+// A failing row stops the evaluation with a *RowError giving its index,
+// or, WithCollectRowErrors, gives NaN and is reported with the other
+// failures in a *BatchError alongside the results. WithParallelBatch
+// spreads large batches over several goroutines.
+func (ev *Evaluator) EvalBatch(expr Expr, cols map[string][]float64) ([]float64, error) {
+	names := make([]string, 0, len(cols))
+	for name := range cols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := 0
+	for i, name := range names {
+		if i == 0 {
+			rows = len(cols[name])
+		} else if len(cols[name]) != rows {
+			return nil, withCode(CodeInvalidArgument, fmt.Errorf("column %s has %d rows, but column %s has %d", name, len(cols[name]), names[0], rows))
+		}
+	}
+
+	fn, err := ev.CompileFunc(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]float64, rows)
+	var failed []*RowError
+	workers := ev.cfg.batchWorkers
+	if workers > rows/minRowsPerWorker {
+		workers = rows / minRowsPerWorker
+	}
+	if workers <= 1 {
+		failed = ev.evalRows(context.Background(), fn, names, cols, results, 0)
+	} else {
+		failed = ev.evalRowsParallel(fn, names, cols, results, workers)
+	}
+
+	if failed == nil {
+		return results, nil
+	}
+	if !ev.cfg.collectRowErrors {
+		return nil, failed[0]
+	}
+	return results, &BatchError{Rows: failed}
+}
+
+// Rows below which a batch is not worth giving another worker
+const minRowsPerWorker = 1024
+
+// evalRows evaluates fn over the rows of cols that results covers, from
+// row first on, with bindings of its own. Unless row errors are collected
+// it stops at the first failure, or, checking at intervals, when ctx is
+// cancelled.
+func (ev *Evaluator) evalRows(ctx context.Context, fn func(map[string]float64) (float64, error), names []string, cols map[string][]float64, results []float64, first int) []*RowError {
+	vars := make(map[string]float64, len(ev.vars)+len(cols))
+	for name, value := range ev.vars {
+		vars[name] = value
+	}
+
+	var failed []*RowError
+	for i := range results {
+		if i%minRowsPerWorker == 0 && ctx.Err() != nil {
+			return failed
+		}
+		row := first + i
+		for _, name := range names {
+			vars[name] = cols[name][row]
+		}
+		result, err := fn(vars)
+		if err != nil {
+			failed = append(failed, &RowError{Row: row, Err: err})
+			if !ev.cfg.collectRowErrors {
+				return failed
+			}
+			result = math.NaN()
+		}
+		results[i] = result
+	}
+	return failed
+}
+
+// evalRowsParallel splits the rows between workers evaluating contiguous
+// ranges. Unless row errors are collected, the first failure cancels the
+// others, and the failures found are returned in row order.
+func (ev *Evaluator) evalRowsParallel(fn func(map[string]float64) (float64, error), names []string, cols map[string][]float64, results []float64, workers int) []*RowError {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	parts := make([][]*RowError, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo, hi := len(results)*w/workers, len(results)*(w+1)/workers
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			parts[w] = ev.evalRows(ctx, fn, names, cols, results[lo:hi], lo)
+			if parts[w] != nil && !ev.cfg.collectRowErrors {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failed []*RowError
+	for _, part := range parts {
+		failed = append(failed, part...)
+	}
+	return failed
+}
+
+// end of file
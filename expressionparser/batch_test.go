@@ -0,0 +1,204 @@
+package expressionparser
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"testing"
+)
+
+// batchColumns returns columns x and y of n rows.
+func batchColumns(n int) map[string][]float64 {
+	cols := map[string][]float64{"x": make([]float64, n), "y": make([]float64, n)}
+	for i := range n {
+		cols["x"][i] = float64(i)
+		cols["y"][i] = float64(i%7) - 3
+	}
+	return cols
+}
+
+const batchInput = "x * x + 3 * x * y - sqrt(abs(y)) / (1 + max(x, y))"
+
+func TestEvalBatch(t *testing.T) {
+	expr := mustParse(t, batchInput)
+	cols := batchColumns(100)
+	got, err := EvalBatch(expr, cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range got {
+		want, _ := EvalWithVars(expr, map[string]float64{"x": cols["x"][i], "y": cols["y"][i]})
+		if got[i] != want {
+			t.Errorf("row %d = %v, want %v", i, got[i], want)
+		}
+	}
+
+	// Variables without a column come from the evaluator
+	ev := NewEvaluator()
+	ev.SetVar("k", 10)
+	if got, err := ev.EvalBatch(mustParse(t, "x * k"), map[string][]float64{"x": {1, 2}}); err != nil || len(got) != 2 || got[1] != 20 {
+		t.Errorf("x * k with k set = %v, %v; want [10 20]", got, err)
+	}
+	if got, err := EvalBatch(mustParse(t, "1 + 1"), nil); err != nil || len(got) != 0 {
+		t.Errorf("EvalBatch without columns = %v, %v; want no rows", got, err)
+	}
+}
+
+func TestEvalBatchErrors(t *testing.T) {
+	expr := mustParse(t, "x / y")
+	if _, err := EvalBatch(expr, map[string][]float64{"x": {1, 2}, "y": {1}}); ErrorCode(err) != CodeInvalidArgument {
+		t.Errorf("columns of unequal length: error %v, want %s", err, CodeInvalidArgument)
+	}
+
+	cols := map[string][]float64{"x": {1, 2, 3, 4}, "y": {1, 0, 2, 0}}
+	_, err := EvalBatch(expr, cols)
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Row != 1 || !errors.Is(err, ErrDivisionByZero) {
+		t.Fatalf("error %v, want a *RowError for row 1", err)
+	}
+	if want := `row 1: division by zero in "x / y" at offset 0`; err.Error() != want {
+		t.Errorf("error %q, want %q", err, want)
+	}
+
+	got, err := NewEvaluator(WithCollectRowErrors()).EvalBatch(expr, cols)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Rows) != 2 || batchErr.Rows[0].Row != 1 || batchErr.Rows[1].Row != 3 {
+		t.Fatalf("collecting row errors: error %v, want a *BatchError for rows 1 and 3", err)
+	}
+	if len(got) != 4 || got[0] != 1 || !math.IsNaN(got[1]) || got[2] != 1.5 || !math.IsNaN(got[3]) {
+		t.Errorf("collecting row errors = %v, want [1 NaN 1.5 NaN]", got)
+	}
+	if !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("error %v does not unwrap to ErrDivisionByZero", err)
+	}
+}
+
+func TestEvalBatchAllocations(t *testing.T) {
+	// Rows share one map of bindings; a row allocates only the frame of the
+	// compiled function, and the arguments of any calls
+	expr := mustParse(t, "x * x + 3 * x * y - y / 2")
+	allocs := func(rows int) float64 {
+		cols := batchColumns(rows)
+		return testing.AllocsPerRun(10, func() {
+			if _, err := EvalBatch(expr, cols); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	if perRow := (allocs(10010) - allocs(10)) / 10000; perRow > 1 {
+		t.Errorf("EvalBatch allocates %v times per row, want at most 1", perRow)
+	}
+}
+
+func BenchmarkEvalBatch(b *testing.B) {
+	expr, err := ParseString(batchInput)
+	if err != nil {
+		b.Fatal(err)
+	}
+	cols := batchColumns(10000)
+	b.Run("EvalBatch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := EvalBatch(expr, cols); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("EvalWithVars", func(b *testing.B) {
+		b.ReportAllocs()
+		results := make([]float64, 10000)
+		for i := 0; i < b.N; i++ {
+			for row := range results {
+				vars := map[string]float64{"x": cols["x"][row], "y": cols["y"][row]}
+				value, err := EvalWithVars(expr, vars)
+				if err != nil {
+					b.Fatal(err)
+				}
+				results[row] = value
+			}
+		}
+	})
+}
+
+func TestEvalBatchParallel(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	const rows = 100000
+	expr := mustParse(t, "x / (y + 3) + 1")
+	cols := batchColumns(rows)
+	parallel := NewEvaluator(WithParallelBatch())
+	if _, err := parallel.EvalBatch(expr, cols); err == nil {
+		t.Fatal("y + 3 is 0 on every seventh row, but EvalBatch did not fail")
+	}
+
+	// Without the failing rows, the results land in their rows
+	for i := range cols["y"] {
+		if cols["y"][i] == -3 {
+			cols["y"][i] = 1
+		}
+	}
+	want, err := EvalBatch(expr, cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parallel.EvalBatch(expr, cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("row %d = %v in parallel, want %v", i, got[i], want[i])
+		}
+	}
+
+	// A failing row in the middle cancels the other workers and is reported
+	const middle = rows/2 + 17
+	cols["y"][middle] = -3
+	_, err = parallel.EvalBatch(expr, cols)
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Row != middle || !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("failing row %d in parallel: error %v", middle, err)
+	}
+
+	collect := NewEvaluator(WithParallelBatch(), WithCollectRowErrors())
+	cols["y"][10] = -3
+	cols["y"][rows-1] = -3
+	got, err = collect.EvalBatch(expr, cols)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Rows) != 3 {
+		t.Fatalf("collecting in parallel: error %v, want rows 10, %d and %d", err, middle, rows-1)
+	}
+	for i, row := range []int{10, middle, rows - 1} {
+		if batchErr.Rows[i].Row != row || !math.IsNaN(got[row]) {
+			t.Errorf("failure %d is row %d giving %v, want row %d giving NaN", i, batchErr.Rows[i].Row, got[row], row)
+		}
+	}
+	if got[11] != want[11] {
+		t.Errorf("row 11 = %v, want %v", got[11], want[11])
+	}
+}
+
+func BenchmarkEvalBatchParallel(b *testing.B) {
+	expr, err := ParseString(batchInput)
+	if err != nil {
+		b.Fatal(err)
+	}
+	cols := batchColumns(400000)
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sequential", nil},
+		{"parallel", []Option{WithParallelBatch()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			ev := NewEvaluator(bench.opts...)
+			for i := 0; i < b.N; i++ {
+				if _, err := ev.EvalBatch(expr, cols); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// end of file
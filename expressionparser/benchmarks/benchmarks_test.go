@@ -0,0 +1,201 @@
+package benchmarks
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	ep "github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser"
+)
+
+// workload is an expression benchmarked with the bindings of its variables.
+type workload struct {
+	name  string
+	input string
+	vars  map[string]float64
+}
+
+// nested returns depth additions and multiplications nested in
+// parentheses, each level using x.
+func nested(depth int) string {
+	var sb strings.Builder
+	for i := 0; i < depth; i++ {
+		sb.WriteString("(x + ")
+	}
+	sb.WriteString("1")
+	for i := 0; i < depth; i++ {
+		sb.WriteString(") * 1.01")
+	}
+	return sb.String()
+}
+
+var workloads = []workload{
+	{"Constant", "2 + 3 * 4 - 10 / 5", nil},
+	{"Financial", "principal * rate / 12 / (1 - (1 + rate / 12) ^ -(years * 12)) + fees - rebate * (1 - tax)", map[string]float64{
+		"principal": 250000, "rate": 0.045, "years": 30, "fees": 25, "rebate": 120, "tax": 0.2,
+	}},
+	{"Nested", nested(32), map[string]float64{"x": 0.5}},
+	{"Scientific", "sqrt(x^2 + y^2) * sin(theta) + exp(-x / tau) * cos(2 * pi * f * t) - log(1 + abs(y)) / atan2(y, x)", map[string]float64{
+		"x": 3, "y": 4, "theta": 0.3, "tau": 2.5, "pi": math.Pi, "f": 50, "t": 0.01,
+	}},
+}
+
+// crossCheck fails tb unless Eval, CompileFunc, the Program VM and
+// EvaluateDirect give the same result for w.
+func crossCheck(tb testing.TB, w workload) {
+	tb.Helper()
+	expr, err := ep.ParseString(w.input)
+	if err != nil {
+		tb.Fatalf("%s: %v", w.name, err)
+	}
+	want, err := ep.EvalWithVars(expr, w.vars)
+	if err != nil {
+		tb.Fatalf("%s: Eval: %v", w.name, err)
+	}
+
+	fn, err := ep.CompileFunc(expr)
+	if err != nil {
+		tb.Fatalf("%s: CompileFunc: %v", w.name, err)
+	}
+	prog, err := ep.CompileProgram(expr)
+	if err != nil {
+		tb.Fatalf("%s: CompileProgram: %v", w.name, err)
+	}
+	slots, err := prog.Slots(w.vars)
+	if err != nil {
+		tb.Fatalf("%s: Slots: %v", w.name, err)
+	}
+	results := map[string]func() (float64, error){
+		"CompileFunc":    func() (float64, error) { return fn(w.vars) },
+		"Program":        func() (float64, error) { return prog.Run(slots) },
+		"EvaluateDirect": func() (float64, error) { return ep.EvaluateDirect(w.input, w.vars) },
+	}
+	for mode, eval := range results {
+		got, err := eval()
+		if err != nil {
+			tb.Fatalf("%s: %s: %v", w.name, mode, err)
+		}
+		if got != want {
+			tb.Fatalf("%s: %s gives %v, Eval %v", w.name, mode, got, want)
+		}
+	}
+}
+
+func TestWorkloadsAgree(t *testing.T) {
+	for _, w := range workloads {
+		crossCheck(t, w)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, w := range workloads {
+		b.Run(w.name, func(b *testing.B) {
+			crossCheck(b, w)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ep.ParseString(w.input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCompileFunc(b *testing.B) {
+	for _, w := range workloads {
+		b.Run(w.name, func(b *testing.B) {
+			crossCheck(b, w)
+			expr, _ := ep.ParseString(w.input)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := ep.CompileFunc(expr); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCompileProgram(b *testing.B) {
+	for _, w := range workloads {
+		b.Run(w.name, func(b *testing.B) {
+			crossCheck(b, w)
+			expr, _ := ep.ParseString(w.input)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := ep.CompileProgram(expr); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEval(b *testing.B) {
+	for _, w := range workloads {
+		b.Run(w.name, func(b *testing.B) {
+			crossCheck(b, w)
+			expr, _ := ep.ParseString(w.input)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := ep.EvalWithVars(expr, w.vars); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRunFunc(b *testing.B) {
+	for _, w := range workloads {
+		b.Run(w.name, func(b *testing.B) {
+			crossCheck(b, w)
+			expr, _ := ep.ParseString(w.input)
+			fn, _ := ep.CompileFunc(expr)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := fn(w.vars); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRunProgram(b *testing.B) {
+	for _, w := range workloads {
+		b.Run(w.name, func(b *testing.B) {
+			crossCheck(b, w)
+			expr, _ := ep.ParseString(w.input)
+			prog, _ := ep.CompileProgram(expr)
+			slots, _ := prog.Slots(w.vars)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := prog.Run(slots); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEvaluateDirect(b *testing.B) {
+	for _, w := range workloads {
+		b.Run(w.name, func(b *testing.B) {
+			crossCheck(b, w)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ep.EvaluateDirect(w.input, w.vars); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// end of file
@@ -0,0 +1,9 @@
+// Package benchmarks measures parsing, compiling and evaluating
+// representative expressions by each of the evaluation paths: the tree
+// walk of Eval, the closures of CompileFunc, the Program VM and
+// EvaluateDirect. Run it with go test -bench; every benchmark first checks
+// that the paths agree on its workload, so a run doubles as a differential
+// test.
+package benchmarks
+
+// end of file
@@ -0,0 +1,346 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Precision in bits of EvalBig when WithBigPrecision is not given
+const defaultBigPrecision = 256
+
+// WithBigPrecision sets the mantissa precision, in bits, used by EvalBig.
+func WithBigPrecision(bits uint) Option {
+	return func(c *config) {
+		c.bigPrecision = bits
+	}
+}
+
+// EvalBig evaluates an expression in arbitrary precision with the default Evaluator.
+func EvalBig(expr Expr) (*big.Float, error) {
+	return defaultEvaluator.EvalBig(expr)
+}
+
+// FormatBig renders a result of EvalBig with the fewest decimal digits that
+// identify it uniquely at its precision.
+func FormatBig(f *big.Float) string {
+	return f.Text('g', -1)
+}
+
+// EvalBig evaluates expr with big.Float arithmetic at the configured
+// precision, rounding to nearest even. Parsed literals are read from their
+// source text at full precision, so 0.1 is not first rounded to a float64;
+// literals of built trees and variable values are converted from float64.
+//
+// Division by zero follows the WithDivisionByZero policy, as in Eval, except
+// that 0/0 is an error under DivideByZeroIEEE. The exponent range of big.Float
+// is so wide that finite results do not overflow in practice; infinite
+// literals and variables propagate like float64 infinities, and an operation
+// that would produce NaN, such as Inf - Inf, is an error. ^ requires an
+// integer exponent. Comparisons and logical operators yield 1 or 0. Of the
+// builtins only abs, sign, min, max, clamp, sqrt, floor, ceil, round and trunc are
+// available.
+func (ev *Evaluator) EvalBig(expr Expr) (result *big.Float, err error) {
+	if err := ev.admit(expr); err != nil {
+		return nil, err
+	}
+
+	// big.Float panics with ErrNaN where IEEE arithmetic would produce NaN
+	defer func() {
+		if r := recover(); r != nil {
+			nan, ok := r.(big.ErrNaN)
+			if !ok {
+				panic(r)
+			}
+			result, err = nil, nan
+		}
+	}()
+
+	return ev.evalBig(expr, nil)
+}
+
+// bigScope is a chain of let bindings in big mode, innermost first.
+type bigScope struct {
+	name   string
+	value  *big.Float
+	parent *bigScope
+}
+
+// newBig returns a zero big.Float at the configured precision.
+func (ev *Evaluator) newBig() *big.Float {
+	prec := ev.cfg.bigPrecision
+	if prec == 0 {
+		prec = defaultBigPrecision
+	}
+	return new(big.Float).SetPrec(prec)
+}
+
+// bigFromFloat converts a float64, rejecting NaN, which big.Float cannot hold.
+func (ev *Evaluator) bigFromFloat(v float64, what string) (*big.Float, error) {
+	if math.IsNaN(v) {
+		return nil, withCode(CodeUnsupported, fmt.Errorf("%s is NaN", what))
+	}
+	return ev.newBig().SetFloat64(v), nil
+}
+
+// evalBig evaluates expr in big mode with the given let bindings in scope.
+func (ev *Evaluator) evalBig(expr Expr, env *bigScope) (*big.Float, error) {
+	switch v := expr.(type) {
+	case *Number:
+		if v.Imag {
+			return nil, imaginaryError(v, "big")
+		}
+		if v.Text != "" {
+			f, _, err := ev.newBig().Parse(v.Text, 10)
+			if err != nil {
+				return nil, withCode(CodeInvalidLiteral, fmt.Errorf("invalid literal %s: %w", v.Text, err))
+			}
+			return f, nil
+		}
+		return ev.bigFromFloat(v.Value, "literal")
+	case *Variable:
+		for s := env; s != nil; s = s.parent {
+			if s.name == v.Name {
+				return s.value, nil
+			}
+		}
+		value, ok := ev.vars[v.Name]
+		if !ok {
+			return nil, &UndefinedVariableError{Name: v.Name, Span: v.Span}
+		}
+		return ev.bigFromFloat(value, "variable "+v.Name)
+	case *UnaryOp:
+		operand, err := ev.evalBig(v.Operand, env)
+		if err != nil {
+			return nil, err
+		}
+		switch v.Op.Type {
+		case MINUS:
+			return ev.newBig().Neg(operand), nil
+		case NOT:
+			return ev.bigTruth(operand.Sign() == 0), nil
+		}
+	case *BinaryOp:
+		left, err := ev.evalBig(v.Left, env)
+		if err != nil {
+			return nil, err
+		}
+		if (v.Op.Type == AND || v.Op.Type == OR) && (left.Sign() != 0) == (v.Op.Type == OR) {
+			// Short-circuit: the right operand cannot change the result
+			return ev.bigTruth(left.Sign() != 0), nil
+		}
+		right, err := ev.evalBig(v.Right, env)
+		if err != nil {
+			return nil, err
+		}
+		if v.Op.Type == DIV && right.Sign() == 0 {
+			value, ieee, err := ev.zeroDivision(v.Op, v.Right)
+			if err != nil {
+				return nil, err
+			}
+			if !ieee {
+				return ev.bigFromFloat(value, "division by zero value")
+			}
+			if left.Sign() == 0 {
+				return nil, fmt.Errorf("%w gives NaN, which big mode cannot represent", zeroDivisorError(v.Op, v.Right))
+			}
+		}
+		return ev.bigOperation(v.Op, left, right)
+	case *FunctionCall:
+		return ev.callBigBuiltin(v, env)
+	case *Let:
+		value, err := ev.evalBig(v.Value, env)
+		if err != nil {
+			return nil, err
+		}
+		return ev.evalBig(v.Body, &bigScope{name: v.Name, value: value, parent: env})
+	case *Conditional:
+		cond, err := ev.evalBig(v.Cond, env)
+		if err != nil {
+			return nil, err
+		}
+		if cond.Sign() != 0 {
+			return ev.evalBig(v.Then, env)
+		}
+		return ev.evalBig(v.Else, env)
+	}
+	return nil, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate expression of type %T in big mode", expr))
+}
+
+// bigOperation applies a binary operator to two big.Floats.
+func (ev *Evaluator) bigOperation(op Token, a, b *big.Float) (*big.Float, error) {
+	switch op.Type {
+	case PLUS:
+		return ev.newBig().Add(a, b), nil
+	case MINUS:
+		return ev.newBig().Sub(a, b), nil
+	case MULT:
+		return ev.newBig().Mul(a, b), nil
+	case DIV:
+		return ev.newBig().Quo(a, b), nil
+	case POW:
+		return ev.bigPow(a, b)
+	case LT:
+		return ev.bigTruth(a.Cmp(b) < 0), nil
+	case LE:
+		return ev.bigTruth(a.Cmp(b) <= 0), nil
+	case GT:
+		return ev.bigTruth(a.Cmp(b) > 0), nil
+	case GE:
+		return ev.bigTruth(a.Cmp(b) >= 0), nil
+	case EQ:
+		return ev.bigTruth(a.Cmp(b) == 0), nil
+	case NE:
+		return ev.bigTruth(a.Cmp(b) != 0), nil
+	case AND:
+		return ev.bigTruth(a.Sign() != 0 && b.Sign() != 0), nil
+	case OR:
+		return ev.bigTruth(a.Sign() != 0 || b.Sign() != 0), nil
+	}
+	return nil, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate operator %s in big mode", operatorSymbol(op)))
+}
+
+// bigPow raises base to an integer exponent by repeated squaring.
+func (ev *Evaluator) bigPow(base, exponent *big.Float) (*big.Float, error) {
+	if !exponent.IsInt() {
+		return nil, withCode(CodeUnsupported, fmt.Errorf("exponent %s is not an integer in big mode", FormatBig(exponent)))
+	}
+	n, acc := exponent.Int64()
+	if acc != big.Exact {
+		return nil, withCode(CodeOverflow, fmt.Errorf("exponent %s is too large in big mode", FormatBig(exponent)))
+	}
+
+	// A negative power of zero is infinite, as with math.Pow
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+
+	result := ev.newBig().SetInt64(1)
+	square := ev.newBig().Set(base)
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			result.Mul(result, square)
+		}
+		if n > 1 {
+			square.Mul(square, square)
+		}
+	}
+
+	if negative {
+		result.Quo(ev.newBig().SetInt64(1), result)
+	}
+	return result, nil
+}
+
+// bigTruth converts a boolean to 1 or 0.
+func (ev *Evaluator) bigTruth(b bool) *big.Float {
+	if b {
+		return ev.newBig().SetInt64(1)
+	}
+	return ev.newBig()
+}
+
+// bigInt rounds x to an integer value in the given direction: -1 toward
+// negative infinity, 0 toward zero and 1 toward positive infinity.
+func (ev *Evaluator) bigInt(x *big.Float, direction int) *big.Float {
+	if x.IsInf() || x.IsInt() {
+		return ev.newBig().Set(x)
+	}
+	i, _ := x.Int(nil)
+	t := ev.newBig().SetInt(i)
+	if direction != 0 && x.Sign() == direction {
+		t.Add(t, ev.newBig().SetInt64(int64(direction)))
+	}
+	return t
+}
+
+// Builtins available in big mode; the signature fields of builtin give their arity
+var bigBuiltins = map[string]struct {
+	sig builtin
+	fn  func(ev *Evaluator, args []*big.Float) (*big.Float, error)
+}{
+	"abs": {builtin{arity: 1}, func(ev *Evaluator, args []*big.Float) (*big.Float, error) {
+		return ev.newBig().Abs(args[0]), nil
+	}},
+	"sign": {builtin{arity: 1}, func(ev *Evaluator, args []*big.Float) (*big.Float, error) {
+		return ev.newBig().SetInt64(int64(args[0].Sign())), nil
+	}},
+	"min": {builtin{arity: 2, variadic: true}, func(ev *Evaluator, args []*big.Float) (*big.Float, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(result) < 0 {
+				result = arg
+			}
+		}
+		return result, nil
+	}},
+	"max": {builtin{arity: 2, variadic: true}, func(ev *Evaluator, args []*big.Float) (*big.Float, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(result) > 0 {
+				result = arg
+			}
+		}
+		return result, nil
+	}},
+	"clamp": {builtin{arity: 3}, func(ev *Evaluator, args []*big.Float) (*big.Float, error) {
+		x, lo, hi := args[0], args[1], args[2]
+		switch {
+		case lo.Cmp(hi) > 0:
+			return nil, withCode(CodeOutsideDomain, fmt.Errorf("clamp: lower bound %s is greater than upper bound %s", FormatBig(lo), FormatBig(hi)))
+		case x.Cmp(lo) < 0:
+			return lo, nil
+		case x.Cmp(hi) > 0:
+			return hi, nil
+		}
+		return x, nil
+	}},
+	"sqrt": {builtin{arity: 1}, func(ev *Evaluator, args []*big.Float) (*big.Float, error) {
+		if args[0].Sign() < 0 {
+			return nil, fmt.Errorf("sqrt: argument %s is %w", FormatBig(args[0]), ErrOutsideDomain)
+		}
+		return ev.newBig().Sqrt(args[0]), nil
+	}},
+	"floor": {builtin{arity: 1}, func(ev *Evaluator, args []*big.Float) (*big.Float, error) {
+		return ev.bigInt(args[0], -1), nil
+	}},
+	"ceil": {builtin{arity: 1}, func(ev *Evaluator, args []*big.Float) (*big.Float, error) {
+		return ev.bigInt(args[0], 1), nil
+	}},
+	"trunc": {builtin{arity: 1}, func(ev *Evaluator, args []*big.Float) (*big.Float, error) {
+		return ev.bigInt(args[0], 0), nil
+	}},
+	"round": {builtin{arity: 1}, func(ev *Evaluator, args []*big.Float) (*big.Float, error) {
+		// Half away from zero, as math.Round
+		half := ev.newBig().SetFloat64(0.5)
+		if args[0].Sign() < 0 {
+			half.Neg(half)
+		}
+		return ev.bigInt(ev.newBig().Add(args[0], half), 0), nil
+	}},
+}
+
+// callBigBuiltin evaluates the arguments of a call and applies one of the
+// builtins available in big mode.
+func (ev *Evaluator) callBigBuiltin(call *FunctionCall, env *bigScope) (*big.Float, error) {
+	b, ok := bigBuiltins[call.Name]
+	if !ok {
+		return nil, ev.unavailableError(call.Name, "big")
+	}
+	if err := b.sig.checkArgs(call.Name, len(call.Args)); err != nil {
+		return nil, err
+	}
+
+	args := make([]*big.Float, len(call.Args))
+	for i, arg := range call.Args {
+		value, err := ev.evalBig(arg, env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	return b.fn(ev, args)
+}
+
+// end of file
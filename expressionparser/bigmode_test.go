@@ -0,0 +1,90 @@
+package expressionparser
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestEvalBigPrecision(t *testing.T) {
+	expr := mustParse(t, "(1e20 + 1) - 1e20")
+	got, err := EvalBig(expr)
+	if err != nil || got.Cmp(big.NewFloat(1)) != 0 {
+		t.Errorf("EvalBig((1e20 + 1) - 1e20) = %v, %v; want 1", got, err)
+	}
+	if got, err := Eval(expr); err != nil || got != 0 {
+		t.Errorf("Eval((1e20 + 1) - 1e20) = %v, %v; want float64's 0", got, err)
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"0.1 + 0.2", "0.3"},
+		{"(1e20 + 1) - 1e20", "1"},
+		{"2 ^ 100", "1.267650600228229401496703205376e+30"},
+		{"let a = 1e30 in a + 1 - a", "1"},
+		{"max(0.25, 0.5 - 0.125)", "0.375"},
+	}
+	for _, tt := range tests {
+		got, err := EvalBig(mustParse(t, tt.input))
+		if err != nil {
+			t.Errorf("EvalBig(%s): %v", tt.input, err)
+			continue
+		}
+		if s := FormatBig(got); s != tt.want {
+			t.Errorf("EvalBig(%s) = %s, want %s", tt.input, s, tt.want)
+		}
+	}
+}
+
+func TestWithBigPrecision(t *testing.T) {
+	third := mustParse(t, "1 / 3")
+	for _, bits := range []uint{24, 64, 512} {
+		got, err := NewEvaluator(WithBigPrecision(bits)).EvalBig(third)
+		if err != nil {
+			t.Fatalf("1 / 3 at %d bits: %v", bits, err)
+		}
+		if got.Prec() != bits {
+			t.Errorf("1 / 3 at %d bits has precision %d", bits, got.Prec())
+		}
+		want := new(big.Float).SetPrec(bits).Quo(new(big.Float).SetPrec(bits).SetInt64(1), new(big.Float).SetPrec(bits).SetInt64(3))
+		if got.Cmp(want) != 0 {
+			t.Errorf("1 / 3 at %d bits = %s, want %s", bits, FormatBig(got), FormatBig(want))
+		}
+	}
+	// 1e20 + 1 needs 67 bits
+	if got, _ := NewEvaluator(WithBigPrecision(53)).EvalBig(mustParse(t, "(1e20 + 1) - 1e20")); got.Sign() != 0 {
+		t.Errorf("(1e20 + 1) - 1e20 at 53 bits = %s, want 0 as in float64", FormatBig(got))
+	}
+}
+
+func TestEvalBigErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+	}{
+		{"1 / 0", CodeDivisionByZero},
+		{"0 / 0", CodeDivisionByZero},
+		{"2 ^ 0.5", CodeUnsupported},
+		{"sin(1)", CodeUnsupported},
+	}
+	for _, tt := range tests {
+		if _, err := EvalBig(mustParse(t, tt.input)); ErrorCode(err) != tt.code {
+			t.Errorf("EvalBig(%s): error %v, want %s", tt.input, err, tt.code)
+		}
+	}
+	if _, err := EvalBig(mustParse(t, "1 / 0")); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("EvalBig(1 / 0): error %v is not ErrDivisionByZero", err)
+	}
+
+	ieee := NewEvaluator(WithDivisionByZero(DivideByZeroIEEE))
+	if got, err := ieee.EvalBig(mustParse(t, "-1 / 0")); err != nil || !got.IsInf() || got.Sign() > 0 {
+		t.Errorf("EvalBig(-1 / 0) under DivideByZeroIEEE = %v, %v; want -Inf", got, err)
+	}
+	if _, err := ieee.EvalBig(mustParse(t, "0 / 0")); err == nil {
+		t.Error("EvalBig(0 / 0) under DivideByZeroIEEE: no error for NaN")
+	}
+}
+
+// end of file
@@ -0,0 +1,365 @@
+package expressionparser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// binaryFormatVersion is written as the first byte of every encoded tree.
+// Bump it whenever the node tags or their layout change.
+const binaryFormatVersion byte = 1
+
+// Node tags of the binary encoding
+const (
+	tagNumber byte = iota + 1
+	tagVariable
+	tagBinary
+	tagUnary
+	tagCall
+	tagLet
+	tagConditional
+	tagImaginary
+	tagString
+	tagList
+	tagQuantity
+	tagAssign
+	tagCell
+	tagCellRange
+)
+
+// maxBinaryDepth bounds nesting while decoding so corrupt input cannot exhaust the stack.
+const maxBinaryDepth = 10000
+
+// Encode writes a compact binary form of expr to w: a version byte followed by
+// the nodes in prefix order. Numbers are stored as IEEE-754 bits; operators
+// are stored by spelling and names length-prefixed, so the encoding does not
+// depend on TokenType values.
+func Encode(w io.Writer, expr Expr) error {
+	bw := bufio.NewWriter(w)
+	if err := bw.WriteByte(binaryFormatVersion); err != nil {
+		return err
+	}
+	if err := encodeNode(bw, expr); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// encodeNode writes a single node and its children.
+func encodeNode(w *bufio.Writer, expr Expr) error {
+	switch v := expr.(type) {
+	case *Number:
+		if v.Imag {
+			w.WriteByte(tagImaginary)
+		} else {
+			w.WriteByte(tagNumber)
+		}
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v.Value))
+		_, err := w.Write(buf[:])
+		return err
+	case *QuantityLiteral:
+		w.WriteByte(tagQuantity)
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v.Value))
+		if _, err := w.Write(buf[:]); err != nil {
+			return err
+		}
+		return writeString(w, v.Unit)
+	case *Variable:
+		w.WriteByte(tagVariable)
+		return writeString(w, v.Name)
+	case *CellRef:
+		w.WriteByte(tagCell)
+		return writeString(w, v.Ref)
+	case *CellRange:
+		w.WriteByte(tagCellRange)
+		if err := writeString(w, v.From); err != nil {
+			return err
+		}
+		return writeString(w, v.To)
+	case *StringLiteral:
+		w.WriteByte(tagString)
+		return writeString(w, v.Value)
+	case *BinaryOp:
+		w.WriteByte(tagBinary)
+		if err := writeString(w, operatorSymbol(v.Op)); err != nil {
+			return err
+		}
+		if err := encodeNode(w, v.Left); err != nil {
+			return err
+		}
+		return encodeNode(w, v.Right)
+	case *UnaryOp:
+		w.WriteByte(tagUnary)
+		if err := writeString(w, operatorSymbol(v.Op)); err != nil {
+			return err
+		}
+		return encodeNode(w, v.Operand)
+	case *FunctionCall:
+		w.WriteByte(tagCall)
+		if err := writeString(w, v.Name); err != nil {
+			return err
+		}
+		writeUvarint(w, uint64(len(v.Args)))
+		for _, arg := range v.Args {
+			if err := encodeNode(w, arg); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *ListLiteral:
+		w.WriteByte(tagList)
+		writeUvarint(w, uint64(len(v.Elems)))
+		for _, elem := range v.Elems {
+			if err := encodeNode(w, elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *Let:
+		w.WriteByte(tagLet)
+		if err := writeString(w, v.Name); err != nil {
+			return err
+		}
+		if err := encodeNode(w, v.Value); err != nil {
+			return err
+		}
+		return encodeNode(w, v.Body)
+	case *Assign:
+		w.WriteByte(tagAssign)
+		if err := writeString(w, v.Name); err != nil {
+			return err
+		}
+		return encodeNode(w, v.Value)
+	case *Conditional:
+		w.WriteByte(tagConditional)
+		for _, child := range []Expr{v.Cond, v.Then, v.Else} {
+			if err := encodeNode(w, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return withCode(CodeUnsupported, fmt.Errorf("cannot encode expression of type %T", expr))
+	}
+}
+
+// writeUvarint writes n as an unsigned varint.
+func writeUvarint(w *bufio.Writer, n uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutUvarint(buf[:], n)])
+}
+
+// writeString writes a length-prefixed string.
+func writeString(w *bufio.Writer, s string) error {
+	writeUvarint(w, uint64(len(s)))
+	_, err := w.WriteString(s)
+	return err
+}
+
+// Decode reads a tree written by Encode. Truncated or corrupt input and
+// unknown format versions produce an error.
+func Decode(r io.Reader) (Expr, error) {
+	br := bufio.NewReader(r)
+	version, err := br.ReadByte()
+	if err != nil {
+		return nil, decodeError(err)
+	}
+	if version != binaryFormatVersion {
+		return nil, withCode(CodeDecode, fmt.Errorf("unsupported expression encoding version %d", version))
+	}
+	return decodeNode(br, 0)
+}
+
+// decodeError reports truncation in a uniform way.
+func decodeError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return withCode(CodeDecode, fmt.Errorf("truncated expression encoding"))
+	}
+	return err
+}
+
+// decodeNode reads a single node and its children.
+func decodeNode(r *bufio.Reader, depth int) (Expr, error) {
+	if depth > maxBinaryDepth {
+		return nil, withCode(CodeDecode, fmt.Errorf("expression encoding nested deeper than %d", maxBinaryDepth))
+	}
+
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, decodeError(err)
+	}
+
+	switch tag {
+	case tagNumber, tagImaginary:
+		var buf [8]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, decodeError(err)
+		}
+		return &Number{Value: math.Float64frombits(binary.LittleEndian.Uint64(buf[:])), Imag: tag == tagImaginary}, nil
+	case tagQuantity:
+		var buf [8]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return nil, decodeError(err)
+		}
+		unit, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		return &QuantityLiteral{Value: math.Float64frombits(binary.LittleEndian.Uint64(buf[:])), Unit: unit}, nil
+	case tagVariable:
+		name, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		return &Variable{Name: name}, nil
+	case tagCell:
+		ref, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		return &CellRef{Ref: ref}, nil
+	case tagCellRange:
+		from, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		to, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		return &CellRange{From: from, To: to}, nil
+	case tagString:
+		value, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		return &StringLiteral{Value: value}, nil
+	case tagBinary:
+		symbol, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		op, ok := binaryOperatorToken(symbol)
+		if !ok {
+			return nil, withCode(CodeDecode, fmt.Errorf("invalid binary operator %q in expression encoding", symbol))
+		}
+		left, err := decodeNode(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		right, err := decodeNode(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryOp{Left: left, Op: op, Right: right}, nil
+	case tagUnary:
+		symbol, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		op, ok := unaryOperatorToken(symbol)
+		if !ok {
+			return nil, withCode(CodeDecode, fmt.Errorf("invalid unary operator %q in expression encoding", symbol))
+		}
+		operand, err := decodeNode(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryOp{Op: op, Operand: operand}, nil
+	case tagCall:
+		name, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, decodeError(err)
+		}
+		call := &FunctionCall{Name: name}
+		for i := uint64(0); i < n; i++ {
+			arg, err := decodeNode(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+		}
+		return call, nil
+	case tagList:
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, decodeError(err)
+		}
+		list := &ListLiteral{}
+		for i := uint64(0); i < n; i++ {
+			elem, err := decodeNode(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			list.Elems = append(list.Elems, elem)
+		}
+		return list, nil
+	case tagLet:
+		name, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeNode(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		body, err := decodeNode(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return &Let{Name: name, Value: value, Body: body}, nil
+	case tagAssign:
+		name, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeNode(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return &Assign{Name: name, Value: value}, nil
+	case tagConditional:
+		var children [3]Expr
+		for i := range children {
+			child, err := decodeNode(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			children[i] = child
+		}
+		return &Conditional{Cond: children[0], Then: children[1], Else: children[2]}, nil
+	default:
+		return nil, withCode(CodeDecode, fmt.Errorf("unknown node tag %d in expression encoding", tag))
+	}
+}
+
+// readString reads a length-prefixed string without trusting the length for allocation.
+func readString(r *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", decodeError(err)
+	}
+	if n > math.MaxInt32 {
+		return "", withCode(CodeDecode, fmt.Errorf("string length %d too large in expression encoding", n))
+	}
+	buf, err := io.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil {
+		return "", err
+	}
+	if uint64(len(buf)) != n {
+		return "", withCode(CodeDecode, fmt.Errorf("truncated expression encoding"))
+	}
+	return string(buf), nil
+}
+
+// end of file
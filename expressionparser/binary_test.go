@@ -0,0 +1,147 @@
+package expressionparser
+
+import (
+	"bytes"
+	"testing"
+)
+
+// encodeCorpus returns the binary encodings of the expressions of the
+// evaluation corpus.
+func encodeCorpus(tb testing.TB) [][]byte {
+	var encoded [][]byte
+	for _, input := range evalCorpus {
+		expr, err := ParseString(input)
+		if err != nil {
+			tb.Fatalf("ParseString(%q): %v", input, err)
+		}
+		var buf bytes.Buffer
+		if err := Encode(&buf, expr); err != nil {
+			tb.Fatalf("Encode(%q): %v", input, err)
+		}
+		encoded = append(encoded, buf.Bytes())
+	}
+	return encoded
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	inputs := append([]string{"let a = 2 in a * a", `"s" + "t"`, "10 km", "4i", "[1, [2]]"}, evalCorpus...)
+	for _, input := range inputs {
+		expr, err := ParseString(input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		var buf bytes.Buffer
+		if err := Encode(&buf, expr); err != nil {
+			t.Fatalf("Encode(%q): %v", input, err)
+		}
+		decoded, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("Decode(%q): %v", input, err)
+		}
+		if !Equal(decoded, expr) {
+			t.Errorf("%q decodes as %s", input, ToSExpr(decoded))
+		}
+	}
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	for _, data := range encodeCorpus(t) {
+		// Every truncation of a tree fails rather than decoding part of it
+		for n := 0; n < len(data); n++ {
+			if expr, err := Decode(bytes.NewReader(data[:n])); err == nil {
+				t.Errorf("Decode(% x) = %s, want an error", data[:n], ToSExpr(expr))
+			}
+		}
+	}
+	if _, err := Decode(bytes.NewReader([]byte{binaryFormatVersion + 1, tagNumber})); err == nil {
+		t.Error("Decode of another format version succeeded")
+	}
+	if _, err := Decode(bytes.NewReader([]byte{binaryFormatVersion, 0xff})); err == nil {
+		t.Error("Decode of an unknown node tag succeeded")
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, data := range encodeCorpus(f) {
+		f.Add(data)
+	}
+	withoutRecovery(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		expr, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		// What decodes must encode again
+		var buf bytes.Buffer
+		if err := Encode(&buf, expr); err != nil {
+			t.Fatalf("Decode(% x) = %s, which does not encode: %v", data, ToSExpr(expr), err)
+		}
+	})
+}
+
+// codecInput is the expression the encoding benchmarks encode and decode.
+const codecInput = "let r = sqrt(x ^ 2 + y ^ 2) in r > 1 ? max(x, y, r) / (1 + abs(x * y)) : -r"
+
+func BenchmarkEncodeBinary(b *testing.B) {
+	expr, err := ParseString(codecInput)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var buf bytes.Buffer
+		if err := Encode(&buf, expr); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeJSON(b *testing.B) {
+	expr, err := ParseString(codecInput)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := EncodeJSON(expr); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeBinary(b *testing.B) {
+	expr, err := ParseString(codecInput)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, expr); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeJSON(b *testing.B) {
+	expr, err := ParseString(codecInput)
+	if err != nil {
+		b.Fatal(err)
+	}
+	data, err := EncodeJSON(expr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodeJSON(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// end of file
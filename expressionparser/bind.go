@@ -0,0 +1,140 @@
+package expressionparser
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// WithStrictFields makes EnvFromStruct and EvalStruct reject a struct with
+// an exported field they cannot bind, rather than leave it out.
+func WithStrictFields() Option {
+	return func(c *config) {
+		c.strictFields = true
+	}
+}
+
+// EnvFromStruct returns an environment binding the exported fields of the
+// struct v, or of the struct v points to, as variables. A field is bound
+// under its name, or under the name of its expr tag, as in
+// `expr:"unit_price"`, and one tagged `expr:"-"` is left out. Fields of
+// numeric kinds are bound to numbers, bools to bools and strings to
+// strings, through pointers too; a nil pointer binds nothing, so that
+// using the field is an undefined variable. The fields of a struct field
+// are bound one level down, under the name of the field, a dot and their
+// own names, as in customer.discount, and those of an embedded struct as
+// if they were the fields of v. Other fields, such as slices and structs
+// nested deeper, are left out, or rejected WithStrictFields.
+func EnvFromStruct(v interface{}, opts ...Option) (*Environment, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, withCode(CodeInvalidArgument, fmt.Errorf("cannot bind the fields of %s, which is not a struct", typeName(v)))
+	}
+	env := NewEnvironment(nil)
+	if err := bindFields(env, "", rv, cfg.strictFields); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// EvalStruct evaluates expr with the fields of the struct v as its
+// variables, bound as EnvFromStruct binds them, with an Evaluator
+// configured by opts. A bool result is returned as 1 or 0, and a string
+// result is an error.
+func EvalStruct(expr Expr, v interface{}, opts ...Option) (float64, error) {
+	env, err := EnvFromStruct(v, opts...)
+	if err != nil {
+		return 0, err
+	}
+	value, err := NewEvaluator(opts...).EvalIn(expr, env)
+	if err != nil {
+		return 0, err
+	}
+	if !value.numeric() {
+		return 0, withCode(CodeType, fmt.Errorf("result is a %s, not a number", value.kind))
+	}
+	return value.num, nil
+}
+
+// bindFields binds the exported fields of the struct v in env under prefix,
+// which is empty at the top level and the name of the field and a dot one
+// level down.
+func bindFields(env *Environment, prefix string, v reflect.Value, strict bool) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("expr")
+		if tag == "-" {
+			continue
+		}
+		name := f.Name
+		if tag != "" {
+			name = tag
+		}
+		field := v.Field(i)
+		for field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				break
+			}
+			field = field.Elem()
+		}
+		if field.Kind() == reflect.Ptr {
+			continue // nil
+		}
+		if value, ok := fieldValue(field); ok {
+			if f.IsExported() {
+				env.Set(prefix+name, value)
+			}
+			continue
+		}
+		switch {
+		case field.Kind() == reflect.Struct && f.Anonymous && tag == "":
+			// Embedded: its fields are promoted
+			if err := bindFields(env, prefix, field, strict); err != nil {
+				return err
+			}
+			continue
+		case field.Kind() == reflect.Struct && f.IsExported() && prefix == "":
+			if err := bindFields(env, name+".", field, strict); err != nil {
+				return err
+			}
+			continue
+		}
+		if strict && f.IsExported() {
+			return withCode(CodeInvalidArgument, fmt.Errorf("cannot bind field %s%s of type %s", prefix, name, f.Type))
+		}
+	}
+	return nil
+}
+
+// fieldValue returns the value a field of a numeric kind, a bool or a
+// string binds to.
+func fieldValue(v reflect.Value) (Value, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return StringValue(v.String()), true
+	case reflect.Bool:
+		return BoolValue(v.Bool()), true
+	}
+	x, ok := numberOf(v)
+	return NumberValue(x), ok
+}
+
+// typeName names the type of v in errors, nil included.
+func typeName(v interface{}) string {
+	if v == nil {
+		return "nil"
+	}
+	return reflect.TypeOf(v).String()
+}
+
+// end of file
@@ -0,0 +1,108 @@
+package expressionparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// address is a struct nested in customer.
+type address struct {
+	Zone int
+}
+
+// customer is a struct nested in order.
+type customer struct {
+	Discount float64 `expr:"discount"`
+	Address  address // two levels down
+}
+
+// audit is embedded in order.
+type audit struct {
+	Version uint8
+}
+
+type order struct {
+	audit
+	UnitPrice float64 `expr:"unit_price"`
+	Qty       int     `expr:"qty"`
+	Express   bool
+	Code      string
+	Secret    float64 `expr:"-"`
+	Weight    *float64
+	Rebate    *float64
+	Customer  customer
+	Tags      []string
+	internal  int
+}
+
+func TestEnvFromStruct(t *testing.T) {
+	weight := 2.5
+	o := order{audit: audit{Version: 3}, UnitPrice: 10, Qty: 3, Express: true, Code: "EU", Secret: 9, Weight: &weight, internal: 1}
+	o.Customer.Discount = 0.1
+	env, err := EnvFromStruct(&o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(env.Names(), " "); got != "Code Customer.discount Express Version Weight qty unit_price" {
+		t.Errorf("Names() = %s", got)
+	}
+	if v, ok := env.Get("Weight"); !ok || v.String() != "2.5" {
+		t.Errorf("Weight through a pointer = %v, %v", v, ok)
+	}
+
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"unit_price * qty * (1 - Customer.discount)", 27},
+		{"Express ? 5 : 0", 5},
+		{"Code == \"EU\"", 1},
+		{"Version + Weight", 5.5},
+	}
+	for _, tt := range tests {
+		// By value and through a pointer alike
+		for _, v := range []interface{}{o, &o} {
+			got, err := EvalStruct(mustParse(t, tt.input), v)
+			if err != nil {
+				t.Errorf("EvalStruct(%s): %v", tt.input, err)
+			} else if got != tt.want {
+				t.Errorf("EvalStruct(%s) = %v, want %v", tt.input, got, tt.want)
+			}
+		}
+	}
+
+	// Left out: the tagged, the nil pointer, the unexported, the slice and
+	// what is nested two levels down
+	for _, name := range []string{"Secret", "Rebate", "internal", "Tags", "Customer.Address.Zone", "UnitPrice"} {
+		if _, err := EvalStruct(mustParse(t, name), o); !errors.Is(err, ErrUndefinedVariable) {
+			t.Errorf("EvalStruct(%s): error %v, want ErrUndefinedVariable", name, err)
+		}
+	}
+}
+
+func TestEnvFromStructErrors(t *testing.T) {
+	var nilOrder *order
+	for _, v := range []interface{}{nil, 42, map[string]float64{"x": 1}, nilOrder} {
+		if _, err := EnvFromStruct(v); ErrorCode(err) != CodeInvalidArgument || !strings.Contains(err.Error(), "which is not a struct") {
+			t.Errorf("EnvFromStruct(%#v): error %v", v, err)
+		}
+	}
+	if _, err := EvalStruct(mustParse(t, "Code"), order{Code: "x"}); ErrorCode(err) != CodeType {
+		t.Errorf("EvalStruct of a string: error %v, want %s", err, CodeType)
+	}
+
+	_, err := EnvFromStruct(order{}, WithStrictFields())
+	if ErrorCode(err) != CodeInvalidArgument || err.Error() != "cannot bind field Customer.Address of type expressionparser.address" {
+		t.Errorf("EnvFromStruct WithStrictFields: error %v", err)
+	}
+	type flat struct {
+		A float64
+		B *int
+	}
+	if _, err := EnvFromStruct(flat{}, WithStrictFields()); err != nil {
+		t.Errorf("EnvFromStruct(flat) WithStrictFields: %v", err)
+	}
+}
+
+// end of file
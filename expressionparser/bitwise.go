@@ -0,0 +1,66 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+)
+
+// bitwiseEpsilon is how far an operand of a bitwise operator may be from an
+// integer, to allow for rounding in the arithmetic that produced it.
+const bitwiseEpsilon = 1e-9
+
+// bitwise applies &, |, ~ (exclusive or), << or >> to two numbers, as
+// int64 values. An operand must lie within bitwiseEpsilon of an integer
+// less than 2^53 in magnitude, since a float64 of 2^53 or more may already
+// have lost its low bits, and the result must be an integer a float64
+// holds exactly, at most 2^53 in magnitude, so that no bits are silently
+// lost.
+// The count of a shift must lie within 0..63.
+func bitwise(op Token, pos int, x, y float64) (Value, error) {
+	symbol := operatorSymbol(op)
+	a, err := bitwiseOperand(symbol, pos, x)
+	if err != nil {
+		return Value{}, err
+	}
+	b, err := bitwiseOperand(symbol, pos, y)
+	if err != nil {
+		return Value{}, err
+	}
+
+	var r int64
+	switch op.Type {
+	case BITAND:
+		r = a & b
+	case BITOR:
+		r = a | b
+	case BITXOR:
+		r = a ^ b
+	case SHL, SHR:
+		if b < 0 || b > 63 {
+			return Value{}, atOffset(withCode(CodeOutsideDomain, fmt.Errorf("shift count %d of %s is outside 0..63", b, symbol)), pos)
+		}
+		if op.Type == SHR {
+			r = a >> uint(b)
+		} else if r = a << uint(b); r>>uint(b) != a {
+			return Value{}, &OverflowError{Op: symbol, Operands: []int64{a, b}, Pos: pos}
+		}
+	}
+	if r > maxExactFloatInt || r < -maxExactFloatInt {
+		return Value{}, atOffset(fmt.Errorf("%w: %d %s %d is %d, beyond the integers a number holds exactly", ErrOverflow, a, symbol, b, r), pos)
+	}
+	return NumberValue(float64(r)), nil
+}
+
+// bitwiseOperand converts an operand of a bitwise operator to an integer.
+func bitwiseOperand(symbol string, pos int, x float64) (int64, error) {
+	n := math.Round(x)
+	if math.IsNaN(x) || math.Abs(x-n) > bitwiseEpsilon {
+		return 0, atOffset(withCode(CodeOutsideDomain, fmt.Errorf("operand %s of %s is not an integer", formatNumber(x), symbol)), pos)
+	}
+	if math.Abs(n) >= maxExactFloatInt {
+		return 0, atOffset(withCode(CodeOverflow, fmt.Errorf("operand %s of %s is beyond the integers a number holds exactly", formatNumber(x), symbol)), pos)
+	}
+	return int64(n), nil
+}
+
+// end of file
@@ -0,0 +1,50 @@
+package expressionparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBitwiseRules(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+		code  string // the code of the error, when there is one
+		msg   string // part of its message
+	}{
+		{input: "6 & 3", want: 2},
+		{input: "6 | 3", want: 7},
+		{input: "6 ~ 3", want: 5},
+		{input: "1 << 10", want: 1024},
+		{input: "-8 >> 1", want: -4},
+		{input: "0.1 * 30 & 7", want: 3}, // 3.0000000000000004 is within the epsilon
+		{input: "2.5 & 1", code: CodeOutsideDomain, msg: "operand 2.5 of & is not an integer"},
+		{input: "1 << 1.5", code: CodeOutsideDomain, msg: "operand 1.5 of << is not an integer"},
+		{input: "2^63 | 1", code: CodeOverflow, msg: "of | is beyond the integers a number holds exactly"},
+		{input: "(2^53 + 1) | 1", code: CodeOverflow, msg: "beyond the integers a number holds exactly"},
+		{input: "(2^53 - 1) & 1", want: 1},
+		{input: "2^53 & 1", code: CodeOverflow, msg: "operand 9.007199254740992e+15 of & is beyond the integers a number holds exactly"},
+		{input: "-(2^53) ~ 0", code: CodeOverflow, msg: "operand -9.007199254740992e+15 of ~ is beyond the integers a number holds exactly"},
+		{input: "1 << 64", code: CodeOutsideDomain, msg: "shift count 64 of << is outside 0..63"},
+		{input: "1 >> -1", code: CodeOutsideDomain, msg: "shift count -1 of >> is outside 0..63"},
+		{input: "3 << 62", code: CodeOverflow},
+	}
+	for _, tt := range tests {
+		got, err := Evaluate(tt.input)
+		if tt.code == "" {
+			if err != nil || got != tt.want {
+				t.Errorf("%q = %v, %v; want %v", tt.input, got, err, tt.want)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%q = %v, want an error %s", tt.input, got, tt.code)
+			continue
+		}
+		if ErrorCode(err) != tt.code || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%q: error %q (%s), want %s containing %q", tt.input, err, ErrorCode(err), tt.code, tt.msg)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,138 @@
+package expressionparser
+
+// Num builds a numeric literal.
+func Num(value float64) *Number {
+	return &Number{Value: value}
+}
+
+// Imaginary builds an imaginary literal, value times i.
+func Imaginary(value float64) *Number {
+	return &Number{Value: value, Imag: true}
+}
+
+// Qty builds a number with a unit of measure, as in Qty(10, "km").
+func Qty(value float64, unit string) *QuantityLiteral {
+	return &QuantityLiteral{Value: value, Unit: unit}
+}
+
+// Str builds a string literal.
+func Str(value string) *StringLiteral {
+	return &StringLiteral{Value: value}
+}
+
+// Var builds a variable reference.
+func Var(name string) *Variable {
+	return &Variable{Name: name}
+}
+
+// Add builds left + right.
+func Add(left, right Expr) *BinaryOp {
+	return operation(PLUS, left, right)
+}
+
+// Sub builds left - right.
+func Sub(left, right Expr) *BinaryOp {
+	return operation(MINUS, left, right)
+}
+
+// Mul builds left * right.
+func Mul(left, right Expr) *BinaryOp {
+	return operation(MULT, left, right)
+}
+
+// Div builds left / right.
+func Div(left, right Expr) *BinaryOp {
+	return operation(DIV, left, right)
+}
+
+// Mod builds left % right.
+func Mod(left, right Expr) *BinaryOp {
+	return operation(MOD, left, right)
+}
+
+// Pow builds base ^ exponent.
+func Pow(base, exponent Expr) *BinaryOp {
+	return operation(POW, base, exponent)
+}
+
+// Less builds left < right.
+func Less(left, right Expr) *BinaryOp {
+	return operation(LT, left, right)
+}
+
+// LessEq builds left <= right.
+func LessEq(left, right Expr) *BinaryOp {
+	return operation(LE, left, right)
+}
+
+// Greater builds left > right.
+func Greater(left, right Expr) *BinaryOp {
+	return operation(GT, left, right)
+}
+
+// GreaterEq builds left >= right.
+func GreaterEq(left, right Expr) *BinaryOp {
+	return operation(GE, left, right)
+}
+
+// Equals builds left == right.
+func Equals(left, right Expr) *BinaryOp {
+	return operation(EQ, left, right)
+}
+
+// NotEquals builds left != right.
+func NotEquals(left, right Expr) *BinaryOp {
+	return operation(NE, left, right)
+}
+
+// And builds left && right.
+func And(left, right Expr) *BinaryOp {
+	return operation(AND, left, right)
+}
+
+// Or builds left || right.
+func Or(left, right Expr) *BinaryOp {
+	return operation(OR, left, right)
+}
+
+// Range builds the range from..to.
+func Range(from, to Expr) *BinaryOp {
+	return operation(DOTDOT, from, to)
+}
+
+// Neg builds the prefix negation -operand.
+func Neg(operand Expr) *UnaryOp {
+	return &UnaryOp{Op: operatorToken(MINUS), Operand: operand}
+}
+
+// Not builds the logical negation !operand.
+func Not(operand Expr) *UnaryOp {
+	return &UnaryOp{Op: operatorToken(NOT), Operand: operand}
+}
+
+// If builds the conditional cond ? then : els.
+func If(cond, then, els Expr) *Conditional {
+	return &Conditional{Cond: cond, Then: then, Else: els}
+}
+
+// List builds a list literal of the given elements.
+func List(elems ...Expr) *ListLiteral {
+	return &ListLiteral{Elems: elems}
+}
+
+// Call builds a call of the named function.
+func Call(name string, args ...Expr) *FunctionCall {
+	return &FunctionCall{Name: name, Args: args}
+}
+
+// operation builds a binary node with a correctly populated operator token.
+func operation(op TokenType, left, right Expr) *BinaryOp {
+	return &BinaryOp{Left: left, Op: operatorToken(op), Right: right}
+}
+
+// operatorToken returns the token for an operator type, as the lexer would produce it.
+func operatorToken(op TokenType) Token {
+	return Token{Type: op, Value: operatorSymbols[op]}
+}
+
+// end of file
@@ -0,0 +1,63 @@
+package expressionparser
+
+import "testing"
+
+func TestBuildersMatchParser(t *testing.T) {
+	x, y := Var("x"), Var("y")
+	tests := []struct {
+		built Expr
+		input string
+	}{
+		{Num(2.5), "2.5"},
+		{Imaginary(4), "4i"},
+		{Qty(10, "km"), "10 km"},
+		{Str("hi"), `"hi"`},
+		{Add(x, y), "x + y"},
+		{Sub(x, y), "x - y"},
+		{Mul(x, y), "x * y"},
+		{Div(x, y), "x / y"},
+		{Mod(x, y), "x % y"},
+		{Pow(x, y), "x ^ y"},
+		{Less(x, y), "x < y"},
+		{LessEq(x, y), "x <= y"},
+		{Greater(x, y), "x > y"},
+		{GreaterEq(x, y), "x >= y"},
+		{Equals(x, y), "x == y"},
+		{NotEquals(x, y), "x != y"},
+		{And(x, y), "x && y"},
+		{Or(x, y), "x || y"},
+		{Range(Num(1), Num(5)), "1..5"},
+		{Neg(x), "-x"},
+		{Not(x), "!x"},
+		{If(x, Num(1), Num(2)), "x ? 1 : 2"},
+		{List(Num(1), x), "[1, x]"},
+		{Call("max", x, y), "max(x, y)"},
+		{Call("rand"), "rand()"},
+		{Mul(Add(Num(2), Num(3)), Num(5)), "(2 + 3) * 5"},
+	}
+	for _, tt := range tests {
+		parsed, err := ParseString(tt.input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", tt.input, err)
+		}
+		if !Equal(tt.built, parsed) {
+			t.Errorf("built %s, parsed %q as %s", ToSExpr(tt.built), tt.input, ToSExpr(parsed))
+		}
+		// The operator tokens are those the lexer makes, text included
+		switch b := tt.built.(type) {
+		case *BinaryOp:
+			if p := parsed.(*BinaryOp); b.Op.Type != p.Op.Type || b.Op.Value != p.Op.Value {
+				t.Errorf("built token %+v for %q, parsed %+v", b.Op, tt.input, p.Op)
+			}
+		case *UnaryOp:
+			if p := parsed.(*UnaryOp); b.Op.Type != p.Op.Type || b.Op.Value != p.Op.Value {
+				t.Errorf("built token %+v for %q, parsed %+v", b.Op, tt.input, p.Op)
+			}
+		}
+		if got := Format(tt.built); got != Format(parsed) {
+			t.Errorf("built tree formats as %q, parsed %q as %q", got, tt.input, Format(parsed))
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,111 @@
+package expressionparser
+
+import (
+	linked "container/list" // list is the type of list values
+	"sync"
+)
+
+// DefaultCache is the cache of CompileCached, which holds up to 1000
+// expressions until its capacity is set otherwise.
+var DefaultCache = NewExpressionCache(1000)
+
+// CompileCached compiles input as Compile does with no options, through
+// DefaultCache, so that compiling the same input again returns the same
+// *Expression, or the same error, without parsing it again.
+func CompileCached(input string) (*Expression, error) {
+	return DefaultCache.Compile(input)
+}
+
+// ExpressionCache holds the results of Compile by input, evicting the
+// least recently used once it holds as many as its capacity. It is safe
+// for concurrent use.
+type ExpressionCache struct {
+	mu       sync.Mutex
+	opts     []Option
+	capacity int
+	entries  map[string]*linked.Element
+	order    *linked.List // of *cacheEntry, most recently used first
+	hits     uint64
+	misses   uint64
+}
+
+// cacheEntry is the result of compiling an input.
+type cacheEntry struct {
+	input string
+	expr  *Expression
+	err   error
+}
+
+// CacheStats counts the lookups of an ExpressionCache.
+type CacheStats struct {
+	Hits     uint64 // lookups answered from the cache
+	Misses   uint64 // lookups that compiled their input
+	Len      int    // inputs held
+	Capacity int
+}
+
+// NewExpressionCache returns an empty cache of the given capacity, which
+// compiles with opts. A capacity of 0 or less disables caching.
+func NewExpressionCache(capacity int, opts ...Option) *ExpressionCache {
+	return &ExpressionCache{opts: opts, capacity: capacity, entries: map[string]*linked.Element{}, order: linked.New()}
+}
+
+// Compile returns the result of Compile for input, compiling it only when
+// the cache does not hold it. Errors are cached as results are, so that
+// invalid input is not parsed again either. Two goroutines missing the same
+// input at once may both compile it; one result is kept.
+func (c *ExpressionCache) Compile(input string) (*Expression, error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[input]; ok {
+		c.hits++
+		c.order.MoveToFront(elem)
+		entry := elem.Value.(*cacheEntry)
+		c.mu.Unlock()
+		return entry.expr, entry.err
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	expr, err := Compile(input, c.opts...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capacity <= 0 {
+		return expr, err
+	}
+	if elem, ok := c.entries[input]; ok {
+		entry := elem.Value.(*cacheEntry)
+		return entry.expr, entry.err
+	}
+	c.entries[input] = c.order.PushFront(&cacheEntry{input: input, expr: expr, err: err})
+	c.evict()
+	return expr, err
+}
+
+// SetCapacity changes the capacity of the cache, evicting the least
+// recently used inputs beyond it. A capacity of 0 or less empties the cache
+// and disables it.
+func (c *ExpressionCache) SetCapacity(capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capacity = capacity
+	c.evict()
+}
+
+// evict removes the least recently used entries beyond the capacity.
+func (c *ExpressionCache) evict() {
+	for c.order.Len() > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).input)
+	}
+}
+
+// Stats returns the counts of the cache so far.
+func (c *ExpressionCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Len: c.order.Len(), Capacity: c.capacity}
+}
+
+// end of file
@@ -0,0 +1,112 @@
+package expressionparser
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestExpressionCache(t *testing.T) {
+	c := NewExpressionCache(2)
+	a, err := c.Compile("x + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := c.Compile("x + 1"); again != a {
+		t.Error("compiling x + 1 again gave another *Expression")
+	}
+	_, bad := c.Compile("2 +")
+	if _, again := c.Compile("2 +"); bad == nil || again != bad {
+		t.Errorf("compiling 2 + again gave error %v, want the cached %v", again, bad)
+	}
+	if got, want := c.Stats(), (CacheStats{Hits: 2, Misses: 2, Len: 2, Capacity: 2}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	// x + 1 is the least recently used, and is evicted first
+	c.Compile("2 +")
+	c.Compile("y * 2")
+	if got := c.Stats(); got.Len != 2 || got.Misses != 3 {
+		t.Errorf("Stats() after y * 2 = %+v, want 2 held after 3 misses", got)
+	}
+	if again, _ := c.Compile("x + 1"); again == a {
+		t.Error("x + 1 was not evicted")
+	}
+	if got := c.Stats(); got.Misses != 4 {
+		t.Errorf("Stats() = %+v, want the evicted x + 1 to miss", got)
+	}
+	// which pushed out 2 +, used before y * 2
+	if _, err := c.Compile("2 +"); err == bad {
+		t.Error("2 + was not evicted")
+	}
+
+	c.SetCapacity(1)
+	if got := c.Stats(); got.Len != 1 || got.Capacity != 1 {
+		t.Errorf("Stats() after SetCapacity(1) = %+v", got)
+	}
+}
+
+func TestExpressionCacheDisabled(t *testing.T) {
+	for _, capacity := range []int{0, -1} {
+		c := NewExpressionCache(capacity)
+		a, _ := c.Compile("x + 1")
+		b, _ := c.Compile("x + 1")
+		if a == b {
+			t.Errorf("capacity %d: compiling twice gave the same *Expression", capacity)
+		}
+		if got, want := c.Stats(), (CacheStats{Misses: 2, Capacity: capacity}); got != want {
+			t.Errorf("capacity %d: Stats() = %+v, want %+v", capacity, got, want)
+		}
+	}
+	c := NewExpressionCache(4)
+	c.Compile("x")
+	c.SetCapacity(0)
+	if got := c.Stats(); got.Len != 0 {
+		t.Errorf("Stats() after SetCapacity(0) = %+v, want it empty", got)
+	}
+}
+
+func TestCompileCached(t *testing.T) {
+	a, err := CompileCached("(2 + 3) * 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := CompileCached("(2 + 3) * 5"); b != a {
+		t.Error("CompileCached twice gave different *Expressions")
+	}
+	if got, _ := a.Eval(nil); got != 25 {
+		t.Errorf("(2 + 3) * 5 = %v, want 25", got)
+	}
+}
+
+func TestExpressionCacheConcurrent(t *testing.T) {
+	c := NewExpressionCache(8)
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				n := (g + i) % 12 // more inputs than the capacity, to evict
+				e, err := c.Compile(fmt.Sprintf("x + %d", n))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if got, err := e.Eval(map[string]float64{"x": 1}); err != nil || got != float64(1+n) {
+					t.Errorf("x + %d = %v, %v", n, got, err)
+					return
+				}
+				if i%50 == 0 {
+					c.Stats()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if got := c.Stats(); got.Hits+got.Misses != 16*200 || got.Len > 8 {
+		t.Errorf("Stats() = %+v, want %d lookups and at most 8 held", got, 16*200)
+	}
+}
+
+// end of file
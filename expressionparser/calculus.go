@@ -0,0 +1,401 @@
+package expressionparser
+
+import (
+	"fmt"
+	"go/token"
+	"math"
+)
+
+// Special forms: calls, such as deriv(x^2, "x", 3), integrate(x^2, "x", 0,
+// 1) and solve(x^2 - 2, "x", 1), whose first argument is an expression
+// evaluated at several values of the variable named by the string literal
+// second, rather than once before the call. The variable is
+// bound in the first argument only, as let binds it in its body; the
+// further arguments are evaluated once, as those of other builtins are.
+var specialForms = map[string]builtin{
+	"deriv":     {arity: 3},
+	"integrate": {arity: 4},
+	"solve":     {arity: 3, optional: 1},
+	"bisect":    {arity: 4},
+}
+
+// defaultIntegrationTolerance is the tolerance of integrate unless set
+// WithIntegrationTolerance.
+const defaultIntegrationTolerance = 1e-10
+
+// WithIntegrationTolerance sets the absolute error integrate aims for,
+// 1e-10 by default.
+func WithIntegrationTolerance(tol float64) Option {
+	return func(c *config) {
+		c.integrationTolerance = tol
+	}
+}
+
+// sample evaluates the first argument of a special form with its variable
+// bound to x.
+type sample func(x float64) (Value, error)
+
+// formVariable returns the variable a call to a special form binds in its
+// first argument, when it has one.
+func formVariable(call *FunctionCall) (string, bool) {
+	if _, ok := specialForms[call.Name]; !ok || len(call.Args) < 2 {
+		return "", false
+	}
+	name, ok := call.Args[1].(*StringLiteral)
+	if !ok || !token.IsIdentifier(name.Value) {
+		return "", false
+	}
+	return name.Value, true
+}
+
+// isSpecialForm reports whether a call goes to a special form, one not
+// replaced by a function registered on the evaluator.
+func (ev *Evaluator) isSpecialForm(call *FunctionCall) bool {
+	_, registered := ev.funcs[call.Name]
+	_, ok := specialForms[call.Name]
+	return ok && !registered
+}
+
+// checkForm checks the number of arguments of a call to a special form and
+// returns the variable it binds.
+func checkForm(call *FunctionCall) (string, error) {
+	if err := specialForms[call.Name].checkArgs(call.Name, len(call.Args)); err != nil {
+		return "", err
+	}
+	name, ok := formVariable(call)
+	if !ok {
+		return "", withCode(CodeType, fmt.Errorf("%s: argument 2 must be the name of a variable in quotes, as in %s(x^2, \"x\", 1)", call.Name, call.Name))
+	}
+	return name, nil
+}
+
+// callSpecialForm evaluates a call to a special form in env.
+func (ev *Evaluator) callSpecialForm(call *FunctionCall, env *scope) (Value, error) {
+	name, err := checkForm(call)
+	if err != nil {
+		return Value{}, err
+	}
+	args := make([]Value, len(call.Args)-2)
+	for i, arg := range call.Args[2:] {
+		value, err := ev.eval(arg, env)
+		if err != nil {
+			return Value{}, err
+		}
+		args[i] = value
+	}
+	if ev.stats != nil {
+		ev.stats.stats.Calls[call.Name]++
+	}
+	return ev.applySpecialForm(call, name, func(x float64) (Value, error) {
+		return ev.eval(call.Args[0], &scope{name: name, value: NumberValue(x), parent: env})
+	}, args)
+}
+
+// compileSpecialForm compiles a call to a special form, its variable taking
+// the next slot of the let bindings.
+func (ev *Evaluator) compileSpecialForm(call *FunctionCall, lets []string, depth *int) closure {
+	name, err := checkForm(call)
+	if err != nil {
+		return fail(err)
+	}
+	slot := len(lets)
+	if slot+1 > *depth {
+		*depth = slot + 1
+	}
+	body := ev.compileNode(call.Args[0], append(lets[:slot:slot], name), depth)
+	args := make([]closure, len(call.Args)-2)
+	for i, arg := range call.Args[2:] {
+		args[i] = ev.compileNode(arg, lets, depth)
+	}
+	return func(f *frame) (Value, error) {
+		values := make([]Value, len(args))
+		for i, arg := range args {
+			value, err := arg(f)
+			if err != nil {
+				return Value{}, err
+			}
+			values[i] = value
+		}
+		return ev.applySpecialForm(call, name, func(x float64) (Value, error) {
+			f.lets[slot] = NumberValue(x)
+			return body(f)
+		}, values)
+	}
+}
+
+// applySpecialForm applies a special form, binding the variable name for
+// f, to the values of its further arguments.
+func (ev *Evaluator) applySpecialForm(call *FunctionCall, name string, f sample, args []Value) (Value, error) {
+	numbers := make([]float64, len(args))
+	for i, arg := range args {
+		if !arg.numeric() {
+			return Value{}, withCode(CodeType, fmt.Errorf("%s: argument %d is a %s, not a number", call.Name, i+3, arg.kind))
+		}
+		numbers[i] = arg.num
+	}
+	at := func(x float64) (float64, error) {
+		value, err := f(x)
+		if err == nil && !value.numeric() {
+			err = withCode(CodeType, fmt.Errorf("the expression is a %s, not a number", value.kind))
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: at %s = %s: %w", call.Name, name, formatNumber(x), err)
+		}
+		return value.num, nil
+	}
+
+	var result float64
+	var err error
+	switch call.Name {
+	case "deriv":
+		result, err = checkedDerivative(at, name, numbers[0])
+	case "integrate":
+		tol := ev.cfg.integrationTolerance
+		if tol <= 0 {
+			tol = defaultIntegrationTolerance
+		}
+		result, err = integral(at, numbers[0], numbers[1], tol)
+	case "solve", "bisect":
+		s := &solver{form: call.Name, variable: name, f: at, iterations: ev.cfg.solveIterations}
+		if s.iterations <= 0 {
+			s.iterations = defaultSolveIterations
+		}
+		if len(numbers) == 1 {
+			result, err = s.newton(numbers[0])
+		} else {
+			result, err = s.bracket(numbers[0], numbers[1], call.Name == "solve")
+		}
+	}
+	if err != nil {
+		return Value{}, err
+	}
+	return NumberValue(result), nil
+}
+
+// Parameters of derivative: the first step, relative to the point, the
+// factor by which each further step shrinks, and the error, relative to
+// the scale of the estimates, beyond which deriv takes them not to converge
+const (
+	derivativeStep      = 0.01
+	derivativeShrink    = 1.4
+	derivativeRounds    = 10
+	derivativeTolerance = 1e-6
+)
+
+// derivative estimates f'(x) by Ridders' method: central differences
+// (f(x+h) - f(x-h)) / 2h for steps h shrinking from 0.01 * max(1, |x|) are
+// extrapolated to h = 0 Richardson-style, and the estimate with the
+// smallest error is taken, the steps stopping once rounding makes the
+// estimates worse. For smooth functions the result is accurate to about
+// ten significant digits; f is evaluated at most twenty times, never at x
+// itself. The estimate is returned however poorly the differences
+// converge, as the solvers need only a slope; see checkedDerivative.
+func derivative(f func(float64) (float64, error), x float64) (float64, error) {
+	best, _, _, err := ridders(f, x)
+	return best, err
+}
+
+// checkedDerivative is derivative for deriv, which gives an error rather
+// than an estimate when there is no derivative to estimate: when the
+// extrapolated differences do not agree to within derivativeTolerance of
+// their scale, as at a pole such as that of 1/x at 0, or when the slopes
+// of f either side of x differ by as much however close to x they are
+// taken, as at a kink such as that of abs(x) at 0. name is the variable
+// of f, for the error. f is evaluated at most twenty-eight times.
+func checkedDerivative(f func(float64) (float64, error), name string, x float64) (float64, error) {
+	best, bestError, scale, err := ridders(f, x)
+	if err != nil {
+		return 0, err
+	}
+	if !(bestError <= derivativeTolerance*scale) {
+		return 0, withCode(CodeNoConvergence, fmt.Errorf("deriv: no convergence at %s = %s, the estimates differing by %s", name, formatNumber(x), formatNumber(bestError)))
+	}
+
+	// The slope of f over [x+h, x+2h] less that over [x-2h, x-h] shrinks
+	// with h where f is smooth, being about 3h f''(x), but not at a kink
+	gap := func(h float64) (float64, error) {
+		var fs [4]float64
+		for i, t := range [...]float64{x - 2*h, x - h, x + h, x + 2*h} {
+			if fs[i], err = f(t); err != nil {
+				return 0, err
+			}
+		}
+		return ((fs[3] - fs[2]) - (fs[1] - fs[0])) / h, nil
+	}
+	h := derivativeStep * math.Max(1, math.Abs(x)) / 10
+	wide, err := gap(h)
+	if err != nil {
+		return 0, err
+	}
+	narrow, err := gap(h / 4)
+	if err != nil {
+		return 0, err
+	}
+	if math.Abs(narrow) > derivativeTolerance*scale && math.Abs(narrow) > math.Abs(wide)/2 {
+		return 0, withCode(CodeNoConvergence, fmt.Errorf("deriv: no convergence at %s = %s, the slopes either side differing by %s", name, formatNumber(x), formatNumber(narrow)))
+	}
+	return best, nil
+}
+
+// ridders computes the estimate of derivative with its error, and the
+// scale the error is measured against: the largest of the magnitude of the
+// estimate and that of f near x relative to the first step, below which
+// rounding is lost.
+func ridders(f func(float64) (float64, error), x float64) (best, bestError, scale float64, err error) {
+	var fmax float64
+	diff := func(h float64) (float64, error) {
+		above, err := f(x + h)
+		if err != nil {
+			return 0, err
+		}
+		below, err := f(x - h)
+		if err != nil {
+			return 0, err
+		}
+		fmax = math.Max(fmax, math.Max(math.Abs(above), math.Abs(below)))
+		return (above - below) / (2 * h), nil
+	}
+
+	h := derivativeStep * math.Max(1, math.Abs(x))
+	var table [derivativeRounds][derivativeRounds]float64
+	first, err := diff(h)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	scale = fmax / math.Max(1, math.Abs(x))
+	table[0][0] = first
+	best, bestError = first, math.Inf(1)
+	for i := 1; i < derivativeRounds; i++ {
+		h /= derivativeShrink
+		if table[0][i], err = diff(h); err != nil {
+			return 0, 0, 0, err
+		}
+		factor := derivativeShrink * derivativeShrink
+		for j := 1; j <= i; j++ {
+			table[j][i] = (table[j-1][i]*factor - table[j-1][i-1]) / (factor - 1)
+			factor *= derivativeShrink * derivativeShrink
+			e := math.Max(math.Abs(table[j][i]-table[j-1][i]), math.Abs(table[j][i]-table[j-1][i-1]))
+			if e <= bestError {
+				best, bestError = table[j][i], e
+			}
+		}
+		if math.Abs(table[i][i]-table[i-1][i-1]) >= 2*bestError {
+			break
+		}
+	}
+	return best, bestError, math.Max(math.Abs(best), scale), nil
+}
+
+// Limits of integral: the panels the interval starts split into, and the
+// most it subdivides, in total and by halving one panel repeatedly, before
+// giving up
+const (
+	integralPanels       = 8
+	integralSubdivisions = 10000
+	integralDepth        = 50
+)
+
+// integral computes the integral of f from a to b, or minus that from b to
+// a when b is less, by adaptive Simpson quadrature: the interval is split
+// into 8 panels, and every panel whose Simpson estimate differs by more
+// than 15 * tol from that of its two halves, each given half its tolerance,
+// is halved again, the halves' estimates being combined with Richardson's
+// correction. An integrand needing more than 10000 subdivisions, or 50
+// halvings of one panel, to reach the tolerance, such as one with a
+// singularity, is an error rather than an endless computation.
+func integral(f func(float64) (float64, error), a, b, tol float64) (float64, error) {
+	if math.IsNaN(a) || math.IsNaN(b) || math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("integrate: bounds %s and %s must be finite", formatNumber(a), formatNumber(b)))
+	}
+	if a > b {
+		result, err := integral(f, b, a, tol)
+		return -result, err
+	}
+	if a == b {
+		return 0, nil
+	}
+
+	q := &quadrature{f: f, tol: tol}
+	total := 0.0
+	width := (b - a) / integralPanels
+	lo := a
+	flo, err := f(a)
+	if err != nil {
+		return 0, err
+	}
+	for i := 1; i <= integralPanels; i++ {
+		hi := a + float64(i)*width
+		if i == integralPanels {
+			hi = b
+		}
+		mid := (lo + hi) / 2
+		fmid, err := f(mid)
+		if err != nil {
+			return 0, err
+		}
+		fhi, err := f(hi)
+		if err != nil {
+			return 0, err
+		}
+		panel, err := q.adapt(lo, hi, flo, fmid, fhi, simpson(lo, hi, flo, fmid, fhi), tol/integralPanels, 0)
+		if err != nil {
+			return 0, err
+		}
+		total += panel
+		lo, flo = hi, fhi
+	}
+	return total, nil
+}
+
+// quadrature holds the integrand and the tolerance of integral and counts
+// its subdivisions.
+type quadrature struct {
+	f            func(float64) (float64, error)
+	tol          float64
+	subdivisions int
+}
+
+// adapt refines the Simpson estimate whole of the integral from a to b,
+// where f takes the values fa, fm and fb at a, the midpoint and b.
+func (q *quadrature) adapt(a, b, fa, fm, fb, whole, tol float64, depth int) (float64, error) {
+	q.subdivisions++
+	if q.subdivisions > integralSubdivisions || depth > integralDepth {
+		return 0, withCode(CodeNoConvergence, fmt.Errorf("integrate: no convergence to within %s after %d subdivisions", formatNumber(q.tol), q.subdivisions-1))
+	}
+	m := (a + b) / 2
+	lm, rm := (a+m)/2, (m+b)/2
+	flm, err := q.f(lm)
+	if err != nil {
+		return 0, err
+	}
+	frm, err := q.f(rm)
+	if err != nil {
+		return 0, err
+	}
+	left, right := simpson(a, m, fa, flm, fm), simpson(m, b, fm, frm, fb)
+	delta := left + right - whole
+	if math.IsNaN(delta) {
+		// Subdividing cannot help an integrand that is NaN
+		return delta, nil
+	}
+	if math.Abs(delta) <= 15*tol {
+		return left + right + delta/15, nil
+	}
+	l, err := q.adapt(a, m, fa, flm, fm, left, tol/2, depth+1)
+	if err != nil {
+		return 0, err
+	}
+	r, err := q.adapt(m, b, fm, frm, fb, right, tol/2, depth+1)
+	if err != nil {
+		return 0, err
+	}
+	return l + r, nil
+}
+
+// simpson returns Simpson's estimate of the integral from a to b, where f
+// takes the values fa, fm and fb at a, the midpoint and b.
+func simpson(a, b, fa, fm, fb float64) float64 {
+	return (b - a) / 6 * (fa + 4*fm + fb)
+}
+
+// end of file
@@ -0,0 +1,136 @@
+package expressionparser
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDeriv(t *testing.T) {
+	tests := []struct {
+		body     string
+		at       float64
+		want     float64
+		symbolic bool // whether Derivative differentiates body
+	}{
+		{"x*x + 3*x", 2, 7, true},
+		{"sin(x)", 1, math.Cos(1), true},
+		{"exp(x)", 10, math.Exp(10), true},
+		{"x^3", 1000, 3e6, true},
+		{"abs(x)", 1, 1, false},
+		{"tan(x)", 1.5, 1 / (math.Cos(1.5) * math.Cos(1.5)), true},
+		{"5", 0, 0, true},
+	}
+	for _, tt := range tests {
+		vars := map[string]float64{"at": tt.at}
+		got, err := EvaluateWithVars(`deriv(`+tt.body+`, "x", at)`, vars)
+		if err != nil {
+			t.Errorf("deriv(%s) at %v: unexpected error %v", tt.body, tt.at, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-8*math.Max(1, math.Abs(tt.want)) {
+			t.Errorf("deriv(%s) at %v = %v, want %v", tt.body, tt.at, got, tt.want)
+		}
+
+		if !tt.symbolic {
+			continue
+		}
+		// The symbolic derivative agrees
+		expr, err := ParseString(tt.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := Derivative(expr, "x")
+		if err != nil {
+			t.Errorf("Derivative(%s): %v", tt.body, err)
+			continue
+		}
+		exact, err := EvalWithVars(d, map[string]float64{"x": tt.at})
+		if err != nil || math.Abs(got-exact) > 1e-8*math.Max(1, math.Abs(exact)) {
+			t.Errorf("deriv(%s) at %v = %v, Derivative gives %v, %v", tt.body, tt.at, got, exact, err)
+		}
+	}
+}
+
+func TestDerivNoConvergence(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{`deriv(1/x, "x", 0)`, "deriv: no convergence at x = 0, the estimates differing"},
+		{`deriv(abs(x), "x", 0)`, "deriv: no convergence at x = 0, the slopes either side differing by 2"},
+		{`deriv(floor(x), "x", 1)`, "deriv: no convergence at x = 1"},
+	}
+	for _, tt := range tests {
+		got, err := Evaluate(tt.input)
+		if err == nil {
+			t.Errorf("%s = %v, want an error", tt.input, got)
+			continue
+		}
+		if ErrorCode(err) != CodeNoConvergence || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: error %q (%s), want %s containing %q", tt.input, err, ErrorCode(err), CodeNoConvergence, tt.msg)
+		}
+	}
+}
+
+func TestDerivPropagatesErrors(t *testing.T) {
+	_, err := Evaluate(`deriv(sqrt(x), "x", 0)`)
+	if ErrorCode(err) != CodeOutsideDomain || !strings.Contains(err.Error(), "deriv: at x = -0.01: sqrt") {
+		t.Errorf(`deriv(sqrt(x), "x", 0): error %v, want the domain error of sqrt in context`, err)
+	}
+}
+
+func TestIntegrate(t *testing.T) {
+	vars := map[string]float64{"pi": math.Pi, "k": 3}
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{`integrate(x*x, "x", 0, 3)`, 9},
+		{`integrate(x*x, "x", 3, 0)`, -9},
+		{`integrate(sin(x), "x", 0, 2*pi)`, 0},
+		{`integrate(sin(x), "x", 0, pi)`, 2},
+		{`integrate(exp(-x*x), "x", -10, 10)`, math.Sqrt(math.Pi)},
+		{`integrate(t * k, "t", 0, 2)`, 6},
+		{`integrate(x, "x", 1, 1)`, 0},
+		{`integrate(integrate(x * y, "y", 0, 1), "x", 0, 2)`, 1},
+	}
+	for _, tt := range tests {
+		got, err := EvalWithVars(mustParse(t, tt.input), vars)
+		if err != nil || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+	loose := NewEvaluator(WithIntegrationTolerance(1e-3))
+	if got, err := loose.Eval(mustParse(t, `integrate(exp(x), "x", 0, 1)`)); err != nil || math.Abs(got-(math.E-1)) > 1e-3 {
+		t.Errorf("integrate(exp(x)) to within 1e-3 = %v, %v", got, err)
+	}
+}
+
+func TestIntegrateErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		// An integrand failing at a sample names the value of the variable
+		{`integrate(1/x, "x", -1, 1)`, CodeDivisionByZero, `integrate: at x = 0: division by zero in "1 / x" at offset 10`},
+		{`integrate(sqrt(x - 1), "x", 0, 2)`, CodeOutsideDomain, `integrate: at x = 0: sqrt: argument -1 is outside the domain`},
+		// Too many subdivisions stop it rather than hanging
+		{`integrate(sin(1/x), "x", 0.0001, 1)`, CodeNoConvergence, "integrate: no convergence to within 1e-10 after 10000 subdivisions"},
+		{`integrate(x, "x", 0, 1/0)`, CodeDivisionByZero, ""},
+		{`integrate(x, x, 0, 1)`, CodeType, "integrate: argument 2 must be the name of a variable in quotes"},
+	}
+	for _, tt := range tests {
+		_, err := Evaluate(tt.input)
+		if ErrorCode(err) != tt.code || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: error %v (%s), want %s containing %q", tt.input, err, ErrorCode(err), tt.code, tt.msg)
+		}
+	}
+	if _, err := NewEvaluator(WithIntegrationTolerance(-1)).Eval(Num(1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("WithIntegrationTolerance(-1): error %v", err)
+	}
+}
+
+// end of file
@@ -0,0 +1,124 @@
+package expressionparser
+
+import "sort"
+
+// Canonicalize returns a normal form of expr in which every chain of + or *
+// (a maximal run of the same operator, however it is parenthesized) is
+// flattened and its operands sorted: numbers first by value, then variables
+// by name, then any other subexpression by its S-expression form. The result
+// is rebuilt left-associated. Non-commutative operators keep their operand
+// order, and operands are never moved across a different operator.
+//
+// A chain of + is sorted only when every operand is known to be a number,
+// since + also concatenates strings: a literal, a comparison or logical
+// operator, a call to a builtin of numbers, or arithmetic and conditionals
+// of those. Any other chain of +, such as "b + a" or "s + 1", whose
+// variables may hold strings, keeps its order.
+//
+// Because a flattened chain is regrouped, floating-point results can differ
+// from the original in the last bits (and in rare overflow cases), exactly as
+// reassociating a sum by hand would. Use it for deduplication alongside Hash
+// and Equal, not to rewrite expressions that must evaluate bit-for-bit.
+func Canonicalize(expr Expr) Expr {
+	b, ok := expr.(*BinaryOp)
+	if !ok || (b.Op.Type != PLUS && b.Op.Type != MULT) {
+		children := Children(expr)
+		if len(children) == 0 {
+			return expr
+		}
+		canonical := make([]Expr, len(children))
+		for i, child := range children {
+			canonical[i] = Canonicalize(child)
+		}
+		return withChildren(expr, canonical)
+	}
+
+	operands := flattenChain(b, b.Op.Type, nil)
+	numeric := true
+	for i, operand := range operands {
+		operands[i] = Canonicalize(operand)
+		numeric = numeric && knownNumeric(operand)
+	}
+	if b.Op.Type == PLUS && !numeric {
+		return rebuildChain(b.Op, operands)
+	}
+	sort.SliceStable(operands, func(i, j int) bool {
+		return canonicalLess(operands[i], operands[j])
+	})
+
+	return rebuildChain(b.Op, operands)
+}
+
+// rebuildChain joins operands with op, left-associated.
+func rebuildChain(op Token, operands []Expr) Expr {
+	result := operands[0]
+	for _, operand := range operands[1:] {
+		result = &BinaryOp{Left: result, Op: op, Right: operand}
+	}
+	return result
+}
+
+// flattenChain appends the operands of a run of the given operator to operands.
+func flattenChain(expr Expr, op TokenType, operands []Expr) []Expr {
+	if b, ok := expr.(*BinaryOp); ok && b.Op.Type == op {
+		operands = flattenChain(b.Left, op, operands)
+		return flattenChain(b.Right, op, operands)
+	}
+	return append(operands, expr)
+}
+
+// knownNumeric reports whether expr gives a number or a bool whenever it
+// gives a value at all, whatever its variables hold.
+func knownNumeric(expr Expr) bool {
+	switch v := expr.(type) {
+	case *Number:
+		return true
+	case *UnaryOp:
+		return v.Op.Type == NOT || knownNumeric(v.Operand)
+	case *BinaryOp:
+		switch v.Op.Type {
+		case AND, OR, EQ, NE, LT, LE, GT, GE:
+			return true
+		case PLUS, MINUS, MULT, DIV, MOD, POW:
+			return knownNumeric(v.Left) && knownNumeric(v.Right)
+		}
+	case *Conditional:
+		return knownNumeric(v.Then) && knownNumeric(v.Else)
+	case *FunctionCall:
+		_, ok := builtins[v.Name]
+		return ok
+	}
+	return false
+}
+
+// canonicalRank orders node kinds: numbers, then variables, then the rest.
+func canonicalRank(expr Expr) int {
+	switch expr.(type) {
+	case *Number:
+		return 0
+	case *Variable:
+		return 1
+	}
+	return 2
+}
+
+// canonicalLess is the deterministic ordering of chain operands.
+func canonicalLess(a, b Expr) bool {
+	ra, rb := canonicalRank(a), canonicalRank(b)
+	if ra != rb {
+		return ra < rb
+	}
+	switch x := a.(type) {
+	case *Number:
+		y := b.(*Number)
+		if x.Imag != y.Imag {
+			return !x.Imag
+		}
+		return x.Value < y.Value
+	case *Variable:
+		return x.Name < b.(*Variable).Name
+	}
+	return ToSExpr(a) < ToSExpr(b)
+}
+
+// end of file
@@ -0,0 +1,88 @@
+package expressionparser
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCanonicalizeEquates(t *testing.T) {
+	pairs := [][2]string{
+		{"1 + sin(a) + (b < c)", "(b < c) + (sin(a) + 1)"},
+		{"2 * x * y", "y * (x * 2)"},
+		{"x * (abs(y) + 1)", "(1 + abs(y)) * x"},
+		{"sin(a * b) - 3", "sin(b * a) - 3"},
+		{"max(x, 1) + -2 + (x > 0 ? 1 : 0)", "(x > 0 ? 1 : 0) + max(x, 1) + -2"},
+	}
+	for _, pair := range pairs {
+		a, err := ParseString(pair[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseString(pair[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		ca, cb := Canonicalize(a), Canonicalize(b)
+		if !Equal(ca, cb) || Hash(ca) != Hash(cb) {
+			t.Errorf("%q canonicalizes to %q, %q to %q", pair[0], Format(ca), pair[1], Format(cb))
+		}
+	}
+}
+
+func TestCanonicalizeKeepsOrder(t *testing.T) {
+	pairs := [][2]string{
+		{"a - b", "b - a"},
+		{"a / b", "b / a"},
+		{"a + b * c", "b + a * c"},
+		{"(a + b) * c", "a + b * c"},
+		{"a ^ b", "b ^ a"},
+		// a and b may hold strings, which + concatenates
+		{"b + a", "a + b"},
+		{`"b" + "a"`, `"a" + "b"`},
+		{"f(x) + 1", "1 + f(x)"},
+	}
+	for _, pair := range pairs {
+		a, err := ParseString(pair[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseString(pair[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ca, cb := Canonicalize(a), Canonicalize(b); Equal(ca, cb) {
+			t.Errorf("%q and %q both canonicalize to %q", pair[0], pair[1], Format(ca))
+		}
+	}
+}
+
+func TestCanonicalizePreservesResults(t *testing.T) {
+	// Regrouping a chain may change the last bits of a result, so results
+	// need only agree to a relative tolerance
+	for _, input := range evalCorpus {
+		expr, err := ParseString(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		canonical := Canonicalize(expr)
+		want, wantErr := EvalWithVars(expr, corpusVars)
+		got, gotErr := EvalWithVars(canonical, corpusVars)
+		if ErrorCode(gotErr) != ErrorCode(wantErr) {
+			t.Errorf("%q canonicalizes to %q, which gives %v, want %v", input, Format(canonical), gotErr, wantErr)
+			continue
+		}
+		if wantErr == nil && got != want && !(math.Abs(got-want) <= 1e-12*math.Abs(want)) && !(got != got && want != want) {
+			t.Errorf("%q canonicalizes to %q, which evaluates to %v, want %v", input, Format(canonical), got, want)
+		}
+	}
+}
+
+func TestCanonicalizeKeepsConcatenation(t *testing.T) {
+	env := map[string]Value{"s": StringValue("c")}
+	got, err := EvalValue(Canonicalize(mustParse(t, `"b" + ("a" + s)`)), env)
+	if err != nil || got.String() != `"bac"` {
+		t.Errorf("canonical form gives %v, %v; want \"bac\"", got, err)
+	}
+}
+
+// end of file
@@ -0,0 +1,120 @@
+package expressionparser
+
+import "fmt"
+
+// CellRef is a reference to a cell of a spreadsheet, letters then digits
+// as in A1 or AB12, parsed WithCellReferences and evaluated by the
+// CellResolver set WithCellResolver.
+type CellRef struct {
+	Ref  string
+	Span Span
+}
+
+// CellRange is the rectangle of cells with corners From and To, as in
+// B1:B10, parsed WithCellReferences. It evaluates to the list of the values
+// the CellResolver gives for it, so that it can be aggregated, as in
+// sum(B1:B10).
+type CellRange struct {
+	From string
+	To   string
+	Span Span
+}
+
+// String returns the reference.
+func (c *CellRef) String() string {
+	return Format(c)
+}
+
+// String returns the range.
+func (c *CellRange) String() string {
+	return Format(c)
+}
+
+// CellResolver supplies the values of the cells of a spreadsheet to the
+// evaluation of CellRef and CellRange nodes. The ranges it is given have
+// their corners in the order written. Errors, such as those of references
+// outside the grid, are returned from the evaluation, after the reference;
+// circular references are for the resolver to detect.
+type CellResolver interface {
+	Value(ref string) (float64, error)
+	Range(from, to string) ([]float64, error)
+}
+
+// WithCellReferences makes the Parser read names of letters followed by
+// digits, such as A1, as CellRef nodes rather than variables, unless they
+// are called, and two of them joined by a colon with no space around it,
+// as in B1:B10, as a CellRange. Ranges are written without spaces so that
+// they are told apart from the colon of ?:, which needs one before a cell
+// reference: c ? A1 : B1.
+func WithCellReferences() Option {
+	return func(c *config) {
+		c.cellReferences = true
+	}
+}
+
+// WithCellResolver makes Eval and Compile evaluate cell references and
+// ranges with r; they are errors without one. It is usually given along
+// with WithCellReferences, which makes the Parser read them.
+func WithCellResolver(r CellResolver) Option {
+	return func(c *config) {
+		c.cells = r
+	}
+}
+
+// isCellName reports whether name is letters followed by digits.
+func isCellName(name string) bool {
+	i := 0
+	for i < len(name) && (name[i] >= 'A' && name[i] <= 'Z' || name[i] >= 'a' && name[i] <= 'z') {
+		i++
+	}
+	if i == 0 || i == len(name) {
+		return false
+	}
+	for _, c := range name[i:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCell returns the reference or range starting with tok, a cell name
+// just consumed.
+func (p *Parser) parseCell(tok Token) (Expr, error) {
+	end := tok.Pos + len(tok.Value)
+	if p.curr.Type == COLON && p.curr.Pos == end {
+		peek := *p.lexer
+		if next := peek.NextToken(); next.Type == IDENT && next.Pos == end+1 && isCellName(next.Value) {
+			p.nextToken()
+			p.nextToken()
+			return &CellRange{From: tok.Value, To: next.Value, Span: Span{Start: tok.Pos, End: p.prevEnd}}, nil
+		}
+	}
+	return &CellRef{Ref: tok.Value, Span: Span{Start: tok.Pos, End: end}}, nil
+}
+
+// cellValue evaluates a cell reference with the resolver.
+func (ev *Evaluator) cellValue(c *CellRef) (Value, error) {
+	if ev.cfg.cells == nil {
+		return Value{}, withCode(CodeUndefinedVar, fmt.Errorf("cell %s cannot be resolved: no CellResolver is set", c.Ref))
+	}
+	value, err := ev.cfg.cells.Value(c.Ref)
+	if err != nil {
+		return Value{}, fmt.Errorf("cell %s: %w", c.Ref, err)
+	}
+	return NumberValue(value), nil
+}
+
+// cellRange evaluates a cell range with the resolver to a list.
+func (ev *Evaluator) cellRange(c *CellRange) (Value, error) {
+	if ev.cfg.cells == nil {
+		return Value{}, withCode(CodeUndefinedVar, fmt.Errorf("cell range %s:%s cannot be resolved: no CellResolver is set", c.From, c.To))
+	}
+	values, err := ev.cfg.cells.Range(c.From, c.To)
+	if err != nil {
+		return Value{}, fmt.Errorf("cell range %s:%s: %w", c.From, c.To, err)
+	}
+	return Value{kind: ListKind, list: &list{items: values}}, nil
+}
+
+// end of file
@@ -0,0 +1,123 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// errNoSuchCell is the error of a reference outside a fakeGrid.
+var errNoSuchCell = errors.New("no such cell")
+
+// fakeGrid is a CellResolver over cells in columns A to Z of rows 1 to 99.
+type fakeGrid map[string]float64
+
+// cell returns the column and row of a reference to the grid.
+func (g fakeGrid) cell(ref string) (byte, int, error) {
+	ref = strings.ToUpper(ref)
+	if row, err := strconv.Atoi(ref[1:]); len(ref) >= 2 && err == nil && row >= 1 && row <= 99 {
+		return ref[0], row, nil
+	}
+	return 0, 0, errNoSuchCell
+}
+
+func (g fakeGrid) Value(ref string) (float64, error) {
+	if _, _, err := g.cell(ref); err != nil {
+		return 0, err
+	}
+	return g[strings.ToUpper(ref)], nil
+}
+
+func (g fakeGrid) Range(from, to string) ([]float64, error) {
+	c1, r1, err := g.cell(from)
+	if err != nil {
+		return nil, err
+	}
+	c2, r2, err := g.cell(to)
+	if err != nil {
+		return nil, err
+	}
+	var values []float64
+	for c := min(c1, c2); c <= max(c1, c2); c++ {
+		for r := min(r1, r2); r <= max(r1, r2); r++ {
+			values = append(values, g[fmt.Sprintf("%c%d", c, r)])
+		}
+	}
+	return values, nil
+}
+
+func TestCellReferences(t *testing.T) {
+	grid := fakeGrid{"A1": 2, "B1": 1, "B2": 2, "B3": 3, "B10": 4, "C1": 10, "C2": 20}
+	opts := []Option{WithCellReferences(), WithCellResolver(grid)}
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"A1", 2},
+		{"A1 * 3 + a1", 8}, // in either case
+		{"A1 * sum(B1:B10)", 20},
+		{"sum(B10:B1)", 10}, // corners in either order
+		{"sum(B1:C2)", 33},
+		{"avg(B1:B3)", 2},
+		{"count(A1:A5)", 5},
+		{"A1 > 1 ? C1 : C2", 10},
+		{"let x = 3 in x * A1", 6},
+		{"Z99", 0}, // empty
+	}
+	for _, tt := range tests {
+		got, err := EvaluateWithVars(tt.input, nil, opts...)
+		if err != nil || got != tt.want {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+		e, err := Compile(tt.input, opts...)
+		if err != nil {
+			t.Errorf("Compile(%s): %v", tt.input, err)
+			continue
+		}
+		if got, err := e.Eval(nil); err != nil || got != tt.want {
+			t.Errorf("%s compiled = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	// Parsed as cells, printed back as written
+	expr, err := ParseString("A1*sum(B1:B10)+C2", WithCellReferences())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Format(expr); got != "A1 * sum(B1:B10) + C2" {
+		t.Errorf("Format = %q", got)
+	}
+	if IsConstant(expr) || len(Variables(expr)) != 0 {
+		t.Errorf("cells: IsConstant %v, Variables %v", IsConstant(expr), Variables(expr))
+	}
+	// Without the option they are variables
+	if got, err := EvaluateWithVars("A1 + 1", map[string]float64{"A1": 4}); err != nil || got != 5 {
+		t.Errorf("A1 + 1 as a variable = %v, %v; want 5", got, err)
+	}
+}
+
+func TestCellReferenceErrors(t *testing.T) {
+	opts := []Option{WithCellReferences(), WithCellResolver(fakeGrid{})}
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"A100 + 1", "cell A100: no such cell"},
+		{"sum(A1:A100)", "cell range A1:A100: no such cell"},
+		{"AA1", "cell AA1: no such cell"},
+	}
+	for _, tt := range tests {
+		_, err := EvaluateWithVars(tt.input, nil, opts...)
+		if !errors.Is(err, errNoSuchCell) || !strings.HasPrefix(err.Error(), tt.msg+" in ") {
+			t.Errorf("%s: error %v, want %q", tt.input, err, tt.msg)
+		}
+	}
+	_, err := EvaluateWithVars("B2 * 2", nil, WithCellReferences())
+	if ErrorCode(err) != CodeUndefinedVar || !strings.HasPrefix(err.Error(), "cell B2 cannot be resolved: no CellResolver is set") {
+		t.Errorf("B2 without a resolver: error %v", err)
+	}
+}
+
+// end of file
@@ -0,0 +1,109 @@
+package expressionparser
+
+import "fmt"
+
+// Problem is an issue found by Check, located by the span of the offending node.
+type Problem struct {
+	Span    Span
+	Message string
+	Code    string // one of the CodeCheck error codes
+}
+
+// String renders the problem with its offset.
+func (p Problem) String() string {
+	return fmt.Sprintf("%s at offset %d", p.Message, p.Span.Start)
+}
+
+// CheckOptions configures Check.
+type CheckOptions struct {
+	// Variables, when non-nil, is the set of variable names the expression
+	// may reference. Names bound by let are always allowed.
+	Variables map[string]bool
+
+	// Functions, when non-nil, replaces the builtins as the known functions,
+	// mapping each name to its argument count.
+	Functions map[string]int
+}
+
+// Check statically validates expr without evaluating it, reporting every
+// call to an unknown function, call with the wrong number of arguments,
+// reference to a variable outside the allowed set and division or modulo by
+// a constant zero. The result is empty, never nil, when no problem is found.
+func Check(expr Expr, opts CheckOptions) []Problem {
+	c := &checker{opts: opts, problems: []Problem{}}
+	c.check(expr, nil)
+	return c.problems
+}
+
+// checker accumulates problems during a traversal.
+type checker struct {
+	opts     CheckOptions
+	problems []Problem
+}
+
+// report records a problem at the span of expr.
+func (c *checker) report(expr Expr, code, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{Span: SpanOf(expr), Message: fmt.Sprintf(format, args...), Code: code})
+}
+
+// function returns the signature of a known function.
+func (c *checker) function(name string) (builtin, bool) {
+	if c.opts.Functions != nil {
+		n, ok := c.opts.Functions[name]
+		return builtin{arity: n}, ok
+	}
+	return lookupBuiltin(name)
+}
+
+// check visits expr with the given let-bound names in scope.
+func (c *checker) check(expr Expr, bound *scope) {
+	switch v := expr.(type) {
+	case *Variable:
+		if _, ok := bound.lookup(v.Name); !ok && c.opts.Variables != nil && !c.opts.Variables[v.Name] {
+			c.report(v, CodeCheckVariable, "unknown variable %s", v.Name)
+		}
+	case *FunctionCall:
+		if b, ok := c.function(v.Name); !ok {
+			c.report(v, CodeCheckFunction, "unknown function %s", v.Name)
+		} else if err := b.checkArgs(v.Name, len(v.Args)); err != nil {
+			c.report(v, CodeCheckArgumentCount, "%v", err)
+		}
+		if name, binder, body, ok := formBinding(v); ok && c.opts.Functions == nil {
+			for i, arg := range v.Args {
+				switch i {
+				case body:
+					c.check(arg, &scope{name: name, parent: bound})
+				case binder:
+				default:
+					c.check(arg, bound)
+				}
+			}
+			return
+		}
+	case *BinaryOp:
+		if v.Op.Type == DIV && isConstantZero(v.Right) {
+			c.report(v, CodeCheckDivisionZero, "division by zero")
+		} else if v.Op.Type == MOD && isConstantZero(v.Right) {
+			c.report(v, CodeCheckModuloZero, "modulo by zero")
+		}
+	case *Let:
+		c.check(v.Value, bound)
+		c.check(v.Body, &scope{name: v.Name, parent: bound})
+		return
+	}
+
+	for _, child := range Children(expr) {
+		c.check(child, bound)
+	}
+}
+
+// isConstantZero reports whether expr uses only literals and evaluates to zero.
+func isConstantZero(expr Expr) bool {
+	if len(Variables(expr)) > 0 || len(Functions(expr)) > 0 {
+		return false
+	}
+	value, err := Eval(expr)
+	return err == nil && value == 0
+}
+
+// end of file
@@ -0,0 +1,56 @@
+package expressionparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	vars := map[string]bool{"x": true, "y": true}
+	tests := []struct {
+		input string
+		opts  CheckOptions
+		want  []Problem
+	}{
+		{"x + sin(y)", CheckOptions{Variables: vars}, []Problem{}},
+		{"x + z", CheckOptions{Variables: vars}, []Problem{
+			{Span{4, 5}, "unknown variable z", CodeCheckVariable},
+		}},
+		{"let z = 1 in x + z", CheckOptions{Variables: vars}, []Problem{}},
+		{"1 + nosuch(x)", CheckOptions{}, []Problem{
+			{Span{4, 13}, "unknown function nosuch", CodeCheckFunction},
+		}},
+		{"sqrt(1, 2)", CheckOptions{}, []Problem{
+			{Span{0, 10}, "sqrt expects 1 argument(s), got 2", CodeCheckArgumentCount},
+		}},
+		{"f(1) + g(1)", CheckOptions{Functions: map[string]int{"f": 1, "g": 2}}, []Problem{
+			{Span{7, 11}, "g expects 2 argument(s), got 1", CodeCheckArgumentCount},
+		}},
+		{"x / (2 - 2)", CheckOptions{}, []Problem{
+			{Span{0, 11}, "division by zero", CodeCheckDivisionZero},
+		}},
+		{"x / y", CheckOptions{}, []Problem{}},
+		{"7 % 0", CheckOptions{}, []Problem{
+			{Span{0, 5}, "modulo by zero", CodeCheckModuloZero},
+		}},
+		// Every problem is reported, a node's before those within it
+		{"q / 0 + bad(q)", CheckOptions{Variables: vars}, []Problem{
+			{Span{0, 5}, "division by zero", CodeCheckDivisionZero},
+			{Span{0, 1}, "unknown variable q", CodeCheckVariable},
+			{Span{8, 14}, "unknown function bad", CodeCheckFunction},
+			{Span{12, 13}, "unknown variable q", CodeCheckVariable},
+		}},
+		{"sum(i, 1, 10, i * x)", CheckOptions{Variables: vars}, []Problem{}},
+	}
+	for _, tt := range tests {
+		expr, err := ParseString(tt.input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", tt.input, err)
+		}
+		if got := Check(expr, tt.opts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Check(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+// end of file
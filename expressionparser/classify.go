@@ -0,0 +1,131 @@
+package expressionparser
+
+import (
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SpanKind classifies a span of input reported by Classify.
+type SpanKind int
+
+const (
+	KindNumberLit    SpanKind = iota // a number, with any exponent or imaginary suffix
+	KindOperator                     // an operator or separator: + && , ; ? : and the like
+	KindParen                        // a parenthesis or bracket
+	KindIdentifier                   // a variable, unit or keyword
+	KindFunctionName                 // a name followed by '(', which calls it
+	KindStringLit                    // a string literal with its quotes
+	KindComment                      // a comment; the grammar has none yet
+	KindError                        // input no token begins with
+)
+
+// Names of the span kinds
+var spanKindNames = map[SpanKind]string{
+	KindNumberLit:    "number",
+	KindOperator:     "operator",
+	KindParen:        "paren",
+	KindIdentifier:   "identifier",
+	KindFunctionName: "function",
+	KindStringLit:    "string",
+	KindComment:      "comment",
+	KindError:        "error",
+}
+
+// String returns a readable name for the kind.
+func (k SpanKind) String() string {
+	if name, ok := spanKindNames[k]; ok {
+		return name
+	}
+	return "SpanKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// ClassifiedSpan is a token of the input, or a run of input that is not
+// one, with its kind.
+type ClassifiedSpan struct {
+	Span
+	Kind SpanKind
+}
+
+// Classify splits input into spans for syntax highlighting, in order: the
+// spans cover every byte of input but whitespace, and never end inside a
+// UTF-8 sequence. It lexes without parsing, looking one token ahead to
+// tell a function name from a variable, so it never fails; input the
+// lexer rejects, such as an unterminated string or a character no token
+// begins with, is a KindError span.
+func Classify(input string) []ClassifiedSpan {
+	spans := []ClassifiedSpan{}
+	l := NewLexer(input)
+	prev, prevType := 0, EOF
+	for tok := l.NextToken(); tok.Type != EOF; tok = l.NextToken() {
+		end := l.offset()
+		if end > len(input) {
+			end = len(input)
+		}
+		spans = appendGap(spans, input, prev, tok.Pos)
+		if last := len(spans) - 1; tok.Type == LPAREN && prevType == IDENT && spans[last].Kind == KindIdentifier {
+			spans[last].Kind = KindFunctionName
+		}
+		spans = appendSpan(spans, input, ClassifiedSpan{Span: Span{Start: tok.Pos, End: end}, Kind: tokenKind(tok.Type)})
+		prev, prevType = end, tok.Type
+	}
+	// The lexer stops at a NUL byte as at the end
+	return appendGap(spans, input, prev, len(input))
+}
+
+// tokenKind returns the kind of span a token of the type is.
+func tokenKind(t TokenType) SpanKind {
+	switch t {
+	case NUMBER:
+		return KindNumberLit
+	case IDENT:
+		return KindIdentifier
+	case STRING:
+		return KindStringLit
+	case LPAREN, RPAREN, LBRACKET, RBRACKET:
+		return KindParen
+	case INVALID:
+		return KindError
+	}
+	return KindOperator
+}
+
+// appendGap appends the input between two tokens that the lexer skipped as
+// whitespace but that is not, as KindError spans.
+func appendGap(spans []ClassifiedSpan, input string, start, end int) []ClassifiedSpan {
+	for i := start; i < end; {
+		r, size := utf8.DecodeRuneInString(input[i:end])
+		if r != utf8.RuneError && unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+		j := i + size
+		for j < end {
+			r, size := utf8.DecodeRuneInString(input[j:end])
+			if r != utf8.RuneError && unicode.IsSpace(r) {
+				break
+			}
+			j += size
+		}
+		spans = appendSpan(spans, input, ClassifiedSpan{Span: Span{Start: i, End: j}, Kind: KindError})
+		i = j
+	}
+	return spans
+}
+
+// appendSpan appends span, merging it into the last span as a KindError
+// span when both are errors, or when the boundary between them falls
+// inside a UTF-8 sequence, as it does where the lexer reads one byte of a
+// non-ASCII character as a letter and the next as invalid.
+func appendSpan(spans []ClassifiedSpan, input string, span ClassifiedSpan) []ClassifiedSpan {
+	if n := len(spans); n > 0 && spans[n-1].End == span.Start {
+		last := &spans[n-1]
+		if (last.Kind == KindError && span.Kind == KindError) || !utf8.RuneStart(input[span.Start]) {
+			last.End, last.Kind = span.End, KindError
+			return spans
+		}
+	}
+	return append(spans, span)
+}
+
+// end of file
@@ -0,0 +1,88 @@
+package expressionparser
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// classified renders the spans of input as kind:text, space separated.
+func classified(input string) string {
+	var parts []string
+	for _, s := range Classify(input) {
+		parts = append(parts, s.Kind.String()+":"+input[s.Start:s.End])
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"sqrt(x) + 2.5e3", "function:sqrt paren:( identifier:x paren:) operator:+ number:2.5e3"},
+		{`max (a, "s") >= [1..3]`, `function:max paren:( identifier:a operator:, string:"s" paren:) operator:>= paren:[ number:1 operator:.. number:3 paren:]`},
+		{"  sin  ", "identifier:sin"},
+		{"f(1 m", "function:f paren:( number:1 identifier:m"},
+		// Broken input
+		{"1 $ © 2", "number:1 error:$ error:© number:2"},
+		{`"abc`, `error:"abc`},
+		{"a \xff b", "identifier:a error:\xff identifier:b"},
+		{"a\x00b", "identifier:a error:\x00b"},
+	}
+	for _, tt := range tests {
+		if got := classified(tt.input); got != tt.want {
+			t.Errorf("Classify(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestClassifyTiles checks that the spans of valid, broken and random
+// input are in order and cover every character that is not whitespace,
+// each starting and ending at a character.
+func TestClassifyTiles(t *testing.T) {
+	inputs := append([]string{
+		"1 $ © 2", `"abc`, "a \xff b", "a\x00b", "é + 1", "((", "1..", `"a\"b" + 'c`,
+	}, evalCorpus...)
+	r := rand.New(rand.NewSource(1))
+	const alphabet = "0123456789.eE+-*/^%()[],;?:!<>=&|~\"'\\ \t\nxyzsin©é\xff\x00"
+	for i := 0; i < 500; i++ {
+		b := make([]byte, r.Intn(20))
+		for j := range b {
+			b[j] = alphabet[r.Intn(len(alphabet))]
+		}
+		inputs = append(inputs, string(b))
+	}
+	for _, input := range inputs {
+		// Offsets of characters, counting each invalid byte as one
+		starts := map[int]bool{len(input): true}
+		for i := range input {
+			starts[i] = true
+		}
+		covered := make([]bool, len(input))
+		prev := 0
+		for _, s := range Classify(input) {
+			if s.Start < prev || s.End <= s.Start || s.End > len(input) {
+				t.Errorf("Classify(%q): span %d-%d after %d", input, s.Start, s.End, prev)
+				break
+			}
+			if !starts[s.Start] || !starts[s.End] {
+				t.Errorf("Classify(%q): span %d-%d splits a character", input, s.Start, s.End)
+			}
+			for i := s.Start; i < s.End; i++ {
+				covered[i] = true
+			}
+			prev = s.End
+		}
+		for i, r := range input {
+			if !covered[i] && !unicode.IsSpace(r) {
+				t.Errorf("Classify(%q): %q at offset %d is in no span", input, r, i)
+				break
+			}
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,38 @@
+package expressionparser
+
+// Clone returns a deep copy of expr. Every node is copied, including
+// leaves, and argument slices are reallocated, so the copy can be mutated
+// without affecting the original.
+func Clone(expr Expr) Expr {
+	switch v := expr.(type) {
+	case nil:
+		return nil
+	case *Number:
+		c := *v
+		return &c
+	case *Variable:
+		c := *v
+		return &c
+	case *QuantityLiteral:
+		c := *v
+		return &c
+	case *StringLiteral:
+		c := *v
+		return &c
+	case *CellRef:
+		c := *v
+		return &c
+	case *CellRange:
+		c := *v
+		return &c
+	}
+
+	children := Children(expr)
+	cloned := make([]Expr, len(children))
+	for i, child := range children {
+		cloned[i] = Clone(child)
+	}
+	return withChildren(expr, cloned)
+}
+
+// end of file
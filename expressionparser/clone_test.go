@@ -0,0 +1,62 @@
+package expressionparser
+
+import "testing"
+
+func TestCloneIsDeep(t *testing.T) {
+	original, err := ParseString("let a = [1, x] in a > 0 ? max(x, 2) : -\"s\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := Clone(original)
+	if !Equal(original, clone) {
+		t.Fatalf("Clone = %s, want %s", ToSExpr(clone), ToSExpr(original))
+	}
+	want := ToSExpr(original)
+
+	// Mutate every leaf of the clone, and the argument slice of its call
+	Walk(clone, func(e Expr) bool {
+		switch v := e.(type) {
+		case *Number:
+			v.Value++
+		case *Variable:
+			v.Name += "_"
+		case *StringLiteral:
+			v.Value = "t"
+		case *FunctionCall:
+			v.Args[1] = Num(9)
+		}
+		return true
+	})
+	if got := ToSExpr(original); got != want {
+		t.Errorf("mutating the clone changed the original to %s, was %s", got, want)
+	}
+	if Equal(original, clone) {
+		t.Error("the mutated clone still equals the original")
+	}
+}
+
+func TestCloneEveryNodeType(t *testing.T) {
+	expr, err := ParseString(`let a = [1, "s", 2 km] in x ? -a : f(A1 + 1, B1:B2)`, WithCellReferences())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := List(expr, &Assign{Name: "y", Value: Num(1)})
+	clone := Clone(tree)
+	if !Equal(tree, clone) {
+		t.Fatalf("Clone = %s, want %s", ToSExpr(clone), ToSExpr(tree))
+	}
+	// No node of the clone is shared with the original
+	nodes := map[Expr]bool{}
+	Walk(tree, func(e Expr) bool {
+		nodes[e] = true
+		return true
+	})
+	Walk(clone, func(e Expr) bool {
+		if nodes[e] {
+			t.Errorf("Clone shares the node %s", ToSExpr(e))
+		}
+		return true
+	})
+}
+
+// end of file
@@ -0,0 +1,88 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxFactorial is the largest n whose factorial a float64 holds.
+const maxFactorial = 170
+
+// fact returns n! by multiplying 2 through n in turn. Up to 22! every
+// product is exact; beyond it each multiplication rounds, so that 170!, the
+// largest factorial within range, is within a few units in the last place.
+func fact(args []float64) (float64, error) {
+	n := args[0]
+	if n > maxFactorial {
+		return 0, fmt.Errorf("fact: %w computing %s!", ErrOverflow, formatNumber(n))
+	}
+	result := 1.0
+	for i := 2.0; i <= n; i++ {
+		result *= i
+	}
+	return result, nil
+}
+
+// gamma returns the gamma function of x, which extends the factorial:
+// gamma(n + 1) is n!.
+func gamma(args []float64) (float64, error) {
+	return math.Gamma(args[0]), nil
+}
+
+// nCr returns the number of ways to choose k of n items, multiplying and
+// dividing in turn so that every intermediate result is itself a binomial
+// coefficient and none overflows before the result would. It is 0 when k
+// exceeds n.
+func nCr(args []float64) (float64, error) {
+	n, k := args[0], args[1]
+	if k > n {
+		return 0, nil
+	}
+	k = math.Min(k, n-k)
+	result := 1.0
+	for i := 1.0; i <= k; i++ {
+		// result is C(n-k+i-1, i-1), so the division is exact
+		result = result * (n - k + i) / i
+		if math.IsInf(result, 0) {
+			return 0, fmt.Errorf("nCr: %w computing nCr(%s, %s)", ErrOverflow, formatNumber(n), formatNumber(args[1]))
+		}
+	}
+	return result, nil
+}
+
+// nPr returns the number of ordered arrangements of k of n items, the
+// product of n down to n-k+1. It is 0 when k exceeds n.
+func nPr(args []float64) (float64, error) {
+	n, k := args[0], args[1]
+	if k > n {
+		return 0, nil
+	}
+	result := 1.0
+	for i := 0.0; i < k; i++ {
+		result *= n - i
+		if math.IsInf(result, 0) {
+			return 0, fmt.Errorf("nPr: %w computing nPr(%s, %s)", ErrOverflow, formatNumber(n), formatNumber(k))
+		}
+	}
+	return result, nil
+}
+
+// notNatural rejects the first argument that is not a finite non-negative integer.
+func notNatural(args []float64) int {
+	for i, x := range args {
+		if x < 0 || x != math.Trunc(x) || math.IsInf(x, 0) {
+			return i
+		}
+	}
+	return -1
+}
+
+// gammaPole rejects zero and the negative integers, where gamma has poles.
+func gammaPole(args []float64) int {
+	if x := args[0]; x <= 0 && x == math.Trunc(x) {
+		return 0
+	}
+	return -1
+}
+
+// end of file
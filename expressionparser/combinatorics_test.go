@@ -0,0 +1,105 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestFactExact(t *testing.T) {
+	for n := int64(0); n <= 22; n++ {
+		got, err := Evaluate(fmt.Sprintf("fact(%d)", n))
+		if err != nil {
+			t.Fatalf("fact(%d): %v", n, err)
+		}
+		want := new(big.Int).MulRange(1, n)
+		if n == 0 {
+			want.SetInt64(1)
+		}
+		if exact, acc := new(big.Float).SetFloat64(got).Int(nil); acc != big.Exact || exact.Cmp(want) != 0 {
+			t.Errorf("fact(%d) = %v, want exactly %v", n, got, want)
+		}
+	}
+	// Beyond 22! within a few units in the last place
+	for _, n := range []int64{23, 50, 100, 170} {
+		got, _ := Evaluate(fmt.Sprintf("fact(%d)", n))
+		want, _ := new(big.Float).SetInt(new(big.Int).MulRange(1, n)).Float64()
+		if math.Abs(got-want) > 8*ulp(want) {
+			t.Errorf("fact(%d) = %v, want %v", n, got, want)
+		}
+	}
+}
+
+// ulp returns the gap between x and the next float64 above it.
+func ulp(x float64) float64 {
+	return math.Nextafter(x, math.Inf(1)) - x
+}
+
+func TestGammaAndCombinations(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"gamma(5)", 24},
+		{"gamma(0.5) ^ 2", math.Pi},
+		{"gamma(-0.5)", -2 * math.Sqrt(math.Pi)},
+		{"gamma(11) == fact(10)", 1},
+		{"nCr(5, 2)", 10},
+		{"nCr(5, 0)", 1},
+		{"nCr(3, 5)", 0},
+		{"nPr(5, 2)", 20},
+		{"nPr(3, 5)", 0},
+		{"nPr(6, 6) == fact(6)", 1},
+	}
+	for _, tt := range tests {
+		got, err := Evaluate(tt.input)
+		if err != nil || math.Abs(got-tt.want) > 4*ulp(math.Abs(tt.want)) {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	// Large results whose factorials would overflow
+	for _, c := range [][2]int64{{60, 30}, {1000, 500}, {1000, 3}} {
+		got, err := Evaluate(fmt.Sprintf("nCr(%d, %d)", c[0], c[1]))
+		want, _ := new(big.Float).SetInt(new(big.Int).Binomial(c[0], c[1])).Float64()
+		if err != nil || math.Abs(got-want) > 1e-13*want {
+			t.Errorf("nCr(%d, %d) = %v, %v; want %v", c[0], c[1], got, err, want)
+		}
+	}
+	// Symmetric and Pascal's rule
+	for n := 1; n <= 40; n++ {
+		for k := 1; k < n; k++ {
+			input := fmt.Sprintf("nCr(%d, %d) == nCr(%d, %d) && nCr(%d, %d) == nCr(%d, %d) + nCr(%d, %d)", n, k, n, n-k, n, k, n-1, k-1, n-1, k)
+			if got, err := Evaluate(input); err != nil || got != 1 {
+				t.Fatalf("%s = %v, %v", input, got, err)
+			}
+		}
+	}
+}
+
+func TestCombinatoricsErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{"fact(-1)", CodeOutsideDomain, "fact: argument -1 is outside the domain"},
+		{"fact(2.5)", CodeOutsideDomain, "fact: argument 2.5 is outside the domain"},
+		{"fact(171)", CodeOverflow, "fact: overflow computing 171!"},
+		{"gamma(0)", CodeOutsideDomain, "gamma: argument 0 is outside the domain"},
+		{"gamma(-1)", CodeOutsideDomain, "gamma: argument -1 is outside the domain"},
+		{"nCr(5, -1)", CodeOutsideDomain, "nCr: argument 2, -1, is outside the domain"},
+		{"nCr(5.5, 2)", CodeOutsideDomain, "nCr: argument 1, 5.5, is outside the domain"},
+		{"nCr(1030, 515)", CodeOverflow, "nCr: overflow computing nCr(1030, 515)"},
+		{"nPr(171, 171)", CodeOverflow, "nPr: overflow computing nPr(171, 171)"},
+	}
+	for _, tt := range tests {
+		_, err := Evaluate(tt.input)
+		if ErrorCode(err) != tt.code || err.Error() != tt.msg+` in "`+tt.input+`" at offset 0` {
+			t.Errorf("%s: error %v, want %s %q", tt.input, err, tt.code, tt.msg)
+		}
+	}
+}
+
+// end of file
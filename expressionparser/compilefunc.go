@@ -0,0 +1,390 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// closure is the compiled form of a subtree, evaluating it in a frame.
+type closure func(f *frame) (Value, error)
+
+// frame holds the variables of one call of a function made by CompileFunc.
+type frame struct {
+	vars     map[string]float64
+	set      map[string]float64 // set on the evaluator with SetVar, for names vars lacks
+	lets     []Value            // values bound by let, by nesting depth
+	observer Observer           // of the Expression evaluated, told of the variables read
+}
+
+// CompileFunc compiles an expression with the default Evaluator.
+func CompileFunc(expr Expr) (func(vars map[string]float64) (float64, error), error) {
+	return defaultEvaluator.CompileFunc(expr)
+}
+
+// CompileFunc turns expr into a function that evaluates it with the given
+// variables, giving the same result or error as EvalWithVars, under the
+// configuration of ev, would. A variable missing from the map takes the
+// value set on ev with SetVar or SetVars, as with ev.Eval; those are copied
+// here, so values set later are not seen. The tree is walked once, here:
+// every node becomes a closure with its operator, literal values, let
+// bindings and function already resolved, so calling the function does no
+// type switching on the tree and suits evaluating one expression many
+// times. Functions registered later with RegisterFunc are not seen, and
+// WithMaxOps and WithMemoization do not apply. The function may be called
+// from several goroutines at once.
+//
+// The only error returned by CompileFunc itself is a tree exceeding the
+// limits set WithLimits; errors such as an unknown function are returned
+// when the part of the tree that has them is evaluated, as with Eval.
+func (ev *Evaluator) CompileFunc(expr Expr) (func(vars map[string]float64) (float64, error), error) {
+	run, err := ev.compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return func(vars map[string]float64) (result float64, err error) {
+		defer recoverPanic(&err)
+		return run(vars, nil)
+	}, nil
+}
+
+// compile compiles expr as CompileFunc does, into a function telling the
+// observer, when there is one, of the variables it reads.
+func (ev *Evaluator) compile(expr Expr) (func(vars map[string]float64, observer Observer) (float64, error), error) {
+	if err := ev.admit(expr); err != nil {
+		return nil, err
+	}
+
+	var set map[string]float64
+	if len(ev.vars) > 0 {
+		set = make(map[string]float64, len(ev.vars))
+		for name, value := range ev.vars {
+			set[name] = value
+		}
+	}
+	depth := 0
+	root := ev.compileNode(expr, nil, &depth)
+	return func(vars map[string]float64, observer Observer) (float64, error) {
+		f := &frame{vars: vars, set: set, observer: observer}
+		if depth > 0 {
+			f.lets = make([]Value, depth)
+		}
+		value, err := root(f)
+		if err != nil {
+			return 0, err
+		}
+		value = ev.roundResult(value)
+		if !value.numeric() {
+			return 0, withCode(CodeType, fmt.Errorf("result is a %s, not a number", value.kind))
+		}
+		return value.num, nil
+	}, nil
+}
+
+// fail returns a closure giving err.
+func fail(err error) closure {
+	return func(*frame) (Value, error) {
+		return Value{}, err
+	}
+}
+
+// compileNode compiles expr, in which lets names the let bindings in scope
+// by depth, raising *depth to the number of let slots needed. Every closure
+// attributes its errors to its subexpression, and under RejectNonFinite also
+// checks its value, as eval does.
+func (ev *Evaluator) compileNode(expr Expr, lets []string, depth *int) closure {
+	c := ev.compileValue(expr, lets, depth)
+	reject := ev.cfg.nonFinite == RejectNonFinite
+	return func(f *frame) (Value, error) {
+		value, err := c(f)
+		if err != nil {
+			return Value{}, ev.locate(expr, err)
+		}
+		if reject {
+			return ev.finite(expr, value)
+		}
+		return value, nil
+	}
+}
+
+// compileValue compiles the node at the root of expr.
+func (ev *Evaluator) compileValue(expr Expr, lets []string, depth *int) closure {
+	switch v := expr.(type) {
+	case *Number:
+		if v.Imag {
+			return fail(imaginaryError(v, "real"))
+		}
+		value := NumberValue(v.Value)
+		return func(*frame) (Value, error) {
+			return value, nil
+		}
+	case *QuantityLiteral:
+		if value, ok, err := durationOf(v); ok {
+			return func(*frame) (Value, error) {
+				return value, err
+			}
+		}
+		return fail(quantityError(v, "real"))
+	case *StringLiteral:
+		value := StringValue(v.Value)
+		return func(*frame) (Value, error) {
+			return value, nil
+		}
+	case *Variable:
+		for slot := len(lets) - 1; slot >= 0; slot-- {
+			if lets[slot] == v.Name {
+				return func(f *frame) (Value, error) {
+					return f.lets[slot], nil
+				}
+			}
+		}
+		return func(f *frame) (Value, error) {
+			if f.observer != nil {
+				observeVarRead(f.observer, v.Name)
+			}
+			if value, ok := f.vars[v.Name]; ok {
+				return NumberValue(value), nil
+			}
+			if value, ok := f.set[v.Name]; ok {
+				return NumberValue(value), nil
+			}
+			return ev.undefinedVariable(v)
+		}
+	case *CellRef:
+		return func(*frame) (Value, error) {
+			return ev.cellValue(v)
+		}
+	case *CellRange:
+		return func(*frame) (Value, error) {
+			return ev.cellRange(v)
+		}
+	case *BinaryOp:
+		return ev.compileBinary(v, lets, depth)
+	case *UnaryOp:
+		return ev.compileUnary(v, lets, depth)
+	case *FunctionCall:
+		if cond, err := ev.excelIf(v); cond != nil || err != nil {
+			if err != nil {
+				return fail(err)
+			}
+			return ev.compileValue(cond, lets, depth)
+		}
+		if ev.isSpecialForm(v) {
+			return ev.compileSpecialForm(v, lets, depth)
+		}
+		if ev.isIterationForm(v) {
+			return ev.compileIteration(v, lets, depth)
+		}
+		if ev.isLazyForm(v) {
+			return ev.compileLazyForm(v, lets, depth)
+		}
+		target, err := ev.resolveCall(v)
+		if err != nil {
+			return fail(err)
+		}
+		args := make([]closure, len(v.Args))
+		for i, arg := range v.Args {
+			args[i] = ev.compileNode(arg, lets, depth)
+		}
+		return func(f *frame) (Value, error) {
+			values := make([]Value, len(args))
+			for i, arg := range args {
+				value, err := arg(f)
+				if err != nil {
+					return Value{}, err
+				}
+				values[i] = value
+			}
+			return ev.applyCall(v, target, values)
+		}
+	case *ListLiteral:
+		elems := make([]closure, len(v.Elems))
+		for i, elem := range v.Elems {
+			elems[i] = ev.compileNode(elem, lets, depth)
+		}
+		return func(f *frame) (Value, error) {
+			values := make([]Value, len(elems))
+			for i, elem := range elems {
+				value, err := elem(f)
+				if err != nil {
+					return Value{}, err
+				}
+				values[i] = value
+			}
+			return listOf(v, values)
+		}
+	case *Let:
+		value := ev.compileNode(v.Value, lets, depth)
+		slot := len(lets)
+		if slot+1 > *depth {
+			*depth = slot + 1
+		}
+		inner := append(lets[:slot:slot], v.Name)
+		body := ev.compileNode(v.Body, inner, depth)
+		return func(f *frame) (Value, error) {
+			bound, err := value(f)
+			if err != nil {
+				return Value{}, err
+			}
+			f.lets[slot] = bound
+			return body(f)
+		}
+	case *Conditional:
+		cond := ev.compileNode(v.Cond, lets, depth)
+		then := ev.compileNode(v.Then, lets, depth)
+		els := ev.compileNode(v.Else, lets, depth)
+		pos := -1
+		if v.Span != (Span{}) {
+			pos = SpanOf(v.Cond).Start
+		}
+		return func(f *frame) (Value, error) {
+			c, err := cond(f)
+			if err != nil {
+				return Value{}, err
+			}
+			b, err := c.condition("?:", pos)
+			if err != nil {
+				return Value{}, err
+			}
+			if b {
+				return then(f)
+			}
+			return els(f)
+		}
+	}
+	if v, ok := expr.(*Assign); ok {
+		return fail(assignmentError(v))
+	}
+	return fail(withCode(CodeUnsupported, errors.New("unsupported expression type")))
+}
+
+// compileUnary compiles a unary operator.
+func (ev *Evaluator) compileUnary(v *UnaryOp, lets []string, depth *int) closure {
+	operand := ev.compileNode(v.Operand, lets, depth)
+	pos := operatorPos(v.Op, v.Span)
+	switch v.Op.Type {
+	case MINUS:
+		return func(f *frame) (Value, error) {
+			value, err := operand(f)
+			if err != nil {
+				return Value{}, err
+			}
+			return negateValue(value, pos)
+		}
+	case NOT:
+		return func(f *frame) (Value, error) {
+			value, err := operand(f)
+			if err != nil {
+				return Value{}, err
+			}
+			b, err := value.condition("!", pos)
+			if err != nil {
+				return Value{}, err
+			}
+			return BoolValue(!b), nil
+		}
+	}
+	return func(f *frame) (Value, error) {
+		if _, err := operand(f); err != nil {
+			return Value{}, err
+		}
+		return Value{}, withCode(CodeUnsupported, errors.New("invalid expression"))
+	}
+}
+
+// compileBinary compiles a binary operator. Operands that are both numbers
+// or bools take a path chosen here for the operator; others fall back to
+// binaryValue, which applies the rules for strings and reports type errors.
+func (ev *Evaluator) compileBinary(v *BinaryOp, lets []string, depth *int) closure {
+	left := ev.compileNode(v.Left, lets, depth)
+	right := ev.compileNode(v.Right, lets, depth)
+	op, pos := v.Op, operatorPos(v.Op, v.Span)
+
+	if op.Type == AND || op.Type == OR {
+		symbol := operatorSymbol(op)
+		return func(f *frame) (Value, error) {
+			l, err := left(f)
+			if err != nil {
+				return Value{}, err
+			}
+			b, err := l.condition(symbol, pos)
+			if err != nil {
+				return Value{}, err
+			}
+			if b == (op.Type == OR) {
+				return BoolValue(b), nil
+			}
+			r, err := right(f)
+			if err != nil {
+				return Value{}, err
+			}
+			if !r.numeric() {
+				return Value{}, &TypeError{Op: symbol, Operands: []Kind{l.kind, r.kind}, Pos: pos}
+			}
+			return BoolValue(isTrue(r.num)), nil
+		}
+	}
+
+	numeric := ev.numericOperator(op)
+	division := isDivision(op)
+	return func(f *frame) (Value, error) {
+		l, err := left(f)
+		if err != nil {
+			return Value{}, err
+		}
+		r, err := right(f)
+		if err != nil {
+			return Value{}, err
+		}
+		if l.kind == ListKind || r.kind == ListKind {
+			return ev.elementwise(op, pos, l, r, v.Right)
+		}
+		if !l.numeric() || !r.numeric() || numeric == nil {
+			return ev.binaryValue(op, pos, l, r)
+		}
+		if division && r.num == 0 {
+			value, ieee, err := ev.zeroDivision(op, v.Right)
+			if err != nil {
+				return Value{}, err
+			}
+			if !ieee {
+				return NumberValue(value), nil
+			}
+		}
+		return numeric(l.num, r.num), nil
+	}
+}
+
+// numericOperator returns the function binaryValue applies for op to two
+// numbers, or nil when there is none.
+func (ev *Evaluator) numericOperator(op Token) func(x, y float64) Value {
+	switch op.Type {
+	case PLUS:
+		return func(x, y float64) Value { return NumberValue(x + y) }
+	case MINUS:
+		return func(x, y float64) Value { return NumberValue(x - y) }
+	case MULT:
+		return func(x, y float64) Value { return NumberValue(x * y) }
+	case DIV:
+		return func(x, y float64) Value { return NumberValue(x / y) }
+	case MOD:
+		return func(x, y float64) Value { return NumberValue(ev.mod(x, y)) }
+	case POW:
+		return func(x, y float64) Value { return NumberValue(math.Pow(x, y)) }
+	case LT:
+		return func(x, y float64) Value { return BoolValue(x < y && !ev.equalNumbers(x, y)) }
+	case LE:
+		return func(x, y float64) Value { return BoolValue(x <= y || ev.equalNumbers(x, y)) }
+	case GT:
+		return func(x, y float64) Value { return BoolValue(x > y && !ev.equalNumbers(x, y)) }
+	case GE:
+		return func(x, y float64) Value { return BoolValue(x >= y || ev.equalNumbers(x, y)) }
+	case EQ:
+		return func(x, y float64) Value { return BoolValue(ev.equalNumbers(x, y)) }
+	case NE:
+		return func(x, y float64) Value { return BoolValue(!ev.equalNumbers(x, y)) }
+	}
+	return nil
+}
+
+// end of file
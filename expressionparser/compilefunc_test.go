@@ -0,0 +1,80 @@
+package expressionparser
+
+import (
+	"testing"
+)
+
+func TestCompileFuncMatchesEval(t *testing.T) {
+	for _, input := range evalCorpus {
+		expr, err := ParseString(input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		fn, err := CompileFunc(expr)
+		if err != nil {
+			t.Fatalf("CompileFunc(%q): %v", input, err)
+		}
+		want, wantErr := EvalWithVars(expr, corpusVars)
+		got, gotErr := fn(corpusVars)
+		if !sameResult(got, gotErr, want, wantErr) {
+			t.Errorf("%q: CompileFunc gives %v, %v; Eval %v, %v", input, got, gotErr, want, wantErr)
+		}
+	}
+}
+
+func TestCompileFuncSetVar(t *testing.T) {
+	ev := NewEvaluator()
+	ev.SetVar("k", 2)
+	expr, err := ParseString("k * x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn, err := ev.CompileFunc(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev.SetVar("k", 100) // after compiling: not seen
+
+	vars := map[string]float64{"x": 3}
+	if got, err := fn(vars); err != nil || got != 6 {
+		t.Errorf("k * x with k set to 2 = %v, %v; want 6", got, err)
+	}
+	vars["k"] = 5
+	if got, err := fn(vars); err != nil || got != 15 {
+		t.Errorf("k * x with k passed as 5 = %v, %v; want 15", got, err)
+	}
+	if _, err := fn(nil); ErrorCode(err) != CodeUndefinedVar {
+		t.Errorf("k * x without x: error %v, want %s", err, CodeUndefinedVar)
+	}
+}
+
+func BenchmarkEval(b *testing.B) {
+	expr, err := ParseString("x * x + 3 * x * y - sqrt(n) / (1 + max(x, y))")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := EvalWithVars(expr, corpusVars); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompileFunc(b *testing.B) {
+	expr, err := ParseString("x * x + 3 * x * y - sqrt(n) / (1 + max(x, y))")
+	if err != nil {
+		b.Fatal(err)
+	}
+	fn, err := CompileFunc(expr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := fn(corpusVars); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// end of file
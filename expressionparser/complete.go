@@ -0,0 +1,224 @@
+package expressionparser
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CompletionContext supplies Complete the names it may suggest.
+type CompletionContext struct {
+	// Variables are the names of the variables the expression may use.
+	Variables []string
+
+	// Functions, when non-nil, replaces the builtins as the names of the
+	// functions the expression may call.
+	Functions []string
+}
+
+// Suggestion is a completion offered by Complete: replacing the input in
+// Replace, which is empty at the cursor when nothing is replaced, with Text.
+type Suggestion struct {
+	Text    string
+	Replace Span
+	Kind    SpanKind // KindIdentifier, KindFunctionName, KindParen or KindOperator
+}
+
+// operatorSuggestions are the operators offered after an operand, most
+// used first.
+var operatorSuggestions = []string{"+", "-", "*", "/", "^", "%", "==", "!=", "<", "<=", ">", ">=", "&&", "||", "=~"}
+
+// Complete returns the completions for the cursor, a byte offset into
+// input, best first. Only the input before the cursor is looked at, and
+// lexed without being parsed, so that it may be incomplete or wrong:
+//
+//   - in a name, the variables and functions whose names begin with what
+//     precedes the cursor replace the whole name, functions with an open
+//     parenthesis unless one follows, and when the name is a variable what
+//     may follow an operand is offered after them;
+//   - where an operand may start, every variable and function is offered;
+//   - after an operand, the parenthesis or bracket that closes the
+//     innermost one open, a comma when that one holds an argument list, and
+//     then the operators.
+//
+// Inside a string literal, after input the lexer rejects, or at a cursor
+// outside input or inside a UTF-8 sequence there are no suggestions. The
+// result is empty, never nil, when there are none.
+func Complete(input string, cursor int, env CompletionContext) []Suggestion {
+	suggestions := []Suggestion{}
+	if cursor < 0 || cursor > len(input) || cursor < len(input) && !utf8.RuneStart(input[cursor]) {
+		return suggestions
+	}
+
+	// Lex the prefix, tracking the parentheses and brackets left open
+	var last Token
+	var open []Token // the opening tokens, with lists and calls told apart by Value
+	l := NewLexer(input[:cursor])
+	for tok := l.NextToken(); tok.Type != EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case LPAREN:
+			if last.Type == IDENT && !isKeyword(last.Value) {
+				tok.Value = "call"
+			}
+			open = append(open, tok)
+		case LBRACKET:
+			open = append(open, tok)
+		case RPAREN, RBRACKET:
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		case INVALID:
+			// An unterminated string holds the cursor, or the prefix is broken
+			return suggestions
+		}
+		last = tok
+	}
+
+	switch {
+	case last.Type == IDENT && last.Pos+len(last.Value) == cursor && !isKeyword(last.Value):
+		// Completing a name: replace all of it, through the cursor too
+		end := cursor
+		for end < len(input) {
+			ch, width := utf8.DecodeRuneInString(input[end:])
+			if !isIdentStart(ch) && !unicode.IsDigit(ch) {
+				break
+			}
+			end += width
+		}
+		replace := Span{Start: last.Pos, End: end}
+		call := strings.HasPrefix(strings.TrimLeft(input[end:], " \t"), "(")
+		suggestions = appendNames(suggestions, env, last.Value, replace, call)
+		if input[last.Pos:end] == last.Value && contains(env.Variables, last.Value) {
+			// The name is a variable already: it may end the operand
+			suggestions = appendOperandEnd(suggestions, open, Span{Start: end, End: end})
+		}
+		return suggestions
+	case isOperandEnd(last.Type) && !(last.Type == IDENT && isKeyword(last.Value)):
+		return appendOperandEnd(suggestions, open, Span{Start: cursor, End: cursor})
+	}
+	return appendNames(suggestions, env, "", Span{Start: cursor, End: cursor}, false)
+}
+
+// appendOperandEnd appends what may follow an operand, inserted at here:
+// the parenthesis or bracket closing the innermost one open, with a comma
+// when that one holds an argument list or a list, and the operators.
+func appendOperandEnd(suggestions []Suggestion, open []Token, here Span) []Suggestion {
+	if len(open) > 0 {
+		inner := open[len(open)-1]
+		closing := ")"
+		if inner.Type == LBRACKET {
+			closing = "]"
+		}
+		suggestions = append(suggestions, Suggestion{Text: closing, Replace: here, Kind: KindParen})
+		if inner.Type == LBRACKET || inner.Value == "call" {
+			suggestions = append(suggestions, Suggestion{Text: ",", Replace: here, Kind: KindOperator})
+		}
+	}
+	for _, op := range operatorSuggestions {
+		suggestions = append(suggestions, Suggestion{Text: op, Replace: here, Kind: KindOperator})
+	}
+	return suggestions
+}
+
+// contains reports whether names holds name.
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isOperandEnd reports whether a token of the type can end an operand, so
+// that an operator may follow it.
+func isOperandEnd(t TokenType) bool {
+	switch t {
+	case NUMBER, IDENT, STRING, RPAREN, RBRACKET:
+		return true
+	}
+	return false
+}
+
+// appendNames appends the variables and functions whose names begin with
+// prefix: those matching its case first, then variables before
+// functions, then shorter names before longer and alphabetically. Functions get an open parenthesis unless call says one follows.
+func appendNames(suggestions []Suggestion, env CompletionContext, prefix string, replace Span, call bool) []Suggestion {
+	functions := env.Functions
+	if functions == nil {
+		functions = builtinNames()
+	}
+	type candidate struct {
+		name string
+		kind SpanKind
+		rank int // 0 for a match of case, 1 for one ignoring it
+	}
+	var candidates []candidate
+	add := func(names []string, kind SpanKind) {
+		for _, name := range names {
+			switch {
+			case strings.HasPrefix(name, prefix):
+				candidates = append(candidates, candidate{name, kind, 0})
+			case strings.HasPrefix(strings.ToLower(name), strings.ToLower(prefix)):
+				candidates = append(candidates, candidate{name, kind, 1})
+			}
+		}
+	}
+	add(env.Variables, KindIdentifier)
+	add(functions, KindFunctionName)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		if a.kind != b.kind {
+			return a.kind == KindIdentifier
+		}
+		if len(a.name) != len(b.name) {
+			return len(a.name) < len(b.name)
+		}
+		return a.name < b.name
+	})
+	for _, c := range candidates {
+		text := c.name
+		if c.kind == KindFunctionName && !call {
+			text += "("
+		}
+		suggestions = append(suggestions, Suggestion{Text: text, Replace: replace, Kind: c.kind})
+	}
+	return suggestions
+}
+
+// builtinNames returns the names of the builtin functions, sorted.
+func builtinNames() []string {
+	var names []string
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for name := range stringBuiltins {
+		names = append(names, name)
+	}
+	for name := range aggregates {
+		names = append(names, name)
+	}
+	for name := range specialForms {
+		names = append(names, name)
+	}
+	for name := range iterationForms {
+		names = append(names, name)
+	}
+	for name := range lazyForms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	unique := names[:0]
+	for _, name := range names {
+		if len(unique) == 0 || name != unique[len(unique)-1] {
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+// end of file
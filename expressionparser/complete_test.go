@@ -0,0 +1,96 @@
+package expressionparser
+
+import (
+	"testing"
+)
+
+// suggestionTexts returns the texts of suggestions.
+func suggestionTexts(suggestions []Suggestion) []string {
+	texts := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		texts[i] = s.Text
+	}
+	return texts
+}
+
+func TestCompleteName(t *testing.T) {
+	env := CompletionContext{Variables: []string{"price", "prize", "qty"}, Functions: []string{"pow", "print_total"}}
+	got := Complete("2 * pr", 6, env)
+	want := []string{"price", "prize", "print_total("}
+	if texts := suggestionTexts(got); !equalStrings(texts, want) {
+		t.Fatalf("Complete(%q) = %q, want %q", "2 * pr", texts, want)
+	}
+	if got[0].Replace != (Span{Start: 4, End: 6}) {
+		t.Errorf("replace range %v, want [4, 6)", got[0].Replace)
+	}
+
+	// Mid-identifier: the whole name is replaced
+	got = Complete("pri + 1", 2, env)
+	if len(got) == 0 || got[0].Text != "price" || got[0].Replace != (Span{Start: 0, End: 3}) {
+		t.Errorf("Complete mid-identifier = %v, want price replacing [0, 3)", got)
+	}
+
+	// A function called already gets no second parenthesis
+	got = Complete("po(2, 3)", 2, env)
+	if len(got) != 1 || got[0].Text != "pow" {
+		t.Errorf("Complete before a call = %v, want pow", got)
+	}
+}
+
+func TestCompleteOperand(t *testing.T) {
+	env := CompletionContext{Variables: []string{"a"}, Functions: []string{"max"}}
+	for _, input := range []string{"", "1 + ", "max("} {
+		got := suggestionTexts(Complete(input, len(input), env))
+		if want := []string{"a", "max("}; !equalStrings(got, want) {
+			t.Errorf("Complete(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestCompleteAfterOperand(t *testing.T) {
+	env := CompletionContext{Variables: []string{"a"}}
+	got := suggestionTexts(Complete("max(a, 2", 8, env))
+	if len(got) < 3 || got[0] != ")" || got[1] != "," || got[2] != "+" {
+		t.Errorf("Complete in a call = %q, want ), then a comma, then the operators", got)
+	}
+	got = suggestionTexts(Complete("(1 + 2", 6, env))
+	if len(got) < 2 || got[0] != ")" || got[1] != "+" {
+		t.Errorf("Complete in parentheses = %q, want ) then the operators", got)
+	}
+}
+
+func TestCompleteNoSuggestions(t *testing.T) {
+	env := CompletionContext{Variables: []string{"a"}, Functions: []string{"max"}}
+	tests := []struct {
+		input  string
+		cursor int
+	}{
+		{`len("ab`, 7}, // inside a string literal
+		{`1 + @`, 5},
+		{"a + 1", -1},
+		{"a + 1", 6},
+		{"a + 1", 100},
+		{"é", 1}, // inside a UTF-8 sequence
+	}
+	for _, tt := range tests {
+		got := Complete(tt.input, tt.cursor, env)
+		if got == nil || len(got) != 0 {
+			t.Errorf("Complete(%q, %d) = %v, want none", tt.input, tt.cursor, got)
+		}
+	}
+}
+
+// equalStrings reports whether a and b hold the same strings in order.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// end of file
@@ -0,0 +1,295 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// EvalComplex evaluates an expression with complex numbers with the default Evaluator.
+func EvalComplex(expr Expr) (complex128, error) {
+	return defaultEvaluator.EvalComplex(expr)
+}
+
+// FormatComplex renders a result of EvalComplex in the syntax of the
+// parser: "3+4i", "-2i", or a plain real number when the imaginary part is zero.
+func FormatComplex(c complex128) string {
+	re, im := real(c), imag(c)
+	switch {
+	case im == 0:
+		return formatNumber(re)
+	case re == 0:
+		return formatNumber(im) + "i"
+	case im < 0:
+		return formatNumber(re) + "-" + formatNumber(-im) + "i"
+	}
+	return formatNumber(re) + "+" + formatNumber(im) + "i"
+}
+
+// imaginaryError reports an imaginary literal met by an evaluation mode without complex numbers.
+func imaginaryError(n *Number, mode string) error {
+	pos := -1
+	if n.Span != (Span{}) {
+		pos = n.Span.Start
+	}
+	return atOffset(withCode(CodeUnsupported, fmt.Errorf("imaginary literal %s is not available in %s mode", literalText(n), mode)), pos)
+}
+
+// EvalComplex evaluates expr with complex128 arithmetic, so imaginary
+// literals such as 4i may be used and sqrt(-1) is i rather than an error.
+// Eval and the other modes reject imaginary literals, so purely real
+// expressions keep their float64 results there.
+//
+// Variable values are real. Division or modulo by zero follows the
+// WithDivisionByZero policy, as in Eval, and ^ is the principal value of
+// the complex power, computed by repeated multiplication for integer
+// exponents. Comparisons and logical operators yield 1 or 0; == and !=
+// compare complex values, while the orderings and % need real operands. A
+// value is true when it is not zero. The builtins re, im, conj, abs (the
+// modulus), arg (the phase), sqrt, exp, ln, log, log10, sin, cos, tan,
+// asin, acos, atan and pow are available; logarithms of zero are errors.
+func (ev *Evaluator) EvalComplex(expr Expr) (complex128, error) {
+	if err := ev.admit(expr); err != nil {
+		return 0, err
+	}
+	return ev.evalComplex(expr, nil)
+}
+
+// complexScope is a chain of let bindings in complex mode, innermost first.
+type complexScope struct {
+	name   string
+	value  complex128
+	parent *complexScope
+}
+
+// evalComplex evaluates expr in complex mode with the given let bindings in scope.
+func (ev *Evaluator) evalComplex(expr Expr, env *complexScope) (complex128, error) {
+	switch v := expr.(type) {
+	case *Number:
+		if v.Imag {
+			return complex(0, v.Value), nil
+		}
+		return complex(v.Value, 0), nil
+	case *Variable:
+		for s := env; s != nil; s = s.parent {
+			if s.name == v.Name {
+				return s.value, nil
+			}
+		}
+		value, ok := ev.vars[v.Name]
+		if !ok {
+			return 0, &UndefinedVariableError{Name: v.Name, Span: v.Span}
+		}
+		return complex(value, 0), nil
+	case *UnaryOp:
+		operand, err := ev.evalComplex(v.Operand, env)
+		if err != nil {
+			return 0, err
+		}
+		switch v.Op.Type {
+		case MINUS:
+			// Subtracting from zero keeps a zero imaginary part positive, so
+			// sqrt(-1) is i rather than the -i across the branch cut
+			return 0 - operand, nil
+		case NOT:
+			return complexTruth(operand == 0), nil
+		}
+	case *BinaryOp:
+		left, err := ev.evalComplex(v.Left, env)
+		if err != nil {
+			return 0, err
+		}
+		if (v.Op.Type == AND || v.Op.Type == OR) && (left != 0) == (v.Op.Type == OR) {
+			// Short-circuit: the right operand cannot change the result
+			return complexTruth(left != 0), nil
+		}
+		right, err := ev.evalComplex(v.Right, env)
+		if err != nil {
+			return 0, err
+		}
+		if isDivision(v.Op) && right == 0 {
+			value, ieee, err := ev.zeroDivision(v.Op, v.Right)
+			if err != nil {
+				return 0, err
+			}
+			if !ieee {
+				return complex(value, 0), nil
+			}
+		}
+		return ev.complexOperation(v.Op, left, right)
+	case *FunctionCall:
+		return ev.callComplexBuiltin(v, env)
+	case *Let:
+		value, err := ev.evalComplex(v.Value, env)
+		if err != nil {
+			return 0, err
+		}
+		return ev.evalComplex(v.Body, &complexScope{name: v.Name, value: value, parent: env})
+	case *Conditional:
+		cond, err := ev.evalComplex(v.Cond, env)
+		if err != nil {
+			return 0, err
+		}
+		if cond != 0 {
+			return ev.evalComplex(v.Then, env)
+		}
+		return ev.evalComplex(v.Else, env)
+	}
+	return 0, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate expression of type %T in complex mode", expr))
+}
+
+// complexOperation applies a binary operator to two complex numbers, with
+// IEEE semantics for a zero divisor.
+func (ev *Evaluator) complexOperation(op Token, a, b complex128) (complex128, error) {
+	switch op.Type {
+	case PLUS:
+		return a + b, nil
+	case MINUS:
+		return a - b, nil
+	case MULT:
+		return a * b, nil
+	case DIV:
+		return a / b, nil
+	case POW:
+		return complexPow(a, b), nil
+	case EQ:
+		return complexTruth(a == b), nil
+	case NE:
+		return complexTruth(a != b), nil
+	case AND:
+		return complexTruth(a != 0 && b != 0), nil
+	case OR:
+		return complexTruth(a != 0 || b != 0), nil
+	}
+
+	// The remaining operators are defined on real numbers only
+	if imag(a) != 0 || imag(b) != 0 {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("operator %s needs real operands, got %s and %s", operatorSymbol(op), FormatComplex(a), FormatComplex(b)))
+	}
+	x, y := real(a), real(b)
+	switch op.Type {
+	case MOD:
+		return complex(ev.mod(x, y), 0), nil
+	case LT:
+		return complexTruth(x < y), nil
+	case LE:
+		return complexTruth(x <= y), nil
+	case GT:
+		return complexTruth(x > y), nil
+	case GE:
+		return complexTruth(x >= y), nil
+	}
+	return 0, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate operator %s in complex mode", operatorSymbol(op)))
+}
+
+// complexPow raises base to exponent. Integer exponents use repeated
+// squaring, which is exact where cmplx.Pow would leave rounding noise, as
+// in i^2.
+func complexPow(base, exponent complex128) complex128 {
+	n := real(exponent)
+	if imag(exponent) != 0 || n != math.Trunc(n) || math.Abs(n) >= maxExactFloatInt {
+		return cmplx.Pow(base, exponent)
+	}
+
+	e := int64(math.Abs(n))
+	result, square := complex128(1), base
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			result *= square
+		}
+		if e > 1 {
+			square *= square
+		}
+	}
+	if n < 0 {
+		return 1 / result
+	}
+	return result
+}
+
+// complexTruth converts a boolean to 1 or 0.
+func complexTruth(b bool) complex128 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// complexUnary adapts a one-argument complex function to a complex builtin.
+func complexUnary(fn func(complex128) complex128) complexBuiltin {
+	return complexBuiltin{builtin{arity: 1}, func(args []complex128) (complex128, error) {
+		return fn(args[0]), nil
+	}}
+}
+
+// complexLog adapts a logarithm, rejecting zero.
+func complexLog(name string, fn func(complex128) complex128) complexBuiltin {
+	return complexBuiltin{builtin{arity: 1}, func(args []complex128) (complex128, error) {
+		if args[0] == 0 {
+			return 0, fmt.Errorf("%s: argument 0 is %w", name, ErrOutsideDomain)
+		}
+		return fn(args[0]), nil
+	}}
+}
+
+// complexBuiltin is a function available in complex mode; the signature
+// fields of builtin give its arity.
+type complexBuiltin struct {
+	sig builtin
+	fn  func(args []complex128) (complex128, error)
+}
+
+// Builtins available in complex mode
+var complexBuiltins = map[string]complexBuiltin{
+	"re": complexUnary(func(c complex128) complex128 {
+		return complex(real(c), 0)
+	}),
+	"im": complexUnary(func(c complex128) complex128 {
+		return complex(imag(c), 0)
+	}),
+	"conj": complexUnary(cmplx.Conj),
+	"abs": complexUnary(func(c complex128) complex128 {
+		return complex(cmplx.Abs(c), 0)
+	}),
+	"arg": complexUnary(func(c complex128) complex128 {
+		return complex(cmplx.Phase(c), 0)
+	}),
+	"sqrt":  complexUnary(cmplx.Sqrt),
+	"exp":   complexUnary(cmplx.Exp),
+	"ln":    complexLog("ln", cmplx.Log),
+	"log":   complexLog("log", cmplx.Log),
+	"log10": complexLog("log10", cmplx.Log10),
+	"sin":   complexUnary(cmplx.Sin),
+	"cos":   complexUnary(cmplx.Cos),
+	"tan":   complexUnary(cmplx.Tan),
+	"asin":  complexUnary(cmplx.Asin),
+	"acos":  complexUnary(cmplx.Acos),
+	"atan":  complexUnary(cmplx.Atan),
+	"pow": {builtin{arity: 2}, func(args []complex128) (complex128, error) {
+		return complexPow(args[0], args[1]), nil
+	}},
+}
+
+// callComplexBuiltin evaluates the arguments of a call and applies one of
+// the builtins available in complex mode.
+func (ev *Evaluator) callComplexBuiltin(call *FunctionCall, env *complexScope) (complex128, error) {
+	b, ok := complexBuiltins[call.Name]
+	if !ok {
+		return 0, ev.unavailableError(call.Name, "complex")
+	}
+	if err := b.sig.checkArgs(call.Name, len(call.Args)); err != nil {
+		return 0, err
+	}
+
+	args := make([]complex128, len(call.Args))
+	for i, arg := range call.Args {
+		value, err := ev.evalComplex(arg, env)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = value
+	}
+	return b.fn(args)
+}
+
+// end of file
@@ -0,0 +1,80 @@
+package expressionparser
+
+import (
+	"math/cmplx"
+	"testing"
+)
+
+func TestEvalComplex(t *testing.T) {
+	tests := []struct {
+		input string
+		want  complex128
+	}{
+		{"(3+4i)*(3-4i)", 25},
+		{"(3+4i)*(3-4i) == 25", 1},
+		{"3 + 4i", 3 + 4i},
+		{"(1+2i)/(3-4i)", -0.2 + 0.4i},
+		{"(1+2i)/(3-4i)*(3-4i)", 1 + 2i},
+		{"2i^2", -4},
+		{"(1+1i)^2", 2i},
+		{"sqrt(-1)", 1i},
+		{"sqrt(-4)", 2i},
+		{"sqrt(-1) * sqrt(-1)", -1},
+		{"abs(3+4i)", 5},
+		{"re(3+4i) + im(3+4i)", 7},
+		{"conj(3+4i)", 3 - 4i},
+		{"(3+4i) * conj(3+4i) == abs(3+4i)^2", 1},
+		{"ln(-1)", complex(0, 3.141592653589793)},
+		{"4i - 4i", 0},
+		{"let z = 1 + 1i in z * z * z * z", -4},
+	}
+	for _, tt := range tests {
+		got, err := EvalComplex(mustParse(t, tt.input))
+		if err != nil {
+			t.Errorf("EvalComplex(%s): %v", tt.input, err)
+			continue
+		}
+		if cmplx.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("EvalComplex(%s) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFormatComplex(t *testing.T) {
+	for c, want := range map[complex128]string{3 + 4i: "3+4i", 3 - 4i: "3-4i", -2i: "-2i", 2.5: "2.5", 0: "0"} {
+		if got := FormatComplex(c); got != want {
+			t.Errorf("FormatComplex(%v) = %s, want %s", c, got, want)
+		}
+	}
+}
+
+func TestEvalComplexErrors(t *testing.T) {
+	for input, code := range map[string]string{
+		"1 / 0i":     CodeDivisionByZero,
+		"1 < 2i":     CodeOutsideDomain,
+		"ln(0i)":     CodeOutsideDomain,
+		"nosuch(1i)": CodeUnknownFunction,
+	} {
+		if _, err := EvalComplex(mustParse(t, input)); ErrorCode(err) != code {
+			t.Errorf("EvalComplex(%s): error %v, want %s", input, err, code)
+		}
+	}
+}
+
+func TestComplexLiteralsOnlyInComplexMode(t *testing.T) {
+	if _, err := Evaluate("3 + 4i"); ErrorCode(err) != CodeUnsupported {
+		t.Errorf("Eval(3 + 4i): error %v, want %s", err, CodeUnsupported)
+	}
+	// Real expressions give the same results in both
+	for _, input := range []string{"abs(-3) + 2 ^ 10", "sqrt(16) / 8", "(1 < 2) + (3 == 3)"} {
+		want, err := Evaluate(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := EvalComplex(mustParse(t, input)); err != nil || got != complex(want, 0) {
+			t.Errorf("EvalComplex(%s) = %v, %v; Eval gives %v", input, got, err, want)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,40 @@
+package expressionparser
+
+// IsConstant reports whether expr evaluates to the same value on every run,
+// independent of any runtime input: it references no free variable or cell and calls
+// only registered builtins that are pure. Names bound by let are not free,
+// so "let x = 2 in x * 3" is constant. Calls to impure builtins such as
+// rand make a tree non-constant even when their arguments are literals.
+func IsConstant(expr Expr) bool {
+	if len(Variables(expr)) > 0 {
+		return false
+	}
+
+	constant := true
+	Walk(expr, func(e Expr) bool {
+		switch v := e.(type) {
+		case *FunctionCall:
+			if b, known := lookupBuiltin(v.Name); !known || b.impure {
+				constant = false
+			}
+		case *CellRef, *CellRange:
+			constant = false
+		}
+		return constant
+	})
+	return constant
+}
+
+// EvalConstant evaluates expr when it is constant, so that callers can
+// compute its value once ahead of time. The boolean is false, and no
+// evaluation happens, when expr depends on runtime input. Evaluation errors,
+// such as a constant division by zero, are returned with a true flag.
+func EvalConstant(expr Expr) (float64, bool, error) {
+	if !IsConstant(expr) {
+		return 0, false, nil
+	}
+	value, err := Eval(expr)
+	return value, true, err
+}
+
+// end of file
@@ -0,0 +1,59 @@
+package expressionparser
+
+import (
+	"testing"
+)
+
+func TestIsConstant(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"(2 + 3) * max(4, sqrt(16)) - -1", true},
+		{"let x = 2 in x * 3", true},
+		{`len("abc") + sum([1, 2])`, true},
+		{"1 + 2 * (3 - max(4, abs(5 / (6 + x))))", false},
+		{"let x = y in x", false},
+		{"let x = 2 in x + y", false},
+		{"rand()", false},
+		{"1 + rand() * 0", false},
+		{"nosuch(1)", false},
+	}
+	for _, tt := range tests {
+		if got := IsConstant(mustParse(t, tt.input)); got != tt.want {
+			t.Errorf("IsConstant(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIsConstantCells(t *testing.T) {
+	expr, err := ParseString("A1 * 2", WithCellReferences())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsConstant(expr) {
+		t.Error("IsConstant(A1 * 2) = true, want false")
+	}
+}
+
+func TestEvalConstant(t *testing.T) {
+	tests := []struct {
+		input    string
+		value    float64
+		constant bool
+		code     string
+	}{
+		{"(2 + 3) * 4", 20, true, ""},
+		{"1 / 0", 0, true, CodeDivisionByZero},
+		{"2 * (1 + x)", 0, false, ""},
+		{"rand() * 10", 0, false, ""},
+	}
+	for _, tt := range tests {
+		value, constant, err := EvalConstant(mustParse(t, tt.input))
+		if value != tt.value || constant != tt.constant || ErrorCode(err) != tt.code {
+			t.Errorf("EvalConstant(%q) = %v, %v, %v; want %v, %v, code %q", tt.input, value, constant, err, tt.value, tt.constant, tt.code)
+		}
+	}
+}
+
+// end of file
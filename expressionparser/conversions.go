@@ -0,0 +1,84 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// anyKind is the parameter kind of a builtin that accepts a value of any
+// kind and checks it itself.
+const anyKind Kind = -1
+
+// toInt converts a value to an integer, truncating toward zero as trunc
+// does: int(2.7) is 2 and int(-2.7) is -2; round(x) rounds to the nearest
+// instead. A bool gives 1 or 0 and a string is read as float reads it.
+// NaN and infinities have no integer and are errors.
+func toInt(args []Value) (Value, error) {
+	x, err := convertNumber("int", args[0])
+	if err != nil {
+		return Value{}, err
+	}
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("int: cannot convert %s to an integer", formatNumber(x)))
+	}
+	return NumberValue(math.Trunc(x)), nil
+}
+
+// toFloat converts a value to a number: a number is unchanged, a bool gives
+// 1 or 0, and a string must hold a finite number, as read by
+// strconv.ParseFloat with surrounding white space ignored.
+func toFloat(args []Value) (Value, error) {
+	x, err := convertNumber("float", args[0])
+	if err != nil {
+		return Value{}, err
+	}
+	return NumberValue(x), nil
+}
+
+// convertNumber converts a number, a bool or a numeric string to a number
+// for the named conversion. Other kinds are a *TypeError.
+func convertNumber(name string, v Value) (float64, error) {
+	switch v.kind {
+	case NumberKind, BoolKind:
+		return v.num, nil
+	case StringKind:
+		x, err := strconv.ParseFloat(strings.TrimSpace(v.str), 64)
+		if err != nil || math.IsNaN(x) || math.IsInf(x, 0) {
+			return 0, withCode(CodeOutsideDomain, fmt.Errorf("%s: cannot convert %q to a number", name, v.str))
+		}
+		return x, nil
+	}
+	return 0, &TypeError{Op: name, Operands: []Kind{v.kind}, Pos: -1}
+}
+
+// toBool converts a value to a bool: a number is true when it is neither
+// zero nor NaN, as where a condition is expected, and a string, a list or
+// a duration when it is not empty or zero. A date is a *TypeError.
+func toBool(args []Value) (Value, error) {
+	v := args[0]
+	switch v.kind {
+	case NumberKind, BoolKind:
+		return BoolValue(isTrue(v.num)), nil
+	case StringKind:
+		return BoolValue(v.str != ""), nil
+	case ListKind:
+		return BoolValue(v.list.len() > 0), nil
+	case DurationKind:
+		return BoolValue(v.dur != 0), nil
+	}
+	return Value{}, &TypeError{Op: "bool", Operands: []Kind{v.kind}, Pos: -1}
+}
+
+// toStr converts a value to a string as Value.String renders it, numbers
+// as Format prints them, except that a string is unchanged rather than
+// quoted.
+func toStr(args []Value) (Value, error) {
+	if v := args[0]; v.kind != StringKind {
+		return StringValue(v.String()), nil
+	}
+	return args[0], nil
+}
+
+// end of file
@@ -0,0 +1,92 @@
+package expressionparser
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestConversionMatrix(t *testing.T) {
+	env := map[string]Value{"nan": NumberValue(math.NaN()), "inf": NumberValue(math.Inf(1))}
+	tests := []struct {
+		input string
+		want  string // the kind and value of the result, or the error without its subexpression
+	}{
+		{"int(-2.7)", "number -2"},
+		{"int(2.7)", "number 2"},
+		{"int(1 < 2)", "number 1"},
+		{"int(2 < 1)", "number 0"},
+		{`int(" 2.5 ")`, "number 2"},
+		{`int("ab")`, `int: cannot convert "ab" to a number`},
+		{`int("")`, `int: cannot convert "" to a number`},
+		{`int("1e400")`, `int: cannot convert "1e400" to a number`},
+		{"int(nan)", "int: cannot convert NaN to an integer"},
+		{"int(inf)", "int: cannot convert +Inf to an integer"},
+		{"int([1, 2])", "cannot apply int to list"},
+		{`int(date("2024-01-31"))`, "cannot apply int to date"},
+		{"int(2 d)", "cannot apply int to duration"},
+
+		{"float(-2.7)", "number -2.7"},
+		{"float(1 < 2)", "number 1"},
+		{"float(2 < 1)", "number 0"},
+		{`float(" 2.5 ")`, "number 2.5"},
+		{"float(inf)", "number +Inf"},
+		{`float("abc")`, `float: cannot convert "abc" to a number`},
+		{`float("NaN")`, `float: cannot convert "NaN" to a number`},
+		{"float([])", "cannot apply float to list"},
+		{`float(date("2024-01-31"))`, "cannot apply float to date"},
+		{"float(2 d)", "cannot apply float to duration"},
+
+		{"bool(-2.7)", "bool true"},
+		{"bool(0)", "bool false"},
+		{"bool(nan)", "bool false"},
+		{"bool(inf)", "bool true"},
+		{"bool(2 < 1)", "bool false"},
+		{`bool("ab")`, "bool true"},
+		{`bool("")`, "bool false"},
+		{"bool([1, 2])", "bool true"},
+		{"bool([])", "bool false"},
+		{"bool(2 d)", "bool true"},
+		{"bool(0 d)", "bool false"},
+		{`bool(date("2024-01-31"))`, "cannot apply bool to date"},
+
+		{"str(-2.7)", `string "-2.7"`},
+		{"str(1e21)", `string "1e+21"`},
+		{"str(1 < 2)", `string "true"`},
+		{`str("ab")`, `string "ab"`},
+		{`str("")`, `string ""`},
+		{"str([1, 2])", `string "[1, 2]"`},
+		{`str(date("2024-01-31"))`, `string "2024-01-31"`},
+		{"str(2 d)", `string "2 d"`},
+		{"str(nan)", `string "NaN"`},
+	}
+	for _, tt := range tests {
+		expr := mustParse(t, tt.input)
+		got, err := EvalValue(expr, env)
+		if err != nil {
+			if want := tt.want + " in " + strconv.Quote(Format(expr)) + " at offset 0"; err.Error() != want {
+				t.Errorf("%s: error %q, want %q", tt.input, err, want)
+			}
+			continue
+		}
+		if s := got.Kind().String() + " " + got.String(); s != tt.want {
+			t.Errorf("%s = %s, want %s", tt.input, s, tt.want)
+		}
+	}
+
+	// Kinds without a conversion are type errors, bad strings outside the domain
+	var typeErr *TypeError
+	if _, err := EvalValue(mustParse(t, "int([1])"), nil); !errors.As(err, &typeErr) || typeErr.Op != "int" {
+		t.Errorf("int([1]): error %v, want a *TypeError", err)
+	}
+	if _, err := EvalValue(mustParse(t, `float("abc")`), nil); ErrorCode(err) != CodeOutsideDomain {
+		t.Errorf(`float("abc"): error %v, want %s`, err, CodeOutsideDomain)
+	}
+	// Conversions compose
+	if got, err := EvalValue(mustParse(t, `int(float(str(7.9))) + bool("x")`), nil); err != nil || got.num != 8 {
+		t.Errorf("composed conversions = %v, %v; want 8", got, err)
+	}
+}
+
+// end of file
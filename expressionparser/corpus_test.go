@@ -0,0 +1,66 @@
+package expressionparser
+
+// corpusVars are the variables the expressions of evalCorpus use.
+var corpusVars = map[string]float64{"x": 3, "y": -2.5, "n": 10, "zero": 0}
+
+// evalCorpus holds expressions covering the operators, builtins and error
+// paths, for tests comparing ways of evaluating them.
+var evalCorpus = []string{
+	"1 + 2 * 3",
+	"(1 + 2) * 3",
+	"x - y - 1",
+	"2 ^ 3 ^ 2",
+	"-x ^ 2",
+	"n % 3",
+	"x / y",
+	"x / zero",
+	"n % zero",
+	"x < y",
+	"x >= 3 && y < 0",
+	"zero || n",
+	"!zero",
+	"x == 3 ? n : y",
+	"if x > 1 then x * 2 else y",
+	"let a = x * 2 in a + a",
+	"let x = 1 in let y = x + 1 in x * y",
+	"sin(x) + cos(y)",
+	"sqrt(n)",
+	"sqrt(y)",
+	"ln(zero)",
+	"max(x, y, n)",
+	"min(1, 2)",
+	"abs(y)",
+	"floor(y) + ceil(y)",
+	"round(2.5)",
+	"pow(2, 10)",
+	"hypot(3, 4)",
+	"atan2(1, x)",
+	"exp(1) - e",
+	"pi * x ^ 2",
+	"unknown + 1",
+	"nosuch(1)",
+	"sqrt(1, 2)",
+	"x & 6",
+	"n << 2",
+	"n >> 1 | 1",
+	"1.5 & 1",
+	"piecewise(x > 5, 1, x > 2, 2, 3)",
+	"piecewise(zero, 1)",
+	"sum(i, 1, n, i ^ 2)",
+	"prod(i, 1, 5, i)",
+	"deriv(t ^ 2, \"t\", x)",
+	"1e308 * 10",
+	"0 && 1 / 0",
+	"x > 0 || 1 / zero",
+}
+
+// sameResult reports whether two evaluations gave the same value, NaN
+// included, or errors with the same message.
+func sameResult(got float64, gotErr error, want float64, wantErr error) bool {
+	if gotErr != nil || wantErr != nil {
+		return gotErr != nil && wantErr != nil && gotErr.Error() == wantErr.Error()
+	}
+	return got == want || got != got && want != want
+}
+
+// end of file
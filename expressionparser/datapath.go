@@ -0,0 +1,170 @@
+package expressionparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvFromData returns an environment over parent, which may be nil,
+// resolving variables from data as decoded by encoding/json into a
+// map[string]interface{}. A variable names a path into data: keys joined
+// by dots, each followed by any number of indexes into arrays, as in
+// order.customer.discount and order.items[0].price. The value at the end
+// of the path must be a number, a json.Number, a bool, a string or an
+// array of numbers, bound as a list.
+//
+// A path that does not resolve is an error of the evaluation naming the
+// path and where it failed: a missing key, an index out of range, a step
+// into a value that is not an object or an array, or a value of another
+// kind at the end. A name whose first key is not in data is looked up in
+// parent instead, and is an undefined variable when it is not there either.
+// data is only read, and must not be modified while the environment is in
+// use.
+func EnvFromData(data map[string]interface{}, parent *Environment) *Environment {
+	env := NewEnvironment(parent)
+	env.data = data
+	return env
+}
+
+// pathStep is a key, or an index when key is "", of a path into data.
+type pathStep struct {
+	key   string
+	index int
+}
+
+// splitPath splits a variable name into the steps of a path, reporting
+// whether it is one.
+func splitPath(name string) ([]pathStep, bool) {
+	var steps []pathStep
+	for _, part := range strings.Split(name, ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key == "" {
+			return nil, false
+		}
+		steps = append(steps, pathStep{key: key})
+		for rest != "" {
+			digits, after, ok := strings.Cut(rest, "]")
+			index, err := strconv.Atoi(digits)
+			if !ok || err != nil {
+				return nil, false
+			}
+			steps = append(steps, pathStep{index: index})
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+	return steps, true
+}
+
+// resolvePath returns the value at the path name in data. It reports false
+// when the first key is not in data, and an error when the path fails
+// further on.
+func resolvePath(data map[string]interface{}, name string) (Value, bool, error) {
+	steps, ok := splitPath(name)
+	if !ok {
+		return Value{}, false, nil
+	}
+	at, ok := data[steps[0].key]
+	if !ok {
+		return Value{}, false, nil
+	}
+	done := steps[0].key
+	for _, step := range steps[1:] {
+		switch {
+		case step.key != "":
+			object, isObject := at.(map[string]interface{})
+			if !isObject {
+				return Value{}, true, withCode(CodeType, fmt.Errorf("variable %s: %s is %s, not an object with key %s", name, done, jsonKind(at), step.key))
+			}
+			if at, ok = object[step.key]; !ok {
+				return Value{}, true, withCode(CodeUndefinedVar, fmt.Errorf("variable %s: %s has no key %s", name, done, step.key))
+			}
+			done += "." + step.key
+		default:
+			array, isArray := at.([]interface{})
+			if !isArray {
+				return Value{}, true, withCode(CodeType, fmt.Errorf("variable %s: %s is %s, not an array to index", name, done, jsonKind(at)))
+			}
+			if step.index >= len(array) {
+				return Value{}, true, withCode(CodeUndefinedVar, fmt.Errorf("variable %s: index %d is out of range of %s, of length %d", name, step.index, done, len(array)))
+			}
+			at = array[step.index]
+			done += "[" + strconv.Itoa(step.index) + "]"
+		}
+	}
+	value, ok := dataValue(at)
+	if _, isArray := at.([]interface{}); !ok && isArray {
+		return Value{}, true, withCode(CodeType, fmt.Errorf("variable %s is an array holding other than numbers", name))
+	} else if !ok {
+		return Value{}, true, withCode(CodeType, fmt.Errorf("variable %s is %s, not a number, bool, string or array of numbers", name, jsonKind(at)))
+	}
+	return value, true, nil
+}
+
+// dataValue converts a value decoded from JSON to a Value.
+func dataValue(x interface{}) (Value, bool) {
+	switch x := x.(type) {
+	case nil:
+		return Value{}, false
+	case string:
+		return StringValue(x), true
+	case bool:
+		return BoolValue(x), true
+	case json.Number:
+		f, err := x.Float64()
+		return NumberValue(f), err == nil
+	case []interface{}:
+		items := make([]float64, len(x))
+		for i, elem := range x {
+			value, ok := dataValue(elem)
+			if !ok || value.kind != NumberKind {
+				return Value{}, false
+			}
+			items[i] = value.num
+		}
+		return Value{kind: ListKind, list: &list{items: items}}, true
+	}
+	f, ok := numberOf(reflect.ValueOf(x))
+	return NumberValue(f), ok
+}
+
+// jsonKind describes the kind of a value decoded from JSON in errors.
+func jsonKind(x interface{}) string {
+	switch x.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a bool"
+	}
+	if _, ok := numberOf(reflect.ValueOf(x)); ok {
+		return "a number"
+	}
+	return "a " + reflect.TypeOf(x).String()
+}
+
+// pathError returns the error of resolving name in the data of the
+// environments in scope, or nil when none of them has the first key of
+// name.
+func (s *scope) pathError(name string) error {
+	for ; s != nil; s = s.parent {
+		for e := s.env; e != nil; e = e.parent {
+			if e.data == nil {
+				continue
+			}
+			if _, found, err := resolvePath(e.data, name); found {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// end of file
@@ -0,0 +1,85 @@
+package expressionparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// orderData decodes the JSON fixture of an order, with json.Number for
+// numbers when useNumber is set.
+func orderData(t *testing.T, useNumber bool) map[string]interface{} {
+	t.Helper()
+	b, err := os.ReadFile("testdata/data/order.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if useNumber {
+		dec.UseNumber()
+	}
+	var data map[string]interface{}
+	if err := dec.Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestEnvFromData(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"order.items[0].price * order.items[0].qty", "10"},
+		{"(order.items[0].price * order.items[0].qty + order.items[1].price) * (1 - order.customer.discount)", "18"},
+		{"order.express ? 1 : 0", "1"},
+		{`order.id == "A-1001"`, "true"},
+		{"order.items[1].sku", `"G-7"`},
+		{"sum(order.weights)", "4"},
+		{"order.weights[2]", "0.5"},
+		{"rate * tax", "0.4"}, // rate from the parent
+	}
+	for _, useNumber := range []bool{false, true} {
+		parent := NewEnvironment(nil)
+		parent.SetNumber("rate", 2)
+		env := EnvFromData(orderData(t, useNumber), parent)
+		for _, tt := range tests {
+			got, err := NewEvaluator().EvalIn(mustParse(t, tt.input), env)
+			if err != nil || got.String() != tt.want {
+				t.Errorf("%s (json.Number %v) = %v, %v; want %s", tt.input, useNumber, got, err, tt.want)
+			}
+		}
+	}
+}
+
+func TestEnvFromDataErrors(t *testing.T) {
+	env := EnvFromData(orderData(t, false), nil)
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		// A missing key or index
+		{"order.customer.email", CodeUndefinedVar, "variable order.customer.email: order.customer has no key email"},
+		{"order.items[2].price", CodeUndefinedVar, "variable order.items[2].price: index 2 is out of range of order.items, of length 2"},
+		{"missing + 1", CodeUndefinedVar, "undefined variable missing"},
+		// A step into something else
+		{"order.id.length", CodeType, "variable order.id.length: order.id is a string, not an object with key length"},
+		{"order.customer[0]", CodeType, "variable order.customer[0]: order.customer is an object, not an array to index"},
+		{"order.weights[1][0]", CodeType, "variable order.weights[1][0]: order.weights[1] is a number, not an array to index"},
+		// A leaf that does not convert
+		{"order.customer", CodeType, "variable order.customer is an object, not a number, bool, string or array of numbers"},
+		{"order.customer.tier", CodeType, "variable order.customer.tier is null, not a number, bool, string or array of numbers"},
+		{"order.labels", CodeType, "variable order.labels is an array holding other than numbers"},
+	}
+	for _, tt := range tests {
+		_, err := NewEvaluator().EvalIn(mustParse(t, tt.input), env)
+		if ErrorCode(err) != tt.code || err == nil || !strings.HasPrefix(err.Error(), tt.msg+` in "`) {
+			t.Errorf("%s: error %v (%s), want %q (%s)", tt.input, err, ErrorCode(err), tt.msg, tt.code)
+		}
+	}
+}
+
+// end of file
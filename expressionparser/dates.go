@@ -0,0 +1,244 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Durations of the units a duration literal such as 30d may carry
+var durationUnits = map[string]time.Duration{
+	"ms":  time.Millisecond,
+	"s":   time.Second,
+	"min": time.Minute,
+	"h":   time.Hour,
+	"d":   24 * time.Hour,
+}
+
+// DateValue returns t as a date Value, in UTC, as bound in the environment
+// of EvalValue.
+func DateValue(t time.Time) Value {
+	return Value{kind: DateKind, date: t.UTC()}
+}
+
+// DurationValue returns d as a duration Value.
+func DurationValue(d time.Duration) Value {
+	return Value{kind: DurationKind, dur: d}
+}
+
+// ValueOf converts a Go value to a Value for the environment of EvalValue:
+// a float64, an int, a bool, a string, a []float64, a time.Time or a
+// time.Duration.
+func ValueOf(x interface{}) (Value, error) {
+	switch v := x.(type) {
+	case float64:
+		return NumberValue(v), nil
+	case int:
+		return NumberValue(float64(v)), nil
+	case bool:
+		return BoolValue(v), nil
+	case string:
+		return StringValue(v), nil
+	case []float64:
+		return ListValue(v), nil
+	case time.Time:
+		return DateValue(v), nil
+	case time.Duration:
+		return DurationValue(v), nil
+	}
+	return Value{}, withCode(CodeType, fmt.Errorf("cannot convert %T to a Value", x))
+}
+
+// AsDate returns the value of a date, in UTC.
+func (v Value) AsDate() (time.Time, error) {
+	if v.kind != DateKind {
+		return time.Time{}, withCode(CodeType, fmt.Errorf("value is a %s, not a date", v.kind))
+	}
+	return v.date, nil
+}
+
+// AsDuration returns the value of a duration.
+func (v Value) AsDuration() (time.Duration, error) {
+	if v.kind != DurationKind {
+		return 0, withCode(CodeType, fmt.Errorf("value is a %s, not a duration", v.kind))
+	}
+	return v.dur, nil
+}
+
+// formatDate renders a date as the argument of date that gives it back:
+// the day alone at midnight, and otherwise in RFC 3339 form.
+func formatDate(t time.Time) string {
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// durationLiteral returns the literal of a duration in the largest unit
+// that divides it.
+func durationLiteral(d time.Duration) *QuantityLiteral {
+	for _, unit := range []string{"d", "h", "min", "s", "ms"} {
+		if d%durationUnits[unit] == 0 {
+			return Qty(float64(d/durationUnits[unit]), unit)
+		}
+	}
+	return Qty(d.Seconds(), "s")
+}
+
+// formatDuration renders a duration as its literal.
+func formatDuration(d time.Duration) string {
+	return Format(durationLiteral(d))
+}
+
+// durationOf returns the duration of a quantity literal in a unit of time.
+func durationOf(q *QuantityLiteral) (Value, bool, error) {
+	unit, ok := durationUnits[q.Unit]
+	if !ok {
+		return Value{}, false, nil
+	}
+	ns := q.Value * float64(unit)
+	if math.IsNaN(ns) || math.Abs(ns) >= math.MaxInt64 {
+		return Value{}, true, fmt.Errorf("duration %s is out of range: %w", Format(q), ErrOverflow)
+	}
+	return DurationValue(time.Duration(ns)), true, nil
+}
+
+// parseDate reads the argument of date: a day such as 2024-01-31, or a
+// time in RFC 3339 form. Days are taken in UTC.
+func parseDate(args []Value) (Value, error) {
+	s := args[0].str
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339Nano, s); err != nil {
+			return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("date: invalid date %q", s))
+		}
+	}
+	return DateValue(t), nil
+}
+
+// currentTime returns the current time in UTC.
+func currentTime(args []Value) (Value, error) {
+	return DateValue(time.Now()), nil
+}
+
+// daysBetween returns the number of days between two dates, whichever
+// comes first, with a fraction for the time of day.
+func daysBetween(args []Value) (Value, error) {
+	d := args[0].date.Sub(args[1].date)
+	if d < 0 {
+		d = -d
+	}
+	return NumberValue(d.Hours() / 24), nil
+}
+
+// dateOperation applies a binary operator to operands of which at least one
+// is a date or a duration: a date plus or minus a duration is a date, the
+// difference of two dates a duration, durations add, scale by numbers and
+// divide, and dates and durations compare with their own kind.
+func dateOperation(op Token, pos int, a, b Value) (Value, error) {
+	switch {
+	case a.kind == DateKind && b.kind == DateKind:
+		switch op.Type {
+		case MINUS:
+			return DurationValue(a.date.Sub(b.date)), nil
+		case LT:
+			return BoolValue(a.date.Before(b.date)), nil
+		case LE:
+			return BoolValue(!a.date.After(b.date)), nil
+		case GT:
+			return BoolValue(a.date.After(b.date)), nil
+		case GE:
+			return BoolValue(!a.date.Before(b.date)), nil
+		case EQ:
+			return BoolValue(a.date.Equal(b.date)), nil
+		case NE:
+			return BoolValue(!a.date.Equal(b.date)), nil
+		}
+	case a.kind == DateKind && b.kind == DurationKind:
+		switch op.Type {
+		case PLUS:
+			return DateValue(a.date.Add(b.dur)), nil
+		case MINUS:
+			return DateValue(a.date.Add(-b.dur)), nil
+		}
+	case a.kind == DurationKind && b.kind == DateKind:
+		if op.Type == PLUS {
+			return DateValue(b.date.Add(a.dur)), nil
+		}
+	case a.kind == DurationKind && b.kind == DurationKind:
+		x, y := a.dur, b.dur
+		switch op.Type {
+		case PLUS:
+			return addDurations(x, y, pos)
+		case MINUS:
+			return addDurations(x, -y, pos)
+		case DIV:
+			if y == 0 {
+				return Value{}, atOffset(ErrDivisionByZero, pos)
+			}
+			return NumberValue(float64(x) / float64(y)), nil
+		case LT:
+			return BoolValue(x < y), nil
+		case LE:
+			return BoolValue(x <= y), nil
+		case GT:
+			return BoolValue(x > y), nil
+		case GE:
+			return BoolValue(x >= y), nil
+		case EQ:
+			return BoolValue(x == y), nil
+		case NE:
+			return BoolValue(x != y), nil
+		}
+	case a.kind == DurationKind && b.numeric():
+		switch op.Type {
+		case MULT:
+			return scaleDuration(float64(a.dur)*b.num, pos)
+		case DIV:
+			if b.num == 0 {
+				return Value{}, atOffset(ErrDivisionByZero, pos)
+			}
+			return scaleDuration(float64(a.dur)/b.num, pos)
+		}
+	case a.numeric() && b.kind == DurationKind:
+		if op.Type == MULT {
+			return scaleDuration(a.num*float64(b.dur), pos)
+		}
+	}
+	return Value{}, &TypeError{Op: operatorSymbol(op), Operands: []Kind{a.kind, b.kind}, Pos: pos}
+}
+
+// addDurations adds two durations, which must not overflow time.Duration.
+func addDurations(x, y time.Duration, pos int) (Value, error) {
+	sum := x + y
+	if (sum > x) != (y > 0) {
+		return Value{}, atOffset(fmt.Errorf("duration %w", ErrOverflow), pos)
+	}
+	return DurationValue(sum), nil
+}
+
+// scaleDuration returns the duration of ns nanoseconds, a multiple or a
+// quotient of a duration, which must lie within the range of time.Duration.
+func scaleDuration(ns float64, pos int) (Value, error) {
+	if math.IsNaN(ns) || math.Abs(ns) >= math.MaxInt64 {
+		return Value{}, atOffset(fmt.Errorf("duration %w", ErrOverflow), pos)
+	}
+	return DurationValue(time.Duration(ns)), nil
+}
+
+// negateValue applies unary minus to a number or a duration.
+func negateValue(v Value, pos int) (Value, error) {
+	switch v.kind {
+	case DurationKind:
+		return DurationValue(-v.dur), nil
+	case ListKind:
+		return negateList(v, pos)
+	}
+	x, err := v.number("-", pos)
+	if err != nil {
+		return Value{}, err
+	}
+	return NumberValue(-x), nil
+}
+
+// end of file
@@ -0,0 +1,99 @@
+package expressionparser
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDates(t *testing.T) {
+	env := map[string]Value{
+		"ship_date":  DateValue(time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC)),
+		"order_date": DateValue(time.Date(2024, 1, 30, 12, 0, 0, 0, time.FixedZone("CET", 3600))),
+		"deadline":   DateValue(time.Now().Add(29 * 24 * time.Hour)),
+	}
+	tests := []struct {
+		input string
+		want  string // the result as Value.String renders it
+		kind  Kind
+	}{
+		{"now() + 30d > deadline", "true", BoolKind},
+		{"now() + 28d > deadline", "false", BoolKind},
+		{"days_between(ship_date, order_date) <= 5", "true", BoolKind},
+		{"days_between(ship_date, order_date) == days_between(order_date, ship_date)", "true", BoolKind},
+		{"days_between(ship_date, order_date)", "3.5416666666666665", NumberKind},
+		{`date("2024-01-31") + 1d`, "2024-02-01", DateKind},
+		{`1d + date("2024-01-31")`, "2024-02-01", DateKind},
+		{"ship_date - 1d - 1d", "2024-02-01", DateKind},
+		{`date("2024-03-01") - date("2024-02-01")`, "29 d", DurationKind},
+		{`date("2024-01-31T10:00:00+02:00")`, "2024-01-31T08:00:00Z", DateKind},
+		{`date("2024-01-31") < date("2024-02-01")`, "true", BoolKind},
+		{`date("2024-01-31") >= date("2024-02-01")`, "false", BoolKind},
+		{`date("2024-01-31") == date("2024-01-31T00:00:00Z")`, "true", BoolKind},
+		{"2h + 30min", "150 min", DurationKind},
+		{"1d / 1h", "24", NumberKind},
+		{"3 * 1h", "3 h", DurationKind},
+		{"-1d", "-1 d", DurationKind},
+		{"1h < 61min", "true", BoolKind},
+	}
+	for _, tt := range tests {
+		got, err := EvalValue(mustParse(t, tt.input), env)
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if got.String() != tt.want || got.Kind() != tt.kind {
+			t.Errorf("%s = %s (%v), want %s (%v)", tt.input, got, got.Kind(), tt.want, tt.kind)
+		}
+	}
+}
+
+func TestDateErrors(t *testing.T) {
+	env := map[string]Value{"ship_date": DateValue(time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC))}
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{`date("2024-01-31") + date("2024-01-31")`, `cannot apply + to date and date in "date(\"2024-01-31\") + date(\"2024-01-31\")" at offset 0`},
+		{"1d - ship_date", `cannot apply - to duration and date in "1 d - ship_date" at offset 0`},
+		{"ship_date * 2", `cannot apply * to date and number in "ship_date * 2" at offset 0`},
+		{`date("2024-02-30")`, `date: invalid date "2024-02-30" in "date(\"2024-02-30\")" at offset 0`},
+		{`date("yesterday")`, `date: invalid date "yesterday" in "date(\"yesterday\")" at offset 0`},
+		{"1h / 0", `division by zero in "1 h / 0" at offset 0`},
+	}
+	for _, tt := range tests {
+		_, err := EvalValue(mustParse(t, tt.input), env)
+		if err == nil || err.Error() != tt.msg {
+			t.Errorf("%s: error %v, want %q", tt.input, err, tt.msg)
+		}
+	}
+	var typeErr *TypeError
+	if _, err := EvalValue(mustParse(t, "ship_date + ship_date"), env); !errors.As(err, &typeErr) {
+		t.Errorf("ship_date + ship_date: error %v, want a *TypeError", err)
+	}
+}
+
+func TestDateBindings(t *testing.T) {
+	// Dates are held in UTC whatever the zone they were bound in
+	at := time.Date(2024, 1, 1, 5, 0, 0, 0, time.FixedZone("", -3600))
+	v, err := ValueOf(at)
+	if err != nil || v.Kind() != DateKind || v.String() != "2024-01-01T06:00:00Z" {
+		t.Fatalf("ValueOf(%v) = %v, %v", at, v, err)
+	}
+	if d, err := v.AsDate(); err != nil || !d.Equal(at) || d.Location() != time.UTC {
+		t.Errorf("AsDate = %v, %v; want %v in UTC", d, err, at)
+	}
+	week, err := ValueOf(7 * 24 * time.Hour)
+	if err != nil || week.Kind() != DurationKind || week.String() != "7 d" {
+		t.Fatalf("ValueOf(a week) = %v, %v", week, err)
+	}
+	got, err := EvalValue(mustParse(t, "at + week"), map[string]Value{"at": v, "week": week})
+	if d, _ := got.AsDate(); err != nil || !d.Equal(at.AddDate(0, 0, 7)) {
+		t.Errorf("at + week = %v, %v", got, err)
+	}
+	if _, err := v.AsDuration(); err == nil {
+		t.Error("AsDuration of a date: no error")
+	}
+}
+
+// end of file
@@ -0,0 +1,378 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// RoundingMode selects how decimal results are rounded to the scale, and
+// results of Eval WithRoundTo.
+type RoundingMode int
+
+const (
+	RoundHalfUp   RoundingMode = iota // ties away from zero: 0.125 -> 0.13, -0.125 -> -0.13
+	RoundHalfEven                     // ties to the even digit, banker's rounding: 0.125 -> 0.12
+	RoundDown                         // toward zero, truncating: 0.129 -> 0.12, -0.129 -> -0.12
+	RoundUp                           // away from zero: 0.121 -> 0.13, -0.121 -> -0.13
+)
+
+// Number of fractional digits of EvalDecimal when WithDecimalScale is not given
+const defaultDecimalScale = 4
+
+// Largest supported scale; 10^18 is the largest power of ten in an int64
+const maxDecimalScale = 18
+
+// WithDecimalScale sets the number of fractional digits kept by EvalDecimal.
+func WithDecimalScale(digits int) Option {
+	return func(c *config) {
+		c.decimalScale = digits
+		c.decimalScaleSet = true
+	}
+}
+
+// WithRounding sets how EvalDecimal rounds results to the scale.
+func WithRounding(mode RoundingMode) Option {
+	return func(c *config) {
+		c.rounding = mode
+	}
+}
+
+// WithStrictDecimals makes EvalDecimal reject a literal with more fractional
+// digits than the scale instead of rounding it.
+func WithStrictDecimals() Option {
+	return func(c *config) {
+		c.strictDecimals = true
+	}
+}
+
+// Decimal is a fixed-point result of EvalDecimal: an integer number of minor
+// units, each 10^-scale.
+type Decimal struct {
+	units int64
+	scale int
+}
+
+// MinorUnits returns the value as an integer count of 10^-scale units, e.g.
+// cents when the scale is 2.
+func (d Decimal) MinorUnits() int64 {
+	return d.units
+}
+
+// Scale returns the number of fractional digits.
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// String renders the value with exactly Scale fractional digits, e.g. "0.3000".
+func (d Decimal) String() string {
+	s := new(big.Int).Abs(big.NewInt(d.units)).String()
+	if d.scale > 0 {
+		if len(s) <= d.scale {
+			s = strings.Repeat("0", d.scale-len(s)+1) + s
+		}
+		s = s[:len(s)-d.scale] + "." + s[len(s)-d.scale:]
+	}
+	if d.units < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// EvalDecimal evaluates an expression in decimal mode with the default Evaluator.
+func EvalDecimal(expr Expr) (Decimal, error) {
+	return defaultEvaluator.EvalDecimal(expr)
+}
+
+// EvalDecimal evaluates expr in fixed-point decimal arithmetic, so that
+// 0.1 + 0.2 == 0.3 holds. Values are int64 counts of 10^-scale units; the
+// scale defaults to 4 digits and may be 0 to 18.
+//
+// Parsed literals are read from their source text and rounded to the scale,
+// or rejected with WithStrictDecimals. Literals of built trees and variable
+// values are converted from float64 and always rounded. Addition,
+// subtraction and % are exact; multiplication, division and ^ are computed
+// exactly and rounded once per operation with the configured RoundingMode.
+// A result that does not fit in an int64 is an overflow error, and division
+// or modulo by zero follows the WithDivisionByZero policy, except that
+// DivideByZeroIEEE gives an error. ^ requires an integer exponent.
+// Comparisons and logical operators yield 1 or 0. Of the builtins only abs,
+// sign, min, max, clamp, floor, ceil, round and trunc are available; the
+// rounding builtins round to an integer.
+func (ev *Evaluator) EvalDecimal(expr Expr) (Decimal, error) {
+	if err := ev.admit(expr); err != nil {
+		return Decimal{}, err
+	}
+	d := &decimalEvaluator{ev: ev, scale: defaultDecimalScale}
+	if ev.cfg.decimalScaleSet {
+		d.scale = ev.cfg.decimalScale
+	}
+	if d.scale < 0 || d.scale > maxDecimalScale {
+		return Decimal{}, withCode(CodeInvalidArgument, fmt.Errorf("decimal scale %d is outside 0 to %d", d.scale, maxDecimalScale))
+	}
+	d.one = new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale)), nil)
+
+	units, err := d.eval(expr, nil)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return Decimal{units: units, scale: d.scale}, nil
+}
+
+// decimalEvaluator carries the scale of one EvalDecimal call.
+type decimalEvaluator struct {
+	ev    *Evaluator
+	scale int
+	one   *big.Int // 10^scale, the units of 1
+}
+
+// decimalScope is a chain of let bindings in decimal mode, innermost first.
+type decimalScope struct {
+	name   string
+	value  int64
+	parent *decimalScope
+}
+
+// format renders a count of units for error messages.
+func (d *decimalEvaluator) format(units int64) string {
+	return Decimal{units: units, scale: d.scale}.String()
+}
+
+// round divides n by den, rounding to an integer with the configured mode.
+func (d *decimalEvaluator) round(n, den *big.Int) *big.Int {
+	return roundQuo(n, den, d.ev.cfg.rounding)
+}
+
+// roundQuo returns n / den rounded to an integer.
+func roundQuo(n, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(n, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+
+	var away bool
+	switch mode {
+	case RoundDown:
+	case RoundUp:
+		away = true
+	default:
+		cmp := new(big.Int).Abs(new(big.Int).Lsh(r, 1)).CmpAbs(den)
+		away = cmp > 0 || (cmp == 0 && (mode == RoundHalfUp || q.Bit(0) == 1))
+	}
+	if away {
+		q.Add(q, big.NewInt(int64(n.Sign()*den.Sign())))
+	}
+	return q
+}
+
+// decimalFit converts an exact count of units to int64, reporting overflow.
+func decimalFit(units *big.Int, what func() string) (int64, error) {
+	if !units.IsInt64() {
+		return 0, fmt.Errorf("decimal %w in %s", ErrOverflow, what())
+	}
+	return units.Int64(), nil
+}
+
+// fromRat converts a rational to units, rounding unless exact is required.
+func (d *decimalEvaluator) fromRat(r *big.Rat, exact bool, what string) (int64, error) {
+	n := new(big.Int).Mul(r.Num(), d.one)
+	if exact && new(big.Int).Rem(n, r.Denom()).Sign() != 0 {
+		return 0, withCode(CodeUnsupported, fmt.Errorf("%s has more than %d fractional digits", what, d.scale))
+	}
+	return decimalFit(d.round(n, r.Denom()), func() string { return what })
+}
+
+// truth converts a boolean to the units of 1 or 0.
+func (d *decimalEvaluator) truth(b bool) int64 {
+	if b {
+		return d.one.Int64()
+	}
+	return 0
+}
+
+// eval evaluates expr in decimal mode with the given let bindings in scope.
+func (d *decimalEvaluator) eval(expr Expr, env *decimalScope) (int64, error) {
+	switch v := expr.(type) {
+	case *Number:
+		if v.Imag {
+			return 0, imaginaryError(v, "decimal")
+		}
+		if v.Text != "" {
+			r, ok := new(big.Rat).SetString(v.Text)
+			if !ok {
+				return 0, withCode(CodeInvalidLiteral, fmt.Errorf("invalid literal %s", v.Text))
+			}
+			return d.fromRat(r, d.ev.cfg.strictDecimals, fmt.Sprintf("literal %s at offset %d", v.Text, v.Span.Start))
+		}
+		r, err := ratFromFloat(v.Value, "literal")
+		if err != nil {
+			return 0, err
+		}
+		return d.fromRat(r, false, "literal "+formatNumber(v.Value))
+	case *Variable:
+		for s := env; s != nil; s = s.parent {
+			if s.name == v.Name {
+				return s.value, nil
+			}
+		}
+		value, ok := d.ev.vars[v.Name]
+		if !ok {
+			return 0, &UndefinedVariableError{Name: v.Name, Span: v.Span}
+		}
+		r, err := ratFromFloat(value, "variable "+v.Name+" =")
+		if err != nil {
+			return 0, err
+		}
+		return d.fromRat(r, false, "variable "+v.Name)
+	case *UnaryOp:
+		operand, err := d.eval(v.Operand, env)
+		if err != nil {
+			return 0, err
+		}
+		switch v.Op.Type {
+		case MINUS:
+			return decimalFit(new(big.Int).Neg(big.NewInt(operand)), func() string { return "-(" + d.format(operand) + ")" })
+		case NOT:
+			return d.truth(operand == 0), nil
+		}
+	case *BinaryOp:
+		left, err := d.eval(v.Left, env)
+		if err != nil {
+			return 0, err
+		}
+		if (v.Op.Type == AND || v.Op.Type == OR) && (left != 0) == (v.Op.Type == OR) {
+			// Short-circuit: the right operand cannot change the result
+			return d.truth(left != 0), nil
+		}
+		right, err := d.eval(v.Right, env)
+		if err != nil {
+			return 0, err
+		}
+		if isDivision(v.Op) && right == 0 {
+			value, ieee, err := d.ev.zeroDivision(v.Op, v.Right)
+			if err != nil {
+				return 0, err
+			}
+			if ieee {
+				return 0, noIEEEError(v.Op, v.Right, "decimal")
+			}
+			r, err := ratFromFloat(value, "division by zero value")
+			if err != nil {
+				return 0, err
+			}
+			return d.fromRat(r, false, "division by zero value "+formatNumber(value))
+		}
+		return d.operation(v.Op, left, right)
+	case *FunctionCall:
+		return d.call(v, env)
+	case *Let:
+		value, err := d.eval(v.Value, env)
+		if err != nil {
+			return 0, err
+		}
+		return d.eval(v.Body, &decimalScope{name: v.Name, value: value, parent: env})
+	case *Conditional:
+		cond, err := d.eval(v.Cond, env)
+		if err != nil {
+			return 0, err
+		}
+		if cond != 0 {
+			return d.eval(v.Then, env)
+		}
+		return d.eval(v.Else, env)
+	}
+	return 0, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate expression of type %T in decimal mode", expr))
+}
+
+// operation applies a binary operator to two counts of units. The divisor
+// of / and % must not be zero.
+func (d *decimalEvaluator) operation(op Token, a, b int64) (int64, error) {
+	x, y := big.NewInt(a), big.NewInt(b)
+	what := func() string {
+		return d.format(a) + " " + operatorSymbol(op) + " " + d.format(b)
+	}
+
+	switch op.Type {
+	case PLUS:
+		return decimalFit(x.Add(x, y), what)
+	case MINUS:
+		return decimalFit(x.Sub(x, y), what)
+	case MULT:
+		return decimalFit(d.round(x.Mul(x, y), d.one), what)
+	case DIV:
+		return decimalFit(d.round(x.Mul(x, d.one), y), what)
+	case MOD:
+		r := a % b
+		if d.ev.cfg.flooredModulo && r != 0 && (r < 0) != (b < 0) {
+			r += b
+		}
+		return r, nil
+	case POW:
+		if b%d.one.Int64() != 0 {
+			return 0, withCode(CodeUnsupported, fmt.Errorf("exponent %s is not an integer", d.format(b)))
+		}
+		k := b / d.one.Int64()
+		r, err := ratPow(new(big.Rat).SetFrac(x, d.one), big.NewRat(k, 1))
+		if errors.Is(err, ErrOverflow) {
+			if shrinks := (a > -d.one.Int64() && a < d.one.Int64()) == (k > 0); !shrinks {
+				return 0, fmt.Errorf("decimal %w in %s", ErrOverflow, what())
+			}
+			// Far below one unit, the power rounds as a quarter unit would
+			sign := int64(1)
+			if a < 0 && k%2 != 0 {
+				sign = -1
+			}
+			return decimalFit(d.round(big.NewInt(sign), big.NewInt(4)), what)
+		}
+		if err != nil {
+			return 0, err
+		}
+		return decimalFit(d.round(new(big.Int).Mul(r.Num(), d.one), r.Denom()), what)
+	case LT:
+		return d.truth(a < b), nil
+	case LE:
+		return d.truth(a <= b), nil
+	case GT:
+		return d.truth(a > b), nil
+	case GE:
+		return d.truth(a >= b), nil
+	case EQ:
+		return d.truth(a == b), nil
+	case NE:
+		return d.truth(a != b), nil
+	case AND:
+		return d.truth(a != 0 && b != 0), nil
+	case OR:
+		return d.truth(a != 0 || b != 0), nil
+	}
+	return 0, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate operator %s in decimal mode", operatorSymbol(op)))
+}
+
+// call evaluates the arguments of a call and applies one of the builtins
+// available in decimal mode. The rounding builtins reuse the rational ones.
+func (d *decimalEvaluator) call(call *FunctionCall, env *decimalScope) (int64, error) {
+	b, ok := ratBuiltins[call.Name]
+	if !ok || call.Name == "sqrt" {
+		return 0, d.ev.unavailableError(call.Name, "decimal")
+	}
+	if err := b.sig.checkArgs(call.Name, len(call.Args)); err != nil {
+		return 0, err
+	}
+
+	args := make([]*big.Rat, len(call.Args))
+	for i, arg := range call.Args {
+		value, err := d.eval(arg, env)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = new(big.Rat).SetFrac(big.NewInt(value), d.one)
+	}
+	result, err := b.fn(args)
+	if err != nil {
+		return 0, err
+	}
+	return d.fromRat(result, true, call.Name)
+}
+
+// end of file
@@ -0,0 +1,109 @@
+package expressionparser
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEvalDecimal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		units int64
+	}{
+		{"0.1 + 0.2", "0.3000", 3000},
+		{"0.1 + 0.2 == 0.3", "1.0000", 10000},
+		{"19.99 * 3", "59.9700", 599700},
+		{"10 / 3", "3.3333", 33333},
+		{"-10 / 3", "-3.3333", -33333},
+		{"1.5 ^ 2", "2.2500", 22500},
+		{"7.5 % 2", "1.5000", 15000},
+		{"round(2.5) + floor(-0.5)", "2.0000", 20000},
+		{"0", "0.0000", 0},
+		{"-0.0001", "-0.0001", -1},
+	}
+	for _, tt := range tests {
+		got, err := EvalDecimal(mustParse(t, tt.input))
+		if err != nil {
+			t.Errorf("EvalDecimal(%s): %v", tt.input, err)
+			continue
+		}
+		if got.String() != tt.want || got.MinorUnits() != tt.units || got.Scale() != 4 {
+			t.Errorf("EvalDecimal(%s) = %s (%d units, scale %d), want %s (%d units)", tt.input, got, got.MinorUnits(), got.Scale(), tt.want, tt.units)
+		}
+	}
+	if got, _ := Eval(mustParse(t, "0.1 + 0.2 == 0.3")); got != 0 {
+		t.Errorf("Eval(0.1 + 0.2 == 0.3) = %v, want float64's 0", got)
+	}
+}
+
+func TestEvalDecimalRounding(t *testing.T) {
+	tests := []struct {
+		input                          string
+		halfUp, halfEven, down, upward string
+	}{
+		{"0.125 * 1", "0.13", "0.12", "0.12", "0.13"},
+		{"0.135 * 1", "0.14", "0.14", "0.13", "0.14"},
+		{"-0.125 * 1", "-0.13", "-0.12", "-0.12", "-0.13"},
+		{"0.121 * 1", "0.12", "0.12", "0.12", "0.13"},
+		{"-0.129 * 1", "-0.13", "-0.13", "-0.12", "-0.13"},
+		{"1 / 8", "0.13", "0.12", "0.12", "0.13"},
+		{"2 / 3", "0.67", "0.67", "0.66", "0.67"},
+		{"0.05 * 0.5", "0.03", "0.02", "0.02", "0.03"},
+	}
+	modes := []RoundingMode{RoundHalfUp, RoundHalfEven, RoundDown, RoundUp}
+	for _, tt := range tests {
+		for i, want := range []string{tt.halfUp, tt.halfEven, tt.down, tt.upward} {
+			// Literals are rounded to the scale as the results of operations are
+			ev := NewEvaluator(WithDecimalScale(2), WithRounding(modes[i]))
+			got, err := ev.EvalDecimal(mustParse(t, tt.input))
+			if err != nil {
+				t.Errorf("EvalDecimal(%s) with mode %d: %v", tt.input, modes[i], err)
+				continue
+			}
+			if got.String() != want {
+				t.Errorf("EvalDecimal(%s) with mode %d = %s, want %s", tt.input, modes[i], got, want)
+			}
+		}
+	}
+}
+
+func TestEvalDecimalLiterals(t *testing.T) {
+	expr := mustParse(t, "1.23456 + 0")
+	if got, err := EvalDecimal(expr); err != nil || got.String() != "1.2346" {
+		t.Errorf("EvalDecimal(1.23456) = %v, %v; want 1.2346", got, err)
+	}
+	strict := NewEvaluator(WithStrictDecimals())
+	if _, err := strict.EvalDecimal(expr); ErrorCode(err) != CodeUnsupported {
+		t.Errorf("EvalDecimal(1.23456) with strict decimals: error %v, want %s", err, CodeUnsupported)
+	}
+	if got, err := strict.EvalDecimal(mustParse(t, "1.2345 + 0")); err != nil || got.String() != "1.2345" {
+		t.Errorf("EvalDecimal(1.2345) with strict decimals = %v, %v; want 1.2345", got, err)
+	}
+}
+
+func TestEvalDecimalErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  error
+	}{
+		{"1 / 0", ErrDivisionByZero},
+		{"0.5 % 0", ErrModuloByZero},
+		{"900000000000000 * 100", ErrOverflow},
+		{"9 ^ 99", ErrOverflow},
+	}
+	for _, tt := range tests {
+		if _, err := EvalDecimal(mustParse(t, tt.input)); !errors.Is(err, tt.want) {
+			t.Errorf("EvalDecimal(%s): error %v, want %v", tt.input, err, tt.want)
+		}
+	}
+	zero := NewEvaluator(WithDivisionByZero(DivideByZeroValue(0)))
+	if got, err := zero.EvalDecimal(mustParse(t, "1 / 0")); err != nil || got.MinorUnits() != 0 {
+		t.Errorf("EvalDecimal(1 / 0) giving 0 = %v, %v", got, err)
+	}
+	if _, err := NewEvaluator(WithDecimalScale(19)).EvalDecimal(Num(1)); err == nil {
+		t.Error("EvalDecimal at scale 19: no error")
+	}
+}
+
+// end of file
@@ -0,0 +1,138 @@
+package expressionparser
+
+import (
+	"errors"
+	"sort"
+)
+
+// Dependencies returns which of exprs depends on which: graph maps each
+// name to the names of the other expressions its expression uses, and
+// inputs maps it to the variables it uses that name none of them, each
+// list sorted and every name of exprs a key of both. Only direct uses are
+// listed; the dependencies of a dependency are found under its own name.
+// An expression that uses itself, directly or through others, makes err
+// the *CycleError of each cycle, joined by errors.Join when there are
+// several, with graph and inputs still complete.
+func Dependencies(exprs map[string]Expr) (graph map[string][]string, inputs map[string][]string, err error) {
+	graph = make(map[string][]string, len(exprs))
+	inputs = make(map[string][]string, len(exprs))
+	for name, expr := range exprs {
+		deps, ins := []string{}, []string{}
+		for _, v := range Variables(expr) {
+			if _, ok := exprs[v]; ok {
+				deps = append(deps, v)
+			} else {
+				ins = append(ins, v)
+			}
+		}
+		sort.Strings(deps)
+		sort.Strings(ins)
+		graph[name], inputs[name] = deps, ins
+	}
+
+	var errs []error
+	for _, path := range cycles(graph) {
+		errs = append(errs, &CycleError{Path: path})
+	}
+	return graph, inputs, errors.Join(errs...)
+}
+
+// Dependencies returns the dependencies among the formulas of l, as the
+// function Dependencies does; since Define rejects cycles, there are none.
+func (l *Library) Dependencies() (graph map[string][]string, inputs map[string][]string) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	exprs := make(map[string]Expr, len(l.formulas))
+	for name, f := range l.formulas {
+		exprs[name] = f.expr
+	}
+	graph, inputs, _ = Dependencies(exprs)
+	return graph, inputs
+}
+
+// cycles returns a cycle through each strongly connected component of
+// graph that has one, as a path from its first name, in sorted order, back
+// to that name. The components are found by Tarjan's algorithm, visiting
+// names in sorted order so that the result is the same from run to run.
+func cycles(graph map[string][]string) [][]string {
+	names := make([]string, 0, len(graph))
+	for name := range graph {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	index := map[string]int{}
+	low := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var components [][]string
+	var visit func(name string)
+	visit = func(name string) {
+		index[name], low[name] = len(index), len(index)
+		stack = append(stack, name)
+		onStack[name] = true
+		for _, dep := range graph[name] {
+			if _, seen := index[dep]; !seen {
+				visit(dep)
+				low[name] = min(low[name], low[dep])
+			} else if onStack[dep] {
+				low[name] = min(low[name], index[dep])
+			}
+		}
+		if low[name] != index[name] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == name {
+				break
+			}
+		}
+		if len(component) > 1 || contains(graph[name], name) {
+			sort.Strings(component)
+			components = append(components, component)
+		}
+	}
+	for _, name := range names {
+		if _, seen := index[name]; !seen {
+			visit(name)
+		}
+	}
+
+	sort.Slice(components, func(i, j int) bool { return components[i][0] < components[j][0] })
+	paths := make([][]string, len(components))
+	for i, component := range components {
+		paths[i] = cyclePath(graph, component)
+	}
+	return paths
+}
+
+// cyclePath returns a path through graph from the first name of component,
+// a strongly connected component, back to it, staying inside component.
+func cyclePath(graph map[string][]string, component []string) []string {
+	start := component[0]
+	visited := map[string]bool{}
+	var walk func(path []string) []string
+	walk = func(path []string) []string {
+		for _, dep := range graph[path[len(path)-1]] {
+			if dep == start {
+				return append(path, dep)
+			}
+			if visited[dep] || !contains(component, dep) {
+				continue
+			}
+			visited[dep] = true
+			if found := walk(append(path, dep)); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return walk([]string{start})
+}
+
+// end of file
@@ -0,0 +1,94 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// parseAll parses each of sources.
+func parseAll(t testing.TB, sources map[string]string) map[string]Expr {
+	t.Helper()
+	exprs := make(map[string]Expr, len(sources))
+	for name, source := range sources {
+		expr, err := ParseString(source)
+		if err != nil {
+			t.Fatalf("%s = %s: %v", name, source, err)
+		}
+		exprs[name] = expr
+	}
+	return exprs
+}
+
+// diamond is a set of formulas in which left and right both use base, and
+// top uses left and right.
+var diamond = map[string]string{
+	"base":  "price * qty",
+	"left":  "base * (1 + tax)",
+	"right": "base > 100 ? base * 0.1 : 0",
+	"top":   "left - right + shipping",
+}
+
+func TestDependencies(t *testing.T) {
+	graph, inputs, err := Dependencies(parseAll(t, diamond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(graph); got != "map[base:[] left:[base] right:[base] top:[left right]]" {
+		t.Errorf("graph = %s", got)
+	}
+	if got := fmt.Sprint(inputs); got != "map[base:[price qty] left:[tax] right:[] top:[shipping]]" {
+		t.Errorf("inputs = %s", got)
+	}
+
+	// Sorted, whatever the order of use
+	graph, inputs, _ = Dependencies(parseAll(t, map[string]string{"a": "z + y + c + b", "b": "1", "c": "x"}))
+	if got := fmt.Sprint(graph["a"], inputs["a"], inputs["c"]); got != "[b c] [y z] [x]" {
+		t.Errorf("a uses %s", got)
+	}
+	if graph, _, err := Dependencies(nil); err != nil || len(graph) != 0 {
+		t.Errorf("Dependencies(nil) = %v, %v", graph, err)
+	}
+}
+
+func TestDependenciesCycles(t *testing.T) {
+	sources := map[string]string{
+		"total":    "subtotal - discount",
+		"discount": "total * 0.1",
+		"subtotal": "price * qty",
+		"self":     "self + 1",
+		"a":        "b + 1",
+		"b":        "c + 1",
+		"c":        "a + total",
+	}
+	graph, inputs, err := Dependencies(parseAll(t, sources))
+	want := "reference cycle a → b → c → a\n" +
+		"reference cycle discount → total → discount\n" +
+		"reference cycle self → self"
+	if err == nil || err.Error() != want {
+		t.Errorf("error %v, want\n%s", err, want)
+	}
+	var cycle *CycleError
+	if !errors.As(err, &cycle) {
+		t.Errorf("error %T is not a *CycleError", err)
+	}
+	// Still complete
+	if len(graph) != len(sources) || len(inputs) != len(sources) || fmt.Sprint(graph["c"]) != "[a total]" {
+		t.Errorf("graph %v, inputs %v", graph, inputs)
+	}
+}
+
+func TestLibraryDependencies(t *testing.T) {
+	l := NewLibrary()
+	for name, source := range diamond {
+		if err := l.Define(name, source); err != nil {
+			t.Fatal(err)
+		}
+	}
+	graph, inputs := l.Dependencies()
+	if got := fmt.Sprint(graph, inputs); got != "map[base:[] left:[base] right:[base] top:[left right]] map[base:[price qty] left:[tax] right:[] top:[shipping]]" {
+		t.Errorf("Dependencies() = %s", got)
+	}
+}
+
+// end of file
@@ -0,0 +1,160 @@
+package expressionparser
+
+import "fmt"
+
+// Derivatives of one-argument builtins: given the argument u, return f'(u)
+var derivativeRules = map[string]func(u Expr) Expr{
+	"sin": func(u Expr) Expr { return Call("cos", u) },
+	"cos": func(u Expr) Expr { return Neg(Call("sin", u)) },
+	"tan": func(u Expr) Expr {
+		return Div(Num(1), Pow(Call("cos", u), Num(2)))
+	},
+	"exp": func(u Expr) Expr { return Call("exp", u) },
+	"ln":  func(u Expr) Expr { return Div(Num(1), u) },
+	"log": func(u Expr) Expr { return Div(Num(1), u) },
+	"log10": func(u Expr) Expr {
+		return Div(Num(1), Mul(u, Call("ln", Num(10))))
+	},
+	"log2": func(u Expr) Expr {
+		return Div(Num(1), Mul(u, Call("ln", Num(2))))
+	},
+	"asin": func(u Expr) Expr {
+		return Div(Num(1), Call("sqrt", Sub(Num(1), Pow(u, Num(2)))))
+	},
+	"acos": func(u Expr) Expr {
+		return Neg(Div(Num(1), Call("sqrt", Sub(Num(1), Pow(u, Num(2))))))
+	},
+	"atan": func(u Expr) Expr {
+		return Div(Num(1), Add(Num(1), Pow(u, Num(2))))
+	},
+	"sqrt": func(u Expr) Expr {
+		return Div(Num(1), Mul(Num(2), Call("sqrt", u)))
+	},
+	"rad": func(u Expr) Expr { return Num(radiansPerDegree) },
+	"deg": func(u Expr) Expr { return Num(degreesPerRadian) },
+}
+
+// Derivative returns the symbolic derivative of expr with respect to the
+// named variable, simplified for readability. Other variables are treated as
+// constants. A conditional is differentiated branch by branch, giving the
+// derivative away from the points where the condition changes. Differentiating
+// through a function without a known rule, or through a comparison or logical
+// operator, is an error naming it.
+func Derivative(expr Expr, variable string) (Expr, error) {
+	d, err := derive(expr, variable)
+	if err != nil {
+		return nil, err
+	}
+
+	d = Simplify(d)
+	if folded, err := Fold(d); err == nil {
+		d = Simplify(folded)
+	}
+	return d, nil
+}
+
+// derive applies the differentiation rules without simplifying.
+func derive(expr Expr, x string) (Expr, error) {
+	if !dependsOn(expr, x) {
+		return Num(0), nil
+	}
+
+	switch v := expr.(type) {
+	case *Variable:
+		return Num(1), nil
+	case *UnaryOp:
+		if v.Op.Type != MINUS {
+			return nil, withCode(CodeUnsupported, fmt.Errorf("cannot differentiate operator %s", operatorSymbol(v.Op)))
+		}
+		du, err := derive(v.Operand, x)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryOp{Op: v.Op, Operand: du}, nil
+	case *BinaryOp:
+		du, err := derive(v.Left, x)
+		if err != nil {
+			return nil, err
+		}
+		dv, err := derive(v.Right, x)
+		if err != nil {
+			return nil, err
+		}
+		u, w := v.Left, v.Right
+
+		switch v.Op.Type {
+		case PLUS, MINUS:
+			return &BinaryOp{Left: du, Op: v.Op, Right: dv}, nil
+		case MULT:
+			// (uw)' = u'w + uw', dropping a term whose factor is symbolically zero
+			if isLiteral(du, 0) {
+				return Mul(u, dv), nil
+			}
+			if isLiteral(dv, 0) {
+				return Mul(du, w), nil
+			}
+			return Add(Mul(du, w), Mul(u, dv)), nil
+		case DIV:
+			// (u/w)' = (u'w - uw') / w^2, or u'/w when w is constant
+			if isLiteral(dv, 0) {
+				return Div(du, w), nil
+			}
+			num := Sub(Mul(du, w), Mul(u, dv))
+			return Div(num, Pow(w, Num(2))), nil
+		case POW:
+			if !dependsOn(w, x) {
+				// (u^n)' = n * u^(n-1) * u'
+				lowered := Pow(u, Sub(w, Num(1)))
+				return Mul(Mul(w, lowered), du), nil
+			}
+			if !dependsOn(u, x) {
+				// (a^w)' = a^w * ln(a) * w'
+				return Mul(Mul(expr, Call("ln", u)), dv), nil
+			}
+			// (u^w)' = u^w * (w' ln(u) + w u' / u)
+			inner := Add(Mul(dv, Call("ln", u)), Div(Mul(w, du), u))
+			return Mul(expr, inner), nil
+		}
+		return nil, withCode(CodeUnsupported, fmt.Errorf("cannot differentiate operator %s", operatorSymbol(v.Op)))
+	case *FunctionCall:
+		if v.Name == "log" && len(v.Args) == 2 {
+			return derive(Div(Call("ln", v.Args[0]), Call("ln", v.Args[1])), x)
+		}
+		rule, ok := derivativeRules[v.Name]
+		if !ok || len(v.Args) != 1 {
+			return nil, withCode(CodeUnsupported, fmt.Errorf("cannot differentiate function %s", v.Name))
+		}
+		du, err := derive(v.Args[0], x)
+		if err != nil {
+			return nil, err
+		}
+		// Chain rule: f(u)' = f'(u) * u'
+		return Mul(rule(v.Args[0]), du), nil
+	case *Conditional:
+		dt, err := derive(v.Then, x)
+		if err != nil {
+			return nil, err
+		}
+		de, err := derive(v.Else, x)
+		if err != nil {
+			return nil, err
+		}
+		return If(v.Cond, dt, de), nil
+	}
+
+	return nil, withCode(CodeUnsupported, fmt.Errorf("cannot differentiate expression of type %T", expr))
+}
+
+// dependsOn reports whether the variable occurs anywhere in expr.
+func dependsOn(expr Expr, name string) bool {
+	found := false
+	Walk(expr, func(e Expr) bool {
+		if v, ok := e.(*Variable); ok && v.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// end of file
@@ -0,0 +1,108 @@
+package expressionparser
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// centralDifference estimates the derivative of expr in x at at.
+func centralDifference(t *testing.T, expr Expr, at float64) float64 {
+	const h = 1e-6
+	above, err := EvalWithVars(expr, map[string]float64{"x": at + h, "y": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	below, err := EvalWithVars(expr, map[string]float64{"x": at - h, "y": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return (above - below) / (2 * h)
+}
+
+func TestDerivativeMatchesFiniteDifferences(t *testing.T) {
+	inputs := []string{
+		"x ^ 3 - 2 * x + 1",
+		"y * x ^ 2",
+		"x ^ y",
+		"2 ^ x",
+		"x ^ x",
+		"sin(x) * cos(x)",
+		"tan(x / 2)",
+		"exp(-x ^ 2)",
+		"ln(x) / x",
+		"log10(x) + log2(x)",
+		"sqrt(1 + x ^ 2)",
+		"asin(x / 4) + acos(x / 5) + atan(x)",
+		"1 / (1 + exp(-x))",
+		"x > 1 ? x ^ 2 : -x",
+	}
+	points := []float64{0.3, 0.7, 1.9, 3.1}
+	for _, input := range inputs {
+		expr, err := ParseString(input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		d, err := Derivative(expr, "x")
+		if err != nil {
+			t.Errorf("Derivative(%q): %v", input, err)
+			continue
+		}
+		for _, at := range points {
+			got, err := EvalWithVars(d, map[string]float64{"x": at, "y": 2})
+			if err != nil {
+				t.Errorf("Derivative(%q) = %s at x = %v: %v", input, Format(d), at, err)
+				continue
+			}
+			want := centralDifference(t, expr, at)
+			if math.Abs(got-want) > 1e-5*math.Max(1, math.Abs(want)) {
+				t.Errorf("Derivative(%q) = %s is %v at x = %v, want about %v", input, Format(d), got, at, want)
+			}
+		}
+	}
+}
+
+func TestDerivative(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"x * y + 3", "y"},
+		{"y ^ 2", "0"},
+		{"sin(x)", "cos(x)"},
+		{"x ^ 2", "2 * x"},
+	}
+	for _, tt := range tests {
+		expr, err := ParseString(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := Derivative(expr, "x")
+		if err != nil || Format(d) != tt.want {
+			t.Errorf("Derivative(%q) = %v, %v, want %s", tt.input, Format(d), err, tt.want)
+		}
+	}
+}
+
+func TestDerivativeErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"floor(x)", "floor"},
+		{"x < 1", "<"},
+		{"x && y", "&&"},
+		{"let a = x in a", "Let"},
+	}
+	for _, tt := range tests {
+		expr, err := ParseString(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Derivative(expr, "x"); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Derivative(%q): %v, want an error naming %s", tt.input, err, tt.want)
+		}
+	}
+}
+
+// end of file
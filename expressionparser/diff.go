@@ -0,0 +1,171 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ChangeKind classifies a difference reported by Diff.
+type ChangeKind int
+
+const (
+	NodeReplaced    ChangeKind = iota // a subtree was replaced by a different kind of node
+	ValueChanged                      // a literal value or a variable name changed
+	OperatorChanged                   // an operator changed while its operands were kept
+	OperandAdded                      // a call gained an argument or a list an element
+	OperandRemoved                    // a call lost an argument or a list an element
+)
+
+// Names of the change kinds
+var changeKindNames = map[ChangeKind]string{
+	NodeReplaced:    "replaced",
+	ValueChanged:    "value changed",
+	OperatorChanged: "operator changed",
+	OperandAdded:    "operand added",
+	OperandRemoved:  "operand removed",
+}
+
+// String returns a readable name for the kind.
+func (k ChangeKind) String() string {
+	if name, ok := changeKindNames[k]; ok {
+		return name
+	}
+	return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Change is one difference between two trees. Path locates the node from the
+// root as dot-separated steps (left, right, operand, args[i], elems[i],
+// value, body, cond, then, else);
+// the root itself has an empty path. Old and New are the infix renderings of
+// the affected nodes, empty when the node does not exist on that side.
+type Change struct {
+	Path string
+	Kind ChangeKind
+	Old  string
+	New  string
+}
+
+// String renders the change on one line.
+func (c Change) String() string {
+	path := c.Path
+	if path == "" {
+		path = "<root>"
+	}
+	return fmt.Sprintf("%s: %s %q -> %q", path, c.Kind, c.Old, c.New)
+}
+
+// Diff returns the structural differences between a and b, in pre-order.
+// Equal trees give an empty slice; trees whose roots differ in kind give a
+// single root-level replacement.
+func Diff(a, b Expr) []Change {
+	changes := []Change{}
+	diffNode(a, b, "", &changes)
+	return changes
+}
+
+// childPath joins a parent path and a step.
+func childPath(path, step string) string {
+	if path == "" {
+		return step
+	}
+	return path + "." + step
+}
+
+// diffNode appends the differences between a and b found at path.
+func diffNode(a, b Expr, path string, changes *[]Change) {
+	if Equal(a, b) {
+		return
+	}
+
+	replaced := func() {
+		*changes = append(*changes, Change{Path: path, Kind: NodeReplaced, Old: Format(a), New: Format(b)})
+	}
+
+	switch x := a.(type) {
+	case *Number:
+		if _, ok := b.(*Number); ok {
+			*changes = append(*changes, Change{Path: path, Kind: ValueChanged, Old: Format(a), New: Format(b)})
+			return
+		}
+	case *QuantityLiteral:
+		if _, ok := b.(*QuantityLiteral); ok {
+			*changes = append(*changes, Change{Path: path, Kind: ValueChanged, Old: Format(a), New: Format(b)})
+			return
+		}
+	case *StringLiteral:
+		if _, ok := b.(*StringLiteral); ok {
+			*changes = append(*changes, Change{Path: path, Kind: ValueChanged, Old: Format(a), New: Format(b)})
+			return
+		}
+	case *Variable:
+		if _, ok := b.(*Variable); ok {
+			*changes = append(*changes, Change{Path: path, Kind: ValueChanged, Old: Format(a), New: Format(b)})
+			return
+		}
+	case *BinaryOp:
+		if y, ok := b.(*BinaryOp); ok {
+			if x.Op.Type != y.Op.Type {
+				*changes = append(*changes, Change{Path: path, Kind: OperatorChanged, Old: operatorSymbol(x.Op), New: operatorSymbol(y.Op)})
+			}
+			diffNode(x.Left, y.Left, childPath(path, "left"), changes)
+			diffNode(x.Right, y.Right, childPath(path, "right"), changes)
+			return
+		}
+	case *UnaryOp:
+		if y, ok := b.(*UnaryOp); ok {
+			if x.Op.Type != y.Op.Type {
+				*changes = append(*changes, Change{Path: path, Kind: OperatorChanged, Old: operatorSymbol(x.Op), New: operatorSymbol(y.Op)})
+			}
+			diffNode(x.Operand, y.Operand, childPath(path, "operand"), changes)
+			return
+		}
+	case *FunctionCall:
+		if y, ok := b.(*FunctionCall); ok && x.Name == y.Name {
+			diffOperands(x.Args, y.Args, path, "args", changes)
+			return
+		}
+	case *ListLiteral:
+		if y, ok := b.(*ListLiteral); ok {
+			diffOperands(x.Elems, y.Elems, path, "elems", changes)
+			return
+		}
+	case *Let:
+		if y, ok := b.(*Let); ok && x.Name == y.Name {
+			diffNode(x.Value, y.Value, childPath(path, "value"), changes)
+			diffNode(x.Body, y.Body, childPath(path, "body"), changes)
+			return
+		}
+	case *Assign:
+		if y, ok := b.(*Assign); ok && x.Name == y.Name {
+			diffNode(x.Value, y.Value, childPath(path, "value"), changes)
+			return
+		}
+	case *Conditional:
+		if y, ok := b.(*Conditional); ok {
+			diffNode(x.Cond, y.Cond, childPath(path, "cond"), changes)
+			diffNode(x.Then, y.Then, childPath(path, "then"), changes)
+			diffNode(x.Else, y.Else, childPath(path, "else"), changes)
+			return
+		}
+	}
+
+	replaced()
+}
+
+// diffOperands appends the differences between the arguments of two calls,
+// or the elements of two lists, found under path at field.
+func diffOperands(xs, ys []Expr, path, field string, changes *[]Change) {
+	for i := 0; i < len(xs) || i < len(ys); i++ {
+		step := childPath(path, field+"["+strconv.Itoa(i)+"]")
+		switch {
+		case i >= len(ys):
+			*changes = append(*changes, Change{Path: step, Kind: OperandRemoved, Old: Format(xs[i])})
+		case i >= len(xs):
+			*changes = append(*changes, Change{Path: step, Kind: OperandAdded, New: Format(ys[i])})
+		default:
+			diffNode(xs[i], ys[i], step, changes)
+		}
+	}
+}
+
+// end of file
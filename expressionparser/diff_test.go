@@ -0,0 +1,76 @@
+package expressionparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []Change
+	}{
+		{"x * (y + max(1, 2))", "x * (y + max(1, 3))", []Change{
+			{Path: "right.right.args[1]", Kind: ValueChanged, Old: "2", New: "3"},
+		}},
+		{"(a + 1) * b", "(a + 1) / b", []Change{
+			{Path: "", Kind: OperatorChanged, Old: "*", New: "/"},
+		}},
+		{"(a + 1) * b", "(a - 2) / b", []Change{
+			{Path: "", Kind: OperatorChanged, Old: "*", New: "/"},
+			{Path: "left", Kind: OperatorChanged, Old: "+", New: "-"},
+			{Path: "left.right", Kind: ValueChanged, Old: "1", New: "2"},
+		}},
+		{"a + b", "f(a, b)", []Change{
+			{Path: "", Kind: NodeReplaced, Old: "a + b", New: "f(a, b)"},
+		}},
+		{"f(a, b)", "g(a, b)", []Change{
+			{Path: "", Kind: NodeReplaced, Old: "f(a, b)", New: "g(a, b)"},
+		}},
+		{"f(a)", "f(a, b + 1)", []Change{
+			{Path: "args[1]", Kind: OperandAdded, New: "b + 1"},
+		}},
+		{"[1, 2, 3]", "[1]", []Change{
+			{Path: "elems[1]", Kind: OperandRemoved, Old: "2"},
+			{Path: "elems[2]", Kind: OperandRemoved, Old: "3"},
+		}},
+		{"let a = x in -a", "let a = x in !a", []Change{
+			{Path: "body", Kind: OperatorChanged, Old: "-", New: "!"},
+		}},
+		{"c ? x : y", "c ? x : z", []Change{
+			{Path: "else", Kind: ValueChanged, Old: "y", New: "z"},
+		}},
+	}
+	for _, tt := range tests {
+		got := Diff(mustParse(t, tt.a), mustParse(t, tt.b))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Diff(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	// Spans differ, trees do not
+	got := Diff(mustParse(t, "x*(y+1)"), mustParse(t, "x * (y + 1)"))
+	if got == nil || len(got) != 0 {
+		t.Errorf("Diff of equal trees = %#v, want an empty slice", got)
+	}
+}
+
+func TestChangeString(t *testing.T) {
+	tests := []struct {
+		change Change
+		want   string
+	}{
+		{Change{Kind: OperatorChanged, Old: "*", New: "/"}, `<root>: operator changed "*" -> "/"`},
+		{Change{Path: "args[1]", Kind: OperandAdded, New: "b"}, `args[1]: operand added "" -> "b"`},
+		{Change{Path: "left", Kind: ChangeKind(9)}, `left: ChangeKind(9) "" -> ""`},
+	}
+	for _, tt := range tests {
+		if got := tt.change.String(); got != tt.want {
+			t.Errorf("String() = %s, want %s", got, tt.want)
+		}
+	}
+}
+
+// end of file
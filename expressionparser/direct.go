@@ -0,0 +1,245 @@
+package expressionparser
+
+// directOp is an entry of the operator stack of EvaluateDirect: a binary
+// or prefix operator, an open parenthesis, or a call with its arguments so
+// far.
+type directOp struct {
+	tok   Token
+	kind  directKind
+	prec  int
+	fn    builtin // of a call
+	nargs int     // of a call
+}
+
+// directKind tells the entries of the operator stack apart.
+type directKind int
+
+const (
+	directBinary directKind = iota
+	directPrefix
+	directParen
+	directCall
+)
+
+// EvaluateDirect evaluates input with vars as EvaluateWithVars does, with
+// the same result, but without building a tree: numbers, variables,
+// parentheses, the prefix and binary operators other than .. and calls of
+// the numeric builtins are evaluated straight off the tokens with a stack
+// of operands and one of operators, by the shunting-yard algorithm. For an
+// expression of ordinary size that allocates nothing but, when it calls
+// functions, a buffer for their arguments. Input using any other part of
+// the language, such as ?:, strings, lists or let, and input that fails to
+// parse or evaluate, is handed to EvaluateWithVars instead, so that the
+// errors are those of Parse and Eval, positions included. It suits input
+// evaluated once and thrown away; input evaluated more than once is better
+// compiled.
+func EvaluateDirect(input string, vars map[string]float64) (float64, error) {
+	if result, ok := evaluateDirect(input, vars); ok {
+		return result, nil
+	}
+	return EvaluateWithVars(input, vars)
+}
+
+// evaluateDirect evaluates input by the shunting-yard algorithm, reporting
+// false for input it does not handle or that fails, which EvaluateWithVars
+// then evaluates again.
+func evaluateDirect(input string, vars map[string]float64) (float64, bool) {
+	var (
+		lexer      Lexer
+		operandBuf [32]float64
+		opBuf      [32]directOp
+		args       []float64 // of calls, allocated at the first
+	)
+	operands, ops := operandBuf[:0], opBuf[:0]
+
+	// reduce applies the operator on top of the stack to its operands
+	reduce := func() bool {
+		op := ops[len(ops)-1]
+		ops = ops[:len(ops)-1]
+		switch op.kind {
+		case directPrefix:
+			if len(operands) < 1 {
+				return false
+			}
+			x := &operands[len(operands)-1]
+			if op.tok.Type == MINUS {
+				*x = -*x
+			} else {
+				*x = truth(!isTrue(*x))
+			}
+			return true
+		case directBinary:
+			if len(operands) < 2 {
+				return false
+			}
+			a, b := operands[len(operands)-2], operands[len(operands)-1]
+			operands = operands[:len(operands)-1]
+			var result float64
+			switch op.tok.Type {
+			case AND:
+				result = truth(isTrue(a) && isTrue(b))
+			case OR:
+				result = truth(isTrue(a) || isTrue(b))
+			default:
+				if isDivision(op.tok) && b == 0 {
+					return false // for the division by zero policy
+				}
+				value, err := defaultEvaluator.binaryValue(op.tok, op.tok.Pos, NumberValue(a), NumberValue(b))
+				if err != nil {
+					return false
+				}
+				result = value.num
+			}
+			operands[len(operands)-1] = result
+			return true
+		}
+		return false
+	}
+
+	// call applies the function of a call to its arguments
+	call := func(op directOp) bool {
+		if op.fn.checkArgs(op.tok.Value, op.nargs) != nil || len(operands) < op.nargs {
+			return false
+		}
+		// The arguments are copied, since a slice given to fn escapes
+		args = append(args[:0], operands[len(operands)-op.nargs:]...)
+		if op.fn.domain != nil && op.fn.domain(args) >= 0 {
+			return false
+		}
+		result, err := op.fn.fn(args)
+		if err != nil {
+			return false
+		}
+		operands = append(operands[:len(operands)-op.nargs], result)
+		return true
+	}
+
+	lexer.Reset(input)
+	tok, next := lexer.NextToken(), lexer.NextToken()
+	advance := func() {
+		tok, next = next, lexer.NextToken()
+	}
+	operand := true // whether an operand is expected
+	for {
+		if operand {
+			switch tok.Type {
+			case NUMBER:
+				value, err := parseNumber(tok.Value)
+				if err != nil {
+					return 0, false // an imaginary literal, or out of range
+				}
+				operands = append(operands, value)
+				operand = false
+			case IDENT:
+				if isKeyword(tok.Value) {
+					return 0, false
+				}
+				if next.Type != LPAREN {
+					value, ok := vars[tok.Value]
+					if !ok {
+						return 0, false
+					}
+					operands = append(operands, value)
+					operand = false
+					break
+				}
+				fn, ok := directBuiltin(tok.Value)
+				if !ok {
+					return 0, false
+				}
+				ops = append(ops, directOp{tok: tok, kind: directCall, fn: fn})
+				advance()
+				if next.Type == RPAREN {
+					advance()
+					op := ops[len(ops)-1]
+					ops = ops[:len(ops)-1]
+					if !call(op) {
+						return 0, false
+					}
+					operand = false
+				}
+			case MINUS, NOT:
+				ops = append(ops, directOp{tok: tok, kind: directPrefix, prec: precUnary})
+			case LPAREN:
+				ops = append(ops, directOp{tok: tok, kind: directParen})
+			default:
+				return 0, false
+			}
+			advance()
+			continue
+		}
+
+		switch tok.Type {
+		case EOF:
+			for len(ops) > 0 {
+				if k := ops[len(ops)-1].kind; k == directParen || k == directCall || !reduce() {
+					return 0, false
+				}
+			}
+			if len(operands) != 1 {
+				return 0, false
+			}
+			return operands[0], true
+		case RPAREN, COMMA:
+			for len(ops) > 0 && ops[len(ops)-1].kind != directParen && ops[len(ops)-1].kind != directCall {
+				if !reduce() {
+					return 0, false
+				}
+			}
+			if len(ops) == 0 {
+				return 0, false
+			}
+			top := &ops[len(ops)-1]
+			switch {
+			case tok.Type == COMMA && top.kind == directCall:
+				top.nargs++
+				operand = true
+			case tok.Type == RPAREN && top.kind == directParen:
+				ops = ops[:len(ops)-1]
+			case tok.Type == RPAREN && top.kind == directCall:
+				top.nargs++
+				op := *top
+				ops = ops[:len(ops)-1]
+				if !call(op) {
+					return 0, false
+				}
+			default:
+				return 0, false
+			}
+		case DOTDOT:
+			return 0, false
+		default:
+			prec := precedence(tok.Type)
+			if prec == precLowest {
+				return 0, false
+			}
+			// Pop what binds tighter, and what binds as tight unless
+			// right associative, as ^ is
+			for len(ops) > 0 {
+				top := ops[len(ops)-1]
+				if top.kind != directBinary && top.kind != directPrefix || top.prec < prec || top.prec == prec && tok.Type == POW {
+					break
+				}
+				if !reduce() {
+					return 0, false
+				}
+			}
+			ops = append(ops, directOp{tok: tok, kind: directBinary, prec: prec})
+			operand = true
+		}
+		advance()
+	}
+}
+
+// directBuiltin returns the numeric builtin name calls, unless a call of it
+// is one EvaluateDirect leaves to Eval: a string function, an aggregate, a
+// special form or one whose result may differ between calls.
+func directBuiltin(name string) (builtin, bool) {
+	b, ok := builtins[name]
+	_, isString := stringBuiltins[name]
+	_, isAggregate := aggregates[name]
+	_, isForm := specialForms[name]
+	return b, ok && !isString && !isAggregate && !isForm && !b.impure
+}
+
+// end of file
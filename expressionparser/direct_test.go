@@ -0,0 +1,51 @@
+package expressionparser
+
+import "testing"
+
+func TestEvaluateDirect(t *testing.T) {
+	inputs := append([]string{
+		// Fall back to Parse and Eval
+		"x > 0 ? 1 : 2", `"a" + "b"`, "sum([1, 2, 3])", "let a = 2 in a * x", "1..3",
+		// Syntax errors, positions included
+		"2 +", "(1 + 2", "1 + 2)", "sqrt(", "3 $ 4", "max(1,)", "",
+		"2 * -x", "--x", "!zero", "-2 ^ 2", "2 ^ -1", "sqrt(x) * min(x, y, n)",
+	}, evalCorpus...)
+	for _, input := range inputs {
+		want, wantErr := EvaluateWithVars(input, corpusVars)
+		got, gotErr := EvaluateDirect(input, corpusVars)
+		if !sameResult(got, gotErr, want, wantErr) {
+			t.Errorf("EvaluateDirect(%q) = %v, %v; EvaluateWithVars gives %v, %v", input, got, gotErr, want, wantErr)
+		}
+	}
+}
+
+func TestEvaluateDirectAllocs(t *testing.T) {
+	for _, input := range []string{"x * x + 3 * x * y - n / (1 + x)", "-(x - y) ^ 2 % 7"} {
+		if allocs := testing.AllocsPerRun(100, func() { EvaluateDirect(input, corpusVars) }); allocs != 0 {
+			t.Errorf("EvaluateDirect(%s) allocates %v times", input, allocs)
+		}
+	}
+}
+
+// directBenchmark is an expression for the benchmarks of EvaluateDirect.
+const directBenchmark = "x * x + 3 * x * y - sqrt(n) / (1 + max(x, y))"
+
+func BenchmarkEvaluateDirect(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := EvaluateDirect(directBenchmark, corpusVars); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvaluateWithVars(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := EvaluateWithVars(directBenchmark, corpusVars); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// end of file
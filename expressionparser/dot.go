@@ -0,0 +1,82 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ToDot renders an expression tree as a Graphviz digraph. Every AST node gets
+// its own id (n0, n1, ... in pre-order) so equal literals stay distinct, and
+// edges are emitted left to right. The output is stable for a given tree.
+func ToDot(expr Expr) string {
+	d := &dotWriter{}
+	d.sb.WriteString("digraph expr {\n")
+	d.sb.WriteString("\tnode [shape=box];\n")
+	if expr != nil {
+		d.node(expr)
+	}
+	d.sb.WriteString("}\n")
+	return d.sb.String()
+}
+
+// dotWriter accumulates DOT output and hands out node ids.
+type dotWriter struct {
+	sb   strings.Builder
+	next int
+}
+
+// node writes expr and its subtree, returning the id assigned to expr.
+func (d *dotWriter) node(expr Expr) string {
+	id := "n" + strconv.Itoa(d.next)
+	d.next++
+
+	var label string
+	switch v := expr.(type) {
+	case *Number:
+		label = literalText(v)
+	case *Variable:
+		label = v.Name
+	case *CellRef, *CellRange:
+		label = Format(v)
+	case *QuantityLiteral:
+		label = Format(v)
+	case *StringLiteral:
+		label = strconv.Quote(v.Value)
+	case *BinaryOp:
+		label = operatorSymbol(v.Op)
+	case *UnaryOp:
+		label = "neg"
+		if v.Op.Type != MINUS {
+			label = operatorSymbol(v.Op)
+		}
+	case *FunctionCall:
+		label = v.Name + "()"
+	case *ListLiteral:
+		label = "[]"
+	case *Let:
+		label = "let " + v.Name
+	case *Assign:
+		label = v.Name + " ="
+	case *Conditional:
+		label = "?:"
+	default:
+		label = fmt.Sprintf("%T", expr)
+	}
+
+	fmt.Fprintf(&d.sb, "\t%s [label=%s];\n", id, dotQuote(label))
+	for _, child := range Children(expr) {
+		childID := d.node(child)
+		fmt.Fprintf(&d.sb, "\t%s -> %s;\n", id, childID)
+	}
+	return id
+}
+
+// dotQuote returns s as a double-quoted DOT string.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// end of file
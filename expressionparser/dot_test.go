@@ -0,0 +1,46 @@
+package expressionparser
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// dotStatement matches the statements ToDot writes within the digraph: a
+// node with a quoted label or an edge.
+var dotStatement = regexp.MustCompile(`^\t(node \[shape=box\]|n\d+ \[label="(\\.|[^"\\])*"\]|n\d+ -> n\d+);$`)
+
+// dotDump renders the parse of input with ToDot, checking that every line
+// is valid DOT.
+func dotDump(t *testing.T, input string) string {
+	expr, err := ParseString(input)
+	if err != nil {
+		t.Fatalf("ParseString(%q): %v", input, err)
+	}
+	dot := ToDot(expr)
+	if dot != ToDot(expr) {
+		t.Errorf("ToDot(%q) differs between calls", input)
+	}
+	lines := strings.Split(strings.TrimSuffix(dot, "\n"), "\n")
+	if lines[0] != "digraph expr {" || lines[len(lines)-1] != "}" {
+		t.Errorf("ToDot(%q) is not a digraph:\n%s", input, dot)
+	}
+	for _, line := range lines[1 : len(lines)-1] {
+		if !dotStatement.MatchString(line) {
+			t.Errorf("ToDot(%q): invalid statement %q", input, line)
+		}
+	}
+	return dot
+}
+
+func TestToDotGolden(t *testing.T) {
+	runGolden(t, "dot", func(input string) string { return dotDump(t, input) })
+}
+
+func TestToDotEmpty(t *testing.T) {
+	if got, want := ToDot(nil), "digraph expr {\n\tnode [shape=box];\n}\n"; got != want {
+		t.Errorf("ToDot(nil) = %q, want %q", got, want)
+	}
+}
+
+// end of file
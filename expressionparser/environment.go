@@ -0,0 +1,99 @@
+package expressionparser
+
+import (
+	"context"
+	"sort"
+)
+
+// Environment is a layer of variable bindings over an optional parent, such
+// as per-request values over per-tenant settings over global constants.
+// Get looks a name up in the innermost layer first and then in each
+// parent in turn, so a binding shadows those of the same name further out;
+// Set only ever writes to the layer it is called on.
+//
+// An environment is safe for concurrent use by any number of evaluations
+// once its bindings and those of its parents are set, since evaluation only
+// reads it. Set is not safe to call concurrently with Get or with itself.
+type Environment struct {
+	parent *Environment
+	vars   map[string]Value
+	data   map[string]interface{} // set by EnvFromData
+}
+
+// NewEnvironment returns an empty environment over parent, which may be nil.
+func NewEnvironment(parent *Environment) *Environment {
+	return &Environment{parent: parent, vars: map[string]Value{}}
+}
+
+// Parent returns the environment that e falls back on, or nil.
+func (e *Environment) Parent() *Environment {
+	return e.parent
+}
+
+// Get returns the value bound to name in e or, failing that, in the nearest
+// of its parents that binds it.
+func (e *Environment) Get(name string) (Value, bool) {
+	for ; e != nil; e = e.parent {
+		if value, ok := e.vars[name]; ok {
+			return value, true
+		}
+		if e.data != nil {
+			if value, found, err := resolvePath(e.data, name); found && err == nil {
+				return value, true
+			}
+		}
+	}
+	return Value{}, false
+}
+
+// Set binds name to value in e itself, shadowing any binding of the name in
+// its parents and leaving them unchanged.
+func (e *Environment) Set(name string, value Value) {
+	e.vars[name] = value
+}
+
+// Names returns the names bound in e and its parents, sorted, each once
+// however many layers bind it. Of the data of EnvFromData, only the keys
+// at the top are listed.
+func (e *Environment) Names() []string {
+	seen := map[string]bool{}
+	var names []string
+	for ; e != nil; e = e.parent {
+		for name := range e.vars {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		for name := range e.data {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetNumber binds name to a number in e, as Set does.
+func (e *Environment) SetNumber(name string, value float64) {
+	e.Set(name, NumberValue(value))
+}
+
+// EvalIn evaluates an expression with the default Evaluator, resolving
+// variables that are not bound by let from env.
+func EvalIn(expr Expr, env *Environment) (Value, error) {
+	return defaultEvaluator.EvalIn(expr, env)
+}
+
+// EvalIn evaluates expr like EvalValue, resolving variables that are not
+// bound by let from env and its parents first and then from those set on
+// the evaluator, which act as the outermost layer. Each let binds its name
+// in a temporary layer over env for its body alone, so env itself is never
+// written to and may be shared between evaluations.
+func (ev *Evaluator) EvalIn(expr Expr, env *Environment) (Value, error) {
+	return ev.evalValueContext(context.Background(), expr, env)
+}
+
+// end of file
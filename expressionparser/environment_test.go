@@ -0,0 +1,147 @@
+package expressionparser
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// layers returns global constants, tenant settings over them and request
+// values over those.
+func layers() (global, tenant, request *Environment) {
+	global = NewEnvironment(nil)
+	global.SetNumber("pi", 3.14159)
+	global.SetNumber("rate", 0.1)
+	global.SetNumber("limit", 100)
+	tenant = NewEnvironment(global)
+	tenant.SetNumber("rate", 0.2)
+	tenant.Set("currency", StringValue("EUR"))
+	request = NewEnvironment(tenant)
+	request.SetNumber("amount", 50)
+	request.SetNumber("limit", 75)
+	return global, tenant, request
+}
+
+func TestEnvironmentChain(t *testing.T) {
+	global, tenant, request := layers()
+	tests := []struct {
+		env   *Environment
+		name  string
+		want  Value
+		found bool
+	}{
+		{request, "amount", NumberValue(50), true},
+		{request, "limit", NumberValue(75), true},
+		{request, "rate", NumberValue(0.2), true},
+		{request, "pi", NumberValue(3.14159), true},
+		{request, "currency", StringValue("EUR"), true},
+		{tenant, "limit", NumberValue(100), true},
+		{tenant, "amount", Value{}, false},
+		{global, "rate", NumberValue(0.1), true},
+		{global, "currency", Value{}, false},
+	}
+	for _, tt := range tests {
+		got, found := tt.env.Get(tt.name)
+		if found != tt.found || found && got.String() != tt.want.String() {
+			t.Errorf("Get(%s) = %v, %v; want %v, %v", tt.name, got, found, tt.want, tt.found)
+		}
+	}
+	if request.Parent() != tenant || tenant.Parent() != global || global.Parent() != nil {
+		t.Error("Parent does not give the layer below")
+	}
+	want := []string{"amount", "currency", "limit", "pi", "rate"}
+	if got := request.Names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Names = %q, want %q", got, want)
+	}
+
+	// Set writes to its own layer only
+	request.SetNumber("rate", 0.5)
+	if got, _ := tenant.Get("rate"); got.num != 0.2 {
+		t.Errorf("tenant rate = %v after setting the request's, want 0.2", got)
+	}
+	got, err := EvalIn(mustParse(t, "amount * rate + limit"), request)
+	if err != nil || got.num != 50*0.5+75 {
+		t.Errorf("amount * rate + limit = %v, %v; want %v", got, err, 50*0.5+75)
+	}
+}
+
+func TestEvalInLet(t *testing.T) {
+	_, _, request := layers()
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"amount * (1 + rate)", 60},
+		{"let rate = 0.5 in amount * (1 + rate)", 75},
+		{"let amount = 10 in let amount = amount * 2 in amount + limit", 95},
+		{"(let limit = 1 in limit) + limit", 76},
+	}
+	for _, tt := range tests {
+		got, err := EvalIn(mustParse(t, tt.input), request)
+		if err != nil || got.num != tt.want {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+	// let binds in a layer of its own, leaving the environment as it was
+	if got, _ := request.Get("rate"); got.num != 0.2 {
+		t.Errorf("rate = %v after the lets, want 0.2", got)
+	}
+	if got := request.Names(); len(got) != 5 {
+		t.Errorf("Names after the lets = %q", got)
+	}
+
+	// An evaluator's own variables are the outermost layer
+	ev := NewEvaluator()
+	ev.SetVar("bonus", 5)
+	ev.SetVar("amount", 1)
+	if got, err := ev.EvalIn(mustParse(t, "amount + bonus"), request); err != nil || got.num != 55 {
+		t.Errorf("amount + bonus = %v, %v; want 55", got, err)
+	}
+}
+
+func TestEvalProgramAssigns(t *testing.T) {
+	_, tenant, request := layers()
+	stmts, err := ParseProgram("rate = rate * 2; total = amount * rate; total + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := EvalProgram(stmts, request)
+	if err != nil || got != 21 {
+		t.Errorf("program = %v, %v; want 21", got, err)
+	}
+	// Assignments shadow the tenant's rate in the request's layer
+	if rate, _ := request.Get("rate"); rate.num != 0.4 {
+		t.Errorf("request rate = %v, want 0.4", rate)
+	}
+	if rate, _ := tenant.Get("rate"); rate.num != 0.2 {
+		t.Errorf("tenant rate = %v, want 0.2", rate)
+	}
+}
+
+func TestEnvironmentConcurrentReads(t *testing.T) {
+	_, _, shared := layers()
+	expr := mustParse(t, "let x = amount * rate in x + limit + pi")
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			own := NewEnvironment(shared)
+			own.SetNumber("amount", float64(g))
+			for i := 0; i < 100; i++ {
+				got, err := EvalIn(expr, own)
+				if want := float64(g)*0.2 + 75 + 3.14159; err != nil || got.num != want {
+					t.Errorf("goroutine %d = %v, %v; want %v", g, got, err, want)
+					return
+				}
+				if _, err := EvalIn(expr, shared); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// end of file
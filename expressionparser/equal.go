@@ -0,0 +1,70 @@
+package expressionparser
+
+// Equal reports whether two trees are structurally identical: the same node
+// kinds, operator token types (token text is ignored), variable and function
+// names, string values, whether numbers are imaginary, units, and recursively equal children. Number values are compared with ==,
+// so 0 equals -0 and NaN never equals anything. Two nil trees are equal.
+func Equal(a, b Expr) bool {
+	switch x := a.(type) {
+	case nil:
+		return b == nil
+	case *Number:
+		y, ok := b.(*Number)
+		return ok && x.Value == y.Value && x.Imag == y.Imag
+	case *Variable:
+		y, ok := b.(*Variable)
+		return ok && x.Name == y.Name
+	case *CellRef:
+		y, ok := b.(*CellRef)
+		return ok && x.Ref == y.Ref
+	case *CellRange:
+		y, ok := b.(*CellRange)
+		return ok && x.From == y.From && x.To == y.To
+	case *QuantityLiteral:
+		y, ok := b.(*QuantityLiteral)
+		return ok && x.Value == y.Value && x.Unit == y.Unit
+	case *StringLiteral:
+		y, ok := b.(*StringLiteral)
+		return ok && x.Value == y.Value
+	case *BinaryOp:
+		y, ok := b.(*BinaryOp)
+		return ok && x.Op.Type == y.Op.Type && Equal(x.Left, y.Left) && Equal(x.Right, y.Right)
+	case *UnaryOp:
+		y, ok := b.(*UnaryOp)
+		return ok && x.Op.Type == y.Op.Type && Equal(x.Operand, y.Operand)
+	case *FunctionCall:
+		y, ok := b.(*FunctionCall)
+		if !ok || x.Name != y.Name || len(x.Args) != len(y.Args) {
+			return false
+		}
+		for i := range x.Args {
+			if !Equal(x.Args[i], y.Args[i]) {
+				return false
+			}
+		}
+		return true
+	case *ListLiteral:
+		y, ok := b.(*ListLiteral)
+		if !ok || len(x.Elems) != len(y.Elems) {
+			return false
+		}
+		for i := range x.Elems {
+			if !Equal(x.Elems[i], y.Elems[i]) {
+				return false
+			}
+		}
+		return true
+	case *Let:
+		y, ok := b.(*Let)
+		return ok && x.Name == y.Name && Equal(x.Value, y.Value) && Equal(x.Body, y.Body)
+	case *Assign:
+		y, ok := b.(*Assign)
+		return ok && x.Name == y.Name && Equal(x.Value, y.Value)
+	case *Conditional:
+		y, ok := b.(*Conditional)
+		return ok && Equal(x.Cond, y.Cond) && Equal(x.Then, y.Then) && Equal(x.Else, y.Else)
+	}
+	return false
+}
+
+// end of file
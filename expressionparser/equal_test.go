@@ -0,0 +1,85 @@
+package expressionparser
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEqualNil(t *testing.T) {
+	if !Equal(nil, nil) {
+		t.Error("Equal(nil, nil) = false")
+	}
+	if Equal(nil, Num(0)) || Equal(Num(0), nil) {
+		t.Error("a nil tree equals a number")
+	}
+	if Equal(Add(Num(1), nil), Add(Num(1), Num(0))) {
+		t.Error("a nil operand equals a number")
+	}
+}
+
+func TestEqualIgnoresParentheses(t *testing.T) {
+	pairs := [][2]string{
+		{"(2 + 3) * 5", "((2 + 3)) * (5)"},
+		{"2 + 3 * 5", "2 + (3 * 5)"},
+		{"(2 - 3) - 4", "2 - 3 - 4"},
+		{"-(x)", "-x"},
+		{"f((x), [(1)])", "f(x, [1])"},
+		{"1.0 + 2e0", "1 + 2"},
+	}
+	for _, pair := range pairs {
+		a, err := ParseString(pair[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseString(pair[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Equal(a, b) {
+			t.Errorf("Equal(%q, %q) = false", pair[0], pair[1])
+		}
+		if !Equal(a, Clone(b)) {
+			t.Errorf("Equal(%q, Clone(%q)) = false", pair[0], pair[1])
+		}
+	}
+}
+
+func TestEqualIgnoresSpansAndTokenText(t *testing.T) {
+	parsed, err := ParseString("x   +  2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	built := &BinaryOp{Left: Var("x"), Op: Token{Type: PLUS}, Right: Num(2)}
+	if !Equal(parsed, built) {
+		t.Errorf("Equal(%s, %s) = false", ToSExpr(parsed), ToSExpr(built))
+	}
+}
+
+func TestEqualDifferences(t *testing.T) {
+	pairs := [][2]Expr{
+		{Num(1), Num(2)},
+		{Num(1), &Number{Value: 1, Imag: true}},
+		{Num(math.NaN()), Num(math.NaN())},
+		{Var("x"), Var("y")},
+		{Var("x"), &StringLiteral{Value: "x"}},
+		{Add(Num(1), Num(2)), Sub(Num(1), Num(2))},
+		{Sub(Num(1), Num(2)), Sub(Num(2), Num(1))},
+		{Neg(Var("x")), Not(Var("x"))},
+		{Call("max", Num(1)), Call("min", Num(1))},
+		{Call("max", Num(1)), Call("max", Num(1), Num(2))},
+		{List(Num(1)), List(Num(1), Num(1))},
+		{&QuantityLiteral{Value: 1, Unit: "m"}, &QuantityLiteral{Value: 1, Unit: "km"}},
+		{&Let{Name: "a", Value: Num(1), Body: Var("a")}, &Let{Name: "b", Value: Num(1), Body: Var("a")}},
+		{&Assign{Name: "a", Value: Num(1)}, &Assign{Name: "a", Value: Num(2)}},
+		{If(Var("c"), Num(1), Num(2)), If(Var("c"), Num(2), Num(1))},
+		{&CellRef{Ref: "A1"}, &CellRef{Ref: "A2"}},
+		{&CellRange{From: "A1", To: "A2"}, &CellRange{From: "A1", To: "A3"}},
+	}
+	for _, pair := range pairs {
+		if Equal(pair[0], pair[1]) || Equal(pair[1], pair[0]) {
+			t.Errorf("Equal(%s, %s) = true", ToSExpr(pair[0]), ToSExpr(pair[1]))
+		}
+	}
+}
+
+// end of file
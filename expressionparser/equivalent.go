@@ -0,0 +1,112 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// CounterExample is a binding of the variables on which two expressions
+// compared by Equivalent differ, with the result or error of each.
+type CounterExample struct {
+	Vars map[string]float64
+	A, B float64
+	ErrA error
+	ErrB error
+}
+
+// equivalentTolerance is the relative difference below which Equivalent
+// takes two results to be equal, allowing for rounding in expressions that
+// are algebraically equal, such as x*(y+z) and x*y+x*z.
+const equivalentTolerance = 1e-9
+
+// specialValues are the values Equivalent tries the variables with besides
+// random ones, those on which rewrites most often go wrong.
+var specialValues = []float64{0, 1, -1, 2, -2, 0.5, -0.5}
+
+// Equivalent tests whether a and b give the same results, evaluating both
+// with EvalWithVars on samples bindings of vars drawn at random. A value is
+// one of 0, ±1, ±2 and ±0.5 a quarter of the time, a whole number between
+// -10 and 10 another quarter, and otherwise of a magnitude uniform in its
+// logarithm from 1e-6 to 1e6 and of either sign. The draws are seeded, so
+// they are the same from run to run.
+//
+// Results are equal when they differ by less than one part in 1e9, or are
+// both NaN or the same infinity, and errors when their codes are the same,
+// as the codes of two divisions by zero are. The first binding on which a
+// and b differ is returned with false; true means that every sample
+// agreed, which makes equivalence likely but does not prove it. vars must
+// include every free variable of both expressions, and samples must be
+// positive.
+func Equivalent(a, b Expr, vars []string, samples int) (bool, CounterExample, error) {
+	if samples < 1 {
+		return false, CounterExample{}, withCode(CodeInvalidArgument, fmt.Errorf("samples must be positive, got %d", samples))
+	}
+	given := make(map[string]bool, len(vars))
+	for _, name := range vars {
+		given[name] = true
+	}
+	for _, side := range []struct {
+		name string
+		expr Expr
+	}{{"a", a}, {"b", b}} {
+		for _, name := range Variables(side.expr) {
+			if !given[name] {
+				return false, CounterExample{}, withCode(CodeInvalidArgument, fmt.Errorf("variable %s of %s is not among vars", name, side.name))
+			}
+		}
+	}
+	fa, err := CompileFunc(a)
+	if err != nil {
+		return false, CounterExample{}, err
+	}
+	fb, err := CompileFunc(b)
+	if err != nil {
+		return false, CounterExample{}, err
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < samples; i++ {
+		binding := make(map[string]float64, len(vars))
+		for _, name := range vars {
+			binding[name] = sampleValue(r)
+		}
+		x, errA := fa(binding)
+		y, errB := fb(binding)
+		if !sameOutcome(x, errA, y, errB) {
+			return false, CounterExample{Vars: binding, A: x, B: y, ErrA: errA, ErrB: errB}, nil
+		}
+	}
+	return true, CounterExample{}, nil
+}
+
+// sampleValue draws a value for a variable as Equivalent describes.
+func sampleValue(r *rand.Rand) float64 {
+	switch r.Intn(4) {
+	case 0:
+		return specialValues[r.Intn(len(specialValues))]
+	case 1:
+		return float64(r.Intn(21) - 10)
+	}
+	v := math.Pow(10, r.Float64()*12-6)
+	if r.Intn(2) == 0 {
+		v = -v
+	}
+	return v
+}
+
+// sameOutcome reports whether two results, or two errors, are equal as
+// Equivalent compares them.
+func sameOutcome(x float64, errX error, y float64, errY error) bool {
+	switch {
+	case errX != nil || errY != nil:
+		return errX != nil && errY != nil && ErrorCode(errX) == ErrorCode(errY)
+	case math.IsNaN(x) || math.IsNaN(y):
+		return math.IsNaN(x) && math.IsNaN(y)
+	case math.IsInf(x, 0) || math.IsInf(y, 0):
+		return x == y
+	}
+	return math.Abs(x-y) <= equivalentTolerance*math.Max(math.Abs(x), math.Abs(y))
+}
+
+// end of file
@@ -0,0 +1,61 @@
+package expressionparser
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEquivalent(t *testing.T) {
+	tests := []struct {
+		a, b string
+		vars []string
+		want bool
+	}{
+		{"x*(y+z)", "x*y+x*z", []string{"x", "y", "z"}, true},
+		{"(x+1)^2", "x^2 + 2*x + 1", []string{"x"}, true},
+		{"x - y", "-(y - x)", []string{"x", "y"}, true},
+		// Errors compare by code: both divide by zero at x = 0
+		{"1/x", "2/(2*x)", []string{"x"}, true},
+		{"sqrt(x) * sqrt(x)", "sqrt(x) ^ 2", []string{"x"}, true},
+		{"x % y", "x - y * trunc(x / y)", []string{"x", "y"}, false}, // 7 % 0 and 7 / 0 differ in code
+		{"x + y", "y + x + 1e-12", []string{"x", "y"}, false},
+	}
+	for _, tt := range tests {
+		got, _, err := Equivalent(mustParse(t, tt.a), mustParse(t, tt.b), tt.vars, 500)
+		if err != nil || got != tt.want {
+			t.Errorf("Equivalent(%s, %s) = %v, %v; want %v", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+}
+
+func TestEquivalentCounterExample(t *testing.T) {
+	// abs(x) and x differ only for negative x
+	ok, ce, err := Equivalent(mustParse(t, "abs(x)"), mustParse(t, "x"), []string{"x"}, 500)
+	if err != nil || ok {
+		t.Fatalf("Equivalent(abs(x), x) = %v, %v; want false", ok, err)
+	}
+	if x := ce.Vars["x"]; x >= 0 || ce.A != -x || ce.B != x || ce.ErrA != nil || ce.ErrB != nil {
+		t.Errorf("counterexample %+v, want a negative x with A = -x and B = x", ce)
+	}
+
+	// An error against a value is a difference
+	ok, ce, err = Equivalent(mustParse(t, "x/x"), mustParse(t, "1"), []string{"x"}, 500)
+	if err != nil || ok {
+		t.Fatalf("Equivalent(x/x, 1) = %v, %v; want false", ok, err)
+	}
+	if ce.Vars["x"] != 0 || !errors.Is(ce.ErrA, ErrDivisionByZero) || ce.ErrB != nil || ce.B != 1 {
+		t.Errorf("counterexample %+v, want x = 0 with a division by zero against 1", ce)
+	}
+}
+
+func TestEquivalentInvalid(t *testing.T) {
+	a, b := mustParse(t, "x + y"), mustParse(t, "y + x")
+	if _, _, err := Equivalent(a, b, []string{"x"}, 10); ErrorCode(err) != CodeInvalidArgument {
+		t.Errorf("Equivalent without y in vars: error %v, want %s", err, CodeInvalidArgument)
+	}
+	if _, _, err := Equivalent(a, b, []string{"x", "y"}, 0); ErrorCode(err) != CodeInvalidArgument {
+		t.Errorf("Equivalent with 0 samples: error %v, want %s", err, CodeInvalidArgument)
+	}
+}
+
+// end of file
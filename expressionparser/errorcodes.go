@@ -0,0 +1,229 @@
+package expressionparser
+
+import (
+	"context"
+	"errors"
+)
+
+// Error codes: stable identifiers of the conditions the errors of this
+// package report, for programs to branch on rather than the wording of
+// messages, which may change. ErrorCode returns the code of any error.
+const (
+	CodeSyntax              = "E_SYNTAX"
+	CodeUnexpectedToken     = "E_SYNTAX_UNEXPECTED_TOKEN"
+	CodeInvalidToken        = "E_SYNTAX_INVALID_TOKEN"
+	CodeInvalidLiteral      = "E_SYNTAX_INVALID_LITERAL"
+	CodeSyntaxArgumentCount = "E_SYNTAX_ARGUMENT_COUNT"
+
+	CodeCheckVariable      = "E_CHECK_VARIABLE"
+	CodeCheckFunction      = "E_CHECK_UNKNOWN_FUNCTION"
+	CodeCheckArgumentCount = "E_CHECK_ARGUMENT_COUNT"
+	CodeCheckDivisionZero  = "E_CHECK_DIV_ZERO"
+	CodeCheckModuloZero    = "E_CHECK_MOD_ZERO"
+
+	CodeLimitInput = "E_LIMIT_INPUT"
+	CodeLimitDepth = "E_LIMIT_DEPTH"
+	CodeLimitNodes = "E_LIMIT_NODES"
+	CodeLimitCalls = "E_LIMIT_CALLS"
+	CodeLimitOps   = "E_LIMIT_OPS"
+
+	CodeInvalidOption   = "E_OPTION_INVALID"
+	CodeInvalidArgument = "E_API_ARGUMENT"
+	CodeDecode          = "E_DECODE"
+
+	CodeDivisionByZero  = "E_EVAL_DIV_ZERO"
+	CodeModuloByZero    = "E_EVAL_MOD_ZERO"
+	CodeOutsideDomain   = "E_EVAL_DOMAIN"
+	CodeUndefinedVar    = "E_EVAL_UNDEF_VAR"
+	CodeUnknownFunction = "E_EVAL_UNKNOWN_FUNCTION"
+	CodeArgumentCount   = "E_EVAL_ARGUMENT_COUNT"
+	CodeType            = "E_EVAL_TYPE"
+	CodeLengthMismatch  = "E_EVAL_LENGTH_MISMATCH"
+	CodeOverflow        = "E_EVAL_OVERFLOW"
+	CodeNonFinite       = "E_EVAL_NON_FINITE"
+	CodeNoConvergence   = "E_EVAL_NO_CONVERGENCE"
+	CodeUnsupported     = "E_EVAL_UNSUPPORTED"
+	CodeCanceled        = "E_EVAL_CANCELED"
+	CodeEvalFailed      = "E_EVAL_FAILED"
+	CodeInvalidPattern  = "E_EVAL_PATTERN"
+
+	CodeIncompatibleUnits = "E_UNITS_INCOMPATIBLE"
+	CodeUnknownUnit       = "E_UNITS_UNKNOWN"
+	CodeInvalidUnit       = "E_UNITS_INVALID"
+
+	CodeReferenceCycle = "E_LIBRARY_CYCLE"
+
+	CodeNonLinear         = "E_SOLVE_NONLINEAR"
+	CodeNoSolution        = "E_SOLVE_NO_SOLUTION"
+	CodeInfiniteSolutions = "E_SOLVE_INFINITE_SOLUTIONS"
+
+	CodePanic = "E_PANIC"
+	CodeOther = "E_OTHER"
+)
+
+// ErrorCodes describes every error code.
+var ErrorCodes = map[string]string{
+	CodeSyntax:              "the input is not an expression",
+	CodeUnexpectedToken:     "a token other than one the grammar allows at that point",
+	CodeInvalidToken:        "a character no token begins with, or an unterminated string",
+	CodeInvalidLiteral:      "a number out of the range of float64, an invalid string literal, or a literal an evaluation mode cannot read",
+	CodeSyntaxArgumentCount: "if called with other than 2 or 3 arguments",
+	CodeCheckVariable:       "a variable outside the set CheckOptions allows",
+	CodeCheckFunction:       "a call to a function Check does not know",
+	CodeCheckArgumentCount:  "a call Check finds with the wrong number of arguments",
+	CodeCheckDivisionZero:   "a division by a constant zero",
+	CodeCheckModuloZero:     "a modulo by a constant zero",
+	CodeLimitInput:          "input longer than WithMaxInputBytes allows",
+	CodeLimitDepth:          "an expression nested deeper than WithMaxDepth or Limits allow",
+	CodeLimitNodes:          "an expression with more nodes than Limits allow",
+	CodeLimitCalls:          "an expression making more function calls than Limits allow",
+	CodeLimitOps:            "an evaluation taking more operations than WithMaxOps allows",
+	CodeInvalidOption:       "an option out of range, or contradicting another",
+	CodeInvalidArgument:     "an argument to a Go function of the package that it cannot accept",
+	CodeDecode:              "an encoded expression or program, in binary or JSON, that does not decode",
+	CodeDivisionByZero:      "a division by zero",
+	CodeModuloByZero:        "a modulo by zero",
+	CodeOutsideDomain:       "an argument outside the domain of a function",
+	CodeUndefinedVar:        "a variable with no value",
+	CodeUnknownFunction:     "a call to a function that does not exist",
+	CodeArgumentCount:       "a call with the wrong number of arguments",
+	CodeType:                "an operand or argument of the wrong type, such as a string where a number is needed",
+	CodeLengthMismatch:      "lists of different lengths combined element by element, as in [1, 2] + [1, 2, 3]",
+	CodeOverflow:            "a result too large for the evaluation mode",
+	CodeNonFinite:           "an infinite or NaN result that WithNonFinite rejects",
+	CodeNoConvergence:       "a numerical method, such as that of integrate or solve, finding no result",
+	CodeUnsupported:         "an operator, function or literal the evaluation mode lacks",
+	CodeCanceled:            "an evaluation stopped by its context",
+	CodeEvalFailed:          "an evaluation failing otherwise, as a registered function may",
+	CodeInvalidPattern:      "a regular expression, given to =~, match or capture, that does not compile",
+	CodeIncompatibleUnits:   "quantities whose units do not combine, such as 1 m + 1 s",
+	CodeUnknownUnit:         "a unit that is not registered",
+	CodeInvalidUnit:         "a unit definition or unit expression that is malformed",
+	CodeReferenceCycle:      "a formula of a Library, or an expression given to Dependencies, that depends on itself",
+	CodeNonLinear:           "an equation given to SolveLinear in which the unknown appears non-linearly",
+	CodeNoSolution:          "an equation given to SolveLinear that holds for no value of the unknown",
+	CodeInfiniteSolutions:   "an equation given to SolveLinear that holds for every value of the unknown",
+	CodePanic:               "a panic recovered as a PanicError",
+	CodeOther:               "an error of none of the kinds above, or not of this package",
+}
+
+// ErrorCode returns the code of err: that of the first error in its chain
+// with a Code method, as the error types of this package have, or else of
+// the first sentinel it wraps, or CodeOther. It returns "" for nil.
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	var coded interface{ Code() string }
+	if errors.As(err, &coded) {
+		return coded.Code()
+	}
+	switch {
+	case errors.Is(err, ErrDivisionByZero):
+		return CodeDivisionByZero
+	case errors.Is(err, ErrModuloByZero):
+		return CodeModuloByZero
+	case errors.Is(err, ErrOutsideDomain):
+		return CodeOutsideDomain
+	case errors.Is(err, ErrUndefinedVariable):
+		return CodeUndefinedVar
+	case errors.Is(err, ErrUnknownFunction):
+		return CodeUnknownFunction
+	case errors.Is(err, ErrOverflow):
+		return CodeOverflow
+	case errors.Is(err, ErrIncompatibleUnits):
+		return CodeIncompatibleUnits
+	case errors.Is(err, ErrInvalidOption):
+		return CodeInvalidOption
+	case errors.Is(err, ErrSyntax):
+		return CodeSyntax
+	case errors.Is(err, ErrNoSolution):
+		return CodeNoSolution
+	case errors.Is(err, ErrInfiniteSolutions):
+		return CodeInfiniteSolutions
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return CodeCanceled
+	}
+	return CodeOther
+}
+
+// codedError gives an error without a type of its own its code.
+type codedError struct {
+	code string
+	err  error
+}
+
+// withCode gives err the code.
+func withCode(code string, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// Error gives the message of the error.
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error.
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// Code returns the code.
+func (e *codedError) Code() string {
+	return e.code
+}
+
+// Code returns the code of the parse error, CodeSyntax unless it is more
+// specific.
+func (e *ParseError) Code() string {
+	if e.code == "" {
+		return CodeSyntax
+	}
+	return e.code
+}
+
+// Code returns the code of the cause, CodeEvalFailed when it has none more
+// specific.
+func (e *EvalError) Code() string {
+	if code := ErrorCode(e.Err); code != CodeOther {
+		return code
+	}
+	return CodeEvalFailed
+}
+
+// Code returns CodeUndefinedVar.
+func (e *UndefinedVariableError) Code() string {
+	return CodeUndefinedVar
+}
+
+// Code returns CodeType.
+func (e *TypeError) Code() string {
+	return CodeType
+}
+
+// Code returns CodeOverflow.
+func (e *OverflowError) Code() string {
+	return CodeOverflow
+}
+
+// Code returns CodeNonFinite.
+func (e *NonFiniteError) Code() string {
+	return CodeNonFinite
+}
+
+// Code returns CodeLimitOps.
+func (e *BudgetExceededError) Code() string {
+	return CodeLimitOps
+}
+
+// Code returns CodeReferenceCycle.
+func (e *CycleError) Code() string {
+	return CodeReferenceCycle
+}
+
+// Code returns CodePanic.
+func (e *PanicError) Code() string {
+	return CodePanic
+}
+
+// end of file
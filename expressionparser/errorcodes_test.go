@@ -0,0 +1,151 @@
+package expressionparser
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestErrorCodesDocumented checks that every Code constant is described in
+// ErrorCodes, and nothing else is, and that no two share a value.
+func TestErrorCodesDocumented(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "errorcodes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	declared := map[string]string{} // value to constant
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			v := spec.(*ast.ValueSpec)
+			if !strings.HasPrefix(v.Names[0].Name, "Code") {
+				continue
+			}
+			code, _ := strconv.Unquote(v.Values[0].(*ast.BasicLit).Value)
+			if other, ok := declared[code]; ok {
+				t.Errorf("%s and %s are both %s", other, v.Names[0].Name, code)
+			}
+			declared[code] = v.Names[0].Name
+			if ErrorCodes[code] == "" {
+				t.Errorf("%s (%s) is not described in ErrorCodes", v.Names[0].Name, code)
+			}
+		}
+	}
+	for code := range ErrorCodes {
+		if _, ok := declared[code]; !ok {
+			t.Errorf("ErrorCodes describes %s, which is no Code constant", code)
+		}
+	}
+}
+
+// TestErrorCodesOfFailures gives each code but CodeOther a failure that
+// has it.
+func TestErrorCodesOfFailures(t *testing.T) {
+	evaluate := func(input string, opts ...Option) error {
+		_, err := Evaluate(input, opts...)
+		return err
+	}
+	value := func(input string) error {
+		_, err := EvalValue(mustParse(t, input), nil)
+		return err
+	}
+	check := func(input string, opts CheckOptions) error {
+		return Validate(input, WithChecks(opts))
+	}
+	solve := func(equation string) error {
+		_, _, err := SolveLinear(equation)
+		return err
+	}
+	failing := NewEvaluator()
+	failing.RegisterFunc("fail", func(args ...float64) (float64, error) {
+		return 0, errors.New("failed")
+	})
+	failing.RegisterFunc("explode", func(args ...float64) (float64, error) {
+		panic("boom")
+	})
+	lib := NewLibrary()
+	if err := lib.Define("a", "b + 1"); err != nil {
+		t.Fatal(err)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		code string
+		err  error
+	}{
+		{CodeSyntax, second(EvalProgram(nil, nil))},
+		{CodeUnexpectedToken, evaluate("1 * * 2")},
+		{CodeInvalidToken, evaluate("1 $ 2")},
+		{CodeInvalidLiteral, evaluate("1e999")},
+		{CodeSyntaxArgumentCount, evaluate("if(1, 2, 3, 4)")},
+		{CodeCheckVariable, check("y", CheckOptions{Variables: map[string]bool{}})},
+		{CodeCheckFunction, check("foo(1)", CheckOptions{})},
+		{CodeCheckArgumentCount, check("sqrt(1, 2)", CheckOptions{})},
+		{CodeCheckDivisionZero, check("1 / 0", CheckOptions{})},
+		{CodeCheckModuloZero, check("1 % 0", CheckOptions{})},
+		{CodeLimitInput, evaluate("123", WithMaxInputBytes(2))},
+		{CodeLimitDepth, evaluate("((1))", WithMaxDepth(1))},
+		{CodeLimitNodes, evaluate("1 + 2 + 3", WithLimits(Limits{MaxNodes: 2}))},
+		{CodeLimitCalls, evaluate("abs(abs(1))", WithLimits(Limits{MaxCalls: 1}))},
+		{CodeLimitOps, evaluate("1 + 2 + 3", WithMaxOps(2))},
+		{CodeInvalidOption, evaluate("1", WithMaxDepth(-1))},
+		{CodeInvalidArgument, lib.Define("1x", "1")},
+		{CodeDecode, second(DecodeJSON([]byte("{")))},
+		{CodeDivisionByZero, evaluate("1 / 0")},
+		{CodeModuloByZero, evaluate("1 % 0")},
+		{CodeOutsideDomain, evaluate("sqrt(-1)")},
+		{CodeUndefinedVar, evaluate("x")},
+		{CodeUnknownFunction, evaluate("foo(1)")},
+		{CodeArgumentCount, evaluate("sqrt(1, 2)")},
+		{CodeType, value(`"a" * 2`)},
+		{CodeLengthMismatch, value("[1, 2] + [1, 2, 3]")},
+		{CodeOverflow, second(EvalInt(mustParse(t, "2 ^ 63")))},
+		{CodeNonFinite, evaluate("1e308 * 10", WithNonFiniteResults(RejectNonFinite))},
+		{CodeNoConvergence, evaluate(`solve(x^2 + 1, "x", 0)`)},
+		{CodeUnsupported, second(EvalBig(mustParse(t, "sin(1)")))},
+		{CodeCanceled, second(NewEvaluator().EvalContext(canceled, mustParse(t, "1 + 1")))},
+		{CodeEvalFailed, second(failing.Eval(mustParse(t, "fail()")))},
+		{CodeInvalidPattern, evaluate(`"a" =~ "("`)},
+		{CodeIncompatibleUnits, second(EvalUnits(mustParse(t, "1 m + 1 s")))},
+		{CodeUnknownUnit, second(EvalUnits(mustParse(t, "1 zz")))},
+		{CodeInvalidUnit, NewEvaluator().RegisterUnit("q", 0, "")},
+		{CodeReferenceCycle, lib.Define("b", "a * 2")},
+		{CodeNonLinear, solve("x*x = 4")},
+		{CodeNoSolution, solve("x = x + 1")},
+		{CodeInfiniteSolutions, solve("2*x = x + x")},
+		{CodePanic, second(failing.Eval(mustParse(t, "explode()")))},
+		{CodeOther, errors.New("not of this package")},
+	}
+	seen := map[string]bool{}
+	for _, tt := range tests {
+		seen[tt.code] = true
+		if got := ErrorCode(tt.err); got != tt.code {
+			t.Errorf("ErrorCode(%v) = %q, want %s", tt.err, got, tt.code)
+		}
+	}
+	var missing []string
+	for code := range ErrorCodes {
+		if !seen[code] {
+			missing = append(missing, code)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("no failure tested for %s", strings.Join(missing, ", "))
+	}
+	if got := ErrorCode(nil); got != "" {
+		t.Errorf("ErrorCode(nil) = %q, want \"\"", got)
+	}
+}
+
+// end of file
@@ -0,0 +1,147 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// WithContinueOnError makes EvalAll carry on past expressions that fail,
+// evaluating every expression that does not depend on them and reporting
+// all the failures, instead of stopping at the first.
+func WithContinueOnError() Option {
+	return func(c *config) {
+		c.continueOnError = true
+	}
+}
+
+// ExprError reports the failure of one of the named expressions of EvalAll.
+// Pos is the offset in the expression that Err occurred at, or -1 when it
+// is not known.
+type ExprError struct {
+	Name string
+	Pos  int
+	Err  error
+}
+
+// Error names the expression and the failure.
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("expression %s: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the error of the expression.
+func (e *ExprError) Unwrap() error {
+	return e.Err
+}
+
+// EvalAll evaluates interdependent named expressions with the default
+// Evaluator.
+func EvalAll(exprs map[string]Expr, vars map[string]float64) (map[string]float64, error) {
+	return defaultEvaluator.EvalAll(exprs, vars)
+}
+
+// EvalAll evaluates each of exprs once, in an order in which every
+// expression comes after those it uses by name, as Dependencies finds
+// them, and returns the value of each by name. The names no expression
+// defines take their values from vars and then from those set on the
+// evaluator. A bool result counts as 1 or 0, and a string or list result
+// is an error.
+//
+// The first expression to fail stops the evaluation, and its *ExprError
+// is returned with the values found so far. WithContinueOnError, the
+// evaluation carries on instead with every expression that does not
+// depend on a failed one, and the error joins, with errors.Join, an
+// *ExprError for each expression that failed or was skipped, in the order
+// of evaluation. Expressions that use each other in a cycle are evaluated
+// not at all, the error being that of Dependencies.
+func (ev *Evaluator) EvalAll(exprs map[string]Expr, vars map[string]float64) (map[string]float64, error) {
+	graph, _, err := Dependencies(exprs)
+	if err != nil {
+		return nil, err
+	}
+	base := NewEnvironment(nil)
+	for name, value := range vars {
+		base.SetNumber(name, value)
+	}
+
+	results := make(map[string]float64, len(exprs))
+	values := make(map[string]Value, len(exprs))
+	failed := map[string]bool{}
+	var errs []error
+	for _, name := range topoOrder(graph) {
+		exprErr := &ExprError{Name: name, Pos: -1}
+		env := NewEnvironment(base)
+		for _, dep := range graph[name] {
+			if failed[dep] {
+				exprErr.Err = fmt.Errorf("depends on %s, which failed", dep)
+				break
+			}
+			env.Set(dep, values[dep])
+		}
+		if exprErr.Err == nil {
+			value, err := ev.evalNamed(name, exprs[name], env)
+			if err == nil {
+				values[name], results[name] = value, value.num
+				continue
+			}
+			exprErr = err
+		}
+		if !ev.cfg.continueOnError {
+			return results, exprErr
+		}
+		failed[name] = true
+		errs = append(errs, exprErr)
+	}
+	return results, errors.Join(errs...)
+}
+
+// evalNamed evaluates expr, the expression of name, in env, failing with an
+// *ExprError unless its value is a number or a bool.
+func (ev *Evaluator) evalNamed(name string, expr Expr, env *Environment) (Value, *ExprError) {
+	value, err := ev.EvalIn(expr, env)
+	if err == nil && !value.numeric() {
+		err = withCode(CodeType, fmt.Errorf("result is a %s, not a number", value.kind))
+	}
+	if err == nil {
+		return value, nil
+	}
+	exprErr := &ExprError{Name: name, Pos: -1, Err: err}
+	if span, ok := errorSpan(err); ok {
+		exprErr.Pos = span.Start
+	}
+	return Value{}, exprErr
+}
+
+// topoOrder returns the names of graph, which has no cycles, each after
+// those it maps to, breaking ties in sorted order.
+func topoOrder(graph map[string][]string) []string {
+	pending := make(map[string]int, len(graph))
+	dependents := map[string][]string{}
+	var ready []string
+	for name, deps := range graph {
+		pending[name] = len(deps)
+		for _, dep := range deps {
+			dependents[dep] = append(dependents[dep], name)
+		}
+		if len(deps) == 0 {
+			ready = append(ready, name)
+		}
+	}
+	sort.Strings(ready)
+
+	order := make([]string, 0, len(graph))
+	for len(ready) > 0 {
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		for _, dependent := range dependents[name] {
+			if pending[dependent]--; pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+		sort.Strings(ready)
+	}
+	return order
+}
+
+// end of file
@@ -0,0 +1,82 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestEvalAllDiamond(t *testing.T) {
+	// Count the evaluations of each formula by the reads of its variables
+	reads := map[string]int{}
+	ev := NewEvaluator(WithBeforeNode(func(e Expr) error {
+		if v, ok := e.(*Variable); ok {
+			reads[v.Name]++
+		}
+		return nil
+	}))
+	got, err := ev.EvalAll(parseAll(t, diamond), map[string]float64{"price": 50, "qty": 4, "tax": 0.25, "shipping": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(got); s != "map[base:200 left:250 right:20 top:235]" {
+		t.Errorf("EvalAll = %s", s)
+	}
+	// base is read by left once and by right twice, but evaluated once
+	if reads["price"] != 1 || reads["qty"] != 1 || reads["base"] != 3 || reads["left"] != 1 {
+		t.Errorf("reads %v; want price, qty and left once and base three times", reads)
+	}
+
+	// Names not defined come from the evaluator too
+	ev = NewEvaluator()
+	ev.SetVar("shipping", 1)
+	got, err = ev.EvalAll(parseAll(t, diamond), map[string]float64{"price": 1, "qty": 2, "tax": 0})
+	if err != nil || got["top"] != 3 {
+		t.Errorf("top = %v, %v; want 3", got["top"], err)
+	}
+}
+
+func TestEvalAllErrors(t *testing.T) {
+	exprs := parseAll(t, map[string]string{
+		"a": "2 + 1 / z", // fails
+		"b": "a + 1",     // depends on a
+		"c": "x * 2",
+		"d": "c + 1",
+		"e": `"s"`, // not a number
+	})
+	vars := map[string]float64{"z": 0, "x": 3}
+
+	// The first failure stops
+	got, err := EvalAll(exprs, vars)
+	var exprErr *ExprError
+	if !errors.As(err, &exprErr) || exprErr.Name != "a" || exprErr.Pos != 4 || !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("EvalAll: error %v, want a division by zero in a at offset 4", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("EvalAll = %v before a failed", got)
+	}
+
+	// Or the rest goes on
+	got, err = NewEvaluator(WithContinueOnError()).EvalAll(exprs, vars)
+	if s := fmt.Sprint(got); s != "map[c:6 d:7]" {
+		t.Errorf("EvalAll WithContinueOnError = %s, want the independent c and d", s)
+	}
+	want := "expression a: division by zero in \"1 / z\" at offset 4\n" +
+		"expression b: depends on a, which failed\n" +
+		"expression e: result is a string, not a number"
+	if err == nil || err.Error() != want {
+		t.Errorf("EvalAll WithContinueOnError: error\n%v\nwant\n%s", err, want)
+	}
+	if !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("EvalAll WithContinueOnError: error %v is not ErrDivisionByZero", err)
+	}
+
+	// A cycle evaluates nothing
+	got, err = EvalAll(parseAll(t, map[string]string{"p": "q", "q": "p", "r": "1"}), nil)
+	var cycle *CycleError
+	if !errors.As(err, &cycle) || got != nil {
+		t.Errorf("EvalAll of a cycle = %v, %v", got, err)
+	}
+}
+
+// end of file
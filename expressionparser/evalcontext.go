@@ -0,0 +1,128 @@
+package expressionparser
+
+import (
+	"context"
+	"fmt"
+)
+
+// Number of nodes evaluated between checks of the context of EvalContext
+const contextCheckInterval = 256
+
+// progress tracks an evaluation that can be cancelled through a context or
+// that has an operation budget.
+type progress struct {
+	ctx     context.Context // nil when there is nothing to check
+	maxOps  int
+	nodes   int
+	stopped bool // set once step has returned an error
+}
+
+// EvalContext evaluates an expression like EvalWithVars, stopping early
+// when ctx is cancelled or its deadline passes.
+func EvalContext(ctx context.Context, expr Expr, vars map[string]float64) (float64, error) {
+	ev := *defaultEvaluator
+	ev.vars = vars
+	return ev.EvalContext(ctx, expr)
+}
+
+// EvalContext evaluates expr like Eval, stopping early when ctx is cancelled
+// or its deadline passes. The context is checked before evaluation starts
+// and then once every few hundred nodes rather than at each one, so the
+// error comes promptly without slowing evaluation down; it wraps ctx.Err()
+// with the number of nodes evaluated so far and the position reached. Eval
+// is EvalContext with context.Background(), which is never checked.
+func (ev *Evaluator) EvalContext(ctx context.Context, expr Expr) (float64, error) {
+	value, err := ev.evalValueContext(ctx, expr, nil)
+	if err != nil {
+		return 0, err
+	}
+	if !value.numeric() {
+		return 0, withCode(CodeType, fmt.Errorf("result is a %s, not a number", value.kind))
+	}
+	return value.num, nil
+}
+
+// evalValueContext is EvalValue under ctx, with the result rounded WithRoundTo,
+// resolving variables from env, when set, before those of the evaluator.
+// A panic, as of a registered function, gives a *PanicError.
+func (ev *Evaluator) evalValueContext(ctx context.Context, expr Expr, env *Environment) (result Value, err error) {
+	defer recoverPanic(&err)
+	value, err := ev.run(ctx, expr, env)
+	if err != nil {
+		return Value{}, err
+	}
+	return ev.roundResult(value), nil
+}
+
+// run evaluates expr under ctx with the state the configuration calls for.
+func (ev *Evaluator) run(ctx context.Context, expr Expr, env *Environment) (Value, error) {
+	if err := ev.admit(expr); err != nil {
+		return Value{}, err
+	}
+	if ctx.Done() == nil {
+		ctx = nil
+	} else if err := ctx.Err(); err != nil {
+		return Value{}, fmt.Errorf("evaluation not started: %w", err)
+	}
+	root := &scope{vars: ev.vars}
+	if env != nil {
+		root = &scope{env: env, parent: root}
+	}
+	memoize := ev.cfg.memoize && ev.trace == nil && ev.stats == nil
+	if ctx == nil && ev.cfg.maxOps == 0 && !memoize {
+		return ev.eval(expr, root)
+	}
+
+	// The copy carries the state of this evaluation only, so ev can still
+	// be shared between goroutines
+	run := *ev
+	if ctx != nil || ev.cfg.maxOps > 0 {
+		run.progress = &progress{ctx: ctx, maxOps: ev.cfg.maxOps}
+	}
+	if memoize {
+		run.memo = ev.newMemo(expr)
+	}
+	return run.eval(expr, root)
+}
+
+// step counts the evaluation of expr against the budget, checking the
+// context at every interval.
+func (p *progress) step(expr Expr) error {
+	p.nodes++
+	if p.maxOps > 0 && p.nodes > p.maxOps {
+		pos := -1
+		if span := SpanOf(expr); span != (Span{}) {
+			pos = span.Start
+		}
+		p.stopped = true
+		return &BudgetExceededError{Limit: p.maxOps, Pos: pos}
+	}
+	if p.ctx == nil || p.nodes%contextCheckInterval != 0 {
+		return nil
+	}
+	if err := p.ctx.Err(); err != nil {
+		p.stopped = true
+		if span := SpanOf(expr); span != (Span{}) {
+			return fmt.Errorf("evaluation stopped after %d nodes at offset %d: %w", p.nodes, span.Start, err)
+		}
+		return fmt.Errorf("evaluation stopped after %d nodes: %w", p.nodes, err)
+	}
+	return nil
+}
+
+// BudgetExceededError reports an evaluation stopped by WithMaxOps.
+type BudgetExceededError struct {
+	Limit int // the budget, every unit of which was spent
+	Pos   int // offset of the node that would have exceeded it; -1 for trees built without spans
+}
+
+// Error gives the budget and, when known, where evaluation stopped.
+func (e *BudgetExceededError) Error() string {
+	msg := fmt.Sprintf("evaluation exceeded its budget of %d operations", e.Limit)
+	if e.Pos < 0 {
+		return msg
+	}
+	return fmt.Sprintf("%s at offset %d", msg, e.Pos)
+}
+
+// end of file
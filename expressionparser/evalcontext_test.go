@@ -0,0 +1,125 @@
+package expressionparser
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sumChain builds 1 + 1 + ... + 1 with n terms.
+func sumChain(n int) Expr {
+	var expr Expr = Num(1)
+	for i := 1; i < n; i++ {
+		expr = Add(expr, Num(1))
+	}
+	return expr
+}
+
+func TestEvalContextCancelled(t *testing.T) {
+	huge := mustParse(t, "sum(i, 1, 1e9, i)")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	_, err := EvalContext(ctx, huge, nil)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("EvalContext with a cancelled context took %v", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) || ErrorCode(err) != CodeCanceled {
+		t.Fatalf("EvalContext with a cancelled context: error %v, want context.Canceled", err)
+	}
+	if want := "evaluation not started: context canceled"; err.Error() != want {
+		t.Errorf("error %q, want %q", err, want)
+	}
+
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	if _, err := EvalContext(expired, sumChain(100000), nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("EvalContext past its deadline: error %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestEvalContextStopsMidway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ev := NewEvaluator()
+	ev.RegisterFunc("stop", func(args ...float64) (float64, error) {
+		cancel()
+		return args[0], nil
+	})
+	start := time.Now()
+	_, err := ev.EvalContext(ctx, mustParse(t, "stop(1) + sum(i, 1, 1e9, i)"))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("EvalContext cancelled midway took %v", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error %v, want context.Canceled", err)
+	}
+	// Checked every contextCheckInterval nodes, it stops at the first check
+	if want := "evaluation stopped after 256 nodes at offset "; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("error %q, want it to start %q", err, want)
+	}
+
+	// Eval is never cancelled
+	if got, err := ev.Eval(mustParse(t, "stop(1) + sum(i, 1, 1000, i)")); err != nil || got != 500501 {
+		t.Errorf("Eval = %v, %v; want 500501", got, err)
+	}
+	if got, err := ev.EvalContext(context.Background(), sumChain(1000)); err != nil || got != 1000 {
+		t.Errorf("EvalContext with context.Background() = %v, %v; want 1000", got, err)
+	}
+}
+
+func TestWithMaxOps(t *testing.T) {
+	tests := []struct {
+		input string
+		ops   int // the exact number of operations
+		pos   int // where a budget one below stops
+	}{
+		{"(1 + 2) * 3 - 4", 7, 14},
+		{"-x", 2, 1},
+		{"let a = 2 in a * a", 5, 17},
+		{"1 < 2 || 1 / 0", 4, 4}, // the skipped division costs nothing
+		{"if(1, 2, 3)", 3, 6},
+		{"max(1, 2, 3)", 4, 10},
+		{"sum(i, 1, 3, i)", 6, 13},
+	}
+	for _, tt := range tests {
+		expr := mustParse(t, tt.input)
+		eval := func(maxOps int) error {
+			ev := NewEvaluator(WithMaxOps(maxOps))
+			ev.SetVar("x", 1)
+			_, err := ev.Eval(expr)
+			return err
+		}
+		if err := eval(tt.ops); err != nil {
+			t.Errorf("%s with a budget of %d: %v", tt.input, tt.ops, err)
+		}
+		err := eval(tt.ops - 1)
+		var budget *BudgetExceededError
+		if !errors.As(err, &budget) || budget.Limit != tt.ops-1 || budget.Pos != tt.pos {
+			t.Errorf("%s with a budget of %d: error %v, want a *BudgetExceededError at offset %d", tt.input, tt.ops-1, err, tt.pos)
+		}
+		if ErrorCode(err) != CodeLimitOps {
+			t.Errorf("%s: code %s, want %s", tt.input, ErrorCode(err), CodeLimitOps)
+		}
+	}
+
+	// The VM counts instructions, as many as the tree has nodes here
+	prog, err := CompileProgram(mustParse(t, "(x + 2) * 3 - 4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := prog.RunBudget([]float64{1}, 7); err != nil {
+		t.Errorf("RunBudget(7): %v", err)
+	}
+	var budget *BudgetExceededError
+	if _, err := prog.RunBudget([]float64{1}, 6); !errors.As(err, &budget) || budget.Limit != 6 {
+		t.Errorf("RunBudget(6): error %v, want a *BudgetExceededError", err)
+	}
+	if got, err := prog.RunBudget([]float64{1}, 0); err != nil || got != 5 {
+		t.Errorf("RunBudget(0) = %v, %v; want 5 with no budget", got, err)
+	}
+}
+
+// end of file
@@ -0,0 +1,117 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Causes of evaluation errors, reachable with errors.Is through the errors
+// of every evaluation mode. ErrUndefinedVariable matches every
+// *UndefinedVariableError, which carries the name, and ErrOverflow every
+// *OverflowError, as well as the overflow of decimals and durations.
+var (
+	ErrDivisionByZero    = errors.New("division by zero")
+	ErrModuloByZero      = errors.New("modulo by zero")
+	ErrOutsideDomain     = errors.New("outside the domain")
+	ErrUndefinedVariable = errors.New("undefined variable")
+	ErrUnknownFunction   = errors.New("unknown function")
+	ErrOverflow          = errors.New("overflow")
+)
+
+// EvalError reports a failure of Eval, EvalValue and the evaluations built
+// on them, EvalContext, EvalTrace, EvalBatch and the functions made by
+// CompileFunc, in the innermost subexpression where it occurred, as in
+// `division by zero in "total / count" at offset 14`. Err is the cause: one
+// of the sentinels above, an *UndefinedVariableError, a *TypeError or the
+// error of a builtin or registered function. NonFiniteError already names
+// its subexpression and is returned as it is, as are the errors of
+// evaluations stopped by WithMaxOps or their context.
+type EvalError struct {
+	Expr Expr  // the subexpression whose evaluation failed
+	Pos  int   // offset of Expr in the input; -1 for trees built without spans
+	Err  error // the cause
+}
+
+// Error gives the cause, the subexpression as Format prints it and, when
+// known, its offset.
+func (e *EvalError) Error() string {
+	cause := e.Err.Error()
+	if p, ok := e.Err.(interface{ withoutOffset() string }); ok {
+		// The offset of the subexpression replaces that of the cause
+		cause = p.withoutOffset()
+	}
+	msg := fmt.Sprintf("%s in %q", cause, Format(e.Expr))
+	if e.Pos < 0 {
+		return msg
+	}
+	return fmt.Sprintf("%s at offset %d", msg, e.Pos)
+}
+
+// Unwrap returns the cause.
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+// locate attributes an error met evaluating expr to it, unless the error
+// already names the subexpression it occurred in or stopped the evaluation.
+func (ev *Evaluator) locate(expr Expr, err error) error {
+	if ev.progress != nil && ev.progress.stopped {
+		return err
+	}
+	switch err.(type) {
+	case *EvalError, *NonFiniteError:
+		return err
+	}
+	pos := -1
+	if span := SpanOf(expr); span != (Span{}) {
+		pos = span.Start
+	}
+	return &EvalError{Expr: expr, Pos: pos, Err: err}
+}
+
+// unavailableError reports a call to a function that an evaluation mode
+// other than Eval's lacks, as an unknown function when Eval lacks it too.
+func (ev *Evaluator) unavailableError(name, mode string) error {
+	_, registered := ev.funcs[name]
+	_, isString := stringBuiltins[name]
+	_, isAggregate := aggregates[name]
+	_, isBuiltin := builtins[name]
+	_, isForm := specialForms[name]
+	_, isIteration := iterationForms[name]
+	_, isLazy := lazyForms[name]
+	if !registered && !isString && !isAggregate && !isBuiltin && !isForm && !isIteration && !isLazy {
+		return fmt.Errorf("%w %s", ErrUnknownFunction, name)
+	}
+	return withCode(CodeUnsupported, fmt.Errorf("function %s is not available in %s mode", name, mode))
+}
+
+// offsetError is an error at a known offset in the input.
+type offsetError struct {
+	err error
+	pos int
+}
+
+// Error gives the error and its offset.
+func (e *offsetError) Error() string {
+	return fmt.Sprintf("%v at offset %d", e.err, e.pos)
+}
+
+// Unwrap returns the error without its offset.
+func (e *offsetError) Unwrap() error {
+	return e.err
+}
+
+// withoutOffset gives the message of the error without its offset.
+func (e *offsetError) withoutOffset() string {
+	return e.err.Error()
+}
+
+// atOffset places err at offset pos, or leaves it as it is when pos is -1.
+func atOffset(err error, pos int) error {
+	if pos < 0 {
+		return err
+	}
+	return &offsetError{err: err, pos: pos}
+}
+
+// end of file
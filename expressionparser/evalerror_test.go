@@ -0,0 +1,198 @@
+package expressionparser
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestEvalErrorNamesSubexpression(t *testing.T) {
+	vars := map[string]float64{"total": 10, "count": 0, "a": 1, "b": -4}
+	tests := []struct {
+		input string
+		msg   string
+		cause error
+		expr  string // the subexpression the *EvalError names
+		pos   int
+	}{
+		{"a + b * 2 + total / count", `division by zero in "total / count" at offset 12`, ErrDivisionByZero, "total / count", 12},
+		{"1 + 2 % (a - a)", `modulo by zero in "2 % (a - a)" at offset 4`, ErrModuloByZero, "2 % (a - a)", 4},
+		{"a + missing * 2", `undefined variable missing in "missing" at offset 4`, ErrUndefinedVariable, "missing", 4},
+		{"2 * nope(a)", `unknown function nope in "nope(a)" at offset 4`, ErrUnknownFunction, "nope(a)", 4},
+		{"3 + sqrt(b)", `sqrt: argument -4 is outside the domain in "sqrt(b)" at offset 4`, ErrOutsideDomain, "sqrt(b)", 4},
+		{"a + (ln(count) + 1)", `ln: argument 0 is outside the domain in "ln(count)" at offset 5`, ErrOutsideDomain, "ln(count)", 5},
+		{"let z = a - a in 4 / z", `division by zero in "4 / z" at offset 17`, ErrDivisionByZero, "4 / z", 17},
+		{"a > 0 ? total / count : 1", `division by zero in "total / count" at offset 8`, ErrDivisionByZero, "total / count", 8},
+		{"max(a, total / count)", `division by zero in "total / count" at offset 7`, ErrDivisionByZero, "total / count", 7},
+	}
+	for _, tt := range tests {
+		_, err := EvalWithVars(mustParse(t, tt.input), vars)
+		if err == nil || err.Error() != tt.msg {
+			t.Errorf("%s: error %v, want %q", tt.input, err, tt.msg)
+		}
+		var evalErr *EvalError
+		if !errors.As(err, &evalErr) {
+			t.Errorf("%s: error %T, want an *EvalError", tt.input, err)
+			continue
+		}
+		if Format(evalErr.Expr) != tt.expr || evalErr.Pos != tt.pos {
+			t.Errorf("%s: *EvalError names %q at %d, want %q at %d", tt.input, Format(evalErr.Expr), evalErr.Pos, tt.expr, tt.pos)
+		}
+		if !errors.Is(err, tt.cause) || !errors.Is(errors.Unwrap(err), tt.cause) {
+			t.Errorf("%s: error %v does not unwrap to %v", tt.input, err, tt.cause)
+		}
+	}
+
+	var undefined *UndefinedVariableError
+	if _, err := EvalWithVars(mustParse(t, "a + missing"), vars); !errors.As(err, &undefined) || undefined.Name != "missing" {
+		t.Errorf("a + missing: error %v, want an *UndefinedVariableError for missing", err)
+	}
+
+	// Built trees have no spans, so the message has no offset
+	_, err := EvalWithVars(Div(Num(1), Var("count")), vars)
+	var evalErr *EvalError
+	if !errors.As(err, &evalErr) || evalErr.Pos != -1 || err.Error() != `division by zero in "1 / count"` {
+		t.Errorf("built 1 / count: error %v, want one without an offset", err)
+	}
+}
+
+func TestErrorCategories(t *testing.T) {
+	// Syntax errors of the lexer and of the parser alike
+	for _, input := range []string{"1 $ 2", `"abc`, "1 +", "(1", "2 +* 3"} {
+		_, err := ParseString(input)
+		var parseErr *ParseError
+		if !errors.Is(err, ErrSyntax) || !errors.As(err, &parseErr) {
+			t.Errorf("ParseString(%q): error %v, want a *ParseError matching ErrSyntax", input, err)
+		}
+	}
+
+	modes := map[string]func(Expr) error{
+		"Eval":         func(e Expr) error { _, err := Eval(e); return err },
+		"EvalValue":    func(e Expr) error { _, err := EvalValue(e, nil); return err },
+		"EvalInt":      func(e Expr) error { _, err := EvalInt(e); return err },
+		"EvalRat":      func(e Expr) error { _, err := EvalRat(e); return err },
+		"EvalBig":      func(e Expr) error { _, err := EvalBig(e); return err },
+		"EvalDecimal":  func(e Expr) error { _, err := EvalDecimal(e); return err },
+		"EvalComplex":  func(e Expr) error { _, err := EvalComplex(e); return err },
+		"EvalInterval": func(e Expr) error { _, err := EvalInterval(e, nil); return err },
+		"EvalUnits":    func(e Expr) error { _, err := EvalUnits(e); return err },
+		"CompileFunc": func(e Expr) error {
+			f, err := CompileFunc(e)
+			if err != nil {
+				return err
+			}
+			_, err = f(nil)
+			return err
+		},
+		"Program": func(e Expr) error {
+			prog, err := CompileProgram(e)
+			if err != nil {
+				return err
+			}
+			slots, err := prog.Slots(nil)
+			if err != nil {
+				return err
+			}
+			_, err = prog.Run(slots)
+			return err
+		},
+	}
+	categories := []struct {
+		input string
+		want  error
+	}{
+		{"q + 1", ErrUndefinedVariable},
+		{"nope(1)", ErrUnknownFunction},
+		{"1 / 0", ErrDivisionByZero},
+	}
+	for name, eval := range modes {
+		for _, tt := range categories {
+			if err := eval(mustParse(t, tt.input)); !errors.Is(err, tt.want) {
+				t.Errorf("%s(%s): error %v, want %v", name, tt.input, err, tt.want)
+			}
+		}
+		if name == "EvalBig" {
+			continue // which has no %
+		}
+		if err := eval(mustParse(t, "2 % 0")); !errors.Is(err, ErrModuloByZero) {
+			t.Errorf("%s(2 %% 0): error %v, want %v", name, err, ErrModuloByZero)
+		}
+	}
+
+	var undefined *UndefinedVariableError
+	if err := modes["EvalInt"](mustParse(t, "1 + qty")); !errors.As(err, &undefined) || undefined.Name != "qty" {
+		t.Errorf("EvalInt(1 + qty): error %v, want an *UndefinedVariableError for qty", err)
+	}
+	var overflow *OverflowError
+	if _, err := EvalInt(mustParse(t, "9 ^ 99")); !errors.Is(err, ErrOverflow) || !errors.As(err, &overflow) {
+		t.Errorf("EvalInt(9 ^ 99): error %v, want an *OverflowError", err)
+	}
+	for _, err := range []error{
+		func() error { _, err := EvalDecimal(mustParse(t, "9 ^ 99")); return err }(),
+		func() error { _, err := EvalValue(mustParse(t, "100000000d * 1000000"), nil); return err }(),
+	} {
+		if !errors.Is(err, ErrOverflow) {
+			t.Errorf("error %v, want %v", err, ErrOverflow)
+		}
+	}
+}
+
+func TestErrorChains(t *testing.T) {
+	// From Evaluate down to a division inside an argument inside parentheses
+	_, err := EvaluateWithVars("2 * (1 + max(3, 4 / (x - x)))", map[string]float64{"x": 1})
+	var evalErr *EvalError
+	if !errors.Is(err, ErrDivisionByZero) || !errors.As(err, &evalErr) || evalErr.Pos != 16 {
+		t.Errorf("division inside max: error %v, want an *EvalError at offset 16 wrapping ErrDivisionByZero", err)
+	}
+	if want := `division by zero in "4 / (x - x)" at offset 16`; err == nil || err.Error() != want {
+		t.Errorf("division inside max: error %v, want %q", err, want)
+	}
+
+	program := func(input string) error {
+		p, err := CompileProgram(mustParse(t, input))
+		if err != nil {
+			return err
+		}
+		_, err = p.Run([]float64{1})
+		return err
+	}
+	ieee := NewEvaluator(WithDivisionByZero(DivideByZeroIEEE))
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"Run of x / 0", program("x / 0"), ErrDivisionByZero},
+		{"Run of x % 0", program("x % 0"), ErrModuloByZero},
+		{"EvalRat of 0 ^ -1", second(EvalRat(mustParse(t, "0 ^ -1"))), ErrDivisionByZero},
+		{"EvalBig of 0 / 0 as IEEE", second(ieee.EvalBig(mustParse(t, "0 / 0"))), ErrDivisionByZero},
+		{"ToGo of foo(1)", second(ToGo(mustParse(t, "foo(1)"), "f")), ErrUnknownFunction},
+		{"Evaluate of foo(1)", second(Evaluate("foo(1)")), ErrUnknownFunction},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: error %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+
+	// The causes from other packages are kept too
+	var syntaxErr *json.SyntaxError
+	if _, err := DecodeJSON([]byte("{")); !errors.As(err, &syntaxErr) {
+		t.Errorf("DecodeJSON({): error %v, want a *json.SyntaxError", err)
+	}
+	var nan big.ErrNaN
+	if _, err := ieee.EvalBig(mustParse(t, "1/0 - 1/0")); !errors.As(err, &nan) {
+		t.Errorf("EvalBig(1/0 - 1/0): error %T, want a big.ErrNaN", err)
+	}
+	if err := NewEvaluator().RegisterUnit("furlong", 201.168, "zz"); ErrorCode(err) != CodeUnknownUnit || err.Error() != "unit furlong: unknown unit zz" {
+		t.Errorf("RegisterUnit of zz: error %v, want %s", err, CodeUnknownUnit)
+	}
+}
+
+// second returns the error of a call returning a result and an error.
+func second[T any](_ T, err error) error {
+	return err
+}
+
+// end of file
@@ -0,0 +1,139 @@
+package expressionparser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// defaultMaxBodyBytes bounds the requests of NewEvalHandler unless set
+// WithMaxBodyBytes.
+const defaultMaxBodyBytes = 1 << 20
+
+// WithMaxBodyBytes bounds the body of the requests NewEvalHandler accepts
+// to n bytes, 1 MiB by default; a longer body is refused with 413 Request
+// Entity Too Large.
+func WithMaxBodyBytes(n int64) Option {
+	return func(c *config) {
+		c.maxBodyBytes = n
+	}
+}
+
+// evalRequest is the body of a request to the handler of NewEvalHandler.
+type evalRequest struct {
+	Expression string             `json:"expression"`
+	Vars       map[string]float64 `json:"vars"`
+}
+
+// evalResponse is the body of a response of the handler of NewEvalHandler.
+type evalResponse struct {
+	Result *float64           `json:"result,omitempty"`
+	Error  *evalResponseError `json:"error,omitempty"`
+}
+
+// evalResponseError is the error of a response of the handler of
+// NewEvalHandler.
+type evalResponseError struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Position *int   `json:"position,omitempty"` // the byte offset in the expression
+}
+
+// NewEvalHandler returns a handler evaluating the expressions POSTed to it
+// as JSON, as in
+//
+//	{"expression": "price * qty", "vars": {"price": 2.5, "qty": 4}}
+//
+// with an Evaluator configured by opts, the limits WithMaxInputBytes,
+// WithMaxDepth, WithMaxOps and WithLimits among them, and with the context
+// of the request, so that an evaluation stops when the client goes away.
+// It responds with {"result": 10}, or with an error such as
+//
+//	{"error": {"code": "E_EVAL_DIV_ZERO", "message": "...", "position": 6}}
+//
+// whose code is that of ErrorCode and whose position, when the error has
+// one, is the offset in the expression. The status is 400 for a body that
+// is not such a request, 413 for one over WithMaxBodyBytes, 415 for a
+// Content-Type other than application/json, 405 for a method other than
+// POST and 422 for an expression that fails to parse or evaluate. The
+// Evaluator is built once, and each request evaluates on a copy of it with
+// the request's variables; opts that are invalid give every request 500.
+func NewEvalHandler(opts ...Option) http.Handler {
+	opts = append([]Option(nil), opts...)
+	base := NewEvaluator(opts...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if base.err != nil {
+			writeEvalError(w, http.StatusInternalServerError, base.err, "")
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeEvalError(w, http.StatusMethodNotAllowed, withCode(CodeInvalidArgument, fmt.Errorf("method %s is not allowed; use POST", r.Method)), "")
+			return
+		}
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+			writeEvalError(w, http.StatusUnsupportedMediaType, withCode(CodeInvalidArgument, fmt.Errorf("content type must be application/json")), "")
+			return
+		}
+
+		limit := base.cfg.maxBodyBytes
+		if limit <= 0 {
+			limit = defaultMaxBodyBytes
+		}
+		var req evalRequest
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeEvalError(w, http.StatusRequestEntityTooLarge, withCode(CodeLimitInput, fmt.Errorf("request body exceeds the limit of %d bytes", limit)), "")
+				return
+			}
+			writeEvalError(w, http.StatusBadRequest, withCode(CodeDecode, fmt.Errorf("invalid request: %v", err)), "")
+			return
+		}
+		if strings.TrimSpace(req.Expression) == "" {
+			writeEvalError(w, http.StatusBadRequest, withCode(CodeInvalidArgument, fmt.Errorf("invalid request: no expression")), "")
+			return
+		}
+
+		expr, err := ParseString(req.Expression, opts...)
+		if err == nil {
+			ev := *base
+			ev.vars = req.Vars
+			var result float64
+			result, err = ev.EvalContext(r.Context(), expr)
+			if err == nil && isNonFinite(result) {
+				err = withCode(CodeNonFinite, fmt.Errorf("result %s has no JSON representation", formatNumber(result)))
+			}
+			if err == nil {
+				writeEvalResponse(w, http.StatusOK, evalResponse{Result: &result})
+				return
+			}
+		}
+		writeEvalError(w, http.StatusUnprocessableEntity, err, req.Expression)
+	})
+}
+
+// writeEvalError responds with err, positioned in input when it has an
+// offset there.
+func writeEvalError(w http.ResponseWriter, status int, err error, input string) {
+	e := &evalResponseError{Code: ErrorCode(err), Message: err.Error()}
+	if span, ok := errorSpan(err); ok && input != "" && span.Start >= 0 && span.Start <= len(input) {
+		pos := span.Start
+		e.Position = &pos
+	}
+	writeEvalResponse(w, status, evalResponse{Error: e})
+}
+
+// writeEvalResponse responds with the status and body.
+func writeEvalResponse(w http.ResponseWriter, status int, body evalResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// end of file
@@ -0,0 +1,121 @@
+package expressionparser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// post sends body to h, with ctx, as a request of the method and content type, and
+// returns the status and the body of the response.
+func post(ctx context.Context, h http.Handler, method, contentType, body string) (int, string) {
+	r := httptest.NewRequest(method, "/eval", strings.NewReader(body)).WithContext(ctx)
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		return w.Code, "Content-Type " + ct
+	}
+	return w.Code, strings.TrimSpace(w.Body.String())
+}
+
+func TestEvalHandler(t *testing.T) {
+	h := NewEvalHandler(WithMaxBodyBytes(200), WithMaxDepth(5), WithMaxInputBytes(40))
+	tests := []struct {
+		method, contentType, body string
+		status                    int
+		want                      string
+	}{
+		{"POST", "application/json", `{"expression": "price*qty", "vars": {"price": 2.5, "qty": 4}}`, 200, `{"result":10}`},
+		{"POST", "application/json; charset=utf-8", `{"expression": "2 + 3"}`, 200, `{"result":5}`},
+		// Syntax and evaluation errors, positioned
+		{"POST", "application/json", `{"expression": "2 +"}`, 422,
+			`{"error":{"code":"E_SYNTAX_UNEXPECTED_TOKEN","message":"expected number, name, string, '(', '[', or unary '-' or '!' but found end of input at offset 3","position":3}}`},
+		{"POST", "application/json", `{"expression": "x + 1/(y-2)", "vars": {"x": 1, "y": 2}}`, 422,
+			`{"error":{"code":"E_EVAL_DIV_ZERO","message":"division by zero in \"1 / (y - 2)\" at offset 4","position":4}}`},
+		{"POST", "application/json", `{"expression": "q"}`, 422,
+			`{"error":{"code":"E_EVAL_UNDEF_VAR","message":"undefined variable q in \"q\" at offset 0","position":0}}`},
+		{"POST", "application/json", `{"expression": "exp(1000)"}`, 422,
+			`{"error":{"code":"E_EVAL_NON_FINITE","message":"result +Inf has no JSON representation"}}`},
+		// Limits
+		{"POST", "application/json", `{"expression": "((((((1))))))"}`, 422,
+			`{"error":{"code":"E_LIMIT_DEPTH","message":"expression nested more than 5 deep at offset 5","position":5}}`},
+		{"POST", "application/json", `{"expression": "1+1+1+1+1+1+1+1+1+1+1+1+1+1+1+1+1+1+1+1+1"}`, 422,
+			`{"error":{"code":"E_LIMIT_INPUT","message":"input of 41 bytes exceeds the limit of 40"}}`},
+		{"POST", "application/json", `{"expression": "1", "pad": "` + strings.Repeat("x", 200) + `"}`, 413,
+			`{"error":{"code":"E_LIMIT_INPUT","message":"request body exceeds the limit of 200 bytes"}}`},
+		// Requests that are not evaluations
+		{"POST", "text/plain", `{"expression": "1"}`, 415,
+			`{"error":{"code":"E_API_ARGUMENT","message":"content type must be application/json"}}`},
+		{"POST", "", `{"expression": "1"}`, 415,
+			`{"error":{"code":"E_API_ARGUMENT","message":"content type must be application/json"}}`},
+		{"GET", "application/json", "", 405,
+			`{"error":{"code":"E_API_ARGUMENT","message":"method GET is not allowed; use POST"}}`},
+		{"POST", "application/json", `{"expr": "1"}`, 400,
+			`{"error":{"code":"E_DECODE","message":"invalid request: json: unknown field \"expr\""}}`},
+		{"POST", "application/json", `{"expression": "  "}`, 400,
+			`{"error":{"code":"E_API_ARGUMENT","message":"invalid request: no expression"}}`},
+	}
+	for _, tt := range tests {
+		status, body := post(context.Background(), h, tt.method, tt.contentType, tt.body)
+		if status != tt.status || body != tt.want {
+			t.Errorf("%s %s %.40s: %d %s; want %d %s", tt.method, tt.contentType, tt.body, status, body, tt.status, tt.want)
+		}
+	}
+}
+
+func TestEvalHandlerBudgetAndContext(t *testing.T) {
+	status, body := post(context.Background(), NewEvalHandler(WithMaxOps(5)), "POST", "application/json", `{"expression": "1+2+3+4+5+6+7+8"}`)
+	if status != 422 || !strings.Contains(body, `"code":"E_LIMIT_OPS"`) {
+		t.Errorf("over the budget: %d %s", status, body)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	status, body = post(ctx, NewEvalHandler(), "POST", "application/json", `{"expression": "1+2"}`)
+	if status != 422 || body != `{"error":{"code":"E_EVAL_CANCELED","message":"evaluation not started: context canceled"}}` {
+		t.Errorf("canceled: %d %s", status, body)
+	}
+
+	// The default limit of the body is 1 MiB
+	big := `{"expression": "1", "pad": "` + strings.Repeat("x", defaultMaxBodyBytes) + `"}`
+	if status, _ := post(context.Background(), NewEvalHandler(), "POST", "application/json", big); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("a body over 1 MiB: status %d, want 413", status)
+	}
+}
+
+func TestEvalHandlerSharesEvaluator(t *testing.T) {
+	applied := 0
+	NewEvalHandler(func(*config) { applied++ })
+	if applied != 1 {
+		t.Errorf("options applied %d times making the handler, want once", applied)
+	}
+
+	// Requests evaluate on copies, so their variables stay their own
+	h := NewEvalHandler()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			status, body := post(context.Background(), h, "POST", "application/json", fmt.Sprintf(`{"expression": "x * 2", "vars": {"x": %d}}`, i))
+			if want := fmt.Sprintf(`{"result":%d}`, 2*i); status != 200 || body != want {
+				t.Errorf("x = %d: %d %s; want 200 %s", i, status, body, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	status, body := post(context.Background(), NewEvalHandler(WithMaxDepth(-1)), "POST", "application/json", `{"expression": "1"}`)
+	if status != http.StatusInternalServerError || !strings.Contains(body, "WithMaxDepth(-1) is negative") {
+		t.Errorf("invalid options: %d %s", status, body)
+	}
+}
+
+// end of file
@@ -0,0 +1,111 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ParseString parses input as a single expression, which must take up all
+// of it: "2 + 3 4" is an error rather than 2 + 3, as Parse, which stops at
+// the end of the expression, would give. Invalid input gives a *ParseError.
+// The options configure the Parser.
+func ParseString(input string, opts ...Option) (Expr, error) {
+	p := getParser(input, opts)
+	defer putParser(p)
+	expr, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if p.curr.Type != EOF {
+		return nil, p.expected("operator or end of input")
+	}
+	return expr, nil
+}
+
+// Evaluate parses input with ParseString and evaluates it with an Evaluator
+// configured by opts, the default one when there are none. Input that does
+// not parse gives a *ParseError and a failed evaluation an *EvalError,
+// which errors.As tells apart.
+func Evaluate(input string, opts ...Option) (float64, error) {
+	return EvaluateWithVars(input, nil, opts...)
+}
+
+// EvaluateWithVars is Evaluate, resolving variables from vars as
+// EvalWithVars does.
+func EvaluateWithVars(input string, vars map[string]float64, opts ...Option) (float64, error) {
+	expr, err := ParseString(input, opts...)
+	if err != nil {
+		return 0, err
+	}
+	if len(opts) == 0 {
+		return EvalWithVars(expr, vars)
+	}
+	ev := NewEvaluator(opts...)
+	ev.vars = vars
+	return ev.Eval(expr)
+}
+
+// WithChecks makes Validate and Compile check the expression once it
+// parses, as Check does with opts: for unknown functions, wrong argument
+// counts and variables outside an allowed set. It has no effect on
+// evaluation.
+func WithChecks(opts CheckOptions) Option {
+	return func(c *config) {
+		c.checks = &opts
+	}
+}
+
+// Validate reports whether input is an expression, parsing it with
+// ParseString but never evaluating it: input that does not parse gives a
+// *ParseError, and nil means it parses. WithChecks it also checks the
+// expression, every problem Check finds giving a *ParseError at its offset,
+// joined by errors.Join when there are several, so that all are reported
+// at once.
+func Validate(input string, opts ...Option) error {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return err
+	}
+	expr, err := ParseString(input, opts...)
+	if err != nil {
+		return err
+	}
+	return cfg.check(expr)
+}
+
+// check checks expr as WithChecks asks, if it does.
+func (c *config) check(expr Expr) error {
+	if c.checks == nil {
+		return nil
+	}
+	var errs []error
+	for _, problem := range Check(expr, *c.checks) {
+		errs = append(errs, &ParseError{Msg: problem.Message, Pos: problem.Span.Start, code: problem.Code})
+	}
+	return errors.Join(errs...)
+}
+
+// MustParse is ParseString for input known to be valid, such as a constant
+// in the source of a program; it panics with an error that quotes input and
+// wraps the *ParseError when it is not. Use ParseString for input from
+// users.
+func MustParse(input string) Expr {
+	expr, err := ParseString(input)
+	if err != nil {
+		panic(fmt.Errorf("expressionparser: MustParse(%q): %w", input, err))
+	}
+	return expr
+}
+
+// MustEvaluate is Evaluate for input known to evaluate, such as a constant
+// in the source of a program; it panics with an error that quotes input and
+// wraps the cause when it does not. Use Evaluate for input from users.
+func MustEvaluate(input string) float64 {
+	result, err := Evaluate(input)
+	if err != nil {
+		panic(fmt.Errorf("expressionparser: MustEvaluate(%q): %w", input, err))
+	}
+	return result
+}
+
+// end of file
@@ -0,0 +1,151 @@
+package expressionparser
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	if got, err := Evaluate("2 + 3 * 4"); err != nil || got != 14 {
+		t.Errorf("Evaluate(2 + 3 * 4) = %v, %v; want 14", got, err)
+	}
+	if got, err := EvaluateWithVars("x * 2", map[string]float64{"x": 21}); err != nil || got != 42 {
+		t.Errorf("EvaluateWithVars(x * 2) = %v, %v; want 42", got, err)
+	}
+
+	// A syntax error is a *ParseError and no *EvalError, and the reverse
+	for input, msg := range map[string]string{
+		"2 + 3 4": "expected operator or end of input but found number 4 at offset 6",
+		"(1":      "expected ')' to close '(' at offset 0 but found end of input at offset 2",
+	} {
+		_, err := Evaluate(input)
+		var parseErr *ParseError
+		var evalErr *EvalError
+		if !errors.As(err, &parseErr) || errors.As(err, &evalErr) || err.Error() != msg {
+			t.Errorf("Evaluate(%s): error %v, want the *ParseError %q", input, err, msg)
+		}
+	}
+	_, err := EvaluateWithVars("x / (x - x)", map[string]float64{"x": 1})
+	var parseErr *ParseError
+	var evalErr *EvalError
+	if !errors.As(err, &evalErr) || errors.As(err, &parseErr) || !errors.Is(err, ErrDivisionByZero) || evalErr.Pos != 0 {
+		t.Errorf("Evaluate(x / (x - x)): error %v, want an *EvalError for division by zero", err)
+	}
+	if _, err := Evaluate("y + 1"); !errors.Is(err, ErrUndefinedVariable) {
+		t.Errorf("Evaluate(y + 1): error %v, want an undefined variable", err)
+	}
+
+	// Options configure the parser and the evaluator alike
+	if got, err := Evaluate("1 / 0", WithDivisionByZero(DivideByZeroValue(0))); err != nil || got != 0 {
+		t.Errorf("Evaluate(1 / 0) giving 0 = %v, %v", got, err)
+	}
+	if got, err := EvaluateWithVars("x / 0", map[string]float64{"x": 1}, WithDivisionByZero(DivideByZeroIEEE)); err != nil || !math.IsInf(got, 1) {
+		t.Errorf("EvaluateWithVars(x / 0) under IEEE = %v, %v; want +Inf", got, err)
+	}
+	var tooLarge *InputTooLargeError
+	if _, err := Evaluate("12345", WithMaxInputBytes(3)); !errors.As(err, &tooLarge) {
+		t.Errorf("Evaluate of 5 bytes over a limit of 3: error %v", err)
+	}
+	if _, err := Evaluate("1", WithMaxOps(-1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Evaluate with WithMaxOps(-1): error %v", err)
+	}
+}
+
+func TestMust(t *testing.T) {
+	if got := MustEvaluate("2 ^ 10"); got != 1024 {
+		t.Errorf("MustEvaluate(2 ^ 10) = %v, want 1024", got)
+	}
+	if got := Format(MustParse("1+x*2")); got != "1 + x * 2" {
+		t.Errorf("MustParse(1+x*2) = %s", got)
+	}
+
+	tests := []struct {
+		name  string
+		call  func()
+		msg   string
+		cause interface{}
+	}{
+		{"MustParse", func() { MustParse("2 + ") }, `expressionparser: MustParse("2 + "): expected number, name, string, '(', '[', or unary '-' or '!' but found end of input at offset 4`, new(*ParseError)},
+		{"MustEvaluate", func() { MustEvaluate("2 + ") }, `expressionparser: MustEvaluate("2 + "): expected number, name, string, '(', '[', or unary '-' or '!' but found end of input at offset 4`, new(*ParseError)},
+		{"MustEvaluate", func() { MustEvaluate("1 / 0") }, `expressionparser: MustEvaluate("1 / 0"): division by zero in "1 / 0" at offset 0`, new(*EvalError)},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				r := recover()
+				err, ok := r.(error)
+				if !ok {
+					t.Errorf("%s: panic value %#v, want an error", tt.name, r)
+					return
+				}
+				if err.Error() != tt.msg || !errors.As(err, tt.cause) {
+					t.Errorf("%s: panic %q, want %q wrapping a %T", tt.name, err, tt.msg, tt.cause)
+				}
+			}()
+			tt.call()
+		}()
+	}
+}
+
+func TestValidate(t *testing.T) {
+	checks := WithChecks(CheckOptions{Variables: map[string]bool{"x": true}})
+	tests := []struct {
+		input string
+		plain string // Validate without options, "" for nil
+		check string // Validate WithChecks, one line per problem
+	}{
+		{"1 + x", "", ""},
+		{"1 + y", "", "unknown variable y at offset 4"},
+		{"2 + ", "expected number, name, string, '(', '[', or unary '-' or '!' but found end of input at offset 4",
+			"expected number, name, string, '(', '[', or unary '-' or '!' but found end of input at offset 4"},
+		{"1 2", "expected operator or end of input but found number 2 at offset 2",
+			"expected operator or end of input but found number 2 at offset 2"},
+		{"foo(1) + sqrt(1, 2) + y", "",
+			"unknown function foo at offset 0\nsqrt expects 1 argument(s), got 2 at offset 9\nunknown variable y at offset 22"},
+	}
+	for _, tt := range tests {
+		for _, run := range []struct {
+			opts []Option
+			want string
+		}{{nil, tt.plain}, {[]Option{checks}, tt.check}} {
+			err := Validate(tt.input, run.opts...)
+			if run.want == "" {
+				if err != nil {
+					t.Errorf("Validate(%q) with %d options: %v", tt.input, len(run.opts), err)
+				}
+				continue
+			}
+			if err == nil || err.Error() != run.want {
+				t.Errorf("Validate(%q) with %d options: error %v, want %q", tt.input, len(run.opts), err, run.want)
+				continue
+			}
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Errorf("Validate(%q): error %T, want a *ParseError", tt.input, err)
+			}
+		}
+	}
+
+	// Every problem is its own *ParseError in the joined error
+	err := Validate("foo(1) + sqrt(1, 2) + y", checks)
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 3 {
+		t.Fatalf("Validate with three problems: error %T, want three joined errors", err)
+	}
+	for i, e := range joined.Unwrap() {
+		if _, ok := e.(*ParseError); !ok {
+			t.Errorf("problem %d is a %T, want a *ParseError", i, e)
+		}
+	}
+
+	// Validate never evaluates, though WithChecks sees a constant zero divisor
+	if err := Validate("1 / 0"); err != nil {
+		t.Errorf("Validate(1 / 0): %v", err)
+	}
+	if err := Validate("1 / 0", checks); err == nil || err.Error() != "division by zero at offset 0" {
+		t.Errorf("Validate(1 / 0) WithChecks: error %v", err)
+	}
+}
+
+// end of file
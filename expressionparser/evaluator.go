@@ -0,0 +1,480 @@
+package expressionparser
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"strconv"
+)
+
+// config holds the settings applied by Options.
+type config struct {
+	limits        Limits
+	lenientNaN    bool
+	floorDivision bool
+	flooredModulo bool
+	bigPrecision  uint
+	zeroDivision  DivisionByZero
+	undefined     UndefinedVariables
+	nonFinite     NonFiniteResults
+	maxOps        int
+	memoize       bool
+	angleUnit     AngleUnit
+
+	collectRowErrors bool
+	batchWorkers     int
+
+	roundResults bool
+	roundDigits  int
+	roundMode    RoundingMode
+
+	decimalScale    int
+	decimalScaleSet bool
+	rounding        RoundingMode
+	strictDecimals  bool
+
+	literalTolerance float64
+	extendedDivision bool
+
+	epsilon float64
+
+	integrationTolerance float64
+	solveIterations      int
+
+	checks *CheckOptions
+
+	maxDepth      int
+	maxInputBytes int
+	arena         *Arena
+	romanNumerals bool
+
+	sexagesimal      bool
+	sexagesimalCarry bool
+
+	beforeNode func(Expr) error
+	afterNode  func(Expr, Value, error)
+	observer   Observer
+
+	cellReferences bool
+	cells          CellResolver
+
+	strictFields bool
+	maxBodyBytes int64
+
+	continueOnError bool
+}
+
+// Option configures an Evaluator, and the Parser and the helpers such as
+// Evaluate and Compile that take options. Options apply in order, so the
+// later of two that set the same thing wins, and each applies where it has
+// a meaning and is ignored elsewhere; no options give the defaults. An
+// option out of range, or contradicting another, gives an error wrapping
+// ErrInvalidOption: from Compile and the helpers at once, and from every
+// evaluation or parse of an Evaluator or Parser made with it.
+type Option func(*config)
+
+// WithLimits makes the evaluator reject trees whose metrics exceed limits
+// before evaluating them.
+func WithLimits(limits Limits) Option {
+	return func(c *config) {
+		c.limits = limits
+	}
+}
+
+// WithLenientNaN makes a builtin called outside its domain, such as sqrt of a
+// negative number or the logarithm of zero, return NaN instead of an error.
+func WithLenientNaN() Option {
+	return func(c *config) {
+		c.lenientNaN = true
+	}
+}
+
+// WithFloorDivision makes / in integer mode round the quotient toward
+// negative infinity, and % take the sign of the divisor to match, instead of
+// rejecting divisions that are not exact.
+func WithFloorDivision() Option {
+	return func(c *config) {
+		c.floorDivision = true
+	}
+}
+
+// WithFlooredModulo makes % take the sign of the divisor, as the MOD
+// function of spreadsheets does: -7 % 3 is 2 and 7 % -3 is -2. By default %
+// is the truncated remainder, with the sign of the dividend, as in Go and C
+// and as math.Mod computes it: -7 % 3 is -1 and 7 % -3 is 1. The option
+// applies to every evaluation mode; in integer mode WithFloorDivision
+// implies it.
+func WithFlooredModulo() Option {
+	return func(c *config) {
+		c.flooredModulo = true
+	}
+}
+
+// mod returns the remainder of a / b under the configured sign convention.
+func (ev *Evaluator) mod(a, b float64) float64 {
+	r := math.Mod(a, b)
+	if ev.cfg.flooredModulo && r != 0 && (r < 0) != (b < 0) {
+		r += b
+	}
+	return r
+}
+
+// DivisionByZero is a policy for / and % with a zero divisor.
+type DivisionByZero struct {
+	ieee     bool
+	replaced bool
+	value    float64
+}
+
+var (
+	// DivideByZeroError makes division or modulo by zero an error naming the
+	// position of the divisor. It is the default.
+	DivideByZeroError = DivisionByZero{}
+
+	// DivideByZeroIEEE follows IEEE 754: 1/0 is +Inf, -1/0 is -Inf, and 0/0
+	// and x % 0 are NaN. Modes that have no infinities or NaN still report
+	// an error.
+	DivideByZeroIEEE = DivisionByZero{ieee: true}
+)
+
+// DivideByZeroValue makes division or modulo by zero give value, as
+// spreadsheets commonly give 0.
+func DivideByZeroValue(value float64) DivisionByZero {
+	return DivisionByZero{replaced: true, value: value}
+}
+
+// WithDivisionByZero sets what / and % do with a zero divisor, in Eval and
+// in every other evaluation mode.
+func WithDivisionByZero(policy DivisionByZero) Option {
+	return func(c *config) {
+		c.zeroDivision = policy
+	}
+}
+
+// UndefinedVariables is a policy for variables that have no value.
+type UndefinedVariables struct {
+	nan      bool
+	replaced bool
+	value    float64
+}
+
+var (
+	// UndefinedError makes a variable without a value an error, an
+	// *UndefinedVariableError naming it. It is the default; MissingVariables
+	// lists every such variable of an expression in advance.
+	UndefinedError = UndefinedVariables{}
+
+	// UndefinedNaN makes a variable without a value NaN, so that evaluation
+	// carries on and the result is usually NaN.
+	UndefinedNaN = UndefinedVariables{nan: true}
+)
+
+// UndefinedDefault makes a variable without a value give value, as
+// reports commonly take a missing figure as 0.
+func UndefinedDefault(value float64) UndefinedVariables {
+	return UndefinedVariables{replaced: true, value: value}
+}
+
+// WithUndefinedVariables sets what Eval, EvalValue and the functions made by
+// CompileFunc do with a variable that has no value, wherever it is
+// evaluated, including in the arguments of calls and in the branches of
+// conditionals and logical operators they do not skip.
+func WithUndefinedVariables(policy UndefinedVariables) Option {
+	return func(c *config) {
+		c.undefined = policy
+	}
+}
+
+// undefinedVariable applies the undefined variable policy to v.
+func (ev *Evaluator) undefinedVariable(v *Variable) (Value, error) {
+	policy := ev.cfg.undefined
+	switch {
+	case policy.replaced:
+		return NumberValue(policy.value), nil
+	case policy.nan:
+		return NumberValue(math.NaN()), nil
+	}
+	return Value{}, &UndefinedVariableError{Name: v.Name, Span: v.Span}
+}
+
+// isDivision reports whether op is / or %, the operators subject to the
+// division by zero policy.
+func isDivision(op Token) bool {
+	return op.Type == DIV || op.Type == MOD
+}
+
+// zeroDivision applies the division by zero policy to op with the given
+// divisor: it returns the value to substitute, or ieee set when the mode
+// should compute the IEEE result itself, or the error to report.
+func (ev *Evaluator) zeroDivision(op Token, divisor Expr) (value float64, ieee bool, err error) {
+	policy := ev.cfg.zeroDivision
+	switch {
+	case policy.replaced:
+		return policy.value, false, nil
+	case policy.ieee:
+		return 0, true, nil
+	}
+	return 0, false, zeroDivisorError(op, divisor)
+}
+
+// zeroDivisorError reports division or modulo by zero, with the position of
+// the divisor when it is known.
+func zeroDivisorError(op Token, divisor Expr) error {
+	err := ErrDivisionByZero
+	if op.Type == MOD {
+		err = ErrModuloByZero
+	}
+	if span := SpanOf(divisor); span != (Span{}) {
+		return atOffset(err, span.Start)
+	}
+	return err
+}
+
+// noIEEEError reports division or modulo by zero under DivideByZeroIEEE in a
+// mode whose values have no infinities or NaN.
+func noIEEEError(op Token, divisor Expr, mode string) error {
+	return fmt.Errorf("%w has no IEEE result in %s mode", zeroDivisorError(op, divisor), mode)
+}
+
+// WithComparisonEpsilon makes the comparisons of Eval, EvalValue and
+// CompileFunc treat two finite numbers a and b as equal when
+//
+//	|a - b| <= epsilon * max(1, |a|, |b|)
+//
+// that is, within epsilon of each other below 1 in magnitude and within a
+// relative epsilon above it, so that with epsilon 1e-9, 0.1 + 0.2 == 0.3
+// holds. <= and >= hold for numbers so equal, and < and > do not, so that
+// x < y is still !(x >= y); numbers further apart compare as before.
+// Infinities equal only themselves and NaN equals nothing. Zero, the default, compares exactly.
+func WithComparisonEpsilon(epsilon float64) Option {
+	return func(c *config) {
+		c.epsilon = epsilon
+	}
+}
+
+// equalNumbers reports whether two numbers are equal under the comparison epsilon.
+func (ev *Evaluator) equalNumbers(a, b float64) bool {
+	eps := ev.cfg.epsilon
+	if a == b || eps == 0 || math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return a == b
+	}
+	return math.Abs(a-b) <= eps*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+// WithMaxOps caps the work of each evaluation by Eval, EvalValue and
+// EvalContext at n operations, where evaluating any node of the tree counts
+// as one: "2 + 3" takes three. An evaluation that would go beyond the cap
+// stops with a *BudgetExceededError. Nodes skipped by short-circuiting or on
+// the branch of ?: not taken cost nothing. Zero, the default, means no cap.
+func WithMaxOps(n int) Option {
+	return func(c *config) {
+		c.maxOps = n
+	}
+}
+
+// WithRoundTo rounds the numeric result of Eval, EvalValue, EvalContext,
+// EvalTrace, CompileFunc and EvalBatch to digits decimal places with mode;
+// intermediate values are not rounded. Rounding works on the shortest
+// decimal form of the result, the one Format prints, so 2.675 rounds to
+// 2.68 with RoundHalfUp although its float64 value is slightly below
+// 2.675. EvalDecimal rounds every operation to its scale instead, following
+// WithRounding.
+func WithRoundTo(digits int, mode RoundingMode) Option {
+	return func(c *config) {
+		c.roundResults = true
+		c.roundDigits = digits
+		c.roundMode = mode
+	}
+}
+
+// roundResult applies WithRoundTo to a final result.
+func (ev *Evaluator) roundResult(value Value) Value {
+	if !ev.cfg.roundResults || value.kind != NumberKind {
+		return value
+	}
+	return NumberValue(roundDecimal(value.num, ev.cfg.roundDigits, ev.cfg.roundMode))
+}
+
+// roundDecimal rounds v to digits decimal places, or to a multiple of
+// 10^-digits when digits is negative. NaN and infinities are unchanged.
+func roundDecimal(v float64, digits int, mode RoundingMode) float64 {
+	return roundDecimalBy(v, digits, func(n, den *big.Int) *big.Int {
+		return roundQuo(n, den, mode)
+	})
+}
+
+// roundDecimalBy rounds v as roundDecimal does, with quo rounding the
+// quotient of the scaled shortest decimal form of v to an integer.
+func roundDecimalBy(v float64, digits int, quo func(n, den *big.Int) *big.Int) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(v, 'g', -1, 64))
+	num, den := new(big.Int).Set(r.Num()), new(big.Int).Set(r.Denom())
+	exp := digits
+	if exp < 0 {
+		exp = -exp
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)
+	if digits >= 0 {
+		num.Mul(num, scale)
+	} else {
+		den.Mul(den, scale)
+	}
+
+	rounded := new(big.Rat).SetInt(quo(num, den))
+	if digits >= 0 {
+		rounded.Quo(rounded, new(big.Rat).SetInt(scale))
+	} else {
+		rounded.Mul(rounded, new(big.Rat).SetInt(scale))
+	}
+	f, _ := rounded.Float64()
+	return f
+}
+
+// NonFiniteResults is a policy for NaN and infinite values met during evaluation.
+type NonFiniteResults int
+
+const (
+	// AllowNonFinite lets NaN and infinities propagate as IEEE 754
+	// arithmetic does. It is the default.
+	AllowNonFinite NonFiniteResults = iota
+
+	// RejectNonFinite makes the first NaN or infinite value computed a
+	// *NonFiniteError, whether or not it would reach the result.
+	RejectNonFinite
+)
+
+// WithNonFiniteResults sets whether Eval and EvalValue accept NaN and
+// infinite values, as produced by sqrt(-1) under WithLenientNaN, by 10^400
+// or by a variable holding one. Under RejectNonFinite every intermediate
+// value is checked as it is computed, so the error names the subexpression
+// that produced the value rather than the whole expression.
+func WithNonFiniteResults(policy NonFiniteResults) Option {
+	return func(c *config) {
+		c.nonFinite = policy
+	}
+}
+
+// NonFiniteError reports a NaN or infinite value computed under RejectNonFinite.
+type NonFiniteError struct {
+	Value float64
+	Expr  Expr // the subexpression that first produced the value
+}
+
+// Error names the value, the subexpression and, when known, its offset.
+func (e *NonFiniteError) Error() string {
+	msg := fmt.Sprintf("%s produced by %s", formatNumber(e.Value), Format(e.Expr))
+	if span := SpanOf(e.Expr); span != (Span{}) {
+		return fmt.Sprintf("%s at offset %d", msg, span.Start)
+	}
+	return msg
+}
+
+// isNonFinite reports whether v is NaN or an infinity.
+func isNonFinite(v float64) bool {
+	return math.IsNaN(v) || math.IsInf(v, 0)
+}
+
+// Evaluator evaluates expressions with a configuration and a set of
+// variables fixed up front and reused across evaluations.
+//
+// Configure the evaluator, set its variables and register its functions
+// first. After that, Eval may be called from any number of goroutines at
+// once, since it only reads the evaluator, as may every other evaluation
+// method and the functions made by CompileFunc: what an evaluation writes,
+// such as the values it memoizes or the statistics it collects, belongs to
+// that evaluation, and the random source and the memoization index shared
+// by evaluations are locked. Each evaluation should get its own variables,
+// as a map or an Environment, unless they are only read. SetVar, SetVars,
+// RegisterFunc, RegisterUnit and SetSeed are for setting up and are not safe
+// to call concurrently with evaluations or with each other.
+type Evaluator struct {
+	cfg      config
+	vars     map[string]float64
+	funcs    map[string]builtin
+	units    map[string]unitDef
+	progress *progress       // set on the copy made by EvalContext
+	trace    *tracer         // set on the copy made by EvalTrace
+	stats    *statsCollector // set on the copy made by EvalWithStats
+	memo     *memo           // set on the copy made for an evaluation WithMemoization
+
+	memoIndex *memoIndex    // shared by the copies; set WithMemoization
+	random    *randomSource // shared by the copies; nil in evaluators not made by NewEvaluator
+	patterns  *patternCache // shared by the copies; nil in evaluators not made by NewEvaluator
+
+	excel bool // set by RegisterExcelFunctions
+
+	err error // of the options, returned by every evaluation
+}
+
+// The evaluator behind the package-level Eval
+var defaultEvaluator = NewEvaluator()
+
+// NewEvaluator returns an evaluator configured by opts, with no variables.
+func NewEvaluator(opts ...Option) *Evaluator {
+	ev := &Evaluator{random: newRandomSource(rand.Int63()), patterns: &patternCache{}}
+	ev.cfg, ev.err = newConfig(opts)
+	if ev.cfg.memoize {
+		ev.memoIndex = &memoIndex{}
+	}
+	return ev
+}
+
+// SetVar sets the value of a variable for later evaluations.
+func (ev *Evaluator) SetVar(name string, value float64) {
+	if ev.vars == nil {
+		ev.vars = map[string]float64{}
+	}
+	ev.vars[name] = value
+}
+
+// SetVars sets every variable in vars, keeping the others already set. The
+// map is copied, so later changes to it do not affect the evaluator.
+func (ev *Evaluator) SetVars(vars map[string]float64) {
+	for name, value := range vars {
+		ev.SetVar(name, value)
+	}
+}
+
+// RegisterFunc makes fn callable from expressions under name, with any
+// number of arguments. A registered function takes precedence over a builtin
+// of the same name, and registering a name again replaces the previous
+// function. An error returned by fn is returned from Eval, wrapped with the
+// function name in an *EvalError locating the call.
+func (ev *Evaluator) RegisterFunc(name string, fn func(args ...float64) (float64, error)) {
+	if ev.funcs == nil {
+		ev.funcs = map[string]builtin{}
+	}
+	ev.funcs[name] = builtin{variadic: true, impure: true, fn: func(args []float64) (float64, error) {
+		return fn(args...)
+	}}
+	if index := ev.memoIndex; index != nil {
+		// Calls to name are no longer pure
+		index.mu.Lock()
+		index.root = nil
+		index.mu.Unlock()
+	}
+}
+
+// Eval evaluates expr to a number with EvalValue. A bool result is
+// returned as 1 or 0, and a string result is an error.
+func (ev *Evaluator) Eval(expr Expr) (float64, error) {
+	return ev.EvalContext(context.Background(), expr)
+}
+
+// admit checks the options, and expr against the configured limits, before
+// evaluation.
+func (ev *Evaluator) admit(expr Expr) error {
+	if ev.err != nil {
+		return ev.err
+	}
+	if ev.cfg.limits == (Limits{}) {
+		return nil
+	}
+	return WithinLimits(Metrics(expr), ev.cfg.limits)
+}
+
+// end of file
@@ -0,0 +1,642 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestShortCircuit(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"denominator != 0 && total/denominator > 2", 0},
+		{"0 && 1/0", 0},
+		{"0 && undefined", 0},
+		{"1 || 1/0", 1},
+		{"1 || undefined", 1},
+		{"denominator == 0 || total/denominator > 2", 1},
+		{"1 ? 2 : 1/0", 2},
+		{"0 ? undefined : 3", 3},
+		{"denominator ? total/denominator : -1", -1},
+	}
+	vars := map[string]float64{"denominator": 0, "total": 10}
+	for _, tt := range tests {
+		got, err := EvaluateWithVars(tt.input, vars)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluatorReuse(t *testing.T) {
+	ev := NewEvaluator(WithFlooredModulo())
+	vars := map[string]float64{"a": 7, "b": 2}
+	ev.SetVars(vars)
+	vars["a"] = 100 // copied: not seen
+	ev.SetVar("c", -1)
+
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"-a % b", 1},
+		{"a % b + c", 0},
+		{"let a = -9 in a % b", 1},
+		{"a * b", 14},
+	}
+	for round := 0; round < 2; round++ {
+		for _, tt := range tests {
+			if got, err := ev.Eval(mustParse(t, tt.input)); err != nil || got != tt.want {
+				t.Errorf("round %d: %q = %v, %v; want %v", round, tt.input, got, err, tt.want)
+			}
+		}
+	}
+	if got, err := Eval(mustParse(t, "-7 % 2")); err != nil || got != -1 {
+		t.Errorf("package Eval(-7 %% 2) = %v, %v; want -1, unaffected by the evaluator", got, err)
+	}
+}
+
+func TestEvaluatorOptionError(t *testing.T) {
+	ev := NewEvaluator(WithMaxOps(-1))
+	for i := 0; i < 2; i++ {
+		if _, err := ev.Eval(Num(1)); err == nil {
+			t.Errorf("evaluation %d with an invalid option: no error", i)
+		}
+	}
+}
+
+// TestEvaluatorConcurrent shares one evaluator, its variables set up front,
+// between goroutines, as the Evaluator documents; run it with -race.
+func TestEvaluatorConcurrent(t *testing.T) {
+	ev := NewEvaluator(WithMemoization())
+	ev.SetVar("k", 3)
+	ev.RegisterFunc("twice", func(args ...float64) (float64, error) {
+		return 2 * args[0], nil
+	})
+	ev.SetSeed(1)
+	inputs := []string{"k * x + twice(x)", "sqrt(k ^ 2 + 16) * x", "rand() < 1 ? x : 0", "let y = x * k in y - x"}
+	exprs := make([]Expr, len(inputs))
+	for i, input := range inputs {
+		exprs[i] = mustParse(t, input)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				x := float64(g*1000 + i)
+				env := NewEnvironment(nil)
+				env.SetNumber("x", x)
+				want := []float64{5 * x, 5 * x, x, 2 * x}
+				for j, expr := range exprs {
+					got, err := ev.EvalIn(expr, env)
+					if err != nil || math.Abs(got.num-want[j]) > 1e-9*math.Abs(want[j]) {
+						t.Errorf("%q with x = %v: %v, %v; want %v", inputs[j], x, got, err, want[j])
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// TestCompiledConcurrent hammers one compiled form of an expression from 32
+// goroutines at once, each with its own bindings; run it with -race.
+func TestCompiledConcurrent(t *testing.T) {
+	const input = "let s = a + b in s * s - twice(a * b) + (a > b ? 1 : 0)"
+	ev := NewEvaluator(WithMemoization())
+	ev.RegisterFunc("twice", func(args ...float64) (float64, error) {
+		return 2 * args[0], nil
+	})
+	expr := mustParse(t, input)
+	fn, err := ev.CompileFunc(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := CompileProgram(mustParse(t, "let s = a + b in s * s - 2 * (a * b) + (a > b ? 1 : 0)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := program.Names()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				a, b := float64(g), float64(i)
+				want := a*a + b*b
+				if a > b {
+					want++
+				}
+				vars := map[string]float64{"a": a, "b": b}
+				slots := make([]float64, len(names))
+				for j, name := range names {
+					slots[j] = vars[name]
+				}
+				env := NewEnvironment(nil)
+				env.SetNumber("a", a)
+				env.SetNumber("b", b)
+				results := map[string]func() (float64, error){
+					"CompileFunc": func() (float64, error) { return fn(vars) },
+					"Run":         func() (float64, error) { return program.Run(slots) },
+					"EvalIn": func() (float64, error) {
+						v, err := ev.EvalIn(expr, env)
+						return v.num, err
+					},
+				}
+				for how, result := range results {
+					if got, err := result(); err != nil || got != want {
+						t.Errorf("%s with a = %v, b = %v: %v, %v; want %v", how, a, b, got, err, want)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestRegisterFunc(t *testing.T) {
+	errNegative := errors.New("amount is negative")
+	ev := NewEvaluator()
+	ev.RegisterFunc("vat", func(args ...float64) (float64, error) {
+		if args[0] < 0 {
+			return 0, errNegative
+		}
+		return args[0] * 0.2, nil
+	})
+	ev.RegisterFunc("count", func(args ...float64) (float64, error) {
+		return float64(len(args)), nil
+	})
+	ev.RegisterFunc("sqrt", func(args ...float64) (float64, error) {
+		return -1, nil
+	})
+
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"amount + vat(amount)", 120},
+		{"vat(vat(amount))", 4},
+		{"count()", 0},
+		{"count(1, 2, amount)", 3},
+		{"sqrt(4)", -1}, // replaces the builtin
+	}
+	ev.SetVar("amount", 100)
+	for _, tt := range tests {
+		if got, err := ev.Eval(mustParse(t, tt.input)); err != nil || got != tt.want {
+			t.Errorf("%q = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+	if got, err := Eval(mustParse(t, "sqrt(4)")); err != nil || got != 2 {
+		t.Errorf("package Eval(sqrt(4)) = %v, %v; want the builtin's 2", got, err)
+	}
+
+	_, err := ev.Eval(mustParse(t, "1 + vat(-amount)"))
+	if !errors.Is(err, errNegative) {
+		t.Fatalf("vat(-amount): error %v, want the function's error", err)
+	}
+	var evalErr *EvalError
+	if !errors.As(err, &evalErr) || evalErr.Pos != 4 || Format(evalErr.Expr) != "vat(-amount)" {
+		t.Errorf("vat(-amount): error %#v, want an *EvalError at the call, offset 4", err)
+	}
+	if want := `vat: amount is negative in "vat(-amount)" at offset 4`; err.Error() != want {
+		t.Errorf("vat(-amount): error %q, want %q", err, want)
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	nan := math.NaN()
+	inputs := []string{"1 / 0", "-1 / 0", "0 / 0", "1 % 0", "-1 % 0", "0 % 0"}
+	tests := []struct {
+		name   string
+		policy DivisionByZero
+		want   []float64 // of each input, in Eval
+		ints   []int64   // of each input, in EvalInt; nil for errors
+	}{
+		{"IEEE", DivideByZeroIEEE, []float64{math.Inf(1), math.Inf(-1), nan, nan, nan, nan}, nil},
+		{"Value(0)", DivideByZeroValue(0), []float64{0, 0, 0, 0, 0, 0}, []int64{0, 0, 0, 0, 0, 0}},
+		{"Value(-1)", DivideByZeroValue(-1), []float64{-1, -1, -1, -1, -1, -1}, []int64{-1, -1, -1, -1, -1, -1}},
+	}
+	for _, tt := range tests {
+		ev := NewEvaluator(WithDivisionByZero(tt.policy))
+		for i, input := range inputs {
+			expr := mustParse(t, input)
+			got, err := ev.Eval(expr)
+			if want := tt.want[i]; err != nil || got != want && !(math.IsNaN(got) && math.IsNaN(want)) {
+				t.Errorf("%s under %s = %v, %v; want %v", input, tt.name, got, err, want)
+			}
+			n, err := ev.EvalInt(expr)
+			if tt.ints == nil {
+				if err == nil {
+					t.Errorf("EvalInt(%s) under %s = %v, want an error", input, tt.name, n)
+				}
+			} else if err != nil || n != tt.ints[i] {
+				t.Errorf("EvalInt(%s) under %s = %v, %v; want %v", input, tt.name, n, err, tt.ints[i])
+			}
+		}
+	}
+	if _, err := NewEvaluator(WithDivisionByZero(DivideByZeroValue(0.5))).EvalInt(mustParse(t, "1 / 0")); err == nil {
+		t.Error("EvalInt(1 / 0) giving 0.5: no error")
+	}
+
+	// The default is an error, whose cause gives the offset of the divisor
+	for _, input := range append(inputs, "(2 + 3) / (1 - 1)", "x % (y - y)") {
+		expr := mustParse(t, input)
+		divisor := SpanOf(expr.(*BinaryOp).Right).Start
+		want := ErrDivisionByZero
+		if strings.Contains(input, "%") {
+			want = ErrModuloByZero
+		}
+		vars := map[string]float64{"x": 1, "y": 2}
+		_, err := EvalWithVars(expr, vars)
+		var evalErr *EvalError
+		if !errors.Is(err, want) || !errors.As(err, &evalErr) {
+			t.Errorf("%s: error %v, want an *EvalError for %v", input, err, want)
+			continue
+		}
+		if cause := fmt.Sprintf("%v at offset %d", want, divisor); evalErr.Err.Error() != cause {
+			t.Errorf("%s: cause %q, want %q", input, evalErr.Err, cause)
+		}
+		ev := NewEvaluator()
+		ev.SetVars(vars)
+		if _, err := ev.EvalInt(expr); !errors.Is(err, want) || !strings.HasSuffix(err.Error(), fmt.Sprintf(" at offset %d", divisor)) {
+			t.Errorf("EvalInt(%s): error %v, want %v at offset %d", input, err, want, divisor)
+		}
+	}
+}
+
+func TestModulo(t *testing.T) {
+	truncated, floored := NewEvaluator(), NewEvaluator(WithFlooredModulo())
+	tests := []struct {
+		input              string
+		truncated, floored float64
+	}{
+		{"7 % 3", 1, 1},
+		{"-7 % 3", -1, 2},
+		{"7 % -3", 1, -2},
+		{"-7 % -3", -1, -1},
+		{"6 % -3", 0, 0},
+		{"-6 % 3", 0, 0},
+		{"5.5 % 2", 1.5, 1.5},
+		{"-5.5 % 2", -1.5, 0.5},
+	}
+	for _, tt := range tests {
+		expr := mustParse(t, tt.input)
+		if got, err := truncated.Eval(expr); err != nil || got != tt.truncated {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.truncated)
+		}
+		if got, err := floored.Eval(expr); err != nil || got != tt.floored {
+			t.Errorf("%s floored = %v, %v; want %v", tt.input, got, err, tt.floored)
+		}
+	}
+
+	// A zero divisor follows the division by zero policy under either convention
+	for _, input := range []string{"7 % 0", "-7 % 0", "7 % -0"} {
+		expr := mustParse(t, input)
+		for _, floored := range []bool{false, true} {
+			with := func(opts ...Option) *Evaluator {
+				if floored {
+					opts = append(opts, WithFlooredModulo())
+				}
+				return NewEvaluator(opts...)
+			}
+			if _, err := with().Eval(expr); !errors.Is(err, ErrModuloByZero) {
+				t.Errorf("%s, floored %v: error %v, want ErrModuloByZero", input, floored, err)
+			}
+			if got, err := with(WithDivisionByZero(DivideByZeroIEEE)).Eval(expr); err != nil || !math.IsNaN(got) {
+				t.Errorf("%s under DivideByZeroIEEE, floored %v = %v, %v; want NaN", input, floored, got, err)
+			}
+			if got, err := with(WithDivisionByZero(DivideByZeroValue(0))).Eval(expr); err != nil || got != 0 {
+				t.Errorf("%s giving 0, floored %v = %v, %v; want 0", input, floored, got, err)
+			}
+		}
+	}
+}
+
+func TestRejectNonFinite(t *testing.T) {
+	tests := []struct {
+		input  string
+		origin string // the subexpression reported
+		pos    int
+		value  float64
+	}{
+		{"1 + 2 * (sqrt(-1) + 3) - 4", "sqrt(-1)", 9, math.NaN()},
+		{"max(1, ln(-1) * 2)", "ln(-1)", 7, math.NaN()},
+		{"(10 ^ 400) / 2 + 1", "10 ^ 400", 0, math.Inf(1)},
+		{"0 * (10 ^ 400)", "10 ^ 400", 4, math.Inf(1)},
+		{"big * 10 - big * 10", "big * 10", 0, math.Inf(1)},
+		{"1 + bad", "bad", 4, math.NaN()},
+	}
+	opts := []Option{WithNonFiniteResults(RejectNonFinite), WithLenientNaN()}
+	vars := map[string]float64{"big": 1e308, "bad": math.NaN()}
+	for _, tt := range tests {
+		expr := mustParse(t, tt.input)
+		ev := NewEvaluator(opts...)
+		ev.SetVars(vars)
+		f, err := ev.CompileFunc(expr)
+		if err != nil {
+			t.Fatalf("CompileFunc(%s): %v", tt.input, err)
+		}
+		_, evalErr := ev.Eval(expr)
+		_, funcErr := f(nil)
+		for _, err := range []error{evalErr, funcErr} {
+			var nonFinite *NonFiniteError
+			if !errors.As(err, &nonFinite) {
+				t.Errorf("%s: error %v, want a *NonFiniteError", tt.input, err)
+				continue
+			}
+			if Format(nonFinite.Expr) != tt.origin || SpanOf(nonFinite.Expr).Start != tt.pos {
+				t.Errorf("%s: error %v, want one produced by %s at offset %d", tt.input, err, tt.origin, tt.pos)
+			}
+			if math.IsNaN(tt.value) != math.IsNaN(nonFinite.Value) || !math.IsNaN(tt.value) && nonFinite.Value != tt.value {
+				t.Errorf("%s: value %v, want %v", tt.input, nonFinite.Value, tt.value)
+			}
+			if ErrorCode(err) != CodeNonFinite {
+				t.Errorf("%s: code %s, want %s", tt.input, ErrorCode(err), CodeNonFinite)
+			}
+		}
+
+		// Allowed by default, the value reaches the result
+		allow := NewEvaluator(WithLenientNaN())
+		allow.SetVars(vars)
+		if got, err := allow.Eval(expr); err != nil || !isNonFinite(got) {
+			t.Errorf("%s allowed = %v, %v; want a non-finite result", tt.input, got, err)
+		}
+	}
+	if got, err := NewEvaluator(opts...).Eval(mustParse(t, "1e308 + 1e308 - 1e308")); err == nil {
+		t.Errorf("1e308 + 1e308 - 1e308 = %v, want an error for the intermediate +Inf", got)
+	}
+}
+
+func TestWithRoundTo(t *testing.T) {
+	tests := []struct {
+		input                          string
+		digits                         int
+		halfUp, halfEven, down, upward float64
+	}{
+		{"2.675", 2, 2.68, 2.68, 2.67, 2.68}, // 2.67499999999999982236431605997495353221893310546875 as a float64
+		{"-2.675", 2, -2.68, -2.68, -2.67, -2.68},
+		{"2.665", 2, 2.67, 2.66, 2.66, 2.67},
+		{"1.005", 2, 1.01, 1, 1, 1.01},
+		{"-1.005", 2, -1.01, -1, -1, -1.01},
+		{"0.125", 2, 0.13, 0.12, 0.12, 0.13},
+		{"-0.125", 2, -0.13, -0.12, -0.12, -0.13},
+		{"-1 / 3", 2, -0.33, -0.33, -0.33, -0.34},
+		{"2.5", 0, 3, 2, 2, 3},
+		{"-2.5", 0, -3, -2, -2, -3},
+		{"-0.125", 0, 0, 0, 0, -1},
+		{"1250", -2, 1300, 1200, 1200, 1300},
+		{"1 / 3 * 3", 2, 1, 1, 1, 1}, // intermediates are not rounded
+	}
+	modes := []RoundingMode{RoundHalfUp, RoundHalfEven, RoundDown, RoundUp}
+	for _, tt := range tests {
+		expr := mustParse(t, tt.input)
+		for i, want := range []float64{tt.halfUp, tt.halfEven, tt.down, tt.upward} {
+			ev := NewEvaluator(WithRoundTo(tt.digits, modes[i]))
+			got, err := ev.Eval(expr)
+			if err != nil || got != want {
+				t.Errorf("%s to %d digits with mode %d = %v, %v; want %v", tt.input, tt.digits, modes[i], got, err, want)
+			}
+			fn, err := ev.CompileFunc(expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := fn(nil); err != nil || got != want {
+				t.Errorf("%s compiled, to %d digits with mode %d = %v, %v; want %v", tt.input, tt.digits, modes[i], got, err, want)
+			}
+		}
+	}
+	if got, err := NewEvaluator(WithRoundTo(2, RoundHalfUp), WithDivisionByZero(DivideByZeroIEEE)).Eval(mustParse(t, "1 / 0")); err != nil || !math.IsInf(got, 1) {
+		t.Errorf("1 / 0 rounded = %v, %v; want +Inf", got, err)
+	}
+}
+
+func TestUndefinedVariables(t *testing.T) {
+	// bonus, in the branch taken, counts, and skipped and never do not
+	expr := mustParse(t, "price * qty + max(tax, 1) + (flag ? bonus : skipped) + (0 && never)")
+	vars := map[string]float64{"qty": 2, "flag": 1}
+	tests := []struct {
+		name   string
+		policy UndefinedVariables
+		want   float64
+	}{
+		{"UndefinedDefault(0)", UndefinedDefault(0), 1},
+		{"UndefinedDefault(5)", UndefinedDefault(5), 5*2 + 5 + 5},
+		{"UndefinedNaN", UndefinedNaN, math.NaN()},
+	}
+	for _, tt := range tests {
+		ev := NewEvaluator(WithUndefinedVariables(tt.policy))
+		ev.SetVars(vars)
+		f, err := ev.CompileFunc(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := map[string]func() (float64, error){
+			"Eval": func() (float64, error) { return ev.Eval(expr) },
+			"EvalValue": func() (float64, error) {
+				v, err := ev.EvalValue(expr)
+				return v.num, err
+			},
+			"CompileFunc": func() (float64, error) { return f(vars) },
+		}
+		for mode, result := range results {
+			got, err := result()
+			if err != nil || got != tt.want && !(math.IsNaN(got) && math.IsNaN(tt.want)) {
+				t.Errorf("%s under %s = %v, %v; want %v", mode, tt.name, got, err, tt.want)
+			}
+		}
+	}
+
+	// The default is an error for the first, and MissingVariables lists them all
+	_, err := EvalWithVars(expr, vars)
+	var undefined *UndefinedVariableError
+	if !errors.As(err, &undefined) || undefined.Name != "price" {
+		t.Errorf("Eval: error %v, want price undefined", err)
+	}
+	if got, want := MissingVariables(expr, vars), []string{"price", "tax", "bonus", "skipped", "never"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("MissingVariables = %q, want %q", got, want)
+	}
+	if _, err := NewEvaluator(WithUndefinedVariables(UndefinedDefault(0))).Eval(mustParse(t, "1 / missing")); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("1 / missing with a default of 0: error %v, want division by zero", err)
+	}
+}
+
+func TestConditional(t *testing.T) {
+	env := map[string]Value{"x": NumberValue(3), "y": NumberValue(-1), "zero": NumberValue(0), "yes": BoolValue(true)}
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"if x > 0 then 1 else 2", 1},
+		{"x > 0 ? (y > 0 ? 1 : 2) : (y > 0 ? 3 : 4)", 2},
+		{"if x < 0 then 1 else if y < 0 then 2 else 3", 2},
+		{"x < 0 ? 1 : y > 0 ? 2 : zero ? 3 : 4", 4},
+		{"x ? 1 : 2", 1},
+		{"zero ? 1 : 2", 2},
+		{"yes ? 1 : 2", 1},
+		// The branch not taken may fail in any way
+		{"x > 0 ? 5 : missing / 0", 5},
+		{"if zero then nope(1) else 6", 6},
+		{"x > 0 ? 1 : 1 / 0 ? 2 : 3", 1},
+	}
+	for _, tt := range tests {
+		got, err := EvalValue(mustParse(t, tt.input), env)
+		if err != nil || got.num != tt.want {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	// An error in the condition, or in the branch taken, is the result
+	errs := []struct {
+		input string
+		want  error
+		at    string
+	}{
+		{"1 / zero ? 1 : 2", ErrDivisionByZero, "1 / zero"},
+		{"missing ? 1 : 2", ErrUndefinedVariable, "missing"},
+		{"x > 0 ? (zero ? 1 : 1 / zero) : 2", ErrDivisionByZero, "1 / zero"},
+		{"if x > 5 then 1 else if x > 2 then missing else 3", ErrUndefinedVariable, "missing"},
+	}
+	for _, tt := range errs {
+		_, err := EvalValue(mustParse(t, tt.input), env)
+		var evalErr *EvalError
+		if !errors.Is(err, tt.want) || !errors.As(err, &evalErr) || Format(evalErr.Expr) != tt.at {
+			t.Errorf("%s: error %v, want %v in %s", tt.input, err, tt.want, tt.at)
+		}
+	}
+	if _, err := EvalValue(mustParse(t, `"s" ? 1 : 2`), env); ErrorCode(err) != CodeType {
+		t.Errorf(`"s" ? 1 : 2: error %v, want %s`, err, CodeType)
+	}
+
+	// Stats count each branch not taken as skipped
+	_, stats, err := EvalWithStats(mustParse(t, "x > 0 ? (y > 0 ? 1 : 2) : (y > 0 ? 3 : 1 / 0)"), map[string]float64{"x": 3, "y": -1})
+	if err != nil || stats.Skipped != 2 || stats.Operators[">"] != 2 || stats.Operators["/"] != 0 {
+		t.Errorf("nested conditionals: stats %+v, %v; want 2 skipped", stats, err)
+	}
+}
+
+func TestComparisonEpsilon(t *testing.T) {
+	vars := map[string]float64{"inf": math.Inf(1), "nan": math.NaN(), "big": 1e12, "tiny": 1e-12}
+	tests := []struct {
+		input        string
+		exact, tight bool // with epsilon 0 and 1e-9
+	}{
+		{"0.1 + 0.2 == 0.3", false, true},
+		{"0.1 + 0.2 != 0.3", true, false},
+		{"0.1 + 0.2 <= 0.3", false, true},
+		{"0.3 >= 0.1 + 0.2", false, true},
+		{"0.1 + 0.2 > 0.3", true, false},
+		{"0.3 < 0.1 + 0.2", true, false},
+		// Relative above 1, absolute below it
+		{"big + 500 == big", false, true},
+		{"big + 5000 == big", false, false},
+		{"tiny == 0", false, true},
+		{"1e-8 == 0", false, false},
+		// Away from the boundary < and > are unaffected
+		{"0.3 < 0.3 + 1e-6", true, true},
+		{"1 > 1 - 1e-6", true, true},
+		{"1 < 1 + 1e-12", true, false},
+		{"inf == inf", true, true},
+		{"inf == big", false, false},
+		{"-inf < -big", true, true},
+		{"inf == inf + 1", true, true},
+		{"nan == nan", false, false},
+		{"nan != nan", true, true},
+		{"nan <= 1", false, false},
+		{"nan >= nan", false, false},
+	}
+	exact := NewEvaluator()
+	tight := NewEvaluator(WithComparisonEpsilon(1e-9))
+	for _, tt := range tests {
+		expr := mustParse(t, tt.input)
+		for _, c := range []struct {
+			ev   *Evaluator
+			want bool
+		}{{exact, tt.exact}, {tight, tt.tight}} {
+			c.ev.SetVars(vars)
+			want := 0.0
+			if c.want {
+				want = 1
+			}
+			if got, err := c.ev.Eval(expr); err != nil || got != want {
+				t.Errorf("%s with epsilon %v = %v, %v; want %v", tt.input, c.ev.cfg.epsilon, got, err, want)
+			}
+			f, err := c.ev.CompileFunc(expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := f(vars); err != nil || got != want {
+				t.Errorf("compiled %s with epsilon %v = %v, %v; want %v", tt.input, c.ev.cfg.epsilon, got, err, want)
+			}
+		}
+	}
+	for _, eps := range []float64{-1e-9, math.NaN()} {
+		if _, err := NewEvaluator(WithComparisonEpsilon(eps)).Eval(Num(1)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("WithComparisonEpsilon(%v): error %v, want %v", eps, err, ErrInvalidOption)
+		}
+	}
+}
+
+func TestIfFunction(t *testing.T) {
+	vars := map[string]float64{"x": 1, "zero": 0}
+	tests := []struct {
+		input  string
+		format string // the Conditional it is parsed as
+		want   float64
+	}{
+		{"if(1, 2, 1 / 0)", "1 ? 2 : 1 / 0", 2},
+		{"if(0, 1 / 0, 3)", "0 ? 1 / 0 : 3", 3},
+		{"if(x > 0, 10 / x, 1 / zero)", "x > 0 ? 10 / x : 1 / zero", 10},
+		{"if(x > 1, nope(1) + missing, x)", "x > 1 ? nope(1) + missing : x", 1},
+		{"if(1 < 2, if(0, 1 / 0, 7), 1 / 0)", "1 < 2 ? 0 ? 1 / 0 : 7 : 1 / 0", 7},
+		// Without an else it gives 0 when the condition is false
+		{"if(0, 5)", "0 ? 5 : 0", 0},
+		{"if(1, 5)", "1 ? 5 : 0", 5},
+	}
+	for _, tt := range tests {
+		expr := mustParse(t, tt.input)
+		if _, ok := expr.(*Conditional); !ok || Format(expr) != tt.format {
+			t.Errorf("%s parses as %T %s, want the Conditional %s", tt.input, expr, Format(expr), tt.format)
+		}
+		if got, err := EvalWithVars(expr, vars); err != nil || got != tt.want {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+		// The branch not taken is skipped in compiled forms too
+		f, err := CompileFunc(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := f(vars); err != nil || got != tt.want {
+			t.Errorf("compiled %s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	if _, err := EvalWithVars(mustParse(t, "if(missing, 1, 2)"), vars); !errors.Is(err, ErrUndefinedVariable) {
+		t.Errorf("if(missing, 1, 2): error %v, want the condition's", err)
+	}
+	for input, msg := range map[string]string{
+		"if(1, 2, 3, 4)": "if expects 2 or 3 arguments, got 4 at offset 0",
+		"if()":           "if expects 2 or 3 arguments, got 0 at offset 0",
+	} {
+		if _, err := ParseString(input); err == nil || err.Error() != msg {
+			t.Errorf("ParseString(%s): error %v, want %q", input, err, msg)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,141 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// RegisterExcelFunctions makes the spreadsheet functions SUM, AVERAGE, MIN,
+// MAX, IF, ROUND, ABS, POWER, MOD and SQRT callable from expressions
+// evaluated by ev, under their names in any case, with the arguments and
+// results Excel gives them:
+//
+//   - SUM, AVERAGE, MIN and MAX take numbers and lists alike, as the
+//     aggregates do; MIN and MAX of an empty list are 0, and AVERAGE of
+//     one is an error;
+//   - IF(cond, then, else) evaluates only the branch taken, and without
+//     else is 0 when cond is false;
+//   - ROUND(x, digits) requires digits, truncates it toward zero when it is
+//     not whole and rounds halves away from zero, so ROUND(2.5, 0) is 3 and
+//     ROUND(-2.5, 0) is -3;
+//   - MOD(n, d) has the sign of d, being n - d*floor(n/d), so MOD(-3, 2) is
+//     1 where -3 % 2 is -1, and MOD(n, 0) is an error;
+//   - POWER(0, 0) is an error, besides the powers pow rejects;
+//   - ABS and SQRT are abs and sqrt.
+//
+// A name that is a builtin as written, such as sqrt or round, or that is
+// registered with RegisterFunc, keeps that meaning, so only spellings that
+// are not, such as ROUND or Round, go to the spreadsheet functions. Like
+// RegisterFunc it is for setting up, and is not safe to call concurrently
+// with evaluations.
+func RegisterExcelFunctions(ev *Evaluator) {
+	ev.excel = true
+	if index := ev.memoIndex; index != nil {
+		index.mu.Lock()
+		index.root = nil
+		index.mu.Unlock()
+	}
+}
+
+// excelFunctions are the spreadsheet functions of RegisterExcelFunctions
+// but IF, by their names in upper case.
+var excelFunctions = map[string]callTarget{
+	"SUM":     {agg: aggregates["sum"], isAggregate: true},
+	"AVERAGE": {agg: aggregates["avg"], isAggregate: true},
+	"MIN":     {agg: aggregate{sig: builtin{arity: 1, variadic: true}, fn: extremeList(math.Min)}, isAggregate: true},
+	"MAX":     {agg: aggregate{sig: builtin{arity: 1, variadic: true}, fn: extremeList(math.Max)}, isAggregate: true},
+	"ROUND":   {b: builtin{arity: 2, fn: excelRound}},
+	"ABS":     {b: builtins["abs"]},
+	"POWER":   {b: withDomain(builtin{arity: 2, fn: excelPower}, negativeBaseFraction)},
+	"MOD":     {b: builtin{arity: 2, fn: excelMod}},
+	"SQRT":    {b: builtins["sqrt"]},
+}
+
+// excelTarget returns the spreadsheet function a call goes to, when
+// RegisterExcelFunctions was called on ev and the name is neither
+// registered nor a builtin as written.
+func (ev *Evaluator) excelTarget(name string) (callTarget, bool) {
+	if !ev.excel {
+		return callTarget{}, false
+	}
+	if _, registered := ev.funcs[name]; registered {
+		return callTarget{}, false
+	}
+	if _, native := lookupBuiltin(name); native {
+		return callTarget{}, false
+	}
+	t, ok := excelFunctions[strings.ToUpper(name)]
+	if ok && t.isAggregate {
+		t.b = t.agg.sig
+	}
+	return t, ok
+}
+
+// excelIf returns the conditional that a call to the spreadsheet IF
+// stands for, or nil when the call is not to IF.
+func (ev *Evaluator) excelIf(call *FunctionCall) (*Conditional, error) {
+	if !ev.excel || !strings.EqualFold(call.Name, "if") {
+		return nil, nil
+	}
+	if _, registered := ev.funcs[call.Name]; registered {
+		return nil, nil
+	}
+	if err := (builtin{arity: 2, optional: 1}).checkArgs(call.Name, len(call.Args)); err != nil {
+		return nil, err
+	}
+	var otherwise Expr = Num(0)
+	if len(call.Args) == 3 {
+		otherwise = call.Args[2]
+	}
+	return &Conditional{Cond: call.Args[0], Then: call.Args[1], Else: otherwise, Span: call.Span}, nil
+}
+
+// extremeList returns an aggregate keeping the least or greatest element as
+// pick chooses, with 0 for an empty list as in a spreadsheet.
+func extremeList(pick func(x, y float64) float64) func(l *list) (float64, error) {
+	return func(l *list) (float64, error) {
+		if l.len() == 0 {
+			return 0, nil
+		}
+		if l.isRange {
+			return pick(l.lo, l.hi), nil
+		}
+		result := l.items[0]
+		for _, x := range l.items[1:] {
+			result = pick(result, x)
+		}
+		return result, nil
+	}
+}
+
+// excelRound rounds args[0] to args[1] places, truncated to a whole
+// number, halves away from zero.
+func excelRound(args []float64) (float64, error) {
+	digits := math.Max(-400, math.Min(math.Trunc(args[1]), 400))
+	return roundDecimalBy(args[0], int(digits), halfAwayQuo), nil
+}
+
+// excelPower raises args[0] to args[1], rejecting 0 to the power 0 as a
+// spreadsheet does.
+func excelPower(args []float64) (float64, error) {
+	if args[0] == 0 && args[1] == 0 {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("POWER: 0 to the power 0 is %w", ErrOutsideDomain))
+	}
+	return math.Pow(args[0], args[1]), nil
+}
+
+// excelMod returns args[0] modulo args[1] with the sign of args[1].
+func excelMod(args []float64) (float64, error) {
+	n, d := args[0], args[1]
+	if d == 0 {
+		return 0, ErrModuloByZero
+	}
+	m := math.Mod(n, d)
+	if m != 0 && (m < 0) != (d < 0) {
+		m += d
+	}
+	return m, nil
+}
+
+// end of file
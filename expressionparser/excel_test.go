@@ -0,0 +1,115 @@
+package expressionparser
+
+import (
+	"errors"
+	"testing"
+)
+
+// excelEvaluator returns an evaluator with the spreadsheet functions.
+func excelEvaluator() *Evaluator {
+	ev := NewEvaluator()
+	RegisterExcelFunctions(ev)
+	return ev
+}
+
+func TestExcelFunctions(t *testing.T) {
+	ev := excelEvaluator()
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		// Results from the Excel documentation
+		{"MOD(3, 2)", 1},
+		{"MOD(-3, 2)", 1},
+		{"MOD(3, -2)", -1},
+		{"MOD(-3, -2)", -1},
+		{"MOD(5.5, 2)", 1.5},
+		{"ROUND(2.5, 0)", 3},
+		{"ROUND(-2.5, 0)", -3},
+		{"ROUND(2.15, 1)", 2.2},
+		{"ROUND(2.149, 1)", 2.1},
+		{"ROUND(-1.475, 2)", -1.48},
+		{"ROUND(21.5, -1)", 20},
+		{"ROUND(626.3, -3)", 1000},
+		{"ROUND(1.98, -1)", 0},
+		{"ROUND(-50.55, -2)", -100},
+		{"ROUND(2.5, 0.9)", 3}, // digits truncated
+		{"POWER(5, 2)", 25},
+		{"POWER(98.6, 3.2)", 2401077.2220695773},
+		{"POWER(4, 5/4)", 5.65685424949238},
+		{"SUM(3, 2)", 5},
+		{"SUM([5, 15, 30])", 50},
+		{"AVERAGE(10, 7, 9, 27, 2)", 11},
+		{"MIN(10, 7, 9, 27, 2)", 2},
+		{"MAX([10, 7, 9, 27, 2])", 27},
+		{"MIN([])", 0},
+		{"MAX([])", 0},
+		{"ABS(-4)", 4},
+		{"SQRT(16)", 4},
+		{"IF(1 > 0, 10, 20)", 10},
+		{"IF(0, 10)", 0},
+		// IF evaluates only the branch taken
+		{"IF(1, 2, 1/0)", 2},
+		{"IF(0, 1/0, 3)", 3},
+		// In any case
+		{"Round(2.345, 1)", 2.3},
+		{"mod(-3, 2)", 1},
+		{"If(0, 1, 2)", 2},
+		// Builtins keep their meaning as written
+		{"-3 % 2", -1},
+		{"round(2.5)", 3},
+		{"pow(0, 0)", 1},
+		{"min(3, -1)", -1},
+	}
+	for _, tt := range tests {
+		expr := mustParse(t, tt.input)
+		if got, err := ev.Eval(expr); err != nil || got != tt.want {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+		fn, err := ev.CompileFunc(expr)
+		if err != nil {
+			t.Errorf("CompileFunc(%s): %v", tt.input, err)
+			continue
+		}
+		if got, err := fn(nil); err != nil || got != tt.want {
+			t.Errorf("%s compiled = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestExcelFunctionErrors(t *testing.T) {
+	ev := excelEvaluator()
+	tests := []struct {
+		input string
+		code  string
+	}{
+		{"MOD(1, 0)", CodeModuloByZero},
+		{"POWER(0, 0)", CodeOutsideDomain},
+		{"POWER(-8, 1/3)", CodeOutsideDomain},
+		{"SQRT(-1)", CodeOutsideDomain},
+		{"AVERAGE([])", CodeOutsideDomain},
+		{"ROUND(2.5)", CodeArgumentCount},
+		{"IF(1)", CodeArgumentCount},
+		{"IF(1, 2, 3, 4)", CodeArgumentCount},
+	}
+	for _, tt := range tests {
+		if _, err := ev.Eval(mustParse(t, tt.input)); ErrorCode(err) != tt.code {
+			t.Errorf("%s: error %v, want %s", tt.input, err, tt.code)
+		}
+	}
+	if _, err := ev.Eval(mustParse(t, "MOD(1, 0)")); !errors.Is(err, ErrModuloByZero) {
+		t.Errorf("MOD(1, 0): error %v, want ErrModuloByZero", err)
+	}
+
+	// Without RegisterExcelFunctions the names are unknown
+	if _, err := Evaluate("MOD(-3, 2)"); ErrorCode(err) != CodeUnknownFunction {
+		t.Errorf("MOD without RegisterExcelFunctions: error %v, want %s", err, CodeUnknownFunction)
+	}
+	// A registered function wins
+	ev.RegisterFunc("MOD", func(args ...float64) (float64, error) { return 42, nil })
+	if got, err := ev.Eval(mustParse(t, "MOD(-3, 2)")); err != nil || got != 42 {
+		t.Errorf("registered MOD = %v, %v; want 42", got, err)
+	}
+}
+
+// end of file
@@ -0,0 +1,101 @@
+package expressionparser
+
+// Expression is an expression compiled by Compile, to be evaluated any
+// number of times with different variables. It is safe for concurrent use.
+type Expression struct {
+	expr     Expr
+	vars     []string
+	fn       func(vars map[string]float64, observer Observer) (float64, error)
+	constant bool
+	id       string   // the input, identifying the expression to the observer
+	observer Observer // set WithObserver
+}
+
+// Compile parses input with ParseString, checks it WithChecks if asked to,
+// folds the operators applied only to literals and compiles it as
+// CompileFunc does, so that Eval does none of that work again; opts
+// configure the Parser and the Evaluator alike. Input that does not parse,
+// or fails the checks, gives a *ParseError. A literal subexpression that
+// fails to fold and is evaluated whenever the expression is, such as the
+// 1/0 of "x + 1/0", is an error, as Fold returns it; one in a branch that
+// may never be taken is left for Eval to report if it is reached. A
+// pattern of =~, match or capture that is a string literal is compiled
+// here, and one that does not compile is an error. A panic gives a
+// *PanicError.
+//
+// An expression that IsConstant is evaluated here, once, and Eval returns
+// its value without evaluating again. Its evaluation error, such as that
+// of "1 / 0", is certain to recur on every call, and is returned by
+// Compile rather than left for Eval.
+func Compile(input string, opts ...Option) (e *Expression, err error) {
+	defer recoverPanic(&err)
+	ev := NewEvaluator(opts...)
+	if ev.err != nil {
+		return nil, ev.err
+	}
+	expr, err := ParseString(input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := ev.cfg.check(expr); err != nil {
+		return nil, err
+	}
+	if err := ev.compilePatterns(expr); err != nil {
+		return nil, err
+	}
+	folded, err := ev.fold(expr)
+	if err != nil {
+		return nil, err
+	}
+	fn, err := ev.compile(folded)
+	if err != nil {
+		return nil, err
+	}
+	e = &Expression{expr: expr, vars: Variables(expr), fn: fn, id: input, observer: ev.cfg.observer}
+	if !IsConstant(expr) {
+		return e, nil
+	}
+	value, err := fn(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	e.fn = func(map[string]float64, Observer) (float64, error) {
+		return value, nil
+	}
+	e.constant = true
+	return e, nil
+}
+
+// Eval evaluates the expression with the given variables, giving the
+// result or error that EvalWithVars would. The map is only read.
+func (e *Expression) Eval(vars map[string]float64) (result float64, err error) {
+	defer recoverPanic(&err)
+	if e.observer != nil {
+		return e.observe(vars)
+	}
+	return e.fn(vars, nil)
+}
+
+// IsConstant reports whether the expression is constant, as IsConstant
+// decides, and so was evaluated by Compile.
+func (e *Expression) IsConstant() bool {
+	return e.constant
+}
+
+// Vars returns the variables the expression needs, as Variables lists them.
+func (e *Expression) Vars() []string {
+	return append([]string(nil), e.vars...)
+}
+
+// Expr returns the tree of the expression as parsed. It must not be
+// modified.
+func (e *Expression) Expr() Expr {
+	return e.expr
+}
+
+// String returns the expression in the normalized form Format prints.
+func (e *Expression) String() string {
+	return Format(e.expr)
+}
+
+// end of file
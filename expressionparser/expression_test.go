@@ -0,0 +1,188 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	e, err := Compile("price * qty * (1+tax)")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if got := fmt.Sprint(e.Vars()); got != "[price qty tax]" {
+		t.Errorf("Vars() = %s, want [price qty tax]", got)
+	}
+	if got := e.String(); got != "price * qty * (1 + tax)" {
+		t.Errorf("String() = %q", got)
+	}
+	if e.IsConstant() {
+		t.Error("IsConstant() = true for an expression of variables")
+	}
+	tests := []struct {
+		vars map[string]float64
+		want float64
+	}{
+		{map[string]float64{"price": 10, "qty": 3, "tax": 0.5}, 45},
+		{map[string]float64{"price": 2, "qty": 0, "tax": 1}, 0},
+		{map[string]float64{"price": -4, "qty": 2, "tax": 0, "unused": 7}, -8},
+	}
+	for _, tt := range tests {
+		if got, err := e.Eval(tt.vars); err != nil || got != tt.want {
+			t.Errorf("Eval(%v) = %v, %v; want %v", tt.vars, got, err, tt.want)
+		}
+	}
+	if _, err := e.Eval(map[string]float64{"price": 10, "qty": 3}); !errors.Is(err, ErrUndefinedVariable) {
+		t.Errorf("Eval without tax: error %v, want ErrUndefinedVariable", err)
+	}
+
+	// Let-bound names are not inputs
+	if got := fmt.Sprint(mustCompile(t, "let y = x * 2 in y + z").Vars()); got != "[x z]" {
+		t.Errorf("Vars() of a let = %s, want [x z]", got)
+	}
+	c := mustCompile(t, "(2+3)*5")
+	if got, err := c.Eval(nil); !c.IsConstant() || err != nil || got != 25 {
+		t.Errorf("(2+3)*5: constant %v, Eval = %v, %v; want 25", c.IsConstant(), got, err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		opts  []Option
+		msg   string
+	}{
+		{"2 +", nil, "expected number, name, string, '(', '[', or unary '-' or '!' but found end of input at offset 3"},
+		{"1/0", nil, `division by zero in "1 / 0" at offset 0`},
+		{"x + 1/0", nil, `division by zero in "1 / 0" at offset 4`},
+		{"foo(x)", []Option{WithChecks(CheckOptions{})}, "unknown function foo at offset 0"},
+	}
+	for _, tt := range tests {
+		e, err := Compile(tt.input, tt.opts...)
+		if e != nil || err == nil || err.Error() != tt.msg {
+			t.Errorf("Compile(%q) = %v, %v; want error %q", tt.input, e, err, tt.msg)
+		}
+	}
+	var parseErr *ParseError
+	if _, err := Compile("foo(x)"); err != nil {
+		t.Errorf("Compile(foo(x)) without checks: %v", err)
+	} else if _, err := Compile("foo(x)", WithChecks(CheckOptions{})); !errors.As(err, &parseErr) {
+		t.Errorf("Compile(foo(x)) WithChecks: error %T, want a *ParseError", err)
+	}
+}
+
+func TestExpressionConcurrentEval(t *testing.T) {
+	e := mustCompile(t, "a * b + (a > b ? a : b) - min(a, b)")
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				a, b := float64(g), float64(i)
+				want := a*b + max(a, b) - min(a, b)
+				got, err := e.Eval(map[string]float64{"a": a, "b": b})
+				if err != nil || got != want {
+					errs <- fmt.Errorf("a = %v, b = %v: %v, %v; want %v", a, b, got, err, want)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func mustCompile(t *testing.T, input string) *Expression {
+	t.Helper()
+	e, err := Compile(input)
+	if err != nil {
+		t.Fatalf("Compile(%q): %v", input, err)
+	}
+	return e
+}
+
+func TestCompileConstant(t *testing.T) {
+	tests := []struct {
+		input    string
+		constant bool
+		want     float64
+	}{
+		{"(2+3)*5", true, 25},
+		{"let a = 4 in a * a", true, 16},
+		{"2 > 1 ? 3 : 1/0", true, 3}, // the division is never reached
+		{"max(1, 2) + sqrt(9)", true, 5},
+		{"x * 0", false, 0},
+		// Impure functions are called on every Eval
+		{"rand() * 0", false, 0},
+	}
+	for _, tt := range tests {
+		e := mustCompile(t, tt.input)
+		if e.IsConstant() != tt.constant {
+			t.Errorf("%s: IsConstant() = %v, want %v", tt.input, e.IsConstant(), tt.constant)
+		}
+		// A constant needs no variables, and ignores any it is given
+		for _, vars := range []map[string]float64{{"x": 1}, {"x": 2, "a": 99}} {
+			if got, err := e.Eval(vars); err != nil || got != tt.want {
+				t.Errorf("%s: Eval(%v) = %v, %v; want %v", tt.input, vars, got, err, tt.want)
+			}
+		}
+	}
+	r := mustCompile(t, "rand()")
+	first, _ := r.Eval(nil)
+	if again, _ := r.Eval(nil); r.IsConstant() || again == first {
+		t.Errorf("rand(): IsConstant() = %v, Eval gave %v then %v", r.IsConstant(), first, again)
+	}
+}
+
+// TestCompileConstantErrors checks that the error of a constant expression
+// is returned by Compile, while a non-constant one leaves an error it may
+// never reach for Eval.
+func TestCompileConstantErrors(t *testing.T) {
+	for _, input := range []string{"1/0", "let a = 0 in 1/a", "sqrt(-1)", "2 < 1 ? 3 : 7 % 0"} {
+		if e, err := Compile(input); err == nil {
+			t.Errorf("Compile(%s) = %v, want its evaluation error", input, e)
+		}
+	}
+	e := mustCompile(t, "x > 0 ? 1/0 : 2")
+	if e.IsConstant() {
+		t.Fatal("x > 0 ? 1/0 : 2: IsConstant() = true")
+	}
+	if got, err := e.Eval(map[string]float64{"x": -1}); err != nil || got != 2 {
+		t.Errorf("Eval(x = -1) = %v, %v; want 2", got, err)
+	}
+	if _, err := e.Eval(map[string]float64{"x": 1}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("Eval(x = 1): error %v, want ErrDivisionByZero", err)
+	}
+}
+
+func BenchmarkCompiledConstant(b *testing.B) {
+	e, err := Compile("(2 + 3) * sqrt(16) - max(1, 2) ^ 3")
+	if err != nil || !e.IsConstant() {
+		b.Fatalf("Compile: %v, constant %v", err, e != nil && e.IsConstant())
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = e.Eval(nil)
+	}
+}
+
+func BenchmarkCompiledVariable(b *testing.B) {
+	e, err := Compile("(x + 3) * sqrt(16) - max(1, 2) ^ 3")
+	if err != nil {
+		b.Fatal(err)
+	}
+	vars := map[string]float64{"x": 2}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = e.Eval(vars)
+	}
+}
+
+// end of file
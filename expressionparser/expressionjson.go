@@ -0,0 +1,214 @@
+package expressionparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FormulaError is the error of decoding an Expression from a formula that
+// does not compile. Path locates the formula in the document, as in
+// "pricing.total" or "rules[2]", when UnmarshalConfig decoded it, and is
+// empty otherwise.
+type FormulaError struct {
+	Path  string
+	Input string
+	Err   error // the error of Compile, such as a *ParseError
+}
+
+// Error returns the message of the error, after the formula and its path.
+func (e *FormulaError) Error() string {
+	if e.Path == "" {
+		return "formula " + strconv.Quote(e.Input) + ": " + e.Err.Error()
+	}
+	return "formula " + strconv.Quote(e.Input) + " at " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error of Compile.
+func (e *FormulaError) Unwrap() error {
+	return e.Err
+}
+
+// UnmarshalJSON compiles the JSON string data as Compile does, so that the
+// formulas of a configuration are checked as it is decoded; null leaves
+// the expression as it is. A formula that does not compile gives a
+// *FormulaError.
+func (e *Expression) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var input string
+	if err := json.Unmarshal(data, &input); err != nil {
+		return err
+	}
+	return e.UnmarshalText([]byte(input))
+}
+
+// MarshalJSON writes the expression as a JSON string of its normalized
+// source, as String gives it. Its receiver is a value so that expressions
+// held by value, as in structs and maps, are marshaled.
+func (e Expression) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.String())
+}
+
+// UnmarshalText compiles text as Compile does, for decoders of formats
+// such as YAML and TOML that unmarshal text. A formula that does not
+// compile gives a *FormulaError.
+func (e *Expression) UnmarshalText(text []byte) error {
+	compiled, err := Compile(string(text))
+	if err != nil {
+		return &FormulaError{Input: string(text), Err: err}
+	}
+	*e = *compiled
+	return nil
+}
+
+// MarshalText returns the normalized source of the expression.
+func (e Expression) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// UnmarshalConfig decodes the JSON document data into v as json.Unmarshal
+// does, and gives the *FormulaError of a formula that does not compile the
+// path of the formula in the document, which json.Unmarshal cannot tell an
+// Expression: that of the first string holding the formula that decodes
+// into an Expression.
+func UnmarshalConfig(data []byte, v interface{}) error {
+	err := json.Unmarshal(data, v)
+	var formula *FormulaError
+	if errors.As(err, &formula) && formula.Path == "" {
+		formula.Path = formulaPath(data, reflect.TypeOf(v), formula.Input)
+	}
+	return err
+}
+
+// expressionType is the type that formulas decode into.
+var expressionType = reflect.TypeOf(Expression{})
+
+// formulaPath returns the path of the first string s in the JSON document
+// that decodes into an Expression of a value of type t, or "" when there
+// is none.
+func formulaPath(data []byte, t reflect.Type, s string) string {
+	type level struct {
+		object bool
+		key    string // of the value being read in an object
+		index  int    // of the value being read in an array
+		inKey  bool   // when the next string of an object is a key
+	}
+	var stack []level
+	// next moves past a value of the innermost object or array.
+	next := func() {
+		if n := len(stack); n > 0 {
+			if stack[n-1].object {
+				stack[n-1].inKey = true
+			} else {
+				stack[n-1].index++
+			}
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		switch tok := tok.(type) {
+		case json.Delim:
+			switch tok {
+			case '{':
+				stack = append(stack, level{object: true, inKey: true})
+			case '[':
+				stack = append(stack, level{})
+			default:
+				stack = stack[:len(stack)-1]
+				next()
+			}
+		case string:
+			if n := len(stack); n > 0 && stack[n-1].object && stack[n-1].inKey {
+				stack[n-1].key, stack[n-1].inKey = tok, false
+				continue
+			}
+			if tok == s {
+				// The path, and whether it leads to an Expression
+				var b strings.Builder
+				at := t
+				for _, l := range stack {
+					if l.object {
+						if b.Len() > 0 {
+							b.WriteByte('.')
+						}
+						b.WriteString(l.key)
+						at = memberType(at, l.key)
+					} else {
+						b.WriteString("[" + strconv.Itoa(l.index) + "]")
+						at = elemType(at)
+					}
+				}
+				for at != nil && at.Kind() == reflect.Ptr {
+					at = at.Elem()
+				}
+				if at == expressionType {
+					return b.String()
+				}
+			}
+			next()
+		default:
+			next()
+		}
+	}
+}
+
+// memberType returns the type that the member key of a JSON object decodes
+// into when the object decodes into a value of type t, matching the names
+// of struct fields as json.Unmarshal does, or nil when there is none.
+func memberType(t reflect.Type, key string) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == nil:
+		return nil
+	case t.Kind() == reflect.Map:
+		return t.Elem()
+	case t.Kind() != reflect.Struct:
+		return nil
+	}
+	var folded reflect.Type
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous && f.Type.Kind() == reflect.Struct {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case name == "-":
+			continue
+		case name == "":
+			name = f.Name
+		}
+		if name == key {
+			return f.Type
+		}
+		if folded == nil && strings.EqualFold(name, key) {
+			folded = f.Type
+		}
+	}
+	return folded
+}
+
+// elemType returns the type that the elements of a JSON array decode into
+// when the array decodes into a value of type t, or nil when there is none.
+func elemType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		return t.Elem()
+	}
+	return nil
+}
+
+// end of file
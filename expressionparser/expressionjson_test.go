@@ -0,0 +1,105 @@
+package expressionparser
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// pricingConfig is a configuration with formulas in several places.
+type pricingConfig struct {
+	Name    string `json:"name"`
+	Pricing struct {
+		Total    Expression  `json:"total"`
+		Discount *Expression `json:"discount,omitempty"`
+	} `json:"pricing"`
+	Rules  []Expression          `json:"rules"`
+	Limits map[string]Expression `json:"limits"`
+}
+
+func TestUnmarshalConfig(t *testing.T) {
+	var cfg pricingConfig
+	data := `{"name": "shop", "pricing": {"total": "price*qty", "discount": "total > 100 ? 0.1 : 0"}, "rules": ["qty >= 1"], "limits": {"max": "2^10"}}`
+	if err := UnmarshalConfig([]byte(data), &cfg); err != nil {
+		t.Fatalf("UnmarshalConfig: %v", err)
+	}
+	if got, err := cfg.Pricing.Total.Eval(map[string]float64{"price": 2.5, "qty": 4}); err != nil || got != 10 {
+		t.Errorf("total = %v, %v; want 10", got, err)
+	}
+	if got := cfg.Rules[0].String() + "; " + cfg.Pricing.Discount.String(); got != "qty >= 1; total > 100 ? 0.1 : 0" {
+		t.Errorf("formulas %q", got)
+	}
+	if max := cfg.Limits["max"]; !max.IsConstant() {
+		t.Error("limits.max is not constant")
+	}
+
+	// Written back normalized
+	out, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `{"name":"shop","pricing":{"total":"price * qty","discount":"total \u003e 100 ? 0.1 : 0"},"rules":["qty \u003e= 1"],"limits":{"max":"2 ^ 10"}}`
+	if string(out) != want {
+		t.Errorf("Marshal = %s, want %s", out, want)
+	}
+}
+
+func TestUnmarshalConfigErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		path string
+		msg  string
+	}{
+		{`{"pricing": {"total": "price*qty", "discount": "0.1 *"}}`, "pricing.discount",
+			`formula "0.1 *" at pricing.discount: expected number, name, string, '(', '[', or unary '-' or '!' but found end of input at offset 5`},
+		{`{"rules": ["qty >= 1", "qty <"]}`, "rules[1]", ""},
+		{`{"limits": {"min": "1", "max": "2 ^^ 3"}}`, "limits.max", ""},
+		// The same text in a field that is not a formula is passed over
+		{`{"name": "1 +", "rules": ["1 +"]}`, "rules[0]", ""},
+	}
+	for _, tt := range tests {
+		var cfg pricingConfig
+		err := UnmarshalConfig([]byte(tt.data), &cfg)
+		var formula *FormulaError
+		var parseErr *ParseError
+		if !errors.As(err, &formula) || !errors.As(err, &parseErr) {
+			t.Errorf("UnmarshalConfig(%s): error %v, want a *FormulaError of a *ParseError", tt.data, err)
+			continue
+		}
+		if formula.Path != tt.path {
+			t.Errorf("UnmarshalConfig(%s): path %q, want %q", tt.data, formula.Path, tt.path)
+		}
+		if tt.msg != "" && err.Error() != tt.msg {
+			t.Errorf("UnmarshalConfig(%s): error %q, want %q", tt.data, err, tt.msg)
+		}
+	}
+
+	// json.Unmarshal cannot tell the path
+	var cfg pricingConfig
+	err := json.Unmarshal([]byte(`{"rules": ["2 +"]}`), &cfg)
+	var formula *FormulaError
+	if !errors.As(err, &formula) || formula.Path != "" || formula.Input != "2 +" {
+		t.Errorf("json.Unmarshal: error %v, want a *FormulaError of 2 + without a path", err)
+	}
+}
+
+func TestExpressionText(t *testing.T) {
+	var e Expression
+	var u encoding.TextUnmarshaler = &e
+	if err := u.UnmarshalText([]byte("0.95*baseline")); err != nil {
+		t.Fatalf("UnmarshalText: %v", err)
+	}
+	if text, err := e.MarshalText(); err != nil || string(text) != "0.95 * baseline" {
+		t.Errorf("MarshalText = %q, %v", text, err)
+	}
+	if err := e.UnmarshalText([]byte("(")); err == nil {
+		t.Error("UnmarshalText((): no error")
+	}
+	// null leaves the expression as it was
+	if err := json.Unmarshal([]byte("null"), &e); err != nil || e.String() != "0.95 * baseline" {
+		t.Errorf("Unmarshal(null) = %v, expression %s", err, e.String())
+	}
+}
+
+// end of file
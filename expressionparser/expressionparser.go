@@ -1,8 +1,13 @@
 package expressionparser
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // Token types
@@ -13,8 +18,35 @@ const (
 	MINUS
 	MULT
 	DIV
+	POW
 	LPAREN
 	RPAREN
+	IDENT
+	COMMA
+	ASSIGN
+	LT        // <
+	LE        // <=
+	GT        // >
+	GE        // >=
+	EQ        // ==
+	NE        // !=
+	AND       // &&
+	OR        // ||
+	NOT       // !
+	QUESTION  // ?
+	COLON     // :
+	MOD       // %
+	STRING    // "text", Value holds the quoted source form
+	LBRACKET  // [
+	RBRACKET  // ]
+	DOTDOT    // ..
+	SEMICOLON // ;
+	BITAND    // &
+	BITOR     // |
+	BITXOR    // ~
+	SHL       // <<
+	SHR       // >>
+	MATCH     // =~
 	INVALID
 )
 
@@ -24,55 +56,108 @@ type TokenType int
 type Token struct {
 	Type  TokenType
 	Value string
+	Pos   int // byte offset of the token in the input
 }
 
 // Lexer converts input string into tokens
 type Lexer struct {
-	input  string
-	pos    int
-	ch     rune
+	input       string
+	pos         int // byte offset after the current character
+	ch          rune
+	width       int   // bytes of the current character
+	limit       int   // WithMaxInputBytes, kept by Reset
+	err         error // of input over the limit, or of the options
+	roman       bool  // WithRomanNumerals, kept by Reset
+	sexagesimal bool  // WithSexagesimal, kept by Reset
 }
 
-// NewLexer creates a new Lexer
-func NewLexer(input string) *Lexer {
-	l := &Lexer{input: input}
-	l.readChar()
+// NewLexer creates a new Lexer, configured by opts such as
+// WithMaxInputBytes, WithRomanNumerals and WithSexagesimal. Input over the limit, or invalid
+// options, give a single INVALID token, and the parser reading it the error.
+func NewLexer(input string, opts ...Option) *Lexer {
+	l := &Lexer{}
+	cfg, err := newConfig(opts)
+	l.limit, l.err, l.roman, l.sexagesimal = cfg.maxInputBytes, err, cfg.romanNumerals, cfg.sexagesimal
+	l.Reset(input)
 	return l
 }
 
-// readChar advances the position in the string and sets the current character.
+// Reset makes the lexer lex input from the start, as a new one would,
+// under the same limit and modes.
+func (l *Lexer) Reset(input string) {
+	limit, err, roman, sexagesimal := l.limit, l.err, l.roman, l.sexagesimal
+	*l = Lexer{input: input, limit: limit, roman: roman, sexagesimal: sexagesimal}
+	if errors.Is(err, ErrInvalidOption) {
+		l.err = err
+	} else {
+		l.err = (&config{maxInputBytes: limit}).checkInput(input)
+	}
+	if l.err == nil {
+		l.readChar()
+	}
+}
+
+// readChar advances the position in the string and sets the current
+// character, decoding UTF-8. A byte that begins no valid sequence is read
+// alone, as utf8.RuneError.
 func (l *Lexer) readChar() {
 	if l.pos >= len(l.input) {
-		l.ch = 0 // EOF
+		l.ch, l.width = 0, 1 // EOF
 	} else {
-		l.ch = rune(l.input[l.pos])
+		l.ch, l.width = utf8.DecodeRuneInString(l.input[l.pos:])
 	}
-	l.pos++
+	l.pos += l.width
+}
+
+// offset returns the byte offset of the current character.
+func (l *Lexer) offset() int {
+	return l.pos - l.width
 }
 
 // NextToken returns the next token in the input.
 func (l *Lexer) NextToken() Token {
 	var tok Token
 
-// Skip whitespace
-for unicode.IsSpace(l.ch) {
-	l.readChar()
-}
+	if l.err != nil {
+		return Token{Type: INVALID, Value: l.err.Error()}
+	}
 
-// Handle EOF
-if l.ch == 0 {
-	return Token{Type: EOF}
-}
+	// Skip whitespace
+	for unicode.IsSpace(l.ch) {
+		l.readChar()
+	}
 
-// Handle numbers
-if unicode.IsDigit(l.ch) {
-	tok.Type = NUMBER
-	tok.Value = l.readNumber()
-	return tok
-}
+	start := l.offset()
 
-// Handle operators and parentheses
-switch l.ch {
+	// Handle EOF
+	if l.ch == 0 {
+		return Token{Type: EOF, Pos: start}
+	}
+
+	// Handle numbers
+	if isDigit(l.ch) {
+		tok.Type = NUMBER
+		tok.Value = l.readNumber()
+		if l.sexagesimal && l.ch == ':' && isDigit(l.peekChar(1)) && strings.Trim(tok.Value, "0123456789") == "" {
+			tok.Value = l.readSexagesimal(start)
+		}
+		tok.Pos = start
+		return tok
+	}
+
+	// Handle identifiers (variables and function names)
+	if isIdentStart(l.ch) {
+		tok.Type = IDENT
+		tok.Value = l.readIdent()
+		if l.roman && isRomanName(tok.Value) && !l.beforeCall() {
+			tok.Type = NUMBER
+		}
+		tok.Pos = start
+		return tok
+	}
+
+	// Handle operators and parentheses
+	switch l.ch {
 	case '+':
 		tok = Token{Type: PLUS, Value: "+"}
 	case '-':
@@ -81,176 +166,1427 @@ switch l.ch {
 		tok = Token{Type: MULT, Value: "*"}
 	case '/':
 		tok = Token{Type: DIV, Value: "/"}
+	case '%':
+		tok = Token{Type: MOD, Value: "%"}
+	case '^':
+		tok = Token{Type: POW, Value: "^"}
 	case '(':
 		tok = Token{Type: LPAREN, Value: "("}
 	case ')':
 		tok = Token{Type: RPAREN, Value: ")"}
+	case ',':
+		tok = Token{Type: COMMA, Value: ","}
+	case '=':
+		if tok = l.either('~', Token{Type: MATCH, Value: "=~"}, Token{}); tok.Type != MATCH {
+			tok = l.either('=', Token{Type: EQ, Value: "=="}, Token{Type: ASSIGN, Value: "="})
+		}
+	case '<':
+		if tok = l.either('<', Token{Type: SHL, Value: "<<"}, Token{}); tok.Type != SHL {
+			tok = l.either('=', Token{Type: LE, Value: "<="}, Token{Type: LT, Value: "<"})
+		}
+	case '>':
+		if tok = l.either('>', Token{Type: SHR, Value: ">>"}, Token{}); tok.Type != SHR {
+			tok = l.either('=', Token{Type: GE, Value: ">="}, Token{Type: GT, Value: ">"})
+		}
+	case '!':
+		tok = l.either('=', Token{Type: NE, Value: "!="}, Token{Type: NOT, Value: "!"})
+	case '&':
+		tok = l.either('&', Token{Type: AND, Value: "&&"}, Token{Type: BITAND, Value: "&"})
+	case '|':
+		tok = l.either('|', Token{Type: OR, Value: "||"}, Token{Type: BITOR, Value: "|"})
+	case '~':
+		tok = Token{Type: BITXOR, Value: "~"}
+	case '?':
+		tok = Token{Type: QUESTION, Value: "?"}
+	case ':':
+		tok = Token{Type: COLON, Value: ":"}
+	case '[':
+		tok = Token{Type: LBRACKET, Value: "["}
+	case ']':
+		tok = Token{Type: RBRACKET, Value: "]"}
+	case ';':
+		tok = Token{Type: SEMICOLON, Value: ";"}
+	case '.':
+		tok = l.either('.', Token{Type: DOTDOT, Value: ".."}, Token{Type: INVALID, Value: "Invalid character: ."})
+	case '"':
+		tok = l.readString()
 	default:
-		tok = Token{Type: INVALID, Value: fmt.Sprintf("Invalid character: %c", l.ch)}
+		tok = Token{Type: INVALID, Value: invalidCharacterMessage(l.ch, l.input[start:l.pos])}
+	}
+
+	tok.Pos = start
+	l.readChar()
+	return tok
 }
 
-l.readChar()
-return tok
+// invalidCharacter holds the message of an INVALID token for each ASCII
+// character, so that lexing allocates nothing: operator values are
+// constants and number and name values slices of the input.
+var invalidCharacter = func() (messages [utf8.RuneSelf]string) {
+	for i := range messages {
+		messages[i] = fmt.Sprintf("Invalid character: %c", rune(i))
+	}
+	return messages
+}()
+
+// invalidCharacterMessage returns the message of an INVALID token for the
+// character ch, spelt text in the input: the whole character, however many
+// bytes it takes, or the byte of a sequence that is not valid UTF-8.
+func invalidCharacterMessage(ch rune, text string) string {
+	switch {
+	case ch < utf8.RuneSelf:
+		return invalidCharacter[ch]
+	case ch == utf8.RuneError && len(text) == 1:
+		return fmt.Sprintf("Invalid UTF-8 byte %#x", text[0])
+	}
+	return fmt.Sprintf("Invalid character: %c", ch)
+}
+
+// either returns double when the character after the current one is next,
+// consuming it, and single otherwise. It lexes two-character operators.
+func (l *Lexer) either(next rune, double, single Token) Token {
+	if l.peekChar(1) == next {
+		l.readChar()
+		return double
+	}
+	return single
 }
 
-// readNumber reads a complete number (integer) from the input.
+// beforeCall reports whether the next character other than white space
+// opens a parenthesis, as after the name of a function called.
+func (l *Lexer) beforeCall() bool {
+	i := 0
+	for unicode.IsSpace(l.peekChar(i)) {
+		i++
+	}
+	return l.peekChar(i) == '('
+}
+
+// peekChar returns the character offset places after the current one without consuming it.
+func (l *Lexer) peekChar(offset int) rune {
+	if offset == 0 {
+		return l.ch
+	}
+	i := l.pos
+	for ; offset > 1 && i < len(l.input); offset-- {
+		_, width := utf8.DecodeRuneInString(l.input[i:])
+		i += width
+	}
+	if i >= len(l.input) {
+		return 0
+	}
+	ch, _ := utf8.DecodeRuneInString(l.input[i:])
+	return ch
+}
+
+// readNumber reads a complete number (integer, decimal or exponent form,
+// with an optional imaginary suffix) from the input.
 func (l *Lexer) readNumber() string {
-	start := l.pos - 1
-	for unicode.IsDigit(l.ch) {
-	l.readChar()
+	start := l.offset()
+	l.readDigits()
+
+	// Fractional part, only when a digit follows the point
+	if l.ch == '.' && isDigit(l.peekChar(1)) {
+		l.readChar()
+		l.readDigits()
+	}
+
+	// Exponent, e.g. 1e21 or 2.5E-3
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar(1)
+		if isDigit(next) || ((next == '+' || next == '-') && isDigit(l.peekChar(2))) {
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	// Imaginary suffix, e.g. 4i, unless it begins an identifier such as in
+	if l.ch == 'i' && !isIdentStart(l.peekChar(1)) && !unicode.IsDigit(l.peekChar(1)) {
+		l.readChar()
+	}
+
+	return l.input[start:l.offset()]
 }
 
-return l.input[start:l.pos-1]
+// readString reads a double-quoted string literal, with Go escape
+// sequences, leaving the lexer on the closing quote.
+func (l *Lexer) readString() Token {
+	start := l.offset()
+	for {
+		l.readChar()
+		switch l.ch {
+		case 0:
+			return Token{Type: INVALID, Value: "Unterminated string literal"}
+		case '\\':
+			l.readChar()
+		case '"':
+			return Token{Type: STRING, Value: l.input[start:l.pos]}
+		}
+	}
+}
 
+// readIdent reads an identifier made of letters, digits and underscores,
+// and of such names joined by dots and followed by indexes, as in
+// customer.discount and items[0].price.
+func (l *Lexer) readIdent() string {
+	start := l.offset()
+	for {
+		if n := l.indexLength(); n > 0 {
+			for ; n > 0; n-- {
+				l.readChar()
+			}
+			continue
+		}
+		if !isIdentStart(l.ch) && !unicode.IsDigit(l.ch) && !(l.ch == '.' && isIdentStart(l.peekChar(1))) {
+			break
+		}
+		l.readChar()
+	}
+
+	return l.input[start:l.offset()]
+}
+
+// indexLength returns the length of the index in brackets, such as [0],
+// at the current character, or 0 when there is none.
+func (l *Lexer) indexLength() int {
+	if l.ch != '[' {
+		return 0
+	}
+	n := 1
+	for isDigit(l.peekChar(n)) {
+		n++
+	}
+	if n == 1 || l.peekChar(n) != ']' {
+		return 0
+	}
+	return n + 1
+}
+
+// isIdentStart reports whether ch can begin an identifier.
+func isIdentStart(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch)
+}
+
+// isDigit reports whether ch is an ASCII decimal digit, as numbers are
+// written with.
+func isDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+// readDigits consumes a run of decimal digits.
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) {
+		l.readChar()
+	}
 }
 
 // Expression tree node types
 type Expr interface{}
 
+// Span is the byte range [Start, End) of a node in the parsed input. Nodes
+// built programmatically have a zero Span.
+type Span struct {
+	Start int
+	End   int
+}
+
 type Number struct {
 	Value float64
+	Imag  bool   // the literal is Value times the imaginary unit, e.g. 4i
+	Text  string // source spelling of a parsed literal, without an i suffix; empty for built nodes and Roman numerals
+	Span  Span
+}
+
+// QuantityLiteral is a number with a unit of measure, evaluated by
+// EvalUnits: 10 km
+type QuantityLiteral struct {
+	Value float64
+	Unit  string
+	Span  Span
+}
+
+// StringLiteral is a string constant: "text"
+type StringLiteral struct {
+	Value string
+	Span  Span
+}
+
+// ListLiteral is a list of numbers: [a, b, c]
+type ListLiteral struct {
+	Elems []Expr
+	Span  Span
 }
 
 type BinaryOp struct {
 	Left  Expr
 	Op    Token
 	Right Expr
+	Span  Span
+}
+
+type UnaryOp struct {
+	Op      Token
+	Operand Expr
+	Span    Span
+}
+
+type Variable struct {
+	Name string
+	Span Span
+}
+
+type FunctionCall struct {
+	Name string
+	Args []Expr
+	Span Span
+}
+
+// Conditional evaluates Then when Cond is true (non-zero) and Else
+// otherwise, leaving the other branch unevaluated: cond ? then : else,
+// if cond then then else else, or if(cond, then, else)
+type Conditional struct {
+	Cond Expr
+	Then Expr
+	Else Expr
+	Span Span
+}
+
+// Let binds Name to Value while evaluating Body: let name = value in body
+type Let struct {
+	Name  string
+	Value Expr
+	Body  Expr
+	Span  Span
+}
+
+// Assign binds Name to Value for the statements of a program that follow
+// it: name = value
+type Assign struct {
+	Name  string
+	Value Expr
+	Span  Span
+}
+
+// SpanOf returns the source span of a node.
+func SpanOf(expr Expr) Span {
+	switch v := expr.(type) {
+	case *Number:
+		return v.Span
+	case *QuantityLiteral:
+		return v.Span
+	case *StringLiteral:
+		return v.Span
+	case *ListLiteral:
+		return v.Span
+	case *BinaryOp:
+		return v.Span
+	case *UnaryOp:
+		return v.Span
+	case *Variable:
+		return v.Span
+	case *FunctionCall:
+		return v.Span
+	case *Let:
+		return v.Span
+	case *Assign:
+		return v.Span
+	case *Conditional:
+		return v.Span
+	case *CellRef:
+		return v.Span
+	case *CellRange:
+		return v.Span
+	}
+	return Span{}
+}
+
+// setSpan updates the source span of a node.
+func setSpan(expr Expr, span Span) {
+	switch v := expr.(type) {
+	case *Number:
+		v.Span = span
+	case *QuantityLiteral:
+		v.Span = span
+	case *StringLiteral:
+		v.Span = span
+	case *ListLiteral:
+		v.Span = span
+	case *BinaryOp:
+		v.Span = span
+	case *UnaryOp:
+		v.Span = span
+	case *Variable:
+		v.Span = span
+	case *FunctionCall:
+		v.Span = span
+	case *Let:
+		v.Span = span
+	case *Assign:
+		v.Span = span
+	case *Conditional:
+		v.Span = span
+	case *CellRef:
+		v.Span = span
+	case *CellRange:
+		v.Span = span
+	}
+}
+
+// joinSpans returns the span from the start of first to the end of last.
+func joinSpans(first, last Expr) Span {
+	return Span{Start: SpanOf(first).Start, End: SpanOf(last).End}
+}
+
+// ErrSyntax is matched by errors.Is for every *ParseError.
+var ErrSyntax = errors.New("syntax error")
+
+// ParseError reports input that is not a valid expression, at the token
+// where parsing failed.
+type ParseError struct {
+	Msg    string
+	Pos    int               // offset of the token in the input
+	code   string            // returned by Code, when more specific than CodeSyntax
+	params map[string]string // of the message, for RenderError
+}
+
+// Error gives the message and the offset.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.Msg, e.Pos)
+}
+
+// Is makes a ParseError match ErrSyntax.
+func (e *ParseError) Is(target error) bool {
+	return target == ErrSyntax
 }
 
 // Parser structure
 type Parser struct {
-	lexer *Lexer
-	curr  Token
+	lexer   *Lexer
+	curr    Token
+	prevEnd int // end offset of the last consumed token
+	cfg     config
+	err     error // of the options, returned by Parse
+	depth   int   // nesting of the expression being parsed, for WithMaxDepth
 }
 
-// NewParser creates a new parser instance
-func NewParser(lexer *Lexer) *Parser {
-	p := &Parser{lexer: lexer}
-	p.nextToken()
+// NewParser creates a new parser instance, configured by opts such as
+// WithMaxDepth
+func NewParser(lexer *Lexer, opts ...Option) *Parser {
+	p := &Parser{}
+	p.Reset(lexer, opts...)
 	return p
 }
 
+// Reset makes the parser parse the tokens of lexer, configured by opts, as
+// a new one would. Resetting a parser and its lexer for each input, rather
+// than making new ones, saves allocating them.
+func (p *Parser) Reset(lexer *Lexer, opts ...Option) {
+	*p = Parser{lexer: lexer}
+	p.cfg, p.err = newConfig(opts)
+	if p.cfg.romanNumerals {
+		lexer.roman = true
+	}
+	if p.cfg.sexagesimal {
+		lexer.sexagesimal = true
+	}
+	if p.err == nil {
+		p.err = lexer.err
+	}
+	if p.err == nil {
+		p.err = p.cfg.checkInput(lexer.input)
+	}
+	if p.err == nil {
+		p.nextToken()
+	}
+}
+
 // nextToken advances to the next token
 func (p *Parser) nextToken() {
+	p.prevEnd = p.curr.Pos + len(p.curr.Value)
 	p.curr = p.lexer.NextToken()
 }
 
-// Parse expression entry point
-func (p *Parser) Parse() (Expr, error) {
+// Parse expression entry point. Invalid input gives a *ParseError, and a
+// panic while parsing a *PanicError.
+func (p *Parser) Parse() (expr Expr, err error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	defer recoverPanic(&err)
 	return p.parseExpr()
 }
 
-// parseExpr handles the parsing of the expression
+// errorf reports a parse error with the code at the current token.
+func (p *Parser) errorf(code, format string, args ...interface{}) *ParseError {
+	return &ParseError{Msg: fmt.Sprintf(format, args...), Pos: p.curr.Pos, code: code}
+}
+
+// expected reports a parse error at the current token, naming what would
+// have been accepted there and what was found instead, as in "expected ')'
+// but found end of input". An invalid token is reported as the lexer
+// describes it, since that is the problem.
+func (p *Parser) expected(what string, args ...interface{}) error {
+	if p.curr.Type == INVALID {
+		err := &ParseError{Msg: p.curr.Value, Pos: p.curr.Pos, code: CodeInvalidToken}
+		if r, _ := utf8.DecodeRuneInString(p.lexer.input[p.curr.Pos:]); r != utf8.RuneError {
+			err.params = map[string]string{"char": string(r)}
+		}
+		return err
+	}
+	expected := fmt.Sprintf(what, args...)
+	err := &ParseError{Msg: fmt.Sprintf("expected %s but found %s", expected, p.curr.found()), Pos: p.curr.Pos, code: CodeUnexpectedToken}
+	err.params = map[string]string{"expected": expected, "found": p.curr.found()}
+	return err
+}
+
+// parseExpr parses a full expression, starting at the lowest precedence
 func (p *Parser) parseExpr() (Expr, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer p.unnest()
+	return p.parseConditional()
+}
 
-	// Start with parsing a term (handles operator precedence)
-	left, err := p.parseTerm()
+// nest enters a level of nesting, failing beyond WithMaxDepth.
+func (p *Parser) nest() error {
+	p.depth++
+	if max := p.cfg.maxDepth; max > 0 && p.depth > max {
+		p.depth--
+		err := p.errorf(CodeLimitDepth, "expression nested more than %d deep", max)
+		err.params = map[string]string{"max": strconv.Itoa(max)}
+		return err
+	}
+	return nil
+}
+
+// unnest leaves a level of nesting.
+func (p *Parser) unnest() {
+	p.depth--
+}
+
+// parseConditional handles the right associative cond ? then : else
+func (p *Parser) parseConditional() (Expr, error) {
+	cond, err := p.parseOr()
 	if err != nil {
-	return nil, err
+		return nil, err
 	}
 
-	// Handle addition and subtraction
-	for p.curr.Type == PLUS || p.curr.Type == MINUS {
-	op := p.curr
+	if p.curr.Type != QUESTION {
+		return cond, nil
+	}
 	p.nextToken()
-	right, err := p.parseTerm()
+
+	then, err := p.parseExpr()
 	if err != nil {
 		return nil, err
 	}
 
-	left = &BinaryOp{Left: left, Op: op, Right: right}
+	if p.curr.Type != COLON {
+		return nil, p.expected("':' in conditional expression")
+	}
+	p.nextToken()
+
+	els, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conditional{Cond: cond, Then: then, Else: els, Span: joinSpans(cond, els)}, nil
+}
+
+// parseOr handles logical or
+func (p *Parser) parseOr() (Expr, error) {
+	return p.parseBinary(p.parseAnd, OR)
+}
+
+// parseAnd handles logical and
+func (p *Parser) parseAnd() (Expr, error) {
+	return p.parseBinary(p.parseBitOr, AND)
+}
+
+// parseBitOr handles bitwise or
+func (p *Parser) parseBitOr() (Expr, error) {
+	return p.parseBinary(p.parseBitXor, BITOR)
+}
+
+// parseBitXor handles bitwise exclusive or, spelled ~ since ^ is the power
+func (p *Parser) parseBitXor() (Expr, error) {
+	return p.parseBinary(p.parseBitAnd, BITXOR)
+}
+
+// parseBitAnd handles bitwise and
+func (p *Parser) parseBitAnd() (Expr, error) {
+	return p.parseBinary(p.parseEquality, BITAND)
+}
+
+// parseEquality handles ==, != and the match =~
+func (p *Parser) parseEquality() (Expr, error) {
+	return p.parseBinary(p.parseComparison, EQ, NE, MATCH)
+}
+
+// parseComparison handles <, <=, > and >=
+func (p *Parser) parseComparison() (Expr, error) {
+	return p.parseBinary(p.parseRange, LT, LE, GT, GE)
+}
+
+// parseRange handles ranges: from..to
+func (p *Parser) parseRange() (Expr, error) {
+	return p.parseBinary(p.parseShift, DOTDOT)
+}
+
+// parseShift handles the shifts << and >>
+func (p *Parser) parseShift() (Expr, error) {
+	return p.parseBinary(p.parseAdditive, SHL, SHR)
+}
+
+// parseBinary parses a left associative chain of the given operators whose
+// operands are parsed by next
+func (p *Parser) parseBinary(next func() (Expr, error), ops ...TokenType) (Expr, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isAny(ops) {
+		op := p.curr
+		p.nextToken()
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = p.newBinary(left, op, right)
+	}
+
+	return left, nil
 }
 
-return left, nil
+// isAny reports whether the current token has one of the given types
+func (p *Parser) isAny(types []TokenType) bool {
+	for _, t := range types {
+		if p.curr.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAdditive handles addition and subtraction
+func (p *Parser) parseAdditive() (Expr, error) {
+
+	// Start with parsing a term (handles operator precedence)
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle addition and subtraction
+	for p.curr.Type == PLUS || p.curr.Type == MINUS {
+		op := p.curr
+		p.nextToken()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+
+		left = p.newBinary(left, op, right)
+	}
+
+	return left, nil
 }
 
-// parseTerm handles multiplication and division
+// parseTerm handles multiplication, division and modulo
 func (p *Parser) parseTerm() (Expr, error) {
-	left, err := p.parseFactor()
+	left, err := p.parseUnary()
 	if err != nil {
 		return nil, err
 	}
 
-	// Handle multiplication and division
-	for p.curr.Type == MULT || p.curr.Type == DIV {
+	// Handle multiplication, division and modulo
+	for p.curr.Type == MULT || p.curr.Type == DIV || p.curr.Type == MOD {
 		op := p.curr
 		p.nextToken()
-		right, err := p.parseFactor()
+		right, err := p.parseUnary()
 
 		if err != nil {
 			return nil, err
+		}
+		left = p.newBinary(left, op, right)
 	}
-	left = &BinaryOp{Left: left, Op: op, Right: right}
+
+	return left, nil
 }
 
-return left, nil
+// parseUnary handles prefix minus and logical not
+func (p *Parser) parseUnary() (Expr, error) {
+	if p.curr.Type == MINUS || p.curr.Type == NOT {
+		op := p.curr
+		p.nextToken()
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseUnary()
+		p.unnest()
+		if err != nil {
+			return nil, err
+		}
+		return p.newUnary(op, operand, Span{Start: op.Pos, End: SpanOf(operand).End}), nil
+	}
+
+	return p.parsePower()
 }
 
-// parseFactor handles numbers and parenthesized expressions
+// parsePower handles right associative exponentiation, which binds tighter than prefix minus
+func (p *Parser) parsePower() (Expr, error) {
+	base, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.curr.Type != POW {
+		return base, nil
+	}
+
+	op := p.curr
+	p.nextToken()
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	exponent, err := p.parseUnary()
+	p.unnest()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.newBinary(base, op, exponent), nil
+}
+
+// parseFactor handles numbers, identifiers, function calls and parenthesized expressions
 func (p *Parser) parseFactor() (Expr, error) {
 	switch p.curr.Type {
-		case NUMBER:
-			value := p.curr.Value
-			p.nextToken()
-			return &Number{Value: parseNumber(value)}, nil
-		case LPAREN:
+	case NUMBER:
+		tok := p.curr
+		p.nextToken()
+		if strings.Contains(tok.Value, ":") {
+			return p.parseSexagesimal(tok)
+		}
+		text := strings.TrimSuffix(tok.Value, "i")
+		value, err := parseNumber(text)
+		if err != nil {
+			return nil, &ParseError{Msg: err.Error(), Pos: tok.Pos, code: CodeInvalidLiteral, params: map[string]string{"literal": text}}
+		}
+		n := p.newNumber(value)
+		n.Imag = text != tok.Value
+		if !isRomanName(text) {
+			n.Text = text
+		}
+		n.Span = Span{Start: tok.Pos, End: p.prevEnd}
+		if p.curr.Type == IDENT && !isKeyword(p.curr.Value) && !n.Imag {
+			// A name right after a literal is its unit: 10 km
+			q := &QuantityLiteral{Value: n.Value, Unit: p.curr.Value}
 			p.nextToken()
-			expr, err := p.parseExpr()
-			if err != nil {
-				return nil, err
-			}
+			q.Span = Span{Start: tok.Pos, End: p.prevEnd}
+			return q, nil
+		}
+		return n, nil
+	case STRING:
+		tok := p.curr
+		p.nextToken()
+		value, err := strconv.Unquote(tok.Value)
+		if err != nil {
+			return nil, &ParseError{Msg: "invalid string literal " + tok.Value, Pos: tok.Pos, code: CodeInvalidLiteral, params: map[string]string{"literal": tok.Value}}
+		}
+		s := Str(value)
+		s.Span = Span{Start: tok.Pos, End: p.prevEnd}
+		return s, nil
+	case IDENT:
+		tok := p.curr
+		if tok.Value == "let" {
+			return p.parseLet()
+		}
+		if tok.Value == "if" {
+			return p.parseIf()
+		}
+		p.nextToken()
+		if p.curr.Type == LPAREN {
+			return p.parseCall(tok)
+		}
+		if p.cfg.cellReferences && isCellName(tok.Value) {
+			return p.parseCell(tok)
+		}
+		v := p.newVariable(tok.Value)
+		v.Span = Span{Start: tok.Pos, End: p.prevEnd}
+		return v, nil
+	case LBRACKET:
+		return p.parseList()
+	case LPAREN:
+		start := p.curr.Pos
+		p.nextToken()
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
 
-			if p.curr.Type != RPAREN {
-				return nil, fmt.Errorf("expected closing parenthesis")
+		if p.curr.Type != RPAREN {
+			return nil, p.expected("')' to close '(' at offset %d", start)
 		}
 
 		p.nextToken()
+
+		// The parentheses belong to the span of the grouped expression
+		setSpan(expr, Span{Start: start, End: p.prevEnd})
 		return expr, nil
-		default:
-			return nil, fmt.Errorf("expected a number or parenthesis, got %v", p.curr.Type)
+	default:
+		return nil, p.expected("number, name, string, '(', '[', or unary '-' or '!'")
+	}
+}
+
+// parseLet parses a let binding: let name = value in body
+func (p *Parser) parseLet() (Expr, error) {
+	start := p.curr.Pos
+	p.nextToken()
+	if p.curr.Type != IDENT {
+		return nil, p.expected("name after let")
+	}
+	name := p.curr.Value
+	p.nextToken()
+
+	if p.curr.Type != ASSIGN {
+		return nil, p.expected("'=' after let %s", name)
+	}
+	p.nextToken()
+
+	value, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.curr.Type != IDENT || p.curr.Value != "in" {
+		return nil, p.expected("'in' after let %s = ...", name)
+	}
+	p.nextToken()
+
+	body, err := p.parseExpr()
+	if err != nil {
+		return nil, err
 	}
+
+	return &Let{Name: name, Value: value, Body: body, Span: Span{Start: start, End: SpanOf(body).End}}, nil
 }
 
-// parseNumber converts string to float64
-func parseNumber(s string) float64 {
-	var num float64
-	
-	fmt.Sscanf(s, "%f", &num)
-	return num
+// parseIf parses a conditional spelled with keywords, if cond then a else
+// b, or as a call, if(cond, a, b), which becomes the same Conditional and so
+// evaluates only the branch it selects. if(cond, a) is 0 when cond is false.
+func (p *Parser) parseIf() (Expr, error) {
+	tok := p.curr
+	start := tok.Pos
+	p.nextToken()
+	if p.curr.Type == LPAREN {
+		lexer, parser := *p.lexer, *p
+		call, err := p.parseCall(tok)
+		if err == nil && (p.curr.Type != IDENT || p.curr.Value != "then") {
+			args := call.(*FunctionCall).Args
+			switch len(args) {
+			case 2:
+				return &Conditional{Cond: args[0], Then: args[1], Else: Num(0), Span: SpanOf(call)}, nil
+			case 3:
+				return &Conditional{Cond: args[0], Then: args[1], Else: args[2], Span: SpanOf(call)}, nil
+			}
+			if len(args) != 1 {
+				return nil, &ParseError{Msg: fmt.Sprintf("if expects 2 or 3 arguments, got %d", len(args)), Pos: start, code: CodeSyntaxArgumentCount, params: map[string]string{"count": strconv.Itoa(len(args))}}
+			}
+		}
+		// The parentheses group the condition of if cond then a else b
+		*p.lexer, *p = lexer, parser
+		if err != nil {
+			// Neither form parses: report the call, which failed first
+			if expr, keywordErr := p.parseIfKeywords(start); keywordErr == nil {
+				return expr, nil
+			}
+			return nil, err
+		}
+	}
+	return p.parseIfKeywords(start)
 }
 
-// Eval evaluates an expression
+// parseIfKeywords parses if cond then a else b from the condition on; start
+// is the offset of if.
+func (p *Parser) parseIfKeywords(start int) (Expr, error) {
+	cond, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.curr.Type != IDENT || p.curr.Value != "then" {
+		return nil, p.expected("'then' after if ...")
+	}
+	p.nextToken()
+
+	then, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.curr.Type != IDENT || p.curr.Value != "else" {
+		return nil, p.expected("'else' after if ... then ...")
+	}
+	p.nextToken()
+
+	els, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conditional{Cond: cond, Then: then, Else: els, Span: Span{Start: start, End: SpanOf(els).End}}, nil
+}
+
+// isKeyword reports whether name is one of the words of let and if
+// expressions, which are never variables or units.
+func isKeyword(name string) bool {
+	switch name {
+	case "let", "in", "if", "then", "else":
+		return true
+	}
+	return false
+}
+
+// parseCall parses the parenthesized, comma separated argument list of a call
+func (p *Parser) parseCall(nameTok Token) (Expr, error) {
+	name := nameTok.Value
+	p.nextToken()
+	call := p.newCall(name)
+
+	if p.curr.Type == RPAREN {
+		p.nextToken()
+		call.Span = Span{Start: nameTok.Pos, End: p.prevEnd}
+		return call, nil
+	}
+
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+
+		if p.curr.Type == COMMA {
+			p.nextToken()
+			continue
+		}
+		if p.curr.Type != RPAREN {
+			return nil, p.expected("',' or ')' in call to %s", name)
+		}
+		p.nextToken()
+		call.Span = Span{Start: nameTok.Pos, End: p.prevEnd}
+		return call, nil
+	}
+}
+
+// parseList parses the bracketed, comma separated elements of a list literal
+func (p *Parser) parseList() (Expr, error) {
+	start := p.curr.Pos
+	p.nextToken()
+	list := &ListLiteral{}
+
+	if p.curr.Type == RBRACKET {
+		p.nextToken()
+		list.Span = Span{Start: start, End: p.prevEnd}
+		return list, nil
+	}
+
+	for {
+		elem, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		list.Elems = append(list.Elems, elem)
+
+		if p.curr.Type == COMMA {
+			p.nextToken()
+			continue
+		}
+		if p.curr.Type != RBRACKET {
+			return nil, p.expected("',' or ']' in list")
+		}
+		p.nextToken()
+		list.Span = Span{Start: start, End: p.prevEnd}
+		return list, nil
+	}
+}
+
+// parseNumber converts the text of a number literal to float64. A literal
+// too large for a float64 is an error, as is text the lexer should never
+// have produced, rather than 0. A Roman numeral, lexed WithRomanNumerals,
+// gives its value.
+func parseNumber(s string) (float64, error) {
+	if isRomanName(s) {
+		return parseRoman(s)
+	}
+	num, err := strconv.ParseFloat(s, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("number literal %s is out of range", s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid number literal %q", s)
+	}
+	return num, nil
+}
+
+// Eval evaluates an expression with the default Evaluator
 func Eval(expr Expr) (float64, error) {
+	return defaultEvaluator.Eval(expr)
+}
+
+// EvalWithVars evaluates an expression, resolving variables that are not
+// bound by let from vars. The map is only read; a nil map behaves like an
+// empty one. A name found in neither gives an *EvalError wrapping an
+// *UndefinedVariableError.
+func EvalWithVars(expr Expr, vars map[string]float64) (float64, error) {
+	ev := *defaultEvaluator
+	ev.vars = vars
+	return ev.Eval(expr)
+}
+
+// UndefinedVariableError reports a variable that has no value during evaluation.
+type UndefinedVariableError struct {
+	Name string
+	Span Span // position of the reference; zero for trees built without spans
+}
+
+// Error names the variable and, when known, its offset in the input.
+func (e *UndefinedVariableError) Error() string {
+	if e.Span == (Span{}) {
+		return e.withoutOffset()
+	}
+	return fmt.Sprintf("undefined variable %s at offset %d", e.Name, e.Span.Start)
+}
+
+// withoutOffset names the variable.
+func (e *UndefinedVariableError) withoutOffset() string {
+	return fmt.Sprintf("undefined variable %s", e.Name)
+}
+
+// Is makes an UndefinedVariableError match ErrUndefinedVariable.
+func (e *UndefinedVariableError) Is(target error) bool {
+	return target == ErrUndefinedVariable
+}
+
+// scope is a chain of let bindings, innermost first. A scope with vars,
+// values or env set resolves names from that map or environment instead of
+// binding a single name.
+type scope struct {
+	name   string
+	value  Value
+	vars   map[string]float64
+	values map[string]Value
+	env    *Environment
+	parent *scope
+}
+
+// lookup finds the innermost binding of name.
+func (s *scope) lookup(name string) (Value, bool) {
+	for ; s != nil; s = s.parent {
+		switch {
+		case s.vars != nil:
+			if value, ok := s.vars[name]; ok {
+				return NumberValue(value), true
+			}
+		case s.values != nil:
+			if value, ok := s.values[name]; ok {
+				return value, true
+			}
+		case s.env != nil:
+			if value, ok := s.env.Get(name); ok {
+				return value, true
+			}
+		case s.name == name:
+			return s.value, true
+		}
+	}
+	return Value{}, false
+}
+
+// operatorPos returns the offset of an operator token for error messages,
+// or -1 when the node was built without spans.
+func operatorPos(op Token, span Span) int {
+	if span == (Span{}) {
+		return -1
+	}
+	return op.Pos
+}
+
+// eval evaluates an expression with the given let bindings in scope
+func (ev *Evaluator) eval(expr Expr, env *scope) (Value, error) {
+	if ev.progress != nil {
+		if err := ev.progress.step(expr); err != nil {
+			return Value{}, err
+		}
+	}
+	if ev.cfg.beforeNode == nil && ev.cfg.afterNode == nil {
+		return ev.visit(expr, env)
+	}
+	if err := ev.before(expr); err != nil {
+		return Value{}, err
+	}
+	value, err := ev.visit(expr, env)
+	ev.after(expr, value, err)
+	return value, err
+}
+
+// visit evaluates an expression that eval has counted, between the hooks.
+func (ev *Evaluator) visit(expr Expr, env *scope) (Value, error) {
+	if ev.memo != nil {
+		if value, ok := ev.memo.lookup(expr, env); ok {
+			return value, nil
+		}
+	}
+	mark := 0
+	if ev.trace != nil {
+		mark = ev.trace.mark()
+	}
+	if ev.stats != nil {
+		ev.stats.enter(1)
+	}
+	value, err := ev.evalNode(expr, env)
+	if ev.stats != nil {
+		ev.stats.depth--
+	}
+	if err != nil {
+		return Value{}, ev.locate(expr, err)
+	}
+	if value, err = ev.finite(expr, value); err != nil {
+		return Value{}, err
+	}
+	if ev.memo != nil {
+		ev.memo.store(expr, env, value)
+	}
+	if ev.trace != nil {
+		ev.trace.reduce(expr, value, mark)
+	}
+	return value, nil
+}
+
+// finite applies the NonFiniteResults policy to the value of expr.
+func (ev *Evaluator) finite(expr Expr, value Value) (Value, error) {
+	if ev.cfg.nonFinite == RejectNonFinite && value.kind == NumberKind && isNonFinite(value.num) {
+		return Value{}, &NonFiniteError{Value: value.num, Expr: expr}
+	}
+	return value, nil
+}
+
+// evalNode evaluates the node at the root of expr, calling eval for its children.
+func (ev *Evaluator) evalNode(expr Expr, env *scope) (Value, error) {
 	switch v := expr.(type) {
-		case *Number:
-			return v.Value, nil
-		case *BinaryOp:
-			left, err := Eval(v.Left)
+	case *Number:
+		if v.Imag {
+			return Value{}, imaginaryError(v, "real")
+		}
+		return NumberValue(v.Value), nil
+	case *QuantityLiteral:
+		if value, ok, err := durationOf(v); ok {
+			return value, err
+		}
+		return Value{}, quantityError(v, "real")
+	case *StringLiteral:
+		return StringValue(v.Value), nil
+	case *BinaryOp:
+		return ev.evalChain(v, env)
+	case *UnaryOp:
+		operand, err := ev.eval(v.Operand, env)
+		if err != nil {
+			return Value{}, err
+		}
+		if ev.stats != nil {
+			if v.Op.Type == MINUS {
+				ev.stats.operator("neg")
+			} else {
+				ev.stats.operator(operatorSymbol(v.Op))
+			}
+		}
+		pos := operatorPos(v.Op, v.Span)
+		switch v.Op.Type {
+		case MINUS:
+			return negateValue(operand, pos)
+		case NOT:
+			b, err := operand.condition("!", pos)
 			if err != nil {
-				return 0, err
+				return Value{}, err
 			}
-			right, err := Eval(v.Right)
+			return BoolValue(!b), nil
+		}
+	case *Variable:
+		if value, ok := env.lookup(v.Name); ok {
+			return value, nil
+		}
+		if err := env.pathError(v.Name); err != nil {
+			return Value{}, err
+		}
+		return ev.undefinedVariable(v)
+	case *FunctionCall:
+		return ev.callBuiltin(v, env)
+	case *CellRef:
+		return ev.cellValue(v)
+	case *CellRange:
+		return ev.cellRange(v)
+	case *ListLiteral:
+		values := make([]Value, len(v.Elems))
+		for i, elem := range v.Elems {
+			value, err := ev.eval(elem, env)
 			if err != nil {
-				return 0, err
+				return Value{}, err
 			}
-	switch v.Op.Type {
-		case PLUS:
-			return left + right, nil
-		case MINUS:
-			return left - right, nil
-		case MULT:
-			return left * right, nil
-		case DIV:
-			if right == 0 {
-				return 0, fmt.Errorf("division by zero")
+			values[i] = value
+		}
+		return listOf(v, values)
+	case *Let:
+		value, err := ev.eval(v.Value, env)
+		if err != nil {
+			return Value{}, err
+		}
+		return ev.eval(v.Body, &scope{name: v.Name, value: value, parent: env})
+	case *Assign:
+		return Value{}, assignmentError(v)
+	case *Conditional:
+		cond, err := ev.eval(v.Cond, env)
+		if err != nil {
+			return Value{}, err
+		}
+		pos := -1
+		if v.Span != (Span{}) {
+			pos = SpanOf(v.Cond).Start
+		}
+		b, err := cond.condition("?:", pos)
+		if err != nil {
+			return Value{}, err
+		}
+		if b {
+			value, err := ev.eval(v.Then, env)
+			if err == nil && ev.trace != nil {
+				ev.trace.skip(v.Else)
 			}
-			return left / right, nil
+			if err == nil && ev.stats != nil {
+				ev.stats.stats.Skipped++
+			}
+			return value, err
+		}
+		if ev.trace != nil {
+			ev.trace.skip(v.Then)
 		}
+		if ev.stats != nil {
+			ev.stats.stats.Skipped++
+		}
+		return ev.eval(v.Else, env)
 	default:
-		return 0, fmt.Errorf("unsupported expression type")
+		return Value{}, withCode(CodeUnsupported, fmt.Errorf("unsupported expression type"))
+	}
+
+	return Value{}, withCode(CodeUnsupported, fmt.Errorf("invalid expression"))
+}
+
+// evalChain evaluates a binary operator whose node eval has already
+// counted. The chain of binary operators down its left operands, as in the
+// trees parsed from "1+1+1+…", is evaluated in a loop rather than by
+// recursion, so its length is bounded by memory and not by the stack. Each
+// node of the chain is counted and checked as eval would.
+func (ev *Evaluator) evalChain(root *BinaryOp, env *scope) (Value, error) {
+	mark := 0
+	if ev.trace != nil {
+		mark = ev.trace.mark()
+	}
+	chain := []*BinaryOp{root}
+	var value Value
+	cached := false
+	for !cached {
+		left, ok := chain[len(chain)-1].Left.(*BinaryOp)
+		if !ok {
+			break
+		}
+		if ev.progress != nil {
+			if err := ev.progress.step(left); err != nil {
+				return Value{}, ev.abandon(chain[1:], err)
+			}
+		}
+		if err := ev.before(left); err != nil {
+			return Value{}, ev.abandon(chain[1:], err)
+		}
+		if ev.memo != nil {
+			// A cached left operand ends the chain
+			value, cached = ev.memo.lookup(left, env)
+		}
+		if cached {
+			ev.after(left, value, nil)
+		}
+		if !cached {
+			chain = append(chain, left)
+		}
+	}
+
+	if ev.stats != nil {
+		// The nodes of the chain below the root nest as if evaluated by recursion
+		ev.stats.enter(len(chain) - 1)
+	}
+	if !cached {
+		var err error
+		if value, err = ev.eval(chain[len(chain)-1].Left, env); err != nil {
+			return Value{}, ev.abandon(chain[1:], err)
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		var err error
+		if value, err = ev.binary(chain[i], value, env); err != nil {
+			return Value{}, ev.abandon(chain[1:i+1], ev.locate(chain[i], err))
+		}
+		if i > 0 && ev.stats != nil {
+			ev.stats.depth--
+		}
+		if i > 0 {
+			if value, err = ev.finite(chain[i], value); err != nil {
+				return Value{}, ev.abandon(chain[1:i+1], err)
+			}
+			if ev.memo != nil {
+				ev.memo.store(chain[i], env, value)
+			}
+			if ev.trace != nil {
+				ev.trace.reduce(chain[i], value, mark)
+			}
+			ev.after(chain[i], value, nil)
+		}
+	}
+	return value, nil
+}
+
+// binary finishes evaluating a binary operator given the value of its left operand.
+func (ev *Evaluator) binary(v *BinaryOp, left Value, env *scope) (Value, error) {
+	if ev.stats != nil {
+		ev.stats.operator(operatorSymbol(v.Op))
+	}
+	if v.Op.Type == AND || v.Op.Type == OR {
+		return ev.logical(v, left, env)
+	}
+	right, err := ev.eval(v.Right, env)
+	if err != nil {
+		return Value{}, err
+	}
+	if left.kind == ListKind || right.kind == ListKind {
+		return ev.elementwise(v.Op, operatorPos(v.Op, v.Span), left, right, v.Right)
+	}
+	if isDivision(v.Op) && right.numeric() && left.numeric() && right.num == 0 {
+		value, ieee, err := ev.zeroDivision(v.Op, v.Right)
+		if err != nil {
+			return Value{}, err
+		}
+		if !ieee {
+			return NumberValue(value), nil
+		}
+	}
+	return ev.binaryValue(v.Op, operatorPos(v.Op, v.Span), left, right)
+}
+
+// logical finishes evaluating && or || given the value of the left operand.
+// The right operand is evaluated only when the left does not decide the
+// result, so "d != 0 && n/d > 2" never divides by zero.
+func (ev *Evaluator) logical(v *BinaryOp, left Value, env *scope) (Value, error) {
+	pos := operatorPos(v.Op, v.Span)
+	l, err := left.condition(operatorSymbol(v.Op), pos)
+	if err != nil {
+		return Value{}, err
+	}
+	if l == (v.Op.Type == OR) {
+		if ev.trace != nil {
+			ev.trace.skip(v.Right)
+		}
+		if ev.stats != nil {
+			ev.stats.stats.Skipped++
+		}
+		return BoolValue(l), nil
+	}
+
+	right, err := ev.eval(v.Right, env)
+	if err != nil {
+		return Value{}, err
+	}
+	if !right.numeric() {
+		return Value{}, &TypeError{Op: operatorSymbol(v.Op), Operands: []Kind{left.kind, right.kind}, Pos: pos}
+	}
+	return BoolValue(isTrue(right.num)), nil
+}
+
+// binaryValue applies a binary operator other than && and || to two values,
+// with IEEE semantics for a zero divisor; pos locates the operator for type
+// errors.
+func (ev *Evaluator) binaryValue(op Token, pos int, a, b Value) (Value, error) {
+	symbol := operatorSymbol(op)
+	if op.Type == MATCH {
+		return ev.matchValue(pos, a, b)
+	}
+	if a.kind == DateKind || a.kind == DurationKind || b.kind == DateKind || b.kind == DurationKind {
+		return dateOperation(op, pos, a, b)
+	}
+
+	// Strings concatenate and compare for equality, with each other only
+	if a.kind == StringKind && b.kind == StringKind {
+		switch op.Type {
+		case PLUS:
+			return StringValue(a.str + b.str), nil
+		case EQ:
+			return BoolValue(a.str == b.str), nil
+		case NE:
+			return BoolValue(a.str != b.str), nil
+		}
+	}
+
+	left, right, err := numbers(symbol, pos, a, b)
+	if err != nil {
+		return Value{}, err
+	}
+	switch op.Type {
+	case PLUS:
+		return NumberValue(left + right), nil
+	case MINUS:
+		return NumberValue(left - right), nil
+	case MULT:
+		return NumberValue(left * right), nil
+	case DIV:
+		return NumberValue(left / right), nil
+	case MOD:
+		return NumberValue(ev.mod(left, right)), nil
+	case POW:
+		return NumberValue(math.Pow(left, right)), nil
+	case LT:
+		return BoolValue(left < right && !ev.equalNumbers(left, right)), nil
+	case LE:
+		return BoolValue(left <= right || ev.equalNumbers(left, right)), nil
+	case GT:
+		return BoolValue(left > right && !ev.equalNumbers(left, right)), nil
+	case GE:
+		return BoolValue(left >= right || ev.equalNumbers(left, right)), nil
+	case EQ:
+		return BoolValue(ev.equalNumbers(left, right)), nil
+	case NE:
+		return BoolValue(!ev.equalNumbers(left, right)), nil
+	case DOTDOT:
+		return rangeValue(left, right, pos)
+	case BITAND, BITOR, BITXOR, SHL, SHR:
+		return bitwise(op, pos, left, right)
+	}
+	return Value{}, withCode(CodeUnsupported, fmt.Errorf("unsupported operator %s", symbol))
+}
+
+// truth converts a boolean to the numeric result of a comparison or logical operator: 1 or 0.
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
 }
 
-return 0, fmt.Errorf("invalid expression")
+// isTrue reports whether a value counts as true in a condition: any number
+// other than zero and NaN.
+func isTrue(v float64) bool {
+	return v != 0 && !math.IsNaN(v)
 }
 
-// end of file
\ No newline at end of file
+// end of file
@@ -0,0 +1,293 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestLexUnicodeIdentifiers(t *testing.T) {
+	tests := []struct {
+		input string
+		names []string
+	}{
+		{"café + 1", []string{"café"}},
+		{"é", []string{"é"}},
+		{"xé", []string{"xé"}},
+		{"naïve * 2", []string{"naïve"}},
+		{"Δt / t₀", []string{"Δt", "t"}},
+		{"größe.höhe", []string{"größe.höhe"}},
+	}
+	for _, tt := range tests {
+		var names []string
+		l := NewLexer(tt.input)
+		for tok := l.NextToken(); tok.Type != EOF; tok = l.NextToken() {
+			if tok.Type == INVALID {
+				break
+			}
+			if tok.Type == IDENT {
+				names = append(names, tok.Value)
+				if got := tt.input[tok.Pos : tok.Pos+len(tok.Value)]; got != tok.Value {
+					t.Errorf("%q: identifier %q at offset %d, where the input has %q", tt.input, tok.Value, tok.Pos, got)
+				}
+			}
+		}
+		if strings.Join(names, ",") != strings.Join(tt.names, ",") {
+			t.Errorf("%q: identifiers %q, want %q", tt.input, names, tt.names)
+		}
+	}
+
+	vars := map[string]float64{"café": 2, "naïve": 3}
+	if got, err := EvaluateWithVars("café * naïve + 1", vars); err != nil || got != 7 {
+		t.Errorf(`"café * naïve + 1" = %v, %v; want 7`, got, err)
+	}
+}
+
+func TestLexInvalidRune(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+		pos   int
+	}{
+		{"a © b", "Invalid character: ©", 2},
+		{"t₀", "Invalid character: ₀", 1},
+		{"1 + €5", "Invalid character: €", 4},
+		{"a \xff b", "Invalid UTF-8 byte 0xff", 2},
+		{"٣ + 1", "Invalid character: ٣", 0},
+	}
+	for _, tt := range tests {
+		_, err := ParseString(tt.input)
+		pe, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%q: error %v, want a *ParseError", tt.input, err)
+			continue
+		}
+		if pe.Msg != tt.msg || pe.Pos != tt.pos {
+			t.Errorf("%q: %q at offset %d, want %q at offset %d", tt.input, pe.Msg, pe.Pos, tt.msg, tt.pos)
+		}
+	}
+
+	want := "Invalid character: © at offset 8\ncafé + © 1\n       ^"
+	input := "café + © 1"
+	_, err := ParseString(input)
+	if got := FormatError(input, err); got != want {
+		t.Errorf("FormatError(%q) =\n%s\nwant\n%s", input, got, want)
+	}
+}
+
+func TestEvalWithVars(t *testing.T) {
+	expr := mustParse(t, "price*qty")
+	vars := map[string]float64{"price": 2.5, "qty": 4, "unused": 1}
+	before := map[string]float64{"price": 2.5, "qty": 4, "unused": 1}
+	if got, err := EvalWithVars(expr, vars); err != nil || got != 10 {
+		t.Errorf("price*qty = %v, %v; want 10", got, err)
+	}
+	if !reflect.DeepEqual(vars, before) {
+		t.Errorf("EvalWithVars changed the map to %v", vars)
+	}
+	if got, err := EvalWithVars(mustParse(t, "let qty = 3 in price * qty"), vars); err != nil || got != 7.5 {
+		t.Errorf("let qty = 3 in price * qty = %v, %v; want 7.5", got, err)
+	}
+}
+
+func TestEvalWithVarsMissing(t *testing.T) {
+	expr := mustParse(t, "price*qty")
+	for _, vars := range []map[string]float64{{"price": 2.5}, {}, nil} {
+		_, err := EvalWithVars(expr, vars)
+		var undefined *UndefinedVariableError
+		if !errors.As(err, &undefined) {
+			t.Errorf("price*qty with %v: error %v, want an *UndefinedVariableError", vars, err)
+			continue
+		}
+		name, span := "qty", Span{6, 9}
+		if len(vars) == 0 {
+			name, span = "price", Span{0, 5}
+		}
+		if undefined.Name != name || undefined.Span != span {
+			t.Errorf("price*qty with %v: undefined %s at %v, want %s at %v", vars, undefined.Name, undefined.Span, name, span)
+		}
+		if !errors.Is(err, ErrUndefinedVariable) {
+			t.Errorf("price*qty with %v: error %v is not ErrUndefinedVariable", vars, err)
+		}
+	}
+	_, err := EvalWithVars(Var("qty"), nil)
+	var undefined *UndefinedVariableError
+	if !errors.As(err, &undefined) || undefined.Error() != "undefined variable qty" {
+		t.Errorf("built qty without a value: error %v, want undefined variable qty without an offset", err)
+	}
+}
+
+func TestEvalWithVarsConstants(t *testing.T) {
+	expr := mustParse(t, "(2 + 3) * 4")
+	for _, vars := range []map[string]float64{nil, {"x": 1}} {
+		if got, err := EvalWithVars(expr, vars); err != nil || got != 20 {
+			t.Errorf("(2 + 3) * 4 with %v = %v, %v; want 20", vars, got, err)
+		}
+	}
+	if got, err := Eval(expr); err != nil || got != 20 {
+		t.Errorf("Eval((2 + 3) * 4) = %v, %v; want 20", got, err)
+	}
+}
+
+func TestEvalDeepChain(t *testing.T) {
+	const depth = 100000
+	var expr Expr = Var("x")
+	for i := 1; i < depth; i++ {
+		op := Add
+		if i%2 == 0 {
+			op = Sub
+		}
+		expr = op(expr, Num(float64(i)))
+	}
+	// Recursing once per node would need far more stack than this
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+	got, err := EvalWithVars(expr, map[string]float64{"x": 1})
+	if want := float64(1 + depth/2); err != nil || got != want {
+		t.Errorf("%d-deep chain = %v, %v; want %v", depth, got, err, want)
+	}
+
+	// An error deep in the chain is reported at its node
+	expr = Div(expr, Var("zero"))
+	for i := 0; i < depth; i++ {
+		expr = Mul(expr, Num(2))
+	}
+	_, err = EvalWithVars(expr, map[string]float64{"x": 1, "zero": 0})
+	var evalErr *EvalError
+	if !errors.Is(err, ErrDivisionByZero) || !errors.As(err, &evalErr) || evalErr.Expr.(*BinaryOp).Op.Type != DIV {
+		t.Errorf("chain dividing by zero: error %v, want an *EvalError for the division", err)
+	}
+}
+
+func TestEvalChainMatchesRecursive(t *testing.T) {
+	// CompileFunc, whose closures do not go through evalChain, checks the results
+	corpus := append([]string{
+		"1 - 2 * 3 + 4 / 5 - n % 4 + x ^ 2 - y",
+		"x + 1 < n - 2 == 1 && y * 2 - 1 != 0",
+		"1 + 2 + x / zero + 3",
+		"(let a = 2 in a * x) + y - n * 2 - 1",
+		"x - y - n - 1 - 2 - 3",
+		"1 + 2 + unknown + 3",
+		"sqrt(n) + max(x, y) * 2 - 1 - abs(y)",
+		"zero || 1 + 1 + 1",
+	}, evalCorpus...)
+	var long strings.Builder
+	long.WriteString("x")
+	for i := 0; i < 1000; i++ {
+		long.WriteString([]string{" + n", " - y", " * 1.001", " / 1.002"}[i%4])
+	}
+	corpus = append(corpus, long.String())
+	for _, input := range corpus {
+		expr := mustParse(t, input)
+		fn, err := CompileFunc(expr)
+		if err != nil {
+			t.Fatalf("CompileFunc(%q): %v", input, err)
+		}
+		want, wantErr := fn(corpusVars)
+		got, gotErr := EvalWithVars(expr, corpusVars)
+		if !sameResult(got, gotErr, want, wantErr) {
+			t.Errorf("%q: Eval gives %v, %v; CompileFunc %v, %v", input, got, gotErr, want, wantErr)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		text string
+		want float64
+		msg  string
+	}{
+		{"42", 42, ""},
+		{"2.5e3", 2500, ""},
+		{"1e-400", 0, ""},
+		{"1e999", 0, "number literal 1e999 is out of range"},
+		{"1.2.3", 0, `invalid number literal "1.2.3"`},
+		{"1e", 0, `invalid number literal "1e"`},
+		{"", 0, `invalid number literal ""`},
+	}
+	for _, tt := range tests {
+		got, err := parseNumber(tt.text)
+		if tt.msg != "" {
+			if err == nil || err.Error() != tt.msg {
+				t.Errorf("parseNumber(%q) = %v, %v; want error %q", tt.text, got, err, tt.msg)
+			}
+		} else if err != nil || got != tt.want {
+			t.Errorf("parseNumber(%q) = %v, %v; want %v", tt.text, got, err, tt.want)
+		}
+	}
+
+	// A literal that does not convert is a parse error, not 0
+	for input, msg := range map[string]string{
+		"1e999 + 1": "number literal 1e999 is out of range at offset 0",
+		"2 * 1e400": "number literal 1e400 is out of range at offset 4",
+	} {
+		got, err := Evaluate(input)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || err.Error() != msg {
+			t.Errorf("Evaluate(%q) = %v, %v; want %q", input, got, err, msg)
+		}
+	}
+}
+
+// BenchmarkParseNumbers parses expressions made mostly of literals, whose
+// conversion dominates.
+func BenchmarkParseNumbers(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&sb, "%d.%03d * 1.5e%d + ", i, i*7%1000, i%20)
+	}
+	sb.WriteString("0.125")
+	input := sb.String()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseString(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// tokenizeCorpus is representative input for the lexer: every kind of
+// token, and the evaluation corpus.
+var tokenizeCorpus = append([]string{
+	`"str" + 'x' =~ "a.*"`,
+	"1 m + 2.5e3 km",
+	"[1..3]; x = y << 2 | z & 7 ^ ~w",
+	"let a = 1 in a ? b : c >= d != e",
+	"1 + 2 $", // an invalid ASCII character costs nothing either
+}, evalCorpus...)
+
+// tokenize lexes input to its end with l.
+func tokenize(l *Lexer, input string) {
+	l.Reset(input)
+	for tok := l.NextToken(); tok.Type != EOF && tok.Type != INVALID; tok = l.NextToken() {
+	}
+}
+
+func TestTokenizeAllocs(t *testing.T) {
+	l := NewLexer("")
+	for _, input := range tokenizeCorpus {
+		if allocs := testing.AllocsPerRun(100, func() { tokenize(l, input) }); allocs != 0 {
+			t.Errorf("lexing %q allocates %v times, want none", input, allocs)
+		}
+	}
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	l := NewLexer("")
+	size := 0
+	for _, input := range tokenizeCorpus {
+		size += len(input)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(size))
+	for i := 0; i < b.N; i++ {
+		for _, input := range tokenizeCorpus {
+			tokenize(l, input)
+		}
+	}
+}
+
+// end of file
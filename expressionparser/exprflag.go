@@ -0,0 +1,111 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExprFlag is a command-line flag whose value is an expression, such as
+// "1024*1024*50" or "0.95*baseline", for flag.Var and pflag alike:
+//
+//	limit := expressionparser.NewExprFlag("1024*1024")
+//	flag.Var(limit, "limit", "the limit in bytes")
+//
+// The flag made by NewExprFlag takes only constant expressions, which Set
+// evaluates at once; the one made by NewDeferredExprFlag takes any, to be
+// evaluated with Eval once its variables are known.
+type ExprFlag struct {
+	expr     *Expression
+	value    float64
+	deferred bool
+	opts     []Option
+}
+
+// NewExprFlag returns a flag taking constant expressions, with the value
+// of def, which must be constant, until it is set. The options configure
+// the Parser and the Evaluator as in Compile. It panics when def is not a
+// constant expression, as MustParse does when input does not parse.
+func NewExprFlag(def string, opts ...Option) *ExprFlag {
+	return newExprFlag(def, false, opts)
+}
+
+// NewDeferredExprFlag is NewExprFlag for a flag taking any expression,
+// whose variables are given when it is evaluated with Eval.
+func NewDeferredExprFlag(def string, opts ...Option) *ExprFlag {
+	return newExprFlag(def, true, opts)
+}
+
+// newExprFlag returns a flag set to def.
+func newExprFlag(def string, deferred bool, opts []Option) *ExprFlag {
+	f := &ExprFlag{deferred: deferred, opts: opts}
+	if err := f.Set(def); err != nil {
+		panic(fmt.Errorf("expressionparser: default %q of an expression flag: %w", def, err))
+	}
+	return f
+}
+
+// Set compiles s as the value of the flag with Compile, and when the flag
+// is not deferred requires that it be constant. A deferred flag with a
+// constant expression is evaluated here too.
+func (f *ExprFlag) Set(s string) error {
+	expr, err := Compile(s, f.opts...)
+	if err != nil {
+		return err
+	}
+	var value float64
+	switch {
+	case expr.IsConstant():
+		value, _ = expr.Eval(nil)
+	case !f.deferred && len(expr.Vars()) > 0:
+		return withCode(CodeInvalidArgument, fmt.Errorf("%q is not a constant expression: it uses %s", s, strings.Join(expr.Vars(), ", ")))
+	case !f.deferred:
+		return withCode(CodeInvalidArgument, fmt.Errorf("%q is not a constant expression", s))
+	}
+	f.expr, f.value = expr, value
+	return nil
+}
+
+// String returns the expression in the normalized form Format prints, or
+// "" for the zero ExprFlag, which the flag package makes to tell whether a
+// default is the zero value.
+func (f *ExprFlag) String() string {
+	if f == nil || f.expr == nil {
+		return ""
+	}
+	return f.expr.String()
+}
+
+// Type names the type of the flag's values in the help of pflag.
+func (f *ExprFlag) Type() string {
+	return "expr"
+}
+
+// Source returns the expression as it was set, before normalizing.
+func (f *ExprFlag) Source() string {
+	if f.expr == nil {
+		return ""
+	}
+	return f.expr.id
+}
+
+// Value returns the value of a constant expression, as evaluated by Set,
+// and 0 for one that is not constant.
+func (f *ExprFlag) Value() float64 {
+	return f.value
+}
+
+// Expression returns the expression compiled by Set, nil for a flag that
+// was never set.
+func (f *ExprFlag) Expression() *Expression {
+	return f.expr
+}
+
+// Eval evaluates the expression with vars, as Expression.Eval does.
+func (f *ExprFlag) Eval(vars map[string]float64) (float64, error) {
+	if f.expr == nil {
+		return 0, withCode(CodeInvalidArgument, fmt.Errorf("expression flag was never set"))
+	}
+	return f.expr.Eval(vars)
+}
+
+// end of file
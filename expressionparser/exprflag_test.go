@@ -0,0 +1,80 @@
+package expressionparser
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+// flagSet returns a flag set with the constant flag limit and the deferred
+// flag threshold, and no output.
+func flagSet() (*flag.FlagSet, *ExprFlag, *ExprFlag) {
+	fs := flag.NewFlagSet("tool", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	limit := NewExprFlag("1024*1024")
+	threshold := NewDeferredExprFlag("0.95*baseline")
+	fs.Var(limit, "limit", "the limit in bytes")
+	fs.Var(threshold, "threshold", "the threshold")
+	return fs, limit, threshold
+}
+
+func TestExprFlag(t *testing.T) {
+	fs, limit, threshold := flagSet()
+	if limit.Value() != 1<<20 || limit.String() != "1024 * 1024" || threshold.Source() != "0.95*baseline" {
+		t.Errorf("defaults %v %q %q", limit.Value(), limit.String(), threshold.Source())
+	}
+	if err := fs.Parse([]string{"-limit", "1024*1024*50", "-threshold=0.8 * baseline + margin"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if limit.Value() != 50<<20 || limit.Source() != "1024*1024*50" || limit.String() != "1024 * 1024 * 50" || !limit.Expression().IsConstant() {
+		t.Errorf("-limit: value %v, source %q, string %q", limit.Value(), limit.Source(), limit.String())
+	}
+	if got, err := threshold.Eval(map[string]float64{"baseline": 100, "margin": 5}); err != nil || got != 85 {
+		t.Errorf("-threshold Eval = %v, %v; want 85", got, err)
+	}
+	if threshold.Value() != 0 || threshold.Type() != "expr" {
+		t.Errorf("-threshold: value %v, type %q", threshold.Value(), threshold.Type())
+	}
+
+	// A constant deferred expression is evaluated by Set
+	if err := fs.Parse([]string{"-threshold", "2^3"}); err != nil || threshold.Value() != 8 {
+		t.Errorf("-threshold 2^3: value %v, %v", threshold.Value(), err)
+	}
+}
+
+func TestExprFlagErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		msg  string
+	}{
+		{[]string{"-limit", "0.95*baseline"}, `invalid value "0.95*baseline" for flag -limit: "0.95*baseline" is not a constant expression: it uses baseline`},
+		{[]string{"-limit", "rand()"}, `invalid value "rand()" for flag -limit: "rand()" is not a constant expression`},
+		{[]string{"-limit", "1024 *"}, `invalid value "1024 *" for flag -limit: expected number, name, string, '(', '[', or unary '-' or '!' but found end of input at offset 6`},
+		{[]string{"-threshold", "1/0"}, `invalid value "1/0" for flag -threshold: division by zero in "1 / 0" at offset 0`},
+	}
+	for _, tt := range tests {
+		fs, limit, _ := flagSet()
+		err := fs.Parse(tt.args)
+		if err == nil || err.Error() != tt.msg {
+			t.Errorf("Parse(%q): error %v, want %q", tt.args, err, tt.msg)
+		}
+		// The value is left as it was
+		if limit.Value() != 1<<20 {
+			t.Errorf("Parse(%q): -limit %v after the error", tt.args, limit.Value())
+		}
+	}
+
+	var zero ExprFlag
+	if _, err := zero.Eval(nil); ErrorCode(err) != CodeInvalidArgument || zero.String() != "" {
+		t.Errorf("zero ExprFlag: Eval error %v, String %q", err, zero.String())
+	}
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(error).Error(), `default "x" of an expression flag`) {
+			t.Errorf("NewExprFlag(x): panic %v", r)
+		}
+	}()
+	NewExprFlag("x")
+}
+
+// end of file
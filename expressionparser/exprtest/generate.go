@@ -0,0 +1,218 @@
+// Package exprtest generates random expressions for testing programs that
+// parse or evaluate them, such as round trips through the printer and
+// comparisons of evaluation modes.
+package exprtest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	ep "github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser"
+)
+
+// GenConfig configures Generate. The zero value generates arithmetic on
+// literals.
+type GenConfig struct {
+	MaxDepth  int            // operators and calls nested below the root; 0 means 4
+	Operators []string       // binary operators that may appear, as written; nil means + - * / ^
+	Unary     []string       // prefix operators, - and !; nil means -
+	Variables []string       // names of the variables that may appear; none when empty
+	Functions map[string]int // functions that may be called, with their argument counts
+	MaxNumber float64        // literals are from 0 to MaxNumber; 0 means 100
+	Decimals  int            // digits literals have after the point
+}
+
+// operator is a binary operator the parser knows.
+type operator struct {
+	typ  ep.TokenType
+	prec int // higher binds tighter
+}
+
+// operators are the binary operators Generate can write, with their
+// precedence in the grammar.
+var operators = map[string]operator{
+	"||": {ep.OR, 1},
+	"&&": {ep.AND, 2},
+	"|":  {ep.BITOR, 3},
+	"~":  {ep.BITXOR, 4},
+	"&":  {ep.BITAND, 5},
+	"==": {ep.EQ, 6},
+	"!=": {ep.NE, 6},
+	"<":  {ep.LT, 7},
+	"<=": {ep.LE, 7},
+	">":  {ep.GT, 7},
+	">=": {ep.GE, 7},
+	"<<": {ep.SHL, 9},
+	">>": {ep.SHR, 9},
+	"+":  {ep.PLUS, 10},
+	"-":  {ep.MINUS, 10},
+	"*":  {ep.MULT, 11},
+	"/":  {ep.DIV, 11},
+	"%":  {ep.MOD, 11},
+	"^":  {ep.POW, 13},
+}
+
+// Precedences of the other forms
+const (
+	unaryPrec = 12
+	atomPrec  = 14
+)
+
+// Generate returns a random expression, as source text and as the tree the
+// parser is to make of it, drawing from r. The text has the parentheses
+// precedence needs and sometimes more. Exponents are literals from 0 to 3,
+// so that, with the default depth and literals, no power or product
+// overflows; division and modulo by zero, and operands out of the domain
+// of an operator or function, may still occur. Generate panics on an
+// operator it does not know.
+func Generate(r *rand.Rand, cfg GenConfig) (string, ep.Expr) {
+	g := &generator{r: r, cfg: cfg}
+	if g.cfg.MaxDepth == 0 {
+		g.cfg.MaxDepth = 4
+	}
+	if g.cfg.Operators == nil {
+		g.cfg.Operators = []string{"+", "-", "*", "/", "^"}
+	}
+	if g.cfg.Unary == nil {
+		g.cfg.Unary = []string{"-"}
+	}
+	if g.cfg.MaxNumber == 0 {
+		g.cfg.MaxNumber = 100
+	}
+	for _, op := range g.cfg.Operators {
+		if _, ok := operators[op]; !ok {
+			panic(fmt.Sprintf("exprtest: unknown operator %q", op))
+		}
+	}
+	for _, op := range g.cfg.Unary {
+		if op != "-" && op != "!" {
+			panic(fmt.Sprintf("exprtest: unknown prefix operator %q", op))
+		}
+	}
+	g.functions = make([]string, 0, len(cfg.Functions))
+	for name := range cfg.Functions {
+		g.functions = append(g.functions, name)
+	}
+	// Map order is random: sort so that a seed gives the same expressions
+	sort.Strings(g.functions)
+
+	n := g.generate(g.cfg.MaxDepth)
+	return n.text, n.expr
+}
+
+// generator holds the state of one Generate call.
+type generator struct {
+	r         *rand.Rand
+	cfg       GenConfig
+	functions []string
+}
+
+// node is a generated expression with the precedence of its text.
+type node struct {
+	text string
+	prec int
+	expr ep.Expr
+}
+
+// generate returns an expression nesting at most depth operators and calls.
+func (g *generator) generate(depth int) node {
+	forms := len(g.cfg.Operators) + len(g.cfg.Unary) + len(g.functions)
+	if depth == 0 || forms == 0 || g.r.Intn(3) == 0 {
+		return g.leaf()
+	}
+
+	var n node
+	switch i := g.r.Intn(forms); {
+	case i < len(g.cfg.Operators):
+		n = g.binary(g.cfg.Operators[i], depth)
+	case i < len(g.cfg.Operators)+len(g.cfg.Unary):
+		n = g.unary(g.cfg.Unary[i-len(g.cfg.Operators)], depth)
+	default:
+		n = g.call(g.functions[i-len(g.cfg.Operators)-len(g.cfg.Unary)], depth)
+	}
+	if g.r.Intn(8) == 0 {
+		n = parenthesize(n)
+	}
+	return n
+}
+
+// leaf returns a literal or a variable.
+func (g *generator) leaf() node {
+	if len(g.cfg.Variables) > 0 && g.r.Intn(2) == 0 {
+		name := g.cfg.Variables[g.r.Intn(len(g.cfg.Variables))]
+		return node{text: name, prec: atomPrec, expr: ep.Var(name)}
+	}
+	scale := math.Pow(10, float64(g.cfg.Decimals))
+	return g.number(math.Round(g.r.Float64()*g.cfg.MaxNumber*scale) / scale)
+}
+
+// number returns the literal value.
+func (g *generator) number(value float64) node {
+	return node{text: strconv.FormatFloat(value, 'f', -1, 64), prec: atomPrec, expr: ep.Num(value)}
+}
+
+// binary returns an application of the binary operator. All but ^ are
+// left associative; the exponent of ^ is a small literal.
+func (g *generator) binary(text string, depth int) node {
+	op := operators[text]
+	left := g.generate(depth - 1)
+	var right node
+	if op.typ == ep.POW {
+		if left.prec <= op.prec {
+			left = parenthesize(left)
+		}
+		right = g.number(float64(g.r.Intn(4)))
+	} else {
+		right = g.generate(depth - 1)
+		if left.prec < op.prec {
+			left = parenthesize(left)
+		}
+		if right.prec <= op.prec {
+			right = parenthesize(right)
+		}
+	}
+	return node{
+		text: left.text + " " + text + " " + right.text,
+		prec: op.prec,
+		expr: &ep.BinaryOp{Left: left.expr, Op: ep.Token{Type: op.typ, Value: text}, Right: right.expr},
+	}
+}
+
+// unary returns an application of the prefix operator.
+func (g *generator) unary(text string, depth int) node {
+	operand := g.generate(depth - 1)
+	if operand.prec < unaryPrec {
+		operand = parenthesize(operand)
+	}
+	expr := ep.Expr(ep.Neg(operand.expr))
+	if text == "!" {
+		expr = ep.Not(operand.expr)
+	}
+	return node{text: text + operand.text, prec: unaryPrec, expr: expr}
+}
+
+// call returns a call of the function with as many arguments as it takes.
+func (g *generator) call(name string, depth int) node {
+	args := make([]ep.Expr, g.cfg.Functions[name])
+	text := name + "("
+	for i := range args {
+		arg := g.generate(depth - 1)
+		if i > 0 {
+			text += ", "
+		}
+		text += arg.text
+		args[i] = arg.expr
+	}
+	return node{text: text + ")", prec: atomPrec, expr: ep.Call(name, args...)}
+}
+
+// parenthesize wraps the text of n in parentheses, which leave the tree as
+// it is.
+func parenthesize(n node) node {
+	return node{text: "(" + n.text + ")", prec: atomPrec, expr: n.expr}
+}
+
+// end of file
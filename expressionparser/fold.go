@@ -0,0 +1,201 @@
+package expressionparser
+
+// Fold returns a copy of expr in which every operator applied only to
+// literals has been replaced by its value, computed with Eval. A
+// conditional, an if, a call to piecewise, or && and || whose deciding
+// operand folds to a literal is replaced by what Eval would evaluate of
+// it, the branches it skips dropped unevaluated, so that "0 && 1/0" folds
+// to 0. Evaluation errors of parts that are always evaluated, such as a
+// literal division by zero, are returned instead of being folded away; a
+// part that may be skipped at run time, such as the right operand of
+// "x > 0 && 1/0", is left unfolded when it fails, for Eval to report if it
+// is reached. Calls, ranges and operators applied to imaginary literals
+// are never folded.
+func Fold(expr Expr) (Expr, error) {
+	return defaultEvaluator.fold(expr)
+}
+
+// fold is Fold, computing values with ev.Eval.
+func (ev *Evaluator) fold(expr Expr) (Expr, error) {
+	return ev.foldNode(expr, true)
+}
+
+// foldNode folds expr. Errors are returned when certain is set, as expr is
+// then known to be evaluated whenever its enclosing tree is; otherwise a
+// node that fails to fold is kept.
+func (ev *Evaluator) foldNode(expr Expr, certain bool) (Expr, error) {
+	switch v := expr.(type) {
+	case *BinaryOp:
+		if v.Op.Type == AND || v.Op.Type == OR {
+			return ev.foldLogical(v, certain)
+		}
+	case *Conditional:
+		return ev.foldConditional(v, certain)
+	case *FunctionCall:
+		if ev.isLazyForm(v) {
+			return ev.foldPiecewise(v, certain)
+		}
+		if cond, err := ev.excelIf(v); err == nil && cond != nil {
+			return ev.foldConditional(cond, certain)
+		}
+	}
+
+	children := Children(expr)
+	var folded []Expr
+	for i, child := range children {
+		sure := certain
+		if i == 3 && ev.isIterationForm(expr.(*FunctionCall)) {
+			// An iteration form over an empty range never evaluates
+			// its last argument
+			sure = false
+		}
+		c, err := ev.foldNode(child, sure)
+		if err != nil {
+			return nil, err
+		}
+		if c != child && folded == nil {
+			folded = make([]Expr, len(children))
+			copy(folded, children)
+		}
+		if folded != nil {
+			folded[i] = c
+		}
+	}
+	node := expr
+	if folded != nil {
+		node = withChildren(expr, folded)
+	}
+	return ev.foldLiteral(node, certain)
+}
+
+// foldLiteral replaces node by its value when it is foldable.
+func (ev *Evaluator) foldLiteral(node Expr, certain bool) (Expr, error) {
+	if !isFoldable(node) {
+		return node, nil
+	}
+	value, err := ev.Eval(node)
+	if err != nil {
+		if certain {
+			return nil, err
+		}
+		return node, nil
+	}
+	return Num(value), nil
+}
+
+// foldLogical folds && or ||, whose right operand is evaluated only when
+// the left does not decide the result.
+func (ev *Evaluator) foldLogical(v *BinaryOp, certain bool) (Expr, error) {
+	left, err := ev.foldNode(v.Left, certain)
+	if err != nil {
+		return nil, err
+	}
+	holds, ok := literalCondition(left)
+	if ok && holds == (v.Op.Type == OR) {
+		// The right operand is never evaluated, and Eval does not reach it
+		return ev.foldLiteral(withChildren(v, []Expr{left, Num(0)}), certain)
+	}
+	right, err := ev.foldNode(v.Right, certain && ok)
+	if err != nil {
+		return nil, err
+	}
+	return ev.foldLiteral(withChildren(v, []Expr{left, right}), certain)
+}
+
+// foldConditional folds a conditional, of which only the branch its
+// condition chooses is evaluated.
+func (ev *Evaluator) foldConditional(v *Conditional, certain bool) (Expr, error) {
+	cond, err := ev.foldNode(v.Cond, certain)
+	if err != nil {
+		return nil, err
+	}
+	if holds, ok := literalCondition(cond); ok {
+		if holds {
+			return ev.foldNode(v.Then, certain)
+		}
+		return ev.foldNode(v.Else, certain)
+	}
+	then, err := ev.foldNode(v.Then, false)
+	if err != nil {
+		return nil, err
+	}
+	otherwise, err := ev.foldNode(v.Else, false)
+	if err != nil {
+		return nil, err
+	}
+	return withChildren(v, []Expr{cond, then, otherwise}), nil
+}
+
+// foldPiecewise folds a call to piecewise: pieces whose condition folds to
+// false are dropped, and the call becomes the value of the first piece
+// whose condition folds to true when all those before it are dropped. Only
+// the first condition left is certain to be evaluated.
+func (ev *Evaluator) foldPiecewise(call *FunctionCall, certain bool) (Expr, error) {
+	if err := lazyForms[call.Name].checkArgs(call.Name, len(call.Args)); err != nil {
+		// Evaluating the call reports it
+		return call, nil
+	}
+	var args []Expr
+	n := len(call.Args)
+	for i := 0; i < n; i += 2 {
+		first := len(args) == 0
+		if i+1 == n {
+			// The default
+			value, err := ev.foldNode(call.Args[i], certain && first)
+			if err != nil {
+				return nil, err
+			}
+			if first {
+				return value, nil
+			}
+			args = append(args, value)
+			break
+		}
+		cond, err := ev.foldNode(call.Args[i], certain && first)
+		if err != nil {
+			return nil, err
+		}
+		holds, ok := literalCondition(cond)
+		if ok && !holds {
+			continue
+		}
+		value, err := ev.foldNode(call.Args[i+1], certain && first && ok)
+		if err != nil {
+			return nil, err
+		}
+		if ok && first {
+			return value, nil
+		}
+		args = append(args, cond, value)
+		if ok {
+			// The pieces after one that always holds are never reached
+			break
+		}
+	}
+	if len(args) == 0 {
+		// Every condition is false: evaluating the call reports that
+		return call, nil
+	}
+	return &FunctionCall{Name: call.Name, Args: args, Span: call.Span}, nil
+}
+
+// isFoldable reports whether expr is an operator whose operands are all real literals.
+func isFoldable(expr Expr) bool {
+	switch v := expr.(type) {
+	case *BinaryOp:
+		if v.Op.Type == DOTDOT {
+			return false
+		}
+	case *UnaryOp:
+	default:
+		return false
+	}
+	for _, child := range Children(expr) {
+		if n, ok := child.(*Number); !ok || n.Imag {
+			return false
+		}
+	}
+	return true
+}
+
+// end of file
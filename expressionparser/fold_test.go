@@ -0,0 +1,117 @@
+package expressionparser
+
+import (
+	"testing"
+)
+
+func TestFoldSkipsUntakenOperands(t *testing.T) {
+	tests := []struct {
+		input string
+		vars  map[string]float64
+		want  string
+	}{
+		{"0 && 1/0", nil, "0"},
+		{"1 || 1/0", nil, "1"},
+		{"0 ? 1/0 : 2", nil, "2"},
+		{"1 ? 2 : 1/0", nil, "2"},
+		{"if 0 then 1/0 else 2", nil, "2"},
+		{"piecewise(0, 1/0, 2)", nil, "2"},
+		{"piecewise(1, 2, 1/0)", nil, "2"},
+		{"piecewise(0, 1/0, x > 1, 2, 3)", map[string]float64{"x": 0}, "piecewise(x > 1, 2, 3)"},
+		{"x > 0 && 1/0", map[string]float64{"x": 0}, "x > 0 && 1 / 0"},
+		{"x ? 1/0 : 2 + 3", map[string]float64{"x": 0}, "x ? 1 / 0 : 5"},
+		{"prod(i, 1, 0, 1/0)", nil, "prod(i, 1, 0, 1 / 0)"},
+	}
+	for _, tt := range tests {
+		expr, err := ParseString(tt.input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", tt.input, err)
+		}
+		folded, err := Fold(expr)
+		if err != nil {
+			t.Errorf("Fold(%q): unexpected error %v", tt.input, err)
+			continue
+		}
+		if got := Format(folded); got != tt.want {
+			t.Errorf("Fold(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+		want, err := EvalWithVars(expr, tt.vars)
+		if err != nil {
+			t.Errorf("EvalWithVars(%q): %v", tt.input, err)
+			continue
+		}
+		got, err := EvalWithVars(folded, tt.vars)
+		if err != nil || got != want {
+			t.Errorf("EvalWithVars(Fold(%q)) = %v, %v; want %v", tt.input, got, err, want)
+		}
+	}
+}
+
+func TestFoldReportsCertainErrors(t *testing.T) {
+	for _, input := range []string{
+		"1/0",
+		"x + 1/0",
+		"1 && 1/0",
+		"0 || 1/0",
+		"(1/0) ? 1 : 2",
+		"1 ? 1/0 : 2",
+		"piecewise(1/0, 1, 2)",
+		"piecewise(0, 1, 1/0)",
+	} {
+		expr, err := ParseString(input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		if _, err := Fold(expr); err == nil {
+			t.Errorf("Fold(%q): no error, want division by zero", input)
+		}
+	}
+}
+
+func TestCompileFoldsLazily(t *testing.T) {
+	for _, input := range []string{"0 && 1/0", "0 ? 1/0 : 2", "piecewise(0, 1/0, 2)", "x > 0 && 1/0"} {
+		e, err := Compile(input)
+		if err != nil {
+			t.Errorf("Compile(%q): unexpected error %v", input, err)
+			continue
+		}
+		if _, err := e.Eval(map[string]float64{"x": 0}); err != nil {
+			t.Errorf("Compile(%q).Eval: unexpected error %v", input, err)
+		}
+	}
+	if _, err := Compile("x + 1/0"); err == nil {
+		t.Error(`Compile("x + 1/0"): no error, want division by zero`)
+	}
+	e, err := Compile("x > 0 && 1/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Eval(map[string]float64{"x": 1}); err == nil {
+		t.Error(`Compile("x > 0 && 1/0").Eval with x = 1: no error, want division by zero`)
+	}
+}
+
+func TestFoldPreservesResults(t *testing.T) {
+	// A folded tree evaluates as the tree did, and a tree that certainly
+	// fails fails to fold with the error it would give
+	for _, input := range evalCorpus {
+		expr, err := ParseString(input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		want, wantErr := EvalWithVars(expr, corpusVars)
+		folded, err := Fold(expr)
+		if err != nil {
+			if wantErr == nil || ErrorCode(err) != ErrorCode(wantErr) {
+				t.Errorf("Fold(%q): %v, but it evaluates to %v, %v", input, err, want, wantErr)
+			}
+			continue
+		}
+		got, gotErr := EvalWithVars(folded, corpusVars)
+		if ErrorCode(gotErr) != ErrorCode(wantErr) || wantErr == nil && !sameResult(got, gotErr, want, wantErr) {
+			t.Errorf("%q folds to %s, which evaluates to %v, %v, want %v, %v", input, Format(folded), got, gotErr, want, wantErr)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,137 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parameters of FormatError's window onto a long line: its width and how
+// far into it the error starts, in characters
+const (
+	errorWindowWidth = 80
+	errorWindowLead  = 30
+)
+
+// FormatError renders err, an error from parsing or evaluating input, for
+// a person fixing the input: the message, then the line of input where the
+// error occurred and under it a caret at the offending token, followed by
+// tildes to the end of the subexpression when the error names one, as in
+//
+//	division by zero in "b / 0" at offset 4
+//	a + b / 0
+//	    ^~~~~
+//
+// The message of multi-line input is prefixed with the line number. Tabs
+// in the input are kept in the marker line so that the caret lines up,
+// every other character, however many bytes it takes, counting as one
+// column; lines longer than 80 characters are cut to a window around the
+// error, elided parts shown as "...". An error without an offset in input,
+// such as that of a tree built programmatically, is rendered as its
+// message alone.
+func FormatError(input string, err error) string {
+	if err == nil {
+		return ""
+	}
+	span, ok := errorSpan(err)
+	if !ok || span.Start < 0 || span.Start > len(input) {
+		return err.Error()
+	}
+
+	// Back to the start of a character an invalid token may begin inside
+	for span.Start > 0 && span.Start < len(input) && !utf8.RuneStart(input[span.Start]) {
+		span.Start--
+	}
+
+	// The line holding the start of the span, without its line break
+	start := strings.LastIndexByte(input[:span.Start], '\n') + 1
+	end := len(input)
+	if i := strings.IndexByte(input[span.Start:], '\n'); i >= 0 {
+		end = span.Start + i
+	}
+	end = start + len(strings.TrimSuffix(input[start:end], "\r"))
+	if span.End > end {
+		span.End = end
+	}
+
+	line := []rune(input[start:end])
+	col := utf8.RuneCountInString(input[start:min(span.Start, end)])
+	width := max(1, utf8.RuneCountInString(input[min(span.Start, end):max(span.Start, span.End)]))
+
+	// A window onto a long line, starting a little before the error
+	prefix, suffix := "", ""
+	if len(line) > errorWindowWidth {
+		from := max(0, min(col-errorWindowLead, len(line)-errorWindowWidth))
+		to := min(len(line), from+errorWindowWidth)
+		if from > 0 {
+			prefix = "..."
+		}
+		if to < len(line) {
+			suffix = "..."
+		}
+		line = line[from:to]
+		col -= from
+		width = max(1, min(width, len(line)-col))
+	}
+
+	var b strings.Builder
+	if strings.Contains(input, "\n") {
+		fmt.Fprintf(&b, "line %d: ", strings.Count(input[:start], "\n")+1)
+	}
+	b.WriteString(err.Error())
+	b.WriteString("\n")
+	b.WriteString(prefix + string(line) + suffix)
+	b.WriteString("\n")
+	b.WriteString(strings.Repeat(" ", len(prefix)))
+	for _, r := range line[:col] {
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	b.WriteString("^")
+	b.WriteString(strings.Repeat("~", width-1))
+	return b.String()
+}
+
+// errorSpan returns the part of the input an error occurred in: the
+// subexpression of an evaluation error, or, for a parse error, the token
+// where parsing stopped, its end unknown.
+func errorSpan(err error) (Span, bool) {
+	var (
+		parseErr     *ParseError
+		evalErr      *EvalError
+		undefinedErr *UndefinedVariableError
+		nonFiniteErr *NonFiniteError
+		overflowErr  *OverflowError
+		budgetErr    *BudgetExceededError
+	)
+	switch {
+	case errors.As(err, &parseErr):
+		return Span{Start: parseErr.Pos, End: parseErr.Pos}, true
+	case errors.As(err, &evalErr):
+		if span := SpanOf(evalErr.Expr); span != (Span{}) {
+			if evalErr.Pos > span.Start && evalErr.Pos < span.End {
+				// The error is at a part of the subexpression, as a verb
+				// of the format string of format
+				return Span{Start: evalErr.Pos, End: evalErr.Pos}, true
+			}
+			return span, true
+		}
+		return Span{Start: evalErr.Pos, End: evalErr.Pos}, evalErr.Pos >= 0
+	case errors.As(err, &undefinedErr):
+		return undefinedErr.Span, undefinedErr.Span != (Span{})
+	case errors.As(err, &nonFiniteErr):
+		span := SpanOf(nonFiniteErr.Expr)
+		return span, span != (Span{})
+	case errors.As(err, &overflowErr):
+		return Span{Start: overflowErr.Pos, End: overflowErr.Pos}, overflowErr.Pos >= 0
+	case errors.As(err, &budgetErr):
+		return Span{Start: budgetErr.Pos, End: budgetErr.Pos}, budgetErr.Pos >= 0
+	}
+	return Span{}, false
+}
+
+// end of file
@@ -0,0 +1,37 @@
+package expressionparser
+
+import "testing"
+
+// formatErrorVars are the variables of the testdata/formaterror inputs, tax
+// left undefined.
+var formatErrorVars = map[string]float64{"a": 1, "b": 2, "c": 3, "café": 1, "naïve": 2, "price": 10, "qty": 3}
+
+// TestFormatErrorGolden renders the error of parsing, or else evaluating,
+// each testdata/formaterror/*.expr file, and compares it with the .golden
+// file of the same name; run go test -run TestFormatErrorGolden -update to
+// rewrite them.
+func TestFormatErrorGolden(t *testing.T) {
+	runGolden(t, "formaterror", func(input string) string {
+		expr, err := ParseString(input)
+		if err == nil {
+			_, err = EvalWithVars(expr, formatErrorVars)
+		}
+		if err == nil {
+			t.Errorf("%q: no error", input)
+		}
+		return FormatError(input, err) + "\n"
+	})
+}
+
+func TestFormatErrorWithoutOffset(t *testing.T) {
+	// A tree built in code has no offsets in the input
+	_, err := Eval(Div(Num(1), Num(0)))
+	if got := FormatError("1 / 0", err); got != err.Error() {
+		t.Errorf("FormatError of an error without an offset = %q, want the message %q", got, err)
+	}
+	if got := FormatError("1", nil); got != "" {
+		t.Errorf("FormatError(nil) = %q, want nothing", got)
+	}
+}
+
+// end of file
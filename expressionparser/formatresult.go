@@ -0,0 +1,221 @@
+package expressionparser
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// NumberStyle selects how FormatResult renders a number.
+type NumberStyle int
+
+const (
+	StyleShortest    NumberStyle = iota // as Format prints literals: 0.5, 1e+21
+	StyleFixed                          // without an exponent: 1234.50
+	StyleScientific                     // one digit before the point: 1.2345e+03
+	StyleEngineering                    // an exponent that is a multiple of 3: 1.2345e+03, 12.5e-06
+	StyleFraction                       // a fraction when one is close enough: 1/3, -7/2
+	StyleSexagesimal                    // seconds as hours, minutes and seconds: 2:15:00, 0:00:03.25
+)
+
+// Names of the number styles
+var numberStyleNames = map[NumberStyle]string{
+	StyleShortest:    "shortest",
+	StyleFixed:       "fixed",
+	StyleScientific:  "scientific",
+	StyleEngineering: "engineering",
+	StyleFraction:    "fraction",
+	StyleSexagesimal: "sexagesimal",
+}
+
+// String returns the name of the style.
+func (s NumberStyle) String() string {
+	if name, ok := numberStyleNames[s]; ok {
+		return name
+	}
+	return "NumberStyle(" + strconv.Itoa(int(s)) + ")"
+}
+
+// FormatOptions configures FormatResult. The zero value renders as Format
+// does.
+type FormatOptions struct {
+	Style NumberStyle
+
+	// Precision is the number of digits after the point in StyleFixed,
+	// StyleScientific and StyleEngineering, and of the seconds in
+	// StyleSexagesimal; -1 means as few as identify the number.
+	Precision int
+
+	// Grouping separates the thousands of the integer part with commas in
+	// StyleShortest and StyleFixed, as in 1,234,567.5.
+	Grouping bool
+
+	// MaxDenominator bounds the denominators of StyleFraction; 0 means
+	// 10000.
+	MaxDenominator int64
+}
+
+// FormatResult renders a result of evaluation for people to read. NaN is
+// rendered "NaN" and the infinities "+Inf" and "-Inf" in every style, and
+// negative zero, and negative numbers rounded to zero, without a sign.
+// StyleFraction renders the fraction with the smallest denominator within
+// MaxDenominator that the continued fraction of v gives and that equals v
+// to 14 significant digits, and v in StyleShortest when there is none.
+// StyleSexagesimal renders v as a number of seconds, such as those of a
+// duration, in the notation WithSexagesimal reads.
+func FormatResult(v float64, opts FormatOptions) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case v == 0:
+		v = 0 // and not -0
+	}
+
+	var s string
+	switch opts.Style {
+	case StyleFixed:
+		s = strconv.FormatFloat(v, 'f', opts.Precision, 64)
+	case StyleScientific:
+		s = strconv.FormatFloat(v, 'e', opts.Precision, 64)
+	case StyleEngineering:
+		s = engineering(v, opts.Precision)
+	case StyleFraction:
+		if f, ok := fraction(v, opts.MaxDenominator); ok {
+			return f
+		}
+		s = formatNumber(v)
+	case StyleSexagesimal:
+		s = sexagesimal(v, opts.Precision)
+	default:
+		s = formatNumber(v)
+	}
+	if strings.HasPrefix(s, "-") && strings.Trim(s, "-0.e+:") == "" {
+		// Rounded to zero: -0.00 is 0.00
+		s = s[1:]
+	}
+	if opts.Grouping && (opts.Style == StyleShortest || opts.Style == StyleFixed) {
+		s = groupThousands(s)
+	}
+	return s
+}
+
+// engineering renders v with an exponent that is a multiple of 3 and
+// precision digits after the point, or as few as identify v when precision
+// is negative.
+func engineering(v float64, precision int) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	digits, exp := decimalDigits(strconv.FormatFloat(v, 'e', -1, 64))
+	if precision >= 0 {
+		// Round to the digits shown, which can carry into the next exponent
+		shift := mod3(exp)
+		digits, exp = decimalDigits(strconv.FormatFloat(v, 'e', precision+shift, 64))
+		if s := mod3(exp); s != shift {
+			digits, exp = decimalDigits(strconv.FormatFloat(v, 'e', precision+s, 64))
+		}
+	}
+	shift := mod3(exp)
+	for len(digits) < shift+1 {
+		digits += "0"
+	}
+	mantissa := digits[:shift+1]
+	if fraction := digits[shift+1:]; fraction != "" {
+		mantissa += "." + fraction
+	}
+	e := exp - shift
+	exponent := "e+"
+	if e < 0 {
+		exponent, e = "e-", -e
+	}
+	if e < 10 {
+		exponent += "0"
+	}
+	return sign + mantissa + exponent + strconv.Itoa(e)
+}
+
+// decimalDigits splits the 'e' form of a positive number into its digits,
+// without the point, and its exponent.
+func decimalDigits(s string) (string, int) {
+	mantissa, exponent, _ := strings.Cut(s, "e")
+	exp, _ := strconv.Atoi(exponent)
+	return strings.Replace(mantissa, ".", "", 1), exp
+}
+
+// mod3 returns exp modulo 3, from 0 to 2 for negative exponents too.
+func mod3(exp int) int {
+	return ((exp % 3) + 3) % 3
+}
+
+// fraction renders v as a fraction with a denominator of at most max, or
+// 10000 when max is 0, reporting whether one equals v to 14 significant
+// digits.
+func fraction(v float64, max int64) (string, bool) {
+	if max <= 0 {
+		max = 10000
+	}
+	if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+		return strconv.FormatFloat(v, 'f', 0, 64), true
+	}
+	sign := ""
+	x := v
+	if x < 0 {
+		sign, x = "-", -x
+	}
+	// The convergents h/k of the continued fraction of x
+	h0, h1 := int64(0), int64(1)
+	k0, k1 := int64(1), int64(0)
+	rest := x
+	for i := 0; i < 64; i++ {
+		a := math.Floor(rest)
+		if a > 1<<53 {
+			break
+		}
+		h := int64(a)*h1 + h0
+		k := int64(a)*k1 + k0
+		if k > max {
+			break
+		}
+		h0, h1, k0, k1 = h1, h, k1, k
+		if math.Abs(float64(h)/float64(k)-x) <= 1e-14*x {
+			return sign + strconv.FormatInt(h, 10) + "/" + strconv.FormatInt(k, 10), true
+		}
+		if rest-a == 0 {
+			break
+		}
+		rest = 1 / (rest - a)
+	}
+	return "", false
+}
+
+// groupThousands puts commas between the thousands of the integer part of
+// a number rendered without an exponent.
+func groupThousands(s string) string {
+	if strings.ContainsAny(s, "eE") {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	integer, fraction, point := strings.Cut(s, ".")
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, d := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	if point {
+		b.WriteString("." + fraction)
+	}
+	return b.String()
+}
+
+// end of file
@@ -0,0 +1,47 @@
+package expressionparser
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormatResult(t *testing.T) {
+	values := []float64{0, math.Copysign(0, -1), 1234567.5, -0.000125, 1.0 / 3, -3.5, 0.1, 8100.25, math.NaN(), math.Inf(1), math.Inf(-1)}
+	tests := []struct {
+		opts FormatOptions
+		want []string
+	}{
+		{FormatOptions{}, []string{"0", "0", "1.2345675e+06", "-0.000125", "0.3333333333333333", "-3.5", "0.1", "8100.25", "NaN", "+Inf", "-Inf"}},
+		{FormatOptions{Grouping: true}, []string{"0", "0", "1.2345675e+06", "-0.000125", "0.3333333333333333", "-3.5", "0.1", "8,100.25", "NaN", "+Inf", "-Inf"}},
+		// Negative numbers rounded to zero lose their sign
+		{FormatOptions{Style: StyleFixed, Precision: 2}, []string{"0.00", "0.00", "1234567.50", "0.00", "0.33", "-3.50", "0.10", "8100.25", "NaN", "+Inf", "-Inf"}},
+		{FormatOptions{Style: StyleFixed, Precision: -1, Grouping: true}, []string{"0", "0", "1,234,567.5", "-0.000125", "0.3333333333333333", "-3.5", "0.1", "8,100.25", "NaN", "+Inf", "-Inf"}},
+		{FormatOptions{Style: StyleScientific, Precision: 3}, []string{"0.000e+00", "0.000e+00", "1.235e+06", "-1.250e-04", "3.333e-01", "-3.500e+00", "1.000e-01", "8.100e+03", "NaN", "+Inf", "-Inf"}},
+		{FormatOptions{Style: StyleScientific, Precision: -1}, []string{"0e+00", "0e+00", "1.2345675e+06", "-1.25e-04", "3.333333333333333e-01", "-3.5e+00", "1e-01", "8.10025e+03", "NaN", "+Inf", "-Inf"}},
+		{FormatOptions{Style: StyleEngineering, Precision: 2}, []string{"0.00e+00", "0.00e+00", "1.23e+06", "-125.00e-06", "333.33e-03", "-3.50e+00", "100.00e-03", "8.10e+03", "NaN", "+Inf", "-Inf"}},
+		{FormatOptions{Style: StyleEngineering, Precision: -1}, []string{"0e+00", "0e+00", "1.2345675e+06", "-125e-06", "333.3333333333333e-03", "-3.5e+00", "100e-03", "8.10025e+03", "NaN", "+Inf", "-Inf"}},
+		{FormatOptions{Style: StyleFraction}, []string{"0", "0", "2469135/2", "-1/8000", "1/3", "-7/2", "1/10", "32401/4", "NaN", "+Inf", "-Inf"}},
+		// With no fraction close enough the number is in StyleShortest
+		{FormatOptions{Style: StyleFraction, MaxDenominator: 10}, []string{"0", "0", "2469135/2", "-0.000125", "1/3", "-7/2", "1/10", "32401/4", "NaN", "+Inf", "-Inf"}},
+		{FormatOptions{Style: StyleSexagesimal, Precision: -1}, []string{"0:00:00", "0:00:00", "342:56:07.5", "-0:00:00.000125", "0:00:00.3333333333333333", "-0:00:03.5", "0:00:00.1", "2:15:00.25", "NaN", "+Inf", "-Inf"}},
+	}
+	for _, tt := range tests {
+		for i, v := range values {
+			if got := FormatResult(v, tt.opts); got != tt.want[i] {
+				t.Errorf("FormatResult(%v, %s) = %q, want %q", v, tt.opts.Style, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestFormatResultFractionPi(t *testing.T) {
+	// No convergent of pi within 10000 matches it to 14 digits
+	if got := FormatResult(math.Pi, FormatOptions{Style: StyleFraction}); got != "3.141592653589793" {
+		t.Errorf("FormatResult(pi, fraction) = %q, want 3.141592653589793", got)
+	}
+	if got := NumberStyle(99).String(); got != "NumberStyle(99)" {
+		t.Errorf("NumberStyle(99).String() = %q", got)
+	}
+}
+
+// end of file
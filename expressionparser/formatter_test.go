@@ -0,0 +1,48 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// formatters are the option combinations the formatter golden files pin,
+// by name.
+var formatters = []struct {
+	name string
+	f    Formatter
+}{
+	{"default", Formatter{}},
+	{"compact", Formatter{Compact: true}},
+	{"parens", Formatter{AlwaysParens: true}},
+	{"compact+parens", Formatter{Compact: true, AlwaysParens: true}},
+	{"e3", Formatter{FloatFormat: 'e', Precision: 3}},
+	{"f-1", Formatter{FloatFormat: 'f', Precision: -1}},
+	{"compact+parens+g4", Formatter{Compact: true, AlwaysParens: true, FloatFormat: 'g', Precision: 4}},
+}
+
+// TestFormatterGolden renders each testdata/format/*.expr file with every
+// combination of options, one line apiece. Every rendering at full precision
+// must parse back to the tree.
+func TestFormatterGolden(t *testing.T) {
+	runGolden(t, "format", func(input string) string {
+		expr, err := ParseString(input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		var sb strings.Builder
+		for _, fm := range formatters {
+			out := fm.f.Format(expr)
+			fmt.Fprintf(&sb, "%s: %s\n", fm.name, out)
+			if fm.f.FloatFormat != 0 && fm.f.Precision >= 0 {
+				continue
+			}
+			if back, err := ParseString(out); err != nil || !Equal(back, expr) {
+				t.Errorf("%s rendering %q of %q does not parse back: %v", fm.name, out, input, err)
+			}
+		}
+		return sb.String()
+	})
+}
+
+// end of file
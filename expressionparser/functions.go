@@ -0,0 +1,367 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+)
+
+// builtin is a function callable from expressions.
+type builtin struct {
+	arity    int // number of arguments, or the minimum number when variadic
+	optional int // number of further arguments that may follow
+	variadic bool
+	fn       func(args []float64) (float64, error)
+	domain   func(args []float64) int                            // index of an argument outside the domain, or -1
+	impure   bool                                                // result may differ between calls with the same arguments
+	degrees  func(args []float64) (float64, error)               // replaces fn WithAngleUnit(Degrees)
+	random   func(r *rand.Rand, args []float64) (float64, error) // replaces fn, drawing from the evaluator's source
+}
+
+// Functions known to Eval, by name
+var builtins = map[string]builtin{
+	"sqrt":  withDomain(unary(math.Sqrt), below(0)),
+	"abs":   unary(math.Abs),
+	"sin":   inDegrees(unary(math.Sin), degreesArg(math.Sin, 30, sinMultiples)),
+	"cos":   inDegrees(unary(math.Cos), degreesArg(math.Cos, 30, cosMultiples)),
+	"tan":   inDegrees(unary(math.Tan), degreesArg(math.Tan, 45, tanMultiples)),
+	"asin":  inDegrees(withDomain(unary(math.Asin), outsideUnit), degreesResult(math.Asin, asinExact)),
+	"acos":  inDegrees(withDomain(unary(math.Acos), outsideUnit), degreesResult(math.Acos, acosExact)),
+	"atan":  inDegrees(unary(math.Atan), degreesResult(math.Atan, atanExact)),
+	"atan2": inDegrees(binaryFn(math.Atan2), atan2Degrees),
+	"rad": unary(func(x float64) float64 {
+		return x * radiansPerDegree
+	}),
+	"deg": unary(func(x float64) float64 {
+		return x * degreesPerRadian
+	}),
+	"exp":     unary(math.Exp),
+	"ln":      withDomain(unary(math.Log), notPositive),
+	"log":     {arity: 1, optional: 1, fn: logBase, domain: logDomain},
+	"log10":   withDomain(unary(math.Log10), notPositive),
+	"log2":    withDomain(unary(math.Log2), notPositive),
+	"pow":     withDomain(binaryFn(math.Pow), negativeBaseFraction),
+	"floor":   withDigits("floor", math.Floor, floorQuo),
+	"ceil":    withDigits("ceil", math.Ceil, ceilQuo),
+	"round":   withDigits("round", math.Round, halfAwayQuo),
+	"trunc":   withDigits("trunc", math.Trunc, truncQuo),
+	"sign":    unary(sign),
+	"min":     variadic(math.Min),
+	"max":     variadic(math.Max),
+	"clamp":   {arity: 3, fn: clamp},
+	"fact":    {arity: 1, fn: fact, domain: notNatural},
+	"gamma":   {arity: 1, fn: gamma, domain: gammaPole},
+	"nCr":     {arity: 2, fn: nCr, domain: notNatural},
+	"nPr":     {arity: 2, fn: nPr, domain: notNatural},
+	"gcd":     {arity: 2, variadic: true, fn: gcd, domain: notExactNatural},
+	"lcm":     {arity: 2, variadic: true, fn: lcm, domain: notExactNatural},
+	"rand":    randomBuiltin(0, uniform),
+	"randint": randomBuiltin(2, randint),
+	"normal":  withDomain(randomBuiltin(2, normal), negativeDeviation),
+}
+
+// unary adapts a one-argument math function to a builtin.
+func unary(fn func(float64) float64) builtin {
+	return builtin{arity: 1, fn: func(args []float64) (float64, error) {
+		return fn(args[0]), nil
+	}}
+}
+
+// binaryFn adapts a two-argument math function to a builtin.
+func binaryFn(fn func(float64, float64) float64) builtin {
+	return builtin{arity: 2, fn: func(args []float64) (float64, error) {
+		return fn(args[0], args[1]), nil
+	}}
+}
+
+// variadic folds a two-argument function over two or more arguments.
+func variadic(fn func(float64, float64) float64) builtin {
+	return builtin{arity: 2, variadic: true, fn: func(args []float64) (float64, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = fn(result, arg)
+		}
+		return result, nil
+	}}
+}
+
+// withDigits adapts a function rounding to an integer to a builtin taking
+// an optional number of decimal places, which may be negative to round to
+// tens, hundreds and so on: round(2.675, 2) is 2.68 and floor(1234, -2) is
+// 1200. With places the rounding works, as WithRoundTo does, on the
+// shortest decimal form of the number, and quo gives the rounded quotient
+// of that form scaled to the places.
+func withDigits(name string, fn func(float64) float64, quo func(n, den *big.Int) *big.Int) builtin {
+	return builtin{arity: 1, optional: 1, fn: func(args []float64) (float64, error) {
+		if len(args) == 1 {
+			return fn(args[0]), nil
+		}
+		digits := args[1]
+		if digits != math.Trunc(digits) {
+			return 0, withCode(CodeOutsideDomain, fmt.Errorf("%s: number of places %s is not an integer", name, formatNumber(digits)))
+		}
+		// Beyond 400 places either way every float64 rounds as it would at 400
+		digits = math.Max(-400, math.Min(digits, 400))
+		return roundDecimalBy(args[0], int(digits), quo), nil
+	}}
+}
+
+// floorQuo returns n/den rounded toward negative infinity; den is positive.
+func floorQuo(n, den *big.Int) *big.Int {
+	return new(big.Int).Div(n, den)
+}
+
+// ceilQuo returns n/den rounded toward positive infinity; den is positive.
+func ceilQuo(n, den *big.Int) *big.Int {
+	q := floorQuo(new(big.Int).Neg(n), den)
+	return q.Neg(q)
+}
+
+// halfAwayQuo returns n/den rounded to the nearest integer, ties away from
+// zero as math.Round and spreadsheets round them.
+func halfAwayQuo(n, den *big.Int) *big.Int {
+	return roundQuo(n, den, RoundHalfUp)
+}
+
+// truncQuo returns n/den rounded toward zero.
+func truncQuo(n, den *big.Int) *big.Int {
+	return new(big.Int).Quo(n, den)
+}
+
+// withDomain attaches a domain check to a builtin.
+func withDomain(b builtin, domain func(args []float64) int) builtin {
+	b.domain = domain
+	return b
+}
+
+// below returns a domain check rejecting a single argument less than limit.
+func below(limit float64) func(args []float64) int {
+	return func(args []float64) int {
+		if args[0] < limit {
+			return 0
+		}
+		return -1
+	}
+}
+
+// notPositive rejects a single argument of zero or less, where logarithms are undefined.
+func notPositive(args []float64) int {
+	if args[0] <= 0 {
+		return 0
+	}
+	return -1
+}
+
+// logBase returns the natural logarithm of a single argument, or its
+// logarithm to the base given second, exact for the powers of 10 and 2
+// with those bases.
+func logBase(args []float64) (float64, error) {
+	if len(args) == 1 {
+		return math.Log(args[0]), nil
+	}
+	switch args[1] {
+	case 10:
+		return math.Log10(args[0]), nil
+	case 2:
+		return math.Log2(args[0]), nil
+	}
+	return math.Log(args[0]) / math.Log(args[1]), nil
+}
+
+// logDomain rejects an argument of zero or less and a base of zero or less
+// or of 1.
+func logDomain(args []float64) int {
+	if args[0] <= 0 {
+		return 0
+	}
+	if len(args) > 1 && (args[1] <= 0 || args[1] == 1) {
+		return 1
+	}
+	return -1
+}
+
+// outsideUnit rejects a single argument outside [-1, 1].
+func outsideUnit(args []float64) int {
+	if args[0] < -1 || args[0] > 1 {
+		return 0
+	}
+	return -1
+}
+
+// negativeBaseFraction rejects a negative base raised to a non-integer exponent.
+func negativeBaseFraction(args []float64) int {
+	if args[0] < 0 && args[1] != math.Trunc(args[1]) && !math.IsInf(args[1], 0) {
+		return 0
+	}
+	return -1
+}
+
+// sign returns -1, 0 or 1 according to the sign of x, and NaN for NaN.
+func sign(x float64) float64 {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return x
+}
+
+// clamp limits args[0] to the range from args[1] to args[2]. Like min and
+// max, which follow math.Min and math.Max, it propagates NaN: a NaN
+// argument gives NaN rather than being ignored.
+func clamp(args []float64) (float64, error) {
+	x, lo, hi := args[0], args[1], args[2]
+	if lo > hi {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("clamp: lower bound %s is greater than upper bound %s", formatNumber(lo), formatNumber(hi)))
+	}
+	return math.Max(lo, math.Min(x, hi)), nil
+}
+
+// checkArgs returns an error when n arguments do not suit the builtin.
+func (b builtin) checkArgs(name string, n int) error {
+	if b.optional > 0 {
+		if n < b.arity || n > b.arity+b.optional {
+			return withCode(CodeArgumentCount, fmt.Errorf("%s expects %d to %d arguments, got %d", name, b.arity, b.arity+b.optional, n))
+		}
+		return nil
+	}
+	if b.variadic {
+		if n < b.arity {
+			return withCode(CodeArgumentCount, fmt.Errorf("%s expects at least %d argument(s), got %d", name, b.arity, n))
+		}
+		return nil
+	}
+	if n != b.arity {
+		return withCode(CodeArgumentCount, fmt.Errorf("%s expects %d argument(s), got %d", name, b.arity, n))
+	}
+	return nil
+}
+
+// callBuiltin evaluates the arguments of a call and applies the named
+// function, looking first among those registered on the evaluator, then
+// among the string builtins, the aggregates and the numeric ones. An argument
+// outside a builtin's domain is an error naming the function and the value,
+// or gives NaN in lenient mode.
+func (ev *Evaluator) callBuiltin(call *FunctionCall, env *scope) (Value, error) {
+	if cond, err := ev.excelIf(call); cond != nil || err != nil {
+		if err != nil {
+			return Value{}, err
+		}
+		return ev.evalNode(cond, env)
+	}
+	if ev.isSpecialForm(call) {
+		return ev.callSpecialForm(call, env)
+	}
+	if ev.isIterationForm(call) {
+		return ev.callIteration(call, env)
+	}
+	if ev.isLazyForm(call) {
+		return ev.callLazyForm(call, env)
+	}
+	target, err := ev.resolveCall(call)
+	if err != nil {
+		return Value{}, err
+	}
+
+	values := make([]Value, len(call.Args))
+	for i, arg := range call.Args {
+		value, err := ev.eval(arg, env)
+		if err != nil {
+			return Value{}, err
+		}
+		values[i] = value
+	}
+	if ev.stats != nil {
+		ev.stats.stats.Calls[call.Name]++
+	}
+	return ev.applyCall(call, target, values)
+}
+
+// domainError reports args[i] outside the domain of the named builtin,
+// giving its position among several arguments. The base of log, which may
+// be outside it by being 1, says what a base must be instead.
+func domainError(name string, args []float64, i int) error {
+	if name == "log" && i == 1 {
+		return withCode(CodeOutsideDomain, fmt.Errorf("log: base must be positive and not 1, got %s", formatNumber(args[i])))
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("%s: argument %d, %s, is %w", name, i+1, formatNumber(args[i]), ErrOutsideDomain)
+	}
+	return fmt.Errorf("%s: argument %s is %w", name, formatNumber(args[i]), ErrOutsideDomain)
+}
+
+// callTarget is the function a call resolves to.
+type callTarget struct {
+	b           builtin
+	sb          stringBuiltin
+	agg         aggregate
+	isString    bool
+	isAggregate bool
+	registered  bool
+}
+
+// resolveCall looks up the function of a call and checks its number of arguments.
+func (ev *Evaluator) resolveCall(call *FunctionCall) (callTarget, error) {
+	if t, ok := ev.excelTarget(call.Name); ok {
+		return t, t.b.checkArgs(call.Name, len(call.Args))
+	}
+	var t callTarget
+	t.b, t.registered = ev.funcs[call.Name]
+	t.sb, t.isString = stringBuiltins[call.Name]
+	t.agg, t.isAggregate = aggregates[call.Name]
+	if t.registered {
+		t.isString, t.isAggregate = false, false
+	} else if t.isString {
+		t.b = t.sb.sig
+	} else if t.isAggregate {
+		t.b = t.agg.sig
+	} else {
+		var ok bool
+		if t.b, ok = builtins[call.Name]; !ok {
+			return t, fmt.Errorf("%w %s", ErrUnknownFunction, call.Name)
+		}
+	}
+	return t, t.b.checkArgs(call.Name, len(call.Args))
+}
+
+// applyCall applies the function a call resolved to to the values of its arguments.
+func (ev *Evaluator) applyCall(call *FunctionCall, t callTarget, values []Value) (Value, error) {
+	if t.isString {
+		return ev.callStringBuiltin(call, t.sb, values)
+	}
+	if t.isAggregate {
+		return applyAggregate(call, t.agg, values)
+	}
+	args, err := numberArgs(call, values)
+	if err != nil {
+		return Value{}, err
+	}
+
+	if t.b.domain != nil {
+		if i := t.b.domain(args); i >= 0 {
+			if ev.cfg.lenientNaN {
+				return NumberValue(math.NaN()), nil
+			}
+			return Value{}, domainError(call.Name, args, i)
+		}
+	}
+
+	fn := t.b.fn
+	switch {
+	case ev.cfg.angleUnit == Degrees && t.b.degrees != nil:
+		fn = t.b.degrees
+	case t.b.random != nil && ev.random != nil:
+		fn = func(args []float64) (float64, error) {
+			return ev.random.draw(t.b.random, args)
+		}
+	}
+	result, err := fn(args)
+	if err != nil && t.registered {
+		return Value{}, fmt.Errorf("%s: %w", call.Name, err)
+	}
+	return NumberValue(result), err
+}
+
+// end of file
@@ -0,0 +1,289 @@
+package expressionparser
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"log(8, 2)", 3},
+		{"log(1000, 10)", 3},
+		{"log(1e-5, 10)", -5},
+		{"log(1024, 2)", 10},
+		{"log(81, 3)", 4},
+		{"log(exp(1))", 1},
+		{"log10(1000)", 3},
+		{"log2(0.125)", -3},
+		{"log(0.25, 0.5)", 2},
+	}
+	for _, tt := range tests {
+		got, err := Evaluate(tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.input, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+	// Bases 10 and 2 are exact
+	for _, input := range []string{"log(1000, 10)", "log(8, 2)"} {
+		if got, _ := Evaluate(input); got != 3 {
+			t.Errorf("%s = %v, want exactly 3", input, got)
+		}
+	}
+}
+
+func TestLogDomain(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"log(0)", "log: argument 0 is outside the domain"},
+		{"log(-1, 10)", "log: argument 1, -1, is outside the domain"},
+		{"log(8, 0)", "log: base must be positive and not 1, got 0"},
+		{"log(8, -2)", "log: base must be positive and not 1, got -2"},
+		{"log(8, 1)", "log: base must be positive and not 1, got 1"},
+	}
+	for _, tt := range tests {
+		_, err := Evaluate(tt.input)
+		if err == nil {
+			t.Errorf("%s: no error, want %q", tt.input, tt.msg)
+			continue
+		}
+		if ErrorCode(err) != CodeOutsideDomain {
+			t.Errorf("%s: code %s, want %s", tt.input, ErrorCode(err), CodeOutsideDomain)
+		}
+		if !strings.HasPrefix(err.Error(), tt.msg+" in ") {
+			t.Errorf("%s: error %q, want %q", tt.input, err, tt.msg)
+		}
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	inf := math.Inf(1)
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"sqrt(16)", 4},
+		{"sqrt(0)", 0},
+		{"sqrt(2) ^ 2", 2.0000000000000004},
+		{"abs(-3.5)", 3.5},
+		{"abs(0)", 0},
+		{"sin(0)", 0},
+		{"sin(asin(1))", 1},
+		{"cos(0)", 1},
+		{"cos(acos(-1))", -1},
+		{"tan(0)", 0},
+		{"tan(atan(1))", 1},
+		{"asin(1) * 2", math.Pi},
+		{"asin(-1)", -math.Pi / 2},
+		{"acos(1)", 0},
+		{"acos(-1)", math.Pi},
+		{"atan(1) * 4", math.Pi},
+		{"atan2(1, 1) * 4", math.Pi},
+		{"atan2(0, -1)", math.Pi},
+		{"atan2(-1, 0)", -math.Pi / 2},
+		{"exp(0)", 1},
+		{"exp(1)", math.E},
+		{"exp(710)", inf},
+		{"exp(-1000)", 0},
+		{"ln(1)", 0},
+		{"ln(exp(2))", 2},
+		{"log10(0.001)", -3},
+		{"log2(1024)", 10},
+		{"pow(2, 10)", 1024},
+		{"pow(-8, 3)", -512},
+		{"pow(4, 0.5)", 2},
+		{"pow(0, 0)", 1},
+		{"pow(0, -1)", inf},
+		{"floor(2.7)", 2},
+		{"floor(-2.2)", -3},
+		{"ceil(2.2)", 3},
+		{"ceil(-2.7)", -2},
+		{"round(2.5)", 3},
+		{"round(-2.5)", -3},
+		{"round(2.4999)", 2},
+		{"trunc(2.7)", 2},
+		{"trunc(-2.7)", -2},
+		{"sign(-3)", -1},
+		{"sign(0)", 0},
+		{"sign(7.5)", 1},
+		{"min(3, -1, 2)", -1},
+		{"min(1, 2)", 1},
+		{"max(3, -1, 2)", 3},
+		{"max(-5, -10)", -5},
+	}
+	for _, tt := range tests {
+		got, err := Evaluate(tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.input, err)
+			continue
+		}
+		if got != tt.want && math.Abs(got-tt.want) > 1e-15*math.Abs(tt.want) {
+			t.Errorf("%s = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{"sqrt(-1)", CodeOutsideDomain, "sqrt: argument -1 is outside the domain"},
+		{"sqrt(-1e-300)", CodeOutsideDomain, "sqrt: argument -1e-300 is outside the domain"},
+		{"ln(0)", CodeOutsideDomain, "ln: argument 0 is outside the domain"},
+		{"ln(-2)", CodeOutsideDomain, "ln: argument -2 is outside the domain"},
+		{"log10(0)", CodeOutsideDomain, "log10: argument 0 is outside the domain"},
+		{"log2(-1)", CodeOutsideDomain, "log2: argument -1 is outside the domain"},
+		{"asin(1.5)", CodeOutsideDomain, "asin: argument 1.5 is outside the domain"},
+		{"acos(-2)", CodeOutsideDomain, "acos: argument -2 is outside the domain"},
+		{"pow(-8, 1 / 3)", CodeOutsideDomain, "pow: argument 1, -8, is outside the domain"},
+		{"sqrt()", CodeArgumentCount, "sqrt expects 1 argument(s), got 0"},
+		{"abs(1, 2)", CodeArgumentCount, "abs expects 1 argument(s), got 2"},
+		{"atan2(1)", CodeArgumentCount, "atan2 expects 2 argument(s), got 1"},
+		{"pow(2)", CodeArgumentCount, "pow expects 2 argument(s), got 1"},
+		{"min()", CodeArgumentCount, "min expects at least 2 argument(s), got 0"},
+		{"max()", CodeArgumentCount, "max expects at least 2 argument(s), got 0"},
+	}
+	for _, tt := range tests {
+		_, err := Evaluate(tt.input)
+		if err == nil {
+			t.Errorf("%s: no error, want %q", tt.input, tt.msg)
+			continue
+		}
+		if ErrorCode(err) != tt.code {
+			t.Errorf("%s: code %s, want %s", tt.input, ErrorCode(err), tt.code)
+		}
+		if want := tt.msg + ` in "` + tt.input + `" at offset 0`; err.Error() != want {
+			t.Errorf("%s: error %q, want %q", tt.input, err, want)
+		}
+	}
+}
+
+func TestBuiltinsLenientNaN(t *testing.T) {
+	ev := NewEvaluator(WithLenientNaN())
+	for _, input := range []string{"sqrt(-1)", "ln(-1)", "asin(2)", "acos(-2)", "pow(-8, 1 / 3)"} {
+		got, err := ev.Eval(mustParse(t, input))
+		if err != nil || !math.IsNaN(got) {
+			t.Errorf("%s in lenient mode = %v, %v; want NaN", input, got, err)
+		}
+	}
+	if _, err := ev.Eval(mustParse(t, "sqrt(1, 2)")); ErrorCode(err) != CodeArgumentCount {
+		t.Errorf("sqrt(1, 2) in lenient mode: error %v, want %s", err, CodeArgumentCount)
+	}
+}
+
+func TestMinMaxClamp(t *testing.T) {
+	nan := math.NaN()
+	vars := map[string]float64{"a": 3, "b": 7, "c": 2, "nan": nan}
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"clamp(min(a, b), 0, max(c, 10))", 3},
+		{"clamp(max(a, b, c) * 2, min(a, c), max(c, 10))", 10},
+		{"clamp(15, 0, 10)", 10},
+		{"clamp(-1, 0, 10)", 0},
+		{"clamp(5, 5, 5)", 5},
+		{"max(1, 2, 3, 4, 5, -1)", 5},
+		{"min(abs(-a), -abs(b))", -7},
+		// NaN propagates rather than being ignored
+		{"min(1, nan)", nan},
+		{"max(nan, 1)", nan},
+		{"abs(nan)", nan},
+		{"clamp(nan, 0, 1)", nan},
+		{"clamp(1, nan, 2)", nan},
+	}
+	for _, tt := range tests {
+		got, err := EvalWithVars(mustParse(t, tt.input), vars)
+		if err != nil || got != tt.want && !(math.IsNaN(got) && math.IsNaN(tt.want)) {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	for input, msg := range map[string]string{
+		"min(1)":          "min expects at least 2 argument(s), got 1",
+		"max(5)":          "max expects at least 2 argument(s), got 1",
+		"clamp(1, 2)":     "clamp expects 3 argument(s), got 2",
+		"clamp(5, 10, 0)": "clamp: lower bound 10 is greater than upper bound 0",
+	} {
+		if _, err := Evaluate(input); err == nil || err.Error() != msg+` in "`+input+`" at offset 0` {
+			t.Errorf("%s: error %v, want %q", input, err, msg)
+		}
+	}
+
+	// Check knows the signatures without evaluating
+	problems := Check(mustParse(t, "min(1) + clamp(1, 2) + max(1, 2, 3) + abs(1, 2)"), CheckOptions{})
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	want := []string{
+		"min expects at least 2 argument(s), got 1 at offset 0",
+		"clamp expects 3 argument(s), got 2 at offset 9",
+		"abs expects 1 argument(s), got 2 at offset 38",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Check found %q, want %q", got, want)
+	}
+}
+
+func TestRoundingDigits(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		// 2.675 is a little below 2.675 in float64, and still rounds up
+		{"round(2.675, 2)", 2.68},
+		{"round(-2.675, 2)", -2.68},
+		{"round(1.005, 2)", 1.01},
+		{"round(x, 2)", 10.01},
+		{"floor(2.675, 2)", 2.67},
+		{"floor(-2.675, 2)", -2.68},
+		{"ceil(2.675, 2)", 2.68},
+		{"ceil(-2.675, 2)", -2.67},
+		{"trunc(2.675, 2)", 2.67},
+		{"trunc(-2.675, 2)", -2.67},
+		// Half away from zero, as in spreadsheets
+		{"round(2.5)", 3},
+		{"round(-2.5)", -3},
+		{"round(-1250, -2)", -1300},
+		{"round(1234.5, -2)", 1200},
+		{"floor(1234, -2)", 1200},
+		{"floor(-1234, -2)", -1300},
+		{"ceil(-1234, -2)", -1200},
+		{"trunc(-1299, -2)", -1200},
+		{"floor(-2.5)", -3},
+		{"ceil(-2.5)", -2},
+		{"trunc(-2.5)", -2},
+		// Exact at the place already
+		{"round(0.1 + 0.2, 1)", 0.3},
+		{"ceil(1.1, 1)", 1.1},
+		{"floor(0.3, 1)", 0.3},
+		{"round(1e300, 2)", 1e300},
+		{"round(5, 400)", 5},
+	}
+	for _, tt := range tests {
+		got, err := EvalWithVars(mustParse(t, tt.input), map[string]float64{"x": 10.005})
+		if err != nil || got != tt.want {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+	for _, name := range []string{"round", "floor", "ceil", "trunc"} {
+		input := name + "(2, 1.5)"
+		if _, err := Evaluate(input); err == nil || err.Error() != name+`: number of places 1.5 is not an integer in "`+input+`" at offset 0` {
+			t.Errorf("%s: error %v", input, err)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,101 @@
+package expressionparser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// addGoldenSeeds adds the inputs of the golden files to the seed corpus of f.
+func addGoldenSeeds(f *testing.F) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "golden", "*.expr"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range inputs {
+		input, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(strings.TrimSuffix(string(input), "\n"))
+	}
+}
+
+// withoutRecovery makes panics crash the fuzz target where they happen,
+// rather than being recovered into a PanicError, until the test ends.
+func withoutRecovery(f *testing.F) {
+	recoverPanics = false
+	f.Cleanup(func() { recoverPanics = true })
+}
+
+func FuzzParse(f *testing.F) {
+	addGoldenSeeds(f)
+	withoutRecovery(f)
+	f.Fuzz(func(t *testing.T, input string) {
+		expr, err := ParseString(input)
+		if (expr == nil) == (err == nil) {
+			t.Fatalf("ParseString(%q) = %v, %v; want a tree or an error", input, expr, err)
+		}
+		var pe *ParseError
+		if err != nil && !errors.As(err, &pe) {
+			t.Fatalf("ParseString(%q): error %T %v, want a *ParseError", input, err, err)
+		}
+	})
+}
+
+func FuzzEval(f *testing.F) {
+	addGoldenSeeds(f)
+	withoutRecovery(f)
+	ev := NewEvaluator(WithMaxOps(100000))
+	ev.SetVars(map[string]float64{"x": 2, "y": -3, "n": 5})
+	f.Fuzz(func(t *testing.T, input string) {
+		expr, err := ParseString(input)
+		if err != nil {
+			return
+		}
+		// Any value or error will do, but a panic fails
+		ev.EvalValue(expr)
+	})
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	addGoldenSeeds(f)
+	withoutRecovery(f)
+	f.Fuzz(func(t *testing.T, input string) {
+		expr, err := ParseString(input)
+		if err != nil {
+			return
+		}
+		printed := Format(expr)
+		reparsed, err := ParseString(printed)
+		if err != nil {
+			t.Fatalf("%q prints as %q, which does not parse: %v", input, printed, err)
+		}
+		if !Equal(expr, reparsed) {
+			t.Fatalf("%q prints as %q, which parses as %s rather than %s", input, printed, ToSExpr(reparsed), ToSExpr(expr))
+		}
+	})
+}
+
+// FuzzArena parses each input into an arena, released after each, and
+// checks the tree against the one parsed without it.
+func FuzzArena(f *testing.F) {
+	addGoldenSeeds(f)
+	withoutRecovery(f)
+	arena := NewArena()
+	f.Fuzz(func(t *testing.T, input string) {
+		want, wantErr := ParseString(input)
+		got, err := ParseString(input, WithArena(arena))
+		defer arena.Release()
+		if (err == nil) != (wantErr == nil) || err != nil && err.Error() != wantErr.Error() {
+			t.Fatalf("ParseString(%q) into an arena: error %v, want %v", input, err, wantErr)
+		}
+		if err == nil && (!Equal(got, want) || ToSExpr(got) != ToSExpr(want)) {
+			t.Fatalf("ParseString(%q) into an arena = %s, want %s", input, ToSExpr(got), ToSExpr(want))
+		}
+	})
+}
+
+// end of file
@@ -0,0 +1,277 @@
+package expressionparser
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Go spellings of the builtins, by name
+var goBuiltins = map[string]string{
+	"sqrt":  "math.Sqrt",
+	"abs":   "math.Abs",
+	"sin":   "math.Sin",
+	"cos":   "math.Cos",
+	"tan":   "math.Tan",
+	"asin":  "math.Asin",
+	"acos":  "math.Acos",
+	"atan":  "math.Atan",
+	"atan2": "math.Atan2",
+	"exp":   "math.Exp",
+	"ln":    "math.Log",
+	"log":   "math.Log",
+	"log10": "math.Log10",
+	"log2":  "math.Log2",
+	"pow":   "math.Pow",
+	"floor": "math.Floor",
+	"ceil":  "math.Ceil",
+	"round": "math.Round",
+	"trunc": "math.Trunc",
+}
+
+// ToGo generates a Go function equivalent to evaluating expr:
+//
+//	func name(vars map[string]float64) (float64, error)
+//
+// The body computes one temporary per node in Eval's order, looks variables
+// up in vars and returns the same errors as Eval for undefined variables and
+// division or modulo by zero; builtins are called without domain checks, so an
+// argument outside a function's domain yields NaN. Comparisons and logical operators yield 1 or 0 as in
+// Eval, and a conditional computes only the branch it selects. The code refers to the errors and math packages, which
+// the surrounding file must import. It is formatted with go/format.
+func ToGo(expr Expr, funcName string) (string, error) {
+	if !token.IsIdentifier(funcName) {
+		return "", withCode(CodeInvalidArgument, fmt.Errorf("invalid Go function name %q", funcName))
+	}
+
+	g := &goGenerator{}
+	result, err := g.emit(expr, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "func %s(vars map[string]float64) (float64, error) {\n", funcName)
+	sb.WriteString(g.body.String())
+	fmt.Fprintf(&sb, "return %s, nil\n}\n", result)
+
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return "", fmt.Errorf("generated code does not format: %w", err)
+	}
+	return string(src), nil
+}
+
+// goGenerator accumulates the statements of a generated function.
+type goGenerator struct {
+	body strings.Builder
+	temp int
+}
+
+// goScope maps let-bound names to the temporaries holding their values.
+type goScope struct {
+	name   string
+	temp   string
+	parent *goScope
+}
+
+// newTemp declares a temporary initialized to value and returns its name.
+func (g *goGenerator) newTemp(value string) string {
+	name := "t" + strconv.Itoa(g.temp)
+	g.temp++
+	fmt.Fprintf(&g.body, "%s := %s\n", name, value)
+	return name
+}
+
+// emit writes the statements computing expr and returns the temporary holding it.
+func (g *goGenerator) emit(expr Expr, scope *goScope) (string, error) {
+	switch v := expr.(type) {
+	case *Number:
+		if v.Imag {
+			return "", withCode(CodeUnsupported, fmt.Errorf("cannot generate Go for imaginary literal %s", literalText(v)))
+		}
+		return g.newTemp(goFloat(v.Value)), nil
+	case *StringLiteral:
+		return "", withCode(CodeUnsupported, fmt.Errorf("cannot generate Go for string literal %s", strconv.Quote(v.Value)))
+	case *ListLiteral:
+		return "", withCode(CodeUnsupported, fmt.Errorf("cannot generate Go for list literal %s", Format(v)))
+	case *QuantityLiteral:
+		return "", withCode(CodeUnsupported, fmt.Errorf("cannot generate Go for quantity %s", Format(v)))
+	case *Assign:
+		return "", withCode(CodeUnsupported, fmt.Errorf("cannot generate Go for assignment %s", Format(v)))
+	case *Variable:
+		for s := scope; s != nil; s = s.parent {
+			if s.name == v.Name {
+				return s.temp, nil
+			}
+		}
+		name := "t" + strconv.Itoa(g.temp)
+		g.temp++
+		fmt.Fprintf(&g.body, "%s, ok := vars[%s]\n", name, strconv.Quote(v.Name))
+		fmt.Fprintf(&g.body, "if !ok {\nreturn 0, errors.New(%s)\n}\n", strconv.Quote((&UndefinedVariableError{Name: v.Name, Span: v.Span}).Error()))
+		return name, nil
+	case *UnaryOp:
+		operand, err := g.emit(v.Operand, scope)
+		if err != nil {
+			return "", err
+		}
+		switch v.Op.Type {
+		case MINUS:
+			return g.newTemp("-" + operand), nil
+		case NOT:
+			return g.truth("!(" + goTrue(operand) + ")"), nil
+		}
+		return "", withCode(CodeUnsupported, fmt.Errorf("cannot generate Go for unary operator %s", operatorSymbol(v.Op)))
+	case *BinaryOp:
+		left, err := g.emit(v.Left, scope)
+		if err != nil {
+			return "", err
+		}
+		if v.Op.Type == AND || v.Op.Type == OR {
+			return g.emitLogical(v, left, scope)
+		}
+		right, err := g.emit(v.Right, scope)
+		if err != nil {
+			return "", err
+		}
+		switch v.Op.Type {
+		case PLUS, MINUS, MULT:
+			return g.newTemp(left + " " + operatorSymbol(v.Op) + " " + right), nil
+		case DIV:
+			fmt.Fprintf(&g.body, "if %s == 0 {\nreturn 0, errors.New(\"division by zero\")\n}\n", right)
+			return g.newTemp(left + " / " + right), nil
+		case MOD:
+			fmt.Fprintf(&g.body, "if %s == 0 {\nreturn 0, errors.New(\"modulo by zero\")\n}\n", right)
+			return g.newTemp("math.Mod(" + left + ", " + right + ")"), nil
+		case POW:
+			return g.newTemp("math.Pow(" + left + ", " + right + ")"), nil
+		case LT, LE, GT, GE, EQ, NE:
+			return g.truth(left + " " + operatorSymbol(v.Op) + " " + right), nil
+		}
+		return "", withCode(CodeUnsupported, fmt.Errorf("cannot generate Go for operator %s", operatorSymbol(v.Op)))
+	case *FunctionCall:
+		fn, ok := goBuiltins[v.Name]
+		if _, isForm := specialForms[v.Name]; isForm || v.Name == "prod" || v.Name == "piecewise" {
+			return "", withCode(CodeUnsupported, fmt.Errorf("cannot generate Go for %s", v.Name))
+		}
+		if !ok {
+			return "", fmt.Errorf("%w %s", ErrUnknownFunction, v.Name)
+		}
+		if err := builtins[v.Name].checkArgs(v.Name, len(v.Args)); err != nil {
+			return "", err
+		}
+		if len(v.Args) > builtins[v.Name].arity && v.Name != "log" {
+			return "", withCode(CodeUnsupported, fmt.Errorf("cannot generate Go for %s with %d arguments", v.Name, len(v.Args)))
+		}
+		if len(v.Args) == 2 && v.Name == "log" {
+			if base, ok := v.Args[1].(*Number); ok && (base.Value == 10 || base.Value == 2) && !base.Imag {
+				// The base is a literal, so only the argument needs a temporary
+				arg, err := g.emit(v.Args[0], scope)
+				if err != nil {
+					return "", err
+				}
+				return g.newTemp("math.Log" + formatNumber(base.Value) + "(" + arg + ")"), nil
+			}
+		}
+		args := make([]string, len(v.Args))
+		for i, arg := range v.Args {
+			a, err := g.emit(arg, scope)
+			if err != nil {
+				return "", err
+			}
+			args[i] = a
+		}
+		if len(args) == 2 && v.Name == "log" {
+			return g.newTemp("math.Log(" + args[0] + ") / math.Log(" + args[1] + ")"), nil
+		}
+		return g.newTemp(fn + "(" + strings.Join(args, ", ") + ")"), nil
+	case *Let:
+		value, err := g.emit(v.Value, scope)
+		if err != nil {
+			return "", err
+		}
+		return g.emit(v.Body, &goScope{name: v.Name, temp: value, parent: scope})
+	case *Conditional:
+		cond, err := g.emit(v.Cond, scope)
+		if err != nil {
+			return "", err
+		}
+		result := g.declareTemp()
+		fmt.Fprintf(&g.body, "if %s {\n", goTrue(cond))
+		if err := g.emitBranch(v.Then, scope, result); err != nil {
+			return "", err
+		}
+		g.body.WriteString("} else {\n")
+		if err := g.emitBranch(v.Else, scope, result); err != nil {
+			return "", err
+		}
+		g.body.WriteString("}\n")
+		return result, nil
+	}
+	return "", withCode(CodeUnsupported, fmt.Errorf("cannot generate Go for expression of type %T", expr))
+}
+
+// declareTemp declares a zero temporary to be assigned later and returns its name.
+func (g *goGenerator) declareTemp() string {
+	name := "t" + strconv.Itoa(g.temp)
+	g.temp++
+	fmt.Fprintf(&g.body, "var %s float64\n", name)
+	return name
+}
+
+// truth declares a temporary holding 1 when the Go condition holds and 0 otherwise.
+func (g *goGenerator) truth(condition string) string {
+	name := g.declareTemp()
+	fmt.Fprintf(&g.body, "if %s {\n%s = 1\n}\n", condition, name)
+	return name
+}
+
+// emitLogical writes && or || given the temporary holding the left operand,
+// computing the right operand only when the left does not decide the result.
+func (g *goGenerator) emitLogical(v *BinaryOp, left string, scope *goScope) (string, error) {
+	result := g.declareTemp()
+	if v.Op.Type == AND {
+		fmt.Fprintf(&g.body, "if %s {\n", goTrue(left))
+	} else {
+		fmt.Fprintf(&g.body, "if %s {\n%s = 1\n} else {\n", goTrue(left), result)
+	}
+	right, err := g.emit(v.Right, scope)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&g.body, "if %s {\n%s = 1\n}\n}\n", goTrue(right), result)
+	return result, nil
+}
+
+// emitBranch writes the statements of one conditional branch, assigning its value to result.
+func (g *goGenerator) emitBranch(expr Expr, scope *goScope, result string) error {
+	value, err := g.emit(expr, scope)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&g.body, "%s = %s\n", result, value)
+	return nil
+}
+
+// goTrue renders the Go condition that a temporary counts as true, matching isTrue.
+func goTrue(temp string) string {
+	return "(" + temp + " != 0 && !math.IsNaN(" + temp + "))"
+}
+
+// goFloat renders a float64 constant as a typed Go expression.
+func goFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "math.NaN()"
+	case math.IsInf(v, 1):
+		return "math.Inf(1)"
+	case math.IsInf(v, -1):
+		return "math.Inf(-1)"
+	}
+	return "float64(" + formatNumber(v) + ")"
+}
+
+// end of file
@@ -0,0 +1,140 @@
+package expressionparser
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// goBindings are the variable bindings the generated functions are run
+// with, each against Eval.
+var goBindings = []map[string]float64{
+	corpusVars,
+	{"x": -1.5, "y": 4, "n": 3, "zero": 0},
+	{"x": 0, "y": 0, "n": 0},
+}
+
+// goCorpus returns the expressions of the evaluation corpus that ToGo
+// generates code for, with those functions named f0, f1, ...
+func goCorpus(t *testing.T) (inputs []string, exprs []Expr, funcs []string) {
+	for _, input := range append([]string{
+		"let a = x * 2 in let b = a + y in a * b",
+		"x > 0 ? (y > 0 ? 1 : 2) : 3",
+		"n % 4 - -x",
+		"log(100, 10) + log(8, 2)",
+	}, evalCorpus...) {
+		expr, err := ParseString(input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		src, err := ToGo(expr, "f"+strconv.Itoa(len(funcs)))
+		if err != nil {
+			continue
+		}
+		inputs = append(inputs, input)
+		exprs = append(exprs, expr)
+		funcs = append(funcs, src)
+	}
+	if len(funcs) < 30 {
+		t.Fatalf("ToGo generated only %d of the corpus", len(funcs))
+	}
+	return inputs, exprs, funcs
+}
+
+// goFile returns a Go source file of package pkg holding the functions.
+func goFile(pkg string, funcs []string, extra string) string {
+	return "package " + pkg + "\n\nimport (\n\t\"errors\"\n\t\"math\"\n)\n\nvar _ = errors.New\nvar _ = math.Pi\n\n" +
+		strings.Join(funcs, "\n") + extra
+}
+
+func TestToGoTypeChecks(t *testing.T) {
+	inputs, _, funcs := goCorpus(t)
+	for i, src := range funcs {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "gen.go", goFile("gen", []string{src}, ""), 0)
+		if err != nil {
+			t.Fatalf("ToGo(%q) does not parse: %v\n%s", inputs[i], err, src)
+		}
+		conf := types.Config{Importer: importer.Default()}
+		if _, err := conf.Check("gen", fset, []*ast.File{file}, nil); err != nil {
+			t.Errorf("ToGo(%q) does not type-check: %v\n%s", inputs[i], err, src)
+		}
+	}
+}
+
+func TestToGoMatchesEval(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a program")
+	}
+	gocmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go command")
+	}
+	inputs, exprs, funcs := goCorpus(t)
+
+	// The program prints each function's result for each binding, a line
+	// apiece
+	var main strings.Builder
+	main.WriteString("\nfunc main() {\n\tbindings := []map[string]float64{\n")
+	for _, vars := range goBindings {
+		fmt.Fprintf(&main, "\t\t%#v,\n", vars)
+	}
+	main.WriteString("\t}\n\tfuncs := []func(map[string]float64) (float64, error){")
+	for i := range funcs {
+		fmt.Fprintf(&main, "f%d, ", i)
+	}
+	main.WriteString("}\n\tfor _, vars := range bindings {\n\t\tfor _, f := range funcs {\n" +
+		"\t\t\tif v, err := f(vars); err != nil {\n\t\t\t\tprintln(\"error\")\n" +
+		"\t\t\t} else {\n\t\t\t\tprintln(math.Float64bits(v))\n\t\t\t}\n\t\t}\n\t}\n}\n")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(goFile("main", funcs, main.String())), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module gen\n\ngo 1.21\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(gocmd, "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s", err, out)
+	}
+
+	lines := bufio.NewScanner(strings.NewReader(string(out)))
+	for _, vars := range goBindings {
+		for i, expr := range exprs {
+			if !lines.Scan() {
+				t.Fatal("the program printed too few results")
+			}
+			want, wantErr := EvalWithVars(expr, vars)
+			got := lines.Text()
+			switch {
+			case wantErr != nil && ErrorCode(wantErr) == CodeOutsideDomain:
+				// The generated code does not check domains
+			case wantErr != nil:
+				if got != "error" {
+					t.Errorf("%q with %v: generated code gives %s, Eval %v", inputs[i], vars, got, wantErr)
+				}
+			default:
+				bits, err := strconv.ParseUint(got, 10, 64)
+				if err != nil || !sameResult(math.Float64frombits(bits), nil, want, nil) {
+					t.Errorf("%q with %v: generated code gives %s, Eval %v", inputs[i], vars, got, want)
+				}
+			}
+		}
+	}
+}
+
+// end of file
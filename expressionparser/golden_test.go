@@ -0,0 +1,69 @@
+package expressionparser
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files of the golden tests from the current output")
+
+// goldenDump renders the parse of input canonically: the S-expression of
+// the tree, or the error of input that does not parse.
+func goldenDump(input string) string {
+	expr, err := ParseString(input)
+	if err != nil {
+		return "error: " + err.Error() + "\n"
+	}
+	return ToSExpr(expr) + "\n"
+}
+
+// TestGolden parses each testdata/golden/*.expr file, its final line break
+// aside, and compares the dump with the .golden file of the same name. Run
+// go test -run TestGolden -update to rewrite the .golden files after a
+// deliberate change, and review the diff.
+func TestGolden(t *testing.T) {
+	runGolden(t, "golden", goldenDump)
+}
+
+// runGolden compares dump of each testdata/dir/*.expr file, its final line
+// break aside, with the .golden file of the same name, or with -update
+// rewrites the .golden files.
+func runGolden(t *testing.T, dir string, dump func(input string) string) {
+	t.Helper()
+	inputs, err := filepath.Glob(filepath.Join("testdata", dir, "*.expr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatalf("no testdata/%s/*.expr files", dir)
+	}
+	for _, path := range inputs {
+		name := strings.TrimSuffix(filepath.Base(path), ".expr")
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := dump(strings.TrimSuffix(string(input), "\n"))
+			golden := strings.TrimSuffix(path, ".expr") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v; run go test -run %s -update to create it", err, t.Name())
+			}
+			if got != string(want) {
+				t.Errorf("%s:\ngot  %s\nwant %s", path, got, want)
+			}
+		})
+	}
+}
+
+// end of file
@@ -0,0 +1,290 @@
+// Package govaluate is a shim with the entry points of
+// github.com/Knetic/govaluate, NewEvaluableExpression and Evaluate, over
+// the expressionparser package, so that code written against govaluate
+// can move to it by changing an import path.
+//
+// The syntax is that of expressionparser, with these of govaluate's
+// translated onto it:
+//
+//   - strings in single quotes, as in name == 'alice';
+//   - a ?? b, which is b when the parameter a is missing or nil and a
+//     otherwise;
+//   - ** for powers, and ^ for exclusive or, which expressionparser
+//     writes ^ and ~.
+//
+// The differences that remain:
+//
+//   - The left operand of ?? must be a parameter name, and ?? binds more
+//     loosely than anything but ?: and the comma, so that a ?? b + 1 is
+//     a ?? (b + 1); a ?? b ? c : d must be parenthesized, as
+//     (a ?? b) ? c : d.
+//   - Parameter names in brackets, such as [response-time], the unary ~
+//     of bitwise not, =~ and !~ on regular expressions, IN, dates in
+//     strings, accessors of struct parameters and functions given to
+//     NewEvaluableExpressionWithFunctions are not supported.
+//   - The functions are the builtins of expressionparser, and the names
+//     true and false are not keywords.
+//   - Parameters must be numbers, bools or strings; numbers of every Go
+//     type are taken as float64. A nil parameter counts as missing.
+//   - Errors are those of expressionparser, positioned in the expression
+//     as translated, rather than govaluate's.
+//   - A ternary without an else, a ? b, is not supported; govaluate gives
+//     nil for it when a is false.
+package govaluate
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	ep "github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser"
+)
+
+// setPrefix begins the names of the variables that say whether the left
+// operand of a ?? is set.
+const setPrefix = "__isset_"
+
+// EvaluableExpression is an expression parsed by NewEvaluableExpression.
+// It is safe for concurrent use.
+type EvaluableExpression struct {
+	input     string
+	expr      ep.Expr
+	coalesced []string // the left operands of ??
+	ev        *ep.Evaluator
+}
+
+// NewEvaluableExpression translates expression from the syntax of
+// govaluate, as the package describes, and parses it.
+func NewEvaluableExpression(expression string) (*EvaluableExpression, error) {
+	translated, coalesced, err := translate(expression)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := ep.ParseString(translated)
+	if err != nil {
+		return nil, err
+	}
+	return &EvaluableExpression{input: expression, expr: expr, coalesced: coalesced, ev: ep.NewEvaluator()}, nil
+}
+
+// Evaluate evaluates the expression with parameters as its variables,
+// giving a float64, a bool, a string or, for a list, a []float64.
+func (e *EvaluableExpression) Evaluate(parameters map[string]interface{}) (interface{}, error) {
+	env := ep.NewEnvironment(nil)
+	for name, param := range parameters {
+		if param == nil {
+			continue
+		}
+		value, err := parameterValue(name, param)
+		if err != nil {
+			return nil, err
+		}
+		env.Set(name, value)
+	}
+	for _, name := range e.coalesced {
+		env.Set(setPrefix+name, ep.BoolValue(parameters[name] != nil))
+	}
+
+	value, err := e.ev.EvalIn(e.expr, env)
+	if err != nil {
+		return nil, err
+	}
+	switch value.Kind() {
+	case ep.BoolKind:
+		return value.AsBool()
+	case ep.StringKind:
+		return value.AsString()
+	case ep.ListKind:
+		return value.AsList()
+	}
+	return value.AsFloat()
+}
+
+// Vars returns the names of the parameters the expression uses.
+func (e *EvaluableExpression) Vars() []string {
+	var names []string
+	for _, name := range ep.Variables(e.expr) {
+		if !strings.HasPrefix(name, setPrefix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// String returns the expression as it was given to NewEvaluableExpression.
+func (e *EvaluableExpression) String() string {
+	return e.input
+}
+
+// parameterValue converts a parameter to a value.
+func parameterValue(name string, param interface{}) (ep.Value, error) {
+	switch p := param.(type) {
+	case bool:
+		return ep.BoolValue(p), nil
+	case string:
+		return ep.StringValue(p), nil
+	}
+	v := reflect.ValueOf(param)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ep.NumberValue(float64(v.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return ep.NumberValue(float64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return ep.NumberValue(v.Float()), nil
+	}
+	return ep.Value{}, fmt.Errorf("parameter %s is a %T, not a number, bool or string", name, param)
+}
+
+// translate rewrites an expression from the syntax of govaluate to that of
+// expressionparser, returning the left operands of its ?? operators.
+func translate(input string) (string, []string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(input); i++ {
+		switch c := input[i]; {
+		case c == '\'' || c == '"':
+			// A string, which may hold the quote after a backslash
+			var text strings.Builder
+			j := i + 1
+			for ; j < len(input) && input[j] != c; j++ {
+				if input[j] == '\\' && j+1 < len(input) {
+					j++
+				}
+				text.WriteByte(input[j])
+			}
+			if j == len(input) {
+				return "", nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			sb.WriteString(strconv.Quote(text.String()))
+			i = j
+		case c == '*' && i+1 < len(input) && input[i+1] == '*':
+			sb.WriteByte('^')
+			i++
+		case c == '^':
+			sb.WriteByte('~')
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	seen := map[string]bool{}
+	out, err := coalesce(sb.String(), seen)
+	if err != nil {
+		return "", nil, err
+	}
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return out, names, nil
+}
+
+// coalesce rewrites each a ?? b in s, whose strings are in double quotes,
+// as a conditional on whether a is set, adding the names a to seen. The
+// right operand of ?? runs to the next ':' or ',' outside brackets, or to
+// the end.
+func coalesce(s string, seen map[string]bool) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			end := skipString(s, i)
+			out.WriteString(s[i:end])
+			i = end - 1
+		case c == '(' || c == '[':
+			end := closing(s, i)
+			if end < 0 {
+				out.WriteString(s[i:])
+				return out.String(), nil // for the parser to report
+			}
+			inner, err := coalesce(s[i+1:end], seen)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(string(c) + inner + string(s[end]))
+			i = end
+		case c == '?' && i+1 < len(s) && s[i+1] == '?':
+			left := strings.TrimRight(out.String(), " \t\n")
+			start := len(left)
+			for start > 0 && isNameByte(left[start-1]) {
+				start--
+			}
+			name := left[start:]
+			if name == "" || name[0] >= '0' && name[0] <= '9' {
+				return "", fmt.Errorf("the left operand of ?? at offset %d must be a parameter name", i)
+			}
+			end := i + 2
+			for end < len(s) && s[end] != ':' && s[end] != ',' {
+				switch s[end] {
+				case '"':
+					end = skipString(s, end)
+					continue
+				case '(', '[':
+					if j := closing(s, end); j >= 0 {
+						end = j
+					}
+				}
+				end++
+			}
+			right, err := coalesce(s[i+2:end], seen)
+			if err != nil {
+				return "", err
+			}
+			if strings.TrimSpace(right) == "" {
+				return "", fmt.Errorf("?? at offset %d has no right operand", i)
+			}
+			seen[name] = true
+			rewritten := left[:start] + "(" + setPrefix + name + " ? " + name + " : (" + right + "))"
+			out.Reset()
+			out.WriteString(rewritten)
+			i = end - 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String(), nil
+}
+
+// skipString returns the offset just past the string in double quotes at
+// s[i].
+func skipString(s string, i int) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+// closing returns the offset of the bracket closing the one at s[i], or -1
+// when there is none.
+func closing(s string, i int) int {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '"':
+			j = skipString(s, j) - 1
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// isNameByte reports whether b may be part of a parameter name.
+func isNameByte(b byte) bool {
+	return b == '_' || b == '.' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+// end of file
@@ -0,0 +1,151 @@
+package govaluate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	ep "github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser"
+)
+
+// parameters are those of the evaluations of the tests.
+var parameters = map[string]interface{}{
+	"foo":                -1,
+	"bar":                2.5,
+	"name":               "alice",
+	"requests_made":      100,
+	"requests_succeeded": 92,
+	"http_response_body": "service is ok",
+	"mem":                uint16(512),
+	"total_mem":          int64(1024),
+	"enabled":            true,
+	"ratio":              float32(0.5),
+	"unset":              nil,
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+		vars  string
+	}{
+		// As in the README of govaluate
+		{"10 > 0", true, ""},
+		{"foo > 0", false, "foo"},
+		{"(requests_made * requests_succeeded / 100) >= 90", true, "requests_made requests_succeeded"},
+		{"http_response_body == 'service is ok'", true, "http_response_body"},
+		{"(mem / total_mem) * 100", 50.0, "mem total_mem"},
+		// Strings in either quotes, with escapes
+		{"name == 'alice' && enabled", true, "name enabled"},
+		{`'it\'s' + " ok"`, "it's ok", ""},
+		{`"q" == 'q'`, true, ""},
+		// ** is a power and ^ exclusive or
+		{"2 ** 10", 1024.0, ""},
+		{"6 ^ 3", 5.0, ""},
+		{"ratio * 4 ** 0.5", 1.0, "ratio"},
+		// Ternaries
+		{"enabled ? 'on' : 'off'", "on", "enabled"},
+		{"foo > 0 ? 'positive' : 'negative'", "negative", "foo"},
+		// ?? on a missing or nil parameter
+		{"missing ?? 5", 5.0, "missing"},
+		{"unset ?? 'default'", "default", "unset"},
+		{"bar ?? 5", 2.5, "bar"},
+		{"missing ?? bar + 1", 3.5, "missing bar"}, // missing ?? (bar + 1)
+		{"(missing ?? 0) > 1 ? 'big' : 'small'", "small", "missing"},
+		{"max(missing ?? 3, foo ?? 9)", 3.0, "missing foo"},
+		{"[1, 2, 3]", []float64{1, 2, 3}, ""},
+	}
+	for _, tt := range tests {
+		e, err := NewEvaluableExpression(tt.input)
+		if err != nil {
+			t.Errorf("NewEvaluableExpression(%s): %v", tt.input, err)
+			continue
+		}
+		got, err := e.Evaluate(parameters)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %#v, %v; want %#v", tt.input, got, err, tt.want)
+		}
+		if vars := strings.Join(e.Vars(), " "); vars != tt.vars {
+			t.Errorf("%s: Vars() = %q, want %q", tt.input, vars, tt.vars)
+		}
+		if e.String() != tt.input {
+			t.Errorf("String() = %q, want %q", e.String(), tt.input)
+		}
+	}
+}
+
+func TestNewEvaluableExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"1 ?? 2", "the left operand of ?? at offset 2 must be a parameter name"},
+		{"missing ??", "?? at offset 8 has no right operand"},
+		{"'open", "unterminated string at offset 0"},
+		// No ternary without an else, as govaluate has
+		{"foo ? 1", "expected ':' in conditional expression but found end of input at offset 7"},
+		{"2 +", "expected number, name, string, '(', '[', or unary '-' or '!' but found end of input at offset 3"},
+	}
+	for _, tt := range tests {
+		e, err := NewEvaluableExpression(tt.input)
+		if err == nil || err.Error() != tt.msg {
+			t.Errorf("NewEvaluableExpression(%s) = %v, %v; want error %q", tt.input, e, err, tt.msg)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	e, err := NewEvaluableExpression("x + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Evaluate(nil); !errors.Is(err, ep.ErrUndefinedVariable) {
+		t.Errorf("without x: error %v, want ErrUndefinedVariable", err)
+	}
+	if _, err := e.Evaluate(map[string]interface{}{"x": nil}); !errors.Is(err, ep.ErrUndefinedVariable) {
+		t.Errorf("with a nil x: error %v, want ErrUndefinedVariable", err)
+	}
+	if _, err := e.Evaluate(map[string]interface{}{"x": []int{1}}); err == nil || err.Error() != "parameter x is a []int, not a number, bool or string" {
+		t.Errorf("with a slice x: error %v", err)
+	}
+	// Names in brackets are not supported
+	b, err := NewEvaluableExpression("[response-time] < 100")
+	if err == nil {
+		if _, err = b.Evaluate(map[string]interface{}{"response-time": 50}); err == nil {
+			t.Error("[response-time]: no error")
+		}
+	}
+}
+
+func TestEvaluateConcurrent(t *testing.T) {
+	e, err := NewEvaluableExpression("n ?? 0 * 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			params := map[string]interface{}{"n": g}
+			if g%2 == 0 {
+				params = nil
+			}
+			for i := 0; i < 100; i++ {
+				want := float64(g)
+				if params == nil {
+					want = 0
+				}
+				if got, err := e.Evaluate(params); err != nil || got != want {
+					t.Errorf("goroutine %d: %v, %v; want %v", g, got, err, want)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// end of file
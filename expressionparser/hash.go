@@ -0,0 +1,104 @@
+package expressionparser
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"math"
+	"strconv"
+)
+
+// hashFormatVersion is mixed into every Hash. Hash values are stable across
+// processes and Go versions and change only when this version is bumped.
+const hashFormatVersion byte = 1
+
+// Hash returns a 64-bit FNV-1a digest of the structure of expr: node kinds,
+// operator types, numeric values and names. Trees that are Equal hash the
+// same (0 and -0 are normalized); token text and formatting of the source
+// play no part, so "2+3" and " (2 + 3)" agree.
+func Hash(expr Expr) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte{hashFormatVersion})
+	hashNode(h, expr)
+	return h.Sum64()
+}
+
+// hashNode feeds a node and its subtree to h in prefix order.
+func hashNode(h hash.Hash64, expr Expr) {
+	hashHead(h, expr)
+	for _, child := range Children(expr) {
+		hashNode(h, child)
+	}
+}
+
+// hashHead feeds the kind and fields of a node, without its children, to h.
+func hashHead(h hash.Hash64, expr Expr) {
+	var buf [binary.MaxVarintLen64]byte
+
+	writeString := func(s string) {
+		h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(s)))])
+		h.Write([]byte(s))
+	}
+
+	switch v := expr.(type) {
+	case nil:
+		h.Write([]byte{0})
+	case *Number:
+		value := v.Value
+		if value == 0 {
+			value = 0
+		}
+		if v.Imag {
+			h.Write([]byte{tagImaginary})
+		} else {
+			h.Write([]byte{tagNumber})
+		}
+		binary.LittleEndian.PutUint64(buf[:8], math.Float64bits(value))
+		h.Write(buf[:8])
+	case *QuantityLiteral:
+		value := v.Value
+		if value == 0 {
+			value = 0
+		}
+		h.Write([]byte{tagQuantity})
+		binary.LittleEndian.PutUint64(buf[:8], math.Float64bits(value))
+		h.Write(buf[:8])
+		writeString(v.Unit)
+	case *Variable:
+		h.Write([]byte{tagVariable})
+		writeString(v.Name)
+	case *CellRef:
+		h.Write([]byte{tagCell})
+		writeString(v.Ref)
+	case *CellRange:
+		h.Write([]byte{tagCellRange})
+		writeString(v.From)
+		writeString(v.To)
+	case *StringLiteral:
+		h.Write([]byte{tagString})
+		writeString(v.Value)
+	case *BinaryOp:
+		h.Write([]byte{tagBinary})
+		writeString(operatorSymbol(v.Op))
+	case *UnaryOp:
+		h.Write([]byte{tagUnary})
+		writeString(operatorSymbol(v.Op))
+	case *FunctionCall:
+		h.Write([]byte{tagCall})
+		writeString(v.Name)
+		writeString(strconv.Itoa(len(v.Args)))
+	case *ListLiteral:
+		h.Write([]byte{tagList})
+		writeString(strconv.Itoa(len(v.Elems)))
+	case *Let:
+		h.Write([]byte{tagLet})
+		writeString(v.Name)
+	case *Assign:
+		h.Write([]byte{tagAssign})
+		writeString(v.Name)
+	case *Conditional:
+		h.Write([]byte{tagConditional})
+	}
+}
+
+// end of file
@@ -0,0 +1,80 @@
+package expressionparser_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	ep "github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser"
+	"github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser/exprtest"
+)
+
+func TestHashEqualTrees(t *testing.T) {
+	pairs := [][2]string{
+		{"2+3", " (2 + 3)"},
+		{"max(a, [1, 2])", "max((a), [1.0, 2e0])"},
+	}
+	for _, pair := range pairs {
+		a, err := ep.ParseString(pair[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ep.ParseString(pair[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ep.Hash(a) != ep.Hash(b) {
+			t.Errorf("Hash(%q) = %#x, Hash(%q) = %#x", pair[0], ep.Hash(a), pair[1], ep.Hash(b))
+		}
+	}
+}
+
+func TestHashNegativeZero(t *testing.T) {
+	if ep.Hash(ep.Num(math.Copysign(0, -1))) != ep.Hash(ep.Num(0)) {
+		t.Error("-0 and 0, which are Equal, hash apart")
+	}
+}
+
+func TestHashStable(t *testing.T) {
+	// The value must not change between processes or Go versions
+	expr, err := ep.ParseString("2 + 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ep.Hash(expr), uint64(0xd21234050d7f6607); got != want {
+		t.Errorf("Hash(2 + 3) = %#x, want %#x", got, want)
+	}
+}
+
+func TestHashCollisions(t *testing.T) {
+	// Distinct small trees must hash apart: among this many, a good 64-bit
+	// hash collides with a probability of about 10^-10
+	cfg := exprtest.GenConfig{
+		Operators: []string{"+", "-", "*", "/", "<", "&&"},
+		Unary:     []string{"-", "!"},
+		Variables: []string{"x", "y", "z"},
+		Functions: map[string]int{"f": 1, "g": 2},
+		MaxDepth:  3,
+		MaxNumber: 9,
+	}
+	trees := map[uint64]ep.Expr{}
+	r := rand.New(rand.NewSource(1))
+	distinct := 0
+	for i := 0; i < 50000; i++ {
+		_, expr := exprtest.Generate(r, cfg)
+		h := ep.Hash(expr)
+		if other, ok := trees[h]; ok {
+			if !ep.Equal(other, expr) {
+				t.Errorf("%s and %s both hash to %#x", ep.ToSExpr(other), ep.ToSExpr(expr), h)
+			}
+			continue
+		}
+		trees[h] = expr
+		distinct++
+	}
+	if distinct < 10000 {
+		t.Errorf("only %d distinct trees generated", distinct)
+	}
+}
+
+// end of file
@@ -0,0 +1,57 @@
+package expressionparser
+
+// WithBeforeNode makes Eval, EvalValue and the evaluations built on them
+// call fn as the evaluation of each node of the tree begins, parents before
+// their children and children left to right, so that "(2+3)*5" visits the
+// product, the sum, 2, 3 and then 5. Nodes skipped by short-circuiting or
+// on the branch of ?: not taken are never visited. An error returned by fn
+// aborts the evaluation, as an *EvalError at the node wrapping it, which
+// suits breakpoints and step limits; the node is then not evaluated.
+func WithBeforeNode(fn func(expr Expr) error) Option {
+	return func(c *config) {
+		c.beforeNode = fn
+	}
+}
+
+// WithAfterNode makes Eval, EvalValue and the evaluations built on them call
+// fn as the evaluation of each node ends, children before their parents,
+// with the value of the node or the error that ended its evaluation. Every
+// node whose evaluation WithBeforeNode saw begin is seen to end, including
+// those abandoned because a descendant failed.
+func WithAfterNode(fn func(expr Expr, value Value, err error)) Option {
+	return func(c *config) {
+		c.afterNode = fn
+	}
+}
+
+// before calls the BeforeNode hook, if any, for expr.
+func (ev *Evaluator) before(expr Expr) error {
+	if ev.cfg.beforeNode == nil {
+		return nil
+	}
+	if err := ev.cfg.beforeNode(expr); err != nil {
+		return ev.locate(expr, err)
+	}
+	return nil
+}
+
+// after calls the AfterNode hook, if any, for expr.
+func (ev *Evaluator) after(expr Expr, value Value, err error) {
+	if ev.cfg.afterNode != nil {
+		ev.cfg.afterNode(expr, value, err)
+	}
+}
+
+// abandon calls the AfterNode hook with err for the nodes of an operator
+// chain whose evaluation began and failed, innermost first, the last of the
+// slice being the innermost, and returns err.
+func (ev *Evaluator) abandon(chain []*BinaryOp, err error) error {
+	if ev.cfg.afterNode != nil {
+		for i := len(chain) - 1; i >= 0; i-- {
+			ev.cfg.afterNode(chain[i], Value{}, err)
+		}
+	}
+	return err
+}
+
+// end of file
@@ -0,0 +1,112 @@
+package expressionparser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// recordHooks returns an evaluator whose hooks log each node as it begins
+// and ends, and the log.
+func recordHooks(opts ...Option) (*Evaluator, *[]string) {
+	var log []string
+	opts = append(opts,
+		WithBeforeNode(func(expr Expr) error {
+			log = append(log, "> "+Format(expr))
+			return nil
+		}),
+		WithAfterNode(func(expr Expr, value Value, err error) {
+			if err != nil {
+				log = append(log, "< "+Format(expr)+" failed")
+				return
+			}
+			log = append(log, "< "+Format(expr)+" = "+value.String())
+		}))
+	return NewEvaluator(opts...), &log
+}
+
+func TestHookOrder(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"(2+3)*5", []string{
+			"> (2 + 3) * 5", "> 2 + 3", "> 2", "< 2 = 2", "> 3", "< 3 = 3", "< 2 + 3 = 5", "> 5", "< 5 = 5", "< (2 + 3) * 5 = 25",
+		}},
+		// The branch not taken and the right of && are never visited
+		{"1 > 2 ? 1 / 0 : 0 && nope", []string{
+			"> 1 > 2 ? 1 / 0 : 0 && nope", "> 1 > 2", "> 1", "< 1 = 1", "> 2", "< 2 = 2", "< 1 > 2 = false",
+			"> 0 && nope", "> 0", "< 0 = 0", "< 0 && nope = false", "< 1 > 2 ? 1 / 0 : 0 && nope = false",
+		}},
+		// Every node that began ends, those abandoned with the failure
+		{"1 + 2 * (3 / 0)", []string{
+			"> 1 + 2 * (3 / 0)", "> 1", "< 1 = 1", "> 2 * (3 / 0)", "> 2", "< 2 = 2", "> 3 / 0", "> 3", "< 3 = 3", "> 0", "< 0 = 0",
+			"< 3 / 0 failed", "< 2 * (3 / 0) failed", "< 1 + 2 * (3 / 0) failed",
+		}},
+	}
+	for _, tt := range tests {
+		ev, log := recordHooks()
+		ev.Eval(mustParse(t, tt.input))
+		if !reflect.DeepEqual(*log, tt.want) {
+			t.Errorf("%s visits\n%q\nwant\n%q", tt.input, *log, tt.want)
+		}
+	}
+}
+
+func TestBeforeNodeAborts(t *testing.T) {
+	errBreak := errors.New("breakpoint")
+	var visited []string
+	ev := NewEvaluator(WithBeforeNode(func(expr Expr) error {
+		visited = append(visited, Format(expr))
+		if call, ok := expr.(*FunctionCall); ok && call.Name == "sqrt" {
+			return errBreak
+		}
+		return nil
+	}))
+	_, err := ev.Eval(mustParse(t, "1 + sqrt(4) * 2"))
+	var evalErr *EvalError
+	if !errors.Is(err, errBreak) || !errors.As(err, &evalErr) || Format(evalErr.Expr) != "sqrt(4)" || evalErr.Pos != 4 {
+		t.Fatalf("error %v, want the breakpoint at sqrt(4)", err)
+	}
+	// sqrt's argument is not evaluated, nor anything after it
+	if want := []string{"1 + sqrt(4) * 2", "1", "sqrt(4) * 2", "sqrt(4)"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited %q, want %q", visited, want)
+	}
+
+	// A step limit
+	steps := 0
+	limited := NewEvaluator(WithBeforeNode(func(Expr) error {
+		if steps++; steps > 3 {
+			return errBreak
+		}
+		return nil
+	}))
+	if _, err := limited.Eval(mustParse(t, "1 + 2 + 3")); !errors.Is(err, errBreak) {
+		t.Errorf("1 + 2 + 3 past 3 steps: error %v", err)
+	}
+}
+
+func BenchmarkHooks(b *testing.B) {
+	expr, err := ParseString("(x + 1) * (x - 1) / max(x, 2) + sqrt(x)")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, bm := range []struct {
+		name string
+		ev   *Evaluator
+	}{
+		{"None", NewEvaluator()},
+		{"Both", NewEvaluator(WithBeforeNode(func(Expr) error { return nil }), WithAfterNode(func(Expr, Value, error) {}))},
+	} {
+		bm.ev.SetVar("x", 3)
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := bm.ev.Eval(expr); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// end of file
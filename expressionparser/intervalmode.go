@@ -0,0 +1,451 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+)
+
+// Interval is a closed range of real numbers [Lo, Hi], the value of
+// expressions in interval mode.
+type Interval struct {
+	Lo, Hi float64
+}
+
+// String renders the interval as [lo, hi].
+func (i Interval) String() string {
+	return "[" + formatNumber(i.Lo) + ", " + formatNumber(i.Hi) + "]"
+}
+
+// Contains reports whether x lies in the interval.
+func (i Interval) Contains(x float64) bool {
+	return i.Lo <= x && x <= i.Hi
+}
+
+// WithLiteralTolerance makes EvalInterval read every numeric literal x as
+// the interval from x - tol*|x| to x + tol*|x|, for constants known to a
+// relative precision of tol. Zero, the default, reads literals exactly.
+func WithLiteralTolerance(tol float64) Option {
+	return func(c *config) {
+		c.literalTolerance = tol
+	}
+}
+
+// WithExtendedIntervalDivision makes EvalInterval divide by an interval
+// containing zero instead of reporting division by zero. The result is the
+// smallest interval holding every quotient: [1, 2] / [0, 1] is [1, +Inf],
+// and division by an interval with zero strictly inside it gives
+// [-Inf, +Inf].
+func WithExtendedIntervalDivision() Option {
+	return func(c *config) {
+		c.extendedDivision = true
+	}
+}
+
+// EvalInterval evaluates an expression over intervals with the default Evaluator.
+func EvalInterval(expr Expr, vars map[string]Interval) (Interval, error) {
+	return defaultEvaluator.EvalInterval(expr, vars)
+}
+
+// EvalInterval evaluates expr with interval arithmetic: the result holds
+// the value of expr for every choice of the variables within their
+// intervals, so with x in [1.9, 2.1], x*x + 3 is [6.61, 7.41]. Variables
+// are looked up in vars, then among those set on the evaluator, which are
+// points; literals are points, or intervals WithLiteralTolerance, and a
+// list literal of two numbers [lo, hi] is the interval between them. Bounds
+// are computed in float64 with rounding to nearest, so they may be off by
+// a unit in the last place.
+//
+// Every operator gives the tightest bounds for its operands; ^ with an
+// integer exponent takes the sign of the base into account, so x^2 never
+// goes below 0, where x*x, which treats its operands as independent, may.
+// Division by an interval containing zero is an error wrapping
+// ErrDivisionByZero, or gives an unbounded interval
+// WithExtendedIntervalDivision; division by exactly zero follows the
+// WithDivisionByZero policy. % needs points. Comparisons and logical
+// operators give [1, 1] when they hold throughout, [0, 0] when they never
+// do and [0, 1] otherwise, and ?: with such an uncertain condition gives
+// the smallest interval holding both branches. Of the builtins the
+// monotonic sqrt, exp, ln, log, log10, log2, atan, floor, ceil, round,
+// trunc, sign, rad and deg are available, the base of log and the number of
+// places of floor, ceil, round and trunc being points, as are abs, min, max
+// and pow.
+func (ev *Evaluator) EvalInterval(expr Expr, vars map[string]Interval) (Interval, error) {
+	if err := ev.admit(expr); err != nil {
+		return Interval{}, err
+	}
+	for name, i := range vars {
+		if !(i.Lo <= i.Hi) {
+			return Interval{}, withCode(CodeOutsideDomain, fmt.Errorf("interval %s of variable %s is empty or not a number", i, name))
+		}
+	}
+	return ev.evalInterval(expr, &intervalScope{vars: vars})
+}
+
+// intervalScope is a chain of let bindings in interval mode, innermost
+// first; a scope with vars set resolves names from that map instead.
+type intervalScope struct {
+	name   string
+	value  Interval
+	vars   map[string]Interval
+	parent *intervalScope
+}
+
+// point returns the interval holding x alone.
+func point(x float64) Interval {
+	return Interval{x, x}
+}
+
+// isPoint reports whether an interval holds a single number.
+func (i Interval) isPoint() bool {
+	return i.Lo == i.Hi
+}
+
+// hull returns the smallest interval holding both a and b.
+func hull(a, b Interval) Interval {
+	return Interval{math.Min(a.Lo, b.Lo), math.Max(a.Hi, b.Hi)}
+}
+
+// intervalTruth converts the truth of a condition over an interval: 1 when
+// it always holds, 0 when it never does, and both when it may.
+func intervalTruth(always, never bool) Interval {
+	switch {
+	case always:
+		return point(1)
+	case never:
+		return point(0)
+	}
+	return Interval{0, 1}
+}
+
+// truthOf tells whether the values of a condition are all true or all false.
+func truthOf(i Interval) (always, never bool) {
+	return !i.Contains(0) && !math.IsNaN(i.Lo), i.Lo == 0 && i.Hi == 0
+}
+
+// evalInterval evaluates expr in interval mode with the given let bindings in scope.
+func (ev *Evaluator) evalInterval(expr Expr, env *intervalScope) (Interval, error) {
+	switch v := expr.(type) {
+	case *Number:
+		if v.Imag {
+			return Interval{}, imaginaryError(v, "interval")
+		}
+		d := ev.cfg.literalTolerance * math.Abs(v.Value)
+		return Interval{v.Value - d, v.Value + d}, nil
+	case *Variable:
+		for s := env; s != nil; s = s.parent {
+			if s.vars != nil {
+				if i, ok := s.vars[v.Name]; ok {
+					return i, nil
+				}
+			} else if s.name == v.Name {
+				return s.value, nil
+			}
+		}
+		value, ok := ev.vars[v.Name]
+		if !ok {
+			return Interval{}, &UndefinedVariableError{Name: v.Name, Span: v.Span}
+		}
+		return point(value), nil
+	case *ListLiteral:
+		if len(v.Elems) != 2 {
+			return Interval{}, withCode(CodeOutsideDomain, fmt.Errorf("interval literal %s needs two bounds", Format(v)))
+		}
+		lo, err := ev.evalInterval(v.Elems[0], env)
+		if err != nil {
+			return Interval{}, err
+		}
+		hi, err := ev.evalInterval(v.Elems[1], env)
+		if err != nil {
+			return Interval{}, err
+		}
+		if !(lo.Lo <= hi.Hi) {
+			return Interval{}, withCode(CodeOutsideDomain, fmt.Errorf("interval literal %s is empty", Format(v)))
+		}
+		return Interval{lo.Lo, hi.Hi}, nil
+	case *UnaryOp:
+		operand, err := ev.evalInterval(v.Operand, env)
+		if err != nil {
+			return Interval{}, err
+		}
+		switch v.Op.Type {
+		case MINUS:
+			return Interval{-operand.Hi, -operand.Lo}, nil
+		case NOT:
+			always, never := truthOf(operand)
+			return intervalTruth(never, always), nil
+		}
+	case *BinaryOp:
+		left, err := ev.evalInterval(v.Left, env)
+		if err != nil {
+			return Interval{}, err
+		}
+		if v.Op.Type == AND || v.Op.Type == OR {
+			always, never := truthOf(left)
+			if v.Op.Type == AND && never || v.Op.Type == OR && always {
+				// Short-circuit: the right operand cannot change the result
+				return intervalTruth(always, never), nil
+			}
+		}
+		right, err := ev.evalInterval(v.Right, env)
+		if err != nil {
+			return Interval{}, err
+		}
+		return ev.intervalOperation(v, left, right)
+	case *FunctionCall:
+		return ev.callIntervalBuiltin(v, env)
+	case *Let:
+		value, err := ev.evalInterval(v.Value, env)
+		if err != nil {
+			return Interval{}, err
+		}
+		return ev.evalInterval(v.Body, &intervalScope{name: v.Name, value: value, parent: env})
+	case *Conditional:
+		cond, err := ev.evalInterval(v.Cond, env)
+		if err != nil {
+			return Interval{}, err
+		}
+		always, never := truthOf(cond)
+		switch {
+		case always:
+			return ev.evalInterval(v.Then, env)
+		case never:
+			return ev.evalInterval(v.Else, env)
+		}
+		then, err := ev.evalInterval(v.Then, env)
+		if err != nil {
+			return Interval{}, err
+		}
+		els, err := ev.evalInterval(v.Else, env)
+		if err != nil {
+			return Interval{}, err
+		}
+		return hull(then, els), nil
+	}
+	return Interval{}, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate expression of type %T in interval mode", expr))
+}
+
+// intervalOperation applies the binary operator of v to two intervals.
+func (ev *Evaluator) intervalOperation(v *BinaryOp, a, b Interval) (Interval, error) {
+	pos := operatorPos(v.Op, v.Span)
+	switch v.Op.Type {
+	case PLUS:
+		return Interval{a.Lo + b.Lo, a.Hi + b.Hi}, nil
+	case MINUS:
+		return Interval{a.Lo - b.Hi, a.Hi - b.Lo}, nil
+	case MULT:
+		return intervalMul(a, b), nil
+	case DIV:
+		return ev.intervalDiv(v, a, b)
+	case MOD:
+		if !a.isPoint() || !b.isPoint() {
+			return Interval{}, atOffset(withCode(CodeUnsupported, fmt.Errorf("%% needs points, got %s and %s", a, b)), pos)
+		}
+		if b.Lo == 0 {
+			value, ieee, err := ev.zeroDivision(v.Op, v.Right)
+			if err != nil {
+				return Interval{}, err
+			}
+			if !ieee {
+				return point(value), nil
+			}
+		}
+		return point(ev.mod(a.Lo, b.Lo)), nil
+	case POW:
+		return ev.intervalPow(a, b, pos)
+	case LT:
+		return intervalTruth(a.Hi < b.Lo, a.Lo >= b.Hi), nil
+	case LE:
+		return intervalTruth(a.Hi <= b.Lo, a.Lo > b.Hi), nil
+	case GT:
+		return intervalTruth(a.Lo > b.Hi, a.Hi <= b.Lo), nil
+	case GE:
+		return intervalTruth(a.Lo >= b.Hi, a.Hi < b.Lo), nil
+	case EQ:
+		return intervalTruth(a.isPoint() && a == b, a.Hi < b.Lo || b.Hi < a.Lo), nil
+	case NE:
+		return intervalTruth(a.Hi < b.Lo || b.Hi < a.Lo, a.isPoint() && a == b), nil
+	case AND, OR:
+		// The left operand did not decide the result
+		la, ln := truthOf(a)
+		ra, rn := truthOf(b)
+		if v.Op.Type == AND {
+			return intervalTruth(la && ra, rn), nil
+		}
+		return intervalTruth(ra, ln && rn), nil
+	}
+	return Interval{}, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate operator %s in interval mode", operatorSymbol(v.Op)))
+}
+
+// boundProduct multiplies two bounds, taking zero times an infinite bound
+// as zero, since the infinity stands for finite numbers past every bound.
+func boundProduct(x, y float64) float64 {
+	if x == 0 || y == 0 {
+		return 0
+	}
+	return x * y
+}
+
+// intervalMul multiplies two intervals.
+func intervalMul(a, b Interval) Interval {
+	p := [4]float64{boundProduct(a.Lo, b.Lo), boundProduct(a.Lo, b.Hi), boundProduct(a.Hi, b.Lo), boundProduct(a.Hi, b.Hi)}
+	return Interval{math.Min(math.Min(p[0], p[1]), math.Min(p[2], p[3])), math.Max(math.Max(p[0], p[1]), math.Max(p[2], p[3]))}
+}
+
+// intervalDiv divides two intervals, applying the division by zero policy
+// to a divisor of exactly zero and WithExtendedIntervalDivision to one
+// containing it.
+func (ev *Evaluator) intervalDiv(v *BinaryOp, a, b Interval) (Interval, error) {
+	if !b.Contains(0) {
+		return intervalMul(a, Interval{1 / b.Hi, 1 / b.Lo}), nil
+	}
+	if b.isPoint() {
+		value, ieee, err := ev.zeroDivision(v.Op, v.Right)
+		if err != nil {
+			return Interval{}, err
+		}
+		if !ieee {
+			return point(value), nil
+		}
+		return Interval{math.Inf(-1), math.Inf(1)}, nil
+	}
+	if !ev.cfg.extendedDivision {
+		pos := -1
+		if span := SpanOf(v.Right); span != (Span{}) {
+			pos = span.Start
+		}
+		return Interval{}, atOffset(fmt.Errorf("%w: divisor %s contains zero", ErrDivisionByZero, b), pos)
+	}
+	switch {
+	case b.Lo == 0:
+		return intervalMul(a, Interval{1 / b.Hi, math.Inf(1)}), nil
+	case b.Hi == 0:
+		return intervalMul(a, Interval{math.Inf(-1), 1 / b.Lo}), nil
+	}
+	return Interval{math.Inf(-1), math.Inf(1)}, nil
+}
+
+// intervalPow raises an interval to the power of another. An integer
+// point exponent may apply to any base; other exponents need a base that
+// is not negative, over which x^y is monotonic in both x and y. A negative
+// power of an interval around zero divides by it, as / does.
+func (ev *Evaluator) intervalPow(base, exponent Interval, pos int) (Interval, error) {
+	if exponent.Lo < 0 && base.Contains(0) && !base.isPoint() && !ev.cfg.extendedDivision {
+		return Interval{}, atOffset(fmt.Errorf("%w: base %s of a negative power contains zero", ErrDivisionByZero, base), pos)
+	}
+	if n := exponent.Lo; exponent.isPoint() && n == math.Trunc(n) && !math.IsInf(n, 0) {
+		lo, hi := math.Pow(base.Lo, n), math.Pow(base.Hi, n)
+		switch {
+		case n == 0:
+			return point(1), nil
+		case math.Mod(n, 2) != 0:
+			// Odd powers are monotonic, increasing unless n is negative
+			if n < 0 && base.Lo < 0 && base.Hi > 0 {
+				return Interval{math.Inf(-1), math.Inf(1)}, nil
+			}
+			return Interval{math.Min(lo, hi), math.Max(lo, hi)}, nil
+		case base.Lo >= 0 || base.Hi <= 0:
+			return Interval{math.Min(lo, hi), math.Max(lo, hi)}, nil
+		case n > 0:
+			return Interval{0, math.Max(lo, hi)}, nil
+		}
+		// An even negative power of a base around zero is unbounded above
+		return Interval{math.Min(lo, hi), math.Inf(1)}, nil
+	}
+	if base.Lo < 0 {
+		return Interval{}, atOffset(withCode(CodeOutsideDomain, fmt.Errorf("^ of %s, which holds negative numbers, to the non-integer power %s", base, exponent)), pos)
+	}
+	p := [4]float64{math.Pow(base.Lo, exponent.Lo), math.Pow(base.Lo, exponent.Hi), math.Pow(base.Hi, exponent.Lo), math.Pow(base.Hi, exponent.Hi)}
+	return Interval{math.Min(math.Min(p[0], p[1]), math.Min(p[2], p[3])), math.Max(math.Max(p[0], p[1]), math.Max(p[2], p[3]))}, nil
+}
+
+// Builtins of Eval that interval mode applies to both bounds, being
+// monotonic in their first argument
+var monotonicBuiltins = []string{"sqrt", "exp", "ln", "log", "log10", "log2", "atan", "floor", "ceil", "round", "trunc", "sign", "rad", "deg"}
+
+// callIntervalBuiltin evaluates the arguments of a call and applies one of
+// the builtins available in interval mode.
+func (ev *Evaluator) callIntervalBuiltin(call *FunctionCall, env *intervalScope) (Interval, error) {
+	b, ok := builtins[call.Name]
+	available := ok && (call.Name == "abs" || call.Name == "min" || call.Name == "max" || call.Name == "pow")
+	for _, name := range monotonicBuiltins {
+		available = available || ok && call.Name == name
+	}
+	if !available {
+		return Interval{}, ev.unavailableError(call.Name, "interval")
+	}
+	if err := b.checkArgs(call.Name, len(call.Args)); err != nil {
+		return Interval{}, err
+	}
+
+	args := make([]Interval, len(call.Args))
+	for i, arg := range call.Args {
+		value, err := ev.evalInterval(arg, env)
+		if err != nil {
+			return Interval{}, err
+		}
+		args[i] = value
+	}
+	pos := -1
+	if call.Span != (Span{}) {
+		pos = call.Span.Start
+	}
+
+	x := args[0]
+	switch call.Name {
+	case "abs":
+		if x.Contains(0) {
+			return Interval{0, math.Max(-x.Lo, x.Hi)}, nil
+		}
+		return Interval{math.Min(math.Abs(x.Lo), math.Abs(x.Hi)), math.Max(math.Abs(x.Lo), math.Abs(x.Hi))}, nil
+	case "min", "max":
+		fn := math.Min
+		if call.Name == "max" {
+			fn = math.Max
+		}
+		for _, arg := range args[1:] {
+			x = Interval{fn(x.Lo, arg.Lo), fn(x.Hi, arg.Hi)}
+		}
+		return x, nil
+	case "pow":
+		return ev.intervalPow(x, args[1], pos)
+	}
+
+	rest := make([]float64, len(args)-1)
+	for i, arg := range args[1:] {
+		if arg.Lo != arg.Hi {
+			return Interval{}, atOffset(withCode(CodeOutsideDomain, fmt.Errorf("%s: argument %d must be a point, not %s", call.Name, i+2, arg)), pos)
+		}
+		rest[i] = arg.Lo
+	}
+	// The domains are intervals, so checking the bounds checks every point
+	for _, bound := range []float64{x.Lo, x.Hi} {
+		if b.domain == nil {
+			break
+		}
+		bounded := append([]float64{bound}, rest...)
+		if i := b.domain(bounded); i > 0 {
+			return Interval{}, atOffset(domainError(call.Name, bounded, i), pos)
+		} else if i == 0 {
+			return Interval{}, atOffset(fmt.Errorf("%s: argument %s holds numbers %w", call.Name, x, ErrOutsideDomain), pos)
+		}
+	}
+	fn := b.fn
+	if ev.cfg.angleUnit == Degrees && b.degrees != nil {
+		fn = b.degrees
+	}
+	lo, err := fn(append([]float64{x.Lo}, rest...))
+	if err != nil {
+		return Interval{}, err
+	}
+	hi, err := fn(append([]float64{x.Hi}, rest...))
+	if err != nil {
+		return Interval{}, err
+	}
+	if lo > hi {
+		// log with a base below 1 decreases
+		lo, hi = hi, lo
+	}
+	return Interval{lo, hi}, nil
+}
+
+// end of file
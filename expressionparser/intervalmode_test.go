@@ -0,0 +1,119 @@
+package expressionparser
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEvalInterval(t *testing.T) {
+	inf := math.Inf(1)
+	vars := map[string]Interval{"x": {1.9, 2.1}, "s": {-1, 2}, "z": {-1, 1}, "p": {0, 1}, "n": {-3, -2}}
+	tests := []struct {
+		input string
+		want  Interval
+	}{
+		{"x*x + 3", Interval{6.61, 7.41}},
+		// Squaring an interval straddling zero starts at 0, where the
+		// product of its independent operands does not
+		{"s^2", Interval{0, 4}},
+		{"(-s)^2", Interval{0, 4}},
+		{"s*s", Interval{-2, 4}},
+		{"z^3", Interval{-1, 1}},
+		{"n * x", Interval{-6.3, -3.8}},
+		{"1 / x", Interval{1 / 2.1, 1 / 1.9}},
+		{"[1, 2] + [3, 4]", Interval{4, 6}},
+		{"[1, 2] - [3, 4]", Interval{-3, -1}},
+		{"let y = x in y - y", Interval{-0.2, 0.2}},
+		{"sqrt(x)", Interval{math.Sqrt(1.9), math.Sqrt(2.1)}},
+		{"exp(p)", Interval{1, math.E}},
+		{"ln(x)", Interval{math.Log(1.9), math.Log(2.1)}},
+		{"abs(s)", Interval{0, 2}},
+		{"min(x, s)", Interval{-1, 2}},
+		{"x > 1", Interval{1, 1}},
+		{"x < 1", Interval{0, 0}},
+		{"s > 0", Interval{0, 1}},
+		{"s > 0 ? 1 : 5", Interval{1, 5}},
+		{"10", Interval{10, 10}},
+	}
+	for _, tt := range tests {
+		got, err := EvalInterval(mustParse(t, tt.input), vars)
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if math.Abs(got.Lo-tt.want.Lo) > 1e-12 || math.Abs(got.Hi-tt.want.Hi) > 1e-12 {
+			t.Errorf("%s = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	ext := NewEvaluator(WithExtendedIntervalDivision())
+	for input, want := range map[string]Interval{
+		"1 / z":        {-inf, inf},
+		"[1, 2] / p":   {1, inf},
+		"[-2, -1] / p": {-inf, -1},
+	} {
+		if got, err := ext.EvalInterval(mustParse(t, input), vars); err != nil || got != want {
+			t.Errorf("%s with extended division = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	tol := NewEvaluator(WithLiteralTolerance(0.01))
+	if got, err := tol.EvalInterval(mustParse(t, "100 + x"), vars); err != nil || math.Abs(got.Lo-100.9) > 1e-12 || math.Abs(got.Hi-103.1) > 1e-12 {
+		t.Errorf("100 + x with a tolerance of 1%% = %v, %v; want [100.9, 103.1]", got, err)
+	}
+}
+
+func TestEvalIntervalContainsPoints(t *testing.T) {
+	inputs := []string{"x*x + 3", "x^2 - 2*x", "(x - 2) * (x + 1) / 3", "sqrt(x) + exp(-x)", "abs(x - 2) ^ 3", "x > 2 ? x : 4 - x"}
+	x := Interval{1.5, 2.5}
+	for _, input := range inputs {
+		expr := mustParse(t, input)
+		bounds, err := EvalInterval(expr, map[string]Interval{"x": x})
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		for i := 0; i <= 20; i++ {
+			at := x.Lo + (x.Hi-x.Lo)*float64(i)/20
+			v, err := EvalWithVars(expr, map[string]float64{"x": at})
+			if err != nil || !(bounds.Lo-1e-12 <= v && v <= bounds.Hi+1e-12) {
+				t.Errorf("%s at x = %v is %v, %v; outside %v", input, at, v, err, bounds)
+			}
+		}
+	}
+}
+
+func TestEvalIntervalErrors(t *testing.T) {
+	vars := map[string]Interval{"x": {1.9, 2.1}, "s": {-1, 2}, "z": {-1, 1}, "p": {0, 1}}
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{"1 / z", CodeDivisionByZero, "division by zero: divisor [-1, 1] contains zero at offset 4"},
+		{"[1, 2] / p", CodeDivisionByZero, "division by zero: divisor [0, 1] contains zero at offset 9"},
+		{"1 / 0", CodeDivisionByZero, "division by zero at offset 4"},
+		{"ln(p)", CodeOutsideDomain, "ln: argument [0, 1] holds numbers outside the domain at offset 0"},
+		{"sqrt(s)", CodeOutsideDomain, "sqrt: argument [-1, 2] holds numbers outside the domain at offset 0"},
+		{"sin(x)", CodeUnsupported, "function sin is not available in interval mode"},
+		{"x % 2", CodeUnsupported, "% needs points, got [1.9, 2.1] and [2, 2] at offset 2"},
+	}
+	for _, tt := range tests {
+		_, err := EvalInterval(mustParse(t, tt.input), vars)
+		if ErrorCode(err) != tt.code || err.Error() != tt.msg {
+			t.Errorf("%s: error %v, want %s %q", tt.input, err, tt.code, tt.msg)
+		}
+	}
+	if _, err := EvalInterval(mustParse(t, "1 / z"), vars); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("1 / z: error %v is not ErrDivisionByZero", err)
+	}
+	ext := NewEvaluator(WithExtendedIntervalDivision())
+	if _, err := ext.EvalInterval(mustParse(t, "1 / [0, 0]"), nil); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("1 / [0, 0] with extended division: error %v, want division by zero", err)
+	}
+	if _, err := EvalInterval(mustParse(t, "x"), map[string]Interval{"x": {2, 1}}); ErrorCode(err) != CodeOutsideDomain {
+		t.Errorf("x in [2, 1]: error %v, want %s", err, CodeOutsideDomain)
+	}
+}
+
+// end of file
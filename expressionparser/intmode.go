@@ -0,0 +1,441 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EvalInt evaluates an expression in integer mode with the default Evaluator.
+func EvalInt(expr Expr) (int64, error) {
+	return defaultEvaluator.EvalInt(expr)
+}
+
+// EvalInt evaluates expr with exact int64 arithmetic. Every literal must be
+// an integer, which is checked before evaluation starts; a fractional
+// literal is reported with its offset. Variables must hold integral values.
+// A literal is read from its source text, so any int64 can be written, up
+// to 9223372036854775807. Variables, and literals of built trees that have
+// no text, are float64 values, so from 2^53 in magnitude up they are
+// rejected as they may already have been rounded.
+//
+// No operation ever wraps around: one whose result does not fit in an
+// int64, from + to ^, << and the builtins abs and fact, gives an
+// *OverflowError instead. By default / must divide exactly and % is the
+// truncated remainder, with the sign of the dividend, as in Go; with
+// WithFloorDivision, / rounds toward negative infinity and % takes the sign
+// of the divisor, so that a == (a/b)*b + a%b holds in both modes. ^ requires
+// a non-negative exponent. Division or modulo by zero follows the
+// WithDivisionByZero policy, except that DivideByZeroIEEE gives an error and
+// a DivideByZeroValue must be an integer. Comparisons and logical operators
+// yield 1 or 0. Of the builtins only abs, min, max, clamp, sign and fact
+// are available.
+func (ev *Evaluator) EvalInt(expr Expr) (int64, error) {
+	if err := ev.admit(expr); err != nil {
+		return 0, err
+	}
+
+	var literalErr error
+	Walk(expr, func(e Expr) bool {
+		if n, ok := e.(*Number); ok && literalErr == nil {
+			if n.Imag {
+				literalErr = imaginaryError(n, "integer")
+			} else if _, ok := intLiteral(n); !ok {
+				literalErr = withCode(CodeUnsupported, fmt.Errorf("literal %s at offset %d is not an exact integer", formatNumber(n.Value), n.Span.Start))
+			}
+		}
+		return literalErr == nil
+	})
+	if literalErr != nil {
+		return 0, literalErr
+	}
+
+	return ev.evalInt(expr, nil)
+}
+
+// OverflowError reports an integer mode operation whose result does not fit
+// in an int64.
+type OverflowError struct {
+	Op       string // operator symbol or function name
+	Operands []int64
+	Pos      int // offset of the operator or call; -1 for trees built without spans
+}
+
+// Error shows the operation with its operands and, when known, the offset.
+func (e *OverflowError) Error() string {
+	operands := make([]string, len(e.Operands))
+	for i, n := range e.Operands {
+		operands[i] = strconv.FormatInt(n, 10)
+	}
+	var operation string
+	switch {
+	case isIdentStart([]rune(e.Op)[0]):
+		operation = e.Op + "(" + strings.Join(operands, ", ") + ")"
+	case len(operands) == 1:
+		operation = e.Op + "(" + operands[0] + ")"
+	default:
+		if e.Op == "^" && e.Operands[0] < 0 {
+			// -2 ^ 64 would read as -(2 ^ 64)
+			operands[0] = "(" + operands[0] + ")"
+		}
+		operation = strings.Join(operands, " "+e.Op+" ")
+	}
+	if e.Pos < 0 {
+		return "integer overflow in " + operation
+	}
+	return fmt.Sprintf("integer overflow in %s at offset %d", operation, e.Pos)
+}
+
+// Is makes an OverflowError match ErrOverflow.
+func (e *OverflowError) Is(target error) bool {
+	return target == ErrOverflow
+}
+
+// intScope is a chain of let bindings in integer mode, innermost first.
+type intScope struct {
+	name   string
+	value  int64
+	parent *intScope
+}
+
+// Magnitude below which every integer read into a float64 is known to be exact
+const maxExactFloatInt = 1 << 53
+
+// toInt64 converts an integral float64 that is within the exactly
+// representable range.
+func toInt64(v float64) (int64, bool) {
+	if v != math.Trunc(v) || math.Abs(v) >= maxExactFloatInt {
+		return 0, false
+	}
+	return int64(v), true
+}
+
+// intLiteral gives the integer a literal stands for. Decimal digits are
+// parsed from the source text, exact over the whole int64 range; any other
+// spelling, or a literal without text, goes through toInt64.
+func intLiteral(n *Number) (int64, bool) {
+	if n.Text != "" {
+		v, err := strconv.ParseInt(n.Text, 10, 64)
+		if err == nil {
+			return v, true
+		}
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, false
+		}
+	}
+	return toInt64(n.Value)
+}
+
+// evalInt evaluates expr in integer mode with the given let bindings in scope.
+func (ev *Evaluator) evalInt(expr Expr, env *intScope) (int64, error) {
+	switch v := expr.(type) {
+	case *Number:
+		n, _ := intLiteral(v)
+		return n, nil
+	case *Variable:
+		for s := env; s != nil; s = s.parent {
+			if s.name == v.Name {
+				return s.value, nil
+			}
+		}
+		value, ok := ev.vars[v.Name]
+		if !ok {
+			return 0, &UndefinedVariableError{Name: v.Name, Span: v.Span}
+		}
+		n, ok := toInt64(value)
+		if !ok {
+			return 0, withCode(CodeUnsupported, fmt.Errorf("variable %s = %s is not an exact integer", v.Name, formatNumber(value)))
+		}
+		return n, nil
+	case *UnaryOp:
+		operand, err := ev.evalInt(v.Operand, env)
+		if err != nil {
+			return 0, err
+		}
+		switch v.Op.Type {
+		case MINUS:
+			if operand == math.MinInt64 {
+				return 0, &OverflowError{Op: "-", Operands: []int64{operand}, Pos: operatorPos(v.Op, v.Span)}
+			}
+			return -operand, nil
+		case NOT:
+			return intTruth(operand == 0), nil
+		}
+	case *BinaryOp:
+		left, err := ev.evalInt(v.Left, env)
+		if err != nil {
+			return 0, err
+		}
+		if (v.Op.Type == AND || v.Op.Type == OR) && (left != 0) == (v.Op.Type == OR) {
+			// Short-circuit: the right operand cannot change the result
+			return intTruth(left != 0), nil
+		}
+		right, err := ev.evalInt(v.Right, env)
+		if err != nil {
+			return 0, err
+		}
+		if isDivision(v.Op) && right == 0 {
+			value, ieee, err := ev.zeroDivision(v.Op, v.Right)
+			if err != nil {
+				return 0, err
+			}
+			if ieee {
+				return 0, noIEEEError(v.Op, v.Right, "integer")
+			}
+			n, ok := toInt64(value)
+			if !ok {
+				return 0, withCode(CodeUnsupported, fmt.Errorf("division by zero value %s is not an exact integer", formatNumber(value)))
+			}
+			return n, nil
+		}
+		return ev.intOperation(v.Op, operatorPos(v.Op, v.Span), left, right)
+	case *FunctionCall:
+		return ev.callIntBuiltin(v, env)
+	case *Let:
+		value, err := ev.evalInt(v.Value, env)
+		if err != nil {
+			return 0, err
+		}
+		return ev.evalInt(v.Body, &intScope{name: v.Name, value: value, parent: env})
+	case *Conditional:
+		cond, err := ev.evalInt(v.Cond, env)
+		if err != nil {
+			return 0, err
+		}
+		if cond != 0 {
+			return ev.evalInt(v.Then, env)
+		}
+		return ev.evalInt(v.Else, env)
+	}
+	return 0, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate expression of type %T in integer mode", expr))
+}
+
+// intOperation applies a binary operator at offset pos to two integers,
+// checking for overflow. The divisor of / and % must not be zero.
+func (ev *Evaluator) intOperation(op Token, pos int, a, b int64) (int64, error) {
+	overflow := func() (int64, error) {
+		return 0, &OverflowError{Op: operatorSymbol(op), Operands: []int64{a, b}, Pos: pos}
+	}
+
+	switch op.Type {
+	case PLUS:
+		r := a + b
+		if (a > 0 && b > 0 && r < 0) || (a < 0 && b < 0 && r >= 0) {
+			return overflow()
+		}
+		return r, nil
+	case MINUS:
+		r := a - b
+		if (b < 0 && r < a) || (b > 0 && r > a) {
+			return overflow()
+		}
+		return r, nil
+	case MULT:
+		r, ok := mulInt64(a, b)
+		if !ok {
+			return overflow()
+		}
+		return r, nil
+	case DIV:
+		if a == math.MinInt64 && b == -1 {
+			return overflow()
+		}
+		q, r := a/b, a%b
+		if r == 0 {
+			return q, nil
+		}
+		if !ev.cfg.floorDivision {
+			return 0, withCode(CodeUnsupported, fmt.Errorf("%d / %d is not an exact integer division", a, b))
+		}
+		if (r < 0) != (b < 0) {
+			q--
+		}
+		return q, nil
+	case MOD:
+		r := a % b
+		if (ev.cfg.floorDivision || ev.cfg.flooredModulo) && r != 0 && (r < 0) != (b < 0) {
+			r += b
+		}
+		return r, nil
+	case POW:
+		if b < 0 {
+			return 0, withCode(CodeUnsupported, fmt.Errorf("negative exponent %d in integer mode", b))
+		}
+		result, base := int64(1), a
+		for e := b; e > 0; e >>= 1 {
+			var ok bool
+			if e&1 == 1 {
+				if result, ok = mulInt64(result, base); !ok {
+					return overflow()
+				}
+			}
+			if e > 1 {
+				if base, ok = mulInt64(base, base); !ok {
+					return overflow()
+				}
+			}
+		}
+		return result, nil
+	case LT:
+		return intTruth(a < b), nil
+	case LE:
+		return intTruth(a <= b), nil
+	case GT:
+		return intTruth(a > b), nil
+	case GE:
+		return intTruth(a >= b), nil
+	case EQ:
+		return intTruth(a == b), nil
+	case NE:
+		return intTruth(a != b), nil
+	case AND:
+		return intTruth(a != 0 && b != 0), nil
+	case OR:
+		return intTruth(a != 0 || b != 0), nil
+	case BITAND:
+		return a & b, nil
+	case BITOR:
+		return a | b, nil
+	case BITXOR:
+		return a ^ b, nil
+	case SHL, SHR:
+		if b < 0 || b > 63 {
+			return 0, atOffset(withCode(CodeOutsideDomain, fmt.Errorf("shift count %d is outside 0..63", b)), pos)
+		}
+		if op.Type == SHR {
+			return a >> uint(b), nil
+		}
+		if r := a << uint(b); r>>uint(b) == a {
+			return r, nil
+		}
+		return overflow()
+	}
+	return 0, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate operator %s in integer mode", operatorSymbol(op)))
+}
+
+// mulInt64 multiplies two integers, reporting false on overflow.
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	r := a * b
+	if r/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return r, true
+}
+
+// intTruth converts a boolean to 1 or 0.
+func intTruth(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Builtins available in integer mode; the signature fields of builtin give
+// their arity. A builtin reports overflow by returning errIntOverflow, which
+// the caller turns into an *OverflowError for the call.
+var intBuiltins = map[string]struct {
+	sig builtin
+	fn  func(args []int64) (int64, error)
+}{
+	"abs": {builtin{arity: 1}, func(args []int64) (int64, error) {
+		if args[0] == math.MinInt64 {
+			return 0, errIntOverflow
+		}
+		if args[0] < 0 {
+			return -args[0], nil
+		}
+		return args[0], nil
+	}},
+	"fact": {builtin{arity: 1}, func(args []int64) (int64, error) {
+		if args[0] < 0 {
+			return 0, fmt.Errorf("fact: argument %d is %w", args[0], ErrOutsideDomain)
+		}
+		result := int64(1)
+		for i := int64(2); i <= args[0]; i++ {
+			var ok bool
+			if result, ok = mulInt64(result, i); !ok {
+				return 0, errIntOverflow
+			}
+		}
+		return result, nil
+	}},
+	"sign": {builtin{arity: 1}, func(args []int64) (int64, error) {
+		switch {
+		case args[0] > 0:
+			return 1, nil
+		case args[0] < 0:
+			return -1, nil
+		}
+		return 0, nil
+	}},
+	"min": {builtin{arity: 2, variadic: true}, func(args []int64) (int64, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg < result {
+				result = arg
+			}
+		}
+		return result, nil
+	}},
+	"max": {builtin{arity: 2, variadic: true}, func(args []int64) (int64, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg > result {
+				result = arg
+			}
+		}
+		return result, nil
+	}},
+	"clamp": {builtin{arity: 3}, func(args []int64) (int64, error) {
+		x, lo, hi := args[0], args[1], args[2]
+		switch {
+		case lo > hi:
+			return 0, withCode(CodeOutsideDomain, fmt.Errorf("clamp: lower bound %d is greater than upper bound %d", lo, hi))
+		case x < lo:
+			return lo, nil
+		case x > hi:
+			return hi, nil
+		}
+		return x, nil
+	}},
+}
+
+// errIntOverflow is returned by an integer mode builtin whose result does not fit in an int64.
+var errIntOverflow = fmt.Errorf("integer %w", ErrOverflow)
+
+// callIntBuiltin evaluates the arguments of a call and applies one of the
+// builtins available in integer mode.
+func (ev *Evaluator) callIntBuiltin(call *FunctionCall, env *intScope) (int64, error) {
+	b, ok := intBuiltins[call.Name]
+	if !ok {
+		return 0, ev.unavailableError(call.Name, "integer")
+	}
+	if err := b.sig.checkArgs(call.Name, len(call.Args)); err != nil {
+		return 0, err
+	}
+
+	args := make([]int64, len(call.Args))
+	for i, arg := range call.Args {
+		value, err := ev.evalInt(arg, env)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = value
+	}
+	result, err := b.fn(args)
+	if err == errIntOverflow {
+		pos := -1
+		if call.Span != (Span{}) {
+			pos = call.Span.Start
+		}
+		return 0, &OverflowError{Op: call.Name, Operands: args, Pos: pos}
+	}
+	return result, err
+}
+
+// end of file
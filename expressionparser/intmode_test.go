@@ -0,0 +1,166 @@
+package expressionparser
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEvalIntOverflow(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"3037000500 * 3037000500", "integer overflow in 3037000500 * 3037000500 at offset 11"},
+		{"4000000000 * 4000000000 * 0", "integer overflow in 4000000000 * 4000000000 at offset 11"},
+		{"2 ^ 63", "integer overflow in 2 ^ 63 at offset 2"},
+		{"2^62 + 2^62", "integer overflow in 4611686018427387904 + 4611686018427387904 at offset 5"},
+		{"-(2^62) - 2^62 - 1", "integer overflow in -9223372036854775808 - 1 at offset 15"},
+		{"abs(-2^62*2)", "integer overflow in abs(-9223372036854775808) at offset 0"},
+	}
+	for _, tt := range tests {
+		_, err := EvalInt(mustParse(t, tt.input))
+		var overflow *OverflowError
+		if !errors.As(err, &overflow) || !errors.Is(err, ErrOverflow) {
+			t.Errorf("%s: error %v, want an *OverflowError", tt.input, err)
+			continue
+		}
+		if err.Error() != tt.msg {
+			t.Errorf("%s: error %q, want %q", tt.input, err, tt.msg)
+		}
+	}
+
+	// Results at the ends of the int64 range
+	for input, want := range map[string]int64{
+		"3037000499 * 3037000499": 9223372030926249001,
+		"-(2^62) - 2^62":          -1 << 63,
+		"2^62 - 1 + 2^62":         1<<63 - 1,
+	} {
+		got, err := EvalInt(mustParse(t, input))
+		if err != nil || got != want {
+			t.Errorf("%s = %v, %v; want %v", input, got, err, want)
+		}
+	}
+}
+
+func TestEvalIntDivision(t *testing.T) {
+	floor := NewEvaluator(WithFloorDivision())
+	tests := []struct {
+		input string
+		exact int64 // -99 for an inexact division error
+		floor int64
+	}{
+		{"7 / 2", -99, 3},
+		{"-7 / 2", -99, -4},
+		{"7 / -2", -99, -4},
+		{"-7 / -2", -99, 3},
+		{"6 / 3", 2, 2},
+		{"-6 / 3", -2, -2},
+		{"7 % 3", 1, 1},
+		{"-7 % 3", -1, 2},
+		{"7 % -3", 1, -2},
+		{"-7 % -3", -1, -1},
+		{"-6 % 3", 0, 0},
+	}
+	for _, tt := range tests {
+		expr := mustParse(t, tt.input)
+		got, err := EvalInt(expr)
+		if tt.exact == -99 {
+			if ErrorCode(err) != CodeUnsupported {
+				t.Errorf("%s: %v, %v; want an inexact division error", tt.input, got, err)
+			}
+		} else if err != nil || got != tt.exact {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.exact)
+		}
+		if got, err := floor.EvalInt(expr); err != nil || got != tt.floor {
+			t.Errorf("%s with floor division = %v, %v; want %v", tt.input, got, err, tt.floor)
+		}
+	}
+	if _, err := EvalInt(mustParse(t, "7 / 2")); err == nil || err.Error() != "7 / 2 is not an exact integer division" {
+		t.Errorf("7 / 2: error %v", err)
+	}
+}
+
+func TestEvalIntRejectsFractions(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"1.5 + 1", "literal 1.5 at offset 0 is not an exact integer"},
+		{"2 + 0.5 * 4", "literal 0.5 at offset 4 is not an exact integer"},
+		{"1 / 0 + 0.1", "literal 0.1 at offset 8 is not an exact integer"}, // before evaluating
+		{"9223372036854775808 - 1", "literal 9.223372036854776e+18 at offset 0 is not an exact integer"},
+		{"2 ^ -1", "negative exponent -1 in integer mode"},
+		{"sqrt(4)", "function sqrt is not available in integer mode"},
+	}
+	for _, tt := range tests {
+		_, err := EvalInt(mustParse(t, tt.input))
+		if ErrorCode(err) != CodeUnsupported || err.Error() != tt.msg {
+			t.Errorf("%s: error %v, want %q", tt.input, err, tt.msg)
+		}
+	}
+}
+
+func TestEvalIntBoundaries(t *testing.T) {
+	// Literals are read from their text, so max can be written out
+	const bounds = "let max = 9223372036854775807 in let min = -max - 1 in "
+	results := []struct {
+		input string
+		want  int64
+	}{
+		{"max", math.MaxInt64},
+		{"min", math.MinInt64},
+		{"max * 1", math.MaxInt64},
+		{"min * 1", math.MinInt64},
+		{"max + min", -1},
+		{"min + max + 1", 0},
+		{"9007199254740993 - 2^53", 1}, // past 2^53, which a float64 would round
+		{"-9223372036854775807 - 1", math.MinInt64},
+		{"min % -1", 0},
+		{"(-2) ^ 63", math.MinInt64},
+		{"-1 << 63", math.MinInt64},
+		{"1 << 62", 1 << 62},
+		{"min >> 63", -1},
+		{"abs(min + 1)", math.MaxInt64},
+		{"fact(20)", 2432902008176640000},
+		{"fact(0)", 1},
+	}
+	for _, tt := range results {
+		got, err := EvalInt(mustParse(t, bounds+tt.input))
+		if err != nil || got != tt.want {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	overflows := []struct {
+		input string
+		op    string
+	}{
+		{"max + 1", "+"},
+		{"max - -1", "-"},
+		{"min - 1", "-"},
+		{"min + -1", "+"},
+		{"-min", "-"},
+		{"min * -1", "*"},
+		{"-1 * min", "*"},
+		{"min / -1", "/"},
+		{"2 ^ 63", "^"},
+		{"(-2) ^ 64", "^"},
+		{"1 << 63", "<<"},
+		{"max << 1", "<<"},
+		{"abs(min)", "abs"},
+		{"fact(21)", "fact"},
+	}
+	for _, tt := range overflows {
+		_, err := EvalInt(mustParse(t, bounds+tt.input))
+		var overflow *OverflowError
+		if !errors.As(err, &overflow) || overflow.Op != tt.op || overflow.Pos < len(bounds) {
+			t.Errorf("%s: error %v, want an *OverflowError for %s after offset %d", tt.input, err, tt.op, len(bounds))
+		}
+	}
+	if _, err := EvalInt(mustParse(t, "fact(-1)")); ErrorCode(err) != CodeOutsideDomain {
+		t.Errorf("fact(-1): error %v, want %s", err, CodeOutsideDomain)
+	}
+}
+
+// end of file
@@ -0,0 +1,165 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+)
+
+// Iteration forms: sum(i, 1, 10, i^2) and prod(k, 1, n, 1 + r/k), whose last
+// argument is evaluated with the variable named first bound to each whole
+// number from the second argument to the third, the values being added or
+// multiplied. The variable is bound in the last argument only, as let binds
+// it in its body; the bounds are evaluated once. A call to sum takes this
+// form only with four arguments, the first a variable that the last refers
+// to, so that sum(a, b, c, d) still adds its arguments.
+var iterationForms = map[string]builtin{
+	"sum":  {arity: 4},
+	"prod": {arity: 4},
+}
+
+// iterationVariable returns the variable a call to an iteration form binds
+// in its last argument, when it has one.
+func iterationVariable(call *FunctionCall) (string, bool) {
+	if _, ok := iterationForms[call.Name]; !ok || len(call.Args) != 4 {
+		return "", false
+	}
+	v, ok := call.Args[0].(*Variable)
+	if !ok || call.Name == "sum" && !contains(Variables(call.Args[3]), v.Name) {
+		return "", false
+	}
+	return v.Name, true
+}
+
+// formBinding returns the variable a call to a special or iteration form
+// binds, the index of the argument naming it and that of the argument it
+// is bound in.
+func formBinding(call *FunctionCall) (name string, binder, body int, ok bool) {
+	if name, ok := formVariable(call); ok {
+		return name, 1, 0, true
+	}
+	if name, ok := iterationVariable(call); ok {
+		return name, 0, 3, true
+	}
+	return "", 0, 0, false
+}
+
+// isIterationForm reports whether a call goes to an iteration form, one not
+// replaced by a function registered on the evaluator: any call to prod, and
+// a call to sum of that form.
+func (ev *Evaluator) isIterationForm(call *FunctionCall) bool {
+	if _, registered := ev.funcs[call.Name]; registered {
+		return false
+	}
+	_, ok := iterationVariable(call)
+	return ok || call.Name == "prod"
+}
+
+// checkIteration checks the arguments of a call to an iteration form and
+// returns the variable it binds.
+func checkIteration(call *FunctionCall) (string, error) {
+	if err := iterationForms[call.Name].checkArgs(call.Name, len(call.Args)); err != nil {
+		return "", err
+	}
+	name, ok := iterationVariable(call)
+	if !ok {
+		return "", withCode(CodeType, fmt.Errorf("%s: argument 1 must be the name of a variable, as in %s(i, 1, 10, i^2)", call.Name, call.Name))
+	}
+	return name, nil
+}
+
+// callIteration evaluates a call to an iteration form in env.
+func (ev *Evaluator) callIteration(call *FunctionCall, env *scope) (Value, error) {
+	name, err := checkIteration(call)
+	if err != nil {
+		return Value{}, err
+	}
+	from, err := ev.eval(call.Args[1], env)
+	if err != nil {
+		return Value{}, err
+	}
+	to, err := ev.eval(call.Args[2], env)
+	if err != nil {
+		return Value{}, err
+	}
+	if ev.stats != nil {
+		ev.stats.stats.Calls[call.Name]++
+	}
+	return ev.iterate(call, name, from, to, func(x float64) (Value, error) {
+		return ev.eval(call.Args[3], &scope{name: name, value: NumberValue(x), parent: env})
+	})
+}
+
+// compileIteration compiles a call to an iteration form, its variable
+// taking the next slot of the let bindings.
+func (ev *Evaluator) compileIteration(call *FunctionCall, lets []string, depth *int) closure {
+	name, err := checkIteration(call)
+	if err != nil {
+		return fail(err)
+	}
+	slot := len(lets)
+	if slot+1 > *depth {
+		*depth = slot + 1
+	}
+	from := ev.compileNode(call.Args[1], lets, depth)
+	to := ev.compileNode(call.Args[2], lets, depth)
+	body := ev.compileNode(call.Args[3], append(lets[:slot:slot], name), depth)
+	return func(f *frame) (Value, error) {
+		lo, err := from(f)
+		if err != nil {
+			return Value{}, err
+		}
+		hi, err := to(f)
+		if err != nil {
+			return Value{}, err
+		}
+		return ev.iterate(call, name, lo, hi, func(x float64) (Value, error) {
+			f.lets[slot] = NumberValue(x)
+			return body(f)
+		})
+	}
+}
+
+// iterate adds, for sum, or multiplies, for prod, the values of f at the
+// whole numbers from from to to: none when from is greater, giving 0 or 1.
+// The sum is compensated as that of cumsum is. Each value of f counts
+// against the budget set WithMaxOps, and cancelling the context of
+// EvalContext stops the loop, so a long range cannot run unchecked.
+func (ev *Evaluator) iterate(call *FunctionCall, name string, from, to Value, f sample) (Value, error) {
+	bounds := [2]float64{}
+	for i, bound := range []Value{from, to} {
+		if !bound.numeric() {
+			return Value{}, withCode(CodeType, fmt.Errorf("%s: argument %d is a %s, not a number", call.Name, i+2, bound.kind))
+		}
+		bounds[i] = bound.num
+	}
+	lo, hi := bounds[0], bounds[1]
+	if lo != math.Trunc(lo) || hi != math.Trunc(hi) || math.Abs(lo) >= maxExactFloatInt || math.Abs(hi) >= maxExactFloatInt {
+		return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("%s: bounds %s and %s must be whole numbers between -2^53 and 2^53", call.Name, formatNumber(lo), formatNumber(hi)))
+	}
+
+	var sum compensatedSum
+	product := 1.0
+	for x := lo; x <= hi; x++ {
+		value, err := f(x)
+		if err == nil && !value.numeric() {
+			err = withCode(CodeType, fmt.Errorf("the expression is a %s, not a number", value.kind))
+		}
+		if err != nil {
+			if ev.progress != nil && ev.progress.stopped {
+				return Value{}, err
+			}
+			return Value{}, fmt.Errorf("%s: at %s = %s: %w", call.Name, name, formatNumber(x), err)
+		}
+		if call.Name == "sum" {
+			sum.add(value.num)
+		} else {
+			product *= value.num
+		}
+	}
+	if call.Name == "sum" {
+		return NumberValue(sum.value()), nil
+	}
+	return NumberValue(product), nil
+}
+
+// end of file
@@ -0,0 +1,82 @@
+package expressionparser
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIteration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"sum(i, 1, 10, i*i)", 385},
+		{"prod(i, 1, 5, i)", 120},
+		{"sum(i, -2, 2, i)", 0},
+		{"sum(i, 3, 3, i * 7)", 21},
+		{"sum(i, 1, 3, x * i)", 12},
+		{"sum(i, 1, 4, 1 / i) * 12", 25},
+		// Empty ranges give the identity
+		{"sum(i, 5, 1, i)", 0},
+		{"prod(i, 5, 1, i)", 1},
+		// Nested forms see the outer variable
+		{"sum(i, 1, 3, sum(j, 1, i, i * j))", 25},   // 1 + 2*3 + 3*6
+		{"sum(i, 1, 3, prod(j, 1, 2, i + j))", 38},  // 2*3 + 3*4 + 4*5
+		{"let i = 100 in sum(i, 1, 3, i) + i", 106}, // in a child scope
+		// Without a bound variable in the body, sum adds its arguments
+		{"sum(1, 2, 3, 4)", 10},
+	}
+	for _, tt := range tests {
+		expr := mustParse(t, tt.input)
+		ev := NewEvaluator()
+		ev.SetVar("x", 2)
+		if got, err := ev.Eval(expr); err != nil || got != tt.want {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+		f, err := ev.CompileFunc(expr)
+		if err != nil {
+			t.Errorf("CompileFunc(%s): %v", tt.input, err)
+			continue
+		}
+		if got, err := f(map[string]float64{"x": 2}); err != nil || got != tt.want {
+			t.Errorf("compiled %s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestIterationBounds(t *testing.T) {
+	for input, msg := range map[string]string{
+		"sum(i, 1.5, 3, i)":  "sum: bounds 1.5 and 3 must be whole numbers between -2^53 and 2^53",
+		"prod(i, 1, 3.5, i)": "prod: bounds 1 and 3.5 must be whole numbers between -2^53 and 2^53",
+	} {
+		_, err := Evaluate(input)
+		if ErrorCode(err) != CodeOutsideDomain || err.Error() != msg+` in "`+input+`" at offset 0` {
+			t.Errorf("%s: error %v, want %q", input, err, msg)
+		}
+	}
+}
+
+func TestIterationLimits(t *testing.T) {
+	huge := mustParse(t, "sum(i, 1, 1e12, i)")
+	_, err := NewEvaluator(WithMaxOps(10000)).Eval(huge)
+	var budget *BudgetExceededError
+	if !errors.As(err, &budget) || budget.Limit != 10000 {
+		t.Errorf("sum over 1e12 numbers with a budget: error %v, want a *BudgetExceededError", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = NewEvaluator().EvalContext(ctx, huge)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("sum over 1e12 numbers with a deadline took %v", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.HasPrefix(err.Error(), "evaluation stopped after ") {
+		t.Errorf("sum over 1e12 numbers with a deadline: error %v", err)
+	}
+}
+
+// end of file
@@ -0,0 +1,286 @@
+package expressionparser
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonNode is the tagged-union wire form of an expression node, e.g.
+// {"type":"binary","op":"+","left":{...},"right":{...}}.
+type jsonNode struct {
+	Type    string      `json:"type"`
+	Value   *float64    `json:"value,omitempty"`
+	Imag    bool        `json:"imag,omitempty"`
+	Unit    string      `json:"unit,omitempty"`
+	Name    string      `json:"name,omitempty"`
+	String  *string     `json:"string,omitempty"`
+	Op      string      `json:"op,omitempty"`
+	Left    *jsonNode   `json:"left,omitempty"`
+	Right   *jsonNode   `json:"right,omitempty"`
+	Operand *jsonNode   `json:"operand,omitempty"`
+	Args    []*jsonNode `json:"args,omitempty"`
+	Elems   []*jsonNode `json:"elems,omitempty"`
+	Bound   *jsonNode   `json:"bound,omitempty"`
+	Body    *jsonNode   `json:"body,omitempty"`
+	Cond    *jsonNode   `json:"cond,omitempty"`
+	Then    *jsonNode   `json:"then,omitempty"`
+	Else    *jsonNode   `json:"else,omitempty"`
+}
+
+// Node type tags used in the JSON encoding
+const (
+	jsonNumber   = "number"
+	jsonQuantity = "quantity"
+	jsonVariable = "variable"
+	jsonString   = "string"
+	jsonBinary   = "binary"
+	jsonUnary    = "unary"
+	jsonCall     = "call"
+	jsonList     = "list"
+	jsonLet      = "let"
+	jsonAssign   = "assign"
+	jsonIf       = "if"
+)
+
+// EncodeJSON serializes an expression tree to JSON.
+func EncodeJSON(expr Expr) ([]byte, error) {
+	node, err := toJSONNode(expr)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(node)
+}
+
+// DecodeJSON rebuilds an expression tree from the output of EncodeJSON.
+func DecodeJSON(data []byte) (Expr, error) {
+	var node *jsonNode
+	if err := json.Unmarshal(data, &node); err != nil {
+		return nil, withCode(CodeDecode, fmt.Errorf("invalid expression JSON: %w", err))
+	}
+	return fromJSONNode(node, "$")
+}
+
+// toJSONNode converts an expression into its wire form.
+func toJSONNode(expr Expr) (*jsonNode, error) {
+	switch v := expr.(type) {
+	case *Number:
+		value := v.Value
+		return &jsonNode{Type: jsonNumber, Value: &value, Imag: v.Imag}, nil
+	case *QuantityLiteral:
+		value := v.Value
+		return &jsonNode{Type: jsonQuantity, Value: &value, Unit: v.Unit}, nil
+	case *Variable:
+		return &jsonNode{Type: jsonVariable, Name: v.Name}, nil
+	case *StringLiteral:
+		value := v.Value
+		return &jsonNode{Type: jsonString, String: &value}, nil
+	case *BinaryOp:
+		left, err := toJSONNode(v.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := toJSONNode(v.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonNode{Type: jsonBinary, Op: operatorSymbol(v.Op), Left: left, Right: right}, nil
+	case *UnaryOp:
+		operand, err := toJSONNode(v.Operand)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonNode{Type: jsonUnary, Op: operatorSymbol(v.Op), Operand: operand}, nil
+	case *FunctionCall:
+		node := &jsonNode{Type: jsonCall, Name: v.Name}
+		for _, arg := range v.Args {
+			a, err := toJSONNode(arg)
+			if err != nil {
+				return nil, err
+			}
+			node.Args = append(node.Args, a)
+		}
+		return node, nil
+	case *ListLiteral:
+		node := &jsonNode{Type: jsonList}
+		for _, elem := range v.Elems {
+			e, err := toJSONNode(elem)
+			if err != nil {
+				return nil, err
+			}
+			node.Elems = append(node.Elems, e)
+		}
+		return node, nil
+	case *Let:
+		value, err := toJSONNode(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		body, err := toJSONNode(v.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonNode{Type: jsonLet, Name: v.Name, Bound: value, Body: body}, nil
+	case *Assign:
+		value, err := toJSONNode(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonNode{Type: jsonAssign, Name: v.Name, Bound: value}, nil
+	case *Conditional:
+		cond, err := toJSONNode(v.Cond)
+		if err != nil {
+			return nil, err
+		}
+		then, err := toJSONNode(v.Then)
+		if err != nil {
+			return nil, err
+		}
+		els, err := toJSONNode(v.Else)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonNode{Type: jsonIf, Cond: cond, Then: then, Else: els}, nil
+	default:
+		return nil, withCode(CodeUnsupported, fmt.Errorf("cannot encode expression of type %T", expr))
+	}
+}
+
+// fromJSONNode converts a wire node back into an expression; path locates
+// the node in the document for error messages.
+func fromJSONNode(node *jsonNode, path string) (Expr, error) {
+	if node == nil {
+		return nil, withCode(CodeDecode, fmt.Errorf("missing expression node at %s", path))
+	}
+
+	switch node.Type {
+	case jsonNumber:
+		if node.Value == nil {
+			return nil, withCode(CodeDecode, fmt.Errorf("number node at %s has no value", path))
+		}
+		return &Number{Value: *node.Value, Imag: node.Imag}, nil
+	case jsonQuantity:
+		if node.Value == nil || node.Unit == "" {
+			return nil, withCode(CodeDecode, fmt.Errorf("quantity node at %s needs a value and a unit", path))
+		}
+		return &QuantityLiteral{Value: *node.Value, Unit: node.Unit}, nil
+	case jsonString:
+		if node.String == nil {
+			return nil, withCode(CodeDecode, fmt.Errorf("string node at %s has no string", path))
+		}
+		return &StringLiteral{Value: *node.String}, nil
+	case jsonVariable:
+		if node.Name == "" {
+			return nil, withCode(CodeDecode, fmt.Errorf("variable node at %s has no name", path))
+		}
+		return &Variable{Name: node.Name}, nil
+	case jsonBinary:
+		op, ok := binaryOperatorToken(node.Op)
+		if !ok {
+			return nil, withCode(CodeDecode, fmt.Errorf("unknown binary operator %q at %s", node.Op, path))
+		}
+		left, err := fromJSONNode(node.Left, path+".left")
+		if err != nil {
+			return nil, err
+		}
+		right, err := fromJSONNode(node.Right, path+".right")
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryOp{Left: left, Op: op, Right: right}, nil
+	case jsonUnary:
+		op, ok := unaryOperatorToken(node.Op)
+		if !ok {
+			return nil, withCode(CodeDecode, fmt.Errorf("unknown unary operator %q at %s", node.Op, path))
+		}
+		operand, err := fromJSONNode(node.Operand, path+".operand")
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryOp{Op: op, Operand: operand}, nil
+	case jsonCall:
+		if node.Name == "" {
+			return nil, withCode(CodeDecode, fmt.Errorf("call node at %s has no name", path))
+		}
+		call := &FunctionCall{Name: node.Name}
+		for i, a := range node.Args {
+			arg, err := fromJSONNode(a, fmt.Sprintf("%s.args[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+		}
+		return call, nil
+	case jsonList:
+		list := &ListLiteral{}
+		for i, e := range node.Elems {
+			elem, err := fromJSONNode(e, fmt.Sprintf("%s.elems[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			list.Elems = append(list.Elems, elem)
+		}
+		return list, nil
+	case jsonLet:
+		if node.Name == "" {
+			return nil, withCode(CodeDecode, fmt.Errorf("let node at %s has no name", path))
+		}
+		value, err := fromJSONNode(node.Bound, path+".bound")
+		if err != nil {
+			return nil, err
+		}
+		body, err := fromJSONNode(node.Body, path+".body")
+		if err != nil {
+			return nil, err
+		}
+		return &Let{Name: node.Name, Value: value, Body: body}, nil
+	case jsonAssign:
+		if node.Name == "" {
+			return nil, withCode(CodeDecode, fmt.Errorf("assign node at %s has no name", path))
+		}
+		value, err := fromJSONNode(node.Bound, path+".bound")
+		if err != nil {
+			return nil, err
+		}
+		return &Assign{Name: node.Name, Value: value}, nil
+	case jsonIf:
+		cond, err := fromJSONNode(node.Cond, path+".cond")
+		if err != nil {
+			return nil, err
+		}
+		then, err := fromJSONNode(node.Then, path+".then")
+		if err != nil {
+			return nil, err
+		}
+		els, err := fromJSONNode(node.Else, path+".else")
+		if err != nil {
+			return nil, err
+		}
+		return &Conditional{Cond: cond, Then: then, Else: els}, nil
+	case "":
+		return nil, withCode(CodeDecode, fmt.Errorf("expression node at %s has no type", path))
+	default:
+		return nil, withCode(CodeDecode, fmt.Errorf("unknown expression node type %q at %s", node.Type, path))
+	}
+}
+
+// binaryOperatorToken maps a printed binary operator back to its token.
+func binaryOperatorToken(symbol string) (Token, bool) {
+	for t, s := range operatorSymbols {
+		if s == symbol && precedence(t) != precLowest {
+			return Token{Type: t, Value: s}, true
+		}
+	}
+	return Token{}, false
+}
+
+// unaryOperatorToken maps a printed prefix operator back to its token.
+func unaryOperatorToken(symbol string) (Token, bool) {
+	for _, t := range []TokenType{MINUS, NOT} {
+		if symbol == operatorSymbols[t] {
+			return Token{Type: t, Value: symbol}, true
+		}
+	}
+	return Token{}, false
+}
+
+// end of file
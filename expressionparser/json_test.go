@@ -0,0 +1,82 @@
+package expressionparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	inputs := append([]string{
+		"let a = 2 in a * a",
+		`upper("a\tb") == "A\tB"`,
+		"10 km + 500 m",
+		"4i * 2",
+		"[1, x, [2]]",
+	}, evalCorpus...)
+	for _, input := range inputs {
+		expr, err := ParseString(input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		data, err := EncodeJSON(expr)
+		if err != nil {
+			t.Fatalf("EncodeJSON(%q): %v", input, err)
+		}
+		decoded, err := DecodeJSON(data)
+		if err != nil {
+			t.Fatalf("DecodeJSON(%s): %v", data, err)
+		}
+		if !Equal(decoded, expr) {
+			t.Errorf("%q decodes from %s as %s", input, data, ToSExpr(decoded))
+			continue
+		}
+		want, wantErr := EvalWithVars(expr, corpusVars)
+		got, gotErr := EvalWithVars(decoded, corpusVars)
+		// Decoded trees have no spans, so errors are the same but for offsets
+		if ErrorCode(gotErr) != ErrorCode(wantErr) || wantErr == nil && !sameResult(got, gotErr, want, wantErr) {
+			t.Errorf("%q evaluates to %v, %v decoded, want %v, %v", input, got, gotErr, want, wantErr)
+		}
+	}
+}
+
+func TestJSONRoundTripAssign(t *testing.T) {
+	assign := &Assign{Name: "x", Value: Add(Var("y"), Num(1))}
+	data, err := EncodeJSON(assign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeJSON(data)
+	if err != nil || !Equal(decoded, assign) {
+		t.Errorf("DecodeJSON(%s) = %v, %v, want %s", data, decoded, err, ToSExpr(assign))
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`{"type":`, "invalid expression JSON"},
+		{`null`, "missing expression node at $"},
+		{`{}`, "expression node at $ has no type"},
+		{`{"type":"matrix"}`, `unknown expression node type "matrix" at $`},
+		{`{"type":"number"}`, "number node at $ has no value"},
+		{`{"type":"variable"}`, "variable node at $ has no name"},
+		{`{"type":"binary","op":"@","left":{"type":"number","value":1},"right":{"type":"number","value":2}}`, `unknown binary operator "@" at $`},
+		{`{"type":"binary","op":"+","left":{"type":"number","value":1}}`, "missing expression node at $.right"},
+		{`{"type":"unary","op":"-","operand":{"type":"tensor"}}`, `unknown expression node type "tensor" at $.operand`},
+		{`{"type":"quantity","value":1}`, "quantity node at $ needs a value and a unit"},
+		{`{"type":"call","args":[]}`, "call node at $ has no name"},
+	}
+	for _, tt := range tests {
+		_, err := DecodeJSON([]byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("DecodeJSON(%s) = %v, want an error containing %q", tt.data, err, tt.want)
+		}
+		if ErrorCode(err) != CodeDecode {
+			t.Errorf("DecodeJSON(%s): code %s, want %s", tt.data, ErrorCode(err), CodeDecode)
+		}
+	}
+}
+
+// end of file
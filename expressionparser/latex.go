@@ -0,0 +1,182 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LaTeXOptions controls how expressions are rendered by Render.
+type LaTeXOptions struct {
+	// ImplicitMultiplication writes products by juxtaposition instead of \cdot,
+	// falling back to \cdot when the right operand starts with a digit.
+	ImplicitMultiplication bool
+}
+
+// Functions with a dedicated LaTeX operator name
+var latexOperators = map[string]string{
+	"sin": `\sin`, "cos": `\cos`, "tan": `\tan`,
+	"asin": `\arcsin`, "acos": `\arccos`, "atan": `\arctan`,
+	"exp": `\exp`, "ln": `\ln`, "log": `\log`,
+	"min": `\min`, "max": `\max`,
+}
+
+// LaTeX spellings of the comparison, logical, range, bitwise and match operators
+var latexSymbols = map[TokenType]string{
+	LE: `\le`, GE: `\ge`, EQ: "=", NE: `\ne`,
+	MOD: `\bmod`, AND: `\land`, OR: `\lor`, NOT: `\lnot `,
+	DOTDOT: `\ldots`, BITAND: `\mathbin{\&}`, BITOR: `\mathbin{|}`, BITXOR: `\oplus`,
+	SHL: `\ll`, SHR: `\gg`, MATCH: `\mathrel{=\sim}`,
+}
+
+// latexSymbol returns the LaTeX spelling of an operator token.
+func latexSymbol(op Token) string {
+	if s, ok := latexSymbols[op.Type]; ok {
+		return s
+	}
+	return operatorSymbol(op)
+}
+
+// ToLaTeX renders an expression as LaTeX math using the default options.
+func ToLaTeX(expr Expr) string {
+	return LaTeXOptions{}.Render(expr)
+}
+
+// Render renders an expression as LaTeX math. Division becomes \frac, powers
+// use ^{...}, and the remaining operators are bracketed with \left( \right)
+// by the same precedence rules as Format. A conditional becomes a cases
+// environment.
+func (o LaTeXOptions) Render(expr Expr) string {
+	switch v := expr.(type) {
+	case nil:
+		return ""
+	case *Number:
+		return imagSuffix(latexNumber(v.Value), v)
+	case *Variable:
+		return latexName(v.Name)
+	case *QuantityLiteral:
+		return latexNumber(v.Value) + `\,\mathrm{` + latexEscape(v.Unit) + `}`
+	case *StringLiteral:
+		return "\\text{``" + latexTextEscaper.Replace(v.Value) + "''}"
+	case *UnaryOp:
+		return latexSymbol(v.Op) + o.operand(v.Operand, exprPrecedence(v.Operand) < precUnary)
+	case *BinaryOp:
+		switch v.Op.Type {
+		case DIV:
+			return `\frac{` + o.Render(v.Left) + `}{` + o.Render(v.Right) + `}`
+		case POW:
+			return o.operand(v.Left, exprPrecedence(v.Left) <= precPower) + `^{` + o.Render(v.Right) + `}`
+		}
+		prec := precedence(v.Op.Type)
+		left := o.operand(v.Left, latexNeedsParens(v.Left, prec, false))
+		right := o.operand(v.Right, latexNeedsParens(v.Right, prec, true))
+		if v.Op.Type == MULT {
+			if o.ImplicitMultiplication && !startsWithDigit(right) {
+				return left + " " + right
+			}
+			return left + ` \cdot ` + right
+		}
+		return left + " " + latexSymbol(v.Op) + " " + right
+	case *FunctionCall:
+		args := make([]string, len(v.Args))
+		for i, arg := range v.Args {
+			args[i] = o.Render(arg)
+		}
+		switch {
+		case v.Name == "sqrt" && len(args) == 1:
+			return `\sqrt{` + args[0] + `}`
+		case v.Name == "abs" && len(args) == 1:
+			return `\left|` + args[0] + `\right|`
+		case v.Name == "log" && len(args) == 2:
+			return `\log_{` + args[1] + `}\left(` + args[0] + `\right)`
+		}
+		name, ok := latexOperators[v.Name]
+		if !ok {
+			name = `\operatorname{` + latexEscape(v.Name) + `}`
+		}
+		return name + `\left(` + strings.Join(args, ", ") + `\right)`
+	case *ListLiteral:
+		elems := make([]string, len(v.Elems))
+		for i, elem := range v.Elems {
+			elems[i] = o.Render(elem)
+		}
+		return `\left[` + strings.Join(elems, ", ") + `\right]`
+	case *Let:
+		return `\mathbf{let}\ ` + latexName(v.Name) + ` = ` + o.Render(v.Value) + `\ \mathbf{in}\ ` + o.Render(v.Body)
+	case *Assign:
+		return latexName(v.Name) + ` \leftarrow ` + o.Render(v.Value)
+	case *Conditional:
+		return `\begin{cases} ` + o.Render(v.Then) + ` & \text{if } ` + o.Render(v.Cond) +
+			` \\ ` + o.Render(v.Else) + ` & \text{otherwise} \end{cases}`
+	default:
+		return fmt.Sprintf(`\text{%T}`, expr)
+	}
+}
+
+// operand renders expr, bracketed when parens is set.
+func (o LaTeXOptions) operand(expr Expr, parens bool) string {
+	if parens {
+		return `\left(` + o.Render(expr) + `\right)`
+	}
+	return o.Render(expr)
+}
+
+// latexPrecedence is exprPrecedence with fractions treated as atoms, since
+// \frac groups its operands visually.
+func latexPrecedence(expr Expr) int {
+	if b, ok := expr.(*BinaryOp); ok && b.Op.Type == DIV {
+		return precAtom
+	}
+	return exprPrecedence(expr)
+}
+
+// latexNeedsParens is needsParens for operands that may be fractions.
+func latexNeedsParens(operand Expr, parentPrec int, right bool) bool {
+	if latexPrecedence(operand) == precAtom {
+		return false
+	}
+	return needsParens(operand, parentPrec, right)
+}
+
+// latexNumber renders a literal, spelling exponents as a power of ten.
+func latexNumber(v float64) string {
+	s := formatNumber(v)
+	if i := strings.IndexByte(s, 'e'); i >= 0 {
+		exp := strings.TrimPrefix(s[i+1:], "+")
+		return s[:i] + `\times 10^{` + exp + `}`
+	}
+	return s
+}
+
+// latexName renders a variable, using \mathit for multi-letter names.
+func latexName(name string) string {
+	if len(name) == 1 {
+		return name
+	}
+	return `\mathit{` + latexEscape(name) + `}`
+}
+
+// latexEscape escapes characters that are special in LaTeX text.
+func latexEscape(s string) string {
+	return strings.ReplaceAll(s, "_", `\_`)
+}
+
+// latexTextEscaper escapes every character that is special in LaTeX text mode.
+var latexTextEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`#`, `\#`,
+	`%`, `\%`,
+	`_`, `\_`,
+	`^`, `\textasciicircum{}`,
+	`~`, `\textasciitilde{}`,
+)
+
+// startsWithDigit reports whether rendered output begins with a digit.
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// end of file
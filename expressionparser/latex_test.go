@@ -0,0 +1,37 @@
+package expressionparser
+
+import "testing"
+
+func TestToLaTeXGolden(t *testing.T) {
+	runGolden(t, "latex", func(input string) string {
+		expr, err := ParseString(input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		return ToLaTeX(expr) + "\n"
+	})
+}
+
+func TestLaTeXImplicitMultiplication(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"2 * x", `2 x`},
+		{"x * 2", `x \cdot 2`},
+		{"a * b * c", `a b c`},
+		{"3 * (x + 1)", `3 \left(x + 1\right)`},
+	}
+	opts := LaTeXOptions{ImplicitMultiplication: true}
+	for _, tt := range tests {
+		expr, err := ParseString(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := opts.Render(expr); got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// end of file
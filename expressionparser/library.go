@@ -0,0 +1,174 @@
+package expressionparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Library is a set of named formulas that may use each other by name, as
+// in subtotal = price * qty and total = subtotal * (1 + tax). It is safe
+// for concurrent use.
+type Library struct {
+	mu       sync.RWMutex
+	opts     []Option
+	ev       *Evaluator
+	formulas map[string]*formula
+}
+
+// formula is the definition of a name in a Library.
+type formula struct {
+	source string
+	expr   Expr
+	refs   []string // the free variables of expr, defined in the library or not
+}
+
+// CycleError reports a definition that would make a formula depend on
+// itself. Path is the cycle, from the name defined back to itself.
+type CycleError struct {
+	Path []string
+}
+
+// Error gives the cycle, as in "reference cycle total → discount → total".
+func (e *CycleError) Error() string {
+	return "reference cycle " + strings.Join(e.Path, " → ")
+}
+
+// NewLibrary returns an empty library whose formulas are parsed and
+// evaluated with opts.
+func NewLibrary(opts ...Option) *Library {
+	opts = append([]Option(nil), opts...)
+	return &Library{opts: opts, ev: NewEvaluator(opts...), formulas: map[string]*formula{}}
+}
+
+// Define makes name the formula source, replacing any formula of that name.
+// The source is parsed at once, and a syntax error is returned by Define,
+// leaving the library unchanged. The names source uses are resolved when a
+// formula is evaluated, so formulas may be defined in any order and may
+// use names not yet defined; a definition that would make a formula depend
+// on itself, directly or through others, is rejected with a *CycleError.
+func (l *Library) Define(name, source string) error {
+	if !isUnitName(name) {
+		return withCode(CodeInvalidArgument, fmt.Errorf("invalid formula name %q", name))
+	}
+	expr, err := ParseString(source, l.opts...)
+	if err != nil {
+		return fmt.Errorf("formula %s: %w", name, err)
+	}
+	f := &formula{source: source, expr: expr, refs: Variables(expr)}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if path := l.cycle(name, f.refs, []string{name}); path != nil {
+		return &CycleError{Path: path}
+	}
+	l.formulas[name] = f
+	return nil
+}
+
+// cycle returns the path from start back to itself through refs, the
+// references of the last name of path, or nil when there is none.
+func (l *Library) cycle(start string, refs []string, path []string) []string {
+	for _, ref := range refs {
+		if ref == start {
+			return append(append([]string(nil), path...), ref)
+		}
+		f, ok := l.formulas[ref]
+		if !ok || contains(path, ref) {
+			continue
+		}
+		if found := l.cycle(start, f.refs, append(path, ref)); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Undefine removes the formula name, reporting whether there was one.
+// Formulas using the name are kept, and fail to evaluate unless it is
+// given a value.
+func (l *Library) Undefine(name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.formulas[name]
+	delete(l.formulas, name)
+	return ok
+}
+
+// Names returns the names of the formulas, sorted.
+func (l *Library) Names() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	names := make([]string, 0, len(l.formulas))
+	for name := range l.formulas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Source returns the source of the formula name.
+func (l *Library) Source(name string) (string, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	f, ok := l.formulas[name]
+	if !ok {
+		return "", false
+	}
+	return f.source, true
+}
+
+// Eval evaluates the formula name with vars as the values of the names it
+// uses that are not formulas; a name defined in the library is always its
+// formula, even when vars has a value for it. Each formula is evaluated at
+// most once per call however many others use it. An error in a formula is
+// prefixed with its name, its offsets being those in its own source; a
+// bool result is returned as 1 or 0, and a string result is an error.
+func (l *Library) Eval(name string, vars map[string]float64) (float64, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if _, ok := l.formulas[name]; !ok {
+		return 0, withCode(CodeUndefinedVar, fmt.Errorf("no formula named %s", name))
+	}
+	base := NewEnvironment(nil)
+	for k, v := range vars {
+		base.SetNumber(k, v)
+	}
+	value, err := l.eval(name, base, map[string]Value{})
+	if err != nil {
+		return 0, err
+	}
+	if !value.numeric() {
+		return 0, withCode(CodeType, fmt.Errorf("formula %s is a %s, not a number", name, value.kind))
+	}
+	return value.num, nil
+}
+
+// eval evaluates the formula name, first evaluating the formulas it uses
+// into done, with the values of other names taken from base.
+func (l *Library) eval(name string, base *Environment, done map[string]Value) (Value, error) {
+	if value, ok := done[name]; ok {
+		return value, nil
+	}
+	f := l.formulas[name]
+	env := NewEnvironment(base)
+	for _, ref := range f.refs {
+		if _, ok := l.formulas[ref]; !ok {
+			continue
+		}
+		value, err := l.eval(ref, base, done)
+		if err != nil {
+			return Value{}, err
+		}
+		env.Set(ref, value)
+	}
+	value, err := l.ev.EvalIn(f.expr, env)
+	if err != nil {
+		return Value{}, fmt.Errorf("formula %s: %w", name, err)
+	}
+	done[name] = value
+	return value, nil
+}
+
+// end of file
@@ -0,0 +1,120 @@
+package expressionparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLibrary(t *testing.T) {
+	l := NewLibrary()
+	// In any order: total comes before the formulas it uses
+	for _, def := range [][2]string{
+		{"total", "subtotal * (1 + tax) - discount"},
+		{"subtotal", "price * qty"},
+		{"discount", "subtotal > 100 ? subtotal * 0.1 : 0"},
+		{"big", "total > 200"},
+	} {
+		if err := l.Define(def[0], def[1]); err != nil {
+			t.Fatalf("Define(%s): %v", def[0], err)
+		}
+	}
+	tests := []struct {
+		name string
+		vars map[string]float64
+		want float64
+	}{
+		{"subtotal", map[string]float64{"price": 2.5, "qty": 4}, 10},
+		{"total", map[string]float64{"price": 2.5, "qty": 4, "tax": 0.5}, 15},
+		{"total", map[string]float64{"price": 50, "qty": 4, "tax": 0.25}, 230},
+		{"big", map[string]float64{"price": 50, "qty": 4, "tax": 0.25}, 1},
+		// A formula is always its definition
+		{"subtotal", map[string]float64{"price": 1, "qty": 1, "subtotal": 99}, 1},
+	}
+	for _, tt := range tests {
+		if got, err := l.Eval(tt.name, tt.vars); err != nil || got != tt.want {
+			t.Errorf("Eval(%s, %v) = %v, %v; want %v", tt.name, tt.vars, got, err, tt.want)
+		}
+	}
+	if got := strings.Join(l.Names(), " "); got != "big discount subtotal total" {
+		t.Errorf("Names() = %s", got)
+	}
+
+	// Redefining replaces
+	if err := l.Define("subtotal", "price * qty * 2"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := l.Eval("total", map[string]float64{"price": 2.5, "qty": 4, "tax": 0.5}); err != nil || got != 30 {
+		t.Errorf("total after redefining subtotal = %v, %v; want 30", got, err)
+	}
+	if src, ok := l.Source("subtotal"); !ok || src != "price * qty * 2" {
+		t.Errorf("Source(subtotal) = %q, %v", src, ok)
+	}
+}
+
+func TestLibraryEvaluatesOnce(t *testing.T) {
+	reads := 0
+	l := NewLibrary(WithBeforeNode(func(e Expr) error {
+		if v, ok := e.(*Variable); ok && v.Name == "price" {
+			reads++
+		}
+		return nil
+	}))
+	// base is used by a and b, both used by top
+	for _, def := range [][2]string{{"base", "price * 2"}, {"a", "base + 1"}, {"b", "base * 3"}, {"top", "a + b + base"}} {
+		if err := l.Define(def[0], def[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := l.Eval("top", map[string]float64{"price": 5}); err != nil || got != 51 || reads != 1 {
+		t.Errorf("Eval(top) = %v, %v, reading price %d times; want 51 once", got, err, reads)
+	}
+}
+
+func TestLibraryErrors(t *testing.T) {
+	l := NewLibrary()
+	l.Define("total", "subtotal - discount")
+	l.Define("discount", "rate * 2")
+
+	var cycle *CycleError
+	err := l.Define("rate", "total / 10")
+	if !errors.As(err, &cycle) || err.Error() != "reference cycle rate → total → discount → rate" {
+		t.Errorf("Define(rate) making a cycle: error %v", err)
+	}
+	if err := l.Define("self", "self + 1"); err == nil || err.Error() != "reference cycle self → self" {
+		t.Errorf("Define(self): error %v", err)
+	}
+	if _, ok := l.Source("rate"); ok {
+		t.Error("a rejected definition was kept")
+	}
+
+	// A syntax error is reported by Define
+	var parseErr *ParseError
+	if err := l.Define("bad", "1 +"); !errors.As(err, &parseErr) || !strings.HasPrefix(err.Error(), "formula bad: ") {
+		t.Errorf("Define(bad): error %v", err)
+	}
+	if err := l.Define("2x", "1"); ErrorCode(err) != CodeInvalidArgument {
+		t.Errorf("Define(2x): error %v, want %s", err, CodeInvalidArgument)
+	}
+
+	// Undefined references fail when evaluated, naming the formula
+	_, err = l.Eval("total", map[string]float64{"subtotal": 10})
+	if !errors.Is(err, ErrUndefinedVariable) || !strings.HasPrefix(err.Error(), "formula discount: undefined variable rate") {
+		t.Errorf("Eval(total) without rate: error %v", err)
+	}
+	if _, err := l.Eval("missing", nil); ErrorCode(err) != CodeUndefinedVar || err.Error() != "no formula named missing" {
+		t.Errorf("Eval(missing): error %v", err)
+	}
+	l.Define("word", `"text"`)
+	if _, err := l.Eval("word", nil); ErrorCode(err) != CodeType {
+		t.Errorf("Eval(word): error %v, want %s", err, CodeType)
+	}
+	if !l.Undefine("discount") || l.Undefine("discount") {
+		t.Error("Undefine(discount) twice")
+	}
+	if got, err := l.Eval("total", map[string]float64{"subtotal": 10, "discount": 3}); err != nil || got != 7 {
+		t.Errorf("Eval(total) after Undefine(discount) = %v, %v; want 7", got, err)
+	}
+}
+
+// end of file
@@ -0,0 +1,242 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Errors of an equation that SolveLinear finds no single solution of
+var (
+	ErrNoSolution        = errors.New("no solution")
+	ErrInfiniteSolutions = errors.New("infinitely many solutions")
+)
+
+// linearTolerance is how small, relative to the terms it is the difference
+// of, a coefficient of an equation must be for SolveLinear to take it as 0
+// when it solves in float64, so that sin(1)*x + x = (sin(1) + 1)*x has
+// infinitely many solutions.
+const linearTolerance = 1e-12
+
+// SolveLinear solves an equation such as "3*x + 2 = 11" or "(3+1)*x = 8":
+// two expressions joined by a single =, in which one variable, the unknown,
+// appears linearly, on either side or both. It returns the name of the
+// unknown and its value. The unknown appears linearly when it is only
+// added, subtracted, negated, multiplied or divided by what does not
+// depend on it, or raised to the literal power 1; otherwise, as in
+// "x*x = 4" or "sin(x) = 0", the error is "x appears non-linearly", with
+// the offset of the subexpression at fault. An equation whose sides differ
+// by a constant other than 0 whatever the unknown, such as "x = x + 1",
+// gives an error wrapping ErrNoSolution, and one whose sides are always
+// equal, such as "2*x = x + x", an error wrapping ErrInfiniteSolutions;
+// the name is returned with both.
+//
+// The equation is solved from the values of its sides at 0 and 1, which
+// give the coefficient of the unknown and the constant term. They are
+// exact rationals where EvalRat can evaluate the sides, so that a constant
+// as large as in "x - 1e20 = 0" cannot swamp the coefficient and
+// "x*0.1 = 0.3" gives 3; otherwise, as with sin, they are float64 values
+// and the solution is refined once at the estimate.
+func SolveLinear(input string) (name string, value float64, err error) {
+	defer recoverPanic(&err)
+	at, err := equalsSign(input)
+	if err != nil {
+		return "", 0, err
+	}
+	left, err := ParseString(input[:at])
+	if err != nil {
+		return "", 0, err
+	}
+	// Spaces keep the offsets of the right side those of the input
+	right, err := ParseString(strings.Repeat(" ", at+1) + input[at+1:])
+	if err != nil {
+		return "", 0, err
+	}
+
+	unknowns := Variables(left)
+	for _, v := range Variables(right) {
+		if !contains(unknowns, v) {
+			unknowns = append(unknowns, v)
+		}
+	}
+	if len(unknowns) == 0 {
+		return "", 0, withCode(CodeInvalidArgument, errors.New("SolveLinear: the equation has no unknown"))
+	}
+	if len(unknowns) > 1 {
+		return "", 0, withCode(CodeInvalidArgument, fmt.Errorf("SolveLinear: the equation has %d unknowns, %s, rather than one", len(unknowns), strings.Join(unknowns, ", ")))
+	}
+	name = unknowns[0]
+	for _, side := range []Expr{left, right} {
+		if d, node := linearDegree(side, name); d > 1 {
+			err := withCode(CodeNonLinear, fmt.Errorf("%s appears non-linearly", name))
+			if span := SpanOf(node); span != (Span{}) {
+				err = atOffset(err, span.Start)
+			}
+			return name, 0, err
+		}
+	}
+
+	if slope, intercept, ok := exactLinear(left, right, name); ok {
+		if slope.Sign() == 0 {
+			diff, _ := intercept.Float64()
+			return name, 0, noSingleSolution(name, diff)
+		}
+		value, _ = new(big.Rat).Quo(new(big.Rat).Neg(intercept), slope).Float64()
+		if !math.IsInf(value, 0) {
+			return name, value, nil
+		}
+	}
+
+	// The values of the sides at a value of the unknown
+	sides := func(x float64) (float64, float64, error) {
+		vars := map[string]float64{name: x}
+		l, err := EvalWithVars(left, vars)
+		if err != nil {
+			return 0, 0, err
+		}
+		r, err := EvalWithVars(right, vars)
+		return l, r, err
+	}
+	l0, r0, err := sides(0)
+	if err != nil {
+		return name, 0, err
+	}
+	l1, r1, err := sides(1)
+	if err != nil {
+		return name, 0, err
+	}
+	slope, intercept := (l1-l0)-(r1-r0), l0-r0
+	if math.Abs(slope) <= linearTolerance*math.Max(math.Abs(l1-l0), math.Abs(r1-r0)) {
+		if math.Abs(intercept) <= linearTolerance*math.Max(math.Abs(l0), math.Abs(r0)) {
+			intercept = 0
+		}
+		return name, 0, noSingleSolution(name, intercept)
+	}
+	value = -intercept / slope
+	if l, r, err := sides(value); err == nil && l != r {
+		if refined := value - (l-r)/slope; !math.IsNaN(refined) && !math.IsInf(refined, 0) {
+			value = refined
+		}
+	}
+	return name, value, nil
+}
+
+// exactLinear gives the coefficient of the unknown and the constant term of
+// left - right as rationals, with ok false when a side is not exact in
+// rational mode.
+func exactLinear(left, right Expr, name string) (slope, intercept *big.Rat, ok bool) {
+	ev := *defaultEvaluator
+	diff := func(x float64) *big.Rat {
+		ev.vars = map[string]float64{name: x}
+		l, err := ev.EvalRat(left)
+		if err != nil {
+			return nil
+		}
+		r, err := ev.EvalRat(right)
+		if err != nil {
+			return nil
+		}
+		return new(big.Rat).Sub(l, r)
+	}
+	at0, at1 := diff(0), diff(1)
+	if at0 == nil || at1 == nil {
+		return nil, nil, false
+	}
+	return new(big.Rat).Sub(at1, at0), at0, true
+}
+
+// noSingleSolution reports an equation whose sides differ by diff whatever
+// the unknown is.
+func noSingleSolution(name string, diff float64) error {
+	if diff == 0 {
+		return fmt.Errorf("%w: the sides are equal whatever %s is", ErrInfiniteSolutions, name)
+	}
+	return fmt.Errorf("%w: the sides differ by %s whatever %s is", ErrNoSolution, formatNumber(diff), name)
+}
+
+// equalsSign returns the offset of the one = of an equation, other than
+// those of let bindings.
+func equalsSign(input string) (int, error) {
+	at, count := -1, 0
+	l := NewLexer(input)
+	var prev [2]Token
+	for tok := l.NextToken(); tok.Type != EOF && tok.Type != INVALID; tok = l.NextToken() {
+		if tok.Type == ASSIGN && !(prev[0].Type == IDENT && prev[0].Value == "let" && prev[1].Type == IDENT) {
+			if count++; count == 2 {
+				return 0, &ParseError{Msg: "SolveLinear: expected one '=' but found another", Pos: tok.Pos}
+			}
+			at = tok.Pos
+		}
+		prev[0], prev[1] = prev[1], tok
+	}
+	if at < 0 {
+		return 0, &ParseError{Msg: "SolveLinear: expected an equation with '='", Pos: len(input)}
+	}
+	return at, nil
+}
+
+// linearDegree returns 0 when expr does not depend on name, 1 when it
+// depends on it linearly, and 2 otherwise, with the subexpression at which
+// it stops being linear.
+func linearDegree(expr Expr, name string) (int, Expr) {
+	switch v := expr.(type) {
+	case *Variable:
+		if v.Name == name {
+			return 1, nil
+		}
+		return 0, nil
+	case *UnaryOp:
+		if v.Op.Type == MINUS {
+			return linearDegree(v.Operand, name)
+		}
+	case *BinaryOp:
+		l, node := linearDegree(v.Left, name)
+		if l > 1 {
+			return l, node
+		}
+		r, node := linearDegree(v.Right, name)
+		if r > 1 {
+			return r, node
+		}
+		switch {
+		case l+r == 0:
+			return 0, nil
+		case v.Op.Type == PLUS || v.Op.Type == MINUS:
+			return 1, nil
+		case v.Op.Type == MULT && l+r == 1, v.Op.Type == DIV && r == 0:
+			return 1, nil
+		case v.Op.Type == POW && r == 0:
+			if n, ok := v.Right.(*Number); ok && n.Value == 1 && !n.Imag {
+				return 1, nil
+			}
+		}
+		return 2, v
+	case *Conditional:
+		if d, _ := linearDegree(v.Cond, name); d == 0 {
+			then, node := linearDegree(v.Then, name)
+			if then > 1 {
+				return then, node
+			}
+			els, node := linearDegree(v.Else, name)
+			if els > 1 {
+				return els, node
+			}
+			if then > els {
+				return then, nil
+			}
+			return els, nil
+		}
+	case *Let:
+		if d, _ := linearDegree(v.Value, name); d == 0 && v.Name != name {
+			return linearDegree(v.Body, name)
+		}
+	}
+	if contains(Variables(expr), name) {
+		return 2, expr
+	}
+	return 0, nil
+}
+
+// end of file
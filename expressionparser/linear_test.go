@@ -0,0 +1,98 @@
+package expressionparser
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSolveLinear(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"2*x + 3 = 7", 2},
+		{"7 = 2*x + 3", 2},
+		{"3*x + 2 = 11", 3},
+		{"x = 5", 5},
+		{"2*x = x + 4", 4}, // on both sides
+		{"x/4 = 2", 8},
+		{"-(x - 1) = 3", -2},
+		{"x^1 = 2", 2},
+		{"1e10*x = 1", 1e-10},
+		{"0.1*x + 0.2*x = 0.3", 1},
+		// Exact where the sides are rational, so a constant cannot cancel x
+		{"x - 1e20 = 0", 1e20},
+		{"1e20 + x = 1e20 + 5", 5},
+		{"x*0.1 = 0.3", 3},
+		{"sin(0)*x + x = 2", 2}, // sin has no rational value, so in float64
+		// Coefficients from subexpressions
+		{"(3+1)*x = 8", 2},
+		{"x * (2 + 3) = 10", 2},
+		{"let a = 2 in a*x = 4", 2},
+	}
+	for _, tt := range tests {
+		name, got, err := SolveLinear(tt.input)
+		if err != nil || name != "x" || got != tt.want {
+			t.Errorf("SolveLinear(%q) = %q, %v, %v; want x, %v", tt.input, name, got, err, tt.want)
+		}
+	}
+}
+
+func TestSolveLinearDegenerate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  error
+		msg   string
+	}{
+		{"x = x + 1", ErrNoSolution, "no solution: the sides differ by -1 whatever x is"},
+		{"2*x = x + x", ErrInfiniteSolutions, "infinitely many solutions: the sides are equal whatever x is"},
+		{"0.1*x + 0.2*x = 0.3*x", ErrInfiniteSolutions, "infinitely many solutions: the sides are equal whatever x is"},
+		{"x * 0 = 0", ErrInfiniteSolutions, "infinitely many solutions: the sides are equal whatever x is"},
+		{"sin(1)*x + x = (sin(1) + 1)*x", ErrInfiniteSolutions, "infinitely many solutions: the sides are equal whatever x is"},
+		{"x + 1e20 = x", ErrNoSolution, "no solution: the sides differ by 1e+20 whatever x is"},
+	}
+	for _, tt := range tests {
+		name, _, err := SolveLinear(tt.input)
+		if !errors.Is(err, tt.want) || name != "x" {
+			t.Errorf("SolveLinear(%q): %q, error %v; want x and %v", tt.input, name, err, tt.want)
+			continue
+		}
+		if err.Error() != tt.msg {
+			t.Errorf("SolveLinear(%q): error %q, want %q", tt.input, err, tt.msg)
+		}
+	}
+	if _, _, err := SolveLinear("x = x + 1"); ErrorCode(err) != CodeNoSolution {
+		t.Errorf("x = x + 1: code %s, want %s", ErrorCode(err), CodeNoSolution)
+	}
+	if _, _, err := SolveLinear("2*x = x + x"); ErrorCode(err) != CodeInfiniteSolutions {
+		t.Errorf("2*x = x + x: code %s, want %s", ErrorCode(err), CodeInfiniteSolutions)
+	}
+}
+
+func TestSolveLinearErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{"x*x = 4", CodeNonLinear, "x appears non-linearly at offset 0"},
+		{"3 + x*x = 4", CodeNonLinear, "x appears non-linearly at offset 4"},
+		{"1 = 2 + sin(x)", CodeNonLinear, "x appears non-linearly at offset 8"},
+		{"2*x + 3 = 7 + x^2", CodeNonLinear, "x appears non-linearly at offset 14"},
+		{"1/x = 2", CodeNonLinear, "x appears non-linearly at offset 0"},
+		{"2 ^ x = 8", CodeNonLinear, "x appears non-linearly at offset 0"},
+		{"x + y = 1", CodeInvalidArgument, "SolveLinear: the equation has 2 unknowns, x, y, rather than one"},
+		{"1 = 2", CodeInvalidArgument, "SolveLinear: the equation has no unknown"},
+		{"x == 2", CodeSyntax, "SolveLinear: expected an equation with '=' at offset 6"},
+		{"x = 1 = 2", CodeSyntax, "SolveLinear: expected one '=' but found another at offset 6"},
+		{"x + = 1", CodeUnexpectedToken, "expected number, name, string, '(', '[', or unary '-' or '!' but found end of input at offset 4"},
+	}
+	for _, tt := range tests {
+		_, _, err := SolveLinear(tt.input)
+		if ErrorCode(err) != tt.code || err.Error() != tt.msg {
+			t.Errorf("SolveLinear(%q): error %v [%s], want %q [%s]", tt.input, err, ErrorCode(err), tt.msg, tt.code)
+		}
+	}
+}
+
+// end of file
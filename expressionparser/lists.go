@@ -0,0 +1,281 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// listOf makes the value of a list literal from the values of its elements,
+// which must be numbers or bools; lists do not nest.
+func listOf(lit *ListLiteral, values []Value) (Value, error) {
+	items := make([]float64, len(values))
+	for i, v := range values {
+		if v.kind == ListKind {
+			pos := -1
+			if lit.Span != (Span{}) {
+				pos = SpanOf(lit.Elems[i]).Start
+			}
+			err := withCode(CodeType, fmt.Errorf("lists cannot be nested, and element %d is a list", i+1))
+			return Value{}, atOffset(err, pos)
+		}
+		if !v.numeric() {
+			kinds := make([]Kind, len(values))
+			for j, v := range values {
+				kinds[j] = v.kind
+			}
+			pos := -1
+			if lit.Span != (Span{}) {
+				pos = lit.Span.Start
+			}
+			return Value{}, &TypeError{Op: "[]", Operands: kinds, Pos: pos}
+		}
+		items[i] = v.num
+	}
+	return Value{kind: ListKind, list: &list{items: items}}, nil
+}
+
+// aggregate is a builtin reducing a list to a number. It takes a single
+// list or range, or one or more numbers spread as its arguments, unless
+// ranked is set: then it takes a list or range and a number, as in
+// percentile(xs, 90), or pair: then it takes two lists, as in dot(a, b).
+// One with mapped set gives a list rather than a number.
+type aggregate struct {
+	sig    builtin
+	fn     func(l *list) (float64, error)
+	ranked func(l *list, p float64) (float64, error)
+	pair   func(a, b *list) (float64, error)
+	mapped func(l *list) []float64
+}
+
+// Builtins aggregating lists, by name. A range is aggregated without
+// expanding it into its elements.
+var aggregates = map[string]aggregate{
+	"sum":        {sig: builtin{arity: 1, variadic: true}, fn: sumList},
+	"avg":        {sig: builtin{arity: 1, variadic: true}, fn: avgList},
+	"count":      {sig: builtin{arity: 1, variadic: true}, fn: countList},
+	"product":    {sig: builtin{arity: 1, variadic: true}, fn: productList},
+	"variance":   {sig: builtin{arity: 1, variadic: true}, fn: varianceOf("variance", true)},
+	"pvariance":  {sig: builtin{arity: 1, variadic: true}, fn: varianceOf("pvariance", false)},
+	"stddev":     {sig: builtin{arity: 1, variadic: true}, fn: stddevOf(varianceOf("stddev", true))},
+	"pstddev":    {sig: builtin{arity: 1, variadic: true}, fn: stddevOf(varianceOf("pstddev", false))},
+	"median":     {sig: builtin{arity: 1, variadic: true}, fn: medianList},
+	"percentile": {sig: builtin{arity: 2}, ranked: percentileList},
+	"dot":        {sig: builtin{arity: 2}, pair: dotLists},
+	"norm":       {sig: builtin{arity: 1, variadic: true}, fn: normList},
+	"cumsum":     {sig: builtin{arity: 1, variadic: true}, mapped: cumsumList},
+	"reverse":    {sig: builtin{arity: 1, variadic: true}, mapped: reverseList},
+}
+
+// sumList adds the elements of a list; the sum of an empty list is 0.
+func sumList(l *list) (float64, error) {
+	if l.isRange {
+		return float64(l.len()) * (l.lo + l.hi) / 2, nil
+	}
+	total := 0.0
+	for _, x := range l.items {
+		total += x
+	}
+	return total, nil
+}
+
+// avgList returns the mean of the elements of a list, which must not be empty.
+func avgList(l *list) (float64, error) {
+	n := l.len()
+	if n == 0 {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("avg of an empty list"))
+	}
+	if l.isRange {
+		return (l.lo + l.hi) / 2, nil
+	}
+	total, _ := sumList(l)
+	return total / float64(n), nil
+}
+
+// countList returns the number of elements of a list.
+func countList(l *list) (float64, error) {
+	return float64(l.len()), nil
+}
+
+// productList multiplies the elements of a list; the product of an empty
+// list is 1. A range containing 0 gives 0 at once, and the product of any
+// other range stops as soon as it overflows to infinity.
+func productList(l *list) (float64, error) {
+	if !l.isRange {
+		result := 1.0
+		for _, x := range l.items {
+			result *= x
+		}
+		return result, nil
+	}
+	n := l.len()
+	if n > 0 && l.lo <= 0 && l.hi >= 0 {
+		return 0, nil
+	}
+
+	// The range lies on one side of zero; multiply the magnitudes
+	result := 1.0
+	l.each(func(x float64) bool {
+		result *= math.Abs(x)
+		return !math.IsInf(result, 0)
+	})
+	if l.hi < 0 && n%2 == 1 {
+		result = -result
+	}
+	return result, nil
+}
+
+// varianceOf returns the variance of the elements of a list: that of a
+// sample, dividing by n - 1, or of a whole population, dividing by n. It
+// is computed in one pass by Welford's method, which unlike summing
+// squares keeps its precision when the elements lie far from zero. A
+// sample needs at least two elements and a population one; a range has
+// the closed form of consecutive integers.
+func varianceOf(name string, sample bool) func(l *list) (float64, error) {
+	return func(l *list) (float64, error) {
+		n := float64(l.len())
+		if n == 0 {
+			return 0, withCode(CodeOutsideDomain, fmt.Errorf("%s of an empty list", name))
+		}
+		if sample && n < 2 {
+			return 0, withCode(CodeOutsideDomain, fmt.Errorf("%s of a sample needs at least 2 elements", name))
+		}
+		if l.isRange {
+			if sample {
+				return n * (n + 1) / 12, nil
+			}
+			return (n*n - 1) / 12, nil
+		}
+
+		mean, squares := 0.0, 0.0
+		for i, x := range l.items {
+			d := x - mean
+			mean += d / float64(i+1)
+			squares += d * (x - mean)
+		}
+		if sample {
+			return squares / (n - 1), nil
+		}
+		return squares / n, nil
+	}
+}
+
+// stddevOf returns the square root of a variance.
+func stddevOf(variance func(l *list) (float64, error)) func(l *list) (float64, error) {
+	return func(l *list) (float64, error) {
+		v, err := variance(l)
+		return math.Sqrt(v), err
+	}
+}
+
+// medianList returns the middle element of a list, or the mean of the two
+// middle ones when it has an even number; an empty list is an error.
+func medianList(l *list) (float64, error) {
+	if l.len() == 0 {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("median of an empty list"))
+	}
+	return percentileList(l, 50)
+}
+
+// percentileList returns the p-th percentile of a list by linear
+// interpolation between the closest ranks, the method of Excel's
+// PERCENTILE.INC and R's default: the sorted elements x[0] to x[n-1] are
+// placed at 0 to 100 percent in equal steps, so that the percentile at
+// rank r = p/100 * (n-1) is x[floor(r)] + (r - floor(r)) * (x[floor(r)+1] -
+// x[floor(r)]). p must lie within 0..100 and the list must not be empty; a
+// NaN element gives NaN.
+func percentileList(l *list, p float64) (float64, error) {
+	n := l.len()
+	if !(p >= 0 && p <= 100) {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("percentile %s is outside 0..100", formatNumber(p)))
+	}
+	if n == 0 {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("percentile of an empty list"))
+	}
+	rank := p / 100 * float64(n-1)
+	if l.isRange {
+		return l.lo + rank, nil
+	}
+
+	xs := append([]float64(nil), l.items...)
+	for _, x := range xs {
+		if math.IsNaN(x) {
+			return x, nil
+		}
+	}
+	sort.Float64s(xs)
+	i := math.Floor(rank)
+	lo := xs[int(i)]
+	if int(i) == n-1 {
+		return lo, nil
+	}
+	return lo + (rank-i)*(xs[int(i)+1]-lo), nil
+}
+
+// applyAggregate applies an aggregate to the values of the arguments of a
+// call: a single list, or numbers, or a list and a number for a ranked one,
+// or two lists for a pair.
+func applyAggregate(call *FunctionCall, a aggregate, values []Value) (Value, error) {
+	if a.ranked != nil {
+		if values[0].kind != ListKind {
+			return Value{}, callTypeError(call, values, 0)
+		}
+		if !values[1].numeric() {
+			return Value{}, callTypeError(call, values, 1)
+		}
+		result, err := a.ranked(values[0].list, values[1].num)
+		if err != nil {
+			return Value{}, err
+		}
+		return NumberValue(result), nil
+	}
+	if a.pair != nil {
+		for i := range values {
+			if values[i].kind != ListKind {
+				return Value{}, callTypeError(call, values, i)
+			}
+			if err := checkExpandable(call, values[i].list); err != nil {
+				return Value{}, err
+			}
+		}
+		result, err := a.pair(values[0].list, values[1].list)
+		if err != nil {
+			return Value{}, err
+		}
+		return NumberValue(result), nil
+	}
+
+	l := &list{}
+	if len(values) == 1 && values[0].kind == ListKind {
+		l = values[0].list
+	} else {
+		args, err := numberArgs(call, values)
+		if err != nil {
+			return Value{}, err
+		}
+		l.items = args
+	}
+	if a.mapped != nil {
+		if err := checkExpandable(call, l); err != nil {
+			return Value{}, err
+		}
+		return Value{kind: ListKind, list: &list{items: a.mapped(l)}}, nil
+	}
+	result, err := a.fn(l)
+	if err != nil {
+		return Value{}, err
+	}
+	return NumberValue(result), nil
+}
+
+// checkExpandable checks that a list passed to a builtin that needs its
+// elements one by one, unlike the aggregates of a range, is not a range too
+// long to expand.
+func checkExpandable(call *FunctionCall, l *list) error {
+	if l.isRange && l.len() > maxElementwiseRange {
+		return withCode(CodeOutsideDomain, fmt.Errorf("%s: range of %d elements is too long, over %d", call.Name, l.len(), maxElementwiseRange))
+	}
+	return nil
+}
+
+// end of file
@@ -0,0 +1,142 @@
+package expressionparser
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAggregates(t *testing.T) {
+	env := map[string]Value{"xs": ListValue([]float64{2, 4, 9})}
+	tests := []struct {
+		input string
+		want  string // the result as Value.String renders it
+	}{
+		{"sum(1..100) == 5050", "true"},
+		{"sum(1..100)", "5050"},
+		{"sum([])", "0"},
+		{"count([])", "0"},
+		{"product([])", "1"},
+		{"sum(5..1)", "0"},
+		{"avg(1..4)", "2.5"},
+		{"product(1..5)", "120"},
+		{"count(1..1e15)", "1e+15"},
+		{"sum(1, 2, 3)", "6"},
+		{"avg(2, 4)", "3"},
+		{"count(1, 2, 3)", "3"},
+		{"product(2, 3)", "6"},
+		{"avg(xs)", "5"},
+		{"sum(xs) / count(xs) == avg(xs)", "true"},
+		{"sum(xs * 2)", "30"},
+	}
+	for _, tt := range tests {
+		got, err := EvalValue(mustParse(t, tt.input), env)
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+
+	errs := []struct {
+		input string
+		code  string
+	}{
+		{"avg([])", CodeOutsideDomain},
+		{"avg(5..1)", CodeOutsideDomain},
+		{"avg()", CodeArgumentCount},
+		{"sum([1, 2], 3)", CodeType},
+		{`sum("a")`, CodeType},
+	}
+	for _, tt := range errs {
+		if _, err := EvalValue(mustParse(t, tt.input), env); ErrorCode(err) != tt.code {
+			t.Errorf("%s: error %v, want %s", tt.input, err, tt.code)
+		}
+	}
+	if _, err := Evaluate("avg([])"); err == nil || err.Error() != `avg of an empty list in "avg([])" at offset 0` {
+		t.Errorf("avg([]): error %v", err)
+	}
+}
+
+func TestAggregateRangesUnexpanded(t *testing.T) {
+	expr := mustParse(t, "sum(1..1e6) + avg(1..1e6) + count(1..1e6)")
+	allocs := testing.AllocsPerRun(5, func() {
+		if _, err := Eval(expr); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 100 {
+		t.Errorf("aggregating ranges of a million numbers allocates %v times", allocs)
+	}
+}
+
+func TestStatistics(t *testing.T) {
+	env := map[string]Value{"xs": ListValue([]float64{2, 4, 4, 4, 5, 5, 7, 9})}
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"pvariance(xs)", 4},
+		{"variance(xs)", 32.0 / 7},
+		{"pstddev(xs)", 2},
+		{"stddev(xs) == sqrt(variance(xs))", 1},
+		{"variance(2, 4, 4, 4, 5, 5, 7, 9) == variance(xs)", 1},
+		{"median(xs)", 4.5},
+		{"median([3, 1, 2])", 2},
+		{"median(1..10)", 5.5},
+		{"pvariance([5])", 0},
+		// Linear interpolation between the closest ranks, p/100 * (n-1)
+		{"percentile(xs, 0)", 2},
+		{"percentile(xs, 25)", 4},
+		{"percentile(xs, 50)", 4.5},
+		{"percentile(xs, 100)", 9},
+		{"percentile([1, 2, 3, 4], 40)", 2.2},
+		{"percentile([7], 30)", 7},
+		{"percentile(1..101, 90)", 91},
+	}
+	for _, tt := range tests {
+		got, err := EvalValue(mustParse(t, tt.input), env)
+		if err != nil || math.Abs(got.num-tt.want) > 1e-12 {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	errs := map[string]string{
+		"variance([5])":       "variance of a sample needs at least 2 elements",
+		"stddev([5])":         "stddev of a sample needs at least 2 elements",
+		"variance([])":        "variance of an empty list",
+		"median([])":          "median of an empty list",
+		"percentile([], 5)":   "percentile of an empty list",
+		"percentile(xs, 101)": "percentile 101 is outside 0..100",
+	}
+	for input, msg := range errs {
+		if _, err := EvalValue(mustParse(t, input), env); err == nil || err.Error() != msg+` in "`+input+`" at offset 0` {
+			t.Errorf("%s: error %v, want %q", input, err, msg)
+		}
+	}
+}
+
+func TestVarianceLargeOffset(t *testing.T) {
+	// Around 1e9 the squares lose the digits the variance is made of
+	xs := []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16}
+	var sum, squares float64
+	for _, x := range xs {
+		sum += x
+		squares += x * x
+	}
+	n := float64(len(xs))
+	naive := (squares - sum*sum/n) / (n - 1)
+	if math.Abs(naive-30) < 1 {
+		t.Fatalf("the naive variance %v is accurate, so this test shows nothing", naive)
+	}
+	got, err := EvalValue(mustParse(t, "variance(xs)"), map[string]Value{"xs": ListValue(xs)})
+	if err != nil || got.num != 30 {
+		t.Errorf("variance around 1e9 = %v, %v; want 30", got, err)
+	}
+	if got, err := Evaluate("pstddev(1e12 + 1, 1e12 + 3)"); err != nil || got != 1 {
+		t.Errorf("pstddev(1e12 + 1, 1e12 + 3) = %v, %v; want 1", got, err)
+	}
+}
+
+// end of file
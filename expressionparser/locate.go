@@ -0,0 +1,36 @@
+package expressionparser
+
+// NodeAt returns the deepest node of a parsed tree whose span contains the
+// byte offset, together with its ancestors ordered from the root down to the
+// node's parent. An offset between tokens, such as a space, selects the
+// smallest node covering it. Offsets outside the root's span, and trees built
+// without spans, give nil results.
+func NodeAt(expr Expr, offset int) (Expr, []Expr) {
+	if expr == nil || !spanContains(SpanOf(expr), offset) {
+		return nil, nil
+	}
+
+	var ancestors []Expr
+	node := expr
+	for {
+		next := Expr(nil)
+		for _, child := range Children(node) {
+			if child != nil && spanContains(SpanOf(child), offset) {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return node, ancestors
+		}
+		ancestors = append(ancestors, node)
+		node = next
+	}
+}
+
+// spanContains reports whether offset lies in the half-open span.
+func spanContains(span Span, offset int) bool {
+	return span.Start <= offset && offset < span.End
+}
+
+// end of file
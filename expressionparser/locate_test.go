@@ -0,0 +1,65 @@
+package expressionparser
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNodeAt(t *testing.T) {
+	const input = "(2 + 34) * 5"
+	expr := mustParse(t, input)
+	sum := expr.(*BinaryOp).Left
+	tests := []struct {
+		offset    int
+		node      string // %T of the node
+		span      Span
+		ancestors []Expr
+	}{
+		{0, "*expressionparser.BinaryOp", Span{0, 8}, []Expr{expr}},    // (
+		{1, "*expressionparser.Number", Span{1, 2}, []Expr{expr, sum}}, // 2
+		{2, "*expressionparser.BinaryOp", Span{0, 8}, []Expr{expr}},    // space
+		{3, "*expressionparser.BinaryOp", Span{0, 8}, []Expr{expr}},    // +
+		{5, "*expressionparser.Number", Span{5, 7}, []Expr{expr, sum}}, // 3
+		{6, "*expressionparser.Number", Span{5, 7}, []Expr{expr, sum}}, // 4
+		{7, "*expressionparser.BinaryOp", Span{0, 8}, []Expr{expr}},    // )
+		{8, "*expressionparser.BinaryOp", Span{0, 12}, nil},            // space
+		{9, "*expressionparser.BinaryOp", Span{0, 12}, nil},            // *
+		{11, "*expressionparser.Number", Span{11, 12}, []Expr{expr}},   // 5
+	}
+	for _, tt := range tests {
+		node, ancestors := NodeAt(expr, tt.offset)
+		if got := fmt.Sprintf("%T", node); got != tt.node {
+			t.Errorf("NodeAt(%q, %d) is a %s, want %s", input, tt.offset, got, tt.node)
+			continue
+		}
+		if got := SpanOf(node); got != tt.span {
+			t.Errorf("NodeAt(%q, %d) spans %v, want %v", input, tt.offset, got, tt.span)
+		}
+		if len(ancestors) != len(tt.ancestors) {
+			t.Errorf("NodeAt(%q, %d) has %d ancestors, want %d", input, tt.offset, len(ancestors), len(tt.ancestors))
+			continue
+		}
+		for i := range ancestors {
+			if ancestors[i] != tt.ancestors[i] {
+				t.Errorf("NodeAt(%q, %d): ancestor %d is %s, want %s", input, tt.offset, i, Format(ancestors[i]), Format(tt.ancestors[i]))
+			}
+		}
+	}
+}
+
+func TestNodeAtOutside(t *testing.T) {
+	expr := mustParse(t, "(2 + 34) * 5")
+	for _, offset := range []int{-1, 12, 100} {
+		if node, ancestors := NodeAt(expr, offset); node != nil || ancestors != nil {
+			t.Errorf("NodeAt(%d) = %v, %v; want nil", offset, node, ancestors)
+		}
+	}
+	if node, _ := NodeAt(Add(Num(2), Num(3)), 0); node != nil {
+		t.Errorf("NodeAt of a built tree = %v, want nil", node)
+	}
+	if node, _ := NodeAt(nil, 0); node != nil {
+		t.Errorf("NodeAt(nil) = %v, want nil", node)
+	}
+}
+
+// end of file
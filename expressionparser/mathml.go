@@ -0,0 +1,160 @@
+package expressionparser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// ToMathML renders an expression as Presentation MathML. Division becomes
+// <mfrac>, powers <msup>, multiplication the dot operator &#x22C5; and calls
+// <mi>name</mi><mfenced>...</mfenced>. Parentheses follow the same rules as
+// ToLaTeX. The output is well-formed XML and deterministic.
+func ToMathML(expr Expr) string {
+	var sb strings.Builder
+	sb.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML">`)
+	sb.WriteString("<mrow>")
+	writeMathML(&sb, expr)
+	sb.WriteString("</mrow></math>")
+	return sb.String()
+}
+
+// writeMathML appends the MathML for expr to sb.
+func writeMathML(sb *strings.Builder, expr Expr) {
+	switch v := expr.(type) {
+	case nil:
+		return
+	case *Number:
+		if v.Value < 0 {
+			sb.WriteString("<mrow><mo>-</mo>")
+			writeMathML(sb, &Number{Value: -v.Value, Imag: v.Imag})
+			sb.WriteString("</mrow>")
+			return
+		}
+		if v.Imag {
+			sb.WriteString("<mrow>")
+			mathMLElement(sb, "mn", formatNumber(v.Value))
+			sb.WriteString("<mo>&InvisibleTimes;</mo>")
+			mathMLElement(sb, "mi", "i")
+			sb.WriteString("</mrow>")
+			return
+		}
+		mathMLElement(sb, "mn", formatNumber(v.Value))
+	case *QuantityLiteral:
+		sb.WriteString("<mrow>")
+		mathMLElement(sb, "mn", formatNumber(v.Value))
+		sb.WriteString(`<mspace width="0.2em"/><mi mathvariant="normal">`)
+		xml.EscapeText(sb, []byte(v.Unit))
+		sb.WriteString("</mi></mrow>")
+	case *StringLiteral:
+		mathMLElement(sb, "ms", v.Value)
+	case *Variable:
+		mathMLElement(sb, "mi", v.Name)
+	case *UnaryOp:
+		sb.WriteString("<mrow>")
+		mathMLElement(sb, "mo", operatorSymbol(v.Op))
+		mathMLOperand(sb, v.Operand, exprPrecedence(v.Operand) < precUnary)
+		sb.WriteString("</mrow>")
+	case *BinaryOp:
+		switch v.Op.Type {
+		case DIV:
+			sb.WriteString("<mfrac>")
+			mathMLOperand(sb, v.Left, false)
+			mathMLOperand(sb, v.Right, false)
+			sb.WriteString("</mfrac>")
+			return
+		case POW:
+			sb.WriteString("<msup>")
+			mathMLOperand(sb, v.Left, exprPrecedence(v.Left) <= precPower)
+			mathMLOperand(sb, v.Right, false)
+			sb.WriteString("</msup>")
+			return
+		}
+		prec := precedence(v.Op.Type)
+		sb.WriteString("<mrow>")
+		mathMLOperand(sb, v.Left, latexNeedsParens(v.Left, prec, false))
+		if v.Op.Type == MULT {
+			sb.WriteString("<mo>&#x22C5;</mo>")
+		} else {
+			mathMLElement(sb, "mo", operatorSymbol(v.Op))
+		}
+		mathMLOperand(sb, v.Right, latexNeedsParens(v.Right, prec, true))
+		sb.WriteString("</mrow>")
+	case *FunctionCall:
+		if v.Name == "sqrt" && len(v.Args) == 1 {
+			sb.WriteString("<msqrt>")
+			writeMathML(sb, v.Args[0])
+			sb.WriteString("</msqrt>")
+			return
+		}
+		args := v.Args
+		sb.WriteString("<mrow>")
+		if v.Name == "log" && len(args) == 2 {
+			// The base is written as a subscript
+			sb.WriteString("<msub>")
+			mathMLElement(sb, "mi", v.Name)
+			writeMathML(sb, args[1])
+			sb.WriteString("</msub>")
+			args = args[:1]
+		} else {
+			mathMLElement(sb, "mi", v.Name)
+		}
+		sb.WriteString("<mfenced>")
+		for _, arg := range args {
+			mathMLOperand(sb, arg, false)
+		}
+		sb.WriteString("</mfenced></mrow>")
+	case *ListLiteral:
+		sb.WriteString(`<mfenced open="[" close="]">`)
+		for _, elem := range v.Elems {
+			mathMLOperand(sb, elem, false)
+		}
+		sb.WriteString("</mfenced>")
+	case *Let:
+		sb.WriteString("<mrow><mtext>let</mtext>")
+		mathMLElement(sb, "mi", v.Name)
+		sb.WriteString("<mo>=</mo>")
+		mathMLOperand(sb, v.Value, false)
+		sb.WriteString("<mtext>in</mtext>")
+		mathMLOperand(sb, v.Body, false)
+		sb.WriteString("</mrow>")
+	case *Assign:
+		sb.WriteString("<mrow>")
+		mathMLElement(sb, "mi", v.Name)
+		sb.WriteString("<mo>=</mo>")
+		mathMLOperand(sb, v.Value, false)
+		sb.WriteString("</mrow>")
+	case *Conditional:
+		sb.WriteString("<mrow>")
+		mathMLOperand(sb, v.Cond, exprPrecedence(v.Cond) <= precConditional)
+		sb.WriteString("<mo>?</mo>")
+		mathMLOperand(sb, v.Then, false)
+		sb.WriteString("<mo>:</mo>")
+		mathMLOperand(sb, v.Else, exprPrecedence(v.Else) < precConditional)
+		sb.WriteString("</mrow>")
+	default:
+		mathMLElement(sb, "mtext", fmt.Sprintf("%T", expr))
+	}
+}
+
+// mathMLOperand appends expr as a single <mrow>, bracketed when parens is set.
+func mathMLOperand(sb *strings.Builder, expr Expr, parens bool) {
+	sb.WriteString("<mrow>")
+	if parens {
+		sb.WriteString("<mo>(</mo>")
+	}
+	writeMathML(sb, expr)
+	if parens {
+		sb.WriteString("<mo>)</mo>")
+	}
+	sb.WriteString("</mrow>")
+}
+
+// mathMLElement appends <tag>text</tag> with the text XML-escaped.
+func mathMLElement(sb *strings.Builder, tag, text string) {
+	sb.WriteString("<" + tag + ">")
+	xml.EscapeText(sb, []byte(text))
+	sb.WriteString("</" + tag + ">")
+}
+
+// end of file
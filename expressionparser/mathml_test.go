@@ -0,0 +1,70 @@
+package expressionparser
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+// wellFormed returns the error of decoding doc as XML, or nil.
+func wellFormed(doc string) error {
+	d := xml.NewDecoder(strings.NewReader(doc))
+	d.Strict = true
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func TestToMathMLWellFormed(t *testing.T) {
+	inputs := append([]string{
+		"x < 1 && y > 2",
+		"a & b | c ~ d << 2",
+		`"<b>&amp;" + "'q'"`,
+		"x > 0 ? x : -x",
+		"-2 ^ -x",
+		"(a + 1) / (b / (c - 1))",
+		"let a = 2 in a * a",
+		"10 km + 500 m",
+	}, evalCorpus...)
+	for _, input := range inputs {
+		expr, err := ParseString(input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		doc := ToMathML(expr)
+		if err := wellFormed(doc); err != nil {
+			t.Errorf("ToMathML(%q) is not well-formed: %v\n%s", input, err, doc)
+		}
+		if doc != ToMathML(expr) {
+			t.Errorf("ToMathML(%q) differs between calls", input)
+		}
+	}
+}
+
+func TestToMathML(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1 / x", "<mfrac><mrow><mn>1</mn></mrow><mrow><mi>x</mi></mrow></mfrac>"},
+		{"x ^ 2", "<msup><mrow><mi>x</mi></mrow><mrow><mn>2</mn></mrow></msup>"},
+		{"x < 1", "<mrow><mrow><mi>x</mi></mrow><mo>&lt;</mo><mrow><mn>1</mn></mrow></mrow>"},
+	}
+	for _, tt := range tests {
+		expr, err := ParseString(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `<math xmlns="http://www.w3.org/1998/Math/MathML"><mrow>` + tt.want + "</mrow></math>"
+		if got := ToMathML(expr); got != want {
+			t.Errorf("ToMathML(%q) = %q, want %q", tt.input, got, want)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,196 @@
+package expressionparser
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+)
+
+// WithMemoization makes Eval, EvalValue and EvalContext compute each
+// distinct subtree once per evaluation: a subtree structurally identical to
+// one already evaluated under the same let bindings takes the value found
+// the first time, which pays off with generated formulas that repeat large
+// subexpressions. Subtrees calling impure functions, such as rand or those
+// registered with RegisterFunc, are always evaluated. A subtree taken from
+// the cache counts as a single operation for WithMaxOps, and EvalTrace does
+// not memoize. The evaluator keeps the hashes of the last tree it evaluated
+// to find its repeated subtrees, so that tree must not be modified in place
+// afterwards.
+func WithMemoization() Option {
+	return func(c *config) {
+		c.memoize = true
+	}
+}
+
+// memo caches the values of subtrees during one evaluation. Subtrees are
+// found by a structural hash and then compared in full, so hash collisions
+// cannot mix up values.
+type memo struct {
+	hashes  map[Expr]uint64 // of the subtrees worth caching; read only
+	entries map[memoKey][]memoEntry
+}
+
+// memoKey locates the cached values of subtrees with one hash evaluated in one scope.
+type memoKey struct {
+	hash uint64
+	env  *scope
+}
+
+// memoEntry is a cached subtree and its value.
+type memoEntry struct {
+	expr  Expr
+	value Value
+}
+
+// memoIndex keeps the subtree hashes of the tree an evaluator memoized
+// last, so that evaluating the same tree again, as is usual with a
+// formula and changing variables, does not hash it again.
+type memoIndex struct {
+	mu     sync.Mutex
+	root   Expr
+	hashes map[Expr]uint64
+}
+
+// newMemo prepares a cache for evaluating expr.
+func (ev *Evaluator) newMemo(expr Expr) *memo {
+	index := ev.memoIndex
+	index.mu.Lock()
+	if index.root != expr {
+		index.root, index.hashes = expr, ev.hashSubtrees(expr)
+	}
+	hashes := index.hashes
+	index.mu.Unlock()
+	return &memo{hashes: hashes, entries: map[memoKey][]memoEntry{}}
+}
+
+// hashSubtrees returns the hashes of the subtrees of expr worth caching:
+// those that have children, call no impure function and occur more than
+// once.
+func (ev *Evaluator) hashSubtrees(expr Expr) map[Expr]uint64 {
+	size := countNodes(expr)
+	hashes := make(map[Expr]uint64, size)
+	counts := make(map[uint64]int, size)
+	ev.hashSubtree(expr, hashes, counts)
+	for e, hash := range hashes {
+		if counts[hash] < 2 {
+			delete(hashes, e)
+		}
+	}
+	return hashes
+}
+
+// countNodes returns the number of nodes in expr.
+func countNodes(expr Expr) int {
+	n := 1
+	for _, child := range Children(expr) {
+		n += countNodes(child)
+	}
+	return n
+}
+
+// hashSubtree hashes expr and its subtrees bottom-up, recording the
+// hashes of those that have children and no impure calls and counting how
+// often each occurs. It returns the hash of expr and whether it is pure.
+// The hashes only have to be good enough to spread the subtrees, as lookups
+// compare them in full; unlike Hash they are cheap to combine.
+func (ev *Evaluator) hashSubtree(expr Expr, hashes map[Expr]uint64, counts map[uint64]int) (uint64, bool) {
+	pure := true
+	var sum uint64
+	switch v := expr.(type) {
+	case *Number:
+		tag := tagNumber
+		if v.Imag {
+			tag = tagImaginary
+		}
+		sum = mix(uint64(tag), math.Float64bits(v.Value))
+	case *QuantityLiteral:
+		sum = mix(mix(uint64(tagQuantity), math.Float64bits(v.Value)), maphash.String(memoSeed, v.Unit))
+	case *Variable:
+		sum = mix(uint64(tagVariable), maphash.String(memoSeed, v.Name))
+	case *CellRef:
+		sum = mix(uint64(tagCell), maphash.String(memoSeed, v.Ref))
+	case *CellRange:
+		sum = mix(mix(uint64(tagCellRange), maphash.String(memoSeed, v.From)), maphash.String(memoSeed, v.To))
+	case *StringLiteral:
+		sum = mix(uint64(tagString), maphash.String(memoSeed, v.Value))
+	case *BinaryOp:
+		sum = mix(uint64(tagBinary), uint64(v.Op.Type))
+	case *UnaryOp:
+		sum = mix(uint64(tagUnary), uint64(v.Op.Type))
+	case *FunctionCall:
+		sum = mix(uint64(tagCall), maphash.String(memoSeed, v.Name))
+		b, registered := ev.funcs[v.Name]
+		if !registered {
+			b, registered = lookupBuiltin(v.Name)
+		}
+		pure = registered && !b.impure
+	case *ListLiteral:
+		sum = mix(uint64(tagList), uint64(len(v.Elems)))
+	case *Let:
+		sum = mix(uint64(tagLet), maphash.String(memoSeed, v.Name))
+	case *Assign:
+		sum = mix(uint64(tagAssign), maphash.String(memoSeed, v.Name))
+	case *Conditional:
+		sum = uint64(tagConditional)
+	}
+
+	children := Children(expr)
+	for _, child := range children {
+		childSum, childPure := ev.hashSubtree(child, hashes, counts)
+		sum = mix(sum, childSum)
+		pure = pure && childPure
+	}
+	if pure && len(children) > 0 {
+		hashes[expr] = sum
+		counts[sum]++
+	}
+	return sum, pure
+}
+
+// Seed of the hashes of names in memo indexes
+var memoSeed = maphash.MakeSeed()
+
+// mix combines x into the hash h.
+func mix(h, x uint64) uint64 {
+	h = (h ^ x) * 0x9e3779b97f4a7c15
+	return h ^ h>>29
+}
+
+// lookup returns the cached value of expr in env.
+func (m *memo) lookup(expr Expr, env *scope) (Value, bool) {
+	hash, ok := m.hashes[expr]
+	if !ok {
+		return Value{}, false
+	}
+	for _, entry := range m.entries[memoKey{hash, env}] {
+		if entry.expr == expr || (Equal(entry.expr, expr) && sameNumbers(entry.expr, expr)) {
+			return entry.value, true
+		}
+	}
+	return Value{}, false
+}
+
+// store caches the value of expr in env.
+func (m *memo) store(expr Expr, env *scope, value Value) {
+	if hash, ok := m.hashes[expr]; ok {
+		key := memoKey{hash, env}
+		m.entries[key] = append(m.entries[key], memoEntry{expr, value})
+	}
+}
+
+// sameNumbers reports whether the literals of two Equal trees have the same
+// bits, which Equal does not check of 0 and -0 although 1/0 and 1/-0 differ.
+func sameNumbers(a, b Expr) bool {
+	if x, ok := a.(*Number); ok {
+		return math.Float64bits(x.Value) == math.Float64bits(b.(*Number).Value)
+	}
+	bs := Children(b)
+	for i, child := range Children(a) {
+		if !sameNumbers(child, bs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// end of file
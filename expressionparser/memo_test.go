@@ -0,0 +1,88 @@
+package expressionparser
+
+import (
+	"strings"
+	"testing"
+)
+
+// repeatedSubtree builds an expression adding n copies of a costly subtree.
+func repeatedSubtree(n int) string {
+	const subtree = "(sqrt(x * x + y * y) + sin(x) * cos(y) + exp(-x / n))"
+	return strings.TrimSuffix(strings.Repeat(subtree+" + ", n), " + ")
+}
+
+func TestMemoizationMatchesEval(t *testing.T) {
+	corpus := append([]string{
+		"(x + y) * (x + y) - (x + y) / 2",
+		"let a = x in (a + 1) * (let a = y in a + 1) + (a + 1)", // same subtree, different bindings
+		"sum(i, 1, 3, (x + i) * (x + i))",
+		"(x / zero) + (x / zero)",
+		"zero && (1 / zero) || (1 / zero)",
+		repeatedSubtree(12),
+	}, evalCorpus...)
+	plain, memoized := NewEvaluator(), NewEvaluator(WithMemoization())
+	plain.SetVars(corpusVars)
+	memoized.SetVars(corpusVars)
+	for _, input := range corpus {
+		expr := mustParse(t, input)
+		want, wantErr := plain.Eval(expr)
+		got, gotErr := memoized.Eval(expr)
+		if !sameResult(got, gotErr, want, wantErr) {
+			t.Errorf("%q: memoized %v, %v; want %v, %v", input, got, gotErr, want, wantErr)
+		}
+	}
+}
+
+func TestMemoizationSkipsImpure(t *testing.T) {
+	ev := NewEvaluator(WithMemoization())
+	ev.SetSeed(1)
+	if got, err := ev.Eval(mustParse(t, "rand() - rand()")); err != nil || got == 0 {
+		t.Errorf("rand() - rand() memoized = %v, %v; want two draws", got, err)
+	}
+	calls := 0
+	ev.RegisterFunc("count", func(args ...float64) (float64, error) {
+		calls++
+		return args[0], nil
+	})
+	if _, err := ev.Eval(mustParse(t, "count(1) + count(1) + count(1)")); err != nil || calls != 3 {
+		t.Errorf("count(1) three times memoized: %d calls, %v; want 3", calls, err)
+	}
+}
+
+func TestMemoizationBudget(t *testing.T) {
+	// The second (1 + 2) * 3 is taken from the cache as one operation
+	expr := mustParse(t, "(1 + 2) * 3 + (1 + 2) * 3")
+	if _, err := NewEvaluator(WithMemoization(), WithMaxOps(7)).Eval(expr); err != nil {
+		t.Errorf("memoized with a budget of 7: %v", err)
+	}
+	if _, err := NewEvaluator(WithMaxOps(7)).Eval(expr); ErrorCode(err) != CodeLimitOps {
+		t.Errorf("not memoized with a budget of 7: error %v, want %s", err, CodeLimitOps)
+	}
+}
+
+func BenchmarkMemoization(b *testing.B) {
+	expr, err := ParseString(repeatedSubtree(32))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"memoized", []Option{WithMemoization()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			ev := NewEvaluator(bench.opts...)
+			ev.SetVars(corpusVars)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ev.Eval(expr); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// end of file
@@ -0,0 +1,177 @@
+package expressionparser
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Renderer turns the code and parameters of an error, as ErrorCode and
+// ErrorParams give them, into a message, such as a translation for the
+// user's language.
+type Renderer interface {
+	Render(code string, params map[string]string) string
+}
+
+// English renders errors as their Error methods do, from the "message"
+// parameter.
+var English Renderer = english{}
+
+type english struct{}
+
+// Render returns the message parameter.
+func (english) Render(code string, params map[string]string) string {
+	return params["message"]
+}
+
+// RenderError renders err with r; with English it gives err.Error(). It
+// returns "" for nil.
+func RenderError(err error, r Renderer) string {
+	if err == nil {
+		return ""
+	}
+	return r.Render(ErrorCode(err), ErrorParams(err))
+}
+
+// ErrorParams returns the parameters of the message of err, for a Renderer
+// to put in a translation: the first error in its chain with parameters
+// gives them, such as "name" for an undefined variable, "expected" and
+// "found" for an unexpected token, "expr" for the subexpression of an
+// *EvalError, and "pos" for the offset, when it is known. "message" is
+// always err.Error().
+func ErrorParams(err error) map[string]string {
+	params := map[string]string{}
+	var p interface{ messageParams() map[string]string }
+	if errors.As(err, &p) {
+		for k, v := range p.messageParams() {
+			params[k] = v
+		}
+	}
+	params["message"] = err.Error()
+	return params
+}
+
+// Catalog is a Renderer of the messages of one language: the templates
+// registered for codes, in which {name} stands for the parameter name, as
+// in "Variable {name} ist nicht definiert (Position {pos})". Errors with
+// codes it has no template for are rendered by its fallback.
+type Catalog struct {
+	templates map[string]string
+	fallback  Renderer
+}
+
+// NewCatalog returns an empty catalog, rendering every error with fallback
+// until templates are registered, or English when fallback is nil.
+func NewCatalog(fallback Renderer) *Catalog {
+	if fallback == nil {
+		fallback = English
+	}
+	return &Catalog{templates: map[string]string{}, fallback: fallback}
+}
+
+// Register sets the template of the code, replacing any registered before.
+func (c *Catalog) Register(code, template string) {
+	c.templates[code] = template
+}
+
+// Render fills in the template of the code with params, leaving in place
+// the placeholders of parameters the error does not have.
+func (c *Catalog) Render(code string, params map[string]string) string {
+	template, ok := c.templates[code]
+	if !ok {
+		return c.fallback.Render(code, params)
+	}
+	pairs := make([]string, 0, 2*len(params))
+	for k, v := range params {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// messageParams gives the parameters of the parse error and its offset.
+func (e *ParseError) messageParams() map[string]string {
+	params := map[string]string{"pos": strconv.Itoa(e.Pos)}
+	for k, v := range e.params {
+		params[k] = v
+	}
+	return params
+}
+
+// messageParams gives the parameters of the cause, its message as
+// "cause", and the subexpression and offset.
+func (e *EvalError) messageParams() map[string]string {
+	params := ErrorParams(e.Err)
+	if p, ok := e.Err.(interface{ withoutOffset() string }); ok {
+		params["cause"] = p.withoutOffset()
+	} else {
+		params["cause"] = params["message"]
+	}
+	delete(params, "message")
+	params["expr"] = Format(e.Expr)
+	setPos(params, e.Pos)
+	return params
+}
+
+// messageParams gives the name and offset of the variable.
+func (e *UndefinedVariableError) messageParams() map[string]string {
+	params := map[string]string{"name": e.Name}
+	if e.Span != (Span{}) {
+		params["pos"] = strconv.Itoa(e.Span.Start)
+	}
+	return params
+}
+
+// messageParams gives the operator, the operand types and the offset.
+func (e *TypeError) messageParams() map[string]string {
+	kinds := make([]string, len(e.Operands))
+	for i, k := range e.Operands {
+		kinds[i] = k.String()
+	}
+	params := map[string]string{"op": e.Op, "operands": strings.Join(kinds, ", ")}
+	if e.Arg > 0 {
+		params["arg"] = strconv.Itoa(e.Arg)
+	}
+	setPos(params, e.Pos)
+	return params
+}
+
+// messageParams gives the operation and the offset.
+func (e *OverflowError) messageParams() map[string]string {
+	operands := make([]string, len(e.Operands))
+	for i, n := range e.Operands {
+		operands[i] = strconv.FormatInt(n, 10)
+	}
+	params := map[string]string{"op": e.Op, "operands": strings.Join(operands, ", ")}
+	setPos(params, e.Pos)
+	return params
+}
+
+// messageParams gives the value, the subexpression and its offset.
+func (e *NonFiniteError) messageParams() map[string]string {
+	params := map[string]string{"value": formatNumber(e.Value), "expr": Format(e.Expr)}
+	if span := SpanOf(e.Expr); span != (Span{}) {
+		params["pos"] = strconv.Itoa(span.Start)
+	}
+	return params
+}
+
+// messageParams gives the budget and the offset.
+func (e *BudgetExceededError) messageParams() map[string]string {
+	params := map[string]string{"limit": strconv.Itoa(e.Limit)}
+	setPos(params, e.Pos)
+	return params
+}
+
+// messageParams gives the size of the input and the limit.
+func (e *InputTooLargeError) messageParams() map[string]string {
+	return map[string]string{"size": strconv.Itoa(e.Size), "limit": strconv.Itoa(e.Limit)}
+}
+
+// setPos sets the pos parameter to an offset that is known.
+func setPos(params map[string]string, pos int) {
+	if pos >= 0 {
+		params["pos"] = strconv.Itoa(pos)
+	}
+}
+
+// end of file
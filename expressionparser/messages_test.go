@@ -0,0 +1,74 @@
+package expressionparser
+
+import (
+	"errors"
+	"testing"
+)
+
+// fakeLocale renders every error as its code alone, so that a test cannot
+// pass with the English message.
+type fakeLocale struct{}
+
+func (fakeLocale) Render(code string, params map[string]string) string {
+	return "code " + code
+}
+
+func TestCatalog(t *testing.T) {
+	german := NewCatalog(nil)
+	german.Register(CodeUnexpectedToken, "{found} an Position {pos} unerwartet, erwartet: {expected}")
+	german.Register(CodeUndefinedVar, "Variable {name} ist nicht definiert (Position {pos})")
+	german.Register(CodeDivisionByZero, "Division durch null in {expr} ({missing})")
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1 * * 2", "'*' an Position 4 unerwartet, erwartet: number, name, string, '(', '[', or unary '-' or '!'"},
+		{"price + tax", "Variable tax ist nicht definiert (Position 8)"},
+		// A parameter the error lacks is left as it is
+		{"2 * (1 / 0)", "Division durch null in 1 / 0 ({missing})"},
+		// and a code without a template is rendered in English
+		{"sqrt(-1)", `sqrt: argument -1 is outside the domain in "sqrt(-1)" at offset 0`},
+	}
+	for _, tt := range tests {
+		_, err := EvaluateWithVars(tt.input, map[string]float64{"price": 1})
+		if got := RenderError(err, german); got != tt.want {
+			t.Errorf("RenderError(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	french := NewCatalog(fakeLocale{})
+	french.Register(CodeUndefinedVar, "la variable {name} n'est pas définie")
+	if _, err := Evaluate("x"); RenderError(err, french) != "la variable x n'est pas définie" {
+		t.Errorf("RenderError(x) in French = %q", RenderError(err, french))
+	}
+	if _, err := Evaluate("1 $ 2"); RenderError(err, french) != "code "+CodeInvalidToken {
+		t.Errorf("RenderError(1 $ 2) falling back = %q", RenderError(err, french))
+	}
+	german.Register(CodeUndefinedVar, "{name} fehlt")
+	if _, err := Evaluate("x"); RenderError(err, german) != "x fehlt" {
+		t.Errorf("RenderError(x) after a second Register = %q", RenderError(err, german))
+	}
+}
+
+func TestRenderEnglish(t *testing.T) {
+	for _, input := range []string{"1 * * 2", "x", "1 / 0", "1 $ 2", `"a" - 1`} {
+		expr, err := ParseString(input)
+		if err == nil {
+			_, err = EvalValue(expr, nil)
+		}
+		if got := RenderError(err, English); err == nil || got != err.Error() {
+			t.Errorf("RenderError(%q, English) = %q, want %v", input, got, err)
+		}
+	}
+	if got := RenderError(nil, English); got != "" {
+		t.Errorf("RenderError(nil) = %q", got)
+	}
+	// Wrapping in another error keeps the parameters of the cause
+	_, err := Evaluate("x")
+	params := ErrorParams(errors.Join(errors.New("while saving"), err))
+	if params["name"] != "x" || params["pos"] != "0" || params["message"] != "while saving\n"+err.Error() {
+		t.Errorf("ErrorParams of a joined error = %v", params)
+	}
+}
+
+// end of file
@@ -0,0 +1,77 @@
+package expressionparser
+
+import "fmt"
+
+// ExprMetrics describes the size and shape of an expression tree.
+type ExprMetrics struct {
+	Depth    int               // nodes on the longest root-to-leaf path
+	Nodes    int               // every node, literals included
+	Ops      map[TokenType]int // binary operators by token type
+	UnaryOps map[TokenType]int // prefix operators by token type
+	Calls    map[string]int    // function calls by name
+}
+
+// Limits caps the metrics of an accepted expression; zero fields are unlimited.
+type Limits struct {
+	MaxDepth int
+	MaxNodes int
+	MaxCalls int
+}
+
+// Metrics measures expr in a single traversal.
+func Metrics(expr Expr) ExprMetrics {
+	m := ExprMetrics{
+		Ops:      map[TokenType]int{},
+		UnaryOps: map[TokenType]int{},
+		Calls:    map[string]int{},
+	}
+	m.Depth = m.measure(expr)
+	return m
+}
+
+// measure counts expr and its subtree, returning the depth of the subtree.
+func (m *ExprMetrics) measure(expr Expr) int {
+	if expr == nil {
+		return 0
+	}
+
+	m.Nodes++
+	switch v := expr.(type) {
+	case *BinaryOp:
+		m.Ops[v.Op.Type]++
+	case *UnaryOp:
+		m.UnaryOps[v.Op.Type]++
+	case *FunctionCall:
+		m.Calls[v.Name]++
+	}
+
+	deepest := 0
+	for _, child := range Children(expr) {
+		if d := m.measure(child); d > deepest {
+			deepest = d
+		}
+	}
+	return deepest + 1
+}
+
+// WithinLimits returns an error describing the first limit m exceeds.
+func WithinLimits(m ExprMetrics, limits Limits) error {
+	if limits.MaxDepth > 0 && m.Depth > limits.MaxDepth {
+		return withCode(CodeLimitDepth, fmt.Errorf("expression depth %d exceeds limit %d", m.Depth, limits.MaxDepth))
+	}
+	if limits.MaxNodes > 0 && m.Nodes > limits.MaxNodes {
+		return withCode(CodeLimitNodes, fmt.Errorf("expression has %d nodes, limit is %d", m.Nodes, limits.MaxNodes))
+	}
+	if limits.MaxCalls > 0 {
+		calls := 0
+		for _, n := range m.Calls {
+			calls += n
+		}
+		if calls > limits.MaxCalls {
+			return withCode(CodeLimitCalls, fmt.Errorf("expression makes %d function calls, limit is %d", calls, limits.MaxCalls))
+		}
+	}
+	return nil
+}
+
+// end of file
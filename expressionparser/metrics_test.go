@@ -0,0 +1,62 @@
+package expressionparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	// (2 + x) * max(-x, sin(3)), built by hand
+	tree := Mul(Add(Num(2), Var("x")), Call("max", Neg(Var("x")), Call("sin", Num(3))))
+	got := Metrics(tree)
+	want := ExprMetrics{
+		Depth:    4,
+		Nodes:    9,
+		Ops:      map[TokenType]int{MULT: 1, PLUS: 1},
+		UnaryOps: map[TokenType]int{MINUS: 1},
+		Calls:    map[string]int{"max": 1, "sin": 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Metrics = %+v, want %+v", got, want)
+	}
+
+	leaf := Metrics(Num(1))
+	if leaf.Depth != 1 || leaf.Nodes != 1 || len(leaf.Ops)+len(leaf.UnaryOps)+len(leaf.Calls) != 0 {
+		t.Errorf("Metrics(1) = %+v", leaf)
+	}
+	if empty := Metrics(nil); empty.Depth != 0 || empty.Nodes != 0 {
+		t.Errorf("Metrics(nil) = %+v", empty)
+	}
+
+	chain := Metrics(Add(Add(Add(Var("a"), Var("b")), Var("c")), Var("d")))
+	if chain.Depth != 4 || chain.Nodes != 7 || chain.Ops[PLUS] != 3 {
+		t.Errorf("Metrics(a + b + c + d) = %+v", chain)
+	}
+}
+
+func TestWithinLimits(t *testing.T) {
+	m := Metrics(Mul(Add(Num(2), Var("x")), Call("max", Neg(Var("x")), Call("sin", Num(3)))))
+	tests := []struct {
+		limits Limits
+		code   string
+	}{
+		{Limits{}, ""},
+		{Limits{MaxDepth: 4, MaxNodes: 9, MaxCalls: 2}, ""},
+		{Limits{MaxDepth: 3}, CodeLimitDepth},
+		{Limits{MaxNodes: 8}, CodeLimitNodes},
+		{Limits{MaxCalls: 1}, CodeLimitCalls},
+		{Limits{MaxDepth: 3, MaxCalls: 1}, CodeLimitDepth},
+	}
+	for _, tt := range tests {
+		err := WithinLimits(m, tt.limits)
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("WithinLimits(%+v) = %v", tt.limits, err)
+			}
+		} else if ErrorCode(err) != tt.code {
+			t.Errorf("WithinLimits(%+v) = %v, want code %s", tt.limits, err, tt.code)
+		}
+	}
+}
+
+// end of file
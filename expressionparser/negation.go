@@ -0,0 +1,134 @@
+package expressionparser
+
+import "math"
+
+// Negations of the comparison operators that hold only when neither operand is NaN
+var negatedComparisons = map[TokenType]TokenType{
+	LT: GE, LE: GT, GT: LE, GE: LT,
+}
+
+// PushDownNegation returns a copy of expr in which unary minus and logical
+// not have been moved as far toward the leaves as Eval semantics allow:
+//
+//	-(a + b)        -> -a + -b
+//	-(a - b)        -> -a + b
+//	-(a * b)        -> -a * b, and likewise for /
+//	-(c ? a : b)    -> c ? -a : -b
+//	!(a && b)       -> !a || !b, and likewise for ||
+//	!(a == b)       -> a != b, and likewise for !=
+//	!(a < b)        -> a >= b, and likewise for the other orderings
+//	!(c ? a : b)    -> c ? !a : !b
+//	--a, !!(a < b)  -> a, a < b
+//
+// Negated literals are folded, and negations pass through the body of a let.
+// The rewrite declines where it would change a result: an ordering is only
+// inverted when neither operand can be NaN (a literal, or a comparison or
+// logical result), since every ordering involving NaN is false; and a double
+// not is only removed from an operand that is already 0 or 1. Distributing
+// minus over + and - is exact except that a zero result may change sign, as
+// in -(1 - 1) = -0 against -1 + 1 = 0. The input is not modified.
+func PushDownNegation(expr Expr) Expr {
+	switch v := expr.(type) {
+	case *UnaryOp:
+		switch v.Op.Type {
+		case MINUS:
+			return negate(v.Operand)
+		case NOT:
+			return not(v.Operand)
+		}
+	case *Number, *Variable, nil:
+		return expr
+	}
+
+	children := Children(expr)
+	pushed := make([]Expr, len(children))
+	for i, child := range children {
+		pushed[i] = PushDownNegation(child)
+	}
+	return withChildren(expr, pushed)
+}
+
+// negate returns an expression equal to -expr with the negation pushed down.
+func negate(expr Expr) Expr {
+	switch v := expr.(type) {
+	case *Number:
+		return &Number{Value: -v.Value, Imag: v.Imag}
+	case *UnaryOp:
+		if v.Op.Type == MINUS {
+			return PushDownNegation(v.Operand)
+		}
+	case *BinaryOp:
+		switch v.Op.Type {
+		case PLUS:
+			return Add(negate(v.Left), negate(v.Right))
+		case MINUS:
+			return Add(negate(v.Left), PushDownNegation(v.Right))
+		case MULT, DIV:
+			return operation(v.Op.Type, negate(v.Left), PushDownNegation(v.Right))
+		}
+	case *Conditional:
+		return If(PushDownNegation(v.Cond), negate(v.Then), negate(v.Else))
+	case *Let:
+		return &Let{Name: v.Name, Value: PushDownNegation(v.Value), Body: negate(v.Body)}
+	}
+	return Neg(PushDownNegation(expr))
+}
+
+// not returns an expression equal to !expr with the negation pushed down.
+func not(expr Expr) Expr {
+	switch v := expr.(type) {
+	case *UnaryOp:
+		if v.Op.Type == NOT && isBoolean(v.Operand) {
+			return PushDownNegation(v.Operand)
+		}
+	case *BinaryOp:
+		left, right := v.Left, v.Right
+		switch v.Op.Type {
+		case AND:
+			return Or(not(left), not(right))
+		case OR:
+			return And(not(left), not(right))
+		case EQ:
+			return NotEquals(PushDownNegation(left), PushDownNegation(right))
+		case NE:
+			return Equals(PushDownNegation(left), PushDownNegation(right))
+		case LT, LE, GT, GE:
+			if neverNaN(left) && neverNaN(right) {
+				return operation(negatedComparisons[v.Op.Type], PushDownNegation(left), PushDownNegation(right))
+			}
+		}
+	case *Conditional:
+		return If(PushDownNegation(v.Cond), not(v.Then), not(v.Else))
+	case *Let:
+		return &Let{Name: v.Name, Value: PushDownNegation(v.Value), Body: not(v.Body)}
+	}
+	return Not(PushDownNegation(expr))
+}
+
+// isBoolean reports whether expr always evaluates to 0 or 1.
+func isBoolean(expr Expr) bool {
+	switch v := expr.(type) {
+	case *Number:
+		return !v.Imag && (v.Value == 0 || v.Value == 1)
+	case *BinaryOp:
+		switch v.Op.Type {
+		case AND, OR, EQ, NE, LT, LE, GT, GE:
+			return true
+		}
+	case *UnaryOp:
+		return v.Op.Type == NOT
+	case *Conditional:
+		return isBoolean(v.Then) && isBoolean(v.Else)
+	}
+	return false
+}
+
+// neverNaN reports whether expr can be shown not to evaluate to NaN.
+func neverNaN(expr Expr) bool {
+	if n, ok := expr.(*Number); ok {
+		return !math.IsNaN(n.Value)
+	}
+	return isBoolean(expr)
+}
+
+// end of file
@@ -0,0 +1,98 @@
+package expressionparser_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	ep "github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser"
+	"github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser/exprtest"
+)
+
+func TestPushDownNegation(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"-(a + b)", "-a + -b"},
+		{"-(a - b)", "-a + b"},
+		{"-(a * b) + -(a / 2)", "-a * b + -a / 2"},
+		{"-(c ? a : 2)", "c ? -a : -2"},
+		{"--a", "a"},
+		{"!(a && b)", "!a || !b"},
+		{"!(a || !(b == c))", "!a && b == c"},
+		{"!(a != b)", "a == b"},
+		{"!(1 < 2)", "1 >= 2"},
+		{"!((a < b) <= (c > d))", "a < b > (c > d)"},
+		{"!!(a < b)", "a < b"},
+		{"let k = -(a + 1) in !(k == 0)", "let k = -a + -1 in k != 0"},
+		{"max(-(a + b), !(a && b))", "max(-a + -b, !a || !b)"},
+		// Declined: a and b may be NaN, and !!a is 0 or 1 where a is not
+		{"!(a < b)", "!(a < b)"},
+		{"!(a >= 1)", "!(a >= 1)"},
+		{"!!a", "!!a"},
+		{"-(a ^ 2)", "-a ^ 2"},
+		// Declined: ~ and the other bitwise operators give more than 0 or 1
+		{"!!(x ~ z)", "!!(x ~ z)"},
+		{"!!(a & b)", "!!(a & b)"},
+	}
+	for _, tt := range tests {
+		expr, err := ep.ParseString(tt.input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", tt.input, err)
+		}
+		before := ep.Clone(expr)
+		got := ep.PushDownNegation(expr)
+		if !ep.Equal(expr, before) {
+			t.Errorf("PushDownNegation(%q) modified its input", tt.input)
+		}
+		if ep.Format(got) != tt.want {
+			t.Errorf("PushDownNegation(%q) = %s, want %s", tt.input, ep.Format(got), tt.want)
+		}
+	}
+}
+
+// negationConfig generates the arithmetic, comparisons and logic the
+// rewrite pushes negations through, on three variables.
+var negationConfig = exprtest.GenConfig{
+	Operators: []string{"+", "-", "*", "/", "<", "<=", ">", ">=", "==", "!=", "&&", "||"},
+	Unary:     []string{"-", "!"},
+	Variables: []string{"x", "y", "z"},
+	Decimals:  1,
+}
+
+// specialValues are the values bindings sometimes take, the ones the rewrite
+// must take care over.
+var specialValues = []float64{0, math.Copysign(0, -1), 1, math.NaN(), math.Inf(1), math.Inf(-1)}
+
+func TestPushDownNegationPreservesResults(t *testing.T) {
+	for seed := int64(0); seed < int64(propertyCases()); seed++ {
+		r := rand.New(rand.NewSource(seed))
+		text, _ := exprtest.Generate(r, negationConfig)
+		prefix := []string{"-", "!"}[r.Intn(2)]
+		text = prefix + "(" + text + ")"
+		expr, err := ep.ParseString(text)
+		if err != nil {
+			t.Fatalf("seed %d: %q does not parse: %v", seed, text, err)
+		}
+		pushed := ep.PushDownNegation(expr)
+
+		for i := 0; i < 4; i++ {
+			vars := map[string]float64{}
+			for _, name := range negationConfig.Variables {
+				if r.Intn(4) == 0 {
+					vars[name] = specialValues[r.Intn(len(specialValues))]
+				} else {
+					vars[name] = math.Round(r.NormFloat64()*50) / 10
+				}
+			}
+			want, wantErr := ep.EvalWithVars(expr, vars)
+			got, gotErr := ep.EvalWithVars(pushed, vars)
+			if !agree(got, gotErr, want, wantErr) {
+				t.Fatalf("seed %d: %s with %v gives %v, %v; rewritten to %s it gives %v, %v",
+					seed, text, vars, want, wantErr, ep.Format(pushed), got, gotErr)
+			}
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,38 @@
+package expressionparser
+
+import "fmt"
+
+// Normalize parses input and prints it as Format does, with single spaces
+// around binary operators, minimal parentheses and numbers in the shortest
+// 'g' form, so that inputs differing only in layout, redundant parentheses
+// or the spelling of numbers, such as "2 + 3*x" and "2+3 * (x)", give the
+// same string: a key for caches and for finding duplicate formulas. Unlike
+// Canonicalize it keeps the order of operands. Comments, which the
+// grammar does not have yet, are dropped, and the result parses to a tree
+// Equal to that of input.
+func Normalize(input string) (string, error) {
+	return reprint(input, Formatter{})
+}
+
+// Minify is Normalize without spaces but those between words, such as in
+// let bindings and after the number of a quantity.
+func Minify(input string) (string, error) {
+	return reprint(input, Formatter{Compact: true})
+}
+
+// reprint parses input and prints it with f, checking that the output
+// parses back to an equal tree.
+func reprint(input string, f Formatter) (string, error) {
+	expr, err := ParseString(input)
+	if err != nil {
+		return "", err
+	}
+	out := f.Format(expr)
+	again, err := ParseString(out)
+	if err != nil || !Equal(again, expr) {
+		return "", withCode(CodeOther, fmt.Errorf("expression %q prints as %q, which does not parse back to it", input, out))
+	}
+	return out, nil
+}
+
+// end of file
@@ -0,0 +1,75 @@
+package expressionparser
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input, normal, minified string
+	}{
+		{"2 + 3*x", "2 + 3 * x", "2+3*x"},
+		{"2+3 * (x)", "2 + 3 * x", "2+3*x"},
+		{"2+(3*x)", "2 + 3 * x", "2+3*x"},
+		{"(2+3)*x", "(2 + 3) * x", "(2+3)*x"},
+		{"x - (y - z)", "x - (y - z)", "x-(y-z)"},
+		{"(2^3)^2", "(2 ^ 3) ^ 2", "(2^3)^2"},
+		{"f(1,2)", "f(1, 2)", "f(1,2)"},
+		{"a ? b : c", "a ? b : c", "a?b:c"},
+		{`"s" + 1`, `"s" + 1`, `"s"+1`},
+		// Spaces between words are kept
+		{"let a = 1 in a+1", "let a = 1 in a + 1", "let a=1 in a+1"},
+		{"5 m + 3 cm", "5 m + 3 cm", "5 m+3 cm"},
+		// Numbers in the shortest 'g' form
+		{"1e21", "1e+21", "1e+21"},
+		{"1000000000000000000000", "1e+21", "1e+21"},
+		{"0.5", "0.5", "0.5"},
+		{"0.50", "0.5", "0.5"},
+		{"1.0", "1", "1"},
+		{"1e-7", "1e-07", "1e-07"},
+		{"100", "100", "100"},
+	}
+	for _, tt := range tests {
+		if got, err := Normalize(tt.input); err != nil || got != tt.normal {
+			t.Errorf("Normalize(%s) = %q, %v; want %q", tt.input, got, err, tt.normal)
+		}
+		if got, err := Minify(tt.input); err != nil || got != tt.minified {
+			t.Errorf("Minify(%s) = %q, %v; want %q", tt.input, got, err, tt.minified)
+		}
+	}
+}
+
+func TestNormalizeRoundTrip(t *testing.T) {
+	for _, input := range evalCorpus {
+		expr, err := ParseString(input)
+		if err != nil {
+			continue
+		}
+		for name, f := range map[string]func(string) (string, error){"Normalize": Normalize, "Minify": Minify} {
+			out, err := f(input)
+			if err != nil {
+				t.Errorf("%s(%s): %v", name, input, err)
+				continue
+			}
+			if again, err := ParseString(out); err != nil || !Equal(again, expr) {
+				t.Errorf("%s(%s) = %q, which does not parse back to it: %v", name, input, out, err)
+			}
+			if twice, _ := f(out); twice != out {
+				t.Errorf("%s(%q) = %q, not a fixed point", name, out, twice)
+			}
+		}
+	}
+}
+
+// TestNormalizeComments checks that comments cannot reach the output: the
+// grammar has none yet, so an input with one fails rather than keeping it.
+func TestNormalizeComments(t *testing.T) {
+	for _, input := range []string{"2 /* c */ + 3", "2 + 3 # note", "2 + 3 // note"} {
+		if got, err := Normalize(input); err == nil {
+			t.Errorf("Normalize(%s) = %q, want a syntax error", input, got)
+		}
+		if got, err := Minify(input); err == nil {
+			t.Errorf("Minify(%s) = %q, want a syntax error", input, got)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,57 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+)
+
+// gcd returns the greatest common divisor of two or more integers, by
+// Euclid's algorithm; gcd(0, 0) is 0.
+func gcd(args []float64) (float64, error) {
+	result := int64(args[0])
+	for _, arg := range args[1:] {
+		result = gcdInt(result, int64(arg))
+	}
+	return float64(result), nil
+}
+
+// lcm returns the least common multiple of two or more integers, which is 0
+// when any of them is. A multiple larger than 2^53, beyond which a float64
+// no longer holds every integer, is an error wrapping ErrOverflow.
+func lcm(args []float64) (float64, error) {
+	result := int64(args[0])
+	for _, arg := range args[1:] {
+		b := int64(arg)
+		if result == 0 || b == 0 {
+			return 0, nil
+		}
+		// Dividing first keeps the product within int64, as both factors are at most 2^53
+		q := result / gcdInt(result, b)
+		if q > maxExactFloatInt/b {
+			return 0, fmt.Errorf("lcm: %w beyond 2^53", ErrOverflow)
+		}
+		result = q * b
+	}
+	return float64(result), nil
+}
+
+// gcdInt returns the greatest common divisor of two non-negative integers.
+func gcdInt(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// notExactNatural rejects the first argument that is not a non-negative
+// integer of at most 2^53, which converts to int64 exactly.
+func notExactNatural(args []float64) int {
+	for i, x := range args {
+		if x < 0 || x != math.Trunc(x) || x > maxExactFloatInt {
+			return i
+		}
+	}
+	return -1
+}
+
+// end of file
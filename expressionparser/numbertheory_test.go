@@ -0,0 +1,68 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestGcdLcm(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"gcd(0, 0)", 0},
+		{"gcd(0, 5)", 5},
+		{"gcd(12, 18)", 6},
+		{"gcd(12, 18, 8)", 2},
+		{"gcd(17, 5)", 1},
+		{"lcm(0, 0)", 0},
+		{"lcm(0, 5)", 0},
+		{"lcm(4, 6)", 12},
+		{"lcm(4, 6, 10)", 60},
+		{"lcm(2^26, 3^16)", 67108864 * 43046721},
+	}
+	for _, tt := range tests {
+		if got, err := Evaluate(tt.input); err != nil || got != tt.want {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	errs := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{"gcd(1.5, 3)", CodeOutsideDomain, "gcd: argument 1, 1.5, is outside the domain"},
+		{"gcd(-4, 6)", CodeOutsideDomain, "gcd: argument 1, -4, is outside the domain"},
+		{"lcm(4, -6)", CodeOutsideDomain, "lcm: argument 2, -6, is outside the domain"},
+		{"gcd(1e+300, 2)", CodeOutsideDomain, "gcd: argument 1, 1e+300, is outside the domain"},
+		{"lcm(2 ^ 30, 3 ^ 20)", CodeOverflow, "lcm: overflow beyond 2^53"},
+		{"lcm(9.007199254740991e+15, 2)", CodeOverflow, "lcm: overflow beyond 2^53"},
+		{"gcd(5)", CodeArgumentCount, "gcd expects at least 2 argument(s), got 1"},
+	}
+	for _, tt := range errs {
+		_, err := Evaluate(tt.input)
+		if ErrorCode(err) != tt.code || err.Error() != tt.msg+` in "`+tt.input+`" at offset 0` {
+			t.Errorf("%s: error %v, want %s %q", tt.input, err, tt.code, tt.msg)
+		}
+	}
+}
+
+func TestGcdLcmProperty(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		a, b := r.Int63n(10000), r.Int63n(10000)
+		in := fmt.Sprintf("gcd(%d, %d) * lcm(%d, %d)", a, b, a, b)
+		got, err := Evaluate(in)
+		if err != nil || got != float64(a*b) {
+			t.Fatalf("%s = %v, %v; want %d", in, got, err, a*b)
+		}
+		in = fmt.Sprintf("gcd(%d, %d) == gcd(%d, %d) && lcm(%d, %d) == lcm(%d, %d)", a, b, b, a, a, b, b, a)
+		if got, err := Evaluate(in); err != nil || got != 1 {
+			t.Fatalf("%s = %v, %v", in, got, err)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,68 @@
+package expressionparser
+
+import "time"
+
+// Observer is told of the evaluations of an Expression compiled
+// WithObserver, for metrics such as evaluation counts, error rates and
+// latency, and for auditing the variables read. The expression is
+// identified by exprID, the input it was compiled from. An observer cannot
+// change results: its methods return nothing, and a panic in one is
+// recovered and ignored. They may be called from several goroutines at
+// once, as Eval may.
+type Observer interface {
+	// OnEvalStart is called as an evaluation begins.
+	OnEvalStart(exprID string)
+
+	// OnEvalEnd is called as an evaluation ends, with how long it took and
+	// its error, which is nil when it succeeded.
+	OnEvalEnd(exprID string, dur time.Duration, err error)
+
+	// OnVarRead is called as the evaluation reads the variable, which
+	// happens each time the variable is reached and not for variables on
+	// a branch that is not taken.
+	OnVarRead(name string)
+}
+
+// WithObserver makes Compile give the Expression the observer, telling it
+// of every evaluation. Without one no time is measured, leaving Eval as
+// fast as before.
+func WithObserver(o Observer) Option {
+	return func(c *config) {
+		c.observer = o
+	}
+}
+
+// observe evaluates the expression, telling its observer.
+func (e *Expression) observe(vars map[string]float64) (float64, error) {
+	observeStart(e.observer, e.id)
+	start := time.Now()
+	result, err := e.fn(vars, e.observer)
+	observeEnd(e.observer, e.id, time.Since(start), err)
+	return result, err
+}
+
+// observeStart calls OnEvalStart, ignoring a panic.
+func observeStart(o Observer, id string) {
+	defer ignorePanic()
+	o.OnEvalStart(id)
+}
+
+// observeEnd calls OnEvalEnd, ignoring a panic.
+func observeEnd(o Observer, id string, dur time.Duration, err error) {
+	defer ignorePanic()
+	o.OnEvalEnd(id, dur, err)
+}
+
+// observeVarRead calls OnVarRead, ignoring a panic.
+func observeVarRead(o Observer, name string) {
+	defer ignorePanic()
+	o.OnVarRead(name)
+}
+
+// ignorePanic recovers from a panic of an observer, which must not change
+// the result of the evaluation it observes.
+func ignorePanic() {
+	recover()
+}
+
+// end of file
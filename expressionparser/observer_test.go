@@ -0,0 +1,116 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is an Observer that records its calls.
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *recorder) OnEvalStart(exprID string) { r.record("start " + exprID) }
+
+func (r *recorder) OnEvalEnd(exprID string, dur time.Duration, err error) {
+	if dur < 0 {
+		r.record("negative duration")
+	}
+	r.record(fmt.Sprintf("end %s %v", exprID, err))
+}
+
+func (r *recorder) OnVarRead(name string) { r.record("read " + name) }
+
+// panicker is an Observer whose every method panics.
+type panicker struct{}
+
+func (panicker) OnEvalStart(string)                     { panic("start") }
+func (panicker) OnEvalEnd(string, time.Duration, error) { panic("end") }
+func (panicker) OnVarRead(string)                       { panic("read") }
+
+func TestObserver(t *testing.T) {
+	rec := &recorder{}
+	e, err := Compile("x > 0 ? x * 2 : y", WithObserver(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := e.Eval(map[string]float64{"x": 3}); err != nil || got != 6 {
+		t.Errorf("Eval(x = 3) = %v, %v; want 6", got, err)
+	}
+	if _, err := e.Eval(map[string]float64{"x": -1}); !errors.Is(err, ErrUndefinedVariable) {
+		t.Errorf("Eval(x = -1): error %v, want ErrUndefinedVariable", err)
+	}
+	want := []string{
+		"start x > 0 ? x * 2 : y", "read x", "read x", "end x > 0 ? x * 2 : y <nil>",
+		// y is reached, though it is undefined
+		"start x > 0 ? x * 2 : y", "read x", "read y", `end x > 0 ? x * 2 : y undefined variable y in "y" at offset 16`,
+	}
+	if got := strings.Join(rec.calls, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("observer calls:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	// A constant reads nothing, and is still counted
+	rec = &recorder{}
+	c, _ := Compile("2 + 3", WithObserver(rec))
+	if got, err := c.Eval(nil); err != nil || got != 5 {
+		t.Errorf("Eval(2 + 3) = %v, %v; want 5", got, err)
+	}
+	if got := strings.Join(rec.calls, ", "); got != "start 2 + 3, end 2 + 3 <nil>" {
+		t.Errorf("observer calls of a constant: %s", got)
+	}
+}
+
+func TestObserverCannotChangeResults(t *testing.T) {
+	e, err := Compile("a / b", WithObserver(panicker{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := e.Eval(map[string]float64{"a": 6, "b": 3}); err != nil || got != 2 {
+		t.Errorf("Eval(6 / 3) with a panicking observer = %v, %v; want 2", got, err)
+	}
+	if _, err := e.Eval(map[string]float64{"a": 6, "b": 0}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("Eval(6 / 0) with a panicking observer: error %v, want ErrDivisionByZero", err)
+	}
+}
+
+func BenchmarkEvalObserver(b *testing.B) {
+	vars := map[string]float64{"x": 3, "y": 4}
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"none", nil},
+		{"discard", []Option{WithObserver(&discard{})}},
+	} {
+		e, err := Compile("x > 0 ? x * y + 1 : y", bench.opts...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = e.Eval(vars)
+			}
+		})
+	}
+}
+
+// discard is an Observer that does nothing.
+type discard struct{}
+
+func (*discard) OnEvalStart(string)                     {}
+func (*discard) OnEvalEnd(string, time.Duration, error) {}
+func (*discard) OnVarRead(string)                       {}
+
+// end of file
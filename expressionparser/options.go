@@ -0,0 +1,100 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrInvalidOption is matched by errors.Is for the error of an option given
+// a value out of its range, or of two options that contradict each other.
+var ErrInvalidOption = errors.New("invalid option")
+
+// WithMaxDepth makes the parser reject input nested more than n deep, each
+// parenthesis, bracket, call argument, branch, prefix operator and
+// exponent of ^ counting as a level, so that deeply nested input cannot exhaust the stack. Zero,
+// the default, means no limit.
+func WithMaxDepth(n int) Option {
+	return func(c *config) {
+		c.maxDepth = n
+	}
+}
+
+// WithMaxInputBytes makes the lexer and the parser reject input longer than
+// n bytes with an *InputTooLargeError before lexing any of it, so that a
+// huge input costs nothing to refuse. Zero, the default, means no limit.
+func WithMaxInputBytes(n int) Option {
+	return func(c *config) {
+		c.maxInputBytes = n
+	}
+}
+
+// InputTooLargeError reports input longer than WithMaxInputBytes allows.
+type InputTooLargeError struct {
+	Size  int // length of the input in bytes
+	Limit int
+}
+
+// Error gives the size and the limit.
+func (e *InputTooLargeError) Error() string {
+	return fmt.Sprintf("input of %d bytes exceeds the limit of %d", e.Size, e.Limit)
+}
+
+// Code returns CodeLimitInput.
+func (e *InputTooLargeError) Code() string {
+	return CodeLimitInput
+}
+
+// checkInput rejects input longer than WithMaxInputBytes allows.
+func (c *config) checkInput(input string) error {
+	if c.maxInputBytes > 0 && len(input) > c.maxInputBytes {
+		return &InputTooLargeError{Size: len(input), Limit: c.maxInputBytes}
+	}
+	return nil
+}
+
+// newConfig applies opts in order, the later of two setting the same thing
+// winning, and checks the result.
+func newConfig(opts []Option) (config, error) {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c, c.validate()
+}
+
+// validate reports the first option out of range, or in conflict with
+// another, as an error wrapping ErrInvalidOption.
+func (c *config) validate() error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidOption, fmt.Sprintf(format, args...))
+	}
+	negative := func(x float64) bool {
+		return x < 0 || math.IsNaN(x)
+	}
+	switch {
+	case c.maxDepth < 0:
+		return invalid("WithMaxDepth(%d) is negative", c.maxDepth)
+	case c.maxInputBytes < 0:
+		return invalid("WithMaxInputBytes(%d) is negative", c.maxInputBytes)
+	case c.maxOps < 0:
+		return invalid("WithMaxOps(%d) is negative", c.maxOps)
+	case c.solveIterations < 0:
+		return invalid("WithSolveIterations(%d) is negative", c.solveIterations)
+	case negative(c.epsilon):
+		return invalid("WithComparisonEpsilon(%s) is not a non-negative number", formatNumber(c.epsilon))
+	case negative(c.integrationTolerance):
+		return invalid("WithIntegrationTolerance(%s) is not a non-negative number", formatNumber(c.integrationTolerance))
+	case negative(c.literalTolerance):
+		return invalid("WithLiteralTolerance(%s) is not a non-negative number", formatNumber(c.literalTolerance))
+	case c.decimalScaleSet && (c.decimalScale < 0 || c.decimalScale > maxDecimalScale):
+		return invalid("WithDecimalScale(%d) is outside 0 to %d", c.decimalScale, maxDecimalScale)
+	case c.nonFinite == RejectNonFinite && c.zeroDivision.ieee:
+		return invalid("DivideByZeroIEEE gives the infinities and NaN that RejectNonFinite rejects")
+	case c.nonFinite == RejectNonFinite && c.undefined.nan:
+		return invalid("UndefinedNaN gives the NaN that RejectNonFinite rejects")
+	}
+	return nil
+}
+
+// end of file
@@ -0,0 +1,172 @@
+package expressionparser
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestOptionsTakeEffect(t *testing.T) {
+	tests := []struct {
+		input string
+		opt   Option
+		want  float64 // with opt; NaN for an error
+		plain float64 // without options; NaN for an error
+	}{
+		{"((1))", WithMaxDepth(1), math.NaN(), 1},
+		{"((1))", WithMaxDepth(3), 1, 1},
+		{"12345", WithMaxInputBytes(4), math.NaN(), 12345},
+		{"1/0", WithDivisionByZero(DivideByZeroIEEE), math.Inf(1), math.NaN()},
+		{"x + 1", WithUndefinedVariables(UndefinedDefault(0)), 1, math.NaN()},
+		{"0.1+0.2 == 0.3", WithComparisonEpsilon(1e-9), 1, 0},
+		{"-7 % 3", WithFlooredModulo(), 2, -1},
+		{"1/3", WithRoundTo(2, RoundHalfUp), 0.33, 1.0 / 3},
+		{"sin(90)", WithAngleUnit(Degrees), 1, math.Sin(90)},
+	}
+	for _, tt := range tests {
+		check := func(how string, got float64, err error, want float64) {
+			t.Helper()
+			if math.IsNaN(want) {
+				if err == nil {
+					t.Errorf("%s %s = %v, want an error", tt.input, how, got)
+				}
+			} else if err != nil || got != want {
+				t.Errorf("%s %s = %v, %v; want %v", tt.input, how, got, err, want)
+			}
+		}
+		got, err := Evaluate(tt.input, tt.opt)
+		check("with the option", got, err, tt.want)
+		// Compile takes the same options, and the default is unchanged
+		if e, err := Compile(tt.input, tt.opt); err == nil {
+			got, err := e.Eval(nil)
+			check("compiled with the option", got, err, tt.want)
+		} else {
+			check("compiled with the option", 0, err, tt.want)
+		}
+		got, err = Evaluate(tt.input)
+		check("without options", got, err, tt.plain)
+	}
+
+	// The budget is of Eval, which Compile does not use
+	var budget *BudgetExceededError
+	if _, err := Evaluate("sum(i, 1, 100, i)", WithMaxOps(50)); !errors.As(err, &budget) {
+		t.Errorf("sum of 100 terms WithMaxOps(50): error %v, want a *BudgetExceededError", err)
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		msg  string
+	}{
+		{[]Option{WithMaxDepth(-1)}, "WithMaxDepth(-1) is negative"},
+		{[]Option{WithMaxInputBytes(-2)}, "WithMaxInputBytes(-2) is negative"},
+		{[]Option{WithMaxOps(-1)}, "WithMaxOps(-1) is negative"},
+		{[]Option{WithSolveIterations(-1)}, "WithSolveIterations(-1) is negative"},
+		{[]Option{WithComparisonEpsilon(-0.5)}, "WithComparisonEpsilon(-0.5) is not a non-negative number"},
+		{[]Option{WithComparisonEpsilon(math.NaN())}, "WithComparisonEpsilon(NaN) is not a non-negative number"},
+		{[]Option{WithIntegrationTolerance(-1)}, "WithIntegrationTolerance(-1) is not a non-negative number"},
+		{[]Option{WithLiteralTolerance(-1)}, "WithLiteralTolerance(-1) is not a non-negative number"},
+		{[]Option{WithDecimalScale(19)}, "WithDecimalScale(19) is outside 0 to 18"},
+		{[]Option{WithNonFiniteResults(RejectNonFinite), WithDivisionByZero(DivideByZeroIEEE)}, "DivideByZeroIEEE gives the infinities and NaN that RejectNonFinite rejects"},
+		{[]Option{WithUndefinedVariables(UndefinedNaN), WithNonFiniteResults(RejectNonFinite)}, "UndefinedNaN gives the NaN that RejectNonFinite rejects"},
+	}
+	for _, tt := range tests {
+		want := "invalid option: " + tt.msg
+		_, parseErr := NewParser(NewLexer("1"), tt.opts...).Parse()
+		_, evalErr := NewEvaluator(tt.opts...).Eval(Num(1))
+		_, compileErr := Compile("1", tt.opts...)
+		_, evaluateErr := Evaluate("1", tt.opts...)
+		for how, err := range map[string]error{
+			"Parse":    parseErr,
+			"Eval":     evalErr,
+			"Compile":  compileErr,
+			"Evaluate": evaluateErr,
+			"Validate": Validate("1", tt.opts...),
+		} {
+			if !errors.Is(err, ErrInvalidOption) || err.Error() != want {
+				t.Errorf("%s with %s: error %v, want %q", how, tt.msg, err, want)
+			}
+		}
+	}
+
+	// The later of two options setting the same thing wins, and is checked
+	if _, err := Evaluate("1", WithMaxDepth(-1), WithMaxDepth(2)); err != nil {
+		t.Errorf("WithMaxDepth(-1) then WithMaxDepth(2): %v", err)
+	}
+	if _, err := Evaluate("1", WithDivisionByZero(DivideByZeroIEEE), WithNonFiniteResults(RejectNonFinite), WithDivisionByZero(DivideByZeroError)); err != nil {
+		t.Errorf("IEEE division replaced before RejectNonFinite is checked: %v", err)
+	}
+}
+
+func TestMaxInputBytes(t *testing.T) {
+	const limit = 9
+	opt := WithMaxInputBytes(limit)
+	entries := map[string]func(input string) error{
+		"ParseString": func(input string) error {
+			_, err := ParseString(input, opt)
+			return err
+		},
+		"Parser": func(input string) error {
+			_, err := NewParser(NewLexer(input, opt)).Parse()
+			return err
+		},
+		"Evaluate": func(input string) error {
+			_, err := Evaluate(input, opt)
+			return err
+		},
+		"Compile": func(input string) error {
+			_, err := Compile(input, opt)
+			return err
+		},
+		"Validate": func(input string) error {
+			return Validate(input, opt)
+		},
+		"ParseProgram": func(input string) error {
+			_, err := ParseProgram(input, opt)
+			return err
+		},
+	}
+	// The limit is of bytes, so é counts twice
+	for _, tt := range []struct {
+		input string
+		over  bool
+	}{
+		{"1 + 2 * 3", false},
+		{"1 + 2 * 30", true},
+		{"1 +     2", false},
+		{"é + 2 * 3", true},
+		{"é + 2 *3", false},
+		{"", false},
+	} {
+		for name, entry := range entries {
+			err := entry(tt.input)
+			var tooLarge *InputTooLargeError
+			if !tt.over {
+				if errors.As(err, &tooLarge) {
+					t.Errorf("%s(%q) at %d bytes: %v", name, tt.input, len(tt.input), err)
+				}
+				continue
+			}
+			if !errors.As(err, &tooLarge) || tooLarge.Size != len(tt.input) || tooLarge.Limit != limit || ErrorCode(err) != CodeLimitInput {
+				t.Errorf("%s(%q) at %d bytes: error %v, want an *InputTooLargeError", name, tt.input, len(tt.input), err)
+			}
+		}
+	}
+
+	// The lexer refuses with a single token, and keeps its limit when reset
+	l := NewLexer("1234567890", opt)
+	if tok := l.NextToken(); tok.Type != INVALID || tok.Value != "input of 10 bytes exceeds the limit of 9" {
+		t.Errorf("lexing 10 bytes: %v", tok)
+	}
+	l.Reset("123456789")
+	if tok := l.NextToken(); tok.Type != NUMBER {
+		t.Errorf("lexing 9 bytes after Reset: %v", tok)
+	}
+	l.Reset("1234567890")
+	if tok := l.NextToken(); tok.Type != INVALID {
+		t.Errorf("lexing 10 bytes after Reset: %v", tok)
+	}
+}
+
+// end of file
@@ -0,0 +1,50 @@
+package expressionparser
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// maxPanicStack is the most of the stack a PanicError keeps, in bytes.
+const maxPanicStack = 4096
+
+// PanicError is the error of a parse or evaluation that panicked, as a
+// function registered on the evaluator may: Parse, Eval and the other
+// entry points recover the panic and return it as a PanicError, so that
+// input from users never crashes the program evaluating it.
+type PanicError struct {
+	Value interface{} // the value passed to panic
+	Stack string      // the stack where it panicked, truncated to 4 KiB
+}
+
+// Error gives the panic value.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value when it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverPanics turns off the recovery of panics when false, so that fuzz
+// tests see a panic where it happens rather than as an error.
+var recoverPanics = true
+
+// recoverPanic recovers a panic into *err as a PanicError. It must be
+// deferred by the function whose error it sets.
+func recoverPanic(err *error) {
+	if !recoverPanics {
+		return
+	}
+	if r := recover(); r != nil {
+		stack := debug.Stack()
+		if len(stack) > maxPanicStack {
+			stack = stack[:maxPanicStack]
+		}
+		*err = &PanicError{Value: r, Stack: string(stack)}
+	}
+}
+
+// end of file
@@ -0,0 +1,75 @@
+package expressionparser
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPanicRecovered(t *testing.T) {
+	errBoom := errors.New("boom")
+	ev := NewEvaluator()
+	ev.RegisterFunc("explode", func(args ...float64) (float64, error) {
+		if args[0] > 0 {
+			panic(errBoom)
+		}
+		var counts map[string]int
+		counts["x"]++ // a runtime error
+		return 0, nil
+	})
+	expr := mustParse(t, "1 + explode(x)")
+	fn, err := ev.CompileFunc(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string]func(x float64) error{
+		"Eval": func(x float64) error {
+			ev.SetVar("x", x)
+			_, err := ev.Eval(expr)
+			return err
+		},
+		"EvalContext": func(x float64) error {
+			ev.SetVar("x", x)
+			_, err := ev.EvalContext(context.Background(), expr)
+			return err
+		},
+		"CompileFunc": func(x float64) error {
+			_, err := fn(map[string]float64{"x": x})
+			return err
+		},
+	}
+	for name, eval := range entries {
+		err := eval(1)
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) || !errors.Is(err, errBoom) || err.Error() != "panic: boom" {
+			t.Errorf("%s of a panicking function: error %v, want a *PanicError wrapping the panic", name, err)
+			continue
+		}
+		if !strings.Contains(panicErr.Stack, "TestPanicRecovered") || len(panicErr.Stack) > maxPanicStack {
+			t.Errorf("%s: stack of %d bytes not from the test:\n%s", name, len(panicErr.Stack), panicErr.Stack)
+		}
+		err = eval(-1)
+		if !errors.As(err, &panicErr) || !strings.HasPrefix(err.Error(), "panic: assignment to entry in nil map") {
+			t.Errorf("%s of a function with a runtime error: error %v", name, err)
+		}
+	}
+}
+
+func TestPanicRecoveryDisabled(t *testing.T) {
+	recoverPanics = false
+	defer func() { recoverPanics = true }()
+	ev := NewEvaluator()
+	ev.RegisterFunc("explode", func(args ...float64) (float64, error) {
+		panic("boom")
+	})
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("recovered %v, want the panic of boom", r)
+		}
+	}()
+	ev.Eval(mustParse(t, "explode()"))
+	t.Error("Eval returned with recovery disabled")
+}
+
+// end of file
@@ -0,0 +1,294 @@
+package expressionparser
+
+// PartialEval returns a copy of expr with the variables in vars replaced by
+// their values and every subtree that thereby becomes constant replaced by
+// its value, computed with Eval semantics; the parts depending on other
+// variables stay symbolic. So with tax = 0.2, "price * qty * (1 + tax)"
+// becomes "price * qty * 1.2". A let whose value becomes constant is
+// dropped, its name replaced through the body, and a conditional or && and
+// || whose deciding operand becomes constant is reduced to what remains of
+// it. Values of comparisons are folded to 1 or 0, as Eval gives them.
+//
+// An error of a part that is always evaluated, such as a constant division
+// by zero, is returned immediately. A part that may be skipped at run time,
+// a branch of a conditional or the right operand of && and || whose
+// deciding operand is still unknown, is left unfolded instead when it
+// fails, so that evaluating the result with the remaining variables gives
+// the same value, or error, as evaluating expr with all of them.
+func PartialEval(expr Expr, vars map[string]float64) (Expr, error) {
+	known := make(map[string]Expr, len(vars))
+	for name, value := range vars {
+		known[name] = Num(value)
+	}
+	return partialEval(expr, known, true)
+}
+
+// RelevantVariables returns the free variables of expr not in known that
+// may still affect its value once the known ones have their values: those
+// PartialEval leaves in the reduced tree, in the order Variables gives
+// them. Branches of conditionals and operands of && and || that the known
+// values rule out are pruned, so in "flag ? a : b" with flag 1 only a is
+// relevant. The answer is conservative: a variable can remain whose value
+// happens not to matter, such as x in "x * 0 + 1". The error is that of
+// PartialEval.
+func RelevantVariables(expr Expr, known map[string]float64) ([]string, error) {
+	reduced, err := PartialEval(expr, known)
+	if err != nil {
+		return nil, err
+	}
+	return Variables(reduced), nil
+}
+
+// partialEval reduces expr with the literal values of known. Evaluation
+// errors are returned when certain is set, as expr is then known to be
+// evaluated whenever its enclosing tree is.
+func partialEval(expr Expr, known map[string]Expr, certain bool) (Expr, error) {
+	switch v := expr.(type) {
+	case *Variable:
+		if value, ok := known[v.Name]; ok {
+			result := Clone(value)
+			setSpan(result, v.Span)
+			return result, nil
+		}
+		return Clone(v), nil
+	case *Let:
+		value, err := partialEval(v.Value, known, certain)
+		if err != nil {
+			return nil, err
+		}
+		inner := make(map[string]Expr, len(known)+1)
+		for name, e := range known {
+			if name != v.Name {
+				inner[name] = e
+			}
+		}
+		if isLiteralValue(value) {
+			inner[v.Name] = value
+			return partialEval(v.Body, inner, certain)
+		}
+		body, err := partialEval(v.Body, inner, certain)
+		if err != nil {
+			return nil, err
+		}
+		return &Let{Name: v.Name, Value: value, Body: body, Span: v.Span}, nil
+	case *FunctionCall:
+		if _, ok := lazyForms[v.Name]; ok && len(v.Args) >= 2 {
+			return partialPiecewise(v, known, certain)
+		}
+		if name, binder, body, ok := formBinding(v); ok {
+			inner := make(map[string]Expr, len(known))
+			for n, e := range known {
+				if n != name {
+					inner[n] = e
+				}
+			}
+			args := make([]Expr, len(v.Args))
+			constant := true
+			for i, arg := range v.Args {
+				if i == binder {
+					args[i] = Clone(arg)
+					continue
+				}
+				scope, sure := known, certain
+				if i == body {
+					// An iteration form over an empty range never
+					// evaluates its last argument
+					scope, sure = inner, certain && binder != 0
+				}
+				r, err := partialEval(arg, scope, sure)
+				if err != nil {
+					return nil, err
+				}
+				if i == body && binder == 0 && !contains(Variables(r), name) {
+					// A call to sum is an iteration form only while its
+					// last argument refers to the variable
+					r = &Let{Name: name, Value: Var(name), Body: r}
+				}
+				args[i] = r
+				constant = constant && (isLiteralValue(r) || i == body && onlyDependsOn(r, name))
+			}
+			node := withChildren(v, args)
+			if !constant {
+				return node, nil
+			}
+			return reduce(node, certain)
+		}
+	case *Assign:
+		value, err := partialEval(v.Value, known, certain)
+		if err != nil {
+			return nil, err
+		}
+		return &Assign{Name: v.Name, Value: value, Span: v.Span}, nil
+	case *Conditional:
+		cond, err := partialEval(v.Cond, known, certain)
+		if err != nil {
+			return nil, err
+		}
+		if isLiteralValue(cond) {
+			b, ok := literalCondition(cond)
+			if !ok {
+				// Evaluating the conditional reports the type error
+				return reduce(withChildren(v, []Expr{cond, Clone(v.Then), Clone(v.Else)}), certain)
+			}
+			if b {
+				return partialEval(v.Then, known, certain)
+			}
+			return partialEval(v.Else, known, certain)
+		}
+		then, err := partialEval(v.Then, known, false)
+		if err != nil {
+			return nil, err
+		}
+		els, err := partialEval(v.Else, known, false)
+		if err != nil {
+			return nil, err
+		}
+		return withChildren(v, []Expr{cond, then, els}), nil
+	case *BinaryOp:
+		if v.Op.Type == AND || v.Op.Type == OR {
+			left, err := partialEval(v.Left, known, certain)
+			if err != nil {
+				return nil, err
+			}
+			if !isLiteralValue(left) {
+				right, err := partialEval(v.Right, known, false)
+				if err != nil {
+					return nil, err
+				}
+				return withChildren(v, []Expr{left, right}), nil
+			}
+			if b, ok := literalCondition(left); !ok || b == (v.Op.Type == OR) {
+				// The right operand is never evaluated, the left one either
+				// deciding the result or being a string that is an error
+				return reduce(withChildren(v, []Expr{left, Clone(v.Right)}), certain)
+			}
+		}
+	}
+
+	children := Children(expr)
+	reduced := make([]Expr, len(children))
+	constant := true
+	for i, child := range children {
+		r, err := partialEval(child, known, certain)
+		if err != nil {
+			return nil, err
+		}
+		reduced[i] = r
+		constant = constant && isLiteralValue(r)
+	}
+	if len(children) == 0 {
+		return Clone(expr), nil
+	}
+	node := withChildren(expr, reduced)
+	if !constant {
+		return node, nil
+	}
+	if call, ok := node.(*FunctionCall); ok {
+		if b, known := lookupBuiltin(call.Name); !known || b.impure {
+			return node, nil
+		}
+	}
+	return reduce(node, certain)
+}
+
+// onlyDependsOn reports whether the value of expr is fixed once the named
+// variable is: it has no other free variable and calls only pure builtins.
+func onlyDependsOn(expr Expr, name string) bool {
+	for _, v := range Variables(expr) {
+		if v != name {
+			return false
+		}
+	}
+	for _, fn := range Functions(expr) {
+		if b, known := lookupBuiltin(fn); !known || b.impure {
+			return false
+		}
+	}
+	return true
+}
+
+// reduce replaces a node whose value no longer depends on any variable by
+// that value as a literal.
+func reduce(node Expr, certain bool) (Expr, error) {
+	value, err := EvalValue(node, nil)
+	if err != nil {
+		return reduceFailed(node, err, certain)
+	}
+	var literal Expr
+	switch {
+	case value.kind == StringKind:
+		literal = Str(value.str)
+	case value.kind == ListKind && value.list.isRange:
+		literal = Range(Num(value.list.lo), Num(value.list.hi))
+	case value.kind == ListKind:
+		elems := make([]Expr, len(value.list.items))
+		for i, x := range value.list.items {
+			elems[i] = Num(x)
+		}
+		literal = List(elems...)
+	case value.kind == DateKind:
+		literal = Call("date", Str(formatDate(value.date)))
+	case value.kind == DurationKind:
+		literal = durationLiteral(value.dur)
+	default:
+		literal = Num(value.num)
+	}
+	setSpan(literal, SpanOf(node))
+	return literal, nil
+}
+
+// reduceFailed returns the error of a node that could not be reduced when
+// it is certain to be evaluated, and otherwise leaves the node unreduced.
+func reduceFailed(node Expr, err error, certain bool) (Expr, error) {
+	if certain {
+		return nil, err
+	}
+	return node, nil
+}
+
+// isLiteralValue reports whether expr is a literal that Eval accepts: a
+// real number, a string, a list literal or range of real numbers, a
+// duration, or date called on a string, which stands for a date.
+func isLiteralValue(expr Expr) bool {
+	switch v := expr.(type) {
+	case *Number:
+		return !v.Imag
+	case *StringLiteral:
+		return true
+	case *QuantityLiteral:
+		_, ok := durationUnits[v.Unit]
+		return ok
+	case *FunctionCall:
+		if v.Name == "date" && len(v.Args) == 1 {
+			_, ok := v.Args[0].(*StringLiteral)
+			return ok
+		}
+	case *ListLiteral:
+		for _, elem := range v.Elems {
+			if !isNumberLiteral(elem) {
+				return false
+			}
+		}
+		return true
+	case *BinaryOp:
+		return v.Op.Type == DOTDOT && isNumberLiteral(v.Left) && isNumberLiteral(v.Right)
+	}
+	return false
+}
+
+// isNumberLiteral reports whether expr is a real number literal.
+func isNumberLiteral(expr Expr) bool {
+	n, ok := expr.(*Number)
+	return ok && !n.Imag
+}
+
+// literalCondition interprets a literal as a condition, reporting false
+// when it is a string, which is not one.
+func literalCondition(expr Expr) (b bool, ok bool) {
+	if n, isNumber := expr.(*Number); isNumber {
+		return isTrue(n.Value), true
+	}
+	return false, false
+}
+
+// end of file
@@ -0,0 +1,68 @@
+package expressionparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPartialEval(t *testing.T) {
+	tests := []struct {
+		input string
+		vars  map[string]float64
+		want  string
+	}{
+		{"price * qty * (1 + tax)", map[string]float64{"tax": 0.2}, "price * qty * 1.2"},
+		{"let r = rate / 100 in amount * r", map[string]float64{"rate": 5}, "amount * 0.05"},
+		{"flag ? a : b", map[string]float64{"flag": 0}, "b"},
+		{"on && x > 1 / 0", map[string]float64{}, "on && x > 1 / 0"}, // may be skipped
+		{"x < 2 || y", map[string]float64{"x": 1}, "1"},
+		{"x + y", nil, "x + y"},
+	}
+	for _, tt := range tests {
+		got, err := PartialEval(mustParse(t, tt.input), tt.vars)
+		if err != nil {
+			t.Errorf("PartialEval(%s, %v): %v", tt.input, tt.vars, err)
+			continue
+		}
+		if Format(got) != tt.want {
+			t.Errorf("PartialEval(%s, %v) = %s, want %s", tt.input, tt.vars, Format(got), tt.want)
+		}
+	}
+
+	// A constant division by zero always evaluated fails at once
+	if _, err := PartialEval(mustParse(t, "x + 1 / (k - 2)"), map[string]float64{"k": 2}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("PartialEval(x + 1 / (k - 2)) with k = 2: error %v, want ErrDivisionByZero", err)
+	}
+}
+
+func TestRelevantVariables(t *testing.T) {
+	tests := []struct {
+		input string
+		known map[string]float64
+		want  string
+	}{
+		{"flag ? a : b", map[string]float64{"flag": 1}, "a"},
+		{"flag ? a : b", map[string]float64{"flag": 0}, "b"},
+		{"flag ? a : b", nil, "flag a b"},
+		// A false left operand of && hides the right
+		{"on && x > limit", map[string]float64{"on": 0}, ""},
+		{"on && x > limit", map[string]float64{"on": 1}, "x limit"},
+		{"done || retries < max", map[string]float64{"done": 1}, ""},
+		// Nothing to prune
+		{"a * b + c", map[string]float64{"b": 2}, "a c"},
+		{"x * 0 + 1", nil, "x"}, // conservative
+		{"let r = rate / 100 in amount * r", map[string]float64{"amount": 10}, "rate"},
+	}
+	for _, tt := range tests {
+		got, err := RelevantVariables(mustParse(t, tt.input), tt.known)
+		if err != nil || strings.Join(got, " ") != tt.want {
+			t.Errorf("RelevantVariables(%s, %v) = %q, %v; want %q", tt.input, tt.known, got, err, tt.want)
+		}
+	}
+	if _, err := RelevantVariables(mustParse(t, "x + 1 / (k - 2)"), map[string]float64{"k": 2}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("RelevantVariables(x + 1 / (k - 2)) with k = 2: error %v, want ErrDivisionByZero", err)
+	}
+}
+
+// end of file
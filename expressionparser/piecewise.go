@@ -0,0 +1,145 @@
+package expressionparser
+
+import (
+	"fmt"
+)
+
+// Lazy forms: calls whose arguments are evaluated only as far as the result
+// needs them, rather than all before the call. piecewise(cond1, value1,
+// cond2, value2, ..., default), as in a tariff piecewise(kwh <= 100, 0.2,
+// kwh <= 500, 0.15, 0.1), tests its conditions in order and gives the value
+// after the first true one, evaluating that value alone; the trailing
+// default, given with an odd number of arguments, is the value when no
+// condition holds, and without one that is an error.
+var lazyForms = map[string]builtin{
+	"piecewise": {arity: 2, variadic: true},
+}
+
+// isLazyForm reports whether a call goes to a lazy form, one not replaced
+// by a function registered on the evaluator.
+func (ev *Evaluator) isLazyForm(call *FunctionCall) bool {
+	_, registered := ev.funcs[call.Name]
+	_, ok := lazyForms[call.Name]
+	return ok && !registered
+}
+
+// callLazyForm evaluates a call to a lazy form in env.
+func (ev *Evaluator) callLazyForm(call *FunctionCall, env *scope) (Value, error) {
+	if err := lazyForms[call.Name].checkArgs(call.Name, len(call.Args)); err != nil {
+		return Value{}, err
+	}
+	if ev.stats != nil {
+		ev.stats.stats.Calls[call.Name]++
+	}
+	return piecewise(call, func(i int) (Value, error) {
+		return ev.eval(call.Args[i], env)
+	})
+}
+
+// compileLazyForm compiles a call to a lazy form.
+func (ev *Evaluator) compileLazyForm(call *FunctionCall, lets []string, depth *int) closure {
+	if err := lazyForms[call.Name].checkArgs(call.Name, len(call.Args)); err != nil {
+		return fail(err)
+	}
+	args := make([]closure, len(call.Args))
+	for i, arg := range call.Args {
+		args[i] = ev.compileNode(arg, lets, depth)
+	}
+	return func(f *frame) (Value, error) {
+		return piecewise(call, func(i int) (Value, error) {
+			return args[i](f)
+		})
+	}
+}
+
+// piecewise applies piecewise to the arguments of call, arg evaluating
+// the one at an index.
+func piecewise(call *FunctionCall, arg func(i int) (Value, error)) (Value, error) {
+	n := len(call.Args)
+	for i := 0; i+1 < n; i += 2 {
+		value, err := arg(i)
+		if err != nil {
+			return Value{}, err
+		}
+		pos := -1
+		if span := SpanOf(call.Args[i]); span != (Span{}) {
+			pos = span.Start
+		}
+		holds, err := value.condition(call.Name, pos)
+		if err != nil {
+			return Value{}, err
+		}
+		if holds {
+			return arg(i + 1)
+		}
+	}
+	if n%2 == 1 {
+		return arg(n - 1)
+	}
+	return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("%s: no matching piece", call.Name))
+}
+
+// partialPiecewise reduces a call to piecewise as PartialEval does a
+// conditional: pieces whose condition becomes false are dropped, and the
+// call becomes the value of the first piece whose condition becomes true
+// when all those before it are dropped. Only the first condition left is
+// certain to be evaluated.
+func partialPiecewise(call *FunctionCall, known map[string]Expr, certain bool) (Expr, error) {
+	var args, dropped []Expr
+	for i := 0; i < len(call.Args); i += 2 {
+		first := len(args) == 0
+		if i+1 == len(call.Args) {
+			// The default
+			if first {
+				return partialEval(call.Args[i], known, certain)
+			}
+			value, err := partialEval(call.Args[i], known, false)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, value)
+			break
+		}
+		cond, err := partialEval(call.Args[i], known, certain && first)
+		if err != nil {
+			return nil, err
+		}
+		holds, ok := literalCondition(cond)
+		switch {
+		case ok && !holds:
+			dropped = append(dropped, cond, Clone(call.Args[i+1]))
+			continue
+		case ok && first:
+			return partialEval(call.Args[i+1], known, certain)
+		case ok || !isLiteralValue(cond):
+			value, err := partialEval(call.Args[i+1], known, false)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, cond, value)
+			if ok {
+				// The pieces after one that always holds are never reached
+				return &FunctionCall{Name: call.Name, Args: args, Span: call.Span}, nil
+			}
+			continue
+		}
+
+		// A condition that is a string, which evaluating the call reports
+		args = append(args, cond)
+		for _, arg := range call.Args[i+1:] {
+			args = append(args, Clone(arg))
+		}
+		node := &FunctionCall{Name: call.Name, Args: args, Span: call.Span}
+		if first {
+			return reduce(node, certain)
+		}
+		return node, nil
+	}
+	if len(args) == 0 {
+		// Every condition is false: evaluating the call reports that
+		return reduce(&FunctionCall{Name: call.Name, Args: dropped, Span: call.Span}, certain)
+	}
+	return &FunctionCall{Name: call.Name, Args: args, Span: call.Span}, nil
+}
+
+// end of file
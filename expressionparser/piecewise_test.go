@@ -0,0 +1,95 @@
+package expressionparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPiecewise(t *testing.T) {
+	tests := []struct {
+		input string
+		x     float64
+		want  float64
+	}{
+		// The first true condition wins
+		{"piecewise(x < 10, 1, x < 20, 2, 3)", 5, 1},
+		{"piecewise(x < 10, 1, x < 20, 2, 3)", 15, 2},
+		{"piecewise(x < 100, 1, x < 20, 2, 3)", 15, 1},
+		{"piecewise(1, 2, 3, 4, 5)", 0, 2},
+		// The default
+		{"piecewise(x < 10, 1, x < 20, 2, 3)", 25, 3},
+		{"piecewise(x > 100, 1, 3)", 15, 3},
+		// A tariff with bands
+		{"piecewise(x <= 100, x * 0.1, x <= 500, 10 + (x - 100) * 0.2, 90 + (x - 500) * 0.4)", 300, 50},
+		// Only the chosen value is evaluated
+		{"piecewise(x > 0, 1, x > 1, 1 / 0)", 15, 1},
+		{"piecewise(x < 0, 1 / 0, 7)", 15, 7},
+		{"piecewise(x > 0, 1, 1 / 0, 2)", 15, 1},
+		{"piecewise(x == 0, 1, 1 / x)", 0, 1},
+		{"piecewise(x == 0, 1, 1 / x)", 4, 0.25},
+	}
+	for _, tt := range tests {
+		expr := mustParse(t, tt.input)
+		vars := map[string]float64{"x": tt.x}
+		if got, err := EvalWithVars(expr, vars); err != nil || got != tt.want {
+			t.Errorf("%s with x = %v = %v, %v; want %v", tt.input, tt.x, got, err, tt.want)
+		}
+		f, err := NewEvaluator().CompileFunc(expr)
+		if err != nil {
+			t.Errorf("CompileFunc(%s): %v", tt.input, err)
+			continue
+		}
+		if got, err := f(vars); err != nil || got != tt.want {
+			t.Errorf("compiled %s with x = %v = %v, %v; want %v", tt.input, tt.x, got, err, tt.want)
+		}
+	}
+}
+
+func TestPiecewiseErrors(t *testing.T) {
+	vars := map[string]float64{"x": 15}
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{"piecewise(x > 100, 1, x > 50, 2)", CodeOutsideDomain, "piecewise: no matching piece"},
+		{"piecewise(x > 0, 1 / 0)", CodeDivisionByZero, "division by zero"},
+		{"piecewise(1)", CodeArgumentCount, "piecewise expects at least 2 argument(s), got 1"},
+		{"piecewise()", CodeArgumentCount, "piecewise expects at least 2 argument(s), got 0"},
+	}
+	for _, tt := range tests {
+		_, err := EvalWithVars(mustParse(t, tt.input), vars)
+		if ErrorCode(err) != tt.code {
+			t.Errorf("%s: error %v, want %s", tt.input, err, tt.code)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.msg) {
+			t.Errorf("%s: error %q, want %q", tt.input, err, tt.msg)
+		}
+	}
+
+	// Check knows the arity without evaluating
+	problems := Check(mustParse(t, "x + piecewise(1)"), CheckOptions{})
+	if len(problems) != 1 || problems[0].String() != "piecewise expects at least 2 argument(s), got 1 at offset 4" {
+		t.Errorf("Check found %v", problems)
+	}
+}
+
+func TestPiecewisePartialEval(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"piecewise(x < 10, 1, y < 20, 2, 3)", "piecewise(y < 20, 2, 3)"},
+		{"piecewise(x > 10, y, y, 2, 3)", "y"},
+		{"piecewise(x < 10, 1, x > 10, y)", "y"},
+	}
+	for _, tt := range tests {
+		got, err := PartialEval(mustParse(t, tt.input), map[string]float64{"x": 15})
+		if err != nil || Format(got) != tt.want {
+			t.Errorf("PartialEval(%s) = %v, %v; want %s", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,37 @@
+package expressionparser
+
+import "sync"
+
+// pooledParser is a parser together with the lexer it reads from, reused
+// through parserPool by ParseString and so by Evaluate, Validate and
+// Compile.
+type pooledParser struct {
+	Parser
+	lexer Lexer
+}
+
+var parserPool = sync.Pool{
+	New: func() interface{} {
+		return new(pooledParser)
+	},
+}
+
+// getParser takes a parser from the pool, reset to parse input as opts
+// configure it. It must be returned with putParser, by a deferred call so
+// that a panic returns it as well.
+func getParser(input string, opts []Option) *pooledParser {
+	p := parserPool.Get().(*pooledParser)
+	p.lexer.Reset(input)
+	p.Reset(&p.lexer, opts...)
+	return p
+}
+
+// putParser returns a parser to the pool, first dropping its references to
+// the input and the options so that the pool keeps neither alive.
+func putParser(p *pooledParser) {
+	p.lexer = Lexer{}
+	p.Parser = Parser{}
+	parserPool.Put(p)
+}
+
+// end of file
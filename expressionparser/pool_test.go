@@ -0,0 +1,87 @@
+package expressionparser
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestParserPoolConcurrent interleaves valid and invalid input, and options
+// the pooled parsers must not carry from one parse to the next, from many
+// goroutines; run it with -race.
+func TestParserPoolConcurrent(t *testing.T) {
+	tests := []struct {
+		input string
+		opts  []Option
+		want  string // the result, or the error
+	}{
+		{"(2 + 3) * 5", nil, "25"},
+		{"2 + ", nil, "expected number, name, string, '(', '[', or unary '-' or '!' but found end of input at offset 4"},
+		{"XIV + 1", []Option{WithRomanNumerals()}, "15"},
+		{"XIV + 1", nil, `undefined variable XIV in "XIV" at offset 0`},
+		{"((1))", []Option{WithMaxDepth(1)}, "expression nested more than 1 deep at offset 1"},
+		{"((1))", nil, "1"},
+		{"1 $ 2", nil, "Invalid character: $ at offset 2"},
+		{"1 / 0", nil, `division by zero in "1 / 0" at offset 0`},
+		{"let a = 2 in a ^ 10", nil, "1024"},
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 300; i++ {
+				tt := tests[(g+i)%len(tests)]
+				got, err := Evaluate(tt.input, tt.opts...)
+				s := fmt.Sprint(got)
+				if err != nil {
+					s = err.Error()
+				}
+				if s != tt.want {
+					t.Errorf("Evaluate(%q) with %d options = %s, want %s", tt.input, len(tt.opts), s, tt.want)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// TestParserPoolReleases checks that a parser returned to the pool holds
+// nothing of its input.
+func TestParserPoolReleases(t *testing.T) {
+	p := getParser("x + 1", []Option{WithMaxDepth(3)})
+	if _, err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	putParser(p)
+	if p.lexer.input != "" || p.cfg.maxDepth != 0 || p.curr != (Token{}) {
+		t.Errorf("pooled parser keeps input %q, depth %d, token %v", p.lexer.input, p.cfg.maxDepth, p.curr)
+	}
+}
+
+func BenchmarkEvaluate(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Evaluate("(2 + 3) * 5 - 4 / 2"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEvaluateUnpooled is BenchmarkEvaluate with a new lexer and
+// parser for each input, as before the pool, for comparison.
+func BenchmarkEvaluateUnpooled(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		expr, err := NewParser(NewLexer("(2 + 3) * 5 - 4 / 2")).Parse()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := Eval(expr); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,76 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ToPostfix renders an expression in reverse Polish notation with
+// space-separated items, e.g. "2 3 + 5 *". Unary minus is spelled "neg" and a
+// call is written after its arguments as name@argc, e.g. "1 2 max@2", and
+// a list literal after its elements as []@count. A quantity is written
+// value:unit, e.g. 10:km. A let
+// binding is its value, then its body, then let:name, an assignment its
+// value then =:name, and a conditional its three operands followed by "?:".
+func ToPostfix(expr Expr) string {
+	var items []string
+	items = appendPostfix(items, expr)
+	return strings.Join(items, " ")
+}
+
+// appendPostfix appends the postfix items of expr to items.
+func appendPostfix(items []string, expr Expr) []string {
+	switch v := expr.(type) {
+	case nil:
+		return items
+	case *Number:
+		return append(items, literalText(v))
+	case *Variable:
+		return append(items, v.Name)
+	case *CellRef:
+		return append(items, v.Ref)
+	case *CellRange:
+		return append(items, v.From+":"+v.To)
+	case *QuantityLiteral:
+		return append(items, formatNumber(v.Value)+":"+v.Unit)
+	case *StringLiteral:
+		return append(items, strconv.Quote(v.Value))
+	case *BinaryOp:
+		items = appendPostfix(items, v.Left)
+		items = appendPostfix(items, v.Right)
+		return append(items, operatorSymbol(v.Op))
+	case *UnaryOp:
+		items = appendPostfix(items, v.Operand)
+		if v.Op.Type == MINUS {
+			return append(items, "neg")
+		}
+		return append(items, operatorSymbol(v.Op))
+	case *FunctionCall:
+		for _, arg := range v.Args {
+			items = appendPostfix(items, arg)
+		}
+		return append(items, v.Name+"@"+strconv.Itoa(len(v.Args)))
+	case *ListLiteral:
+		for _, elem := range v.Elems {
+			items = appendPostfix(items, elem)
+		}
+		return append(items, "[]@"+strconv.Itoa(len(v.Elems)))
+	case *Let:
+		items = appendPostfix(items, v.Value)
+		items = appendPostfix(items, v.Body)
+		return append(items, "let:"+v.Name)
+	case *Assign:
+		items = appendPostfix(items, v.Value)
+		return append(items, "=:"+v.Name)
+	case *Conditional:
+		items = appendPostfix(items, v.Cond)
+		items = appendPostfix(items, v.Then)
+		items = appendPostfix(items, v.Else)
+		return append(items, "?:")
+	default:
+		return append(items, fmt.Sprintf("<%T>", expr))
+	}
+}
+
+// end of file
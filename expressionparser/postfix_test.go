@@ -0,0 +1,49 @@
+package expressionparser
+
+import "testing"
+
+func TestToPostfix(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"(2 + 3) * 5", "2 3 + 5 *"},
+		{"2 + 3 * 5", "2 3 5 * +"},
+		{"2 - 3 - 4", "2 3 - 4 -"},
+		{"2 - (3 - 4)", "2 3 4 - -"},
+		{"2 ^ 3 ^ 2", "2 3 2 ^ ^"},
+		{"-x + 1", "x neg 1 +"},
+		{"!a && b", "a ! b &&"},
+		{"max(1, 2) / min(x, y, 3)", "1 2 max@2 x y 3 min@3 /"},
+		{"[1, 2, x]", "1 2 x []@3"},
+		{"x > 0 ? x : -x", "x 0 > x x neg ?:"},
+		{"let a = 2 in a * a", "2 a a * let:a"},
+		{"10 km + 500 m", "10:km 500:m +"},
+		{`upper("a b")`, `"a b" upper@1`},
+		{"1.5e3 % 7", "1500 7 %"},
+	}
+	for _, tt := range tests {
+		expr, err := ParseString(tt.input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", tt.input, err)
+		}
+		if got := ToPostfix(expr); got != tt.want {
+			t.Errorf("ToPostfix(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestToPostfixOperandOrder(t *testing.T) {
+	// The left operand of a non-commutative operator comes first
+	for _, op := range []string{"-", "/", "%", "^", "<", "<<"} {
+		expr, err := ParseString("a " + op + " b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := ToPostfix(expr), "a b "+op; got != want {
+			t.Errorf("ToPostfix(a %s b) = %q, want %q", op, got, want)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,376 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Operator spellings used when printing expressions
+var operatorSymbols = map[TokenType]string{
+	PLUS:   "+",
+	MINUS:  "-",
+	MULT:   "*",
+	DIV:    "/",
+	MOD:    "%",
+	POW:    "^",
+	LT:     "<",
+	LE:     "<=",
+	GT:     ">",
+	GE:     ">=",
+	EQ:     "==",
+	NE:     "!=",
+	AND:    "&&",
+	OR:     "||",
+	NOT:    "!",
+	DOTDOT: "..",
+	BITAND: "&",
+	BITOR:  "|",
+	BITXOR: "~",
+	SHL:    "<<",
+	SHR:    ">>",
+	MATCH:  "=~",
+}
+
+// Precedence levels, higher binds tighter
+const (
+	precLowest = iota
+	precConditional
+	precOr
+	precAnd
+	precBitOr
+	precBitXor
+	precBitAnd
+	precEquality
+	precComparison
+	precRange
+	precShift
+	precAdditive
+	precMultiplicative
+	precUnary
+	precPower
+	precAtom
+)
+
+// precedence returns the binding strength of a binary operator.
+func precedence(t TokenType) int {
+	switch t {
+	case OR:
+		return precOr
+	case AND:
+		return precAnd
+	case BITOR:
+		return precBitOr
+	case BITXOR:
+		return precBitXor
+	case BITAND:
+		return precBitAnd
+	case EQ, NE, MATCH:
+		return precEquality
+	case LT, LE, GT, GE:
+		return precComparison
+	case DOTDOT:
+		return precRange
+	case SHL, SHR:
+		return precShift
+	case PLUS, MINUS:
+		return precAdditive
+	case MULT, DIV, MOD:
+		return precMultiplicative
+	case POW:
+		return precPower
+	}
+	return precLowest
+}
+
+// exprPrecedence returns the binding strength of the operator at the root of expr.
+func exprPrecedence(expr Expr) int {
+	switch v := expr.(type) {
+	case *BinaryOp:
+		return precedence(v.Op.Type)
+	case *UnaryOp:
+		return precUnary
+	case *Let, *Assign:
+		// The body of a let and the value of an assignment extend as far right as possible
+		return precLowest
+	case *Conditional:
+		return precConditional
+	case *Number:
+		// A negative literal prints with a leading minus, so treat it like a unary operator
+		if v.Value < 0 {
+			return precUnary
+		}
+	case *QuantityLiteral:
+		if v.Value < 0 {
+			return precUnary
+		}
+	}
+	return precAtom
+}
+
+// operatorSymbol returns the printed form of an operator token.
+func operatorSymbol(op Token) string {
+	if s, ok := operatorSymbols[op.Type]; ok {
+		return s
+	}
+	return op.Value
+}
+
+// formatNumber renders a numeric literal so that the lexer reads it back unchanged.
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// literalText renders a literal like formatNumber, with an i suffix when it is imaginary.
+func literalText(n *Number) string {
+	return imagSuffix(formatNumber(n.Value), n)
+}
+
+// imagSuffix appends the imaginary unit to the rendering s of n when n is imaginary.
+func imagSuffix(s string, n *Number) string {
+	if n.Imag {
+		return s + "i"
+	}
+	return s
+}
+
+// Formatter renders expressions in infix notation. The zero value produces
+// the default output of Format: single spaces around binary operators,
+// minimal parentheses and the shortest 'g' form of numbers.
+type Formatter struct {
+	// Compact omits the spaces around binary operators and after commas.
+	Compact bool
+
+	// AlwaysParens parenthesizes every operator application below the root,
+	// making grouping explicit regardless of precedence.
+	AlwaysParens bool
+
+	// FloatFormat is the strconv.FormatFloat verb for numbers ('e', 'E',
+	// 'f', 'g' or 'G'); zero means 'g'.
+	FloatFormat byte
+
+	// Precision is the strconv.FormatFloat precision. It applies only when
+	// FloatFormat is set; -1 means the shortest exact representation. A
+	// limited precision rounds literals, so the output may no longer parse
+	// back to an equal tree.
+	Precision int
+}
+
+// Format renders an expression in infix notation, inserting parentheses only
+// where precedence or associativity requires them. The output parses back to
+// a structurally equal tree.
+func Format(expr Expr) string {
+	return Formatter{}.Format(expr)
+}
+
+// Format renders expr according to the formatter's options.
+func (f Formatter) Format(expr Expr) string {
+	var sb strings.Builder
+	f.write(&sb, expr)
+	return sb.String()
+}
+
+// number renders a literal with the configured verb and precision.
+func (f Formatter) number(v float64) string {
+	if f.FloatFormat == 0 {
+		return formatNumber(v)
+	}
+	return strconv.FormatFloat(v, f.FloatFormat, f.Precision, 64)
+}
+
+// write appends the infix form of expr to sb.
+func (f Formatter) write(sb *strings.Builder, expr Expr) {
+	space := " "
+	if f.Compact {
+		space = ""
+	}
+
+	switch v := expr.(type) {
+	case nil:
+		return
+	case *Number:
+		sb.WriteString(imagSuffix(f.number(v.Value), v))
+	case *BinaryOp:
+		prec := precedence(v.Op.Type)
+		f.operand(sb, v.Left, needsParens(v.Left, prec, false))
+		sb.WriteString(space)
+		sb.WriteString(operatorSymbol(v.Op))
+		sb.WriteString(space)
+		f.operand(sb, v.Right, needsParens(v.Right, prec, true))
+	case *UnaryOp:
+		sb.WriteString(operatorSymbol(v.Op))
+		f.operand(sb, v.Operand, exprPrecedence(v.Operand) < precUnary)
+	case *Variable:
+		sb.WriteString(v.Name)
+	case *CellRef:
+		sb.WriteString(v.Ref)
+	case *CellRange:
+		sb.WriteString(v.From + ":" + v.To)
+	case *QuantityLiteral:
+		sb.WriteString(f.number(v.Value))
+		sb.WriteString(" ")
+		sb.WriteString(v.Unit)
+	case *StringLiteral:
+		sb.WriteString(strconv.Quote(v.Value))
+	case *FunctionCall:
+		sb.WriteString(v.Name)
+		sb.WriteString("(")
+		for i, arg := range v.Args {
+			if i > 0 {
+				sb.WriteString(",")
+				sb.WriteString(space)
+			}
+			f.write(sb, arg)
+		}
+		sb.WriteString(")")
+	case *ListLiteral:
+		sb.WriteString("[")
+		for i, elem := range v.Elems {
+			if i > 0 {
+				sb.WriteString(",")
+				sb.WriteString(space)
+			}
+			f.write(sb, elem)
+		}
+		sb.WriteString("]")
+	case *Let:
+		sb.WriteString("let ")
+		sb.WriteString(v.Name)
+		sb.WriteString(space)
+		sb.WriteString("=")
+		sb.WriteString(space)
+		f.write(sb, v.Value)
+		sb.WriteString(" in ")
+		f.write(sb, v.Body)
+	case *Assign:
+		sb.WriteString(v.Name)
+		sb.WriteString(space)
+		sb.WriteString("=")
+		sb.WriteString(space)
+		f.write(sb, v.Value)
+	case *Conditional:
+		// Right associative: a nested conditional needs parentheses only as the condition
+		f.operand(sb, v.Cond, exprPrecedence(v.Cond) <= precConditional)
+		sb.WriteString(space + "?" + space)
+		f.operand(sb, v.Then, false)
+		if space == "" && edgeIsCell(v.Then, true) && edgeIsCell(v.Else, false) {
+			// A1:B1 would read back as a range
+			sb.WriteString(" :")
+		} else {
+			sb.WriteString(space + ":" + space)
+		}
+		f.operand(sb, v.Else, exprPrecedence(v.Else) < precConditional)
+	default:
+		sb.WriteString(fmt.Sprintf("<%T>", expr))
+	}
+}
+
+// edgeIsCell reports whether the last operand printed of expr, or the first
+// when last is false, may be a cell reference.
+func edgeIsCell(expr Expr, last bool) bool {
+	for {
+		switch expr.(type) {
+		case *CellRef:
+			return true
+		case *BinaryOp, *UnaryOp, *Conditional:
+			children := Children(expr)
+			if last {
+				expr = children[len(children)-1]
+			} else {
+				expr = children[0]
+			}
+		default:
+			return false
+		}
+	}
+}
+
+// operand appends an operand, wrapped in parentheses when required or when
+// AlwaysParens is set and the operand is itself an operator application.
+func (f Formatter) operand(sb *strings.Builder, expr Expr, parens bool) {
+	if f.AlwaysParens && exprPrecedence(expr) != precAtom {
+		parens = true
+	}
+	if parens {
+		sb.WriteString("(")
+	}
+	f.write(sb, expr)
+	if parens {
+		sb.WriteString(")")
+	}
+}
+
+// needsParens reports whether an operand of an operator with the given
+// precedence must be parenthesized. Exponentiation is right associative and
+// every other binary operator left associative, so an operand of equal
+// precedence on the non-associative side keeps its parentheses.
+func needsParens(operand Expr, parentPrec int, right bool) bool {
+	prec := exprPrecedence(operand)
+	if prec < parentPrec {
+		return true
+	}
+	if prec != parentPrec {
+		return false
+	}
+	if parentPrec == precPower {
+		return !right
+	}
+	return right
+}
+
+// String renders the number in infix form.
+func (n *Number) String() string {
+	return Format(n)
+}
+
+// String renders the quantity with its unit.
+func (q *QuantityLiteral) String() string {
+	return Format(q)
+}
+
+// String renders the string literal quoted, as it is parsed.
+func (s *StringLiteral) String() string {
+	return Format(s)
+}
+
+// String renders the list literal in brackets.
+func (l *ListLiteral) String() string {
+	return Format(l)
+}
+
+// String renders the operation in infix form with minimal parentheses.
+func (b *BinaryOp) String() string {
+	return Format(b)
+}
+
+// String renders the unary operation in infix form.
+func (u *UnaryOp) String() string {
+	return Format(u)
+}
+
+// String returns the variable name.
+func (v *Variable) String() string {
+	return Format(v)
+}
+
+// String renders the call with its arguments.
+func (c *FunctionCall) String() string {
+	return Format(c)
+}
+
+// String renders the let binding.
+func (l *Let) String() string {
+	return Format(l)
+}
+
+// String renders the assignment.
+func (a *Assign) String() string {
+	return Format(a)
+}
+
+// String renders the conditional.
+func (c *Conditional) String() string {
+	return Format(c)
+}
+
+// end of file
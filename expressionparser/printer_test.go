@@ -0,0 +1,90 @@
+package expressionparser_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	ep "github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser"
+	"github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser/exprtest"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		expr ep.Expr
+		want string
+	}{
+		{ep.Mul(ep.Add(ep.Num(2), ep.Num(3)), ep.Num(5)), "(2 + 3) * 5"},
+		{ep.Add(ep.Num(2), ep.Mul(ep.Num(3), ep.Num(5))), "2 + 3 * 5"},
+		{ep.Sub(ep.Num(2), ep.Sub(ep.Num(3), ep.Num(4))), "2 - (3 - 4)"},
+		{ep.Sub(ep.Sub(ep.Num(2), ep.Num(3)), ep.Num(4)), "2 - 3 - 4"},
+		{ep.Pow(ep.Num(2), ep.Pow(ep.Num(3), ep.Num(2))), "2 ^ 3 ^ 2"},
+		{ep.Pow(ep.Pow(ep.Num(2), ep.Num(3)), ep.Num(2)), "(2 ^ 3) ^ 2"},
+		{ep.Neg(ep.Add(ep.Var("x"), ep.Num(1))), "-(x + 1)"},
+		{ep.Call("max", ep.Add(ep.Var("x"), ep.Num(1)), ep.Num(2)), "max(x + 1, 2)"},
+	}
+	for _, tt := range tests {
+		if got := ep.Format(tt.expr); got != tt.want {
+			t.Errorf("Format(%s) = %q, want %q", ep.ToSExpr(tt.expr), got, tt.want)
+		}
+	}
+}
+
+// groupingParens returns the offsets of the parentheses in text that group
+// rather than enclose the arguments of a call, as pairs of opening and
+// closing positions.
+func groupingParens(text string) [][2]int {
+	var pairs [][2]int
+	var open []int
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			open = append(open, i)
+		case ')':
+			start := open[len(open)-1]
+			open = open[:len(open)-1]
+			if start == 0 || !isNameByte(text[start-1]) {
+				pairs = append(pairs, [2]int{start, i})
+			}
+		}
+	}
+	return pairs
+}
+
+// isNameByte reports whether b may end a function name.
+func isNameByte(b byte) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+func TestFormatRoundTripMinimal(t *testing.T) {
+	cfg := exprtest.GenConfig{
+		Operators: []string{"||", "&&", "|", "~", "&", "==", "!=", "<", "<=", ">", ">=", "<<", ">>", "+", "-", "*", "/", "%", "^"},
+		Unary:     []string{"-", "!"},
+		Variables: []string{"x", "y"},
+		Functions: map[string]int{"max": 2, "abs": 1},
+		MaxDepth:  5,
+	}
+	for seed := int64(0); seed < 2000; seed++ {
+		text, expr := exprtest.Generate(rand.New(rand.NewSource(seed)), cfg)
+		printed := ep.Format(expr)
+		reparsed, err := ep.ParseString(printed)
+		if err != nil {
+			t.Fatalf("seed %d: %q prints as %q, which does not parse: %v", seed, text, printed, err)
+		}
+		if !ep.Equal(reparsed, expr) {
+			t.Fatalf("seed %d: %q prints as %q, which parses as %s", seed, text, printed, ep.ToSExpr(reparsed))
+		}
+
+		// Every pair of parentheses must be needed: without it the text
+		// fails to parse or parses differently
+		for _, pair := range groupingParens(printed) {
+			without := printed[:pair[0]] + printed[pair[0]+1:pair[1]] + printed[pair[1]+1:]
+			without = strings.Replace(without, "--", "- -", -1)
+			if e, err := ep.ParseString(without); err == nil && ep.Equal(e, expr) {
+				t.Fatalf("seed %d: %q prints as %q, whose parentheses at %d are not needed", seed, text, printed, pair[0])
+			}
+		}
+	}
+}
+
+// end of file
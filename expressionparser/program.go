@@ -0,0 +1,514 @@
+package expressionparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Opcodes of the postfix bytecode. Operands follow the opcode as uvarints.
+const (
+	opConst byte = iota + 1 // index into constants; push it
+	opVar                   // index into names; push the variable's value
+	opLoad                  // local slot; push its value
+	opStore                 // local slot; pop a value into it
+	opAdd
+	opSub
+	opMul
+	opDiv
+	opPow
+	opNeg
+	opCall // index into funcs, argument count; pop the arguments, push the result
+	opLess
+	opLessEq
+	opGreater
+	opGreaterEq
+	opEq
+	opNotEq
+	opAnd // no longer emitted: && and || compile to jumps
+	opOr
+	opNot
+	opJumpIfFalse // forward offset; pop a condition, skip ahead when it is false
+	opJump        // forward offset; skip ahead unconditionally
+	opMod
+	opBitAnd
+	opBitOr
+	opBitXor
+	opShl
+	opShr
+	opEval // index into trees, variable count; pop the tree's variables, push its value
+)
+
+// Opcode mnemonics used by Disassemble
+var opNames = map[byte]string{
+	opConst: "CONST", opVar: "VAR", opLoad: "LOAD", opStore: "STORE",
+	opAdd: "ADD", opSub: "SUB", opMul: "MUL", opDiv: "DIV", opPow: "POW", opNeg: "NEG",
+	opCall: "CALL", opLess: "LT", opLessEq: "LE", opGreater: "GT", opGreaterEq: "GE",
+	opEq: "EQ", opNotEq: "NE", opAnd: "AND", opOr: "OR", opNot: "NOT",
+	opJumpIfFalse: "JUMPF", opJump: "JUMP", opMod: "MOD",
+	opBitAnd: "BITAND", opBitOr: "BITOR", opBitXor: "BITXOR", opShl: "SHL", opShr: "SHR",
+	opEval: "EVAL",
+}
+
+// Number of uvarint operands of each opcode
+var opOperands = map[byte]int{
+	opConst: 1, opVar: 1, opLoad: 1, opStore: 1, opCall: 2, opJumpIfFalse: 1, opJump: 1, opEval: 2,
+}
+
+// Binary operator opcodes by token type
+var binaryOpcodes = map[TokenType]byte{
+	PLUS: opAdd, MINUS: opSub, MULT: opMul, DIV: opDiv, MOD: opMod, POW: opPow,
+	LT: opLess, LE: opLessEq, GT: opGreater, GE: opGreaterEq, EQ: opEq, NE: opNotEq,
+	BITAND: opBitAnd, BITOR: opBitOr, BITXOR: opBitXor, SHL: opShl, SHR: opShr,
+}
+
+// Bitwise operators by opcode, applied by bitwise
+var bitwiseOperators = map[byte]Token{
+	opBitAnd: {Type: BITAND}, opBitOr: {Type: BITOR}, opBitXor: {Type: BITXOR},
+	opShl: {Type: SHL}, opShr: {Type: SHR},
+}
+
+// Program is an expression compiled to a compact postfix bytecode: an
+// opcode stream with a constants pool, a table of the free variable names
+// pushed by VAR, a table of the function names used by CALL, the subtrees
+// evaluated by EVAL, and the number of local slots needed for let bindings.
+type Program struct {
+	code      []byte
+	constants []float64
+	names     []string
+	funcs     []string
+	trees     []programTree
+	locals    int
+
+	calls []programCall // the functions of funcs, looked up by link
+}
+
+// programTree is a subtree the bytecode has no instructions for, evaluated
+// as Eval does with the values of its free variables, which EVAL pops in
+// the order of names.
+type programTree struct {
+	expr  Expr
+	names []string
+}
+
+// Names returns the free variables of the program in slot order.
+func (p *Program) Names() []string {
+	return append([]string(nil), p.names...)
+}
+
+// CompileProgram compiles expr to bytecode, for Run. Functions are
+// referenced by name and looked up among the builtins when the program is
+// compiled or decoded; an unknown one gives an error only when it is called.
+//
+// Every tree the parser produces compiles. The bytecode covers numbers and
+// the operators, builtins, let bindings and conditionals on them; a tree
+// that may hold other values, such as strings, lists or quantities, compiles
+// to a single EVAL of the whole tree, and calls to the special and iteration
+// forms, such as deriv and sum(i, 1, n, i^2), to an EVAL of the call.
+func CompileProgram(expr Expr) (*Program, error) {
+	c := &programCompiler{prog: &Program{}, constIdx: map[uint64]int{}, nameIdx: map[string]int{}, funcIdx: map[string]int{}}
+	if !programmable(expr) {
+		c.compileTree(expr, nil)
+	} else if err := c.compile(expr, nil); err != nil {
+		return nil, err
+	}
+	c.prog.link()
+	return c.prog, nil
+}
+
+// programCompiler tracks the pools while emitting code.
+type programCompiler struct {
+	prog     *Program
+	constIdx map[uint64]int
+	nameIdx  map[string]int
+	funcIdx  map[string]int
+}
+
+// localScope maps let-bound names to local slots.
+type localScope struct {
+	name   string
+	slot   int
+	parent *localScope
+}
+
+// emit appends an opcode and its operands.
+func (c *programCompiler) emit(op byte, operands ...int) {
+	c.prog.code = append(c.prog.code, op)
+	for _, operand := range operands {
+		c.prog.code = binary.AppendUvarint(c.prog.code, uint64(operand))
+	}
+}
+
+// index returns the position of key in a pool, adding it when new.
+func index[K comparable](m map[K]int, key K, add func()) int {
+	if i, ok := m[key]; ok {
+		return i
+	}
+	i := len(m)
+	m[key] = i
+	add()
+	return i
+}
+
+// compile emits the code for expr.
+func (c *programCompiler) compile(expr Expr, locals *localScope) error {
+	switch v := expr.(type) {
+	case *Number:
+		if v.Imag {
+			c.compileTree(v, locals)
+			return nil
+		}
+		i := index(c.constIdx, math.Float64bits(v.Value), func() { c.prog.constants = append(c.prog.constants, v.Value) })
+		c.emit(opConst, i)
+	case *Variable:
+		for s := locals; s != nil; s = s.parent {
+			if s.name == v.Name {
+				c.emit(opLoad, s.slot)
+				return nil
+			}
+		}
+		i := index(c.nameIdx, v.Name, func() { c.prog.names = append(c.prog.names, v.Name) })
+		c.emit(opVar, i)
+	case *UnaryOp:
+		op := opNeg
+		switch v.Op.Type {
+		case MINUS:
+		case NOT:
+			op = opNot
+		default:
+			c.compileTree(v, locals)
+			return nil
+		}
+		if err := c.compile(v.Operand, locals); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *BinaryOp:
+		// Short-circuit a && b as a ? !!b : 0, and a || b as a ? 1 : !!b
+		switch v.Op.Type {
+		case AND:
+			return c.compile(&Conditional{Cond: v.Left, Then: Not(Not(v.Right)), Else: Num(0)}, locals)
+		case OR:
+			return c.compile(&Conditional{Cond: v.Left, Then: Num(1), Else: Not(Not(v.Right))}, locals)
+		}
+		op, ok := binaryOpcodes[v.Op.Type]
+		if !ok {
+			c.compileTree(v, locals)
+			return nil
+		}
+		if err := c.compile(v.Left, locals); err != nil {
+			return err
+		}
+		if err := c.compile(v.Right, locals); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *FunctionCall:
+		if !numericCall(v) {
+			c.compileTree(v, locals)
+			return nil
+		}
+		for _, arg := range v.Args {
+			if !callableBuiltin(v) {
+				// The call fails before its arguments are evaluated, so
+				// placeholders stand in for them
+				arg = Num(0)
+			}
+			if err := c.compile(arg, locals); err != nil {
+				return err
+			}
+		}
+		i := index(c.funcIdx, v.Name, func() { c.prog.funcs = append(c.prog.funcs, v.Name) })
+		c.emit(opCall, i, len(v.Args))
+	case *Let:
+		if err := c.compile(v.Value, locals); err != nil {
+			return err
+		}
+		slot := c.prog.locals
+		c.prog.locals++
+		c.emit(opStore, slot)
+		return c.compile(v.Body, &localScope{name: v.Name, slot: slot, parent: locals})
+	case *Conditional:
+		// cond JUMPF(then+jump) then JUMP(else) else
+		if err := c.compile(v.Cond, locals); err != nil {
+			return err
+		}
+		then, err := c.compileBranch(v.Then, locals)
+		if err != nil {
+			return err
+		}
+		els, err := c.compileBranch(v.Else, locals)
+		if err != nil {
+			return err
+		}
+		jump := binary.AppendUvarint([]byte{opJump}, uint64(len(els)))
+		c.emit(opJumpIfFalse, len(then)+len(jump))
+		c.prog.code = append(c.prog.code, then...)
+		c.prog.code = append(c.prog.code, jump...)
+		c.prog.code = append(c.prog.code, els...)
+	default:
+		c.compileTree(expr, locals)
+	}
+	return nil
+}
+
+// compileTree emits an EVAL of expr, after pushing the values of its free
+// variables.
+func (c *programCompiler) compileTree(expr Expr, locals *localScope) {
+	names := Variables(expr)
+	for _, name := range names {
+		c.compile(&Variable{Name: name}, locals)
+	}
+	c.prog.trees = append(c.prog.trees, programTree{expr: expr, names: names})
+	c.emit(opEval, len(c.prog.trees)-1, len(names))
+}
+
+// programmable reports whether every value in expr is a number, so that
+// the bytecode can evaluate it, leaving to EVAL only the calls that always
+// give a number, to the special and iteration forms.
+func programmable(expr Expr) bool {
+	switch v := expr.(type) {
+	case *Number:
+		return !v.Imag
+	case *Variable:
+		return true
+	case *UnaryOp:
+		return (v.Op.Type == MINUS || v.Op.Type == NOT) && programmable(v.Operand)
+	case *BinaryOp:
+		if _, ok := binaryOpcodes[v.Op.Type]; !ok && v.Op.Type != AND && v.Op.Type != OR {
+			return false
+		}
+		return programmable(v.Left) && programmable(v.Right)
+	case *Let:
+		return programmable(v.Value) && programmable(v.Body)
+	case *Conditional:
+		return programmable(v.Cond) && programmable(v.Then) && programmable(v.Else)
+	case *FunctionCall:
+		if defaultEvaluator.isSpecialForm(v) || defaultEvaluator.isIterationForm(v) {
+			return true
+		}
+		if !numericCall(v) {
+			return false
+		}
+		if !callableBuiltin(v) {
+			return true
+		}
+		for _, arg := range v.Args {
+			if !programmable(arg) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// numericCall reports whether call goes to a function of numbers the
+// bytecode calls with CALL, rather than to a string or list function or a
+// form that evaluates its arguments itself.
+func numericCall(call *FunctionCall) bool {
+	for _, form := range []map[string]builtin{specialForms, iterationForms, lazyForms} {
+		if _, ok := form[call.Name]; ok {
+			return false
+		}
+	}
+	_, str := stringBuiltins[call.Name]
+	_, list := aggregates[call.Name]
+	return !str && !list
+}
+
+// callableBuiltin reports whether call names a builtin and passes it a
+// number of arguments it accepts.
+func callableBuiltin(call *FunctionCall) bool {
+	b, ok := lookupBuiltin(call.Name)
+	return ok && b.checkArgs(call.Name, len(call.Args)) == nil
+}
+
+// compileBranch compiles expr into a separate code buffer, sharing the
+// pools and local slots, so that jumps over it can be sized.
+func (c *programCompiler) compileBranch(expr Expr, locals *localScope) ([]byte, error) {
+	code := c.prog.code
+	c.prog.code = nil
+	err := c.compile(expr, locals)
+	branch := c.prog.code
+	c.prog.code = code
+	return branch, err
+}
+
+// Disassemble lists the program one instruction per line with its byte
+// offset, resolving pool references in comments.
+func (p *Program) Disassemble() string {
+	var sb strings.Builder
+	for pc := 0; pc < len(p.code); {
+		start := pc
+		op := p.code[pc]
+		pc++
+
+		operands := make([]int, opOperands[op])
+		for i := range operands {
+			n, size := binary.Uvarint(p.code[pc:])
+			if size <= 0 {
+				fmt.Fprintf(&sb, "%04d %s <truncated>\n", start, opNames[op])
+				return sb.String()
+			}
+			operands[i] = int(n)
+			pc += size
+		}
+
+		fmt.Fprintf(&sb, "%04d %s", start, opNames[op])
+		for _, operand := range operands {
+			fmt.Fprintf(&sb, " %d", operand)
+		}
+		switch {
+		case op == opConst && operands[0] < len(p.constants):
+			fmt.Fprintf(&sb, " ; %s", formatNumber(p.constants[operands[0]]))
+		case op == opVar && operands[0] < len(p.names):
+			fmt.Fprintf(&sb, " ; %s", p.names[operands[0]])
+		case op == opCall && operands[0] < len(p.funcs):
+			fmt.Fprintf(&sb, " ; %s", p.funcs[operands[0]])
+		case op == opJumpIfFalse || op == opJump:
+			fmt.Fprintf(&sb, " ; -> %04d", pc+operands[0])
+		case op == opEval && operands[0] < len(p.trees):
+			fmt.Fprintf(&sb, " ; %s", Format(p.trees[operands[0]].expr))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// programFormatVersion is the first byte of a marshalled Program. Version 2
+// added the trees of EVAL; version 1 encodings, which have none, still
+// decode.
+const programFormatVersion byte = 2
+
+// MarshalBinary encodes the program in a versioned binary form, the trees
+// evaluated by EVAL in the form of Encode.
+func (p *Program) MarshalBinary() ([]byte, error) {
+	buf := []byte{programFormatVersion}
+	buf = binary.AppendUvarint(buf, uint64(len(p.code)))
+	buf = append(buf, p.code...)
+	buf = binary.AppendUvarint(buf, uint64(len(p.constants)))
+	for _, c := range p.constants {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(c))
+	}
+	for _, table := range [][]string{p.names, p.funcs} {
+		buf = binary.AppendUvarint(buf, uint64(len(table)))
+		for _, s := range table {
+			buf = binary.AppendUvarint(buf, uint64(len(s)))
+			buf = append(buf, s...)
+		}
+	}
+	buf = binary.AppendUvarint(buf, uint64(p.locals))
+	buf = binary.AppendUvarint(buf, uint64(len(p.trees)))
+	for _, tree := range p.trees {
+		buf = binary.AppendUvarint(buf, uint64(len(tree.names)))
+		for _, name := range tree.names {
+			buf = binary.AppendUvarint(buf, uint64(len(name)))
+			buf = append(buf, name...)
+		}
+		var enc bytes.Buffer
+		if err := Encode(&enc, tree.expr); err != nil {
+			return nil, err
+		}
+		buf = binary.AppendUvarint(buf, uint64(enc.Len()))
+		buf = append(buf, enc.Bytes()...)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a program written by MarshalBinary.
+func (p *Program) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	version, err := r.ReadByte()
+	if err != nil {
+		return withCode(CodeDecode, fmt.Errorf("truncated program encoding"))
+	}
+	if version != 1 && version != programFormatVersion {
+		return withCode(CodeDecode, fmt.Errorf("unsupported program encoding version %d", version))
+	}
+
+	readLen := func() (int, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil || n > uint64(r.Len()) {
+			return 0, withCode(CodeDecode, fmt.Errorf("truncated program encoding"))
+		}
+		return int(n), nil
+	}
+
+	var q Program
+	n, err := readLen()
+	if err != nil {
+		return err
+	}
+	q.code = make([]byte, n)
+	r.Read(q.code)
+
+	if n, err = readLen(); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		var bits [8]byte
+		if _, err := r.Read(bits[:]); err != nil {
+			return withCode(CodeDecode, fmt.Errorf("truncated program encoding"))
+		}
+		q.constants = append(q.constants, math.Float64frombits(binary.LittleEndian.Uint64(bits[:])))
+	}
+
+	readTable := func(table *[]string) error {
+		n, err := readLen()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			size, err := readLen()
+			if err != nil {
+				return err
+			}
+			s := make([]byte, size)
+			r.Read(s)
+			*table = append(*table, string(s))
+		}
+		return nil
+	}
+	for _, table := range []*[]string{&q.names, &q.funcs} {
+		if err := readTable(table); err != nil {
+			return err
+		}
+	}
+
+	locals, err := binary.ReadUvarint(r)
+	if err != nil {
+		return withCode(CodeDecode, fmt.Errorf("truncated program encoding"))
+	}
+	q.locals = int(locals)
+
+	if version > 1 {
+		if n, err = readLen(); err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			var tree programTree
+			if err := readTable(&tree.names); err != nil {
+				return err
+			}
+			size, err := readLen()
+			if err != nil {
+				return err
+			}
+			enc := make([]byte, size)
+			r.Read(enc)
+			if tree.expr, err = Decode(bytes.NewReader(enc)); err != nil {
+				return err
+			}
+			q.trees = append(q.trees, tree)
+		}
+	}
+	q.link()
+
+	*p = q
+	return nil
+}
+
+// end of file
@@ -0,0 +1,236 @@
+package expressionparser
+
+import (
+	"testing"
+)
+
+// runProgram compiles input to a Program and runs it with vars.
+func runProgram(t *testing.T, input string, vars map[string]float64) (float64, error) {
+	t.Helper()
+	expr, err := ParseString(input)
+	if err != nil {
+		t.Fatalf("ParseString(%q): %v", input, err)
+	}
+	prog, err := CompileProgram(expr)
+	if err != nil {
+		t.Fatalf("CompileProgram(%q): %v", input, err)
+	}
+	slots, err := prog.Slots(vars)
+	if err != nil {
+		t.Fatalf("Slots(%q): %v", input, err)
+	}
+	return prog.Run(slots)
+}
+
+func TestProgramBitwise(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"a & b", 8},
+		{"a | b", 14},
+		{"a ~ b", 6},
+		{"a << 2", 48},
+		{"a >> 2", 3},
+		{"-a >> 1", -6},
+		{"(a & b) | 1 << 4", 24},
+	}
+	vars := map[string]float64{"a": 12, "b": 10}
+	for _, tt := range tests {
+		got, err := runProgram(t, tt.input, vars)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.input, err)
+			continue
+		}
+		want, err := EvaluateWithVars(tt.input, vars)
+		if err != nil || want != tt.want {
+			t.Fatalf("EvaluateWithVars(%q) = %v, %v; want %v", tt.input, want, err, tt.want)
+		}
+		if got != tt.want {
+			t.Errorf("%q = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestProgramBitwiseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+	}{
+		{"1.5 & 1", CodeOutsideDomain},
+		{"1 | 0.5", CodeOutsideDomain},
+		{"1 << 64", CodeOutsideDomain},
+		{"1 >> -1", CodeOutsideDomain},
+		{"1 << 2.5", CodeOutsideDomain},
+		{"(2^53 + 2) | 1", CodeOverflow},
+		{"2^62 << 2", CodeOverflow},
+	}
+	for _, tt := range tests {
+		_, err := runProgram(t, tt.input, nil)
+		if err == nil {
+			t.Errorf("%q: no error, want %s", tt.input, tt.code)
+			continue
+		}
+		if got := ErrorCode(err); got != tt.code {
+			t.Errorf("%q: error %v has code %s, want %s", tt.input, err, got, tt.code)
+		}
+		if _, evalErr := Evaluate(tt.input); ErrorCode(evalErr) != tt.code {
+			t.Errorf("%q: Eval gives %v, Program %v", tt.input, evalErr, err)
+		}
+	}
+}
+
+// programCorpus holds expressions of every node type, with values the
+// bytecode has no instructions for, as EVAL trees of their own or within
+// numeric code.
+var programCorpus = []string{
+	"1 .. 3",
+	"count(1 .. n)",
+	`"abc" =~ "b"`,
+	`upper("a") == "A"`,
+	`len("héllo") * x`,
+	"[1, 2, x]",
+	"sum([x, y, 3]) + 1",
+	"2 km + 300 m",
+	"90 min",
+	"2i * 2i",
+	"let a = [1, 2] in max(a)",
+	"let a = x in deriv(t ^ 2, \"t\", a) + a",
+	"x + sum(i, 1, n, i ^ 2)",
+	"piecewise(x > 5, 1, 2)",
+	"x > 0 ? \"pos\" : \"neg\"",
+	"-\"a\"",
+	"A1 + 1",
+	"sum(B1:B2)",
+}
+
+func TestProgramCompilesEveryNodeType(t *testing.T) {
+	var exprs []Expr
+	covered := map[string]int{}
+	for _, input := range append(programCorpus, evalCorpus...) {
+		expr, err := ParseString(input, WithCellReferences())
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		exprs = append(exprs, expr)
+	}
+	exprs = append(exprs, List(&Assign{Name: "y", Value: Num(1)}))
+	for _, expr := range exprs {
+		for typ, n := range nodeCounts(expr) {
+			covered[typ] += n
+		}
+		prog, err := CompileProgram(expr)
+		if err != nil {
+			t.Errorf("CompileProgram(%s): %v", Format(expr), err)
+			continue
+		}
+		want, wantErr := EvalWithVars(expr, corpusVars)
+		slots, err := prog.Slots(corpusVars)
+		if err != nil {
+			if ErrorCode(wantErr) != CodeUndefinedVar {
+				t.Errorf("Slots(%s): %v, but Eval gives %v, %v", Format(expr), err, want, wantErr)
+			}
+			continue
+		}
+		got, gotErr := prog.Run(slots)
+		if !sameProgramResult(got, gotErr, want, wantErr) {
+			t.Errorf("%s: Program gives %v, %v; Eval %v, %v", Format(expr), got, gotErr, want, wantErr)
+		}
+	}
+	for _, typ := range nodeTypes(t) {
+		if covered[typ] == 0 {
+			t.Errorf("no expression of the corpus has a %s", typ)
+		}
+	}
+}
+
+// sameProgramResult reports whether a Program and Eval gave the same value, NaN
+// included, or errors with the same code, the bytecode's errors lacking
+// the offsets of Eval's.
+func sameProgramResult(got float64, gotErr error, want float64, wantErr error) bool {
+	if gotErr != nil || wantErr != nil {
+		return gotErr != nil && wantErr != nil && ErrorCode(gotErr) == ErrorCode(wantErr)
+	}
+	return sameResult(got, nil, want, nil)
+}
+
+func TestProgramDisassembleGolden(t *testing.T) {
+	runGolden(t, "disasm", func(input string) string {
+		expr, err := ParseString(input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		prog, err := CompileProgram(expr)
+		if err != nil {
+			t.Fatalf("CompileProgram(%q): %v", input, err)
+		}
+		return prog.Disassemble()
+	})
+}
+
+func TestProgramMarshalRoundTrip(t *testing.T) {
+	for _, input := range append(programCorpus, evalCorpus...) {
+		expr, err := ParseString(input, WithCellReferences())
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		prog, err := CompileProgram(expr)
+		if err != nil {
+			t.Fatalf("CompileProgram(%q): %v", input, err)
+		}
+		data, err := prog.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary(%q): %v", input, err)
+		}
+		var decoded Program
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary(%q): %v", input, err)
+		}
+		if got, want := decoded.Disassemble(), prog.Disassemble(); got != want {
+			t.Errorf("%q decodes to\n%s\nwant\n%s", input, got, want)
+		}
+		want, wantErr := EvalWithVars(expr, corpusVars)
+		if slots, err := decoded.Slots(corpusVars); err != nil {
+			if ErrorCode(wantErr) != CodeUndefinedVar {
+				t.Errorf("Slots(%q): %v, but Eval gives %v, %v", input, err, want, wantErr)
+			}
+		} else if got, gotErr := decoded.Run(slots); !sameProgramResult(got, gotErr, want, wantErr) {
+			t.Errorf("%q decoded gives %v, %v; Eval %v, %v", input, got, gotErr, want, wantErr)
+		}
+
+		for n := 0; n < len(data); n++ {
+			if err := new(Program).UnmarshalBinary(data[:n]); ErrorCode(err) != CodeDecode {
+				t.Errorf("%q truncated to %d bytes: error %v, want %s", input, n, err, CodeDecode)
+			}
+		}
+	}
+}
+
+func TestProgramUnmarshalVersion1(t *testing.T) {
+	expr, err := ParseString("x * 2 + max(y, 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := CompileProgram(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := prog.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Version 1 had no trees: drop their count, zero
+	v1 := append([]byte{1}, data[1:len(data)-1]...)
+	var decoded Program
+	if err := decoded.UnmarshalBinary(v1); err != nil {
+		t.Fatalf("UnmarshalBinary of version 1: %v", err)
+	}
+	if got, want := decoded.Disassemble(), prog.Disassemble(); got != want {
+		t.Errorf("version 1 decodes to\n%s\nwant\n%s", got, want)
+	}
+	if err := decoded.UnmarshalBinary(append([]byte{3}, data[1:]...)); ErrorCode(err) != CodeDecode {
+		t.Errorf("version 3: error %v, want %s", err, CodeDecode)
+	}
+}
+
+// end of file
@@ -0,0 +1,206 @@
+package expressionparser_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	ep "github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser"
+	"github.com/ColinWilcox1967/GoLang-ExpressionParser/expressionparser/exprtest"
+)
+
+// propertyCases is the number of generated expressions each property test
+// checks, fewer with -short.
+func propertyCases() int {
+	if testing.Short() {
+		return 200
+	}
+	return 3000
+}
+
+// propertyConfig generates arithmetic, comparisons and logic on x and y
+// with a few functions.
+var propertyConfig = exprtest.GenConfig{
+	Operators: []string{"+", "-", "*", "/", "%", "^", "<", "<=", "==", "!=", "&&", "||"},
+	Unary:     []string{"-", "!"},
+	Variables: []string{"x", "y"},
+	Functions: map[string]int{"abs": 1, "sqrt": 1, "max": 2, "min": 2},
+	Decimals:  1,
+}
+
+// generate returns the expression the seed generates, and random bindings
+// of its variables.
+func generate(seed int64) (string, ep.Expr, map[string]float64) {
+	r := rand.New(rand.NewSource(seed))
+	text, expr := exprtest.Generate(r, propertyConfig)
+	vars := map[string]float64{
+		"x": math.Round(r.NormFloat64()*100) / 10,
+		"y": math.Round(r.NormFloat64()*100) / 10,
+	}
+	return text, expr, vars
+}
+
+// agree reports whether two evaluations gave the same number, NaN
+// matching NaN, or failed with the same error code.
+func agree(got float64, gotErr error, want float64, wantErr error) bool {
+	if gotErr != nil || wantErr != nil {
+		return gotErr != nil && wantErr != nil && ep.ErrorCode(gotErr) == ep.ErrorCode(wantErr)
+	}
+	return got == want || math.IsNaN(got) && math.IsNaN(want)
+}
+
+func TestPropertyRoundTrip(t *testing.T) {
+	for seed := int64(0); seed < int64(propertyCases()); seed++ {
+		text, want, vars := generate(seed)
+		parsed, err := ep.ParseString(text)
+		if err != nil {
+			t.Fatalf("seed %d: %q does not parse: %v", seed, text, err)
+		}
+		if !ep.Equal(parsed, want) {
+			t.Fatalf("seed %d: %q parses as %s, want %s", seed, text, ep.ToSExpr(parsed), ep.ToSExpr(want))
+		}
+
+		printed := ep.Format(parsed)
+		reparsed, err := ep.ParseString(printed)
+		if err != nil {
+			t.Fatalf("seed %d: %q prints as %q, which does not parse: %v", seed, text, printed, err)
+		}
+		if !ep.Equal(reparsed, parsed) {
+			t.Fatalf("seed %d: %q prints as %q, which parses as %s, want %s", seed, text, printed, ep.ToSExpr(reparsed), ep.ToSExpr(parsed))
+		}
+
+		got, gotErr := ep.EvalWithVars(reparsed, vars)
+		value, err := ep.EvalWithVars(parsed, vars)
+		if !agree(got, gotErr, value, err) {
+			t.Fatalf("seed %d: %q with %v is %v, %v, but printed as %q is %v, %v", seed, text, vars, value, err, printed, got, gotErr)
+		}
+	}
+}
+
+func TestPropertyEvalModesAgree(t *testing.T) {
+	for seed := int64(0); seed < int64(propertyCases()); seed++ {
+		text, expr, vars := generate(seed)
+		want, wantErr := ep.EvalWithVars(expr, vars)
+		check := func(mode string, got float64, gotErr error) {
+			t.Helper()
+			if !agree(got, gotErr, want, wantErr) {
+				t.Fatalf("seed %d: %q with %v is %v, %v with %s, want %v, %v", seed, text, vars, got, gotErr, mode, want, wantErr)
+			}
+		}
+
+		if folded, err := ep.Fold(expr); err != nil {
+			check("Fold", 0, err)
+		} else {
+			got, err := ep.EvalWithVars(folded, vars)
+			check("Fold", got, err)
+		}
+
+		got, err := ep.EvalWithVars(ep.Simplify(expr), vars)
+		check("Simplify", got, err)
+
+		if compiled, err := ep.Compile(text); err != nil {
+			check("Compile", 0, err)
+		} else {
+			got, err := compiled.Eval(vars)
+			check("Compile", got, err)
+		}
+
+		if fn, err := ep.CompileFunc(expr); err != nil {
+			check("CompileFunc", 0, err)
+		} else {
+			got, err := fn(vars)
+			check("CompileFunc", got, err)
+		}
+
+		prog, err := ep.CompileProgram(expr)
+		if err != nil {
+			t.Fatalf("seed %d: CompileProgram(%q): %v", seed, text, err)
+		}
+		slots, err := prog.Slots(vars)
+		if err != nil {
+			t.Fatalf("seed %d: binding %v for %q: %v", seed, vars, text, err)
+		}
+		got, err = prog.Run(slots)
+		check("Program", got, err)
+	}
+}
+
+func TestPropertyPartialEval(t *testing.T) {
+	for seed := int64(0); seed < int64(propertyCases()); seed++ {
+		text, expr, vars := generate(seed)
+		want, wantErr := ep.EvalWithVars(expr, vars)
+
+		// Each split of the bindings into those known up front and the rest
+		for split := 0; split < 4; split++ {
+			some, rest := map[string]float64{}, map[string]float64{}
+			for i, name := range []string{"x", "y"} {
+				if split&(1<<i) != 0 {
+					some[name] = vars[name]
+				} else {
+					rest[name] = vars[name]
+				}
+			}
+			reduced, err := ep.PartialEval(expr, some)
+			if err != nil {
+				// A failing part always evaluated; Eval may meet another error first
+				if wantErr == nil {
+					t.Fatalf("seed %d: PartialEval(%q, %v): %v, but with %v it is %v", seed, text, some, err, vars, want)
+				}
+				continue
+			}
+			got, err := ep.EvalWithVars(reduced, rest)
+			if !agree(got, err, want, wantErr) {
+				t.Fatalf("seed %d: %q reduced with %v to %q is %v, %v with %v; want %v, %v", seed, text, some, ep.Format(reduced), got, err, rest, want, wantErr)
+			}
+			if len(rest) == 0 && err == nil {
+				if !ep.IsConstant(reduced) {
+					t.Fatalf("seed %d: %q with every variable known reduced to %q", seed, text, ep.Format(reduced))
+				}
+			}
+		}
+	}
+}
+
+func TestPropertyProgramBindings(t *testing.T) {
+	for seed := int64(0); seed < int64(propertyCases()); seed++ {
+		text, expr, _ := generate(seed)
+		prog, err := ep.CompileProgram(expr)
+		if err != nil {
+			t.Fatalf("seed %d: CompileProgram(%q): %v", seed, text, err)
+		}
+
+		// One program run with fresh random bindings, zeros among them
+		r := rand.New(rand.NewSource(-seed))
+		for i := 0; i < 4; i++ {
+			vars := map[string]float64{"x": 0, "y": 0}
+			for _, name := range []string{"x", "y"} {
+				if r.Intn(4) > 0 {
+					vars[name] = math.Round(r.NormFloat64()*100) / 10
+				}
+			}
+			slots, err := prog.Slots(vars)
+			if err != nil {
+				t.Fatalf("seed %d: binding %v for %q: %v", seed, vars, text, err)
+			}
+			got, gotErr := prog.Run(slots)
+			want, wantErr := ep.EvalWithVars(expr, vars)
+			if !agree(got, gotErr, want, wantErr) {
+				t.Fatalf("seed %d: %q with %v is %v, %v with Program, want %v, %v", seed, text, vars, got, gotErr, want, wantErr)
+			}
+		}
+	}
+}
+
+func TestPropertyDirect(t *testing.T) {
+	for seed := int64(0); seed < int64(propertyCases()); seed++ {
+		text, _, vars := generate(seed)
+		want, wantErr := ep.EvaluateWithVars(text, vars)
+		got, gotErr := ep.EvaluateDirect(text, vars)
+		// The same error, positions included
+		if !agree(got, gotErr, want, wantErr) || gotErr != nil && gotErr.Error() != wantErr.Error() {
+			t.Fatalf("seed %d: EvaluateDirect(%q) with %v is %v, %v; want %v, %v", seed, text, vars, got, gotErr, want, wantErr)
+		}
+	}
+}
+
+// end of file
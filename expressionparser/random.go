@@ -0,0 +1,86 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// randomSource is a random number generator shared by the goroutines
+// evaluating with one Evaluator.
+type randomSource struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// newRandomSource returns a source seeded with seed.
+func newRandomSource(seed int64) *randomSource {
+	return &randomSource{r: rand.New(rand.NewSource(seed))}
+}
+
+// draw applies a random builtin to args with the source locked.
+func (s *randomSource) draw(fn func(r *rand.Rand, args []float64) (float64, error), args []float64) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.r, args)
+}
+
+// The source of random builtins run without an Evaluator, by Program.Run
+// and by evaluators not made by NewEvaluator
+var sharedRandom = newRandomSource(rand.Int63())
+
+// SetSeed reseeds the source of the random builtins rand, randint and
+// normal, so that the values they return from then on, and so the results
+// of evaluations calling them, repeat from run to run. Evaluators made by
+// NewEvaluator start with a seed of their own. Calls are drawn in
+// evaluation order; with WithParallelBatch the rows of EvalBatch share the
+// source in no fixed order, so their values are not reproducible.
+//
+// SetSeed is not safe to call concurrently with Eval.
+func (ev *Evaluator) SetSeed(seed int64) {
+	ev.random = newRandomSource(seed)
+}
+
+// randomBuiltin makes a builtin drawing from the evaluator's source with fn.
+func randomBuiltin(arity int, fn func(r *rand.Rand, args []float64) (float64, error)) builtin {
+	return builtin{arity: arity, impure: true, random: fn, fn: func(args []float64) (float64, error) {
+		return sharedRandom.draw(fn, args)
+	}}
+}
+
+// uniform returns a number in [0, 1).
+func uniform(r *rand.Rand, args []float64) (float64, error) {
+	return r.Float64(), nil
+}
+
+// randint returns a whole number between two whole bounds, both included.
+func randint(r *rand.Rand, args []float64) (float64, error) {
+	a, b := args[0], args[1]
+	if a != math.Trunc(a) || b != math.Trunc(b) {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("randint: bounds %s and %s must be whole numbers", formatNumber(a), formatNumber(b)))
+	}
+	if math.Abs(a) >= maxExactFloatInt || math.Abs(b) >= maxExactFloatInt {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("randint: bounds %s and %s must lie between -2^53 and 2^53", formatNumber(a), formatNumber(b)))
+	}
+	if a > b {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("randint: lower bound %s is greater than upper bound %s", formatNumber(a), formatNumber(b)))
+	}
+	return a + float64(r.Int63n(int64(b-a)+1)), nil
+}
+
+// normal returns a number drawn from the normal distribution with mean
+// args[0] and standard deviation args[1].
+func normal(r *rand.Rand, args []float64) (float64, error) {
+	return args[0] + args[1]*r.NormFloat64(), nil
+}
+
+// negativeDeviation rejects a negative standard deviation.
+func negativeDeviation(args []float64) int {
+	if args[1] < 0 {
+		return 1
+	}
+	return -1
+}
+
+// end of file
@@ -0,0 +1,78 @@
+package expressionparser
+
+import "testing"
+
+func TestSeededRandom(t *testing.T) {
+	want := []struct {
+		input string
+		value float64
+	}{
+		{"rand()", 0.3730283610466326},
+		{"rand()", 0.06600049679351791},
+		{"randint(1, 6)", 1},
+		{"randint(1, 6)", 6},
+		{"normal(10, 2)", 10.263956968542141},
+		{"randint(3, 3)", 3},
+	}
+	for replay := 0; replay < 2; replay++ {
+		ev := NewEvaluator()
+		ev.SetSeed(42)
+		for _, tt := range want {
+			if got, err := ev.Eval(mustParse(t, tt.input)); err != nil || got != tt.value {
+				t.Errorf("replay %d: %s with seed 42 = %v, %v; want %v", replay, tt.input, got, err, tt.value)
+			}
+		}
+	}
+
+	ev := NewEvaluator()
+	ev.SetSeed(7)
+	for i := 0; i < 1000; i++ {
+		r, _ := ev.Eval(mustParse(t, "rand()"))
+		n, _ := ev.Eval(mustParse(t, "randint(-2, 2)"))
+		if r < 0 || r >= 1 || n < -2 || n > 2 || n != float64(int(n)) {
+			t.Fatalf("rand() = %v, randint(-2, 2) = %v", r, n)
+		}
+	}
+}
+
+func TestRandomErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"randint(6, 1)", "randint: lower bound 6 is greater than upper bound 1"},
+		{"randint(1.5, 3)", "randint: bounds 1.5 and 3 must be whole numbers"},
+		{"normal(0, -1)", "normal: argument 2, -1, is outside the domain"},
+	}
+	for _, tt := range tests {
+		_, err := Evaluate(tt.input)
+		if ErrorCode(err) != CodeOutsideDomain || err.Error() != tt.msg+` in "`+tt.input+`" at offset 0` {
+			t.Errorf("%s: error %v, want %q", tt.input, err, tt.msg)
+		}
+	}
+}
+
+func TestFoldingSkipsRandom(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"rand() + 1 * 2", "rand() + 2"},
+		{"randint(1 + 1, 6)", "randint(2, 6)"},
+		{"2 * 3 + normal(0, 1)", "6 + normal(0, 1)"},
+	}
+	for _, tt := range tests {
+		folded, err := Fold(mustParse(t, tt.input))
+		if err != nil || Format(folded) != tt.want {
+			t.Errorf("Fold(%s) = %s, %v; want %s", tt.input, Format(folded), err, tt.want)
+		}
+		partial, err := PartialEval(mustParse(t, tt.input), nil)
+		if err != nil || Format(partial) != tt.want {
+			t.Errorf("PartialEval(%s) = %s, %v; want %s", tt.input, Format(partial), err, tt.want)
+		}
+		if IsConstant(mustParse(t, tt.input)) {
+			t.Errorf("IsConstant(%s) = true", tt.input)
+		}
+	}
+}
+
+// end of file
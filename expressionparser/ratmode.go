@@ -0,0 +1,324 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// EvalRat evaluates an expression with exact rationals with the default Evaluator.
+func EvalRat(expr Expr) (*big.Rat, error) {
+	return defaultEvaluator.EvalRat(expr)
+}
+
+// EvalRat evaluates expr with exact big.Rat arithmetic, so 1/3 + 1/6 is
+// exactly 1/2. The result's RatString method gives it as "num/den" (or an
+// integer) and FloatString(n) as a decimal rounded to n digits.
+//
+// Parsed literals are read from their source text, so 0.1 is exactly 1/10;
+// literals of built trees and variable values are converted exactly from
+// float64 and must be finite. Division or modulo by zero follows the
+// WithDivisionByZero policy, except that DivideByZeroIEEE gives an error; %
+// is the truncated remainder, with the sign of the dividend. An operation
+// whose result is not rational is an error naming it: ^ with a non-integer
+// exponent (zero to a negative power is a division by zero), and sqrt of a
+// number that is not the square of a rational. A power whose numerator or
+// denominator would exceed a million bits is an error wrapping ErrOverflow.
+// Comparisons and logical operators yield 1 or 0. Of the other builtins
+// only abs, sign, min, max, clamp, floor, ceil, round and trunc are
+// available.
+func (ev *Evaluator) EvalRat(expr Expr) (*big.Rat, error) {
+	if err := ev.admit(expr); err != nil {
+		return nil, err
+	}
+	return ev.evalRat(expr, nil)
+}
+
+// ratScope is a chain of let bindings in rational mode, innermost first.
+type ratScope struct {
+	name   string
+	value  *big.Rat
+	parent *ratScope
+}
+
+// ratFromFloat converts a finite float64 exactly.
+func ratFromFloat(v float64, what string) (*big.Rat, error) {
+	r := new(big.Rat).SetFloat64(v)
+	if r == nil {
+		return nil, withCode(CodeUnsupported, fmt.Errorf("%s %s is not a finite number", what, formatNumber(v)))
+	}
+	return r, nil
+}
+
+// evalRat evaluates expr in rational mode with the given let bindings in scope.
+func (ev *Evaluator) evalRat(expr Expr, env *ratScope) (*big.Rat, error) {
+	switch v := expr.(type) {
+	case *Number:
+		if v.Imag {
+			return nil, imaginaryError(v, "rational")
+		}
+		if v.Text != "" {
+			r, ok := new(big.Rat).SetString(v.Text)
+			if !ok {
+				return nil, withCode(CodeInvalidLiteral, fmt.Errorf("invalid literal %s", v.Text))
+			}
+			return r, nil
+		}
+		return ratFromFloat(v.Value, "literal")
+	case *Variable:
+		for s := env; s != nil; s = s.parent {
+			if s.name == v.Name {
+				return s.value, nil
+			}
+		}
+		value, ok := ev.vars[v.Name]
+		if !ok {
+			return nil, &UndefinedVariableError{Name: v.Name, Span: v.Span}
+		}
+		return ratFromFloat(value, "variable "+v.Name+" =")
+	case *UnaryOp:
+		operand, err := ev.evalRat(v.Operand, env)
+		if err != nil {
+			return nil, err
+		}
+		switch v.Op.Type {
+		case MINUS:
+			return new(big.Rat).Neg(operand), nil
+		case NOT:
+			return ratTruth(operand.Sign() == 0), nil
+		}
+	case *BinaryOp:
+		left, err := ev.evalRat(v.Left, env)
+		if err != nil {
+			return nil, err
+		}
+		if (v.Op.Type == AND || v.Op.Type == OR) && (left.Sign() != 0) == (v.Op.Type == OR) {
+			// Short-circuit: the right operand cannot change the result
+			return ratTruth(left.Sign() != 0), nil
+		}
+		right, err := ev.evalRat(v.Right, env)
+		if err != nil {
+			return nil, err
+		}
+		if isDivision(v.Op) && right.Sign() == 0 {
+			value, ieee, err := ev.zeroDivision(v.Op, v.Right)
+			if err != nil {
+				return nil, err
+			}
+			if ieee {
+				return nil, noIEEEError(v.Op, v.Right, "rational")
+			}
+			return ratFromFloat(value, "division by zero value")
+		}
+		return ev.ratOperation(v.Op, left, right)
+	case *FunctionCall:
+		return ev.callRatBuiltin(v, env)
+	case *Let:
+		value, err := ev.evalRat(v.Value, env)
+		if err != nil {
+			return nil, err
+		}
+		return ev.evalRat(v.Body, &ratScope{name: v.Name, value: value, parent: env})
+	case *Conditional:
+		cond, err := ev.evalRat(v.Cond, env)
+		if err != nil {
+			return nil, err
+		}
+		if cond.Sign() != 0 {
+			return ev.evalRat(v.Then, env)
+		}
+		return ev.evalRat(v.Else, env)
+	}
+	return nil, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate expression of type %T in rational mode", expr))
+}
+
+// ratOperation applies a binary operator to two rationals. The divisor of /
+// and % must not be zero.
+func (ev *Evaluator) ratOperation(op Token, a, b *big.Rat) (*big.Rat, error) {
+	switch op.Type {
+	case PLUS:
+		return new(big.Rat).Add(a, b), nil
+	case MINUS:
+		return new(big.Rat).Sub(a, b), nil
+	case MULT:
+		return new(big.Rat).Mul(a, b), nil
+	case DIV:
+		return new(big.Rat).Quo(a, b), nil
+	case MOD:
+		direction := 0
+		if ev.cfg.flooredModulo {
+			direction = -1
+		}
+		q := ratInt(new(big.Rat).Quo(a, b), direction)
+		return q.Sub(a, q.Mul(q, b)), nil
+	case POW:
+		return ratPow(a, b)
+	case LT:
+		return ratTruth(a.Cmp(b) < 0), nil
+	case LE:
+		return ratTruth(a.Cmp(b) <= 0), nil
+	case GT:
+		return ratTruth(a.Cmp(b) > 0), nil
+	case GE:
+		return ratTruth(a.Cmp(b) >= 0), nil
+	case EQ:
+		return ratTruth(a.Cmp(b) == 0), nil
+	case NE:
+		return ratTruth(a.Cmp(b) != 0), nil
+	case AND:
+		return ratTruth(a.Sign() != 0 && b.Sign() != 0), nil
+	case OR:
+		return ratTruth(a.Sign() != 0 || b.Sign() != 0), nil
+	}
+	return nil, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate operator %s in rational mode", operatorSymbol(op)))
+}
+
+// maxRatBits bounds the numerator and denominator ratPow computes, so that
+// 110^10^10 is an error rather than hours spent multiplying.
+const maxRatBits = 1 << 20
+
+// ratPow raises base to an integer exponent. A result whose numerator or
+// denominator would exceed maxRatBits bits is an error wrapping ErrOverflow.
+func ratPow(base, exponent *big.Rat) (*big.Rat, error) {
+	if !exponent.IsInt() {
+		return nil, withCode(CodeUnsupported, fmt.Errorf("^ with non-integer exponent %s is not rational", exponent.RatString()))
+	}
+	n := exponent.Num()
+	if n.Sign() < 0 && base.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+
+	e := new(big.Int).Abs(n)
+	bits := max(base.Num().BitLen(), base.Denom().BitLen()) - 1
+	if bits > 0 && (!e.IsInt64() || e.Int64() > maxRatBits/int64(bits)) {
+		return nil, fmt.Errorf("rational %w computing %s ^ %s", ErrOverflow, base.RatString(), n)
+	}
+	result := new(big.Rat).SetFrac(
+		new(big.Int).Exp(base.Num(), e, nil),
+		new(big.Int).Exp(base.Denom(), e, nil),
+	)
+	if n.Sign() < 0 {
+		result.Inv(result)
+	}
+	return result, nil
+}
+
+// ratTruth converts a boolean to 1 or 0.
+func ratTruth(b bool) *big.Rat {
+	if b {
+		return big.NewRat(1, 1)
+	}
+	return new(big.Rat)
+}
+
+// ratInt rounds x to an integer in the given direction: -1 toward negative
+// infinity, 0 toward zero and 1 toward positive infinity.
+func ratInt(x *big.Rat, direction int) *big.Rat {
+	if x.IsInt() {
+		return new(big.Rat).Set(x)
+	}
+	q := new(big.Int).Quo(x.Num(), x.Denom())
+	if direction != 0 && x.Sign() == direction {
+		q.Add(q, big.NewInt(int64(direction)))
+	}
+	return new(big.Rat).SetInt(q)
+}
+
+// ratSqrt returns the square root of x when it is itself rational.
+func ratSqrt(x *big.Rat) (*big.Rat, bool) {
+	if x.Sign() < 0 {
+		return nil, false
+	}
+	num := new(big.Int).Sqrt(x.Num())
+	den := new(big.Int).Sqrt(x.Denom())
+	if new(big.Int).Mul(num, num).Cmp(x.Num()) != 0 || new(big.Int).Mul(den, den).Cmp(x.Denom()) != 0 {
+		return nil, false
+	}
+	return new(big.Rat).SetFrac(num, den), true
+}
+
+// Builtins available in rational mode; the signature fields of builtin give their arity
+var ratBuiltins = map[string]struct {
+	sig builtin
+	fn  func(args []*big.Rat) (*big.Rat, error)
+}{
+	"abs": {builtin{arity: 1}, func(args []*big.Rat) (*big.Rat, error) {
+		return new(big.Rat).Abs(args[0]), nil
+	}},
+	"sign": {builtin{arity: 1}, func(args []*big.Rat) (*big.Rat, error) {
+		return big.NewRat(int64(args[0].Sign()), 1), nil
+	}},
+	"min": {builtin{arity: 2, variadic: true}, func(args []*big.Rat) (*big.Rat, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(result) < 0 {
+				result = arg
+			}
+		}
+		return result, nil
+	}},
+	"max": {builtin{arity: 2, variadic: true}, func(args []*big.Rat) (*big.Rat, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			if arg.Cmp(result) > 0 {
+				result = arg
+			}
+		}
+		return result, nil
+	}},
+	"clamp": {builtin{arity: 3}, func(args []*big.Rat) (*big.Rat, error) {
+		x, lo, hi := args[0], args[1], args[2]
+		switch {
+		case lo.Cmp(hi) > 0:
+			return nil, withCode(CodeOutsideDomain, fmt.Errorf("clamp: lower bound %s is greater than upper bound %s", lo.RatString(), hi.RatString()))
+		case x.Cmp(lo) < 0:
+			return lo, nil
+		case x.Cmp(hi) > 0:
+			return hi, nil
+		}
+		return x, nil
+	}},
+	"sqrt": {builtin{arity: 1}, func(args []*big.Rat) (*big.Rat, error) {
+		if r, ok := ratSqrt(args[0]); ok {
+			return r, nil
+		}
+		return nil, withCode(CodeUnsupported, fmt.Errorf("sqrt(%s) is not rational", args[0].RatString()))
+	}},
+	"floor": {builtin{arity: 1}, func(args []*big.Rat) (*big.Rat, error) {
+		return ratInt(args[0], -1), nil
+	}},
+	"ceil": {builtin{arity: 1}, func(args []*big.Rat) (*big.Rat, error) {
+		return ratInt(args[0], 1), nil
+	}},
+	"trunc": {builtin{arity: 1}, func(args []*big.Rat) (*big.Rat, error) {
+		return ratInt(args[0], 0), nil
+	}},
+	"round": {builtin{arity: 1}, func(args []*big.Rat) (*big.Rat, error) {
+		// Half away from zero, as math.Round
+		half := big.NewRat(int64(args[0].Sign()), 2)
+		return ratInt(new(big.Rat).Add(args[0], half), 0), nil
+	}},
+}
+
+// callRatBuiltin evaluates the arguments of a call and applies one of the
+// builtins available in rational mode.
+func (ev *Evaluator) callRatBuiltin(call *FunctionCall, env *ratScope) (*big.Rat, error) {
+	b, ok := ratBuiltins[call.Name]
+	if !ok {
+		return nil, ev.unavailableError(call.Name, "rational")
+	}
+	if err := b.sig.checkArgs(call.Name, len(call.Args)); err != nil {
+		return nil, err
+	}
+
+	args := make([]*big.Rat, len(call.Args))
+	for i, arg := range call.Args {
+		value, err := ev.evalRat(arg, env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	return b.fn(args)
+}
+
+// end of file
@@ -0,0 +1,76 @@
+package expressionparser
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEvalRat(t *testing.T) {
+	tests := []struct {
+		input   string
+		rat     string // RatString
+		decimal string // FloatString(5)
+	}{
+		{"1/3 + 1/6", "1/2", "0.50000"},
+		{"0.1 + 0.2", "3/10", "0.30000"},
+		{"2/3 * 3/4", "1/2", "0.50000"},
+		{"1/3 - 1/2", "-1/6", "-0.16667"},
+		{"(1/3) ^ 3", "1/27", "0.03704"},
+		{"2 ^ -2", "1/4", "0.25000"},
+		{"sqrt(4/9)", "2/3", "0.66667"},
+		{"1e-3 * 1000", "1", "1.00000"},
+		{"-7 % 3", "-1", "-1.00000"},
+		{"round(5/2) + floor(-1/3)", "2", "2.00000"},
+		{"1/3 < 0.34", "1", "1.00000"},
+		{"let third = 1/3 in third * 3 == 1", "1", "1.00000"},
+	}
+	for _, tt := range tests {
+		got, err := EvalRat(mustParse(t, tt.input))
+		if err != nil {
+			t.Errorf("EvalRat(%s): %v", tt.input, err)
+			continue
+		}
+		if got.RatString() != tt.rat || got.FloatString(5) != tt.decimal {
+			t.Errorf("EvalRat(%s) = %s, %s; want %s, %s", tt.input, got.RatString(), got.FloatString(5), tt.rat, tt.decimal)
+		}
+	}
+}
+
+func TestEvalRatErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{"sqrt(2)", CodeUnsupported, "sqrt(2) is not rational"},
+		{"2 ^ 0.5", CodeUnsupported, "^ with non-integer exponent 1/2 is not rational"},
+		{"sin(1)", CodeUnsupported, "function sin is not available in rational mode"},
+		{"1 / (1/3 - 1/3)", CodeDivisionByZero, ""},
+		{"0 / 0", CodeDivisionByZero, ""},
+		{"0 ^ -1", CodeDivisionByZero, ""},
+		{"7 % 0", CodeModuloByZero, ""},
+		{"2 ^ 2000000", CodeOverflow, "rational overflow computing 2 ^ 2000000"},
+	}
+	for _, tt := range tests {
+		_, err := EvalRat(mustParse(t, tt.input))
+		if ErrorCode(err) != tt.code {
+			t.Errorf("EvalRat(%s): error %v, want %s", tt.input, err, tt.code)
+			continue
+		}
+		if tt.msg != "" && err.Error() != tt.msg {
+			t.Errorf("EvalRat(%s): error %q, want %q", tt.input, err, tt.msg)
+		}
+	}
+
+	// Division by zero fails as it does in Eval
+	expr := mustParse(t, "x / 0")
+	ev := NewEvaluator()
+	ev.SetVar("x", 1)
+	_, ratErr := ev.EvalRat(expr)
+	_, floatErr := ev.Eval(expr)
+	if !errors.Is(ratErr, ErrDivisionByZero) || !errors.Is(floatErr, ErrDivisionByZero) {
+		t.Errorf("x / 0: EvalRat error %v, Eval error %v; want ErrDivisionByZero from both", ratErr, floatErr)
+	}
+}
+
+// end of file
@@ -0,0 +1,183 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Recalculator holds the values of interdependent named expressions, as
+// EvalAll evaluates them, and brings them up to date when inputs change by
+// evaluating again only the expressions the change reaches. It is safe for
+// concurrent use.
+type Recalculator struct {
+	mu         sync.Mutex
+	ev         *Evaluator
+	exprs      map[string]Expr
+	graph      map[string][]string
+	rank       map[string]int      // the position of each name in topological order
+	dependents map[string][]string // the expressions using each expression
+	readers    map[string][]string // the expressions using each input
+	vars       *Environment
+	values     map[string]Value
+	errs       map[string]*ExprError
+}
+
+// NewRecalculator evaluates exprs with vars as the inputs, as EvalAll does
+// with an Evaluator configured by opts, and returns a Recalculator holding
+// the results. Expressions that fail are held with their errors, which
+// Value returns; only a cycle among exprs, reported as by Dependencies, is
+// an error of NewRecalculator. exprs must not be modified afterwards.
+func NewRecalculator(exprs map[string]Expr, vars map[string]float64, opts ...Option) (*Recalculator, error) {
+	graph, inputs, err := Dependencies(exprs)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recalculator{
+		ev:         NewEvaluator(opts...),
+		exprs:      exprs,
+		graph:      graph,
+		rank:       make(map[string]int, len(exprs)),
+		dependents: map[string][]string{},
+		readers:    map[string][]string{},
+		vars:       NewEnvironment(nil),
+		values:     make(map[string]Value, len(exprs)),
+		errs:       map[string]*ExprError{},
+	}
+	order := topoOrder(graph)
+	for i, name := range order {
+		r.rank[name] = i
+		for _, dep := range graph[name] {
+			r.dependents[dep] = append(r.dependents[dep], name)
+		}
+		for _, input := range inputs[name] {
+			r.readers[input] = append(r.readers[input], name)
+		}
+	}
+	for name, value := range vars {
+		r.vars.SetNumber(name, value)
+	}
+	for _, name := range order {
+		r.recalc(name)
+	}
+	return r, nil
+}
+
+// Update sets the inputs in changes and evaluates again the expressions
+// using an input whose value it changes, then, in topological order, those
+// using an expression whose value or error changed, and so on. It returns
+// the names of the expressions whose values or errors changed, sorted, and
+// the *ExprError of each expression evaluated again that failed, joined by
+// errors.Join. The result of every expression is then that of EvalAll with
+// the inputs as they now are. A name in changes that names one of the
+// expressions is an input no expression uses, since their names always
+// refer to the expressions.
+func (r *Recalculator) Update(changes map[string]float64) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dirty := map[string]bool{}
+	for name, value := range changes {
+		if old, ok := r.vars.Get(name); ok && sameValue(old, NumberValue(value)) {
+			continue
+		}
+		r.vars.SetNumber(name, value)
+		for _, reader := range r.readers[name] {
+			dirty[reader] = true
+		}
+	}
+
+	var changed []string
+	var errs []error
+	for len(dirty) > 0 {
+		name := r.first(dirty)
+		delete(dirty, name)
+		if !r.recalc(name) {
+			continue
+		}
+		changed = append(changed, name)
+		for _, dependent := range r.dependents[name] {
+			dirty[dependent] = true
+		}
+		if err := r.errs[name]; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	sort.Strings(changed)
+	return changed, errors.Join(errs...)
+}
+
+// first returns the name of dirty that comes first in topological order.
+func (r *Recalculator) first(dirty map[string]bool) string {
+	first := ""
+	for name := range dirty {
+		if first == "" || r.rank[name] < r.rank[first] {
+			first = name
+		}
+	}
+	return first
+}
+
+// recalc evaluates the expression name again, reporting whether its value
+// or its error changed.
+func (r *Recalculator) recalc(name string) bool {
+	old, had := r.values[name]
+	oldErr := r.errs[name]
+
+	env := NewEnvironment(r.vars)
+	var exprErr *ExprError
+	for _, dep := range r.graph[name] {
+		if r.errs[dep] != nil {
+			exprErr = &ExprError{Name: name, Pos: -1, Err: fmt.Errorf("depends on %s, which failed", dep)}
+			break
+		}
+		env.Set(dep, r.values[dep])
+	}
+	var value Value
+	if exprErr == nil {
+		value, exprErr = r.ev.evalNamed(name, r.exprs[name], env)
+	}
+	if exprErr != nil {
+		delete(r.values, name)
+		r.errs[name] = exprErr
+		return had || oldErr == nil || oldErr.Error() != exprErr.Error()
+	}
+	delete(r.errs, name)
+	r.values[name] = value
+	return !had || !sameValue(old, value)
+}
+
+// Value returns the value of the expression name as last evaluated, or the
+// error it failed with.
+func (r *Recalculator) Value(name string) (float64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.errs[name]; err != nil {
+		return 0, err
+	}
+	value, ok := r.values[name]
+	if !ok {
+		return 0, withCode(CodeUndefinedVar, fmt.Errorf("no expression named %s", name))
+	}
+	return value.num, nil
+}
+
+// Values returns the values of the expressions that did not fail, by name.
+func (r *Recalculator) Values() map[string]float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results := make(map[string]float64, len(r.values))
+	for name, value := range r.values {
+		results[name] = value.num
+	}
+	return results
+}
+
+// sameValue reports whether two numeric values are the same, NaN being the
+// same as NaN so that an expression stuck at NaN is not changed.
+func sameValue(a, b Value) bool {
+	return a.kind == b.kind && (a.num == b.num || math.IsNaN(a.num) && math.IsNaN(b.num))
+}
+
+// end of file
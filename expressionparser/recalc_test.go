@@ -0,0 +1,243 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRecalculator(t *testing.T) {
+	r, err := NewRecalculator(parseAll(t, diamond), map[string]float64{"price": 50, "qty": 4, "tax": 0.25, "shipping": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(r.Values()); got != "map[base:200 left:250 right:20 top:235]" {
+		t.Errorf("Values() = %s", got)
+	}
+	tests := []struct {
+		changes map[string]float64
+		changed string
+		top     float64
+	}{
+		{map[string]float64{"shipping": 7}, "top", 237},
+		{map[string]float64{"shipping": 7}, "", 237}, // no change
+		{map[string]float64{"tax": 0.5}, "left top", 287},
+		{map[string]float64{"qty": 1}, "base left right top", 82},
+		{map[string]float64{"price": 25, "qty": 2}, "", 82}, // base is 50 as before
+		{map[string]float64{"unused": 1}, "", 82},
+	}
+	for _, tt := range tests {
+		changed, err := r.Update(tt.changes)
+		if err != nil || strings.Join(changed, " ") != tt.changed {
+			t.Errorf("Update(%v) = %q, %v; want %q", tt.changes, changed, err, tt.changed)
+		}
+		if top, err := r.Value("top"); err != nil || top != tt.top {
+			t.Errorf("after Update(%v): top = %v, %v; want %v", tt.changes, top, err, tt.top)
+		}
+	}
+}
+
+func TestRecalculatorErrors(t *testing.T) {
+	exprs := parseAll(t, map[string]string{"ratio": "a / b", "pct": "ratio * 100", "other": "a + 1"})
+	r, err := NewRecalculator(exprs, map[string]float64{"a": 1, "b": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Value("pct"); err == nil || err.Error() != "expression pct: depends on ratio, which failed" {
+		t.Errorf("pct: error %v", err)
+	}
+	if got, err := r.Value("other"); err != nil || got != 2 {
+		t.Errorf("other = %v, %v; want 2", got, err)
+	}
+
+	// Recovering
+	changed, err := r.Update(map[string]float64{"b": 4})
+	if err != nil || strings.Join(changed, " ") != "pct ratio" {
+		t.Errorf("Update(b = 4) = %q, %v", changed, err)
+	}
+	if got, _ := r.Value("pct"); got != 25 {
+		t.Errorf("pct = %v, want 25", got)
+	}
+	// And failing again
+	changed, err = r.Update(map[string]float64{"b": 0})
+	if strings.Join(changed, " ") != "pct ratio" || !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("Update(b = 0) = %q, %v", changed, err)
+	}
+	if _, err := r.Value("missing"); ErrorCode(err) != CodeUndefinedVar {
+		t.Errorf("Value(missing): error %v", err)
+	}
+	if _, err := NewRecalculator(parseAll(t, map[string]string{"p": "q", "q": "p"}), nil); err == nil {
+		t.Error("NewRecalculator of a cycle: no error")
+	}
+}
+
+// randomFormulas returns n formulas named f0 to fn-1 over the inputs in0
+// to in(inputs-1), each using some of the formulas before it, with
+// divisions that fail for some inputs.
+func randomFormulas(r *rand.Rand, n, inputs int) map[string]string {
+	operand := func(i int) string {
+		if i > 0 && r.Intn(3) > 0 {
+			return fmt.Sprintf("f%d", r.Intn(i))
+		}
+		return fmt.Sprintf("in%d", r.Intn(inputs))
+	}
+	sources := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		var s string
+		switch r.Intn(5) {
+		case 0:
+			s = fmt.Sprintf("%s / (%s - 2)", operand(i), operand(i))
+		case 1:
+			s = fmt.Sprintf("%s > %s ? %s : %s", operand(i), operand(i), operand(i), operand(i))
+		case 2:
+			s = fmt.Sprintf("round(%s * %s, 3)", operand(i), operand(i))
+		default:
+			s = fmt.Sprintf("%s + %s - %s", operand(i), operand(i), operand(i))
+		}
+		sources[fmt.Sprintf("f%d", i)] = s
+	}
+	return sources
+}
+
+// randomInputs returns values for the inputs in0 to in(inputs-1) from 0 to 4.
+func randomInputs(r *rand.Rand, inputs int) map[string]float64 {
+	vars := make(map[string]float64, inputs)
+	for i := 0; i < inputs; i++ {
+		vars[fmt.Sprintf("in%d", i)] = float64(r.Intn(5))
+	}
+	return vars
+}
+
+// outcomes returns the value or error of each expression, as EvalAll
+// WithContinueOnError gives them. Zero is rendered without a sign, as -0
+// is the same value as 0 to Update.
+func outcomes(values map[string]float64, err error) map[string]string {
+	result := make(map[string]string, len(values))
+	for name, v := range values {
+		if v == 0 {
+			v = 0
+		}
+		result[name] = fmt.Sprint(v)
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			var exprErr *ExprError
+			if errors.As(err, &exprErr) {
+				result[exprErr.Name] = err.Error()
+			}
+		}
+	}
+	return result
+}
+
+// recalcOutcomes returns the value or error of each expression of rc.
+func recalcOutcomes(rc *Recalculator, names []string) map[string]string {
+	result := make(map[string]string, len(names))
+	for _, name := range names {
+		v, err := rc.Value(name)
+		if v == 0 {
+			v = 0
+		}
+		result[name] = fmt.Sprint(v)
+		if err != nil {
+			result[name] = err.Error()
+		}
+	}
+	return result
+}
+
+// TestRecalculatorProperty checks that after every Update the values are
+// those of a full EvalAll, and that the names reported changed are those
+// whose outcome differs from that of the EvalAll before.
+func TestRecalculatorProperty(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	full := NewEvaluator(WithContinueOnError())
+	for round := 0; round < 20; round++ {
+		const inputs = 8
+		exprs := parseAll(t, randomFormulas(r, 40, inputs))
+		names := make([]string, 0, len(exprs))
+		for name := range exprs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		vars := randomInputs(r, inputs)
+		rc, err := NewRecalculator(exprs, vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := outcomes(full.EvalAll(exprs, vars))
+		for step := 0; step < 10; step++ {
+			changes := map[string]float64{}
+			for i := r.Intn(3) + 1; i > 0; i-- {
+				changes[fmt.Sprintf("in%d", r.Intn(inputs))] = float64(r.Intn(5))
+			}
+			for name, value := range changes {
+				vars[name] = value
+			}
+			changed, _ := rc.Update(changes)
+			after := outcomes(full.EvalAll(exprs, vars))
+			if got := recalcOutcomes(rc, names); fmt.Sprint(got) != fmt.Sprint(after) {
+				t.Fatalf("round %d, step %d: after Update(%v)\n%v\nwant EvalAll's\n%v", round, step, changes, got, after)
+			}
+			var differ []string
+			for _, name := range names {
+				if before[name] != after[name] {
+					differ = append(differ, name)
+				}
+			}
+			sort.Strings(differ)
+			if strings.Join(changed, " ") != strings.Join(differ, " ") {
+				t.Fatalf("round %d, step %d: Update(%v) changed %v, want %v", round, step, changes, changed, differ)
+			}
+			before = after
+		}
+	}
+}
+
+// benchmarkFormulas returns 500 formulas over 50 inputs: f0 to f9 each
+// reading in0 alone, and f10 to f499 building on each other and the other
+// inputs.
+func benchmarkFormulas(b *testing.B) (map[string]Expr, map[string]float64) {
+	sources := map[string]string{"f10": "in1 + in2"}
+	for i := 0; i < 10; i++ {
+		sources[fmt.Sprintf("f%d", i)] = fmt.Sprintf("in0 * %d", i)
+	}
+	for i := 11; i < 500; i++ {
+		sources[fmt.Sprintf("f%d", i)] = fmt.Sprintf("f%d + in%d * 2", i/2+5, 1+i%49)
+	}
+	vars := map[string]float64{}
+	for i := 0; i < 50; i++ {
+		vars[fmt.Sprintf("in%d", i)] = float64(i)
+	}
+	return parseAll(b, sources), vars
+}
+
+func BenchmarkRecalculatorLeaf(b *testing.B) {
+	exprs, vars := benchmarkFormulas(b)
+	rc, err := NewRecalculator(exprs, vars)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rc.Update(map[string]float64{"in0": float64(i)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvalAllFull(b *testing.B) {
+	exprs, vars := benchmarkFormulas(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vars["in0"] = float64(i)
+		if _, err := EvalAll(exprs, vars); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// end of file
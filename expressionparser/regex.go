@@ -0,0 +1,131 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sync"
+)
+
+// Pattern matching: s =~ pattern is true when the regular expression
+// pattern, in the RE2 syntax of package regexp, matches somewhere in the
+// string s, as in name =~ "^prod-"; anchor it with ^ and $ to match the
+// whole string. match(s, pattern) is the same test as a function, and
+// capture(s, pattern, group) gives the text the numbered group of the
+// first match captured, group 0 being the whole match, or "" when the
+// pattern does not match or the group takes no part in the match. Both
+// operands must be strings.
+
+// maxCachedPatterns bounds the patterns a patternCache keeps, so that
+// patterns built from data, as by format, cannot grow it without limit;
+// those beyond it are compiled each time they are used.
+const maxCachedPatterns = 256
+
+// patternCache holds the regular expressions compiled for an Evaluator, by
+// pattern, with the error of each pattern that does not compile.
+type patternCache struct {
+	mu       sync.Mutex
+	patterns map[string]compiledPattern
+}
+
+// compiledPattern is a pattern compiled, or the error compiling it.
+type compiledPattern struct {
+	re  *regexp.Regexp
+	err error
+}
+
+// The pattern cache of evaluators not made by NewEvaluator
+var sharedPatterns = &patternCache{}
+
+// compile returns the regular expression of pattern, compiling it the
+// first time. A pattern that does not compile gives the error of regexp.
+func (c *patternCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.patterns[pattern]; ok {
+		return p.re, p.err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		err = withCode(CodeInvalidPattern, fmt.Errorf("invalid pattern %q: %w", pattern, err))
+	}
+	if c.patterns == nil {
+		c.patterns = map[string]compiledPattern{}
+	}
+	if len(c.patterns) < maxCachedPatterns {
+		c.patterns[pattern] = compiledPattern{re: re, err: err}
+	}
+	return re, err
+}
+
+// pattern returns the regular expression of pattern from the cache of the
+// evaluator, shared by its copies.
+func (ev *Evaluator) pattern(pattern string) (*regexp.Regexp, error) {
+	if ev.patterns == nil {
+		return sharedPatterns.compile(pattern)
+	}
+	return ev.patterns.compile(pattern)
+}
+
+// matchValue applies =~ to two values, pos locating the operator.
+func (ev *Evaluator) matchValue(pos int, s, pattern Value) (Value, error) {
+	if s.kind != StringKind || pattern.kind != StringKind {
+		return Value{}, &TypeError{Op: operatorSymbols[MATCH], Operands: []Kind{s.kind, pattern.kind}, Pos: pos}
+	}
+	re, err := ev.pattern(pattern.str)
+	if err != nil {
+		return Value{}, atOffset(err, pos)
+	}
+	return BoolValue(re.MatchString(s.str)), nil
+}
+
+// matchString applies match or capture, named by name, to its arguments.
+func (ev *Evaluator) matchString(name string, args []Value) (Value, error) {
+	re, err := ev.pattern(args[1].str)
+	if err != nil {
+		return Value{}, fmt.Errorf("%s: %w", name, err)
+	}
+	if name == "match" {
+		return BoolValue(re.MatchString(args[0].str)), nil
+	}
+
+	group := args[2].num
+	if group != math.Trunc(group) || group < 0 || group > float64(re.NumSubexp()) {
+		return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("capture: group %s is not one of the %d groups of %q, or 0", formatNumber(group), re.NumSubexp(), args[1].str))
+	}
+	m := re.FindStringSubmatchIndex(args[0].str)
+	if i := 2 * int(group); m != nil && m[i] >= 0 {
+		return StringValue(args[0].str[m[i]:m[i+1]]), nil
+	}
+	return StringValue(""), nil
+}
+
+// compilePatterns compiles the patterns of expr that are string literals,
+// the right operands of =~ and the second arguments of match and capture,
+// so that Compile reports one that does not compile rather than leaving it
+// to Eval. The error is that of the first such pattern.
+func (ev *Evaluator) compilePatterns(expr Expr) error {
+	var err error
+	Walk(expr, func(node Expr) bool {
+		var lit Expr
+		switch v := node.(type) {
+		case *BinaryOp:
+			if v.Op.Type == MATCH {
+				lit = v.Right
+			}
+		case *FunctionCall:
+			if _, registered := ev.funcs[v.Name]; !registered && (v.Name == "match" || v.Name == "capture") && len(v.Args) > 1 {
+				lit = v.Args[1]
+			}
+		}
+		if s, ok := lit.(*StringLiteral); ok && err == nil {
+			if _, err = ev.pattern(s.Value); err != nil && s.Span != (Span{}) {
+				err = atOffset(err, s.Span.Start)
+			}
+		}
+		return err == nil
+	})
+	return err
+}
+
+// end of file
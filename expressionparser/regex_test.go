@@ -0,0 +1,99 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	env := map[string]Value{"name": StringValue("prod-web-1")}
+	tests := []struct {
+		input string
+		want  string // the result as Value.String renders it
+	}{
+		{`name =~ "^prod-"`, "true"},
+		{`name =~ "^dev-"`, "false"},
+		{`name =~ "web"`, "true"},
+		{`"abc" =~ "^b"`, "false"},
+		{`!(name =~ "^dev") && name =~ "1$"`, "true"},
+		{`match(name, "-[0-9]+$")`, "true"},
+		{`match(name, "^x")`, "false"},
+		{`capture(name, "^([a-z]+)-([a-z]+)", 2)`, `"web"`},
+		{`capture(name, "^([a-z]+)", 0)`, `"prod"`},
+		// No match, or a group taking no part in it, captures nothing
+		{`capture(name, "^x(y)", 1)`, `""`},
+		{`capture(name, "(x)?prod", 1)`, `""`},
+	}
+	for _, tt := range tests {
+		got, err := EvalValue(mustParse(t, tt.input), env)
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	env := map[string]Value{"name": StringValue("prod-web-1"), "n": NumberValue(3), "p": StringValue("(")}
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{`name =~ "("`, CodeInvalidPattern, "invalid pattern \"(\": error parsing regexp: missing closing ): `(`"},
+		{`name =~ p`, CodeInvalidPattern, "invalid pattern \"(\": error parsing regexp: missing closing ): `(`"},
+		{`n =~ "3"`, CodeType, "cannot apply =~ to number and string"},
+		{`name =~ 3`, CodeType, "cannot apply =~ to string and number"},
+		{`[1] =~ "a"`, CodeType, "cannot apply =~ to list and string"},
+		{`match(n, "a")`, CodeType, "cannot apply match to number and string: argument 1 is a number"},
+		{`capture(name, "(a)", 3)`, CodeOutsideDomain, `capture: group 3 is not one of the 1 groups of "(a)", or 0`},
+		{`capture(name, "(p)", -1)`, CodeOutsideDomain, `capture: group -1 is not one of the 1 groups of "(p)", or 0`},
+	}
+	for _, tt := range tests {
+		_, err := EvalValue(mustParse(t, tt.input), env)
+		if ErrorCode(err) != tt.code {
+			t.Errorf("%s: error %v, want %s", tt.input, err, tt.code)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.msg+" in ") {
+			t.Errorf("%s: error %q, want %q", tt.input, err, tt.msg)
+		}
+	}
+
+	// Compile reports a literal pattern that does not compile, and leaves one
+	// from a variable to evaluation
+	if _, err := Compile(`x + match("a", "[a")`); ErrorCode(err) != CodeInvalidPattern || !strings.Contains(err.Error(), "missing closing ]") {
+		t.Errorf("Compile with a bad literal pattern: error %v, want %s", err, CodeInvalidPattern)
+	}
+	if _, err := Compile(`match("a", p)`); err != nil {
+		t.Errorf("Compile with a variable pattern: %v", err)
+	}
+}
+
+func TestPatternCache(t *testing.T) {
+	ev := NewEvaluator()
+	for _, input := range []string{`"a" =~ "^a+$"`, `"aa" =~ "^a+$"`, `match("b", "^a+$")`} {
+		if _, err := ev.EvalValue(mustParse(t, input)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A pattern that does not compile is cached with its error
+	if ev.patterns.compile("("); len(ev.patterns.patterns) != 2 {
+		t.Errorf("the cache holds %d patterns, want 2", len(ev.patterns.patterns))
+	}
+
+	for i := 0; i < maxCachedPatterns+10; i++ {
+		if _, err := ev.pattern(fmt.Sprintf("^%d$", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(ev.patterns.patterns); n != maxCachedPatterns {
+		t.Errorf("the cache holds %d patterns, want at most %d", n, maxCachedPatterns)
+	}
+}
+
+// end of file
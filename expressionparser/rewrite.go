@@ -0,0 +1,71 @@
+package expressionparser
+
+// Rewrite returns a transformed copy of expr. The tree is rebuilt bottom-up:
+// children are rewritten first, then fn is called on the node (with its new
+// children). A nil result from fn keeps the node as is. Subtrees in which
+// nothing changed are shared with the input, which is never modified.
+func Rewrite(expr Expr, fn func(Expr) Expr) Expr {
+	if expr == nil {
+		return nil
+	}
+
+	children := Children(expr)
+	var rewritten []Expr
+	for i, child := range children {
+		c := Rewrite(child, fn)
+		if c != child && rewritten == nil {
+			rewritten = make([]Expr, len(children))
+			copy(rewritten, children)
+		}
+		if rewritten != nil {
+			rewritten[i] = c
+		}
+	}
+
+	node := expr
+	if rewritten != nil {
+		node = withChildren(expr, rewritten)
+	}
+	if replacement := fn(node); replacement != nil {
+		return replacement
+	}
+	return node
+}
+
+// withChildren returns a shallow copy of expr with its direct subexpressions
+// replaced, in the order reported by Children.
+func withChildren(expr Expr, children []Expr) Expr {
+	switch v := expr.(type) {
+	case *BinaryOp:
+		c := *v
+		c.Left, c.Right = children[0], children[1]
+		return &c
+	case *UnaryOp:
+		c := *v
+		c.Operand = children[0]
+		return &c
+	case *FunctionCall:
+		c := *v
+		c.Args = children
+		return &c
+	case *ListLiteral:
+		c := *v
+		c.Elems = children
+		return &c
+	case *Let:
+		c := *v
+		c.Value, c.Body = children[0], children[1]
+		return &c
+	case *Assign:
+		c := *v
+		c.Value = children[0]
+		return &c
+	case *Conditional:
+		c := *v
+		c.Cond, c.Then, c.Else = children[0], children[1], children[2]
+		return &c
+	}
+	return expr
+}
+
+// end of file
@@ -0,0 +1,81 @@
+package expressionparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// renameVar returns a rewrite renaming the variable from to to.
+func renameVar(from, to string) func(Expr) Expr {
+	return func(e Expr) Expr {
+		if v, ok := e.(*Variable); ok && v.Name == from {
+			return &Variable{Name: to, Span: v.Span}
+		}
+		return nil
+	}
+}
+
+func TestRewriteLeavesInputUntouched(t *testing.T) {
+	expr, err := ParseString("let a = x + 1 in x > 0 ? max(x, a, [x]) : -x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := Clone(expr)
+	got := Rewrite(expr, renameVar("x", "y"))
+	if !reflect.DeepEqual(expr, before) {
+		t.Errorf("Rewrite modified its input: %s, was %s", ToSExpr(expr), ToSExpr(before))
+	}
+	want, err := ParseString("let a = y + 1 in y > 0 ? max(y, a, [y]) : -y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(got, want) {
+		t.Errorf("Rewrite = %s, want %s", ToSExpr(got), ToSExpr(want))
+	}
+}
+
+func TestRewriteEverySite(t *testing.T) {
+	expr, err := ParseString("x * 1 + f(x * 1, (y * 1) * 1) - [x * 1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sites := 0
+	got := Rewrite(expr, func(e Expr) Expr {
+		if b, ok := e.(*BinaryOp); ok && b.Op.Type == MULT {
+			if n, ok := b.Right.(*Number); ok && n.Value == 1 {
+				sites++
+				return b.Left
+			}
+		}
+		return nil
+	})
+	if sites != 5 {
+		t.Errorf("rewrote %d sites, want 5", sites)
+	}
+	want, err := ParseString("x + f(x, y) - [x]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(got, want) {
+		t.Errorf("Rewrite = %s, want %s", ToSExpr(got), ToSExpr(want))
+	}
+}
+
+func TestRewriteSharesUnchangedSubtrees(t *testing.T) {
+	expr, err := ParseString("(a + b) * x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Rewrite(expr, renameVar("x", "y")).(*BinaryOp)
+	if got == expr {
+		t.Fatal("Rewrite returned its input although a node changed")
+	}
+	if got.Left != expr.(*BinaryOp).Left {
+		t.Error("Rewrite copied the unchanged subtree a + b")
+	}
+	if same := Rewrite(expr, func(Expr) Expr { return nil }); same != expr {
+		t.Error("Rewrite copied a tree in which nothing changed")
+	}
+}
+
+// end of file
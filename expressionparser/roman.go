@@ -0,0 +1,105 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// WithRomanNumerals makes the Lexer read a name made only of the capital
+// letters I, V, X, L, C, D and M, such as XIV or MMXXIV, as a number
+// literal, unless it is called, so that "XIV + 6" is 20. A numeral must be
+// in the standard form, each decimal place written as one of I, II, III,
+// IV, V, VI, VII, VIII and IX or their tens, hundreds and thousands, so
+// that IIII, VV, IL and IXX are invalid literals whose error names the
+// sequence at fault. Without the option such names are variables as usual.
+// roman(n) gives the numeral of a whole number from 1 to 3999.
+func WithRomanNumerals() Option {
+	return func(c *config) {
+		c.romanNumerals = true
+	}
+}
+
+// romanLetters gives the decimal place of each letter of a Roman numeral,
+// 0 for the units to 3 for the thousands, and whether it is the five of
+// its place rather than the one.
+var romanLetters = map[byte]struct {
+	place int
+	five  bool
+}{
+	'I': {0, false}, 'V': {0, true},
+	'X': {1, false}, 'L': {1, true},
+	'C': {2, false}, 'D': {2, true},
+	'M': {3, false},
+}
+
+// romanDigits spells each digit of a Roman numeral in the ones (o), fives
+// (f) and tens (t) of its place: 4 is "of", as IV, XL or CD.
+var romanDigits = [...]string{"", "o", "oo", "ooo", "of", "f", "fo", "foo", "fooo", "ot"}
+
+// romanShapes gives the digit of each spelling in romanDigits.
+var romanShapes = func() map[string]int {
+	shapes := map[string]int{}
+	for digit, shape := range romanDigits {
+		shapes[shape] = digit
+	}
+	return shapes
+}()
+
+// isRomanName reports whether name is made only of Roman numeral letters.
+func isRomanName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if _, ok := romanLetters[name[i]]; !ok {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// parseRoman reads a Roman numeral in the standard form. The letters of
+// each digit follow those of a higher place, and a digit is a one, a five
+// or a ten of its place, the ten only after a single one, as in IX.
+func parseRoman(s string) (float64, error) {
+	total, place, start, shape := 0, 4, 0, ""
+	for i := 0; i < len(s); i++ {
+		letter, role := romanLetters[s[i]], "o"
+		if letter.five {
+			role = "f"
+		}
+		switch {
+		case letter.place < place:
+			total += romanShapes[shape] * int(math.Pow10(place))
+			place, start, shape = letter.place, i, role
+		case letter.place == place:
+			shape += role
+		case letter.place == place+1 && !letter.five && shape == "o":
+			shape = "ot"
+		default:
+			shape = "-"
+		}
+		if _, ok := romanShapes[shape]; !ok {
+			return 0, fmt.Errorf("invalid Roman numeral %s: invalid sequence %s", s, s[start:i+1])
+		}
+	}
+	return float64(total + romanShapes[shape]*int(math.Pow10(place))), nil
+}
+
+// toRoman gives the Roman numeral of a whole number from 1 to 3999.
+func toRoman(args []Value) (Value, error) {
+	x := args[0].num
+	if x != math.Trunc(x) || x < 1 || x > 3999 {
+		return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("roman: %s is not a whole number from 1 to 3999", formatNumber(x)))
+	}
+	var sb strings.Builder
+	n := int(x)
+	// The one, five and ten of each place, from the thousands down
+	for place, letters := range [...]string{"M??", "CDM", "XLC", "IVX"} {
+		digit := n / int(math.Pow10(3-place)) % 10
+		for _, role := range romanDigits[digit] {
+			sb.WriteByte(letters[strings.IndexRune("oft", role)])
+		}
+	}
+	return StringValue(sb.String()), nil
+}
+
+// end of file
@@ -0,0 +1,119 @@
+package expressionparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRomanNumerals(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"XIV + 6", 20},
+		{"MMXXIV", 2024},
+		{"MMMCMXCIX", 3999},
+		{"MCMXCIV - 1994", 0},
+		{"III", 3},
+		{"IV", 4},
+		{"IX", 9},
+		{"XL", 40},
+		{"XC", 90},
+		{"CD", 400},
+		{"CM", 900},
+		{"M * (X - I)", 9000},
+	}
+	for _, tt := range tests {
+		if got, err := Evaluate(tt.input, WithRomanNumerals()); err != nil || got != tt.want {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	// Called, or without the option, the letters are names
+	if _, err := Evaluate("XIV(2)", WithRomanNumerals()); ErrorCode(err) != CodeUnknownFunction {
+		t.Errorf("XIV(2): error %v, want %s", err, CodeUnknownFunction)
+	}
+	if _, err := Evaluate("XIV + 6"); ErrorCode(err) != CodeUndefinedVar || !strings.HasPrefix(err.Error(), "undefined variable XIV") {
+		t.Errorf("XIV + 6 without WithRomanNumerals: error %v, want an undefined variable", err)
+	}
+	if got, err := EvalWithVars(mustParse(t, "X + 1"), map[string]float64{"X": 41}); err != nil || got != 42 {
+		t.Errorf("X + 1 with X = 41 = %v, %v; want 42", got, err)
+	}
+}
+
+func TestRomanNumeralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		sequence string
+	}{
+		// A one repeated more than three times
+		{"IIII", "IIII"},
+		{"CCCC", "CCCC"},
+		{"MMMM", "MMMM"},
+		// A five repeated
+		{"VV", "VV"},
+		{"LL", "LL"},
+		{"DD", "DD"},
+		// A one before a letter more than ten times larger
+		{"IL", "IL"},
+		{"IC", "IC"},
+		{"XM", "XM"},
+		// A five subtracted
+		{"VX", "VX"},
+		// Too many letters before or after a subtraction
+		{"IIV", "IIV"},
+		{"IXX", "IXX"},
+		{"XIIX", "IIX"},
+		{"IXI", "IXI"},
+	}
+	for _, tt := range tests {
+		input := "1 + " + tt.input
+		_, err := ParseString(input, WithRomanNumerals())
+		want := "invalid Roman numeral " + tt.input + ": invalid sequence " + tt.sequence + " at offset 4"
+		if ErrorCode(err) != CodeInvalidLiteral || err.Error() != want {
+			t.Errorf("%s: error %v, want %q", input, err, want)
+		}
+	}
+}
+
+func TestRoman(t *testing.T) {
+	tests := []struct {
+		n    float64
+		want string
+	}{
+		{1, "I"},
+		{4, "IV"},
+		{14, "XIV"},
+		{40, "XL"},
+		{1994, "MCMXCIV"},
+		{2024, "MMXXIV"},
+		{3999, "MMMCMXCIX"},
+	}
+	for _, tt := range tests {
+		got, err := EvalValue(Call("roman", Num(tt.n)), nil)
+		if s, _ := got.AsString(); err != nil || s != tt.want {
+			t.Errorf("roman(%v) = %v, %v; want %s", tt.n, got, err, tt.want)
+		}
+	}
+	for _, input := range []string{"roman(0)", "roman(4000)", "roman(1.5)", "roman(-1)"} {
+		_, err := EvalValue(mustParse(t, input), nil)
+		n := strings.TrimSuffix(strings.TrimPrefix(input, "roman("), ")")
+		if ErrorCode(err) != CodeOutsideDomain || !strings.HasPrefix(err.Error(), "roman: "+n+" is not a whole number from 1 to 3999 in ") {
+			t.Errorf("%s: error %v, want %s", input, err, CodeOutsideDomain)
+		}
+	}
+
+	// Every numeral reads back as the number it was made from
+	for n := 1; n <= 3999; n++ {
+		v, err := EvalValue(Call("roman", Num(float64(n))), nil)
+		if err != nil {
+			t.Fatalf("roman(%d): %v", n, err)
+		}
+		s, _ := v.AsString()
+		if got, err := Evaluate(s, WithRomanNumerals()); err != nil || got != float64(n) {
+			t.Fatalf("roman(%d) is %s, which reads as %v, %v", n, s, got, err)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,126 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+)
+
+// defaultSolveIterations is the iteration cap of solve and bisect unless set
+// WithSolveIterations.
+const defaultSolveIterations = 100
+
+// solveTolerance is the step, relative to max(1, |x|), below which solve and
+// bisect take their estimate of a root to have converged.
+const solveTolerance = 1e-15
+
+// WithSolveIterations sets the number of iterations after which solve and
+// bisect give up on finding a root, 100 by default.
+func WithSolveIterations(n int) Option {
+	return func(c *config) {
+		c.solveIterations = n
+	}
+}
+
+// solver finds a root of f, the first argument of a call to solve or bisect
+// as a function of the variable it binds, within a number of iterations.
+type solver struct {
+	form       string
+	variable   string
+	f          func(float64) (float64, error)
+	iterations int
+}
+
+// converged reports whether a step from x to next is small enough to stop.
+func converged(x, next float64) bool {
+	return math.Abs(next-x) <= solveTolerance*math.Max(1, math.Abs(x))
+}
+
+// noConvergence is the error of a solver that ran out of iterations, or
+// whose estimate left the finite numbers, at x.
+func (s *solver) noConvergence(x float64) error {
+	return withCode(CodeNoConvergence, fmt.Errorf("%s: no convergence after %d iterations, the last estimate being %s = %s", s.form, s.iterations, s.variable, formatNumber(x)))
+}
+
+// newton finds a root of f from the guess x by Newton's method, the
+// derivative being estimated as deriv does. It may diverge, or converge to
+// a root other than the nearest, where bracket would not.
+func (s *solver) newton(x float64) (float64, error) {
+	for i := 0; i < s.iterations; i++ {
+		fx, err := s.f(x)
+		if err != nil || fx == 0 {
+			return x, err
+		}
+		slope, err := derivative(s.f, x)
+		if err != nil {
+			return 0, err
+		}
+		if slope == 0 {
+			return 0, withCode(CodeNoConvergence, fmt.Errorf("%s: the derivative is zero at %s = %s; try another guess, or bounds either side of the root", s.form, s.variable, formatNumber(x)))
+		}
+		next := x - fx/slope
+		if math.IsNaN(next) || math.IsInf(next, 0) {
+			return 0, s.noConvergence(x)
+		}
+		if converged(x, next) {
+			return next, nil
+		}
+		x = next
+	}
+	return 0, s.noConvergence(x)
+}
+
+// bracket finds a root of f between lo and hi, where f must differ in
+// sign, keeping the root between the bounds as it narrows them. With
+// newton set it takes Newton's step where that stays within the bounds,
+// and halves them otherwise, so that it converges wherever bisection does
+// but usually much faster; without it, it halves them every time.
+func (s *solver) bracket(lo, hi float64, newton bool) (float64, error) {
+	if math.IsNaN(lo) || math.IsNaN(hi) || math.IsInf(lo, 0) || math.IsInf(hi, 0) {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("%s: bounds %s and %s must be finite", s.form, formatNumber(lo), formatNumber(hi)))
+	}
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	flo, err := s.f(lo)
+	if err != nil || flo == 0 {
+		return lo, err
+	}
+	fhi, err := s.f(hi)
+	if err != nil || fhi == 0 {
+		return hi, err
+	}
+	if math.Signbit(flo) == math.Signbit(fhi) || math.IsNaN(flo) || math.IsNaN(fhi) {
+		return 0, withCode(CodeNoConvergence, fmt.Errorf("%s: the expression is %s at %s = %s and %s at %s = %s, which do not differ in sign", s.form, formatNumber(flo), s.variable, formatNumber(lo), formatNumber(fhi), s.variable, formatNumber(hi)))
+	}
+
+	x := lo + (hi-lo)/2
+	for i := 0; i < s.iterations; i++ {
+		fx, err := s.f(x)
+		if err != nil || fx == 0 {
+			return x, err
+		}
+		if math.Signbit(fx) == math.Signbit(flo) {
+			lo, flo = x, fx
+		} else {
+			hi = x
+		}
+		next := lo + (hi-lo)/2
+		if newton {
+			slope, err := derivative(s.f, x)
+			if err != nil {
+				return 0, err
+			}
+			// A step that is NaN fails the comparisons and so bisects too
+			if step := x - fx/slope; step > lo && step < hi {
+				next = step
+			}
+		}
+		if converged(x, next) || hi-lo <= solveTolerance*math.Max(1, math.Abs(x)) {
+			return next, nil
+		}
+		x = next
+	}
+	return 0, s.noConvergence(x)
+}
+
+// end of file
@@ -0,0 +1,72 @@
+package expressionparser
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSolve(t *testing.T) {
+	vars := map[string]float64{"k": 7}
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{`solve(x^2 - 2, "x", 1)`, math.Sqrt2},
+		{`solve(x^2 - 2, "x", -1)`, -math.Sqrt2},
+		{`solve(x^3 - 2*x - 5, "x", 2)`, 2.0945514815423265},
+		{`solve(x - k, "x", 0)`, 7},
+		{`bisect(x^2 - 2, "x", 0, 2)`, math.Sqrt2},
+		{`bisect(x - 1, "x", 1, 2)`, 1},
+		{`bisect(x, "x", 2, -1)`, 0},
+		// Newton's method runs away from the root of atan from 2, where
+		// bounds either side of it find it
+		{`bisect(atan(x), "x", -1, 2)`, 0},
+		{`solve(atan(x), "x", -1, 2)`, 0},
+	}
+	for _, tt := range tests {
+		got, err := EvalWithVars(mustParse(t, tt.input), vars)
+		if err != nil || math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+	if _, err := Evaluate(`solve(atan(x), "x", 2)`); ErrorCode(err) != CodeNoConvergence {
+		t.Errorf(`solve(atan(x), "x", 2): error %v, want %s`, err, CodeNoConvergence)
+	}
+}
+
+func TestSolveErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{`solve(x^2 + 1, "x", 1)`, CodeNoConvergence, "solve: no convergence after 100 iterations, the last estimate being x = "},
+		{`solve(1/x, "x", 1)`, CodeNoConvergence, "solve: no convergence after 100 iterations, the last estimate being x = 1.2676506002277574e+30"},
+		// Endpoints of one sign are an error before any iteration
+		{`bisect(x^2 + 1, "x", -1, 2)`, CodeNoConvergence, "bisect: the expression is 2 at x = -1 and 5 at x = 2, which do not differ in sign"},
+		{`solve(x^2 - 2, "x")`, CodeArgumentCount, "solve expects 3 to 4 arguments, got 2"},
+		{`solve(sqrt(x), "x", -4)`, CodeOutsideDomain, "solve: at x = -4: sqrt"},
+	}
+	for _, tt := range tests {
+		_, err := Evaluate(tt.input)
+		if ErrorCode(err) != tt.code || !strings.HasPrefix(err.Error(), tt.msg) {
+			t.Errorf("%s: error %v (%s), want %s starting %q", tt.input, err, ErrorCode(err), tt.code, tt.msg)
+		}
+	}
+
+	few := NewEvaluator(WithSolveIterations(3))
+	_, err := few.Eval(mustParse(t, `solve(x^3 - 2*x - 5, "x", 100)`))
+	if ErrorCode(err) != CodeNoConvergence || !strings.HasPrefix(err.Error(), "solve: no convergence after 3 iterations, the last estimate being x = 29.64721870432") {
+		t.Errorf("solve with 3 iterations: error %v", err)
+	}
+	if got, err := few.Eval(mustParse(t, `solve(x - 5, "x", 0)`)); err != nil || got != 5 {
+		t.Errorf("solve(x - 5) with 3 iterations = %v, %v; want 5", got, err)
+	}
+	if _, err := NewEvaluator(WithSolveIterations(-1)).Eval(Num(1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("WithSolveIterations(-1): error %v", err)
+	}
+}
+
+// end of file
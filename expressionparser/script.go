@@ -0,0 +1,113 @@
+package expressionparser
+
+import "fmt"
+
+// ParseProgram parses a program: statements separated by semicolons, each
+// an assignment such as "x = 2" or an expression, as in
+// "x = 2; y = x * 3; y + 1". Empty statements are skipped. Invalid input
+// gives a *ParseError. The options configure the Parser.
+func ParseProgram(input string, opts ...Option) ([]Expr, error) {
+	p := NewParser(NewLexer(input), opts...)
+	if p.err != nil {
+		return nil, p.err
+	}
+	var stmts []Expr
+	for {
+		for p.curr.Type == SEMICOLON {
+			p.nextToken()
+		}
+		if p.curr.Type == EOF {
+			return stmts, nil
+		}
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+		if p.curr.Type != SEMICOLON && p.curr.Type != EOF {
+			return nil, p.expected("operator or ';' after statement %d", len(stmts))
+		}
+	}
+}
+
+// parseStatement parses an assignment or an expression.
+func (p *Parser) parseStatement() (Expr, error) {
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	target, ok := expr.(*Variable)
+	if !ok || p.curr.Type != ASSIGN {
+		return expr, nil
+	}
+	p.nextToken()
+	value, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &Assign{Name: target.Name, Value: value, Span: Span{Start: target.Span.Start, End: SpanOf(value).End}}, nil
+}
+
+// ProgramError reports the failure of a statement of a program run by
+// EvalProgram.
+type ProgramError struct {
+	Statement int // number of the statement, from 1
+	Err       error
+}
+
+// Error numbers the statement and gives the error, which locates the
+// failing subexpression in the input of the program.
+func (e *ProgramError) Error() string {
+	return fmt.Sprintf("statement %d: %v", e.Statement, e.Err)
+}
+
+// Unwrap returns the error of the statement.
+func (e *ProgramError) Unwrap() error {
+	return e.Err
+}
+
+// EvalProgram runs a program with the default Evaluator.
+func EvalProgram(stmts []Expr, env *Environment) (float64, error) {
+	return defaultEvaluator.EvalProgram(stmts, env)
+}
+
+// EvalProgram runs the statements of a program, as ParseProgram gives them,
+// in order, each evaluated like EvalIn with env. An assignment stores the
+// value of its expression in env itself, replacing any earlier value, so
+// that later statements, and the caller, see it; a variable read before it
+// is assigned follows the WithUndefinedVariables policy. The result is the
+// value of the last statement, which must be a number. The first statement
+// to fail stops the program with a *ProgramError numbering it.
+func (ev *Evaluator) EvalProgram(stmts []Expr, env *Environment) (float64, error) {
+	if len(stmts) == 0 {
+		return 0, withCode(CodeSyntax, fmt.Errorf("program has no statements"))
+	}
+	if env == nil {
+		env = NewEnvironment(nil)
+	}
+	var value Value
+	for i, stmt := range stmts {
+		var err error
+		if a, ok := stmt.(*Assign); ok {
+			if value, err = ev.EvalIn(a.Value, env); err == nil {
+				env.Set(a.Name, value)
+			}
+		} else {
+			value, err = ev.EvalIn(stmt, env)
+		}
+		if err != nil {
+			return 0, &ProgramError{Statement: i + 1, Err: err}
+		}
+	}
+	if !value.numeric() {
+		return 0, withCode(CodeType, fmt.Errorf("result is a %s, not a number", value.kind))
+	}
+	return value.num, nil
+}
+
+// assignmentError reports an assignment met outside the statements of a program.
+func assignmentError(a *Assign) error {
+	return withCode(CodeUnsupported, fmt.Errorf("assignment to %s is only available as a statement of EvalProgram", a.Name))
+}
+
+// end of file
@@ -0,0 +1,106 @@
+package expressionparser
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEvalProgram(t *testing.T) {
+	stmts, err := ParseProgram("x = 2; y = x * 3; y + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := NewEnvironment(nil)
+	got, err := EvalProgram(stmts, env)
+	if err != nil || got != 7 {
+		t.Fatalf("x = 2; y = x * 3; y + 1 = %v, %v; want 7", got, err)
+	}
+	for name, want := range map[string]float64{"x": 2, "y": 6} {
+		if v, ok := env.Get(name); !ok || v.num != want {
+			t.Errorf("%s = %v, %v after the program; want %v", name, v, ok, want)
+		}
+	}
+
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"x = 1; x = x + 1; x", 2},
+		{"a = 1;", 1},
+		{"r = 2; area = 3 * r ^ 2; area / r", 6},
+		{"n = 3; f = n > 2 ? n * 2 : 0; f", 6},
+	}
+	for _, tt := range tests {
+		stmts, err := ParseProgram(tt.input)
+		if err != nil {
+			t.Errorf("ParseProgram(%q): %v", tt.input, err)
+			continue
+		}
+		if got, err := EvalProgram(stmts, nil); err != nil || math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%q = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	// A name read before it is assigned follows the policy
+	stmts, _ = ParseProgram("y = z + 1; z = 5; y + z")
+	if got, err := NewEvaluator(WithUndefinedVariables(UndefinedDefault(0))).EvalProgram(stmts, nil); err != nil || got != 6 {
+		t.Errorf("z read before it is assigned, as 0: %v, %v; want 6", got, err)
+	}
+}
+
+func TestEvalProgramErrors(t *testing.T) {
+	tests := []struct {
+		input     string
+		statement int
+		msg       string
+	}{
+		{"y = z + 1; y", 1, `statement 1: undefined variable z in "z" at offset 4`},
+		{"a = 1; b = a / 0; b", 2, `statement 2: division by zero in "a / 0" at offset 11`},
+	}
+	for _, tt := range tests {
+		stmts, err := ParseProgram(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env := NewEnvironment(nil)
+		_, err = EvalProgram(stmts, env)
+		var progErr *ProgramError
+		if !errors.As(err, &progErr) || progErr.Statement != tt.statement || err.Error() != tt.msg {
+			t.Errorf("%q: error %v, want %q", tt.input, err, tt.msg)
+		}
+		var evalErr *EvalError
+		if !errors.As(err, &evalErr) || evalErr.Pos < 0 {
+			t.Errorf("%q: error %v has no position", tt.input, err)
+		}
+	}
+	stmts, _ := ParseProgram("a = 1; b = a / 0; c = 3")
+	env := NewEnvironment(nil)
+	EvalProgram(stmts, env)
+	if _, ok := env.Get("c"); ok {
+		t.Error("statements after the failing one ran")
+	}
+	if _, err := EvalProgram(nil, nil); ErrorCode(err) != CodeSyntax {
+		t.Errorf("empty program: error %v, want %s", err, CodeSyntax)
+	}
+	stmts, _ = ParseProgram(`a = "s"; a`)
+	if _, err := EvalProgram(stmts, nil); ErrorCode(err) != CodeType {
+		t.Errorf("program giving a string: error %v, want %s", err, CodeType)
+	}
+}
+
+func TestParseProgramErrors(t *testing.T) {
+	for input, msg := range map[string]string{
+		"a = 1; b = 2 3": "expected operator or ';' after statement 2 but found number 3 at offset 13",
+		"a = 1 b = 2":    "expected operator or ';' after statement 1 but found '=' at offset 8",
+		"a = ; 1":        "expected number, name, string, '(', '[', or unary '-' or '!' but found ';' at offset 4",
+	} {
+		_, err := ParseProgram(input)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || err.Error() != msg {
+			t.Errorf("ParseProgram(%q): error %v, want %q", input, err, msg)
+		}
+	}
+}
+
+// end of file
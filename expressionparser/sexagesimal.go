@@ -0,0 +1,105 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SexagesimalComponents is what a sexagesimal literal, read
+// WithSexagesimal, does with minutes or seconds of 60 or more.
+type SexagesimalComponents int
+
+const (
+	// SexagesimalReject makes a literal such as 1:75 or 0:10:60 invalid.
+	// It is the default.
+	SexagesimalReject SexagesimalComponents = iota
+
+	// SexagesimalCarry carries the excess into the part before, so that
+	// 1:75 is 2:15 and 0:59:60 is 1:00:00.
+	SexagesimalCarry
+)
+
+// WithSexagesimal makes the Lexer read numbers in colon notation, hours
+// and minutes as in 2:30 or hours, minutes and seconds as in 1:30:00 or
+// 0:00:12.5, as literals of the duration they give, in seconds, so that
+// "1:30:00 + 0:45:00" is the duration 2:15:00 and "2:30 * 3" is 7:30.
+// Durations add, subtract, scale by numbers, divide and compare as those
+// of literals such as 90 min do. The hours are any whole number; the
+// minutes and seconds take two digits each, and only the seconds a
+// fraction. components says what minutes and seconds of 60 or more do.
+// The colon of ?: must then have a space before it when a number precedes
+// it, as in c ? 1 : 2. FormatResult renders seconds in this notation in
+// StyleSexagesimal.
+func WithSexagesimal(components SexagesimalComponents) Option {
+	return func(c *config) {
+		c.sexagesimal = true
+		c.sexagesimalCarry = components == SexagesimalCarry
+	}
+}
+
+// readSexagesimal reads the rest of a literal in colon notation whose hours
+// have been read: one or two parts after colons, the last of three taking
+// a fraction.
+func (l *Lexer) readSexagesimal(start int) string {
+	for parts := 1; parts < 3 && l.ch == ':' && isDigit(l.peekChar(1)); parts++ {
+		l.readChar()
+		l.readDigits()
+		if parts == 2 && l.ch == '.' && isDigit(l.peekChar(1)) {
+			l.readChar()
+			l.readDigits()
+		}
+	}
+	return l.input[start:l.offset()]
+}
+
+// parseSexagesimal parses the literal of tok, in colon notation, as a
+// duration in seconds.
+func (p *Parser) parseSexagesimal(tok Token) (Expr, error) {
+	invalid := func(format string, args ...interface{}) error {
+		msg := "invalid sexagesimal literal " + tok.Value + ": " + fmt.Sprintf(format, args...)
+		return &ParseError{Msg: msg, Pos: tok.Pos, code: CodeInvalidLiteral, params: map[string]string{"literal": tok.Value}}
+	}
+	parts := strings.Split(tok.Value, ":")
+	names := []string{"hours", "minutes", "seconds"}
+	seconds := 0.0
+	for i, part := range parts {
+		if whole, _, _ := strings.Cut(part, "."); i > 0 && len(whole) != 2 {
+			return nil, invalid("%s %s must have two digits", names[i], part)
+		}
+		x, err := strconv.ParseFloat(part, 64)
+		if err != nil || math.IsInf(x, 0) {
+			return nil, invalid("%s %s are out of range", names[i], part)
+		}
+		if i > 0 && x >= 60 && !p.cfg.sexagesimalCarry {
+			return nil, invalid("%s %s are 60 or more", names[i], part)
+		}
+		seconds += x * math.Pow(60, float64(2-i))
+	}
+	return &QuantityLiteral{Value: seconds, Unit: "s", Span: Span{Start: tok.Pos, End: p.prevEnd}}, nil
+}
+
+// sexagesimal renders v seconds as hours, minutes and seconds, as in
+// 2:15:00 or -0:00:03.25, with precision digits after the point of the
+// seconds or as few as identify them.
+func sexagesimal(v float64, precision int) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	if precision >= 0 {
+		// Round first, so that 59.999 seconds carry into the minutes
+		scale := math.Pow10(precision)
+		v = math.Round(v*scale) / scale
+	}
+	whole := math.Floor(v)
+	hours, minutes := math.Floor(whole/3600), math.Mod(math.Floor(whole/60), 60)
+	seconds := strconv.FormatFloat(math.Mod(whole, 60)+(v-whole), 'f', precision, 64)
+	if seconds[0] == '.' || len(seconds) == 1 || seconds[1] == '.' {
+		seconds = "0" + seconds
+	}
+	return fmt.Sprintf("%s%s:%02d:%s", sign, strconv.FormatFloat(hours, 'f', 0, 64), int(minutes), seconds)
+}
+
+// end of file
@@ -0,0 +1,109 @@
+package expressionparser
+
+import "testing"
+
+func TestSexagesimal(t *testing.T) {
+	sexagesimal := FormatOptions{Style: StyleSexagesimal, Precision: -1}
+	tests := []struct {
+		input string
+		want  string // the duration in StyleSexagesimal
+	}{
+		{"1:30:00 + 0:45:00", "2:15:00"},
+		{"2:30 * 3", "7:30:00"},
+		{"3 * 2:30", "7:30:00"},
+		{"7:30 / 3", "2:30:00"},
+		{"1:30 - 2:00", "-0:30:00"},
+		{"1:30 + 90 min", "3:00:00"},
+		{"0:00:12.5", "0:00:12.5"},
+		{"0:00:12.5 / 4", "0:00:03.125"},
+		{"1:00:00 / 3", "0:20:00"},
+		{"0:00:01 / 3", "0:00:00.333333333"}, // durations hold nanoseconds
+		{"100:00:01", "100:00:01"},
+		{"1 ? 1:30 : 2:00", "1:30:00"},
+	}
+	for _, tt := range tests {
+		expr, err := ParseString(tt.input, WithSexagesimal(SexagesimalReject))
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		got, err := EvalValue(expr, nil)
+		d, derr := got.AsDuration()
+		if err != nil || derr != nil {
+			t.Errorf("%s = %v, %v; want a duration", tt.input, got, err)
+			continue
+		}
+		if s := FormatResult(d.Seconds(), sexagesimal); s != tt.want {
+			t.Errorf("%s = %s, want %s", tt.input, s, tt.want)
+		}
+	}
+
+	// Durations divide to numbers and compare
+	for input, want := range map[string]float64{
+		"7:30 / 2:30":    3,
+		"1:30 > 1:29:59": 1,
+		"1:30 == 90 min": 1,
+		"0:01 < 0:00:59": 0,
+		"1 ? 2 : 3":      2,
+	} {
+		if got, err := Evaluate(input, WithSexagesimal(SexagesimalReject)); err != nil || got != want {
+			t.Errorf("%s = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if got := FormatResult(3.25, FormatOptions{Style: StyleSexagesimal, Precision: 2}); got != "0:00:03.25" {
+		t.Errorf("3.25 s to 2 places = %s, want 0:00:03.25", got)
+	}
+}
+
+func TestSexagesimalComponents(t *testing.T) {
+	tests := []struct {
+		input string
+		carry float64 // in seconds
+		msg   string  // rejecting them
+	}{
+		{"1:75", 8100, "invalid sexagesimal literal 1:75: minutes 75 are 60 or more at offset 0"},
+		{"0:10:60", 660, "invalid sexagesimal literal 0:10:60: seconds 60 are 60 or more at offset 0"},
+		{"0:59:60", 3600, "invalid sexagesimal literal 0:59:60: seconds 60 are 60 or more at offset 0"},
+		{"0:00:99.5", 99.5, "invalid sexagesimal literal 0:00:99.5: seconds 99.5 are 60 or more at offset 0"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseString(tt.input, WithSexagesimal(SexagesimalReject)); ErrorCode(err) != CodeInvalidLiteral || err.Error() != tt.msg {
+			t.Errorf("%s: error %v, want %q", tt.input, err, tt.msg)
+		}
+		got, err := EvalValue(mustParseWith(t, tt.input, WithSexagesimal(SexagesimalCarry)), nil)
+		if d, derr := got.AsDuration(); err != nil || derr != nil || d.Seconds() != tt.carry {
+			t.Errorf("%s carrying = %v, %v; want %v s", tt.input, got, err, tt.carry)
+		}
+	}
+
+	for input, msg := range map[string]string{
+		"1:5":    "invalid sexagesimal literal 1:5: minutes 5 must have two digits at offset 0",
+		"1:30:5": "invalid sexagesimal literal 1:30:5: seconds 5 must have two digits at offset 0",
+	} {
+		for _, components := range []SexagesimalComponents{SexagesimalReject, SexagesimalCarry} {
+			if _, err := ParseString(input, WithSexagesimal(components)); ErrorCode(err) != CodeInvalidLiteral || err.Error() != msg {
+				t.Errorf("%s: error %v, want %q", input, err, msg)
+			}
+		}
+	}
+
+	// Without the option, a colon after a number is the colon of ?:
+	if _, err := ParseString("1:30"); ErrorCode(err) != CodeUnexpectedToken {
+		t.Errorf("1:30 without WithSexagesimal: error %v, want %s", err, CodeUnexpectedToken)
+	}
+	if _, err := Evaluate("1:30 + 2", WithSexagesimal(SexagesimalReject)); ErrorCode(err) != CodeType {
+		t.Errorf("1:30 + 2: error %v, want %s", err, CodeType)
+	}
+}
+
+// mustParseWith parses input with opts, failing the test on an error.
+func mustParseWith(t *testing.T, input string, opts ...Option) Expr {
+	t.Helper()
+	expr, err := ParseString(input, opts...)
+	if err != nil {
+		t.Fatalf("ParseString(%q): %v", input, err)
+	}
+	return expr
+}
+
+// end of file
@@ -0,0 +1,99 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ToSExpr renders an expression as a Lisp-style prefix form, e.g.
+// "(* (+ 2 3) 5)". The rendering is canonical and deterministic: numbers use
+// strconv's shortest 'g' form, unary minus is "(neg x)", variables are bare
+// names, quantities "(quantity 10 km)", calls are "(call name arg...)",
+// list literals "(list elem...)",
+// let bindings "(let name value body)", assignments "(set name value)" and
+// conditionals "(if cond then else)".
+func ToSExpr(expr Expr) string {
+	var sb strings.Builder
+	writeSExpr(&sb, expr)
+	return sb.String()
+}
+
+// writeSExpr appends the prefix form of expr to sb.
+func writeSExpr(sb *strings.Builder, expr Expr) {
+	switch v := expr.(type) {
+	case nil:
+		sb.WriteString("()")
+	case *Number:
+		sb.WriteString(literalText(v))
+	case *Variable:
+		sb.WriteString(v.Name)
+	case *CellRef:
+		fmt.Fprintf(sb, "(cell %s)", v.Ref)
+	case *CellRange:
+		fmt.Fprintf(sb, "(cells %s %s)", v.From, v.To)
+	case *QuantityLiteral:
+		fmt.Fprintf(sb, "(quantity %s %s)", formatNumber(v.Value), v.Unit)
+	case *StringLiteral:
+		sb.WriteString(strconv.Quote(v.Value))
+	case *BinaryOp:
+		sb.WriteString("(")
+		sb.WriteString(operatorSymbol(v.Op))
+		sb.WriteString(" ")
+		writeSExpr(sb, v.Left)
+		sb.WriteString(" ")
+		writeSExpr(sb, v.Right)
+		sb.WriteString(")")
+	case *UnaryOp:
+		op := operatorSymbol(v.Op)
+		if v.Op.Type == MINUS {
+			op = "neg"
+		}
+		sb.WriteString("(")
+		sb.WriteString(op)
+		sb.WriteString(" ")
+		writeSExpr(sb, v.Operand)
+		sb.WriteString(")")
+	case *FunctionCall:
+		sb.WriteString("(call ")
+		sb.WriteString(v.Name)
+		for _, arg := range v.Args {
+			sb.WriteString(" ")
+			writeSExpr(sb, arg)
+		}
+		sb.WriteString(")")
+	case *ListLiteral:
+		sb.WriteString("(list")
+		for _, elem := range v.Elems {
+			sb.WriteString(" ")
+			writeSExpr(sb, elem)
+		}
+		sb.WriteString(")")
+	case *Let:
+		sb.WriteString("(let ")
+		sb.WriteString(v.Name)
+		sb.WriteString(" ")
+		writeSExpr(sb, v.Value)
+		sb.WriteString(" ")
+		writeSExpr(sb, v.Body)
+		sb.WriteString(")")
+	case *Assign:
+		sb.WriteString("(set ")
+		sb.WriteString(v.Name)
+		sb.WriteString(" ")
+		writeSExpr(sb, v.Value)
+		sb.WriteString(")")
+	case *Conditional:
+		sb.WriteString("(if ")
+		writeSExpr(sb, v.Cond)
+		sb.WriteString(" ")
+		writeSExpr(sb, v.Then)
+		sb.WriteString(" ")
+		writeSExpr(sb, v.Else)
+		sb.WriteString(")")
+	default:
+		sb.WriteString(fmt.Sprintf("<%T>", expr))
+	}
+}
+
+// end of file
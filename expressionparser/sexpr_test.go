@@ -0,0 +1,55 @@
+package expressionparser
+
+import "testing"
+
+func TestToSExpr(t *testing.T) {
+	tests := []struct {
+		expr Expr
+		want string
+	}{
+		{nil, "()"},
+		{Num(2.5), "2.5"},
+		{Num(1e21), "1e+21"},
+		{&Number{Value: 4, Imag: true}, "4i"},
+		{Var("x"), "x"},
+		{&CellRef{Ref: "A1"}, "(cell A1)"},
+		{&CellRange{From: "B1", To: "B10"}, "(cells B1 B10)"},
+		{&QuantityLiteral{Value: 10, Unit: "km"}, "(quantity 10 km)"},
+		{&StringLiteral{Value: "a \"b\""}, `"a \"b\""`},
+		{Mul(Add(Num(2), Num(3)), Num(5)), "(* (+ 2 3) 5)"},
+		{Neg(Var("x")), "(neg x)"},
+		{Not(Var("x")), "(! x)"},
+		{Call("max", Num(1), Var("y")), "(call max 1 y)"},
+		{Call("rand"), "(call rand)"},
+		{List(Num(1), Num(2)), "(list 1 2)"},
+		{List(), "(list)"},
+		{&Let{Name: "a", Value: Num(2), Body: Mul(Var("a"), Var("a"))}, "(let a 2 (* a a))"},
+		{&Assign{Name: "x", Value: Num(1)}, "(set x 1)"},
+		{If(Greater(Var("x"), Num(0)), Var("x"), Neg(Var("x"))), "(if (> x 0) x (neg x))"},
+	}
+	for _, tt := range tests {
+		if got := ToSExpr(tt.expr); got != tt.want {
+			t.Errorf("ToSExpr(%#v) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestToSExprIgnoresSpelling(t *testing.T) {
+	// Trees that are Equal render alike, however their source was written
+	inputs := []string{"(2+3)*5", "( 2 + 3 ) * 5.0", "((2 + 3)) * 5e0"}
+	var want string
+	for i, input := range inputs {
+		expr, err := ParseString(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ToSExpr(expr)
+		if i == 0 {
+			want = got
+		} else if got != want {
+			t.Errorf("ToSExpr(%q) = %q, want %q as for %q", input, got, want, inputs[0])
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,141 @@
+package expressionparser
+
+// Simplify applies conservative algebraic identities until none matches:
+//
+//	x * 1, 1 * x, x / 1, x + 0, 0 + x, x - 0, x ^ 1  =>  x
+//	0 - x                                             =>  -x
+//	x + x                                             =>  2 * x
+//	-(-x)                                             =>  x
+//	0 * x, x * 0                                      =>  0
+//	x - x                                             =>  0
+//	x ^ 0                                             =>  1
+//
+// Rules that discard an operand (the last three groups) only fire when that
+// operand is a literal or a variable, so no erroring subexpression is ever
+// dropped. The caveat is that a variable bound to NaN or ±Inf would have
+// produced NaN under 0 * x and x - x, and an unbound variable no longer
+// reports an error once discarded.
+//
+// Rules that give back an operand x, and -(-x), only fire when x is proven
+// to be a number: a literal, a variable, a builtin of numbers, or
+// arithmetic and conditionals of those. "ab" * 1 is an error and (a < b) * 1
+// the number 1, not the bool true, so both are left as they are. Variables
+// are taken to be numbers, so a variable bound to a string or a bool by
+// EvalValue may give a value under Simplify where it gave an error, or a
+// bool where it gave a number.
+func Simplify(expr Expr) Expr {
+	for {
+		changed := false
+		expr = Rewrite(expr, func(e Expr) Expr {
+			if s := simplifyNode(e); s != nil {
+				changed = true
+				return s
+			}
+			return nil
+		})
+		if !changed {
+			return expr
+		}
+	}
+}
+
+// simplifyNode applies a single rule at the root of expr, returning nil when none matches.
+func simplifyNode(expr Expr) Expr {
+	switch v := expr.(type) {
+	case *UnaryOp:
+		if inner, ok := v.Operand.(*UnaryOp); ok && v.Op.Type == MINUS && inner.Op.Type == MINUS && isNumeric(inner.Operand) {
+			return inner.Operand
+		}
+	case *BinaryOp:
+		switch v.Op.Type {
+		case PLUS:
+			if isLiteral(v.Right, 0) && isNumeric(v.Left) {
+				return v.Left
+			}
+			if isLiteral(v.Left, 0) && isNumeric(v.Right) {
+				return v.Right
+			}
+			if l, ok := v.Left.(*Variable); ok {
+				if r, ok := v.Right.(*Variable); ok && l.Name == r.Name {
+					return Mul(Num(2), l)
+				}
+			}
+		case MINUS:
+			if isLiteral(v.Right, 0) && isNumeric(v.Left) {
+				return v.Left
+			}
+			if isLiteral(v.Left, 0) {
+				return Neg(v.Right)
+			}
+			if l, ok := v.Left.(*Variable); ok {
+				if r, ok := v.Right.(*Variable); ok && l.Name == r.Name {
+					return Num(0)
+				}
+			}
+		case MULT:
+			if isLiteral(v.Right, 1) && isNumeric(v.Left) {
+				return v.Left
+			}
+			if isLiteral(v.Left, 1) && isNumeric(v.Right) {
+				return v.Right
+			}
+			if (isLiteral(v.Left, 0) && isDiscardable(v.Right)) || (isLiteral(v.Right, 0) && isDiscardable(v.Left)) {
+				return Num(0)
+			}
+		case DIV:
+			if isLiteral(v.Right, 1) && isNumeric(v.Left) {
+				return v.Left
+			}
+		case POW:
+			if isLiteral(v.Right, 1) && isNumeric(v.Left) {
+				return v.Left
+			}
+			if isLiteral(v.Right, 0) && isDiscardable(v.Left) {
+				return Num(1)
+			}
+		}
+	}
+	return nil
+}
+
+// isLiteral reports whether expr is the real number value.
+func isLiteral(expr Expr, value float64) bool {
+	n, ok := expr.(*Number)
+	return ok && !n.Imag && n.Value == value
+}
+
+// isNumeric reports whether expr gives a number whenever it gives a value,
+// taking variables to be numbers.
+func isNumeric(expr Expr) bool {
+	switch v := expr.(type) {
+	case *Number, *Variable:
+		return true
+	case *UnaryOp:
+		return v.Op.Type == MINUS && isNumeric(v.Operand)
+	case *BinaryOp:
+		switch v.Op.Type {
+		case PLUS, MINUS, MULT, DIV, MOD, POW:
+			return isNumeric(v.Left) && isNumeric(v.Right)
+		}
+	case *Conditional:
+		return isNumeric(v.Then) && isNumeric(v.Else)
+	case *FunctionCall:
+		_, ok := builtins[v.Name]
+		return ok
+	}
+	return false
+}
+
+// isDiscardable reports whether expr can be dropped without losing an error:
+// a finite literal or a plain variable.
+func isDiscardable(expr Expr) bool {
+	switch v := expr.(type) {
+	case *Number:
+		return v.Value-v.Value == 0
+	case *Variable:
+		return true
+	}
+	return false
+}
+
+// end of file
@@ -0,0 +1,65 @@
+package expressionparser
+
+import "testing"
+
+func TestSimplify(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"(x * 1) + 0", "x"},
+		{"0 + (1 * (x / 1)) ^ 1", "x"},
+		{"x + x", "2 * x"},
+		{"(x * 1) + (0 + x)", "2 * x"},
+		{"x - (x * 1)", "0"},
+		{"0 - x", "-x"},
+		{"-(0 - x)", "x"},
+		{"--x", "x"},
+		{"0 * y + x ^ 0", "1"},
+		{"(x - x) * y + 1", "1"},
+		{"0 * (1 / zero)", "0 * (1 / zero)"},
+		{"(1 / zero) ^ 0", "(1 / zero) ^ 0"},
+		{"x * 2 + y", "x * 2 + y"},
+		{"sin(x) / 1 - 0", "sin(x)"},
+		{"1 * (x == 2 ? 3 : y) + 0", "x == 2 ? 3 : y"},
+		// Strings and bools are not numbers, so the rules keep them as they are
+		{`"ab" * 1`, `"ab" * 1`},
+		{"(x < 1) * 1 + 0", "(x < 1) * 1 + 0"},
+		{"(x > 0) ^ 1", "(x > 0) ^ 1"},
+		{"--(x > 0)", "--(x > 0)"},
+	}
+	for _, tt := range tests {
+		expr, err := ParseString(tt.input)
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", tt.input, err)
+		}
+		got := Simplify(expr)
+		if Format(got) != tt.want {
+			t.Errorf("Simplify(%q) = %q, want %q", tt.input, Format(got), tt.want)
+		}
+		if again := Simplify(got); !Equal(again, got) {
+			t.Errorf("Simplify(%q) = %q, which is not a fixed point: it simplifies to %q", tt.input, Format(got), Format(again))
+		}
+
+		want, wantErr := EvalWithVars(expr, corpusVars)
+		value, err := EvalWithVars(got, corpusVars)
+		if ErrorCode(err) != ErrorCode(wantErr) || wantErr == nil && value != want {
+			t.Errorf("%q evaluates to %v, %v simplified, want %v, %v", tt.input, value, err, want, wantErr)
+		}
+	}
+}
+
+// TestSimplifyKeepsKinds checks, with EvalValue, that simplifying changes
+// neither the kind of a result nor whether there is one.
+func TestSimplifyKeepsKinds(t *testing.T) {
+	for _, input := range []string{`"ab" * 1`, `"ab" / 1 + 0`, "(1 < 2) * 1", "--(1 < 2)", "0 + (1 < 2) ^ 1", "-(-2) * 1"} {
+		expr := mustParse(t, input)
+		want, wantErr := EvalValue(expr, nil)
+		got, err := EvalValue(Simplify(expr), nil)
+		if (err == nil) != (wantErr == nil) || err == nil && got.String() != want.String() {
+			t.Errorf("%q simplifies to %q, giving %v, %v; want %v, %v", input, Format(Simplify(expr)), got, err, want, wantErr)
+		}
+	}
+}
+
+// end of file
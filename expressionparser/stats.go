@@ -0,0 +1,66 @@
+package expressionparser
+
+import (
+	"context"
+	"fmt"
+)
+
+// Stats describes the work done by an evaluation, as counted by
+// EvalWithStats.
+type Stats struct {
+	Operators map[string]int // evaluations of each operator by symbol, with unary minus as "neg"
+	Calls     map[string]int // calls of each function by name
+	MaxDepth  int            // deepest nesting of subexpressions under evaluation, 1 for a lone literal
+	Skipped   int            // subexpressions left unevaluated by short-circuiting or ?:
+}
+
+// statsCollector counts the work of an evaluation as it goes.
+type statsCollector struct {
+	stats Stats
+	depth int
+}
+
+// enter records the start of the evaluation of n nested subexpressions.
+func (c *statsCollector) enter(n int) {
+	c.depth += n
+	if c.depth > c.stats.MaxDepth {
+		c.stats.MaxDepth = c.depth
+	}
+}
+
+// operator counts an evaluation of the operator with the given symbol.
+func (c *statsCollector) operator(symbol string) {
+	c.stats.Operators[symbol]++
+}
+
+// EvalWithStats evaluates an expression like EvalWithVars, returning with
+// the result the Stats of its evaluation.
+func EvalWithStats(expr Expr, vars map[string]float64) (float64, Stats, error) {
+	ev := *defaultEvaluator
+	ev.vars = vars
+	return ev.EvalWithStats(expr)
+}
+
+// EvalWithStats evaluates expr like Eval and also returns what the
+// evaluation did: how often each operator was applied and each function
+// called, how deeply subexpressions nested, and how many were skipped. The
+// parts skipped by && and || or by a conditional count only towards
+// Skipped, and evaluation WithMemoization does not reuse results, so the
+// counts are those of the expression as written. When evaluation fails,
+// the stats up to the failure are returned with the error. Evaluations
+// without stats do not collect them and pay nothing for them.
+func (ev *Evaluator) EvalWithStats(expr Expr) (float64, Stats, error) {
+	run := *ev
+	run.stats = &statsCollector{stats: Stats{Operators: map[string]int{}, Calls: map[string]int{}}}
+	value, err := run.evalValueContext(context.Background(), expr, nil)
+	stats := run.stats.stats
+	if err != nil {
+		return 0, stats, err
+	}
+	if !value.numeric() {
+		return 0, stats, withCode(CodeType, fmt.Errorf("result is a %s, not a number", value.kind))
+	}
+	return value.num, stats, nil
+}
+
+// end of file
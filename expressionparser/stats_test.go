@@ -0,0 +1,79 @@
+package expressionparser
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEvalWithStats(t *testing.T) {
+	vars := map[string]float64{"x": 4}
+	tests := []struct {
+		input string
+		want  float64
+		stats Stats
+	}{
+		// The branch not taken and its sqrt, ln and * are not counted
+		{"x > 0 ? sqrt(x) + 1 : ln(x) * 2 - 3", 3, Stats{Operators: map[string]int{">": 1, "+": 1}, Calls: map[string]int{"sqrt": 1}, MaxDepth: 4, Skipped: 1}},
+		{"x < 0 ? sqrt(x) + 1 : max(x, 2) * 2", 8, Stats{Operators: map[string]int{"<": 1, "*": 1}, Calls: map[string]int{"max": 1}, MaxDepth: 4, Skipped: 1}},
+		{"0 && sqrt(x) || -x", 1, Stats{Operators: map[string]int{"&&": 1, "||": 1, "neg": 1}, Calls: map[string]int{}, MaxDepth: 3, Skipped: 1}},
+		{"-(1 + 2) * 3", -9, Stats{Operators: map[string]int{"*": 1, "+": 1, "neg": 1}, Calls: map[string]int{}, MaxDepth: 4}},
+		{"sqrt(x) + sqrt(x + 1) - sqrt(1)", 2 + math.Sqrt(5) - 1, Stats{Operators: map[string]int{"+": 2, "-": 1}, Calls: map[string]int{"sqrt": 3}, MaxDepth: 5}},
+		{"7", 7, Stats{Operators: map[string]int{}, Calls: map[string]int{}, MaxDepth: 1}},
+	}
+	for _, tt := range tests {
+		got, stats, err := EvalWithStats(mustParse(t, tt.input), vars)
+		if err != nil || got != tt.want {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+		if !reflect.DeepEqual(stats, tt.stats) {
+			t.Errorf("%s: stats %+v, want %+v", tt.input, stats, tt.stats)
+		}
+	}
+}
+
+func TestEvalWithStatsError(t *testing.T) {
+	// The stats up to the failure come with the error
+	_, stats, err := EvalWithStats(mustParse(t, "sqrt(x) + sqrt(x + 1) + 1 / 0"), map[string]float64{"x": 4})
+	if !errors.Is(err, ErrDivisionByZero) {
+		t.Fatalf("error %v, want division by zero", err)
+	}
+	want := Stats{Operators: map[string]int{"+": 3, "/": 1}, Calls: map[string]int{"sqrt": 2}, MaxDepth: 5}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("stats %+v, want %+v", stats, want)
+	}
+
+	// The evaluator collects nothing outside EvalWithStats
+	ev := NewEvaluator()
+	if _, _, err := ev.EvalWithStats(mustParse(t, "1 + 2")); err != nil {
+		t.Fatal(err)
+	}
+	if ev.stats != nil {
+		t.Error("EvalWithStats left a collector on the evaluator")
+	}
+}
+
+func BenchmarkEvalWithStats(b *testing.B) {
+	expr, err := ParseString("x > 0 ? sqrt(x) * 2 + max(x, 1) : ln(x) - 3")
+	if err != nil {
+		b.Fatal(err)
+	}
+	vars := map[string]float64{"x": 4}
+	b.Run("Eval", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := EvalWithVars(expr, vars); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("EvalWithStats", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, _, err := EvalWithStats(expr, vars); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// end of file
@@ -0,0 +1,318 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// stringBuiltin is a builtin taking or returning strings or dates, or
+// converting between kinds. params gives the kind of each argument, the
+// last one repeating for the further arguments of a variadic builtin: a
+// number parameter accepts a number or a bool, anyKind every value, and any
+// other needs a value of its kind.
+type stringBuiltin struct {
+	sig    builtin
+	params []Kind
+	fn     func(args []Value) (Value, error)
+}
+
+// Builtins working on strings and dates, by name. Lengths and offsets count
+// characters (Unicode code points), not bytes, so that len("héllo") is 5;
+// fields, like offsets, are numbered from 0. convert, match and capture
+// have no fn, as they use the units or the compiled patterns of the
+// evaluator applying them.
+var stringBuiltins = map[string]stringBuiltin{
+	"len": {builtin{arity: 1}, []Kind{StringKind}, func(args []Value) (Value, error) {
+		return NumberValue(float64(len([]rune(args[0].str)))), nil
+	}},
+	"upper": {builtin{arity: 1}, []Kind{StringKind}, func(args []Value) (Value, error) {
+		return StringValue(strings.ToUpper(args[0].str)), nil
+	}},
+	"lower": {builtin{arity: 1}, []Kind{StringKind}, func(args []Value) (Value, error) {
+		return StringValue(strings.ToLower(args[0].str)), nil
+	}},
+	"substr": {builtin{arity: 3}, []Kind{StringKind, NumberKind, NumberKind}, substr},
+	"contains": {builtin{arity: 2}, []Kind{StringKind, StringKind}, func(args []Value) (Value, error) {
+		return BoolValue(strings.Contains(args[0].str, args[1].str)), nil
+	}},
+	"startswith": {builtin{arity: 2}, []Kind{StringKind, StringKind}, func(args []Value) (Value, error) {
+		return BoolValue(strings.HasPrefix(args[0].str, args[1].str)), nil
+	}},
+	"endswith": {builtin{arity: 2}, []Kind{StringKind, StringKind}, func(args []Value) (Value, error) {
+		return BoolValue(strings.HasSuffix(args[0].str, args[1].str)), nil
+	}},
+	"trim": {builtin{arity: 1}, []Kind{StringKind}, func(args []Value) (Value, error) {
+		return StringValue(strings.TrimSpace(args[0].str)), nil
+	}},
+	"replace":      {builtin{arity: 3}, []Kind{StringKind, StringKind, StringKind}, replace},
+	"field":        {builtin{arity: 3}, []Kind{StringKind, StringKind, NumberKind}, field},
+	"format":       {builtin{arity: 1, variadic: true}, []Kind{StringKind, anyKind}, formatString},
+	"date":         {builtin{arity: 1}, []Kind{StringKind}, parseDate},
+	"now":          {builtin{impure: true}, nil, currentTime},
+	"days_between": {builtin{arity: 2}, []Kind{DateKind, DateKind}, daysBetween},
+	"int":          {builtin{arity: 1}, []Kind{anyKind}, toInt},
+	"float":        {builtin{arity: 1}, []Kind{anyKind}, toFloat},
+	"bool":         {builtin{arity: 1}, []Kind{anyKind}, toBool},
+	"str":          {builtin{arity: 1}, []Kind{anyKind}, toStr},
+	"convert":      {builtin{arity: 3}, []Kind{NumberKind, StringKind, StringKind}, nil},
+	"hex":          {builtin{arity: 1, optional: 1}, []Kind{NumberKind}, prefixedBase("hex", 16)},
+	"oct":          {builtin{arity: 1, optional: 1}, []Kind{NumberKind}, prefixedBase("oct", 8)},
+	"bin":          {builtin{arity: 1, optional: 1}, []Kind{NumberKind}, prefixedBase("bin", 2)},
+	"tobase":       {builtin{arity: 2, optional: 1}, []Kind{NumberKind}, toBase},
+	"frombase":     {builtin{arity: 2}, []Kind{StringKind, NumberKind}, fromBase},
+	"match":        {builtin{arity: 2}, []Kind{StringKind, StringKind}, nil},
+	"capture":      {builtin{arity: 3}, []Kind{StringKind, StringKind, NumberKind}, nil},
+	"roman":        {builtin{arity: 1}, []Kind{NumberKind}, toRoman},
+}
+
+// substr returns length characters of a string from offset start, which
+// must both be whole numbers lying within the string.
+func substr(args []Value) (Value, error) {
+	s := []rune(args[0].str)
+	start, length := args[1].num, args[2].num
+	if start != math.Trunc(start) || length != math.Trunc(length) {
+		return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("substr: start %s and length %s must be whole numbers", formatNumber(start), formatNumber(length)))
+	}
+	if start < 0 || start > float64(len(s)) {
+		return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("substr: start %s is outside a string of length %d", formatNumber(start), len(s)))
+	}
+	if length < 0 || start+length > float64(len(s)) {
+		return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("substr: length %s from start %s runs past a string of length %d", formatNumber(length), formatNumber(start), len(s)))
+	}
+	return StringValue(string(s[int(start) : int(start)+int(length)])), nil
+}
+
+// replace returns a string with every occurrence of a non-empty substring
+// replaced by another.
+func replace(args []Value) (Value, error) {
+	if args[1].str == "" {
+		return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("replace: the string to replace is empty"))
+	}
+	return StringValue(strings.ReplaceAll(args[0].str, args[1].str, args[2].str)), nil
+}
+
+// field splits a string at every occurrence of a non-empty separator and
+// returns the part numbered n from 0, which must exist.
+func field(args []Value) (Value, error) {
+	sep, n := args[1].str, args[2].num
+	if sep == "" {
+		return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("field: the separator is empty"))
+	}
+	fields := strings.Split(args[0].str, sep)
+	if n != math.Trunc(n) {
+		return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("field: index %s must be a whole number", formatNumber(n)))
+	}
+	if n < 0 || n >= float64(len(fields)) {
+		return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("field: index %s is outside the %d fields of the string", formatNumber(n), len(fields)))
+	}
+	return StringValue(fields[int(n)]), nil
+}
+
+// formatString fills in the verbs of a format string from the further
+// arguments in turn, supporting a safe subset of those of fmt: %d for a
+// whole number, %f for a number with six decimal places, %s for any value
+// as str converts it, and %% for a percent sign. A verb may have a width,
+// as in %5d, to which its text is padded with spaces on the left, and a
+// precision, as in %.2f or %8.3f: the decimal places of %f, the least
+// number of digits of %d, and the most characters of %s; each is at most
+// two digits. Other verbs and flags are errors, located at the verb, as
+// are missing or unused arguments.
+func formatString(args []Value) (Value, error) {
+	s, rest := []rune(args[0].str), args[1:]
+	var sb strings.Builder
+	// verbError reports an error of the verb starting at s[start]
+	verbError := func(start int, format string, a ...interface{}) error {
+		return &formatVerbError{err: withCode(CodeOutsideDomain, fmt.Errorf(format, a...)), at: len(string(s[:start]))}
+	}
+	next := func(start int, verb string) (Value, error) {
+		if len(rest) == 0 {
+			return Value{}, verbError(start, "format: no argument for %s", verb)
+		}
+		arg := rest[0]
+		rest = rest[1:]
+		return arg, nil
+	}
+	number := func(verb string, arg Value) (float64, error) {
+		if !arg.numeric() {
+			return 0, withCode(CodeType, fmt.Errorf("format: %s needs a number, got argument %d, a %s", verb, len(args)-len(rest), arg.kind))
+		}
+		return arg.num, nil
+	}
+	// digits reads a width or precision of at most two digits at s[i:],
+	// giving -1 when there is none
+	digits := func(i *int) int {
+		n := -1
+		for j := 0; j < 2 && *i < len(s) && isDigit(s[*i]); j++ {
+			n = max(n, 0)*10 + int(s[*i]-'0')
+			*i++
+		}
+		return n
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			sb.WriteRune(s[i])
+			continue
+		}
+		start := i
+		i++
+		width, precision := digits(&i), -1
+		if i < len(s) && s[i] == '.' {
+			i++
+			if precision = digits(&i); precision < 0 && i < len(s) {
+				return Value{}, verbError(start, "format: unsupported verb %q", string(s[start:i+1]))
+			}
+		}
+		if i >= len(s) {
+			return Value{}, verbError(start, "format: incomplete verb at the end of %q", args[0].str)
+		}
+		verb := string(s[start : i+1])
+		var text string
+		switch s[i] {
+		case '%':
+			if verb != "%%" {
+				return Value{}, verbError(start, "format: unsupported verb %q", verb)
+			}
+			sb.WriteByte('%')
+			continue
+		case 'd':
+			arg, err := next(start, verb)
+			if err != nil {
+				return Value{}, err
+			}
+			x, err := number(verb, arg)
+			if err != nil {
+				return Value{}, err
+			}
+			if x != math.Trunc(x) || math.IsInf(x, 0) {
+				return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("format: %s needs a whole number, got %s", verb, formatNumber(x)))
+			}
+			text = strconv.FormatFloat(math.Abs(x), 'f', 0, 64)
+			if pad := precision - len(text); pad > 0 {
+				text = strings.Repeat("0", pad) + text
+			}
+			if x < 0 {
+				text = "-" + text
+			}
+		case 'f':
+			arg, err := next(start, verb)
+			if err != nil {
+				return Value{}, err
+			}
+			x, err := number(verb, arg)
+			if err != nil {
+				return Value{}, err
+			}
+			if precision < 0 {
+				precision = 6
+			}
+			text = strconv.FormatFloat(x, 'f', precision, 64)
+		case 's':
+			arg, err := next(start, verb)
+			if err != nil {
+				return Value{}, err
+			}
+			str, _ := toStr([]Value{arg})
+			text = str.str
+			if r := []rune(text); precision >= 0 && precision < len(r) {
+				text = string(r[:precision])
+			}
+		default:
+			return Value{}, verbError(start, "format: unsupported verb %q", verb)
+		}
+		if pad := width - utf8.RuneCountInString(text); pad > 0 {
+			sb.WriteString(strings.Repeat(" ", pad))
+		}
+		sb.WriteString(text)
+	}
+	if len(rest) > 0 {
+		return Value{}, withCode(CodeOutsideDomain, fmt.Errorf("format: %d argument(s) left over by %q", len(rest), args[0].str))
+	}
+	return StringValue(sb.String()), nil
+}
+
+// formatVerbError is an error of format at a verb of its format string, at
+// byte offset at of the string.
+type formatVerbError struct {
+	err error
+	at  int
+}
+
+// Error gives the error.
+func (e *formatVerbError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error.
+func (e *formatVerbError) Unwrap() error {
+	return e.err
+}
+
+// locateVerb attributes an error of format at a verb to the format string:
+// at the verb itself when the string is a literal without escape
+// sequences, whose offsets those of its value are, and at the string
+// otherwise.
+func locateVerb(call *FunctionCall, err *formatVerbError) error {
+	format := call.Args[0]
+	span := SpanOf(format)
+	if span == (Span{}) {
+		return &EvalError{Expr: format, Pos: -1, Err: err.err}
+	}
+	pos := span.Start
+	if lit, ok := format.(*StringLiteral); ok && span.End-span.Start == len(lit.Value)+2 {
+		pos += 1 + err.at
+	}
+	return &EvalError{Expr: format, Pos: pos, Err: err.err}
+}
+
+// lookupBuiltin returns the signature of a builtin of any kind.
+func lookupBuiltin(name string) (builtin, bool) {
+	if b, ok := stringBuiltins[name]; ok {
+		return b.sig, true
+	}
+	if a, ok := aggregates[name]; ok {
+		return a.sig, true
+	}
+	if f, ok := specialForms[name]; ok {
+		return f, true
+	}
+	if f, ok := iterationForms[name]; ok {
+		return f, true
+	}
+	if f, ok := lazyForms[name]; ok {
+		return f, true
+	}
+	b, ok := builtins[name]
+	return b, ok
+}
+
+// callStringBuiltin checks the argument kinds of a string builtin and applies it.
+func (ev *Evaluator) callStringBuiltin(call *FunctionCall, b stringBuiltin, args []Value) (Value, error) {
+	for i, arg := range args {
+		param := b.params[len(b.params)-1]
+		if i < len(b.params) {
+			param = b.params[i]
+		}
+		if param == NumberKind && !arg.numeric() || param != NumberKind && param != anyKind && arg.kind != param {
+			return Value{}, callTypeError(call, args, i)
+		}
+	}
+	if b.fn == nil && call.Name == "convert" {
+		return ev.convertUnits(args[0].num, args[1].str, args[2].str)
+	}
+	if b.fn == nil {
+		return ev.matchString(call.Name, args)
+	}
+	value, err := b.fn(args)
+	var verbErr *formatVerbError
+	if errors.As(err, &verbErr) {
+		return Value{}, locateVerb(call, verbErr)
+	}
+	return value, err
+}
+
+// end of file
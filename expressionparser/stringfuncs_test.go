@@ -0,0 +1,139 @@
+package expressionparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // the result as Value.String renders it
+	}{
+		{`upper(substr("hello world", 0, 5)) == "HELLO"`, "true"},
+		{`substr("hello world", 6, 5)`, `"world"`},
+		{`substr("hello", 5, 0)`, `""`},
+		{`substr("héllo", 1, 3)`, `"éll"`},
+		{`len("héllo")`, "5"}, // runes, not bytes
+		{`len("")`, "0"},
+		{`upper("héllo")`, `"HÉLLO"`},
+		{`lower("HeLLo")`, `"hello"`},
+		{`trim("  padded \t")`, `"padded"`},
+		{`trim("  a b ")`, `"a b"`},
+		{`contains("haystack", "st")`, "true"},
+		{`startswith("haystack", "hay")`, "true"},
+		{`startswith("prod-1", "prod")`, "true"},
+		{`endswith("haystack", "hay")`, "false"},
+		{`endswith("x.go", ".go")`, "true"},
+		{`endswith("x.go", ".rs")`, "false"},
+		{`replace("a-b-c", "-", "+")`, `"a+b+c"`},
+		{`field("a,b,c", ",", 0)`, `"a"`},
+		{`field("a,b,c", ",", 2)`, `"c"`},
+		{`field("a,b,,d", ",", 3)`, `"d"`},
+		{`field("a,b,,d", ",", 2)`, `""`},
+		{`format("%d items at %.2f", 3, 1.5)`, `"3 items at 1.50"`},
+		{`format("%5.2f|%s", 3.14159, "x")`, `" 3.14|x"`},
+		{`format("[%5d] [%.3d] [%4d]", 42, 7, -3)`, `"[   42] [007] [  -3]"`},
+		{`format("[%8.3f] [%f]", 2.5, 1)`, `"[   2.500] [1.000000]"`},
+		{`format("[%6s] [%.2s] [%4.1s]", "héllo", "abc", "xyz")`, `"[ héllo] [ab] [   x]"`},
+		{`format("100%%")`, `"100%"`},
+		{`"con" + "cat" == "concat"`, "true"},
+		{`"a" != "b"`, "true"},
+	}
+	for _, tt := range tests {
+		got, err := EvalValue(mustParse(t, tt.input), nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestStringBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{`substr("hello", -1, 2)`, CodeOutsideDomain, "substr: start -1 is outside a string of length 5"},
+		{`substr("hello", 6, 0)`, CodeOutsideDomain, "substr: start 6 is outside a string of length 5"},
+		{`substr("hello", 2, 4)`, CodeOutsideDomain, "substr: length 4 from start 2 runs past a string of length 5"},
+		{`substr("hello", 0, -1)`, CodeOutsideDomain, "substr: length -1 from start 0 runs past a string of length 5"},
+		{`substr("hello", 0.5, 1)`, CodeOutsideDomain, "substr: start 0.5 and length 1 must be whole numbers"},
+		{`substr("héllo", 1, 5)`, CodeOutsideDomain, "substr: length 5 from start 1 runs past a string of length 5"},
+		{`substr("hello", 0)`, CodeArgumentCount, ""},
+		{`substr(5, 0, 1)`, CodeType, "cannot apply substr to number, number and number: argument 1 is a number"},
+		{`upper(1)`, CodeType, "cannot apply upper to number: argument 1 is a number"},
+		{`replace("abc", "", "x")`, CodeOutsideDomain, "replace: the string to replace is empty"},
+		{`field("a,b", ",", 2)`, CodeOutsideDomain, "field: index 2 is outside the 2 fields of the string"},
+		{`field("a,b", "", 0)`, CodeOutsideDomain, "field: the separator is empty"},
+	}
+	for _, tt := range tests {
+		_, err := EvalValue(mustParse(t, tt.input), nil)
+		if ErrorCode(err) != tt.code {
+			t.Errorf("%s: error %v, want %s", tt.input, err, tt.code)
+			continue
+		}
+		if tt.msg != "" && !strings.HasPrefix(err.Error(), tt.msg+" in ") {
+			t.Errorf("%s: error %q, want %q", tt.input, err, tt.msg)
+		}
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{`format("%d", "x")`, CodeType, "format: %d needs a number, got argument 2, a string"},
+		{`format("%d", 1.5)`, CodeOutsideDomain, "format: %d needs a whole number, got 1.5"},
+		{`format("%s", 1, 2)`, CodeOutsideDomain, "format: 1 argument(s) left over"},
+		{`format("%q", 1)`, CodeOutsideDomain, `format: unsupported verb "%q"`},
+		{`format("%5.2x", 1)`, CodeOutsideDomain, `format: unsupported verb "%5.2x"`},
+		{`format("%100d", 1)`, CodeOutsideDomain, `format: unsupported verb "%100"`},
+		{`format("%-3d", 1)`, CodeOutsideDomain, `format: unsupported verb "%-"`},
+		{`format("%5")`, CodeOutsideDomain, "format: incomplete verb"},
+	}
+	for _, tt := range tests {
+		_, err := EvalValue(mustParse(t, tt.input), nil)
+		if ErrorCode(err) != tt.code {
+			t.Errorf("%s: error %v, want %s", tt.input, err, tt.code)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: error %q, want one containing %q", tt.input, err, tt.msg)
+		}
+	}
+}
+
+func TestFormatVerbErrorOffset(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{`format("%5.2f|%q", 3.14159, "x")`, 14},
+		{`format("é %q", 1)`, 11},
+		{`format("%d %d", 1)`, 11},
+		{`format("\n %q", 1)`, 7}, // escapes: the start of the string
+	}
+	for _, tt := range tests {
+		_, err := EvalValue(mustParse(t, tt.input), nil)
+		span, ok := errorSpan(err)
+		if !ok || span.Start != tt.pos {
+			t.Errorf("%s: error %v at %v, want offset %d", tt.input, err, span, tt.pos)
+		}
+	}
+
+	input := `format("%5.2f|%q", 3.14159, "x")`
+	_, err := EvalValue(mustParse(t, input), nil)
+	want := "format: unsupported verb \"%q\" in \"\\\"%5.2f|%q\\\"\" at offset 14\n" + input + "\n              ^"
+	if got := FormatError(input, err); got != want {
+		t.Errorf("FormatError:\n%s\nwant\n%s", got, want)
+	}
+}
+
+// end of file
@@ -0,0 +1,143 @@
+package expressionparser
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Substitute returns a copy of expr with every free variable named in
+// bindings replaced by a Clone of its bound expression. Substitution does not
+// enter a let body for the name that let rebinds, nor the first argument of
+// a special form such as deriv or the last of an iteration form such as
+// sum(i, 1, n, i^2) for the variable it binds, and a binder that
+// would capture a free variable of an inserted expression is renamed first,
+// so meaning is preserved. Replacements are not themselves substituted again;
+// cyclic definitions are the caller's concern (see SelfReferencing).
+func Substitute(expr Expr, bindings map[string]Expr) Expr {
+	if len(bindings) == 0 {
+		return expr
+	}
+
+	switch v := expr.(type) {
+	case *Variable:
+		if replacement, ok := bindings[v.Name]; ok {
+			return Clone(replacement)
+		}
+		return expr
+	case *Let:
+		value := Substitute(v.Value, bindings)
+
+		inner := make(map[string]Expr, len(bindings))
+		for name, replacement := range bindings {
+			if name != v.Name {
+				inner[name] = replacement
+			}
+		}
+
+		name, body := v.Name, v.Body
+		if capturesFreeVariable(name, body, inner) {
+			fresh := freshName(name, body, inner)
+			body = Substitute(body, map[string]Expr{name: Var(fresh)})
+			name = fresh
+		}
+		return &Let{Name: name, Value: value, Body: Substitute(body, inner)}
+	case *FunctionCall:
+		name, binder, index, ok := formBinding(v)
+		if !ok {
+			break
+		}
+		inner := make(map[string]Expr, len(bindings))
+		for n, replacement := range bindings {
+			if n != name {
+				inner[n] = replacement
+			}
+		}
+		body := v.Args[index]
+		if capturesFreeVariable(name, body, inner) {
+			fresh := freshName(name, body, inner)
+			body = Substitute(body, map[string]Expr{name: Var(fresh)})
+			name = fresh
+		}
+		args := make([]Expr, len(v.Args))
+		for i, arg := range v.Args {
+			switch i {
+			case index:
+				args[i] = Substitute(body, inner)
+			case binder:
+				if _, quoted := arg.(*StringLiteral); quoted {
+					args[i] = Str(name)
+				} else {
+					args[i] = Var(name)
+				}
+			default:
+				args[i] = Substitute(arg, bindings)
+			}
+		}
+		return &FunctionCall{Name: v.Name, Args: args}
+	}
+
+	children := Children(expr)
+	if len(children) == 0 {
+		return expr
+	}
+	substituted := make([]Expr, len(children))
+	for i, child := range children {
+		substituted[i] = Substitute(child, bindings)
+	}
+	return withChildren(expr, substituted)
+}
+
+// capturesFreeVariable reports whether a replacement inserted into body would
+// reference name, which body's enclosing let binds.
+func capturesFreeVariable(name string, body Expr, bindings map[string]Expr) bool {
+	for _, used := range Variables(body) {
+		replacement, ok := bindings[used]
+		if !ok {
+			continue
+		}
+		for _, free := range Variables(replacement) {
+			if free == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// freshName derives a variant of name that is free in body and in every replacement.
+func freshName(name string, body Expr, bindings map[string]Expr) string {
+	taken := map[string]bool{}
+	for _, v := range Variables(body) {
+		taken[v] = true
+	}
+	for n, replacement := range bindings {
+		taken[n] = true
+		for _, v := range Variables(replacement) {
+			taken[v] = true
+		}
+	}
+	for i := 1; ; i++ {
+		candidate := name + "_" + strconv.Itoa(i)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// SelfReferencing returns, sorted, the names whose bound expression refers
+// directly to the name itself.
+func SelfReferencing(bindings map[string]Expr) []string {
+	var names []string
+	for name, expr := range bindings {
+		for _, v := range Variables(expr) {
+			if v == name {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// end of file
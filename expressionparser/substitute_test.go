@@ -0,0 +1,64 @@
+package expressionparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// mustParse parses input or fails t.
+func mustParse(t *testing.T, input string) Expr {
+	t.Helper()
+	expr, err := ParseString(input)
+	if err != nil {
+		t.Fatalf("ParseString(%q): %v", input, err)
+	}
+	return expr
+}
+
+func TestSubstitute(t *testing.T) {
+	tests := []struct {
+		input    string
+		bindings map[string]string
+		want     string
+	}{
+		{"x + y", map[string]string{"x": "a * 2"}, "a * 2 + y"},
+		// Nested: the replacement goes inside calls, lists and conditionals
+		{"f(x, [x]) ? x : -x", map[string]string{"x": "b + 1"}, "f(b + 1, [b + 1]) ? b + 1 : -(b + 1)"},
+		// A binding that itself contains variables is not substituted again
+		{"x + y", map[string]string{"x": "y * 2", "y": "3"}, "y * 2 + 3"},
+		// Shadowing: the let body's x is not the free x
+		{"x + let x = 2 in x * y", map[string]string{"x": "10", "y": "z"}, "10 + (let x = 2 in x * z)"},
+		{"let a = x in a + x", map[string]string{"x": "1"}, "let a = 1 in a + 1"},
+		// The binder is renamed rather than capture a free variable inserted
+		{"let a = 1 in a + x", map[string]string{"x": "a"}, "let a_1 = 1 in a_1 + a"},
+		{"sum(i, 1, n, i * x)", map[string]string{"x": "i", "n": "5"}, "sum(i_1, 1, 5, i_1 * i)"},
+	}
+	for _, tt := range tests {
+		bindings := map[string]Expr{}
+		for name, input := range tt.bindings {
+			bindings[name] = mustParse(t, input)
+		}
+		expr := mustParse(t, tt.input)
+		got := Substitute(expr, bindings)
+		if want := mustParse(t, tt.want); !Equal(got, want) {
+			t.Errorf("Substitute(%q, %v) = %q, want %q", tt.input, tt.bindings, Format(got), tt.want)
+		}
+		if !Equal(expr, mustParse(t, tt.input)) {
+			t.Errorf("Substitute(%q) modified its input", tt.input)
+		}
+	}
+}
+
+func TestSelfReferencing(t *testing.T) {
+	bindings := map[string]Expr{
+		"a": mustParse(t, "a + 1"),
+		"b": mustParse(t, "c * 2"),
+		"c": mustParse(t, "let c = 1 in c"),
+		"d": mustParse(t, "f(d)"),
+	}
+	if got, want := SelfReferencing(bindings), []string{"a", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SelfReferencing = %q, want %q", got, want)
+	}
+}
+
+// end of file
@@ -0,0 +1,109 @@
+package expressionparser
+
+import (
+	"fmt"
+	"reflect"
+	"text/template"
+)
+
+// TemplateFuncs returns functions for text/template (and html/template)
+// that evaluate formulas inline, with EvaluateWithVars and opts:
+//
+//	{{ eval "unit_price * qty" . }}
+//	{{ evalf "%.2f" "unit_price * qty" . }}
+//
+// eval gives the result and evalf the result formatted as fmt.Sprintf
+// formats it. The variables are taken from the last argument, typically
+// the dot, as VarsOf takes them. An error stops the execution of the
+// template, which reports it.
+func TemplateFuncs(opts ...Option) template.FuncMap {
+	eval := func(input string, data interface{}) (float64, error) {
+		vars, err := VarsOf(data)
+		if err != nil {
+			return 0, err
+		}
+		return EvaluateWithVars(input, vars, opts...)
+	}
+	return template.FuncMap{
+		"eval": eval,
+		"evalf": func(format, input string, data interface{}) (string, error) {
+			v, err := eval(input, data)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf(format, v), nil
+		},
+	}
+}
+
+// VarsOf returns the numbers of data as variables: the values of a map
+// with string keys that are of a numeric or boolean type, or the fields of
+// a struct bound to numbers or bools by EnvFromStruct, true being 1 and
+// false 0. Other values and fields are left out. data may be a pointer to
+// a map or struct, and nil, which has no variables; anything else is an
+// error.
+func VarsOf(data interface{}) (map[string]float64, error) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		vars := make(map[string]float64, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			if x, ok := numberOf(iter.Value()); ok {
+				vars[iter.Key().String()] = x
+			}
+		}
+		return vars, nil
+	case reflect.Struct:
+		env := NewEnvironment(nil)
+		if err := bindFields(env, "", v, false); err != nil {
+			return nil, err
+		}
+		vars := make(map[string]float64, len(env.vars))
+		for name, value := range env.vars {
+			if value.numeric() {
+				vars[name] = value.num
+			}
+		}
+		return vars, nil
+	}
+	return nil, withCode(CodeInvalidArgument, fmt.Errorf("cannot take variables from a value of type %s", v.Type()))
+}
+
+// numberOf returns the number a value of a numeric or boolean type, or
+// an interface or pointer holding one, stands for.
+func numberOf(v reflect.Value) (float64, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return 0, false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// end of file
@@ -0,0 +1,66 @@
+package expressionparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// execute renders text with data and the functions of TemplateFuncs.
+func execute(t *testing.T, text string, data interface{}) (string, error) {
+	t.Helper()
+	tmpl, err := template.New("doc").Funcs(TemplateFuncs()).Parse(text)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, data)
+	return b.String(), err
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	const text = `{{ .item }}: {{ eval "unit_price * qty" . }} = {{ evalf "%.2f" "unit_price * qty * (1 + vat)" . }}`
+	data := map[string]interface{}{"item": "widget", "unit_price": 2.5, "qty": 3, "vat": 0.2, "gift": true}
+	if got, err := execute(t, text, data); err != nil || got != "widget: 7.5 = 9.00" {
+		t.Errorf("with a map: %q, %v", got, err)
+	}
+
+	type order struct {
+		Item      string
+		UnitPrice float64 `expr:"unit_price"`
+		Qty       *int    `expr:"qty"`
+		VAT       float32 `expr:"vat"`
+		Customer  struct{ Discount float64 }
+	}
+	qty := 4
+	o := order{Item: "gadget", UnitPrice: 10, Qty: &qty, VAT: 0.5}
+	o.Customer.Discount = 0.25
+	const structText = `{{ .Item }}: {{ evalf "%.1f" "unit_price * qty * (1 - Customer.Discount)" . }}`
+	if got, err := execute(t, structText, &o); err != nil || got != "gadget: 30.0" {
+		t.Errorf("with a struct: %q, %v", got, err)
+	}
+	if got, err := execute(t, `{{ eval "gift ? 1 : 2" . }}`, data); err != nil || got != "1" {
+		t.Errorf("with a bool: %q, %v", got, err)
+	}
+}
+
+func TestTemplateFuncsErrors(t *testing.T) {
+	data := map[string]float64{"unit_price": 2}
+	_, err := execute(t, `total {{ eval "unit_price * qty" . }}`, data)
+	if !errors.Is(err, ErrUndefinedVariable) || !strings.Contains(err.Error(), `error calling eval: undefined variable qty`) {
+		t.Errorf("missing variable: error %v", err)
+	}
+	if _, err := execute(t, `{{ evalf "%v" "2 +" . }}`, data); err == nil || !strings.Contains(err.Error(), "error calling evalf") {
+		t.Errorf("bad formula: error %v", err)
+	}
+	if _, err := execute(t, `{{ eval "1" "dot" }}`, nil); ErrorCode(err) != CodeInvalidArgument {
+		t.Errorf("string data: error %v, want %s", err, CodeInvalidArgument)
+	}
+	// No data, no variables
+	if got, err := execute(t, `{{ eval "6 * 7" . }}`, nil); err != nil || got != "42" {
+		t.Errorf("nil data: %q, %v", got, err)
+	}
+}
+
+// end of file
@@ -0,0 +1,114 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenNames are the names of the token types, as written in Go.
+var tokenNames = [...]string{
+	EOF:       "EOF",
+	NUMBER:    "NUMBER",
+	PLUS:      "PLUS",
+	MINUS:     "MINUS",
+	MULT:      "MULT",
+	DIV:       "DIV",
+	POW:       "POW",
+	LPAREN:    "LPAREN",
+	RPAREN:    "RPAREN",
+	IDENT:     "IDENT",
+	COMMA:     "COMMA",
+	ASSIGN:    "ASSIGN",
+	LT:        "LT",
+	LE:        "LE",
+	GT:        "GT",
+	GE:        "GE",
+	EQ:        "EQ",
+	NE:        "NE",
+	AND:       "AND",
+	OR:        "OR",
+	NOT:       "NOT",
+	QUESTION:  "QUESTION",
+	COLON:     "COLON",
+	MOD:       "MOD",
+	STRING:    "STRING",
+	LBRACKET:  "LBRACKET",
+	RBRACKET:  "RBRACKET",
+	DOTDOT:    "DOTDOT",
+	SEMICOLON: "SEMICOLON",
+	BITAND:    "BITAND",
+	BITOR:     "BITOR",
+	BITXOR:    "BITXOR",
+	SHL:       "SHL",
+	SHR:       "SHR",
+	MATCH:     "MATCH",
+	INVALID:   "INVALID",
+}
+
+// String returns the name of the token type, such as NUMBER.
+func (t TokenType) String() string {
+	if t >= 0 && int(t) < len(tokenNames) {
+		return tokenNames[t]
+	}
+	return fmt.Sprintf("TokenType(%d)", int(t))
+}
+
+// GoString returns the token type as Go source, such as
+// expressionparser.NUMBER.
+func (t TokenType) GoString() string {
+	if t >= 0 && int(t) < len(tokenNames) {
+		return "expressionparser." + tokenNames[t]
+	}
+	return fmt.Sprintf("expressionparser.TokenType(%d)", int(t))
+}
+
+// String returns the type, text and offset of the token, as in
+// NUMBER("5") @12, the text being left out when empty, as at EOF.
+func (t Token) String() string {
+	return fmt.Sprintf("%s @%d", t.describe(), t.Pos)
+}
+
+// GoString returns the token as a Go composite literal.
+func (t Token) GoString() string {
+	return fmt.Sprintf("expressionparser.Token{Type: %#v, Value: %q, Pos: %d}", t.Type, t.Value, t.Pos)
+}
+
+// found describes the token as a parse error found it, by kind and text.
+func (t Token) found() string {
+	switch t.Type {
+	case EOF:
+		return "end of input"
+	case NUMBER:
+		return "number " + t.Value
+	case IDENT:
+		return "name " + t.Value
+	case STRING:
+		return "string " + t.Value
+	}
+	return "'" + t.Value + "'"
+}
+
+// describe returns the type and text of the token.
+func (t Token) describe() string {
+	if t.Value == "" {
+		return t.Type.String()
+	}
+	return fmt.Sprintf("%s(%q)", t.Type, t.Value)
+}
+
+// DumpTokens returns the tokens of input, as the lexer reads them, one per
+// line as Token.String prints them, up to EOF or the first INVALID token.
+func DumpTokens(input string) string {
+	var b strings.Builder
+	l := NewLexer(input)
+	for {
+		tok := l.NextToken()
+		b.WriteString(tok.String())
+		b.WriteByte('\n')
+		if tok.Type == EOF || tok.Type == INVALID {
+			return b.String()
+		}
+	}
+}
+
+// end of file
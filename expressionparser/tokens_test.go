@@ -0,0 +1,68 @@
+package expressionparser
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTokenString(t *testing.T) {
+	tests := []struct {
+		tok      Token
+		str, gos string
+	}{
+		{Token{Type: NUMBER, Value: "5", Pos: 12}, `NUMBER("5") @12`, `expressionparser.Token{Type: expressionparser.NUMBER, Value: "5", Pos: 12}`},
+		{Token{Type: EOF, Pos: 3}, `EOF @3`, `expressionparser.Token{Type: expressionparser.EOF, Value: "", Pos: 3}`},
+		{Token{Type: STRING, Value: `"a\"b"`, Pos: 0}, `STRING("\"a\\\"b\"") @0`, `expressionparser.Token{Type: expressionparser.STRING, Value: "\"a\\\"b\"", Pos: 0}`},
+		{Token{Type: TokenType(99), Value: "?", Pos: 1}, `TokenType(99)("?") @1`, `expressionparser.Token{Type: expressionparser.TokenType(99), Value: "?", Pos: 1}`},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(tt.tok); got != tt.str {
+			t.Errorf("String() = %s, want %s", got, tt.str)
+		}
+		if got := fmt.Sprintf("%#v", tt.tok); got != tt.gos {
+			t.Errorf("GoString() = %s, want %s", got, tt.gos)
+		}
+	}
+	if got := fmt.Sprintf("%v %#v", MATCH, INVALID); got != "MATCH expressionparser.INVALID" {
+		t.Errorf("TokenType formats as %q", got)
+	}
+	// Every type has a name
+	for typ := EOF; typ <= INVALID; typ++ {
+		if name := typ.String(); name == "" || name[0] == 'T' {
+			t.Errorf("TokenType %d has no name", int(typ))
+		}
+	}
+}
+
+func TestDumpTokens(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"", "EOF @0\n"},
+		{"2*(x+1)", `NUMBER("2") @0
+MULT("*") @1
+LPAREN("(") @2
+IDENT("x") @3
+PLUS("+") @4
+NUMBER("1") @5
+RPAREN(")") @6
+EOF @7
+`},
+		// Up to the first INVALID token
+		{`x1 >= 2.5e3 && "a" ; # 1`, `IDENT("x1") @0
+GE(">=") @3
+NUMBER("2.5e3") @6
+AND("&&") @12
+STRING("\"a\"") @15
+SEMICOLON(";") @19
+INVALID("Invalid character: #") @21
+`},
+	}
+	for _, tt := range tests {
+		if got := DumpTokens(tt.input); got != tt.want {
+			t.Errorf("DumpTokens(%q) =\n%s\nwant\n%s", tt.input, got, tt.want)
+		}
+	}
+}
+
+// end of file
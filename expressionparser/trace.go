@@ -0,0 +1,120 @@
+package expressionparser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Step is one reduction in the trace of EvalTrace: a subexpression and the
+// value it evaluated to.
+type Step struct {
+	Expr     string  // the subexpression, as printed by Format
+	Span     Span    // its position; zero for trees built without spans
+	Operands []Value // values of the children that were evaluated, in order
+	Result   Value
+	Skipped  bool // not evaluated, by short-circuiting or as the branch of ?: not taken
+
+	node Expr
+}
+
+// String renders the step as a reduction such as "5 * 5 => 25", showing
+// the values of the operands, or as "1 / x skipped". A call binding a
+// variable, as sum(i, 1, n, i) does, is shown as written, since its
+// operands include the value of its body at every value of the variable.
+func (s Step) String() string {
+	if s.Skipped {
+		return s.Expr + " skipped"
+	}
+	var reduced string
+	switch v := s.node.(type) {
+	case *BinaryOp:
+		right := Format(v.Right)
+		if len(s.Operands) == 2 {
+			right = s.Operands[1].String()
+		}
+		reduced = s.Operands[0].String() + " " + operatorSymbol(v.Op) + " " + right
+	case *UnaryOp:
+		operand := s.Operands[0].String()
+		if strings.HasPrefix(operand, "-") {
+			operand = "(" + operand + ")"
+		}
+		reduced = operatorSymbol(v.Op) + operand
+	case *FunctionCall:
+		if _, _, _, binds := formBinding(v); binds {
+			reduced = s.Expr
+			break
+		}
+		args := make([]string, len(s.Operands))
+		for i, arg := range s.Operands {
+			args[i] = arg.String()
+		}
+		reduced = v.Name + "(" + strings.Join(args, ", ") + ")"
+	default:
+		reduced = s.Expr
+	}
+	return reduced + " => " + s.Result.String()
+}
+
+// tracer collects the steps of an evaluation. values holds the results of
+// the nodes whose parent is still being evaluated, so that a node finds the
+// values of its children at the top.
+type tracer struct {
+	steps  []Step
+	values []Value
+}
+
+// reduce records that expr evaluated to value, the values of its children
+// having been pushed from mark on.
+func (t *tracer) reduce(expr Expr, value Value, mark int) {
+	switch expr.(type) {
+	case *Number, *StringLiteral:
+	default:
+		operands := append([]Value(nil), t.values[mark:]...)
+		t.steps = append(t.steps, Step{Expr: Format(expr), Span: SpanOf(expr), Operands: operands, Result: value, node: expr})
+	}
+	t.values = append(t.values[:mark], value)
+}
+
+// skip records that expr was not evaluated.
+func (t *tracer) skip(expr Expr) {
+	t.steps = append(t.steps, Step{Expr: Format(expr), Span: SpanOf(expr), Skipped: true, node: expr})
+}
+
+// mark returns the position from which the values of the children of the
+// node about to be evaluated will be pushed.
+func (t *tracer) mark() int {
+	return len(t.values)
+}
+
+// EvalTrace evaluates an expression like EvalWithVars, returning with the
+// result the steps of its evaluation.
+func EvalTrace(expr Expr, vars map[string]float64) (float64, []Step, error) {
+	ev := *defaultEvaluator
+	ev.vars = vars
+	return ev.EvalTrace(expr)
+}
+
+// EvalTrace evaluates expr like Eval and also returns how the result was
+// computed: a Step for every operator, call, variable, let and conditional,
+// in the order their evaluation finished, so "(2+3)*5" gives "2 + 3 => 5"
+// and then "5 * 5 => 25". Literals are not steps of their own. The parts
+// not evaluated because of short-circuiting or a conditional appear as
+// skipped steps where they would have been evaluated. When evaluation
+// fails, the steps up to the failure are returned with the error. Tracing
+// does not change the result.
+func (ev *Evaluator) EvalTrace(expr Expr) (float64, []Step, error) {
+	run := *ev
+	run.trace = &tracer{}
+	value, err := run.evalValueContext(context.Background(), expr, nil)
+	steps := run.trace.steps
+	if err != nil {
+		return 0, steps, err
+	}
+	if !value.numeric() {
+		return 0, steps, withCode(CodeType, fmt.Errorf("result is a %s, not a number", value.kind))
+	}
+	return value.num, steps, nil
+}
+
+// end of file
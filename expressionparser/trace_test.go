@@ -0,0 +1,52 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestEvalTraceGolden compares the steps EvalTrace gives for each
+// testdata/trace/*.expr file, with their spans, and then the result or
+// error, with the .golden file of the same name.
+func TestEvalTraceGolden(t *testing.T) {
+	runGolden(t, "trace", func(input string) string {
+		expr := mustParse(t, input)
+		result, steps, err := EvalTrace(expr, nil)
+		var b strings.Builder
+		for _, step := range steps {
+			fmt.Fprintf(&b, "%d:%d %v\n", step.Span.Start, step.Span.End, step)
+		}
+		if err != nil {
+			fmt.Fprintf(&b, "error: %v\n", err)
+		} else {
+			fmt.Fprintf(&b, "= %s\n", formatNumber(result))
+		}
+
+		// Tracing does not change the result
+		want, wantErr := Eval(expr)
+		if !sameResult(result, err, want, wantErr) {
+			t.Errorf("EvalTrace(%s) = %v, %v; Eval gives %v, %v", input, result, err, want, wantErr)
+		}
+		return b.String()
+	})
+}
+
+func TestEvalTraceSteps(t *testing.T) {
+	_, steps, err := EvalTrace(mustParse(t, "(2+3)*5"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, step := range steps {
+		got = append(got, step.String())
+	}
+	if want := []string{"2 + 3 => 5", "5 * 5 => 25"}; strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("steps %q, want %q", got, want)
+	}
+	if steps[0].Expr != "2 + 3" || steps[0].Span != (Span{Start: 0, End: 5}) || len(steps[0].Operands) != 2 {
+		t.Errorf("first step %+v", steps[0])
+	}
+}
+
+// end of file
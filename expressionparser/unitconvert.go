@@ -0,0 +1,78 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// convertUnits converts x from one unit to another of the same dimension,
+// as convert(100, "km/h", "m/s") does: both are written as RegisterUnit
+// takes them and may be compound, and a unit with an offset, such as C or
+// F, standing alone converts with it, so that convert(72, "F", "C") is
+// 22.22... The conversion is worked out in rationals and rounded once, so
+// that C and F convert into each other directly rather than by way of a
+// rounded kelvin value: 100 C is 212 F exactly. Errors name both units.
+func (ev *Evaluator) convertUnits(x float64, from, to string) (Value, error) {
+	fail := func(err error) (Value, error) {
+		return Value{}, fmt.Errorf("convert %q to %q: %w", from, to, err)
+	}
+	fromFactor, fromOffset, fromDims, err := ev.unitScale(from)
+	if err != nil {
+		return fail(err)
+	}
+	toFactor, toOffset, toDims, err := ev.unitScale(to)
+	if err != nil {
+		return fail(err)
+	}
+	if !equalDims(fromDims, toDims) {
+		return fail(fmt.Errorf("%w %s and %s", ErrIncompatibleUnits, formatUnit(baseUnits(fromDims)), formatUnit(baseUnits(toDims))))
+	}
+	if math.IsInf(x, 0) || math.IsNaN(x) {
+		return NumberValue((x*fromFactor + (fromOffset - toOffset)) / toFactor), nil
+	}
+	out := new(big.Rat).Mul(exactScale(x), exactScale(fromFactor))
+	out.Add(out, exactScale(fromOffset))
+	out.Sub(out, exactScale(toOffset))
+	result, _ := out.Quo(out, exactScale(toFactor)).Float64()
+	return NumberValue(result), nil
+}
+
+// roundedScales are the rationals that the factor and offset of F in
+// defaultUnits stand for.
+var roundedScales = map[float64]*big.Rat{
+	5.0 / 9:        big.NewRat(5, 9),
+	459.67 * 5 / 9: big.NewRat(45967, 180),
+}
+
+// exactScale gives the rational a finite value or a unit's factor or offset
+// stands for: that of roundedScales, or otherwise the shortest decimal that
+// rounds to it, so that 273.15 is 27315/100.
+func exactScale(v float64) *big.Rat {
+	if r, ok := roundedScales[v]; ok {
+		return r
+	}
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(v, 'g', -1, 64))
+	return r
+}
+
+// unitScale returns the factor and offset taking a value in the unit s to
+// its base units, and the exponents of those; only a single unit has an
+// offset.
+func (ev *Evaluator) unitScale(s string) (factor, offset float64, dims map[string]int, err error) {
+	units, err := parseUnit(s)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if factor, dims, err = ev.unitDims(units); err != nil {
+		return 0, 0, nil, err
+	}
+	if len(units) == 1 && units[0].exp == 1 {
+		def, _ := ev.lookupUnit(units[0].name)
+		offset = def.offset
+	}
+	return factor, offset, dims, nil
+}
+
+// end of file
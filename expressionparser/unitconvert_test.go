@@ -0,0 +1,118 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{`convert(2, "h", "min")`, 120},
+		{`convert(1, "mi", "km")`, 1.609344},
+		{`convert(1, "lb", "g")`, 453.59237},
+		{`convert(1, "GiB", "MiB")`, 1024},
+		{`convert(8, "bit", "B")`, 1},
+		{`convert(x, "m", "cm")`, 300},
+		// Compound units
+		{`convert(100, "km/h", "m/s")`, 100.0 / 3.6},
+		{`convert(60, "mph", "km/h")`, 96.56064},
+		{`convert(1, "kg*m/s^2", "g*cm/s^2")`, 1e5},
+		// Temperatures are affine alone, and differences in compound units
+		{`convert(72, "F", "C")`, 200.0 / 9},
+		{`convert(-40, "C", "F")`, -40},
+		{`convert(0, "C", "K")`, 273.15},
+		{`convert(212, "F", "K")`, 373.15},
+		{`convert(0, "K", "F")`, -459.67},
+		{`convert(9, "F/s", "K/s")`, 5},
+	}
+	ev := NewEvaluator()
+	ev.SetVar("x", 3)
+	for _, tt := range tests {
+		got, err := ev.Eval(mustParse(t, tt.input))
+		if err != nil || math.Abs(got-tt.want) > 1e-12*math.Max(1, math.Abs(tt.want)) {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestConvertTemperatures(t *testing.T) {
+	// C and F are exact at the points where they are usually checked
+	tests := []struct{ c, f float64 }{{0, 32}, {100, 212}, {-40, -40}}
+	for _, tt := range tests {
+		toF := fmt.Sprintf(`convert(%v, "C", "F")`, tt.c)
+		if got, err := Evaluate(toF); err != nil || got != tt.f {
+			t.Errorf("%s = %v, %v; want %v", toF, got, err, tt.f)
+		}
+		toC := fmt.Sprintf(`convert(%v, "F", "C")`, tt.f)
+		if got, err := Evaluate(toC); err != nil || got != tt.c {
+			t.Errorf("%s = %v, %v; want %v", toC, got, err, tt.c)
+		}
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{`convert(1, "m", "s")`, CodeIncompatibleUnits, `convert "m" to "s": incompatible units m and s`},
+		{`convert(1, "km/h", "kg")`, CodeIncompatibleUnits, `convert "km/h" to "kg": incompatible units m/s and kg`},
+		{`convert(1, "parsec", "m")`, CodeUnknownUnit, `convert "parsec" to "m": unknown unit parsec`},
+		{`convert(1, "m", "lightyear")`, CodeUnknownUnit, `convert "m" to "lightyear": unknown unit lightyear`},
+		{`convert(1, "m", 2)`, CodeType, "cannot apply convert to number, string and number: argument 3 is a number"},
+	}
+	for _, tt := range tests {
+		_, err := Evaluate(tt.input)
+		if ErrorCode(err) != tt.code {
+			t.Errorf("%s: error %v, want %s", tt.input, err, tt.code)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.msg+" in ") {
+			t.Errorf("%s: error %q, want %q", tt.input, err, tt.msg)
+		}
+	}
+	if _, err := Evaluate(`convert(1, "m", "s")`); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Errorf("convert m to s: error %v, want ErrIncompatibleUnits", err)
+	}
+}
+
+func TestConvertRegisteredUnits(t *testing.T) {
+	ev := NewEvaluator()
+	if err := ev.RegisterUnit("furlong", 201.168, "m"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ev.RegisterUnit("fortnight", 14, "d"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ev.RegisterAffineUnit("R", 5.0/9, 0, "K"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{`convert(1, "furlong", "m")`, 201.168},
+		{`convert(1, "furlong/fortnight", "m/s")`, 201.168 / (14 * 86400)},
+		{`convert(491.67, "R", "F")`, 32},
+	}
+	for _, tt := range tests {
+		got, err := ev.Eval(mustParse(t, tt.input))
+		if err != nil || math.Abs(got-tt.want) > 1e-12*math.Max(1, math.Abs(tt.want)) {
+			t.Errorf("%s = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+	// Units registered on one evaluator are unknown to others
+	if _, err := Evaluate(`convert(1, "furlong", "m")`); ErrorCode(err) != CodeUnknownUnit {
+		t.Errorf("furlong with the default evaluator: error %v, want %s", err, CodeUnknownUnit)
+	}
+}
+
+// end of file
@@ -0,0 +1,657 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrIncompatibleUnits is the cause of the error EvalUnits gives for adding,
+// subtracting or comparing quantities of different dimensions, as in 3 m + 2 s.
+var ErrIncompatibleUnits = errors.New("incompatible units")
+
+// Quantity is the result of EvalUnits: a value in a unit such as "km/h".
+type Quantity struct {
+	Value float64
+	Unit  string // empty for a plain number
+}
+
+// String renders the quantity as it would be written in an expression, the
+// value followed by the unit.
+func (q Quantity) String() string {
+	if q.Unit == "" {
+		return formatNumber(q.Value)
+	}
+	return formatNumber(q.Value) + " " + q.Unit
+}
+
+// unitDef is a unit of measure: factor times a product of powers of base
+// units. A unit with an offset, as the degree Celsius has from the kelvin,
+// measures x as x*factor + offset in its base unit when it stands alone,
+// and otherwise scales as a difference.
+type unitDef struct {
+	factor float64
+	dims   map[string]int // exponent by base unit
+	offset float64
+}
+
+// baseUnit makes name the unit of a dimension of its own.
+func baseUnit(name string) unitDef {
+	return unitDef{factor: 1, dims: map[string]int{name: 1}}
+}
+
+// Units available in every evaluator, in terms of the base units m, s, kg,
+// K and B
+var defaultUnits = map[string]unitDef{
+	"m":   baseUnit("m"),
+	"km":  {1000, map[string]int{"m": 1}, 0},
+	"cm":  {0.01, map[string]int{"m": 1}, 0},
+	"mm":  {0.001, map[string]int{"m": 1}, 0},
+	"mi":  {1609.344, map[string]int{"m": 1}, 0},
+	"ft":  {0.3048, map[string]int{"m": 1}, 0},
+	"yd":  {0.9144, map[string]int{"m": 1}, 0},
+	"s":   baseUnit("s"),
+	"ms":  {0.001, map[string]int{"s": 1}, 0},
+	"min": {60, map[string]int{"s": 1}, 0},
+	"h":   {3600, map[string]int{"s": 1}, 0},
+	"d":   {86400, map[string]int{"s": 1}, 0},
+	"mph": {1609.344 / 3600, map[string]int{"m": 1, "s": -1}, 0},
+	"kn":  {1852.0 / 3600, map[string]int{"m": 1, "s": -1}, 0},
+	"kg":  baseUnit("kg"),
+	"g":   {0.001, map[string]int{"kg": 1}, 0},
+	"t":   {1000, map[string]int{"kg": 1}, 0},
+	"lb":  {0.45359237, map[string]int{"kg": 1}, 0},
+	"oz":  {0.45359237 / 16, map[string]int{"kg": 1}, 0},
+	"K":   baseUnit("K"),
+	"C":   {1, map[string]int{"K": 1}, 273.15},
+	"F":   {5.0 / 9, map[string]int{"K": 1}, 459.67 * 5 / 9},
+	"B":   baseUnit("B"),
+	"bit": {0.125, map[string]int{"B": 1}, 0},
+	"kB":  {1e3, map[string]int{"B": 1}, 0},
+	"MB":  {1e6, map[string]int{"B": 1}, 0},
+	"GB":  {1e9, map[string]int{"B": 1}, 0},
+	"TB":  {1e12, map[string]int{"B": 1}, 0},
+	"KiB": {1 << 10, map[string]int{"B": 1}, 0},
+	"MiB": {1 << 20, map[string]int{"B": 1}, 0},
+	"GiB": {1 << 30, map[string]int{"B": 1}, 0},
+	"TiB": {1 << 40, map[string]int{"B": 1}, 0},
+}
+
+// RegisterUnit makes name a unit for EvalUnits and convert worth factor
+// times of, a unit written in the units already known as in "m", "km/h" or
+// "kg*m/s^2". An empty of makes name the base unit of a new dimension, and
+// factor must then be 1. Known from the start are the units m, km, cm, mm,
+// mi, ft and yd of length, s, ms, min, h and d of time, mph and kn of
+// speed, kg, g, t, lb and oz of mass, K, C and F of temperature, and B,
+// bit, kB, MB, GB, TB, KiB, MiB, GiB and TiB of data; registering a name
+// again replaces the previous unit.
+func (ev *Evaluator) RegisterUnit(name string, factor float64, of string) error {
+	return ev.RegisterAffineUnit(name, factor, 0, of)
+}
+
+// RegisterAffineUnit makes name a unit whose scale does not start at zero,
+// as temperatures other than kelvins do: x in name is x*factor + offset in
+// of, which must then be a single unit, as with RegisterAffineUnit("C", 1,
+// 273.15, "K"). The offset applies where convert converts from or to the
+// unit alone; in a compound unit, or in EvalUnits, which adds and compares
+// quantities, the unit measures differences and only the factor applies, so
+// that 10 C + 5 K is 15 K. An offset of 0 registers the unit as RegisterUnit
+// does.
+func (ev *Evaluator) RegisterAffineUnit(name string, factor, offset float64, of string) error {
+	if !isUnitName(name) {
+		return withCode(CodeInvalidUnit, fmt.Errorf("invalid unit name %q", name))
+	}
+	if factor <= 0 || math.IsInf(factor, 0) || math.IsNaN(factor) {
+		return withCode(CodeInvalidUnit, fmt.Errorf("unit %s: factor %s is not a positive number", name, formatNumber(factor)))
+	}
+	if math.IsInf(offset, 0) || math.IsNaN(offset) {
+		return withCode(CodeInvalidUnit, fmt.Errorf("unit %s: offset %s is not a finite number", name, formatNumber(offset)))
+	}
+	def := baseUnit(name)
+	if of != "" {
+		units, err := parseUnit(of)
+		if err != nil {
+			return fmt.Errorf("unit %s: %w", name, err)
+		}
+		if def.factor, def.dims, err = ev.unitDims(units); err != nil {
+			return fmt.Errorf("unit %s: %w", name, err)
+		}
+		if offset != 0 {
+			if len(units) != 1 || units[0].exp != 1 {
+				return withCode(CodeInvalidUnit, fmt.Errorf("unit %s: an offset needs a single unit, not %s", name, of))
+			}
+			within, _ := ev.lookupUnit(units[0].name)
+			def.offset = offset*def.factor + within.offset
+		}
+		def.factor *= factor
+	} else if factor != 1 || offset != 0 {
+		return withCode(CodeInvalidUnit, fmt.Errorf("base unit %s must have a factor of 1 and no offset, got %s and %s", name, formatNumber(factor), formatNumber(offset)))
+	}
+	if ev.units == nil {
+		ev.units = map[string]unitDef{}
+	}
+	ev.units[name] = def
+	return nil
+}
+
+// isUnitName reports whether name can be written after a literal: an
+// identifier other than a keyword.
+func isUnitName(name string) bool {
+	if name == "" || isKeyword(name) {
+		return false
+	}
+	for i, ch := range name {
+		if !isIdentStart(ch) && (i == 0 || !unicode.IsDigit(ch)) {
+			return false
+		}
+	}
+	return true
+}
+
+// unitPower is a unit raised to a power within a compound unit.
+type unitPower struct {
+	name string
+	exp  int
+}
+
+// parseUnit reads a compound unit such as "kg*m/s^2", in which each / divides
+// by the one unit after it.
+func parseUnit(s string) ([]unitPower, error) {
+	var units []unitPower
+	sign := 1
+	for rest, first := s, true; ; first = false {
+		term := rest
+		end := strings.IndexAny(rest, "*/")
+		if end >= 0 {
+			term = rest[:end]
+		}
+		exp := 1
+		if name, power, ok := strings.Cut(term, "^"); ok {
+			n, err := strconv.Atoi(power)
+			if err != nil {
+				return nil, withCode(CodeInvalidUnit, fmt.Errorf("invalid power %q in unit %q", power, s))
+			}
+			term, exp = name, n
+		}
+		switch {
+		case first && term == "1" && exp == 1:
+			// 1/s has no unit above the line
+		case !isUnitName(term):
+			return nil, withCode(CodeInvalidUnit, fmt.Errorf("invalid unit %q", s))
+		default:
+			units = combineUnits(units, []unitPower{{term, exp}}, sign)
+		}
+		if end < 0 {
+			return units, nil
+		}
+		sign = 1
+		if rest[end] == '/' {
+			sign = -1
+		}
+		rest = rest[end+1:]
+	}
+}
+
+// combineUnits multiplies the compound unit a by b raised to sign, 1 or -1.
+// The units of a keep their places, new ones follow, and units whose powers
+// cancel are dropped.
+func combineUnits(a, b []unitPower, sign int) []unitPower {
+	result := append([]unitPower(nil), a...)
+	for _, u := range b {
+		i := 0
+		for i < len(result) && result[i].name != u.name {
+			i++
+		}
+		if i == len(result) {
+			result = append(result, unitPower{u.name, sign * u.exp})
+			continue
+		}
+		if result[i].exp += sign * u.exp; result[i].exp == 0 {
+			result = append(result[:i], result[i+1:]...)
+		}
+	}
+	return result
+}
+
+// formatUnit renders a compound unit: the units with positive powers joined
+// by *, then / and each unit with a negative power, e.g. "kg*m/s^2".
+func formatUnit(units []unitPower) string {
+	var num, den []string
+	for _, u := range units {
+		exp := u.exp
+		if exp < 0 {
+			exp = -exp
+		}
+		s := u.name
+		if exp != 1 {
+			s += "^" + strconv.Itoa(exp)
+		}
+		if u.exp > 0 {
+			num = append(num, s)
+		} else {
+			den = append(den, s)
+		}
+	}
+	if len(num) == 0 && len(den) > 0 {
+		num = []string{"1"}
+	}
+	return strings.Join(append([]string{strings.Join(num, "*")}, den...), "/")
+}
+
+// lookupUnit finds a unit registered on the evaluator or a default one.
+func (ev *Evaluator) lookupUnit(name string) (unitDef, bool) {
+	if u, ok := ev.units[name]; ok {
+		return u, true
+	}
+	u, ok := defaultUnits[name]
+	return u, ok
+}
+
+// unitDims returns the factor converting a compound unit to base units and
+// the exponents of its base units.
+func (ev *Evaluator) unitDims(units []unitPower) (float64, map[string]int, error) {
+	factor := 1.0
+	dims := map[string]int{}
+	for _, u := range units {
+		def, ok := ev.lookupUnit(u.name)
+		if !ok {
+			return 0, nil, withCode(CodeUnknownUnit, fmt.Errorf("unknown unit %s", u.name))
+		}
+		factor *= math.Pow(def.factor, float64(u.exp))
+		for base, exp := range def.dims {
+			if dims[base] += exp * u.exp; dims[base] == 0 {
+				delete(dims, base)
+			}
+		}
+	}
+	return factor, dims, nil
+}
+
+// EvalUnits evaluates an expression with units of measure with the default Evaluator.
+func EvalUnits(expr Expr) (Quantity, error) {
+	return defaultEvaluator.EvalUnits(expr)
+}
+
+// EvalUnits evaluates expr with quantities, numbers carrying a unit written
+// after them, so "10 km / 2 h" is 5 km/h. Units are those known to
+// RegisterUnit. * and / combine the units of their operands, which cancel
+// when their powers do; a product or quotient left without a dimension,
+// as in 5 km / 500 m, becomes a plain number. + and - and % need operands
+// of the same dimension, and the comparisons compare them: operands in the
+// same unit keep it, others are converted to base units first, so
+// 1 km + 250 m is 1250 m while 3 m + 2 s is an error wrapping
+// ErrIncompatibleUnits. ^ raises a quantity to an integer power.
+//
+// Variables, plain literals and the results of comparisons and logical
+// operators are plain numbers. abs keeps the unit of its argument, min and
+// max work like + on theirs, and sqrt halves the powers of a unit whose
+// powers are all even; every other builtin, and the conditions of ?:, &&,
+// || and !, take plain numbers only. Division or modulo by zero follows the
+// WithDivisionByZero policy, as in Eval.
+func (ev *Evaluator) EvalUnits(expr Expr) (Quantity, error) {
+	if err := ev.admit(expr); err != nil {
+		return Quantity{}, err
+	}
+	q, err := ev.evalUnits(expr, nil)
+	if err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{Value: q.value, Unit: formatUnit(q.units)}, nil
+}
+
+// quantity is a value in units mode; a plain number has no units.
+type quantity struct {
+	value float64
+	units []unitPower
+}
+
+// unitScope is a chain of let bindings in units mode, innermost first.
+type unitScope struct {
+	name   string
+	value  quantity
+	parent *unitScope
+}
+
+// quantityError reports a quantity met by an evaluation mode without units.
+func quantityError(q *QuantityLiteral, mode string) error {
+	return withCode(CodeUnsupported, fmt.Errorf("quantity %s is not available in %s mode, only in EvalUnits", Format(q), mode))
+}
+
+// evalUnits evaluates expr in units mode with the given let bindings in scope.
+func (ev *Evaluator) evalUnits(expr Expr, env *unitScope) (quantity, error) {
+	switch v := expr.(type) {
+	case *Number:
+		if v.Imag {
+			return quantity{}, imaginaryError(v, "units")
+		}
+		return quantity{value: v.Value}, nil
+	case *QuantityLiteral:
+		if _, ok := ev.lookupUnit(v.Unit); !ok {
+			pos := -1
+			if v.Span != (Span{}) {
+				pos = v.Span.Start
+			}
+			return quantity{}, atOffset(withCode(CodeUnknownUnit, fmt.Errorf("unknown unit %s", v.Unit)), pos)
+		}
+		return quantity{value: v.Value, units: []unitPower{{v.Unit, 1}}}, nil
+	case *Variable:
+		for s := env; s != nil; s = s.parent {
+			if s.name == v.Name {
+				return s.value, nil
+			}
+		}
+		value, ok := ev.vars[v.Name]
+		if !ok {
+			return quantity{}, &UndefinedVariableError{Name: v.Name, Span: v.Span}
+		}
+		return quantity{value: value}, nil
+	case *UnaryOp:
+		operand, err := ev.evalUnits(v.Operand, env)
+		if err != nil {
+			return quantity{}, err
+		}
+		switch v.Op.Type {
+		case MINUS:
+			return quantity{value: -operand.value, units: operand.units}, nil
+		case NOT:
+			if err := plainOperand("!", operand, operatorPos(v.Op, v.Span)); err != nil {
+				return quantity{}, err
+			}
+			return quantity{value: truth(!isTrue(operand.value))}, nil
+		}
+	case *BinaryOp:
+		return ev.unitsBinary(v, env)
+	case *FunctionCall:
+		return ev.callUnitsBuiltin(v, env)
+	case *Let:
+		value, err := ev.evalUnits(v.Value, env)
+		if err != nil {
+			return quantity{}, err
+		}
+		return ev.evalUnits(v.Body, &unitScope{name: v.Name, value: value, parent: env})
+	case *Conditional:
+		cond, err := ev.evalUnits(v.Cond, env)
+		if err != nil {
+			return quantity{}, err
+		}
+		pos := -1
+		if v.Span != (Span{}) {
+			pos = SpanOf(v.Cond).Start
+		}
+		if err := plainOperand("?:", cond, pos); err != nil {
+			return quantity{}, err
+		}
+		if isTrue(cond.value) {
+			return ev.evalUnits(v.Then, env)
+		}
+		return ev.evalUnits(v.Else, env)
+	}
+	return quantity{}, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate expression of type %T in units mode", expr))
+}
+
+// plainOperand rejects a quantity where op needs a plain number.
+func plainOperand(op string, q quantity, pos int) error {
+	if len(q.units) == 0 {
+		return nil
+	}
+	return atOffset(withCode(CodeIncompatibleUnits, fmt.Errorf("%s needs a plain number, got %s", op, Quantity{q.value, formatUnit(q.units)})), pos)
+}
+
+// unitsBinary evaluates a binary operator in units mode.
+func (ev *Evaluator) unitsBinary(v *BinaryOp, env *unitScope) (quantity, error) {
+	pos := operatorPos(v.Op, v.Span)
+	symbol := operatorSymbol(v.Op)
+	left, err := ev.evalUnits(v.Left, env)
+	if err != nil {
+		return quantity{}, err
+	}
+	if v.Op.Type == AND || v.Op.Type == OR {
+		if err := plainOperand(symbol, left, pos); err != nil {
+			return quantity{}, err
+		}
+		if isTrue(left.value) == (v.Op.Type == OR) {
+			// Short-circuit: the right operand cannot change the result
+			return quantity{value: truth(isTrue(left.value))}, nil
+		}
+	}
+	right, err := ev.evalUnits(v.Right, env)
+	if err != nil {
+		return quantity{}, err
+	}
+
+	switch v.Op.Type {
+	case AND, OR:
+		if err := plainOperand(symbol, right, pos); err != nil {
+			return quantity{}, err
+		}
+		return quantity{value: truth(isTrue(right.value))}, nil
+	case MULT:
+		return ev.simplifyUnits(quantity{left.value * right.value, combineUnits(left.units, right.units, 1)})
+	case DIV:
+		if right.value == 0 {
+			value, ieee, err := ev.zeroDivision(v.Op, v.Right)
+			if err != nil {
+				return quantity{}, err
+			}
+			if !ieee {
+				return quantity{value: value}, nil
+			}
+		}
+		return ev.simplifyUnits(quantity{left.value / right.value, combineUnits(left.units, right.units, -1)})
+	case POW:
+		return unitsPow(left, right, pos)
+	}
+
+	// The other operators need operands of one dimension
+	if left, right, err = ev.sameUnits(symbol, pos, left, right); err != nil {
+		return quantity{}, err
+	}
+	a, b := left.value, right.value
+	switch v.Op.Type {
+	case PLUS:
+		return quantity{a + b, left.units}, nil
+	case MINUS:
+		return quantity{a - b, left.units}, nil
+	case MOD:
+		if b == 0 {
+			value, ieee, err := ev.zeroDivision(v.Op, v.Right)
+			if err != nil {
+				return quantity{}, err
+			}
+			if !ieee {
+				return quantity{value: value}, nil
+			}
+		}
+		return quantity{ev.mod(a, b), left.units}, nil
+	case LT:
+		return quantity{value: truth(a < b)}, nil
+	case LE:
+		return quantity{value: truth(a <= b)}, nil
+	case GT:
+		return quantity{value: truth(a > b)}, nil
+	case GE:
+		return quantity{value: truth(a >= b)}, nil
+	case EQ:
+		return quantity{value: truth(a == b)}, nil
+	case NE:
+		return quantity{value: truth(a != b)}, nil
+	}
+	return quantity{}, withCode(CodeUnsupported, fmt.Errorf("cannot evaluate operator %s in units mode", symbol))
+}
+
+// sameUnits brings two quantities to a common unit for op: the unit of both
+// when they agree, and otherwise base units, provided their dimensions do.
+func (ev *Evaluator) sameUnits(op string, pos int, a, b quantity) (quantity, quantity, error) {
+	if equalUnits(a.units, b.units) {
+		return a, b, nil
+	}
+	fa, da, err := ev.unitDims(a.units)
+	if err != nil {
+		return a, b, atOffset(err, pos)
+	}
+	fb, db, err := ev.unitDims(b.units)
+	if err != nil {
+		return a, b, atOffset(err, pos)
+	}
+	if !equalDims(da, db) {
+		err := fmt.Errorf("%w %s and %s for %s", ErrIncompatibleUnits, unitOrPlain(a.units), unitOrPlain(b.units), op)
+		return a, b, atOffset(err, pos)
+	}
+	base := baseUnits(da)
+	return quantity{a.value * fa, base}, quantity{b.value * fb, base}, nil
+}
+
+// simplifyUnits turns a quantity whose units leave no dimension, such as
+// km/m, into the plain number it stands for.
+func (ev *Evaluator) simplifyUnits(q quantity) (quantity, error) {
+	if len(q.units) == 0 {
+		return q, nil
+	}
+	factor, dims, err := ev.unitDims(q.units)
+	if err != nil || len(dims) > 0 {
+		return q, err
+	}
+	return quantity{value: q.value * factor}, nil
+}
+
+// unitsPow raises a quantity to a plain power, which must be an integer
+// when the quantity has a unit.
+func unitsPow(base, exponent quantity, pos int) (quantity, error) {
+	if err := plainOperand("^", exponent, pos); err != nil {
+		return quantity{}, err
+	}
+	value := math.Pow(base.value, exponent.value)
+	if len(base.units) == 0 {
+		return quantity{value: value}, nil
+	}
+	n := exponent.value
+	if n != math.Trunc(n) || math.Abs(n) > math.MaxInt32 {
+		return quantity{}, atOffset(withCode(CodeOutsideDomain, fmt.Errorf("cannot raise %s to the non-integer power %s", formatUnit(base.units), formatNumber(n))), pos)
+	}
+	units := make([]unitPower, 0, len(base.units))
+	for _, u := range base.units {
+		if n != 0 {
+			units = append(units, unitPower{u.name, u.exp * int(n)})
+		}
+	}
+	return quantity{value, units}, nil
+}
+
+// callUnitsBuiltin evaluates a call in units mode. abs, min, max and sqrt
+// take quantities; the arguments of the others must be plain numbers,
+// which are passed to the function as Eval would.
+func (ev *Evaluator) callUnitsBuiltin(call *FunctionCall, env *unitScope) (quantity, error) {
+	target, err := ev.resolveCall(call)
+	if err != nil {
+		return quantity{}, err
+	}
+	args := make([]quantity, len(call.Args))
+	withUnits := false
+	for i, arg := range call.Args {
+		if args[i], err = ev.evalUnits(arg, env); err != nil {
+			return quantity{}, err
+		}
+		withUnits = withUnits || len(args[i].units) > 0
+	}
+
+	pos := -1
+	if call.Span != (Span{}) {
+		pos = call.Span.Start
+	}
+	if withUnits && !target.registered {
+		switch call.Name {
+		case "abs":
+			return quantity{math.Abs(args[0].value), args[0].units}, nil
+		case "min", "max":
+			result := args[0]
+			for _, arg := range args[1:] {
+				a, b, err := ev.sameUnits(call.Name, pos, result, arg)
+				if err != nil {
+					return quantity{}, err
+				}
+				if (call.Name == "min") == (b.value < a.value) {
+					a = b
+				}
+				result = a
+			}
+			return result, nil
+		case "sqrt":
+			return unitsSqrt(args[0], pos)
+		}
+	}
+
+	values := make([]Value, len(args))
+	for i, arg := range args {
+		if err := plainOperand(call.Name, arg, pos); err != nil {
+			return quantity{}, err
+		}
+		values[i] = NumberValue(arg.value)
+	}
+	result, err := ev.applyCall(call, target, values)
+	if err != nil {
+		return quantity{}, atOffset(err, pos)
+	}
+	if !result.numeric() {
+		return quantity{}, atOffset(withCode(CodeType, fmt.Errorf("%s gives a %s, not a number", call.Name, result.kind)), pos)
+	}
+	return quantity{value: result.num}, nil
+}
+
+// unitsSqrt takes the square root of a quantity whose unit has even powers.
+func unitsSqrt(q quantity, pos int) (quantity, error) {
+	units := make([]unitPower, len(q.units))
+	for i, u := range q.units {
+		if u.exp%2 != 0 {
+			return quantity{}, atOffset(withCode(CodeOutsideDomain, fmt.Errorf("sqrt: unit %s is not a square", formatUnit(q.units))), pos)
+		}
+		units[i] = unitPower{u.name, u.exp / 2}
+	}
+	if q.value < 0 {
+		return quantity{}, atOffset(fmt.Errorf("sqrt: argument %s is %w", Quantity{q.value, formatUnit(q.units)}, ErrOutsideDomain), pos)
+	}
+	return quantity{math.Sqrt(q.value), units}, nil
+}
+
+// equalUnits reports whether two compound units are written alike, up to
+// the order of their units.
+func equalUnits(a, b []unitPower) bool {
+	return len(a) == len(b) && len(combineUnits(a, b, -1)) == 0
+}
+
+// equalDims reports whether two dimensions agree.
+func equalDims(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for base, exp := range a {
+		if b[base] != exp {
+			return false
+		}
+	}
+	return true
+}
+
+// baseUnits returns the compound unit of a dimension in base units, in
+// alphabetical order.
+func baseUnits(dims map[string]int) []unitPower {
+	units := make([]unitPower, 0, len(dims))
+	for base, exp := range dims {
+		units = append(units, unitPower{base, exp})
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].name < units[j].name })
+	return units
+}
+
+// unitOrPlain names a compound unit in an error, or says it is none.
+func unitOrPlain(units []unitPower) string {
+	if len(units) == 0 {
+		return "plain number"
+	}
+	return formatUnit(units)
+}
+
+// end of file
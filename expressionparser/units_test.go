@@ -0,0 +1,131 @@
+package expressionparser
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEvalUnits(t *testing.T) {
+	tests := []struct {
+		input string
+		value float64
+		unit  string
+	}{
+		{"10 km / 2 h", 5, "km/h"},
+		{"1 km + 250 m", 1250, "m"},
+		{"1 km + 1 km", 2, "km"},
+		{"1 h - 30 min", 1800, "s"},
+		{"2 km * 3", 6, "km"},
+		{"5 km / 500 m", 10, ""},
+		{"2 m * 3 m", 6, "m^2"},
+		{"(3 m) ^ 2", 9, "m^2"},
+		{"sqrt(9 m * 1 m)", 3, "m"},
+		{"60 mph * 2 h", 120, "mph*h"},
+		{"1 mi / 1 h + 1 mph", 0.89408, "m/s"},
+		{"100 kg * 9.8 m / 1 s / 1 s", 980, "kg*m/s^2"},
+		{"abs(-2 m)", 2, "m"},
+		{"max(1 m, 1 km)", 1000, "m"},
+		{"10 C + 5 K", 15, "K"},
+		{"1 km > 999 m", 1, ""},
+	}
+	for _, tt := range tests {
+		got, err := EvalUnits(mustParse(t, tt.input))
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if math.Abs(got.Value-tt.value) > 1e-12*tt.value || got.Unit != tt.unit {
+			t.Errorf("%s = %v, want %v %s", tt.input, got, tt.value, tt.unit)
+		}
+	}
+	if got, _ := EvalUnits(mustParse(t, "10 km / 2 h")); got.String() != "5 km/h" {
+		t.Errorf("10 km / 2 h renders as %q, want 5 km/h", got)
+	}
+}
+
+func TestEvalUnitsErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{"3 m + 2 s", CodeIncompatibleUnits, "incompatible units m and s for + at offset 4"},
+		{"3 m < 2 s", CodeIncompatibleUnits, "incompatible units m and s for < at offset 4"},
+		{"1 kg - 1", CodeIncompatibleUnits, ""},
+		{"1 km / 1 h + 1 m", CodeIncompatibleUnits, ""},
+		{"1 zz + 1", CodeUnknownUnit, "unknown unit zz at offset 0"},
+	}
+	for _, tt := range tests {
+		_, err := EvalUnits(mustParse(t, tt.input))
+		if ErrorCode(err) != tt.code {
+			t.Errorf("%s: error %v, want %s", tt.input, err, tt.code)
+			continue
+		}
+		if tt.code == CodeIncompatibleUnits && !errors.Is(err, ErrIncompatibleUnits) {
+			t.Errorf("%s: error %v is not ErrIncompatibleUnits", tt.input, err)
+		}
+		if tt.msg != "" && err.Error() != tt.msg {
+			t.Errorf("%s: error %q, want %q", tt.input, err, tt.msg)
+		}
+	}
+	// Builtins other than abs, min, max and sqrt take plain numbers
+	if _, err := EvalUnits(mustParse(t, "sin(1 m)")); ErrorCode(err) != CodeIncompatibleUnits || err.Error() != "sin needs a plain number, got 1 m at offset 0" {
+		t.Errorf("sin(1 m): error %v", err)
+	}
+	if _, err := Eval(mustParse(t, "1 km + 1")); ErrorCode(err) != CodeUnsupported {
+		t.Errorf("Eval(1 km + 1): error %v, want %s", err, CodeUnsupported)
+	}
+}
+
+func TestRegisterUnit(t *testing.T) {
+	ev := NewEvaluator()
+	for name, of := range map[string]string{"N": "kg*m/s^2", "coin": ""} {
+		if err := ev.RegisterUnit(name, 1, of); err != nil {
+			t.Fatalf("RegisterUnit(%s): %v", name, err)
+		}
+	}
+	if err := ev.RegisterUnit("furlong", 201.168, "m"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"2 furlong + 1 m", "403.336 m"},
+		{"1 N + 1 kg * 1 m / 1 s^2", "2 kg*m/s^2"},
+		{"2 N * 3 m", "6 N*m"},
+		{"3 coin * 2", "6 coin"},
+	}
+	for _, tt := range tests {
+		if got, err := ev.EvalUnits(mustParse(t, tt.input)); err != nil || got.String() != tt.want {
+			t.Errorf("%s = %v, %v; want %s", tt.input, got, err, tt.want)
+		}
+	}
+	if _, err := ev.EvalUnits(mustParse(t, "3 coin + 1 m")); !errors.Is(err, ErrIncompatibleUnits) {
+		t.Errorf("3 coin + 1 m: error %v, want incompatible units", err)
+	}
+	// Units registered on one evaluator are unknown to others
+	if _, err := EvalUnits(mustParse(t, "1 furlong")); ErrorCode(err) != CodeUnknownUnit {
+		t.Errorf("1 furlong with the default evaluator: error %v, want %s", err, CodeUnknownUnit)
+	}
+
+	bad := []struct {
+		name, of string
+		factor   float64
+		code     string
+	}{
+		{"bad", "m", 0, CodeInvalidUnit},
+		{"bad", "m", math.NaN(), CodeInvalidUnit},
+		{"2x", "m", 1, CodeInvalidUnit},
+		{"base", "", 2, CodeInvalidUnit},
+		{"x2", "zzz", 1, CodeUnknownUnit},
+	}
+	for _, tt := range bad {
+		if err := ev.RegisterUnit(tt.name, tt.factor, tt.of); ErrorCode(err) != tt.code {
+			t.Errorf("RegisterUnit(%q, %v, %q): error %v, want %s", tt.name, tt.factor, tt.of, err, tt.code)
+		}
+	}
+}
+
+// end of file
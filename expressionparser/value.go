@@ -0,0 +1,331 @@
+package expressionparser
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the type of a Value.
+type Kind int
+
+const (
+	NumberKind Kind = iota
+	BoolKind
+	StringKind
+	ListKind
+	DateKind
+	DurationKind
+)
+
+// String returns the name of the kind used in error messages.
+func (k Kind) String() string {
+	switch k {
+	case NumberKind:
+		return "number"
+	case BoolKind:
+		return "bool"
+	case StringKind:
+		return "string"
+	case ListKind:
+		return "list"
+	case DateKind:
+		return "date"
+	case DurationKind:
+		return "duration"
+	}
+	return "Kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Value is a typed result of EvalValue: a number, a bool, a string, a
+// list of numbers, a date or a duration. The zero Value is the number 0.
+//
+// Values convert implicitly only between numbers and bools:
+//   - where a number is expected, by arithmetic operators, orderings,
+//     builtins and Eval, a bool counts as 1 or 0;
+//   - where a bool is expected, by &&, ||, ! and the condition of ?:, a
+//     number is true when it is neither zero nor NaN.
+//
+// Other conversions are explicit, by the builtins int, float, bool and
+// str. Strings never convert implicitly: + concatenates two strings and ==
+// and != compare them, and applying any other operator, or a builtin that
+// does not accept a string, gives a *TypeError, as does mixing a string
+// with a number or a bool. Lists never convert either: the arithmetic
+// operators apply to them element by element, a number or bool with a list
+// applying to each element and two lists of the same length index by
+// index, and otherwise they are taken only by the aggregate builtins sum,
+// avg, count, product, variance, pvariance, stddev, pstddev, median,
+// percentile, dot and norm and by cumsum and reverse, which give lists.
+// Dates and durations do not convert either: a date plus or minus a
+// duration is a date and the difference of two dates a duration, while
+// adding two dates is a *TypeError; durations add and subtract, scale by
+// numbers and divide to a number; and dates and durations compare with values of their own kind. Dates are times in UTC,
+// which is also the time zone of the days date reads. Comparisons and
+// logical operators produce bools. The As accessors apply the same rules.
+type Value struct {
+	kind Kind
+	num  float64 // the number, or 1 or 0 for a bool
+	str  string
+	list *list
+	date time.Time
+	dur  time.Duration
+}
+
+// list holds the numbers of a list Value: its elements, or for a range the
+// whole numbers from lo to hi, which are never stored one by one.
+type list struct {
+	items   []float64
+	isRange bool
+	lo, hi  float64
+}
+
+// len returns the number of elements of the list.
+func (l *list) len() int {
+	if !l.isRange {
+		return len(l.items)
+	}
+	if l.hi < l.lo {
+		return 0
+	}
+	return int(l.hi-l.lo) + 1
+}
+
+// each calls fn with the elements of the list in order until it returns false.
+func (l *list) each(fn func(x float64) bool) {
+	if !l.isRange {
+		for _, x := range l.items {
+			if !fn(x) {
+				return
+			}
+		}
+		return
+	}
+	for x := l.lo; x <= l.hi; x++ {
+		if !fn(x) {
+			return
+		}
+	}
+}
+
+// NumberValue returns the number v as a Value.
+func NumberValue(v float64) Value {
+	return Value{kind: NumberKind, num: v}
+}
+
+// BoolValue returns b as a Value.
+func BoolValue(b bool) Value {
+	return Value{kind: BoolKind, num: truth(b)}
+}
+
+// StringValue returns s as a Value.
+func StringValue(s string) Value {
+	return Value{kind: StringKind, str: s}
+}
+
+// ListValue returns a list of the numbers in items as a Value, as bound in
+// the environment of EvalValue. The slice is copied.
+func ListValue(items []float64) Value {
+	return Value{kind: ListKind, list: &list{items: append([]float64(nil), items...)}}
+}
+
+// rangeValue returns the list of whole numbers from lo to hi, empty when lo
+// is greater; pos locates the operator for errors.
+func rangeValue(lo, hi float64, pos int) (Value, error) {
+	if lo != math.Trunc(lo) || hi != math.Trunc(hi) || math.Abs(lo) >= maxExactFloatInt || math.Abs(hi) >= maxExactFloatInt {
+		err := withCode(CodeOutsideDomain, fmt.Errorf("range bounds %s and %s must be whole numbers between -2^53 and 2^53", formatNumber(lo), formatNumber(hi)))
+		return Value{}, atOffset(err, pos)
+	}
+	return Value{kind: ListKind, list: &list{isRange: true, lo: lo, hi: hi}}, nil
+}
+
+// Kind returns the type of the value.
+func (v Value) Kind() Kind {
+	return v.kind
+}
+
+// AsFloat returns the value as a number, converting a bool to 1 or 0.
+func (v Value) AsFloat() (float64, error) {
+	if !v.numeric() {
+		return 0, withCode(CodeType, fmt.Errorf("value is a %s, not a number", v.kind))
+	}
+	return v.num, nil
+}
+
+// AsBool returns the value as a bool, a number counting as true when it is
+// neither zero nor NaN.
+func (v Value) AsBool() (bool, error) {
+	if !v.numeric() {
+		return false, withCode(CodeType, fmt.Errorf("value is a %s, not a bool", v.kind))
+	}
+	return isTrue(v.num), nil
+}
+
+// AsString returns the value of a string.
+func (v Value) AsString() (string, error) {
+	if v.kind != StringKind {
+		return "", withCode(CodeType, fmt.Errorf("value is a %s, not a string", v.kind))
+	}
+	return v.str, nil
+}
+
+// AsList returns the elements of a list. A range is expanded into a slice
+// holding every number in it.
+func (v Value) AsList() ([]float64, error) {
+	if v.kind != ListKind {
+		return nil, withCode(CodeType, fmt.Errorf("value is a %s, not a list", v.kind))
+	}
+	if !v.list.isRange {
+		return append([]float64(nil), v.list.items...), nil
+	}
+	items := make([]float64, 0, v.list.len())
+	v.list.each(func(x float64) bool {
+		items = append(items, x)
+		return true
+	})
+	return items, nil
+}
+
+// String renders the value for display: numbers as by Format, bools as
+// true or false, strings quoted, lists in brackets, ranges as from..to,
+// dates as 2024-01-31, or in RFC 3339 form when they have a time of day,
+// and durations as literals such as 30 d.
+func (v Value) String() string {
+	switch v.kind {
+	case BoolKind:
+		return strconv.FormatBool(v.num != 0)
+	case StringKind:
+		return strconv.Quote(v.str)
+	case ListKind:
+		if v.list.isRange {
+			return formatNumber(v.list.lo) + ".." + formatNumber(v.list.hi)
+		}
+		items := make([]string, len(v.list.items))
+		for i, x := range v.list.items {
+			items[i] = formatNumber(x)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case DateKind:
+		return formatDate(v.date)
+	case DurationKind:
+		return formatDuration(v.dur)
+	}
+	return formatNumber(v.num)
+}
+
+// numeric reports whether v is a number or a bool, which convert to each other.
+func (v Value) numeric() bool {
+	return v.kind == NumberKind || v.kind == BoolKind
+}
+
+// TypeError reports an operator or builtin applied to values of types it
+// does not accept.
+type TypeError struct {
+	Op       string // operator symbol or function name
+	Operands []Kind
+	Arg      int // position from 1 of the argument of a call at fault, or 0
+	Pos      int // offset of the operator or call; -1 for trees built without spans
+}
+
+// Error names the operator, the operand types, the argument at fault and,
+// when known, the offset.
+func (e *TypeError) Error() string {
+	if e.Pos < 0 {
+		return e.withoutOffset()
+	}
+	return fmt.Sprintf("%s at offset %d", e.withoutOffset(), e.Pos)
+}
+
+// withoutOffset names the operator and the operand types.
+func (e *TypeError) withoutOffset() string {
+	kinds := make([]string, len(e.Operands))
+	for i, k := range e.Operands {
+		kinds[i] = k.String()
+	}
+	list := kinds[len(kinds)-1]
+	if len(kinds) > 1 {
+		list = strings.Join(kinds[:len(kinds)-1], ", ") + " and " + list
+	}
+	if e.Arg > 0 {
+		return fmt.Sprintf("cannot apply %s to %s: argument %d is a %s", e.Op, list, e.Arg, e.Operands[e.Arg-1])
+	}
+	return fmt.Sprintf("cannot apply %s to %s", e.Op, list)
+}
+
+// EvalValue evaluates an expression with the default Evaluator, resolving
+// variables that are not bound by let from env. The map is only read; a nil
+// map behaves like an empty one.
+func EvalValue(expr Expr, env map[string]Value) (Value, error) {
+	if err := defaultEvaluator.admit(expr); err != nil {
+		return Value{}, err
+	}
+	return defaultEvaluator.eval(expr, &scope{values: env})
+}
+
+// EvalValue evaluates expr to a typed value. Variables not bound by let are
+// resolved from those set on the evaluator, as numbers; a missing one gives
+// an *UndefinedVariableError. && and || evaluate their right operand only
+// when the left one does not decide the result, and ?: evaluates only the
+// chosen branch, so errors in the skipped parts are never raised. Chains
+// of binary operators down the left operand, such as a machine-generated
+// sum of many thousands of terms, are evaluated without recursion and so
+// may be arbitrarily long. Errors met in a subexpression come as an
+// *EvalError quoting it, with the cause, such as ErrDivisionByZero, reachable
+// with errors.Is and errors.As.
+func (ev *Evaluator) EvalValue(expr Expr) (Value, error) {
+	return ev.evalValueContext(context.Background(), expr, nil)
+}
+
+// number converts v where an operator or builtin expects a number.
+func (v Value) number(op string, pos int) (float64, error) {
+	if !v.numeric() {
+		return 0, &TypeError{Op: op, Operands: []Kind{v.kind}, Pos: pos}
+	}
+	return v.num, nil
+}
+
+// numbers converts the two operands of a binary operator that expects numbers.
+func numbers(op string, pos int, a, b Value) (float64, float64, error) {
+	if !a.numeric() || !b.numeric() {
+		return 0, 0, &TypeError{Op: op, Operands: []Kind{a.kind, b.kind}, Pos: pos}
+	}
+	return a.num, b.num, nil
+}
+
+// numberArgs converts the arguments of a call to a builtin that expects numbers.
+func numberArgs(call *FunctionCall, values []Value) ([]float64, error) {
+	args := make([]float64, len(values))
+	for i, v := range values {
+		if !v.numeric() {
+			return nil, callTypeError(call, values, i)
+		}
+		args[i] = v.num
+	}
+	return args, nil
+}
+
+// callTypeError reports a call whose arguments have kinds the function does
+// not accept, the first at fault being args[i].
+func callTypeError(call *FunctionCall, args []Value, i int) *TypeError {
+	kinds := make([]Kind, len(args))
+	for j, arg := range args {
+		kinds[j] = arg.kind
+	}
+	pos := -1
+	if call.Span != (Span{}) {
+		pos = call.Span.Start
+	}
+	return &TypeError{Op: call.Name, Operands: kinds, Arg: i + 1, Pos: pos}
+}
+
+// condition converts v where an operator expects a bool.
+func (v Value) condition(op string, pos int) (bool, error) {
+	if !v.numeric() {
+		return false, &TypeError{Op: op, Operands: []Kind{v.kind}, Pos: pos}
+	}
+	return isTrue(v.num), nil
+}
+
+// end of file
@@ -0,0 +1,195 @@
+package expressionparser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// valueOperands holds an expression of each kind of value.
+var valueOperands = []struct {
+	kind  Kind
+	input string
+}{
+	{NumberKind, "6"},
+	{BoolKind, "(1 < 2)"},
+	{StringKind, `"ab"`},
+	{ListKind, "[1, 2]"},
+	{DateKind, `date("2024-01-31")`},
+	{DurationKind, "2 d"},
+}
+
+var valueOperators = []string{
+	"+", "-", "*", "/", "%", "^",
+	"<", "<=", ">", ">=", "==", "!=",
+	"&&", "||",
+}
+
+// valueMatrix evaluates every operator against every pair of operand kinds,
+// and every unary operator against every kind, one line each.
+func valueMatrix(t *testing.T) string {
+	t.Helper()
+	var b strings.Builder
+	line := func(input string) {
+		got, err := EvalValue(mustParse(t, input), nil)
+		var typeErr *TypeError
+		if errors.As(err, &typeErr) {
+			fmt.Fprintf(&b, "%s => %v\n", input, typeErr)
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(&b, "%s => error %s: %v\n", input, ErrorCode(err), err)
+			return
+		}
+		fmt.Fprintf(&b, "%s => %s %s\n", input, got.Kind(), got)
+	}
+	for _, op := range valueOperators {
+		for _, x := range valueOperands {
+			for _, y := range valueOperands {
+				line(x.input + " " + op + " " + y.input)
+			}
+		}
+	}
+	for _, op := range []string{"-", "!"} {
+		for _, x := range valueOperands {
+			line(op + x.input)
+		}
+	}
+	return b.String()
+}
+
+// TestValueOperatorMatrix compares the result or error of every operator
+// applied to every combination of value kinds with
+// testdata/values/matrix.golden. Run go test -run TestValueOperatorMatrix
+// -update to rewrite it after a deliberate change to the conversion rules.
+func TestValueOperatorMatrix(t *testing.T) {
+	got := valueMatrix(t)
+	golden := filepath.Join("testdata", "values", "matrix.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v; run go test -run %s -update to create it", err, t.Name())
+	}
+	if got != string(want) {
+		gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+		for i := 0; i < len(gotLines) && i < len(wantLines); i++ {
+			if gotLines[i] != wantLines[i] {
+				t.Fatalf("line %d:\n got %s\nwant %s", i+1, gotLines[i], wantLines[i])
+			}
+		}
+		t.Fatalf("got %d lines, want %d", len(gotLines), len(wantLines))
+	}
+}
+
+func TestTypeErrorNamesOperands(t *testing.T) {
+	tests := []struct {
+		input    string
+		op       string
+		operands []Kind
+		pos      int
+	}{
+		{`"a" * 2`, "*", []Kind{StringKind, NumberKind}, 4},
+		{`2 - "a"`, "-", []Kind{NumberKind, StringKind}, 2},
+		{`1 + ("x" < "y")`, "<", []Kind{StringKind, StringKind}, 9},
+		{`-"a"`, "-", []Kind{StringKind}, 0},
+	}
+	for _, tt := range tests {
+		_, err := EvalValue(mustParse(t, tt.input), nil)
+		var typeErr *TypeError
+		if !errors.As(err, &typeErr) {
+			t.Errorf("%s: error %v, want a *TypeError", tt.input, err)
+			continue
+		}
+		if typeErr.Op != tt.op || !reflect.DeepEqual(typeErr.Operands, tt.operands) || typeErr.Pos != tt.pos {
+			t.Errorf("%s: %+v, want %s on %v at %d", tt.input, *typeErr, tt.op, tt.operands, tt.pos)
+		}
+		if ErrorCode(err) != CodeType {
+			t.Errorf("%s: code %s, want %s", tt.input, ErrorCode(err), CodeType)
+		}
+	}
+
+	// The *EvalError names the subexpression at its own offset; the cause
+	// keeps that of the operator
+	_, err := EvalValue(mustParse(t, `"a" * 2`), nil)
+	if want := `cannot apply * to string and number in "\"a\" * 2" at offset 0`; err == nil || err.Error() != want {
+		t.Errorf(`"a" * 2: error %v, want %q`, err, want)
+	}
+	if want := "cannot apply * to string and number at offset 4"; errors.Unwrap(err).Error() != want {
+		t.Errorf(`"a" * 2: cause %v, want %q`, errors.Unwrap(err), want)
+	}
+	_, err = EvalValue(Mul(Str("a"), Num(2)), nil)
+	if want := `cannot apply * to string and number in "\"a\" * 2"`; err == nil || err.Error() != want {
+		t.Errorf("built \"a\" * 2: error %v, want %q", err, want)
+	}
+}
+
+func TestValueAccessors(t *testing.T) {
+	env := map[string]Value{
+		"n": NumberValue(2.5),
+		"b": BoolValue(true),
+		"s": StringValue("hi"),
+		"l": ListValue([]float64{1, 2}),
+	}
+	type result struct {
+		float float64
+		bool  bool
+		str   string
+		list  []float64
+		fails string // the accessors that fail, by initial
+	}
+	want := map[string]result{
+		"n": {float: 2.5, bool: true, fails: "sl"},
+		"b": {float: 1, bool: true, fails: "sl"},
+		"s": {str: "hi", fails: "fbl"},
+		"l": {list: []float64{1, 2}, fails: "fbs"},
+	}
+	for name, v := range env {
+		w := want[name]
+		var fails string
+		f, err := v.AsFloat()
+		if err != nil {
+			fails += "f"
+		} else if f != w.float {
+			t.Errorf("%s.AsFloat() = %v, want %v", v, f, w.float)
+		}
+		bv, err := v.AsBool()
+		if err != nil {
+			fails += "b"
+		} else if bv != w.bool {
+			t.Errorf("%s.AsBool() = %v, want %v", v, bv, w.bool)
+		}
+		s, err := v.AsString()
+		if err != nil {
+			fails += "s"
+		} else if s != w.str {
+			t.Errorf("%s.AsString() = %q, want %q", v, s, w.str)
+		}
+		l, err := v.AsList()
+		if err != nil {
+			fails += "l"
+			if ErrorCode(err) != CodeType {
+				t.Errorf("%s.AsList(): code %s, want %s", v, ErrorCode(err), CodeType)
+			}
+		} else if !reflect.DeepEqual(l, w.list) {
+			t.Errorf("%s.AsList() = %v, want %v", v, l, w.list)
+		}
+		if fails != w.fails {
+			t.Errorf("%s: accessors %q fail, want %q", v, fails, w.fails)
+		}
+
+		if got, err := EvalValue(Var(name), env); err != nil || got.Kind() != v.Kind() || got.String() != v.String() {
+			t.Errorf("%s with %s = %v, %v", name, v, got, err)
+		}
+	}
+}
+
+// end of file
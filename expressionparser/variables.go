@@ -0,0 +1,100 @@
+package expressionparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Variables returns the distinct names of the free variables referenced in
+// expr, in order of first appearance in a left-to-right, depth-first
+// traversal. A name bound by let is not reported for uses inside that let's
+// body, but is reported if it also occurs free elsewhere, including in the
+// let's own value; so is a name bound by a special form, such as deriv, in
+// its first argument, or by an iteration form, such as sum(i, 1, n, i^2),
+// in its last.
+func Variables(expr Expr) []string {
+	c := &variableCollector{seen: map[string]bool{}}
+	c.collect(expr, nil)
+	return c.names
+}
+
+// variableCollector accumulates free variables in first-appearance order.
+type variableCollector struct {
+	names []string
+	seen  map[string]bool
+}
+
+// collect visits expr with the given let-bound names in scope.
+func (c *variableCollector) collect(expr Expr, bound *scope) {
+	switch v := expr.(type) {
+	case *Variable:
+		if _, ok := bound.lookup(v.Name); !ok && !c.seen[v.Name] {
+			c.seen[v.Name] = true
+			c.names = append(c.names, v.Name)
+		}
+	case *Let:
+		c.collect(v.Value, bound)
+		c.collect(v.Body, &scope{name: v.Name, parent: bound})
+	case *FunctionCall:
+		name, binder, body, ok := formBinding(v)
+		for i, arg := range v.Args {
+			switch {
+			case ok && i == body:
+				c.collect(arg, &scope{name: name, parent: bound})
+			case ok && i == binder:
+			default:
+				c.collect(arg, bound)
+			}
+		}
+	default:
+		for _, child := range Children(expr) {
+			c.collect(child, bound)
+		}
+	}
+}
+
+// MissingVariables returns the free variables of expr, as Variables lists
+// them, that have no value in env: those that Eval with env would report
+// undefined, all of them rather than the first it meets.
+func MissingVariables(expr Expr, env map[string]float64) []string {
+	var missing []string
+	for _, name := range Variables(expr) {
+		if _, ok := env[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// Functions returns the distinct names of all functions called in expr, in
+// order of first appearance: a call is listed before the calls nested in its
+// arguments, and arguments are searched left to right.
+func Functions(expr Expr) []string {
+	var names []string
+	seen := map[string]bool{}
+	Walk(expr, func(e Expr) bool {
+		if call, ok := e.(*FunctionCall); ok && !seen[call.Name] {
+			seen[call.Name] = true
+			names = append(names, call.Name)
+		}
+		return true
+	})
+	return names
+}
+
+// CheckFunctions returns an error listing every function called in expr
+// that is not in the allowed set, or nil if all calls are allowed.
+func CheckFunctions(expr Expr, allowed map[string]bool) error {
+	var denied []string
+	for _, name := range Functions(expr) {
+		if !allowed[name] {
+			denied = append(denied, name)
+		}
+	}
+	if len(denied) > 0 {
+		return withCode(CodeCheckFunction, fmt.Errorf("functions not allowed: %s", strings.Join(denied, ", ")))
+	}
+	return nil
+}
+
+// end of file
@@ -0,0 +1,92 @@
+package expressionparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// namesIn parses input and returns what names gives for the tree.
+func namesIn(t *testing.T, input string, names func(Expr) []string) []string {
+	t.Helper()
+	expr, err := ParseString(input)
+	if err != nil {
+		t.Fatalf("ParseString(%q): %v", input, err)
+	}
+	return names(expr)
+}
+
+func TestVariables(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"1 + 2", nil},
+		{"x * x + y", []string{"x", "y"}},
+		{"b + a + b", []string{"b", "a"}},
+		{"let a = 2 in a * x", []string{"x"}},
+		{"let a = a + 1 in a", []string{"a"}},
+		{"(let a = 1 in a) + a", []string{"a"}},
+		{"let x = y in let y = x in x + y + z", []string{"y", "z"}},
+		{"sum(i, 1, n, i ^ 2)", []string{"n"}},
+		{`deriv(x ^ 2, "x", a)`, []string{"a"}},
+		{"f(g(u), [v, u])", []string{"u", "v"}},
+	}
+	for _, tt := range tests {
+		if got := namesIn(t, tt.input, Variables); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Variables(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestMissingVariables(t *testing.T) {
+	expr, err := ParseString("price * qty + tax + price")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := MissingVariables(expr, map[string]float64{"qty": 2})
+	if want := []string{"price", "tax"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MissingVariables = %q, want %q", got, want)
+	}
+	if got := MissingVariables(expr, map[string]float64{"price": 1, "qty": 2, "tax": 0}); got != nil {
+		t.Errorf("MissingVariables with every variable bound = %q", got)
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"x + 1", nil},
+		{"sin(x) + sin(y)", []string{"sin"}},
+		{"max(min(a, b), abs(min(c, 1)))", []string{"max", "min", "abs"}},
+		{"f(g(h(1))) + h(2) + g(3)", []string{"f", "g", "h"}},
+		{"let a = sqrt(x) in a ? exp(a) : [ln(a)]", []string{"sqrt", "exp", "ln"}},
+	}
+	for _, tt := range tests {
+		if got := namesIn(t, tt.input, Functions); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Functions(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestCheckFunctions(t *testing.T) {
+	expr, err := ParseString("max(sin(x), exp(y), system(1), sin(2), exec(3))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed := map[string]bool{"max": true, "sin": true, "exp": true}
+	err = CheckFunctions(expr, allowed)
+	if err == nil || err.Error() != "functions not allowed: system, exec" {
+		t.Errorf("CheckFunctions = %v, want the functions not allowed", err)
+	}
+	if ErrorCode(err) != CodeCheckFunction {
+		t.Errorf("CheckFunctions: code %s, want %s", ErrorCode(err), CodeCheckFunction)
+	}
+	allowed["system"], allowed["exec"] = true, true
+	if err := CheckFunctions(expr, allowed); err != nil {
+		t.Errorf("CheckFunctions with every function allowed = %v", err)
+	}
+}
+
+// end of file
@@ -0,0 +1,124 @@
+package expressionparser
+
+import (
+	"fmt"
+	"math"
+)
+
+// listItems returns the elements of a list, expanding a range.
+func listItems(l *list) []float64 {
+	if !l.isRange {
+		return l.items
+	}
+	items := make([]float64, 0, l.len())
+	l.each(func(x float64) bool {
+		items = append(items, x)
+		return true
+	})
+	return items
+}
+
+// compensatedSum accumulates a sum by Neumaier's variant of Kahan
+// summation, which carries the low-order bits each addition loses, so that
+// the error does not grow with the number of terms.
+type compensatedSum struct {
+	sum, c float64
+}
+
+// add adds x to the sum.
+func (s *compensatedSum) add(x float64) {
+	t := s.sum + x
+	if math.Abs(s.sum) >= math.Abs(x) {
+		s.c += (s.sum - t) + x
+	} else {
+		s.c += (x - t) + s.sum
+	}
+	s.sum = t
+}
+
+// value returns the sum.
+func (s *compensatedSum) value() float64 {
+	return s.sum + s.c
+}
+
+// dotLists returns the dot product of two lists of the same length, summing
+// the products with compensation; two empty lists are an error.
+func dotLists(a, b *list) (float64, error) {
+	if a.len() != b.len() {
+		return 0, withCode(CodeLengthMismatch, fmt.Errorf("dot of lists of lengths %d and %d", a.len(), b.len()))
+	}
+	if a.len() == 0 {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("dot of empty lists"))
+	}
+	xs, ys := listItems(a), listItems(b)
+	var s compensatedSum
+	for i, x := range xs {
+		s.add(x * ys[i])
+	}
+	return s.value(), nil
+}
+
+// normList returns the Euclidean norm of a list, which must not be empty.
+// The squares are summed scaled by the largest magnitude so far, as BLAS
+// nrm2 does, so that no square overflows or underflows where the norm
+// itself would not; an infinite element gives +Inf and a NaN one NaN. A
+// range of n numbers about their mean m has the closed form sqrt(n*(m^2 +
+// (n^2-1)/12)).
+func normList(l *list) (float64, error) {
+	n := float64(l.len())
+	if n == 0 {
+		return 0, withCode(CodeOutsideDomain, fmt.Errorf("norm of an empty list"))
+	}
+	if l.isRange {
+		m := (l.lo + l.hi) / 2
+		return math.Sqrt(n * (m*m + (n*n-1)/12)), nil
+	}
+	scale, ssq := 0.0, 1.0
+	inf, nan := false, false
+	l.each(func(x float64) bool {
+		switch ax := math.Abs(x); {
+		case math.IsNaN(x):
+			nan = true
+		case math.IsInf(x, 0):
+			inf = true
+		case ax == 0:
+		case scale < ax:
+			ssq = 1 + ssq*(scale/ax)*(scale/ax)
+			scale = ax
+		default:
+			ssq += (ax / scale) * (ax / scale)
+		}
+		return true
+	})
+	switch {
+	case nan:
+		return math.NaN(), nil
+	case inf:
+		return math.Inf(1), nil
+	}
+	return scale * math.Sqrt(ssq), nil
+}
+
+// cumsumList returns the running sums of a list, each compensated as sum
+// is; that of an empty list is empty.
+func cumsumList(l *list) []float64 {
+	items := make([]float64, 0, l.len())
+	var s compensatedSum
+	l.each(func(x float64) bool {
+		s.add(x)
+		items = append(items, s.value())
+		return true
+	})
+	return items
+}
+
+// reverseList returns the elements of a list in reverse order.
+func reverseList(l *list) []float64 {
+	items := append([]float64(nil), listItems(l)...)
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	return items
+}
+
+// end of file
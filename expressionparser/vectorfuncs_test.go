@@ -0,0 +1,74 @@
+package expressionparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVectorBuiltins(t *testing.T) {
+	env := map[string]Value{"v": ListValue([]float64{3, 4}), "w": ListValue([]float64{1, 2, 3})}
+	tests := []struct {
+		input string
+		want  string // the result as Value.String renders it
+	}{
+		{"dot([1,2,3], [4,5,6])", "32"},         // 4 + 10 + 18
+		{"dot(1..3, [1,1,1])", "6"},             // 1 + 2 + 3
+		{"dot(w, w * 2)", "28"},                 // 2 * (1 + 4 + 9)
+		{"dot([1e16, 1, -1e16], [1,1,1])", "1"}, // compensated
+		{"norm([3, 4])", "5"},
+		{"norm(v)", "5"},
+		{"norm(v * 2)", "10"},
+		{"norm([1, 2, 2])", "3"},
+		{"norm(1..3) == sqrt(14)", "true"},
+		{"norm([1e200, 1e200]) == sqrt(2) * 1e200", "true"},
+		{"norm([3e-200, 4e-200])", "5e-200"},
+		{"dot(v, v) == norm(v) ^ 2", "true"},
+		{"cumsum([1, 2, 3])", "[1, 3, 6]"},
+		{"cumsum(1..4)", "[1, 3, 6, 10]"},
+		{"cumsum(w + 1)", "[2, 5, 9]"},
+		{"sum(cumsum(v * 2))", "20"}, // 6 + 14
+		{"reverse([1, 2, 3])", "[3, 2, 1]"},
+		{"reverse(1..3)", "[3, 2, 1]"},
+		{"reverse(cumsum(w)) - w", "[5, 1, -2]"},
+		// Empty lists have running sums and a reverse
+		{"cumsum([])", "[]"},
+		{"reverse([])", "[]"},
+	}
+	for _, tt := range tests {
+		got, err := EvalValue(mustParse(t, tt.input), env)
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestVectorBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{"dot([], [])", CodeOutsideDomain, "dot of empty lists"},
+		{"dot([1], [1, 2])", CodeLengthMismatch, "dot of lists of lengths 1 and 2"},
+		{"norm([])", CodeOutsideDomain, "norm of an empty list"},
+		{"norm(5..1)", CodeOutsideDomain, "norm of an empty list"},
+		{"dot(1, 2)", CodeType, "cannot apply dot to number and number: argument 1 is a number"},
+		{`cumsum("a")`, CodeType, "cannot apply cumsum to string: argument 1 is a string"},
+	}
+	for _, tt := range tests {
+		_, err := EvalValue(mustParse(t, tt.input), nil)
+		if ErrorCode(err) != tt.code {
+			t.Errorf("%s: error %v, want %s", tt.input, err, tt.code)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.msg+" in ") {
+			t.Errorf("%s: error %q, want %q", tt.input, err, tt.msg)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,103 @@
+package expressionparser
+
+import (
+	"fmt"
+)
+
+// maxElementwiseRange bounds the length of a range expanded by element-wise
+// arithmetic, which unlike the aggregates needs every element in memory.
+const maxElementwiseRange = 1 << 24
+
+// isElementwise reports whether op applies element by element to a list:
+// the arithmetic operators.
+func isElementwise(op Token) bool {
+	switch op.Type {
+	case PLUS, MINUS, MULT, DIV, MOD, POW:
+		return true
+	}
+	return false
+}
+
+// elementwise applies the arithmetic operator op to a and b, one of them or
+// both a list: to a list and a number or bool, as in [1, 2, 3] * 2, the
+// operator applies to each element and the number, and to two lists, as in
+// [1, 2] + [10, 20], to the elements at each index, the lists having the
+// same length. A zero divisor is met according to the division by zero
+// policy, divisor being the right operand for its errors; pos locates the
+// operator.
+func (ev *Evaluator) elementwise(op Token, pos int, a, b Value, divisor Expr) (Value, error) {
+	symbol := operatorSymbol(op)
+	if !isElementwise(op) || !a.numeric() && a.kind != ListKind || !b.numeric() && b.kind != ListKind {
+		return Value{}, &TypeError{Op: symbol, Operands: []Kind{a.kind, b.kind}, Pos: pos}
+	}
+	var left, right []float64
+	var err error
+	if a.kind == ListKind {
+		if left, err = elements(a, pos); err != nil {
+			return Value{}, err
+		}
+	}
+	if b.kind == ListKind {
+		if right, err = elements(b, pos); err != nil {
+			return Value{}, err
+		}
+	}
+	n := len(left)
+	switch {
+	case a.kind != ListKind:
+		n = len(right)
+	case b.kind == ListKind && len(right) != n:
+		err := withCode(CodeLengthMismatch, fmt.Errorf("cannot apply %s to lists of lengths %d and %d", symbol, len(left), len(right)))
+		return Value{}, atOffset(err, pos)
+	}
+
+	items := make([]float64, n)
+	for i := range items {
+		x, y := a.num, b.num
+		if a.kind == ListKind {
+			x = left[i]
+		}
+		if b.kind == ListKind {
+			y = right[i]
+		}
+		if isDivision(op) && y == 0 {
+			value, ieee, err := ev.zeroDivision(op, divisor)
+			if err != nil {
+				return Value{}, err
+			}
+			if !ieee {
+				items[i] = value
+				continue
+			}
+		}
+		value, err := ev.binaryValue(op, pos, NumberValue(x), NumberValue(y))
+		if err != nil {
+			return Value{}, err
+		}
+		items[i] = value.num
+	}
+	return Value{kind: ListKind, list: &list{items: items}}, nil
+}
+
+// elements returns the elements of a list, expanding a range.
+func elements(v Value, pos int) ([]float64, error) {
+	if n := v.list.len(); v.list.isRange && n > maxElementwiseRange {
+		err := withCode(CodeOutsideDomain, fmt.Errorf("range of %d elements is too long for element-wise arithmetic, over %d", n, maxElementwiseRange))
+		return nil, atOffset(err, pos)
+	}
+	return v.AsList()
+}
+
+// negateList negates each element of a list.
+func negateList(v Value, pos int) (Value, error) {
+	items, err := elements(v, pos)
+	if err != nil {
+		return Value{}, err
+	}
+	for i := range items {
+		items[i] = -items[i]
+	}
+	return Value{kind: ListKind, list: &list{items: items}}, nil
+}
+
+// end of file
@@ -0,0 +1,103 @@
+package expressionparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestElementwise(t *testing.T) {
+	env := map[string]Value{"xs": ListValue([]float64{1, 2, 3}), "ys": ListValue([]float64{10, 20, 30})}
+	tests := []struct {
+		input string
+		want  string // the result as Value.String renders it
+	}{
+		{"[1,2,3] * 2", "[2, 4, 6]"},
+		{"2 * [1,2,3]", "[2, 4, 6]"},
+		{"[1,2,3] + [10,20,30]", "[11, 22, 33]"},
+		{"xs + ys", "[11, 22, 33]"},
+		{"ys - xs", "[9, 18, 27]"},
+		{"xs * xs", "[1, 4, 9]"},
+		{"ys / xs", "[10, 10, 10]"},
+		{"[1,2,3] - 1", "[0, 1, 2]"},
+		{"1 - [1,2,3]", "[0, -1, -2]"},
+		{"[1,2,3] / 2", "[0.5, 1, 1.5]"},
+		{"6 / [1,2,3]", "[6, 3, 2]"},
+		{"[7,8,9] % 4", "[3, 0, 1]"},
+		{"10 % [3,4]", "[1, 2]"},
+		{"[1,2,3] ^ 2", "[1, 4, 9]"},
+		{"2 ^ [1,2,3]", "[2, 4, 8]"},
+		{"-[1,2]", "[-1, -2]"},
+		{"(1..3) * 2", "[2, 4, 6]"},
+		{"(1..3) + [1,1,1]", "[2, 3, 4]"},
+		{"[] * 2", "[]"},
+		{"[] + []", "[]"},
+		// Aggregates accept the results
+		{"sum([1,2,3] * 2)", "12"},
+		{"avg([1,2] + [3,4])", "5"},
+		{"sum(1..10 * 1)", "55"},
+		{"count(xs * ys)", "3"},
+		{"sum(xs * ys)", "140"},
+	}
+	for _, tt := range tests {
+		got, err := EvalValue(mustParse(t, tt.input), env)
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestElementwiseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+		msg   string
+	}{
+		{"[1,2] + [1,2,3]", CodeLengthMismatch, "cannot apply + to lists of lengths 2 and 3"},
+		{"[1,2,3] * [1]", CodeLengthMismatch, "cannot apply * to lists of lengths 3 and 1"},
+		{"[] - [1]", CodeLengthMismatch, "cannot apply - to lists of lengths 0 and 1"},
+		{`[1,2] * "a"`, CodeType, "cannot apply * to list and string"},
+		{"[1,2] < 3", CodeType, "cannot apply < to list and number"},
+		{"[[1]] * 2", CodeType, "lists cannot be nested, and element 1 is a list"},
+	}
+	for _, tt := range tests {
+		_, err := EvalValue(mustParse(t, tt.input), nil)
+		if ErrorCode(err) != tt.code {
+			t.Errorf("%s: error %v, want %s", tt.input, err, tt.code)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.msg+" in ") {
+			t.Errorf("%s: error %q, want %q", tt.input, err, tt.msg)
+		}
+	}
+}
+
+func TestElementwiseDivisionByZero(t *testing.T) {
+	tests := []struct {
+		input        string
+		ieee, zeroed string
+	}{
+		{"[1,2] / [1,0]", "[1, +Inf]", "[1, 0]"},
+		{"1 / [2, 0]", "[0.5, +Inf]", "[0.5, 0]"},
+		{"[-1, 4] / 0", "[-Inf, +Inf]", "[0, 0]"},
+	}
+	ieee := NewEvaluator(WithDivisionByZero(DivideByZeroIEEE))
+	zeroed := NewEvaluator(WithDivisionByZero(DivideByZeroValue(0)))
+	for _, tt := range tests {
+		expr := mustParse(t, tt.input)
+		if _, err := EvalValue(expr, nil); ErrorCode(err) != CodeDivisionByZero {
+			t.Errorf("%s: error %v, want %s", tt.input, err, CodeDivisionByZero)
+		}
+		if got, err := ieee.EvalValue(expr); err != nil || got.String() != tt.ieee {
+			t.Errorf("%s under DivideByZeroIEEE = %v, %v; want %s", tt.input, got, err, tt.ieee)
+		}
+		if got, err := zeroed.EvalValue(expr); err != nil || got.String() != tt.zeroed {
+			t.Errorf("%s giving 0 = %v, %v; want %s", tt.input, got, err, tt.zeroed)
+		}
+	}
+}
+
+// end of file
@@ -0,0 +1,211 @@
+package expressionparser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// programCall is a function of a program, looked up once for Run.
+type programCall struct {
+	b     builtin
+	known bool
+}
+
+// link looks up the functions called by the program among the builtins.
+func (p *Program) link() {
+	p.calls = make([]programCall, len(p.funcs))
+	for i, name := range p.funcs {
+		if _, ok := stringBuiltins[name]; ok {
+			// Decoded programs may name them; leave them unknown
+			continue
+		}
+		if b, ok := builtins[name]; ok {
+			p.calls[i] = programCall{b: b, known: true}
+		}
+	}
+}
+
+// Slots arranges the values of the program's free variables in the order
+// of Names, as Run takes them. A variable missing from vars gives an
+// *UndefinedVariableError.
+func (p *Program) Slots(vars map[string]float64) ([]float64, error) {
+	slots := make([]float64, len(p.names))
+	for i, name := range p.names {
+		value, ok := vars[name]
+		if !ok {
+			return nil, &UndefinedVariableError{Name: name}
+		}
+		slots[i] = value
+	}
+	return slots, nil
+}
+
+// Run executes the program on an operand stack, with vars holding the
+// values of the free variables in the order of Names (see Slots). The
+// result and errors are those of Eval with the default configuration,
+// positions aside: the bytecode keeps no source spans, so errors such as a
+// division by zero carry no offset, except in the trees EVAL evaluates.
+func (p *Program) Run(vars []float64) (float64, error) {
+	return p.run(vars, 0)
+}
+
+// RunBudget executes the program like Run, stopping with a
+// *BudgetExceededError once maxOps instructions have been executed. Zero
+// means no limit.
+func (p *Program) RunBudget(vars []float64, maxOps int) (float64, error) {
+	return p.run(vars, maxOps)
+}
+
+// run executes the program, counting instructions against maxOps when it is positive.
+func (p *Program) run(vars []float64, maxOps int) (result float64, err error) {
+	if len(vars) != len(p.names) {
+		return 0, withCode(CodeInvalidArgument, fmt.Errorf("program has %d variable(s), got %d value(s)", len(p.names), len(vars)))
+	}
+	defer func() {
+		// Only a corrupt program, as UnmarshalBinary may decode, can fail here
+		if r := recover(); r != nil {
+			result, err = 0, withCode(CodeDecode, fmt.Errorf("invalid program: %v", r))
+		}
+	}()
+
+	code := p.code
+	stack := make([]float64, 0, 16)
+	var locals []float64
+	if p.locals > 0 {
+		locals = make([]float64, p.locals)
+	}
+	operand := func(pc *int) int {
+		n, size := binary.Uvarint(code[*pc:])
+		*pc += size
+		return int(n)
+	}
+
+	ops := 0
+	for pc := 0; pc < len(code); {
+		if maxOps > 0 {
+			if ops++; ops > maxOps {
+				return 0, &BudgetExceededError{Limit: maxOps, Pos: -1}
+			}
+		}
+		op := code[pc]
+		pc++
+
+		switch op {
+		case opConst:
+			stack = append(stack, p.constants[operand(&pc)])
+		case opVar:
+			stack = append(stack, vars[operand(&pc)])
+		case opLoad:
+			stack = append(stack, locals[operand(&pc)])
+		case opStore:
+			locals[operand(&pc)] = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+		case opNeg:
+			stack[len(stack)-1] = -stack[len(stack)-1]
+		case opNot:
+			stack[len(stack)-1] = truth(!isTrue(stack[len(stack)-1]))
+		case opJumpIfFalse:
+			offset := operand(&pc)
+			cond := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !isTrue(cond) {
+				pc += offset
+			}
+		case opJump:
+			pc += operand(&pc)
+		case opCall:
+			fn, n := operand(&pc), operand(&pc)
+			args := stack[len(stack)-n:]
+			value, err := p.call(fn, args)
+			if err != nil {
+				return 0, err
+			}
+			stack = append(stack[:len(stack)-n], value)
+		case opEval:
+			tree, n := p.trees[operand(&pc)], operand(&pc)
+			values := make(map[string]float64, n)
+			for i, name := range tree.names {
+				values[name] = stack[len(stack)-n+i]
+			}
+			value, err := EvalWithVars(tree.expr, values)
+			if err != nil {
+				return 0, err
+			}
+			stack = append(stack[:len(stack)-n], value)
+		default:
+			x, y := stack[len(stack)-2], stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			var r float64
+			switch op {
+			case opAdd:
+				r = x + y
+			case opSub:
+				r = x - y
+			case opMul:
+				r = x * y
+			case opDiv:
+				if y == 0 {
+					return 0, ErrDivisionByZero
+				}
+				r = x / y
+			case opMod:
+				if y == 0 {
+					return 0, ErrModuloByZero
+				}
+				r = math.Mod(x, y)
+			case opPow:
+				r = math.Pow(x, y)
+			case opLess:
+				r = truth(x < y)
+			case opLessEq:
+				r = truth(x <= y)
+			case opGreater:
+				r = truth(x > y)
+			case opGreaterEq:
+				r = truth(x >= y)
+			case opEq:
+				r = truth(x == y)
+			case opNotEq:
+				r = truth(x != y)
+			case opAnd:
+				r = truth(isTrue(x) && isTrue(y))
+			case opOr:
+				r = truth(isTrue(x) || isTrue(y))
+			case opBitAnd, opBitOr, opBitXor, opShl, opShr:
+				value, err := bitwise(bitwiseOperators[op], -1, x, y)
+				if err != nil {
+					return 0, err
+				}
+				r = value.num
+			default:
+				return 0, withCode(CodeDecode, fmt.Errorf("invalid opcode %d at %04d", op, pc-1))
+			}
+			stack[len(stack)-1] = r
+		}
+	}
+
+	if len(stack) != 1 {
+		return 0, withCode(CodeDecode, fmt.Errorf("invalid program: %d value(s) left on the stack", len(stack)))
+	}
+	return stack[0], nil
+}
+
+// call applies function fn of the program to args, as callBuiltin does.
+func (p *Program) call(fn int, args []float64) (float64, error) {
+	name, c := p.funcs[fn], p.calls[fn]
+	if !c.known {
+		return 0, fmt.Errorf("%w %s", ErrUnknownFunction, name)
+	}
+	if err := c.b.checkArgs(name, len(args)); err != nil {
+		return 0, err
+	}
+	if c.b.domain != nil {
+		if i := c.b.domain(args); i >= 0 {
+			return 0, domainError(name, args, i)
+		}
+	}
+	return c.b.fn(args)
+}
+
+// end of file
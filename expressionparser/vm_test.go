@@ -0,0 +1,86 @@
+package expressionparser
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProgramRunMatchesEval(t *testing.T) {
+	bindings := []map[string]float64{
+		corpusVars,
+		{"x": 0, "y": 0, "n": 0, "zero": 0},
+		{"x": -1, "y": 1e300, "n": -3, "zero": 0},
+		{"x": 0.5, "y": -0.5, "n": 2.5, "zero": 1},
+	}
+	for _, input := range append(programCorpus, evalCorpus...) {
+		expr, err := ParseString(input, WithCellReferences())
+		if err != nil {
+			t.Fatalf("ParseString(%q): %v", input, err)
+		}
+		prog, err := CompileProgram(expr)
+		if err != nil {
+			t.Fatalf("CompileProgram(%q): %v", input, err)
+		}
+		for _, vars := range bindings {
+			slots, err := prog.Slots(vars)
+			if err != nil {
+				// Only names the bindings lack, as Eval reports
+				if _, evalErr := EvalWithVars(expr, vars); ErrorCode(evalErr) != CodeUndefinedVar {
+					t.Errorf("Slots(%q): %v", input, err)
+				}
+				continue
+			}
+			got, gotErr := prog.Run(slots)
+			want, wantErr := EvalWithVars(expr, vars)
+			if !sameProgramResult(got, gotErr, want, wantErr) {
+				t.Errorf("%q with %v: Run gives %v, %v; Eval %v, %v", input, vars, got, gotErr, want, wantErr)
+			}
+		}
+	}
+}
+
+func TestProgramRunErrors(t *testing.T) {
+	prog, err := CompileProgram(mustParse(t, "x / (y - 1)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := prog.Names(); len(names) != 2 || names[0] != "x" || names[1] != "y" {
+		t.Fatalf("Names() = %v, want [x y]", names)
+	}
+	if _, err := prog.Run([]float64{1, 1}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("Run with y = 1: error %v, want ErrDivisionByZero", err)
+	}
+	if _, err := prog.Run([]float64{1}); ErrorCode(err) != CodeInvalidArgument {
+		t.Errorf("Run with one value: error %v, want %s", err, CodeInvalidArgument)
+	}
+	var undefined *UndefinedVariableError
+	if _, err := prog.Slots(map[string]float64{"x": 1}); !errors.As(err, &undefined) || undefined.Name != "y" {
+		t.Errorf("Slots without y: error %v, want an *UndefinedVariableError for y", err)
+	}
+	if got, err := prog.Run([]float64{6, 4}); err != nil || got != 2 {
+		t.Errorf("Run(6, 4) = %v, %v; want 2", got, err)
+	}
+}
+
+func BenchmarkProgramRun(b *testing.B) {
+	expr, err := ParseString("x * x + 3 * x * y - sqrt(n) / (1 + max(x, y))")
+	if err != nil {
+		b.Fatal(err)
+	}
+	prog, err := CompileProgram(expr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	slots, err := prog.Slots(corpusVars)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := prog.Run(slots); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// end of file
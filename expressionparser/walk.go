@@ -0,0 +1,37 @@
+package expressionparser
+
+// Children returns the direct subexpressions of expr in left-to-right order.
+// The returned slice may share storage with the node and must not be modified.
+func Children(expr Expr) []Expr {
+	switch v := expr.(type) {
+	case *BinaryOp:
+		return []Expr{v.Left, v.Right}
+	case *UnaryOp:
+		return []Expr{v.Operand}
+	case *FunctionCall:
+		return v.Args
+	case *ListLiteral:
+		return v.Elems
+	case *Let:
+		return []Expr{v.Value, v.Body}
+	case *Assign:
+		return []Expr{v.Value}
+	case *Conditional:
+		return []Expr{v.Cond, v.Then, v.Else}
+	}
+	return nil
+}
+
+// Walk traverses expr depth first, calling fn for each node before its
+// children, which are visited left to right. Returning false from fn skips
+// the children of that node.
+func Walk(expr Expr, fn func(Expr) bool) {
+	if expr == nil || !fn(expr) {
+		return
+	}
+	for _, child := range Children(expr) {
+		Walk(child, fn)
+	}
+}
+
+// end of file
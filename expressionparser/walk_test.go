@@ -0,0 +1,112 @@
+package expressionparser
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+// nodeTypes returns the node types SpanOf knows, as %T spells them, read
+// from its source so that a type added there must be added to the tests.
+func nodeTypes(t *testing.T) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), "expressionparser.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		fn, ok := n.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "SpanOf" {
+			return true
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if clause, ok := n.(*ast.CaseClause); ok {
+				for _, typ := range clause.List {
+					star := typ.(*ast.StarExpr)
+					types = append(types, "*expressionparser."+star.X.(*ast.Ident).Name)
+				}
+			}
+			return true
+		})
+		return false
+	})
+	if len(types) == 0 {
+		t.Fatal("found no node types in SpanOf")
+	}
+	return types
+}
+
+// nodeCounts returns the number of nodes of each type Walk visits in expr.
+func nodeCounts(expr Expr) map[string]int {
+	counts := map[string]int{}
+	Walk(expr, func(e Expr) bool {
+		counts[fmt.Sprintf("%T", e)]++
+		return true
+	})
+	return counts
+}
+
+func TestWalkCoversEveryNodeType(t *testing.T) {
+	expr, err := ParseString(`let a = [1, "s", 2 km] in x ? -a : f(A1 + 1, B1:B2)`, WithCellReferences())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := List(expr, &Assign{Name: "y", Value: Num(1)})
+	counts := nodeCounts(tree)
+	for _, typ := range nodeTypes(t) {
+		if counts[typ] == 0 {
+			t.Errorf("Walk does not reach a %s in %s", typ, ToSExpr(tree))
+		}
+	}
+}
+
+func TestWalkCounts(t *testing.T) {
+	expr, err := ParseString("(2 + x) * max(x, -1, [3, 4])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{
+		"*expressionparser.BinaryOp":     2,
+		"*expressionparser.Number":       4,
+		"*expressionparser.Variable":     2,
+		"*expressionparser.FunctionCall": 1,
+		"*expressionparser.UnaryOp":      1,
+		"*expressionparser.ListLiteral":  1,
+	}
+	got := nodeCounts(expr)
+	if len(got) != len(want) {
+		t.Errorf("counts %v, want %v", got, want)
+	}
+	for typ, n := range want {
+		if got[typ] != n {
+			t.Errorf("%d nodes of type %s, want %d", got[typ], typ, n)
+		}
+	}
+}
+
+func TestWalkOrderAndSkip(t *testing.T) {
+	expr, err := ParseString("f(a - b, c) + d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var visited []string
+	Walk(expr, func(e Expr) bool {
+		switch v := e.(type) {
+		case *Variable:
+			visited = append(visited, v.Name)
+		case *BinaryOp:
+			visited = append(visited, v.Op.Value)
+			return v.Op.Type != MINUS
+		case *FunctionCall:
+			visited = append(visited, v.Name)
+		}
+		return true
+	})
+	if got, want := fmt.Sprint(visited), "[+ f - c d]"; got != want {
+		t.Errorf("visited %s, want %s", got, want)
+	}
+}
+
+// end of file
@@ -0,0 +1,3 @@
+module github.com/ColinWilcox1967/GoLang-ExpressionParser
+
+go 1.22